@@ -0,0 +1,157 @@
+package estimate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/log"
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func NewCmdEstimate() *cobra.Command {
+	exportFlags := data.CmdExportFlags{}
+	options := utils.EstimateOptions{}
+	var jsonOutput bool
+
+	estimateCmd := &cobra.Command{
+		Use:   "estimate [flags]",
+		Short: "Estimate the API requests, duration and archive size of an export",
+		Long: "Estimate the API requests, rate-limit consumption, duration and archive size of exporting a " +
+			"repository or a whole workspace, without running the export. Each repository costs one or two " +
+			"API requests to sample.\n\n" +
+			"The repository can also be given as a URL, e.g. https://bitbucket.org/workspace/repo, instead of --workspace and --repo.",
+		Args: cobra.MaximumNArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := utils.ApplyRepositoryURLArg(cmd, args, &exportFlags); err != nil {
+				return err
+			}
+			problems := []error{utils.ValidateExportCommand(&exportFlags)}
+			if options.RateLimitPerHour <= 0 {
+				problems = append(problems, errors.New("--rate-limit must be positive"))
+			}
+			if options.RequestLatency <= 0 || options.CloneThroughput <= 0 {
+				problems = append(problems, errors.New("--request-latency and --clone-throughput must be positive"))
+			}
+			return utils.JoinProblems(problems...)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			logger, err := log.NewLogger(exportFlags.Debug)
+			if err != nil {
+				return fmt.Errorf("failed to initialize logger: %w", err)
+			}
+			defer func() {
+				_ = logger.Sync()
+			}()
+
+			utils.SetupEnvironmentCredentials(&exportFlags)
+			if err := utils.ValidateExportFlags(&exportFlags); err != nil {
+				return err
+			}
+			options.Concurrency = exportFlags.Concurrency
+			options.Nice = exportFlags.Nice
+			estimate, err := utils.RunEstimate(&exportFlags, options, logger)
+			if err != nil {
+				return err
+			}
+			logger.Debug("Export estimated", zap.Int("sampling_requests", estimate.SamplingRequests))
+			return printEstimate(cmd.OutOrStdout(), estimate, jsonOutput)
+		},
+	}
+
+	estimateCmd.Flags().SortFlags = false
+	utils.SetupCommandUsageTemplate(estimateCmd, 100)
+
+	estimateCmd.Flags().StringVarP(&exportFlags.BitbucketAPIURL, "bbc-api-url", "a",
+		"https://api.bitbucket.org/2.0", "Bitbucket API to use")
+	estimateCmd.Flags().StringVarP(&exportFlags.BitbucketAccessToken, "access-token", "t", "",
+		"Bitbucket workspace access token for authentication (env: BITBUCKET_ACCESS_TOKEN)")
+	estimateCmd.Flags().StringVarP(&exportFlags.BitbucketAPIToken, "api-token", "", "",
+		"Bitbucket API token for authentication (env: BITBUCKET_API_TOKEN)")
+	estimateCmd.Flags().StringVarP(&exportFlags.BitbucketEmail, "email", "e", "",
+		"Atlassian account email for API token authentication (env: BITBUCKET_EMAIL)")
+	estimateCmd.Flags().StringVarP(&exportFlags.BitbucketUser, "user", "u", "",
+		"Bitbucket username for basic authentication (env: BITBUCKET_USERNAME)")
+	estimateCmd.Flags().StringVarP(&exportFlags.BitbucketAppPass, "app-password", "p", "",
+		"Bitbucket app password for basic authentication (env: BITBUCKET_APP_PASSWORD)")
+	estimateCmd.Flags().StringVar(&exportFlags.BitbucketOAuthKey, "oauth-key", "",
+		"Bitbucket OAuth consumer key for client-credentials authentication (env: BITBUCKET_OAUTH_KEY)")
+	estimateCmd.Flags().StringVar(&exportFlags.BitbucketOAuthSecret, "oauth-secret", "",
+		"Bitbucket OAuth consumer secret for client-credentials authentication (env: BITBUCKET_OAUTH_SECRET)")
+	estimateCmd.Flags().StringSliceVar(&exportFlags.AuthFallback, "auth-fallback", nil,
+		"Authentication methods to try in order when Bitbucket answers 401 or 403 for an endpoint: access-token, api-token, app-password")
+	estimateCmd.Flags().StringVar(&exportFlags.Proxy, "proxy", "",
+		"Proxy URL for Bitbucket API and git traffic: http://, https://, socks5:// or socks5h:// (default: HTTPS_PROXY)")
+	estimateCmd.Flags().StringVar(&exportFlags.CABundle, "ca-bundle", "",
+		"PEM file of CA certificates to trust for Bitbucket traffic, e.g. of a TLS-inspecting proxy")
+	estimateCmd.Flags().BoolVar(&exportFlags.InsecureSkipTLS, "insecure-skip-tls", false,
+		"Skip TLS certificate verification for Bitbucket API and git traffic (not recommended)")
+	estimateCmd.Flags().StringVarP(&exportFlags.Workspace, "workspace", "w", "",
+		"Bitbucket workspace name")
+	estimateCmd.Flags().VarP(utils.NewRepositoryListValue(&exportFlags.Repository), "repo", "r",
+		"Name of the repository to estimate; repeat or separate with commas for several")
+	estimateCmd.Flags().BoolVar(&exportFlags.AllRepos, "all-repos", false,
+		"Estimate every repository in the workspace instead of a single --repo")
+	estimateCmd.Flags().StringVar(&exportFlags.IncludeReposFile, "include-repos", "",
+		"With --all-repos, only estimate repositories listed in this file (one slug or glob per line)")
+	estimateCmd.Flags().StringVar(&exportFlags.ExcludeReposFile, "exclude-repos", "",
+		"With --all-repos, skip repositories listed in this file (one slug or glob per line)")
+	estimateCmd.Flags().BoolVar(&exportFlags.OpenPRsOnly, "open-prs-only", false,
+		"Estimate an export of open pull requests only")
+	estimateCmd.Flags().IntVar(&exportFlags.Concurrency, "concurrency", 1,
+		"Simulate this many workers fetching pull request comments (1-16)")
+	estimateCmd.Flags().BoolVar(&exportFlags.Nice, "nice", false,
+		"Simulate --nice: one request at a time, delay between requests, half-size pages")
+	estimateCmd.Flags().IntVar(&options.RateLimitPerHour, "rate-limit", utils.DefaultRateLimitPerHour,
+		"Hourly API request quota of the workspace")
+	estimateCmd.Flags().DurationVar(&options.RequestLatency, "request-latency", utils.DefaultRequestLatency,
+		"Average duration of a Bitbucket API request")
+	estimateCmd.Flags().Float64Var(&options.CloneThroughput, "clone-throughput", utils.DefaultCloneThroughput,
+		"Clone speed in MiB per second")
+	estimateCmd.Flags().StringVar(&exportFlags.ConfigFile, "config", "",
+		"YAML configuration file (e.g. per-endpoint API base URL overrides)")
+	estimateCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the estimate as JSON")
+	estimateCmd.Flags().BoolVarP(&exportFlags.Debug, "debug", "d", false, "Enable debug logging")
+
+	return estimateCmd
+}
+
+func printEstimate(out io.Writer, estimate *data.ExportEstimate, jsonOutput bool) error {
+	if jsonOutput {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(estimate)
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "REPOSITORY\tSIZE\tPULL REQUESTS\tCOMMENTS\tAPI REQUESTS\tARCHIVE")
+	for _, repo := range estimate.Repositories {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%d\t~%d\t%d\t%s\n", repo.Repository, utils.FormatBytes(uint64(repo.SizeBytes)),
+			repo.PullRequests, repo.Comments, repo.APIRequests, utils.FormatBytes(uint64(repo.ArchiveBytes)))
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(out, "\nAPI requests:     %d (%.0f%% of the hourly quota of %d)\n"+
+		"Duration:         %s (API %s with %d worker(s), cloning %s)\n"+
+		"Archive size:     %s\n"+
+		"Sampling cost:    %d API request(s)\n",
+		estimate.APIRequests, estimate.QuotaShare*100, estimate.RateLimitPerHour,
+		seconds(estimate.DurationSeconds), seconds(estimate.APIDurationSeconds), estimate.Concurrency,
+		seconds(estimate.CloneDurationSeconds),
+		utils.FormatBytes(uint64(estimate.ArchiveBytes)),
+		estimate.SamplingRequests)
+	return err
+}
+
+func seconds(s float64) string {
+	return (time.Duration(s) * time.Second).String()
+}
@@ -0,0 +1,85 @@
+package estimate
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCmdEstimate(t *testing.T) {
+	cmd := NewCmdEstimate()
+
+	assert.Equal(t, "estimate [flags]", cmd.Use)
+	for _, name := range []string{"workspace", "repo", "all-repos", "concurrency", "nice", "rate-limit",
+		"request-latency", "clone-throughput", "json"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "flag %s should be defined", name)
+	}
+}
+
+func TestEstimateValidatesFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"-r", "repo"}, "a bitbucket workspace must be specified"},
+		{[]string{"-w", "workspace"}, "a bitbucket repository must be specified"},
+		{[]string{"-w", "workspace", "-r", "repo", "--all-repos"}, "--repo cannot be combined with --all-repos"},
+		{[]string{"-w", "workspace", "-r", "repo", "--rate-limit", "0"}, "--rate-limit must be positive"},
+	}
+	for _, tt := range tests {
+		cmd := NewCmdEstimate()
+		cmd.SetArgs(tt.args)
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		assert.ErrorContains(t, cmd.Execute(), tt.err, tt.args)
+	}
+}
+
+func TestEstimateCommand(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repositories/workspace/repo":
+			_, _ = w.Write([]byte(`{"slug": "repo", "size": 5242880}`))
+		case "/repositories/workspace/repo/pullrequests":
+			_, _ = w.Write([]byte(`{"size": 10, "values": [{"id": 1, "comment_count": 2}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+	cmd := NewCmdEstimate()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"-a", server.URL, "-t", "token", "-w", "workspace", "-r", "repo", "--json"})
+	require.NoError(t, cmd.Execute())
+
+	var estimate data.ExportEstimate
+	require.NoError(t, json.Unmarshal(out.Bytes(), &estimate))
+	require.Len(t, estimate.Repositories, 1)
+	assert.Equal(t, 10, estimate.Repositories[0].PullRequests)
+	assert.Equal(t, 20, estimate.Repositories[0].Comments)
+	assert.Equal(t, 2, estimate.SamplingRequests)
+}
+
+func TestPrintEstimate(t *testing.T) {
+	var out bytes.Buffer
+	err := printEstimate(&out, &data.ExportEstimate{
+		Repositories: []data.RepositoryEstimate{{Repository: "repo", SizeBytes: 3 << 30, PullRequests: 12,
+			Comments: 40, APIRequests: 15, ArchiveBytes: 3 << 30}},
+		APIRequests: 17, RateLimitPerHour: 1000, QuotaShare: 0.02, Concurrency: 1,
+		APIDurationSeconds: 7, CloneDurationSeconds: 154, DurationSeconds: 161, ArchiveBytes: 3 << 30,
+		SamplingRequests: 2,
+	}, false)
+
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), "repo        3.0 GiB  12             ~40       15            3.0 GiB")
+	assert.Contains(t, out.String(), "API requests:     17 (2% of the hourly quota of 1000)")
+	assert.Contains(t, out.String(), "Duration:         2m41s (API 7s with 1 worker(s), cloning 2m34s)")
+}
@@ -1,8 +1,10 @@
 package export
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/katiem0/gh-bbc-exporter/internal/data"
 	"github.com/katiem0/gh-bbc-exporter/internal/log"
@@ -17,15 +19,14 @@ func NewCmdExport() *cobra.Command {
 	exportCmd := &cobra.Command{
 		Use:   "export [flags]",
 		Short: "Export repository and metadata from Bitbucket Cloud",
-		Long:  "Export repository and metadata from Bitbucket Cloud for GitHub Cloud import.",
+		Long: "Export repository and metadata from Bitbucket Cloud for GitHub Cloud import.\n\n" +
+			"The repository can also be given as a URL, e.g. https://bitbucket.org/workspace/repo, instead of --workspace and --repo.",
+		Args: cobra.MaximumNArgs(1),
 		PreRunE: func(exportCmd *cobra.Command, args []string) error {
-			if len(cmdExportFlags.Workspace) == 0 {
-				return errors.New("a bitbucket workspace must be specified")
+			if err := utils.ApplyRepositoryURLArg(exportCmd, args, &cmdExportFlags); err != nil {
+				return err
 			}
-			if len(cmdExportFlags.Repository) == 0 {
-				return errors.New("a bitbucket repository must be specified")
-			}
-			return nil
+			return utils.ValidateExportCommand(&cmdExportFlags)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cmd.SilenceUsage = true
@@ -37,7 +38,10 @@ func NewCmdExport() *cobra.Command {
 				_ = logger.Sync()
 			}()
 			zap.ReplaceGlobals(logger)
-			return runCmdExport(&cmdExportFlags, logger)
+
+			ctx, stop := utils.InterruptContext(cmd.Context(), logger)
+			defer stop()
+			return runCmdExport(ctx, &cmdExportFlags, logger)
 		},
 	}
 
@@ -58,10 +62,22 @@ func NewCmdExport() *cobra.Command {
 		"Bitbucket username for basic authentication (env: BITBUCKET_USERNAME)")
 	exportCmd.PersistentFlags().StringVarP(&cmdExportFlags.BitbucketAppPass, "app-password", "p", "",
 		"Bitbucket app password for basic authentication (env: BITBUCKET_APP_PASSWORD)")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.BitbucketOAuthKey, "oauth-key", "",
+		"Bitbucket OAuth consumer key for client-credentials authentication (env: BITBUCKET_OAUTH_KEY)")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.BitbucketOAuthSecret, "oauth-secret", "",
+		"Bitbucket OAuth consumer secret for client-credentials authentication (env: BITBUCKET_OAUTH_SECRET)")
+	exportCmd.PersistentFlags().StringSliceVar(&cmdExportFlags.AuthFallback, "auth-fallback", nil,
+		"Authentication methods to try in order when Bitbucket answers 401 or 403 for an endpoint: access-token, api-token, app-password")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.Proxy, "proxy", "",
+		"Proxy URL for Bitbucket API and git traffic: http://, https://, socks5:// or socks5h:// (default: HTTPS_PROXY)")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.CABundle, "ca-bundle", "",
+		"PEM file of CA certificates to trust for Bitbucket traffic, e.g. of a TLS-inspecting proxy")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.InsecureSkipTLS, "insecure-skip-tls", false,
+		"Skip TLS certificate verification for Bitbucket API and git traffic (not recommended)")
 	exportCmd.PersistentFlags().StringVarP(&cmdExportFlags.Workspace, "workspace", "w", "",
 		"Bitbucket workspace name")
-	exportCmd.PersistentFlags().StringVarP(&cmdExportFlags.Repository, "repo", "r", "",
-		"Name of the repository to export from Bitbucket Cloud")
+	exportCmd.PersistentFlags().VarP(utils.NewRepositoryListValue(&cmdExportFlags.Repository), "repo", "r",
+		"Name of the repository to export from Bitbucket Cloud; repeat or separate with commas to export several into one archive")
 	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.TempDir, "temp-dir", "",
 		"Temporary directory for cloning (env: BITBUCKET_TEMP_DIR)")
 	exportCmd.PersistentFlags().StringVarP(&cmdExportFlags.OutputDir, "output", "o", "",
@@ -72,18 +88,155 @@ func NewCmdExport() *cobra.Command {
 		"Export pull requests created on or after this date (format: YYYY-MM-DD)")
 	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.SkipCommitLookup, "skip-commit-lookup", false,
 		"Skip Bitbucket API lookups to retrieve commit SHAs (use local lookup only)")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.ColdStorageBefore, "cold-storage-before", "",
+		"Move pull requests created before this date into a cold-storage bundle outside the archive (format: YYYY-MM-DD)")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.ColdStorageDeclined, "cold-storage-declined", false,
+		"Move declined pull requests into the cold-storage bundle outside the archive")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.FailOnUnsafePaths, "fail-on-unsafe-paths", false,
+		"Abort the export if the repository contains file paths GitHub rejects (e.g. .git entries, NTFS-invalid names)")
+	exportCmd.PersistentFlags().StringSliceVar(&cmdExportFlags.PRsTouchingPaths, "prs-touching-path", nil,
+		"Export only pull requests that modified paths matching this glob (e.g. src/service-a/**); repeatable")
+	exportCmd.PersistentFlags().StringArrayVar(&cmdExportFlags.SubdirSplits, "subdir-split", nil,
+		"Carve a sub-directory into its own archive (format: path=new-repo-name); repeatable, requires git-filter-repo")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.SplitLinkBase, "split-link-base", "",
+		"Base URL of the split target repositories (e.g. https://github.com/your-org) for links to pull requests in another split")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.ReactionMapFile, "reaction-map", "",
+		"YAML file mapping Bitbucket emoji/approval shortcodes to GitHub reaction types")
+	exportCmd.PersistentFlags().DurationVar(&cmdExportFlags.MaxDuration, "max-duration", 0,
+		"Abort the export after this long (e.g. 2h30m), writing a checkpoint and partial report")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.ConfigFile, "config", "",
+		"YAML configuration file (e.g. per-endpoint API base URL overrides)")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.KeepAmbiguousPRs, "keep-ambiguous-prs", false,
+		"Keep pull requests whose branch names look like commit SHAs by prefixing the refs with 'bb-'")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.CommentFormatter, "comment-formatter", "markdown",
+		"Comment body format: markdown (raw Bitbucket Markdown), html-to-md (convert rendered HTML), or raw (unmodified)")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.Nice, "nice", false,
+		"Throttle Bitbucket API usage (one request at a time, delay between requests, half-size pages) for shared quotas")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.NDJSON, "ndjson", false,
+		"Also write NDJSON copies of pull requests, comments and users to an analytics/ directory outside the archive")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.CompareStats, "compare-stats", false,
+		"Compare the archive with Bitbucket's repository size, commit, branch and pull request counts and report discrepancies")
+	exportCmd.PersistentFlags().StringArrayVar(&cmdExportFlags.PruneRefs, "prune-ref", nil,
+		"Also delete refs under this prefix from the clone (refs/pull, refs/stash and refs/notes are pruned by default); repeatable")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.KeepNotes, "keep-notes", false,
+		"Keep refs/notes in the cloned repository instead of pruning them")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.MaxPackSize, "max-pack-size", "1g",
+		"Repack cloned repositories whose packfiles exceed this size into smaller packs (0 disables)")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.CompactJSON, "compact-json", false,
+		"Write the JSON files in the archive minified instead of indented")
+	exportCmd.PersistentFlags().IntVar(&cmdExportFlags.RecordsPerFile, "records-per-file", utils.DefaultRecordsPerFile,
+		"Records per archive JSON file; more go to numbered files such as pull_requests_000002.json (0 writes one file per type)")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.ExportRulesets, "export-rulesets", false,
+		"Translate Bitbucket branch restrictions into rulesets.json and an apply-rulesets.sh script outside the archive")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.ExportWatchers, "export-watchers", false,
+		"List the Bitbucket watchers of each repository in watchers.json outside the archive, to ask them to watch it on GitHub")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.IssueLinks, "issue-links", false,
+		"List the Bitbucket issues each pull request references through its branch name, title or description in issue-links.json outside the archive")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.ProtectionHealth, "protection-health", false,
+		"Compare each branch restriction with the recent commits and pull request merges of its branches in protection-health.json outside the archive")
+	exportCmd.PersistentFlags().IntVar(&cmdExportFlags.ProtectionHealthDays, "protection-health-days", utils.DefaultProtectionHealthDays,
+		"Days of branch activity --protection-health looks at")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.FixedTimestamps, "fixed-timestamps", false,
+		"Use a fixed time (SOURCE_DATE_EPOCH, or the Unix epoch) for generated timestamps so unchanged data re-exports identically")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.Wave, "wave", "",
+		"Migration wave name recorded in the manifest and report, and added to the output, archive and report names")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.OutputPrefix, "output-prefix", "",
+		"Prefix of the archive and export report names, recorded in the manifest and report (e.g. acme-wave2-)")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.UsersScope, "users-scope", "workspace",
+		"Users written to the archive: workspace (all members), contributors (pull request and comment authors), or none")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.LongPaths, "long-paths", "gnu",
+		"Archive paths over 100 characters outside git repositories: gnu (long-name entries), truncate, or error")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.TarFormat, "tar-format", "ustar",
+		"Archive header format: ustar (GNU entries only where needed), gnu, or pax (long names, large files, UTF-8)")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.DropPendingReviews, "drop-pending-reviews", false,
+		"Leave out pull request reviews whose inline comments were never published (Bitbucket pending comments)")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.ExportPatches, "export-patches", false,
+		"Save each open pull request's diff as patches/<pr-id>.patch next to the archive for audit")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.GenerateCodeowners, "generate-codeowners", false,
+		"Write a CODEOWNERS file per repository to migration-notes/ from default reviewers and --config path rules")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.TokenRefreshCmd, "token-refresh-cmd", "",
+		"Command that prints a new Bitbucket token; run on a 401 response before retrying the request")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.Consistency, "consistency", utils.ConsistencyBestEffort,
+		"How to handle pull requests newer than the clone: best-effort (fetch missing commits) or strict (leave them out)")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.TopUpFetch, "top-up-fetch", false,
+		"Fetch every repository again after pull requests and comments are exported to include commits pushed meanwhile")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.LocalMirror, "local-mirror", "",
+		"Existing bare mirror of the repository to export instead of cloning it from Bitbucket (checked with git fsck)")
+	exportCmd.PersistentFlags().IntVar(&cmdExportFlags.CloneRetries, "clone-retries", utils.DefaultCloneRetries,
+		"Retries of a failed git clone per clone URL, with exponential backoff")
+	exportCmd.PersistentFlags().DurationVar(&cmdExportFlags.CloneRetryDelay, "clone-retry-delay", utils.DefaultCloneRetryDelay,
+		"Wait before the first clone retry; doubled for each further retry")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.CloneProtocol, "clone-protocol", utils.CloneProtocolHTTPS,
+		"Clone URL to try first: https or ssh; the other is tried when cloning with it fails")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.FailOnCloneError, "fail-on-clone-error", false,
+		"Fail when a clone has none of the branches Bitbucket lists, a wiki cannot be cloned, or the top-up fetch fails")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.SkipGit, "skip-git", false,
+		"Export metadata only: store an empty placeholder repository instead of cloning each repository")
+	exportCmd.PersistentFlags().IntVar(&cmdExportFlags.GitDepth, "git-depth", 0,
+		"Clone at most this many commits of history per branch (0 clones the full history)")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.VerifyFrozen, "verify-frozen", false,
+		"Fail the export if branches, tags or pull requests changed in Bitbucket while it ran")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.AsOf, "as-of", "",
+		"Export the repository as it was at this date or time: later pull requests and comments are left out, branches and tags are moved back (format: YYYY-MM-DD or RFC 3339)")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.GhostUser, "ghost-user", utils.DefaultGhostUser,
+		"Login that pull requests and comments by deleted Bitbucket accounts are attributed to in the users file")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.Resume, "resume", false,
+		"Continue an interrupted export from its checkpoint in the output directory instead of starting over")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.DownloadAvatar, "download-avatar", false,
+		"Save the workspace avatar under organization/ next to the archive, to upload to the GitHub organization")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.MergeCommitCheck, "merge-commit-check", utils.MergeCommitCheckReport,
+		"Verify merge commits of merged pull requests against the mirror: report, clear (also remove mismatched merge commits) or off")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.PRFooterTemplate, "pr-footer-template", "",
+		"Go template appended to every pull request description, e.g. 'Migrated from Bitbucket PR #{{.ID}} on {{.Date}}; original author {{.Author}}'")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.CommentFooter, "comment-footer-template", "",
+		"Go template appended to every pull request comment, e.g. 'Originally posted at {{.URL}} on {{.CreatedAt}}'")
+	exportCmd.PersistentFlags().IntVar(&cmdExportFlags.Concurrency, "concurrency", 1,
+		"Number of workers fetching pull request comments and resolving commit SHAs in parallel (1-16)")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.AnalyzeDocs, "analyze-docs", false,
+		"Scan markdown files for Bitbucket-specific syntax ([TOC], wiki links, src/ links) and list them in docs-report.json")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.UserMappingFile, "user-mapping", "",
+		"CSV file mapping Bitbucket user UUIDs or display names to GitHub logins and emails (header: bitbucket,github_login,email)")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.RedactionRulesFile, "redaction-rules", "",
+		"YAML file of regular expressions replaced in pull request descriptions and comment bodies, e.g. internal hostnames")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.DownloadAttachments, "download-attachments", false,
+		"Store Bitbucket-hosted images and files linked from pull request descriptions and comments in the archive's attachments/")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.PredictPRNumbers, "predict-pr-numbers", false,
+		"Predict the GitHub number of each pull request in pr-number-map.json and rewrite #123 references in comments to match")
+	exportCmd.PersistentFlags().IntVar(&cmdExportFlags.PRNumberOffset, "pr-number-offset", 0,
+		"Issues and pull requests the target GitHub repository already has; predicted numbers start after them")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.GitOutput, "git-output", utils.GitOutputMirror,
+		"How repositories are stored in the archive: mirror, bundle (a git bundle per repository, not importable by GEI) or both")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.VerifyArchive, "verify-archive", false,
+		"Read the archive back after creating it and fail if its entries or sizes do not match the export directory")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.PreserveFileModes, "preserve-file-modes", false,
+		"Keep the host permission bits of archive entries instead of normalizing them to 0644 and 0755")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.ProgressFormat, "progress-format", utils.ProgressFormatText,
+		"Progress output: text (log lines only) or json (one progress event per line on stdout, for automation)")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.LinkTarget, "link-target", "",
+		"GitHub repository URL that commit, compare and source links in comments are rewritten to ({repository} is replaced with the repository slug)")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.FeatureChecklist, "feature-checklist", false,
+		"List the Bitbucket features each repository uses (LFS, pipelines, wiki, issues, ...) and their migration status in feature-checklist.json")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.ConvertPipelines, "convert-pipelines", false,
+		"Translate bitbucket-pipelines.yml into GitHub Actions workflow stubs under migration-notes and list unsupported features in pipelines-conversion.json")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.Encrypt, "encrypt", "",
+		"Encrypt the archive for a recipient before it leaves the machine (format: age:<recipient> or gpg:<recipient>)")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.AllRepos, "all-repos", false,
+		"Export every repository in the workspace instead of a single --repo")
+	exportCmd.PersistentFlags().BoolVar(&cmdExportFlags.GroupByProject, "group-by-project", false,
+		"With --all-repos, produce one archive per Bitbucket project")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.IncludeReposFile, "include-repos", "",
+		"With --all-repos, only export repositories listed in this file (one slug or glob per line)")
+	exportCmd.PersistentFlags().StringVar(&cmdExportFlags.ExcludeReposFile, "exclude-repos", "",
+		"With --all-repos, skip repositories listed in this file (one slug or glob per line)")
 	exportCmd.PersistentFlags().BoolVarP(&cmdExportFlags.Debug, "debug", "d", false, "Enable debug logging")
 
 	if err := exportCmd.MarkPersistentFlagRequired("workspace"); err != nil {
 		fmt.Printf("Error marking workspace flag as required: %v\n", err)
 	}
-	if err := exportCmd.MarkPersistentFlagRequired("repo"); err != nil {
-		fmt.Printf("Error marking repository flag as required: %v\n", err)
-	}
 	return exportCmd
 }
 
-func runCmdExport(cmdExportFlags *data.CmdExportFlags, logger *zap.Logger) error {
+func runCmdExport(ctx context.Context, cmdExportFlags *data.CmdExportFlags, logger *zap.Logger) error {
 	logger.Info("Starting Bitbucket Cloud export",
 		zap.String("workspace", cmdExportFlags.Workspace),
 		zap.String("repository", cmdExportFlags.Repository))
@@ -95,8 +248,16 @@ func runCmdExport(cmdExportFlags *data.CmdExportFlags, logger *zap.Logger) error
 	if err := utils.ValidateExportFlags(cmdExportFlags); err != nil {
 		return err
 	}
+	if err := utils.CheckRuntimePrerequisites(utils.DetectRuntime(), cmdExportFlags.OutputDir, cmdExportFlags.TempDir, logger); err != nil {
+		return err
+	}
 
-	if cmdExportFlags.BitbucketAccessToken != "" {
+	if len(cmdExportFlags.AuthFallback) > 0 {
+		logger.Info("Using authentication fallback chain",
+			zap.Strings("methods", cmdExportFlags.AuthFallback))
+	} else if cmdExportFlags.BitbucketOAuthKey != "" {
+		logger.Info("Using OAuth consumer authentication")
+	} else if cmdExportFlags.BitbucketAccessToken != "" {
 		logger.Info("Using workspace access token authentication")
 	} else if cmdExportFlags.BitbucketAPIToken != "" {
 		logger.Info("Using API token authentication")
@@ -105,46 +266,17 @@ func runCmdExport(cmdExportFlags *data.CmdExportFlags, logger *zap.Logger) error
 			zap.String("username", cmdExportFlags.BitbucketUser))
 	}
 
-	client := utils.NewClient(
-		cmdExportFlags.BitbucketAPIURL,
-		cmdExportFlags.BitbucketAccessToken,
-		cmdExportFlags.BitbucketAPIToken,
-		cmdExportFlags.BitbucketEmail,
-		cmdExportFlags.BitbucketUser,
-		cmdExportFlags.BitbucketAppPass,
-		logger,
-		cmdExportFlags.OutputDir,
-		cmdExportFlags.SkipCommitLookup,
-	)
-
-	if cmdExportFlags.OpenPRsOnly {
-		logger.Info("Filtering: Only open PRs will be exported")
-	}
-	if cmdExportFlags.PRsFromDate != "" {
-		logger.Info("Filtering: PRs from date", zap.String("from_date", cmdExportFlags.PRsFromDate))
-	}
-
-	// Apply commit SHA expansion behavior
-	if cmdExportFlags.SkipCommitLookup {
-		logger.Info("Skipping Bitbucket API commit SHA lookups (will look locally only)")
-	}
-
-	exporter := utils.NewExporter(client, cmdExportFlags.OutputDir, logger, cmdExportFlags.OpenPRsOnly, cmdExportFlags.PRsFromDate)
-
-	if cmdExportFlags.TempDir != "" {
-		exporter.SetTempDir(cmdExportFlags.TempDir)
-		logger.Debug("Using custom temporary directory", zap.String("temp_dir", cmdExportFlags.TempDir))
+	started := time.Now()
+	outputs, err := utils.RunExportContext(ctx, cmdExportFlags, logger)
+	if cmdExportFlags.ProgressFormat == utils.ProgressFormatJSON {
+		utils.WriteProgressResult(os.Stdout, started, outputs, err)
+		return err
 	}
-	// Run export
-	if err := exporter.Export(cmdExportFlags.Workspace, cmdExportFlags.Repository); err != nil {
-		logger.Error("Export failed")
+	if err != nil {
 		return err
 	}
-
-	// Print success message
-	outputPath := exporter.GetOutputPath()
-	utils.PrintSuccessMessage(outputPath)
-
-	logger.Info("Export completed successfully")
+	for _, outputPath := range outputs {
+		utils.PrintSuccessMessage(outputPath)
+	}
 	return nil
 }
@@ -2,6 +2,7 @@ package export
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"os"
@@ -13,6 +14,7 @@ import (
 	"github.com/katiem0/gh-bbc-exporter/internal/utils"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest/observer"
 )
@@ -139,6 +141,32 @@ func TestExportCommandLongDescription(t *testing.T) {
 	assert.Contains(t, cmd.Long, "Bitbucket", "Long description should mention Bitbucket")
 }
 
+func TestExportRepositoryURLArgument(t *testing.T) {
+	cmd := NewCmdExport()
+	err := cmd.PreRunE(cmd, []string{"https://bitbucket.org/url-ws/url-repo/src/main"})
+	assert.NoError(t, err)
+	assert.Equal(t, "url-ws", cmd.Flag("workspace").Value.String())
+	assert.Equal(t, "url-repo", cmd.Flag("repo").Value.String())
+
+	cmd = NewCmdExport()
+	err = cmd.PreRunE(cmd, []string{"https://bitbucket.example.com/projects/P/repos/r"})
+	assert.ErrorContains(t, err, "Bitbucket Server")
+}
+
+func TestExportRepeatedRepoFlag(t *testing.T) {
+	cmd := NewCmdExport()
+	require.NoError(t, cmd.ParseFlags([]string{"--repo", "repo-a", "--repo", "repo-b,repo-c", "--workspace", "ws"}))
+	assert.Equal(t, "repo-a,repo-b,repo-c", cmd.Flag("repo").Value.String())
+
+	err := cmd.PreRunE(cmd, nil)
+	assert.NoError(t, err)
+
+	cmd = NewCmdExport()
+	require.NoError(t, cmd.ParseFlags([]string{"--repo", "repo-a", "--repo", "REPO-A", "--workspace", "ws"}))
+	err = cmd.PreRunE(cmd, nil)
+	assert.ErrorContains(t, err, "repository REPO-A is given more than once in --repo")
+}
+
 func TestExportCommandExample(t *testing.T) {
 	cmd := NewCmdExport()
 
@@ -1280,7 +1308,7 @@ func TestRunCmdExport_AuthLogging(t *testing.T) {
 		OutputDir:            tempDir,
 	}
 
-	err = runCmdExport(&cmdExportFlags, logger)
+	err = runCmdExport(context.Background(), &cmdExportFlags, logger)
 	assert.Error(t, err, "expected runCmdExport to return an error (export likely fails in tests)")
 
 	entries := obs.All()
@@ -1756,7 +1784,7 @@ func TestRunCmdExportWithDebugLogging(t *testing.T) {
 	}
 
 	// This will fail but we're testing that debug logging is set up correctly
-	_ = runCmdExport(&cmdExportFlags, logger)
+	_ = runCmdExport(context.Background(), &cmdExportFlags, logger)
 
 	// Verify debug-level logs were captured
 	entries := obs.All()
@@ -1799,3 +1827,80 @@ func TestCleanupExportDirsHelper(t *testing.T) {
 	_, err = os.Stat(testDir)
 	assert.True(t, os.IsNotExist(err), "Test directory should have been cleaned up")
 }
+
+func TestExportAllReposFlagValidation(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "All repos combined with repo",
+			args:    []string{"--workspace", "test-workspace", "--repo", "test-repo", "--all-repos"},
+			wantErr: "--repo cannot be combined with --all-repos",
+		},
+		{
+			name:    "Group by project without all repos",
+			args:    []string{"--workspace", "test-workspace", "--repo", "test-repo", "--group-by-project"},
+			wantErr: "--group-by-project requires --all-repos",
+		},
+		{
+			name:    "Include repos without all repos",
+			args:    []string{"--workspace", "test-workspace", "--repo", "test-repo", "--include-repos", "wave1.txt"},
+			wantErr: "--include-repos and --exclude-repos require --all-repos",
+		},
+		{
+			name:    "Exclude repos without all repos",
+			args:    []string{"--workspace", "test-workspace", "--repo", "test-repo", "--exclude-repos", "skip.txt"},
+			wantErr: "--include-repos and --exclude-repos require --all-repos",
+		},
+		{
+			name:    "Subdir split with all repos",
+			args:    []string{"--workspace", "test-workspace", "--all-repos", "--subdir-split", "src/a=repo-a"},
+			wantErr: "--subdir-split cannot be combined with --all-repos",
+		},
+		{
+			name:    "Invalid wave name",
+			args:    []string{"--workspace", "test-workspace", "--repo", "test-repo", "--wave", "../wave1"},
+			wantErr: "invalid wave name",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := NewCmdExport()
+			cmd.SetArgs(tc.args)
+			err := cmd.Execute()
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestExportAllReposWithoutRepoPassesPreRun(t *testing.T) {
+	cmd := NewCmdExport()
+
+	executed := false
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		executed = true
+		return nil
+	}
+
+	cmd.SetArgs([]string{"--workspace", "test-workspace", "--all-repos", "--group-by-project"})
+	err := cmd.Execute()
+	assert.NoError(t, err)
+	assert.True(t, executed)
+}
+
+func TestExportPreRunEReportsAllProblems(t *testing.T) {
+	cmd := NewCmdExport()
+	assert.NoError(t, cmd.PersistentFlags().Set("wave", "bad wave"))
+	assert.NoError(t, cmd.PersistentFlags().Set("concurrency", "99"))
+
+	err := cmd.PreRunE(cmd, nil)
+	assert.ErrorContains(t, err, "found 4 problems:")
+	assert.ErrorContains(t, err, "bitbucket workspace must be specified")
+	assert.ErrorContains(t, err, "bitbucket repository must be specified")
+	assert.ErrorContains(t, err, "invalid wave name")
+	assert.ErrorContains(t, err, "--concurrency")
+}
@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+const defaultJobsDir = "./bbc-exporter-jobs"
+
+func NewCmdJobs() *cobra.Command {
+	var outputDir string
+
+	jobsCmd := &cobra.Command{
+		Use:   "jobs",
+		Short: "List and retry export jobs recorded by the serve command",
+		Long: "List and retry the export jobs that 'bbc-exporter serve' records in jobs.json in its " +
+			"output directory. The commands work whether or not the server is running.",
+		Args: cobra.NoArgs,
+	}
+	jobsCmd.PersistentFlags().StringVarP(&outputDir, "output", "o", defaultJobsDir,
+		"Output directory of the serve command holding the job store")
+
+	jobsCmd.AddCommand(newCmdJobsList(&outputDir))
+	jobsCmd.AddCommand(newCmdJobsRetry(&outputDir))
+	return jobsCmd
+}
+
+func newCmdJobsList(outputDir *string) *cobra.Command {
+	var jsonOutput bool
+
+	listCmd := &cobra.Command{
+		Use:   "list [flags]",
+		Short: "List export jobs and their status",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			jobs, err := utils.ListJobs(*outputDir)
+			if err != nil {
+				return err
+			}
+			return printJobs(cmd.OutOrStdout(), jobs, jsonOutput)
+		},
+	}
+	listCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the jobs as JSON")
+	return listCmd
+}
+
+func newCmdJobsRetry(outputDir *string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "retry <job-id>",
+		Short: "Queue a failed or interrupted export job again",
+		Long: "Queue a failed or interrupted export job again. Its earlier output is removed. " +
+			"A running server starts the job within a few seconds; otherwise it runs when serve next starts.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			job, err := utils.RetryJob(*outputDir, args[0])
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "Job %s queued again (%s)\n", job.ID, describeJob(job))
+			return err
+		},
+	}
+}
+
+func printJobs(out io.Writer, jobs []data.ExportJob, jsonOutput bool) error {
+	if jsonOutput {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(jobs)
+	}
+	if len(jobs) == 0 {
+		_, err := fmt.Fprintln(out, "No jobs found")
+		return err
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "ID\tSTATUS\tATTEMPTS\tEXPORT\tSUBMITTED\tFINISHED\tERROR")
+	for _, job := range jobs {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%d\t%s\t%s\t%s\t%s\n", job.ID, job.Status, job.Attempts,
+			describeJob(job), job.SubmittedAt, valueOrDash(job.FinishedAt), valueOrDash(job.Error))
+	}
+	return writer.Flush()
+}
+
+// describeJob names what a job exports, e.g. workspace/repo or workspace/*.
+func describeJob(job data.ExportJob) string {
+	if job.Request.AllRepos {
+		return job.Request.Workspace + "/*"
+	}
+	return job.Request.Workspace + "/" + job.Request.Repository
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
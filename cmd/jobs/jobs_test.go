@@ -0,0 +1,89 @@
+package jobs
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeJobStore(t *testing.T, jobs ...data.ExportJob) string {
+	t.Helper()
+	dir := t.TempDir()
+	content, err := json.Marshal(jobs)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "jobs.json"), content, 0600))
+	return dir
+}
+
+func runJobsCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := NewCmdJobs()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetErr(buf)
+	cmd.SetArgs(args)
+	cmd.SilenceErrors = true
+	err := cmd.Execute()
+	return buf.String(), err
+}
+
+func TestNewCmdJobs(t *testing.T) {
+	cmd := NewCmdJobs()
+
+	assert.Equal(t, "jobs", cmd.Use)
+	assert.NotNil(t, cmd.PersistentFlags().Lookup("output"))
+	var names []string
+	for _, sub := range cmd.Commands() {
+		names = append(names, sub.Name())
+	}
+	assert.ElementsMatch(t, []string{"list", "retry"}, names)
+}
+
+func TestJobsList(t *testing.T) {
+	dir := writeJobStore(t,
+		data.ExportJob{ID: "job-000001", Status: "completed", Attempts: 1, SubmittedAt: "2024-01-01T00:00:00Z",
+			FinishedAt: "2024-01-01T01:00:00Z", Request: data.ExportJobRequest{Workspace: "ws", Repository: "repo"}},
+		data.ExportJob{ID: "job-000002", Status: "failed", Attempts: 1, SubmittedAt: "2024-01-02T00:00:00Z",
+			Error: "rate limited", Request: data.ExportJobRequest{Workspace: "ws", AllRepos: true}})
+
+	output, err := runJobsCommand(t, "list", "--output", dir)
+	require.NoError(t, err)
+	assert.Contains(t, output, "ID          STATUS     ATTEMPTS")
+	assert.Contains(t, output, "job-000001  completed  1         ws/repo")
+	assert.Contains(t, output, "job-000002  failed     1         ws/*")
+	assert.Contains(t, output, "rate limited")
+
+	output, err = runJobsCommand(t, "list", "--output", dir, "--json")
+	require.NoError(t, err)
+	var jobs []data.ExportJob
+	require.NoError(t, json.Unmarshal([]byte(output), &jobs))
+	assert.Len(t, jobs, 2)
+}
+
+func TestJobsListEmpty(t *testing.T) {
+	output, err := runJobsCommand(t, "list", "--output", t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, "No jobs found\n", output)
+}
+
+func TestJobsRetry(t *testing.T) {
+	dir := writeJobStore(t,
+		data.ExportJob{ID: "job-000001", Status: "interrupted", Request: data.ExportJobRequest{Workspace: "ws", Repository: "repo"}},
+		data.ExportJob{ID: "job-000002", Status: "completed"})
+
+	output, err := runJobsCommand(t, "retry", "job-000001", "--output", dir)
+	require.NoError(t, err)
+	assert.Equal(t, "Job job-000001 queued again (ws/repo)\n", output)
+
+	_, err = runJobsCommand(t, "retry", "job-000002", "--output", dir)
+	assert.ErrorContains(t, err, "only failed or interrupted jobs can be retried")
+
+	_, err = runJobsCommand(t, "retry", "--output", dir)
+	assert.Error(t, err)
+}
@@ -1,11 +1,13 @@
 package migrate
 
 import (
+	"context"
 	"fmt"
-	"time"
+	"os"
 
 	"github.com/cli/go-gh/v2/pkg/api"
 	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/i18n"
 	"github.com/katiem0/gh-bbc-exporter/internal/log"
 	"github.com/katiem0/gh-bbc-exporter/internal/utils"
 	"github.com/spf13/cobra"
@@ -20,29 +22,35 @@ func NewCmdMigrate() *cobra.Command {
 	migrateCmd := &cobra.Command{
 		Use:   "migrate [flags]",
 		Short: "Export from Bitbucket and import to GitHub",
-		Long:  "Migrate a repository from Bitbucket Cloud to GitHub Enterprise.",
+		Long: "Migrate a repository from Bitbucket Cloud to GitHub Enterprise.\n\n" +
+			"The repository can also be given as a URL, e.g. https://bitbucket.org/workspace/repo, instead of --workspace and --repo.",
+		Args: cobra.MaximumNArgs(1),
 		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := utils.ApplyRepositoryURLArg(cmd, args, &exportFlags); err != nil {
+				return err
+			}
+			var problems []error
 			if exportFlags.Workspace == "" {
-				return fmt.Errorf("bitbucket workspace must be specified")
+				problems = append(problems, i18n.Errorf(i18n.WorkspaceRequired))
 			}
 			if exportFlags.Repository == "" {
-				return fmt.Errorf("bitbucket repository must be specified")
+				problems = append(problems, i18n.Errorf(i18n.RepositoryRequired))
+			}
+			if len(utils.RepositorySlugs(&exportFlags)) > 1 {
+				problems = append(problems, fmt.Errorf("migrate imports a single repository; export several into one archive with the export command"))
 			}
 			if migrateFlags.TargetOrg == "" {
-				return fmt.Errorf("target GitHub organization must be specified")
+				problems = append(problems, fmt.Errorf("target GitHub organization must be specified"))
 			}
-			_, _, err := utils.GetUploadsBaseURL(migrateFlags.TargetAPIURL)
-			if err != nil {
-				return fmt.Errorf("unsupported target API URL: %w", err)
+			if _, _, err := utils.GetUploadsBaseURL(migrateFlags.TargetAPIURL); err != nil {
+				problems = append(problems, fmt.Errorf("unsupported target API URL: %w", err))
 			}
-
-			if exportFlags.PRsFromDate != "" {
-				_, err := time.Parse("2006-01-02", exportFlags.PRsFromDate)
-				if err != nil {
-					return fmt.Errorf("invalid date format for --prs-from-date: %s (expected YYYY-MM-DD)", exportFlags.PRsFromDate)
-				}
-			}
-			return nil
+			problems = append(problems,
+				utils.ValidateWaveName(exportFlags.Wave),
+				utils.ValidateOutputPrefix(exportFlags.OutputPrefix),
+				utils.ValidateExportOptions(&exportFlags),
+			)
+			return utils.JoinProblems(problems...)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var gqlClient *api.GraphQLClient
@@ -117,7 +125,9 @@ func NewCmdMigrate() *cobra.Command {
 			logger.Debug("REST client created successfully")
 
 			logger.Debug("Starting migration process")
-			return runCmdMigrate(&exportFlags, &migrateFlags, utils.NewAPIGetter(gqlClient, restClient, authToken), logger)
+			ctx, stop := utils.InterruptContext(cmd.Context(), logger)
+			defer stop()
+			return runCmdMigrate(ctx, &exportFlags, &migrateFlags, utils.NewAPIGetter(gqlClient, restClient, authToken), logger)
 		},
 	}
 
@@ -133,6 +143,18 @@ func NewCmdMigrate() *cobra.Command {
 		"Bitbucket username for basic authentication (env: BITBUCKET_USERNAME)")
 	migrateCmd.PersistentFlags().StringVarP(&exportFlags.BitbucketAppPass, "app-password", "p", "",
 		"Bitbucket app password for basic authentication (env: BITBUCKET_APP_PASSWORD)")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.BitbucketOAuthKey, "oauth-key", "",
+		"Bitbucket OAuth consumer key for client-credentials authentication (env: BITBUCKET_OAUTH_KEY)")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.BitbucketOAuthSecret, "oauth-secret", "",
+		"Bitbucket OAuth consumer secret for client-credentials authentication (env: BITBUCKET_OAUTH_SECRET)")
+	migrateCmd.PersistentFlags().StringSliceVar(&exportFlags.AuthFallback, "auth-fallback", nil,
+		"Authentication methods to try in order when Bitbucket answers 401 or 403 for an endpoint: access-token, api-token, app-password")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.Proxy, "proxy", "",
+		"Proxy URL for Bitbucket API and git traffic: http://, https://, socks5:// or socks5h:// (default: HTTPS_PROXY)")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.CABundle, "ca-bundle", "",
+		"PEM file of CA certificates to trust for Bitbucket traffic, e.g. of a TLS-inspecting proxy")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.InsecureSkipTLS, "insecure-skip-tls", false,
+		"Skip TLS certificate verification for Bitbucket API and git traffic (not recommended)")
 	migrateCmd.PersistentFlags().StringVarP(&exportFlags.Workspace, "workspace", "w", "",
 		"Bitbucket workspace name")
 	migrateCmd.PersistentFlags().StringVarP(&exportFlags.Repository, "repo", "r", "",
@@ -147,6 +169,124 @@ func NewCmdMigrate() *cobra.Command {
 		"Export pull requests created on or after this date (format: YYYY-MM-DD)")
 	migrateCmd.PersistentFlags().BoolVar(&exportFlags.SkipCommitLookup, "skip-commit-lookup", false,
 		"Skip Bitbucket API lookups to retrieve commit SHAs (use local lookup only)")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.ColdStorageBefore, "cold-storage-before", "",
+		"Move pull requests created before this date into a cold-storage bundle outside the archive (format: YYYY-MM-DD)")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.ColdStorageDeclined, "cold-storage-declined", false,
+		"Move declined pull requests into the cold-storage bundle outside the archive")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.FailOnUnsafePaths, "fail-on-unsafe-paths", false,
+		"Abort the export if the repository contains file paths GitHub rejects (e.g. .git entries, NTFS-invalid names)")
+	migrateCmd.PersistentFlags().StringSliceVar(&exportFlags.PRsTouchingPaths, "prs-touching-path", nil,
+		"Export only pull requests that modified paths matching this glob (e.g. src/service-a/**); repeatable")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.ReactionMapFile, "reaction-map", "",
+		"YAML file mapping Bitbucket emoji/approval shortcodes to GitHub reaction types")
+	migrateCmd.PersistentFlags().DurationVar(&exportFlags.MaxDuration, "max-duration", 0,
+		"Abort the export after this long (e.g. 2h30m), writing a checkpoint and partial report")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.ConfigFile, "config", "",
+		"YAML configuration file (e.g. per-endpoint API base URL overrides)")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.KeepAmbiguousPRs, "keep-ambiguous-prs", false,
+		"Keep pull requests whose branch names look like commit SHAs by prefixing the refs with 'bb-'")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.CommentFormatter, "comment-formatter", "markdown",
+		"Comment body format: markdown (raw Bitbucket Markdown), html-to-md (convert rendered HTML), or raw (unmodified)")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.Nice, "nice", false,
+		"Throttle Bitbucket API usage (one request at a time, delay between requests, half-size pages) for shared quotas")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.NDJSON, "ndjson", false,
+		"Also write NDJSON copies of pull requests, comments and users to an analytics/ directory outside the archive")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.CompareStats, "compare-stats", false,
+		"Compare the archive with Bitbucket's repository size, commit, branch and pull request counts and report discrepancies")
+	migrateCmd.PersistentFlags().StringArrayVar(&exportFlags.PruneRefs, "prune-ref", nil,
+		"Also delete refs under this prefix from the clone (refs/pull, refs/stash and refs/notes are pruned by default); repeatable")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.KeepNotes, "keep-notes", false,
+		"Keep refs/notes in the cloned repository instead of pruning them")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.MaxPackSize, "max-pack-size", "1g",
+		"Repack cloned repositories whose packfiles exceed this size into smaller packs (0 disables)")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.CompactJSON, "compact-json", false,
+		"Write the JSON files in the archive minified instead of indented")
+	migrateCmd.PersistentFlags().IntVar(&exportFlags.RecordsPerFile, "records-per-file", utils.DefaultRecordsPerFile,
+		"Records per archive JSON file; more go to numbered files such as pull_requests_000002.json (0 writes one file per type)")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.ExportRulesets, "export-rulesets", false,
+		"Translate Bitbucket branch restrictions into rulesets.json and an apply-rulesets.sh script outside the archive")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.ExportWatchers, "export-watchers", false,
+		"List the Bitbucket watchers of each repository in watchers.json outside the archive, to ask them to watch it on GitHub")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.IssueLinks, "issue-links", false,
+		"List the Bitbucket issues each pull request references through its branch name, title or description in issue-links.json outside the archive")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.ProtectionHealth, "protection-health", false,
+		"Compare each branch restriction with the recent commits and pull request merges of its branches in protection-health.json outside the archive")
+	migrateCmd.PersistentFlags().IntVar(&exportFlags.ProtectionHealthDays, "protection-health-days", utils.DefaultProtectionHealthDays,
+		"Days of branch activity --protection-health looks at")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.FixedTimestamps, "fixed-timestamps", false,
+		"Use a fixed time (SOURCE_DATE_EPOCH, or the Unix epoch) for generated timestamps so unchanged data re-exports identically")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.Wave, "wave", "",
+		"Migration wave name recorded in the manifest and report, and added to the output, archive and report names")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.OutputPrefix, "output-prefix", "",
+		"Prefix of the archive and export report names, recorded in the manifest and report (e.g. acme-wave2-)")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.UsersScope, "users-scope", "workspace",
+		"Users written to the archive: workspace (all members), contributors (pull request and comment authors), or none")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.LongPaths, "long-paths", "gnu",
+		"Archive paths over 100 characters outside git repositories: gnu (long-name entries), truncate, or error")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.TarFormat, "tar-format", "ustar",
+		"Archive header format: ustar (GNU entries only where needed), gnu, or pax (long names, large files, UTF-8)")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.DropPendingReviews, "drop-pending-reviews", false,
+		"Leave out pull request reviews whose inline comments were never published (Bitbucket pending comments)")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.ExportPatches, "export-patches", false,
+		"Save each open pull request's diff as patches/<pr-id>.patch next to the archive for audit")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.GenerateCodeowners, "generate-codeowners", false,
+		"Write a CODEOWNERS file per repository to migration-notes/ from default reviewers and --config path rules")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.TokenRefreshCmd, "token-refresh-cmd", "",
+		"Command that prints a new Bitbucket token; run on a 401 response before retrying the request")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.Consistency, "consistency", utils.ConsistencyBestEffort,
+		"How to handle pull requests newer than the clone: best-effort (fetch missing commits) or strict (leave them out)")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.TopUpFetch, "top-up-fetch", false,
+		"Fetch every repository again after pull requests and comments are exported to include commits pushed meanwhile")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.LocalMirror, "local-mirror", "",
+		"Existing bare mirror of the repository to export instead of cloning it from Bitbucket (checked with git fsck)")
+	migrateCmd.PersistentFlags().IntVar(&exportFlags.CloneRetries, "clone-retries", utils.DefaultCloneRetries,
+		"Retries of a failed git clone per clone URL, with exponential backoff")
+	migrateCmd.PersistentFlags().DurationVar(&exportFlags.CloneRetryDelay, "clone-retry-delay", utils.DefaultCloneRetryDelay,
+		"Wait before the first clone retry; doubled for each further retry")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.CloneProtocol, "clone-protocol", utils.CloneProtocolHTTPS,
+		"Clone URL to try first: https or ssh; the other is tried when cloning with it fails")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.FailOnCloneError, "fail-on-clone-error", false,
+		"Fail when a clone has none of the branches Bitbucket lists, a wiki cannot be cloned, or the top-up fetch fails")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.VerifyFrozen, "verify-frozen", false,
+		"Fail the export if branches, tags or pull requests changed in Bitbucket while it ran")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.AsOf, "as-of", "",
+		"Export the repository as it was at this date or time: later pull requests and comments are left out, branches and tags are moved back (format: YYYY-MM-DD or RFC 3339)")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.GhostUser, "ghost-user", utils.DefaultGhostUser,
+		"Login that pull requests and comments by deleted Bitbucket accounts are attributed to in the users file")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.Resume, "resume", false,
+		"Continue an interrupted export from its checkpoint in the output directory instead of starting over")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.DownloadAvatar, "download-avatar", false,
+		"Save the workspace avatar under organization/ next to the archive, to upload to the GitHub organization")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.MergeCommitCheck, "merge-commit-check", utils.MergeCommitCheckReport,
+		"Verify merge commits of merged pull requests against the mirror: report, clear (also remove mismatched merge commits) or off")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.PRFooterTemplate, "pr-footer-template", "",
+		"Go template appended to every pull request description, e.g. 'Migrated from Bitbucket PR #{{.ID}} on {{.Date}}; original author {{.Author}}'")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.CommentFooter, "comment-footer-template", "",
+		"Go template appended to every pull request comment, e.g. 'Originally posted at {{.URL}} on {{.CreatedAt}}'")
+	migrateCmd.PersistentFlags().IntVar(&exportFlags.Concurrency, "concurrency", 1,
+		"Number of workers fetching pull request comments and resolving commit SHAs in parallel (1-16)")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.AnalyzeDocs, "analyze-docs", false,
+		"Scan markdown files for Bitbucket-specific syntax ([TOC], wiki links, src/ links) and list them in docs-report.json")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.UserMappingFile, "user-mapping", "",
+		"CSV file mapping Bitbucket user UUIDs or display names to GitHub logins and emails (header: bitbucket,github_login,email)")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.RedactionRulesFile, "redaction-rules", "",
+		"YAML file of regular expressions replaced in pull request descriptions and comment bodies, e.g. internal hostnames")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.DownloadAttachments, "download-attachments", false,
+		"Store Bitbucket-hosted images and files linked from pull request descriptions and comments in the archive's attachments/")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.PredictPRNumbers, "predict-pr-numbers", false,
+		"Predict the GitHub number of each pull request in pr-number-map.json and rewrite #123 references in comments to match")
+	migrateCmd.PersistentFlags().IntVar(&exportFlags.PRNumberOffset, "pr-number-offset", 0,
+		"Issues and pull requests the target GitHub repository already has; predicted numbers start after them")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.VerifyArchive, "verify-archive", false,
+		"Read the archive back after creating it and fail if its entries or sizes do not match the export directory")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.PreserveFileModes, "preserve-file-modes", false,
+		"Keep the host permission bits of archive entries instead of normalizing them to 0644 and 0755")
+	migrateCmd.PersistentFlags().StringVar(&exportFlags.LinkTarget, "link-target", "",
+		"GitHub repository URL that commit, compare and source links in comments are rewritten to ({repository} is replaced with the repository slug)")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.FeatureChecklist, "feature-checklist", false,
+		"List the Bitbucket features each repository uses (LFS, pipelines, wiki, issues, ...) and their migration status in feature-checklist.json")
+	migrateCmd.PersistentFlags().BoolVar(&exportFlags.ConvertPipelines, "convert-pipelines", false,
+		"Translate bitbucket-pipelines.yml into GitHub Actions workflow stubs under migration-notes and list unsupported features in pipelines-conversion.json")
 
 	migrateCmd.PersistentFlags().StringVar(&migrateFlags.TargetOrg, "target-org", "",
 		"Target GitHub organization (required)")
@@ -158,6 +298,8 @@ func NewCmdMigrate() *cobra.Command {
 		"The URL of the target API, if not migrating to github.com. Defaults to https://api.github.com")
 	migrateCmd.PersistentFlags().Var(&migrateFlags.TargetRepoVisibility, "target-repo-visibility",
 		"The visibility of the target repo. Defaults to private. Valid values are public, private, or internal.")
+	migrateCmd.PersistentFlags().StringVar(&migrateFlags.ArchivePath, "archive", "",
+		"Upload an existing export archive instead of exporting again (resumes an interrupted upload)")
 	migrateCmd.PersistentFlags().BoolVarP(&exportFlags.Debug, "debug", "d", false, "Enable debug logging")
 
 	if err := migrateCmd.MarkPersistentFlagRequired("workspace"); err != nil {
@@ -175,7 +317,7 @@ func NewCmdMigrate() *cobra.Command {
 	return migrateCmd
 }
 
-func runCmdMigrate(exportFlags *data.CmdExportFlags, migrateFlags *data.CmdMigrateFlags, g *utils.APIGetter, logger *zap.Logger) error {
+func runCmdMigrate(ctx context.Context, exportFlags *data.CmdExportFlags, migrateFlags *data.CmdMigrateFlags, g *utils.APIGetter, logger *zap.Logger) error {
 	logger.Debug("runCmdMigrate started",
 		zap.String("workspace", exportFlags.Workspace),
 		zap.String("repository", exportFlags.Repository),
@@ -187,6 +329,53 @@ func runCmdMigrate(exportFlags *data.CmdExportFlags, migrateFlags *data.CmdMigra
 		zap.String("source", fmt.Sprintf("%s/%s", exportFlags.Workspace, exportFlags.Repository)),
 		zap.String("target", fmt.Sprintf("%s/%s", migrateFlags.TargetOrg, migrateFlags.TargetRepo)))
 
+	var archivePath string
+	if migrateFlags.ArchivePath != "" {
+		if _, err := os.Stat(migrateFlags.ArchivePath); err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		archivePath = migrateFlags.ArchivePath
+		logger.Info("Step 1: Skipping export, using existing archive",
+			zap.String("archive", archivePath))
+	} else {
+		var err error
+		archivePath, err = exportForMigration(ctx, exportFlags, logger)
+		if err != nil {
+			return err
+		}
+	}
+	logger.Debug("Archive path determined", zap.String("archivePath", archivePath))
+
+	logger.Info("Step 2: Importing to GitHub Enterprise Cloud",
+		zap.String("archive", archivePath))
+
+	newUploadsBaseURL, newUploadsHost, err := utils.GetUploadsBaseURL(migrateFlags.TargetAPIURL)
+	if err != nil {
+		return fmt.Errorf("unsupported target for migration uploads: %w", err)
+	}
+
+	g.SetUploadsBaseURL(newUploadsBaseURL, newUploadsHost)
+	logger.Debug("Uploads base URL configured",
+		zap.String("uploadsBaseURL", newUploadsBaseURL),
+		zap.String("uploadsHost", newUploadsHost))
+
+	logger.Debug("Starting GitHub API migration",
+		zap.String("archivePath", archivePath),
+		zap.String("targetOrg", migrateFlags.TargetOrg))
+
+	if err := utils.RunGitHubAPIMigration(exportFlags, migrateFlags, archivePath, g, logger); err != nil {
+		logger.Debug("Import failed", zap.Error(err))
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	logger.Info("Migration completed successfully")
+	logger.Debug("Migration process finished",
+		zap.String("source", fmt.Sprintf("%s/%s", exportFlags.Workspace, exportFlags.Repository)),
+		zap.String("target", fmt.Sprintf("%s/%s", migrateFlags.TargetOrg, migrateFlags.TargetRepo)))
+	return nil
+}
+
+func exportForMigration(ctx context.Context, exportFlags *data.CmdExportFlags, logger *zap.Logger) (string, error) {
 	logger.Info("Step 1: Exporting from Bitbucket Cloud")
 	logger.Debug("Setting up environment credentials")
 
@@ -198,13 +387,17 @@ func runCmdMigrate(exportFlags *data.CmdExportFlags, migrateFlags *data.CmdMigra
 		zap.Bool("hasAPIToken", exportFlags.BitbucketAPIToken != ""),
 		zap.Bool("hasEmail", exportFlags.BitbucketEmail != ""),
 		zap.Bool("hasUser", exportFlags.BitbucketUser != ""),
-		zap.Bool("hasAppPass", exportFlags.BitbucketAppPass != ""))
+		zap.Bool("hasAppPass", exportFlags.BitbucketAppPass != ""),
+		zap.Bool("hasOAuthKey", exportFlags.BitbucketOAuthKey != ""))
 
 	if err := utils.ValidateExportFlags(exportFlags); err != nil {
 		logger.Debug("Export validation failed", zap.Error(err))
-		return fmt.Errorf("export validation failed: %w", err)
+		return "", fmt.Errorf("export validation failed: %w", err)
 	}
 	logger.Debug("Export flags validated successfully")
+	if err := utils.CheckRuntimePrerequisites(utils.DetectRuntime(), exportFlags.OutputDir, exportFlags.TempDir, logger); err != nil {
+		return "", err
+	}
 
 	logger.Debug("Creating Bitbucket client",
 		zap.String("apiURL", exportFlags.BitbucketAPIURL),
@@ -222,6 +415,10 @@ func runCmdMigrate(exportFlags *data.CmdExportFlags, migrateFlags *data.CmdMigra
 		exportFlags.OutputDir,
 		exportFlags.SkipCommitLookup,
 	)
+	if err := utils.ConfigureClient(client, exportFlags); err != nil {
+		return "", err
+	}
+	client.SetContext(ctx)
 	logger.Debug("Bitbucket client created")
 
 	logger.Debug("Creating exporter",
@@ -230,6 +427,9 @@ func runCmdMigrate(exportFlags *data.CmdExportFlags, migrateFlags *data.CmdMigra
 		zap.String("prsFromDate", exportFlags.PRsFromDate))
 
 	exporter := utils.NewExporter(client, exportFlags.OutputDir, logger, exportFlags.OpenPRsOnly, exportFlags.PRsFromDate)
+	if err := exporter.ApplyExportFlags(exportFlags); err != nil {
+		return "", err
+	}
 
 	logger.Debug("Starting export",
 		zap.String("workspace", exportFlags.Workspace),
@@ -237,38 +437,9 @@ func runCmdMigrate(exportFlags *data.CmdExportFlags, migrateFlags *data.CmdMigra
 
 	if err := exporter.Export(exportFlags.Workspace, exportFlags.Repository); err != nil {
 		logger.Debug("Export failed", zap.Error(err))
-		return fmt.Errorf("export failed: %w", err)
+		return "", fmt.Errorf("export failed: %w", err)
 	}
 	logger.Debug("Export completed successfully")
 
-	archivePath := exporter.GetOutputPath()
-	logger.Debug("Archive path determined", zap.String("archivePath", archivePath))
-
-	logger.Info("Step 2: Importing to GitHub Enterprise Cloud",
-		zap.String("archive", archivePath))
-
-	newUploadsBaseURL, newUploadsHost, err := utils.GetUploadsBaseURL(migrateFlags.TargetAPIURL)
-	if err != nil {
-		return fmt.Errorf("unsupported target for migration uploads: %w", err)
-	}
-
-	g.SetUploadsBaseURL(newUploadsBaseURL, newUploadsHost)
-	logger.Debug("Uploads base URL configured",
-		zap.String("uploadsBaseURL", newUploadsBaseURL),
-		zap.String("uploadsHost", newUploadsHost))
-
-	logger.Debug("Starting GitHub API migration",
-		zap.String("archivePath", archivePath),
-		zap.String("targetOrg", migrateFlags.TargetOrg))
-
-	if err := utils.RunGitHubAPIMigration(exportFlags, migrateFlags, archivePath, g, logger); err != nil {
-		logger.Debug("Import failed", zap.Error(err))
-		return fmt.Errorf("import failed: %w", err)
-	}
-
-	logger.Info("Migration completed successfully")
-	logger.Debug("Migration process finished",
-		zap.String("source", fmt.Sprintf("%s/%s", exportFlags.Workspace, exportFlags.Repository)),
-		zap.String("target", fmt.Sprintf("%s/%s", migrateFlags.TargetOrg, migrateFlags.TargetRepo)))
-	return nil
+	return exporter.GetOutputPath(), nil
 }
@@ -1,6 +1,7 @@
 package migrate
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/katiem0/gh-bbc-exporter/internal/data"
 	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
 )
 
 func cleanupExportDirs(t *testing.T) {
@@ -116,6 +118,12 @@ func TestMigratePreRunValidation(t *testing.T) {
 			expectError: true,
 			errorMsg:    "target GitHub organization must be specified",
 		},
+		{
+			name:        "Several repositories",
+			args:        []string{"--workspace", "test-ws", "--repo", "repo-a,repo-b", "--target-org", "test-org"},
+			expectError: true,
+			errorMsg:    "migrate imports a single repository",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -570,6 +578,7 @@ func TestMigrateAllFlagsPresent(t *testing.T) {
 		"target-repo",
 		"github-target-pat",
 		"target-repo-visibility",
+		"archive",
 
 		"debug",
 	}
@@ -1390,3 +1399,19 @@ func TestMigrateGHESFailsFastBeforeExport(t *testing.T) {
 	matches, _ := filepath.Glob("./bitbucket-export-*")
 	assert.Empty(t, matches, "No export directory should be created when target API URL is invalid")
 }
+
+func TestMigrateArchiveFlagMissingFile(t *testing.T) {
+	logger := zap.NewNop()
+	exportFlags := &data.CmdExportFlags{Workspace: "test-ws", Repository: "test-repo"}
+	migrateFlags := &data.CmdMigrateFlags{
+		TargetOrg:   "test-org",
+		ArchivePath: filepath.Join(t.TempDir(), "missing.tar.gz"),
+	}
+
+	err := runCmdMigrate(context.Background(), exportFlags, migrateFlags, nil, logger)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to read archive")
+
+	matches, _ := filepath.Glob("./bitbucket-export-*")
+	assert.Empty(t, matches, "No export should run when an archive is provided")
+}
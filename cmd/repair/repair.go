@@ -0,0 +1,140 @@
+package repair
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/log"
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func NewCmdRepair() *cobra.Command {
+	migrateFlags := data.CmdMigrateFlags{}
+	repairOpts := utils.RepairOptions{}
+	var debug bool
+
+	repairCmd := &cobra.Command{
+		Use:   "repair [flags]",
+		Short: "Recover pull requests and comments missing after an import",
+		Long: "Compare an export with the GitHub repository it was imported into and recover the pull requests and " +
+			"comments that did not make it.\n\n" +
+			"The missing records are listed in repair-report.json and written either as a supplemental archive " +
+			"holding only them (--format archive) or as a script that replays them through the GitHub API (--format script).",
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			var problems []error
+			if repairOpts.ExportPath == "" {
+				problems = append(problems, errors.New("--export must be set to the export directory or archive"))
+			}
+			if migrateFlags.TargetOrg == "" {
+				problems = append(problems, errors.New("target GitHub organization must be specified"))
+			}
+			if _, _, err := utils.GetAPIURLHost(migrateFlags.TargetAPIURL); err != nil {
+				problems = append(problems, err)
+			}
+			problems = append(problems, utils.ValidateRepairFormat(repairOpts.Format))
+			return utils.JoinProblems(problems...)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			logger, err := log.NewLogger(debug)
+			if err != nil {
+				return fmt.Errorf("failed to initialize logger: %w", err)
+			}
+			defer func() {
+				_ = logger.Sync()
+			}()
+			zap.ReplaceGlobals(logger)
+
+			host, _, err := utils.GetAPIURLHost(migrateFlags.TargetAPIURL)
+			if err != nil {
+				return fmt.Errorf("invalid target API URL: %w", err)
+			}
+			authToken, err := utils.GetGitHubAuthToken(&migrateFlags, logger)
+			if err != nil {
+				return fmt.Errorf("failed to get GitHub authentication token: %w", err)
+			}
+			restClient, err := api.NewRESTClient(api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github+json",
+				},
+				Host:      host,
+				AuthToken: authToken,
+			})
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := utils.InterruptContext(cmd.Context(), logger)
+			defer stop()
+			repairOpts.TargetOrg = migrateFlags.TargetOrg
+			repairOpts.TargetRepo = migrateFlags.TargetRepo
+			return runCmdRepair(ctx, repairOpts, utils.NewAPIGetter(&api.GraphQLClient{}, restClient, authToken), logger)
+		},
+	}
+
+	repairCmd.Flags().SortFlags = false
+	repairCmd.PersistentFlags().SortFlags = false
+
+	utils.SetupCommandUsageTemplate(repairCmd, 100)
+
+	repairCmd.PersistentFlags().StringVar(&repairOpts.ExportPath, "export", "",
+		"Export directory or .tar.gz archive that was imported (required)")
+	repairCmd.PersistentFlags().StringVarP(&repairOpts.Repository, "repo", "r", "",
+		"Repository of the export to repair, when the export holds several")
+	repairCmd.PersistentFlags().StringVar(&migrateFlags.TargetOrg, "target-org", "",
+		"GitHub organization the export was imported into (required)")
+	repairCmd.PersistentFlags().StringVar(&migrateFlags.TargetRepo, "target-repo", "",
+		"GitHub repository the export was imported into (defaults to the repository name in the export)")
+	repairCmd.PersistentFlags().StringVar(&migrateFlags.GitHubPAT, "github-target-pat", "",
+		"GitHub Personal Access Token (env: GITHUB_PAT)")
+	repairCmd.PersistentFlags().StringVar(&migrateFlags.TargetAPIURL, "target-api-url", "https://api.github.com",
+		"The URL of the target API, if not on github.com. Defaults to https://api.github.com")
+	repairCmd.PersistentFlags().StringVar(&repairOpts.Format, "format", utils.RepairFormatArchive,
+		"Output for the missing records: archive (supplemental archive) or script (GitHub API replay script)")
+	repairCmd.PersistentFlags().StringVarP(&repairOpts.OutputDir, "output", "o", "",
+		"Output directory for the repair report and archive or script (default: ./bitbucket-repair-TIMESTAMP)")
+	repairCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
+
+	if err := repairCmd.MarkPersistentFlagRequired("export"); err != nil {
+		fmt.Printf("Error marking export flag as required: %v\n", err)
+	}
+	if err := repairCmd.MarkPersistentFlagRequired("target-org"); err != nil {
+		fmt.Printf("Error marking target-org flag as required: %v\n", err)
+	}
+	return repairCmd
+}
+
+func runCmdRepair(ctx context.Context, repairOpts utils.RepairOptions, g *utils.APIGetter, logger *zap.Logger) error {
+	if repairOpts.OutputDir == "" {
+		repairOpts.OutputDir = fmt.Sprintf("./bitbucket-repair-%s", time.Now().Format("20060102-150405"))
+	}
+	logger.Info("Starting repair of an imported repository",
+		zap.String("export", repairOpts.ExportPath),
+		zap.String("targetOrg", repairOpts.TargetOrg),
+		zap.String("format", repairOpts.Format),
+		zap.String("output", repairOpts.OutputDir))
+
+	output, report, err := utils.RunRepair(ctx, repairOpts, g, logger)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		fmt.Printf("Nothing to repair: %s has every pull request and comment of the export\n", report.Target)
+		return nil
+	}
+	fmt.Printf("Found %d pull requests, %d issue comments and %d review comments missing from %s\n",
+		len(report.PullRequests), len(report.IssueComments), len(report.ReviewComments), report.Target)
+	if repairOpts.Format == utils.RepairFormatScript {
+		fmt.Printf("Replay script: %s\n", output)
+	} else {
+		fmt.Printf("Supplemental archive: %s\n", output)
+	}
+	return nil
+}
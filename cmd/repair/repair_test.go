@@ -0,0 +1,42 @@
+package repair
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCmdRepair(t *testing.T) {
+	cmd := NewCmdRepair()
+
+	assert.NotNil(t, cmd)
+	assert.Equal(t, "repair [flags]", cmd.Use)
+	for _, name := range []string{"export", "repo", "target-org", "target-repo", "github-target-pat",
+		"target-api-url", "format", "output", "debug"} {
+		assert.NotNil(t, cmd.PersistentFlags().Lookup(name), "flag %s should be defined", name)
+	}
+	assert.Equal(t, "archive", cmd.PersistentFlags().Lookup("format").DefValue)
+}
+
+func TestRepairRequiresExportAndTargetOrg(t *testing.T) {
+	cmd := NewCmdRepair()
+	cmd.SetArgs([]string{})
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "export")
+	assert.ErrorContains(t, err, "target GitHub organization must be specified")
+}
+
+func TestRepairPreRunEReportsAllProblems(t *testing.T) {
+	cmd := NewCmdRepair()
+	cmd.SetArgs([]string{"--export", "export-dir", "--target-org", "", "--format", "patch"})
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "found 2 problems:")
+	assert.ErrorContains(t, err, "target GitHub organization must be specified")
+	assert.ErrorContains(t, err, "invalid value for --format")
+}
@@ -1,21 +1,55 @@
 package cmd
 
 import (
+	"os"
+
+	"github.com/katiem0/gh-bbc-exporter/cmd/estimate"
 	"github.com/katiem0/gh-bbc-exporter/cmd/export"
+	"github.com/katiem0/gh-bbc-exporter/cmd/jobs"
 	"github.com/katiem0/gh-bbc-exporter/cmd/migrate"
+	"github.com/katiem0/gh-bbc-exporter/cmd/repair"
+	"github.com/katiem0/gh-bbc-exporter/cmd/serve"
+	"github.com/katiem0/gh-bbc-exporter/cmd/supportbundle"
+	cmdsync "github.com/katiem0/gh-bbc-exporter/cmd/sync"
+	"github.com/katiem0/gh-bbc-exporter/cmd/verifyusers"
+	cmdversion "github.com/katiem0/gh-bbc-exporter/cmd/version"
+	"github.com/katiem0/gh-bbc-exporter/internal/i18n"
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+	"github.com/katiem0/gh-bbc-exporter/internal/version"
 	"github.com/spf13/cobra"
 )
 
 func NewCmdRoot() *cobra.Command {
+	var lang string
 
 	cmdRoot := &cobra.Command{
-		Use:   "bbc-exporter",
-		Short: "Export and migrate repositories from Bitbucket Cloud to GitHub",
+		Use:     "bbc-exporter",
+		Short:   "Export and migrate repositories from Bitbucket Cloud to GitHub",
+		Version: version.Get().Version,
 	}
+	cmdRoot.SetVersionTemplate("bbc-exporter {{.Version}}\nRun 'bbc-exporter version' for build and compatibility details.\n")
 	cmdRoot.PersistentFlags().Bool("help", false, "Show help for command")
+	cmdRoot.PersistentFlags().StringVar(&lang, "lang", "",
+		"Language of the result and error messages: en, ja or de (env: BBC_EXPORTER_LANG)")
+	cmdRoot.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		if lang == "" {
+			lang = os.Getenv(i18n.EnvVar)
+		}
+		return i18n.SetLanguage(lang)
+	}
+	cmdRoot.SetFlagErrorFunc(utils.FlagErrorWithSuggestions)
+	cmdRoot.Flags().Bool("version", false, "Show the exporter version")
 
 	cmdRoot.AddCommand(export.NewCmdExport())
 	cmdRoot.AddCommand(migrate.NewCmdMigrate())
+	cmdRoot.AddCommand(estimate.NewCmdEstimate())
+	cmdRoot.AddCommand(cmdsync.NewCmdSync())
+	cmdRoot.AddCommand(repair.NewCmdRepair())
+	cmdRoot.AddCommand(verifyusers.NewCmdVerifyUsers())
+	cmdRoot.AddCommand(serve.NewCmdServe())
+	cmdRoot.AddCommand(jobs.NewCmdJobs())
+	cmdRoot.AddCommand(supportbundle.NewCmdSupportBundle())
+	cmdRoot.AddCommand(cmdversion.NewCmdVersion())
 	cmdRoot.CompletionOptions.DisableDefaultCmd = true
 	cmdRoot.SetHelpCommand(&cobra.Command{
 		Use:    "no-help",
@@ -44,6 +44,60 @@ func TestNewCmdRootHasMigrateSubcommand(t *testing.T) {
 	assert.NotNil(t, migrateCmd, "migrate subcommand should exist")
 }
 
+func TestNewCmdRootHasServeSubcommand(t *testing.T) {
+	cmd := NewCmdRoot()
+
+	var serveCmd *cobra.Command
+	for _, subCmd := range cmd.Commands() {
+		if subCmd.Use == "serve [flags]" {
+			serveCmd = subCmd
+			break
+		}
+	}
+
+	assert.NotNil(t, serveCmd, "serve subcommand should exist")
+}
+
+func TestNewCmdRootHasJobsSubcommand(t *testing.T) {
+	cmd := NewCmdRoot()
+
+	var jobsCmd *cobra.Command
+	for _, subCmd := range cmd.Commands() {
+		if subCmd.Use == "jobs" {
+			jobsCmd = subCmd
+			break
+		}
+	}
+
+	assert.NotNil(t, jobsCmd, "jobs subcommand should exist")
+}
+
+func TestNewCmdRootHasSupportBundleSubcommand(t *testing.T) {
+	cmd := NewCmdRoot()
+
+	var supportBundleCmd *cobra.Command
+	for _, subCmd := range cmd.Commands() {
+		if subCmd.Use == "support-bundle [flags]" {
+			supportBundleCmd = subCmd
+			break
+		}
+	}
+
+	assert.NotNil(t, supportBundleCmd, "support-bundle subcommand should exist")
+}
+
+func TestNewCmdRootVersionFlag(t *testing.T) {
+	cmd := NewCmdRoot()
+
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"--version"})
+
+	assert.NoError(t, cmd.Execute())
+	assert.Contains(t, buf.String(), "bbc-exporter ")
+	assert.Contains(t, buf.String(), "bbc-exporter version")
+}
+
 func TestNewCmdRootHasHelpFlag(t *testing.T) {
 	cmd := NewCmdRoot()
 
@@ -81,8 +135,8 @@ func TestNewCmdRootHelpCommandHidden(t *testing.T) {
 func TestNewCmdRootSubcommandCount(t *testing.T) {
 	cmd := NewCmdRoot()
 
-	// Should have exactly 2 subcommands: export and migrate
-	assert.Equal(t, 2, len(cmd.Commands()), "Root command should have 2 subcommands")
+	// Should have exactly 10 subcommands: export, migrate, estimate, sync, repair, verify-users, serve, jobs, support-bundle and version
+	assert.Equal(t, 10, len(cmd.Commands()), "Root command should have 10 subcommands")
 }
 
 func TestNewCmdRootExecuteHelp(t *testing.T) {
@@ -179,6 +233,7 @@ func TestNewCmdRootSubcommandNames(t *testing.T) {
 
 	assert.Contains(t, subcommandNames, "export", "Root should have export subcommand")
 	assert.Contains(t, subcommandNames, "migrate", "Root should have migrate subcommand")
+	assert.Contains(t, subcommandNames, "estimate", "Root should have estimate subcommand")
 }
 
 func TestNewCmdRootNoRunFunction(t *testing.T) {
@@ -203,3 +258,25 @@ func TestNewCmdRootPersistentFlagsInheritance(t *testing.T) {
 			"Subcommand %s should inherit help flag", subCmd.Name())
 	}
 }
+
+func TestNewCmdRootRejectsUnsupportedLanguage(t *testing.T) {
+	cmd := NewCmdRoot()
+	cmd.SetArgs([]string{"--lang", "fr", "version"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid value for --lang")
+}
+
+func TestNewCmdRootSuggestsMistypedFlags(t *testing.T) {
+	cmd := NewCmdRoot()
+	cmd.SetArgs([]string{"export", "--wokspace", "ws"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+
+	err := cmd.Execute()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Did you mean this?\n\t--workspace")
+}
@@ -0,0 +1,153 @@
+package serve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/log"
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdServeFlags struct {
+	Listen    string
+	AuthToken string
+	Workers   int
+}
+
+func NewCmdServe() *cobra.Command {
+	serveFlags := cmdServeFlags{}
+	exportFlags := data.CmdExportFlags{}
+
+	serveCmd := &cobra.Command{
+		Use:   "serve [flags]",
+		Short: "Run exports submitted through a REST API",
+		Long: "Run as a long-lived service that accepts export jobs over HTTP, runs them with the same " +
+			"pipeline as the export command, and reports their status, export reports and archive paths.\n\n" +
+			"Bitbucket credentials, output and temporary directories, and the configuration file are set " +
+			"when the server starts; jobs choose the workspace, repositories and export options.",
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if serveFlags.AuthToken == "" {
+				serveFlags.AuthToken = os.Getenv("BBC_EXPORTER_SERVE_TOKEN")
+			}
+			if serveFlags.Workers < 1 {
+				return errors.New("--workers must be at least 1")
+			}
+			return utils.ValidateServeAuth(serveFlags.Listen, serveFlags.AuthToken)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			logger, err := log.NewLogger(exportFlags.Debug)
+			if err != nil {
+				return fmt.Errorf("failed to initialize logger: %w", err)
+			}
+			defer func() {
+				_ = logger.Sync()
+			}()
+			zap.ReplaceGlobals(logger)
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return runCmdServe(ctx, &serveFlags, &exportFlags, logger)
+		},
+	}
+
+	serveCmd.Flags().SortFlags = false
+	serveCmd.PersistentFlags().SortFlags = false
+
+	utils.SetupCommandUsageTemplate(serveCmd, 100)
+
+	serveCmd.PersistentFlags().StringVar(&serveFlags.Listen, "listen", "127.0.0.1:8080",
+		"Address the REST API listens on")
+	serveCmd.PersistentFlags().StringVar(&serveFlags.AuthToken, "auth-token", "",
+		"Bearer token clients must send; required unless listening on loopback (env: BBC_EXPORTER_SERVE_TOKEN)")
+	serveCmd.PersistentFlags().IntVar(&serveFlags.Workers, "workers", 1,
+		"Number of export jobs run at the same time")
+	serveCmd.PersistentFlags().StringVarP(&exportFlags.BitbucketAPIURL, "bbc-api-url", "a",
+		"https://api.bitbucket.org/2.0", "Bitbucket API to use")
+	serveCmd.PersistentFlags().StringVarP(&exportFlags.BitbucketAccessToken, "access-token", "t", "",
+		"Bitbucket workspace access token for authentication (env: BITBUCKET_ACCESS_TOKEN)")
+	serveCmd.PersistentFlags().StringVarP(&exportFlags.BitbucketAPIToken, "api-token", "", "",
+		"Bitbucket API token for authentication (env: BITBUCKET_API_TOKEN)")
+	serveCmd.PersistentFlags().StringVarP(&exportFlags.BitbucketEmail, "email", "e", "",
+		"Atlassian account email for API token authentication (env: BITBUCKET_EMAIL)")
+	serveCmd.PersistentFlags().StringVarP(&exportFlags.BitbucketUser, "user", "u", "",
+		"Bitbucket username for basic authentication (env: BITBUCKET_USERNAME)")
+	serveCmd.PersistentFlags().StringVarP(&exportFlags.BitbucketAppPass, "app-password", "p", "",
+		"Bitbucket app password for basic authentication (env: BITBUCKET_APP_PASSWORD)")
+	serveCmd.PersistentFlags().StringVar(&exportFlags.BitbucketOAuthKey, "oauth-key", "",
+		"Bitbucket OAuth consumer key for client-credentials authentication (env: BITBUCKET_OAUTH_KEY)")
+	serveCmd.PersistentFlags().StringVar(&exportFlags.BitbucketOAuthSecret, "oauth-secret", "",
+		"Bitbucket OAuth consumer secret for client-credentials authentication (env: BITBUCKET_OAUTH_SECRET)")
+	serveCmd.PersistentFlags().StringSliceVar(&exportFlags.AuthFallback, "auth-fallback", nil,
+		"Authentication methods to try in order when Bitbucket answers 401 or 403 for an endpoint: access-token, api-token, app-password")
+	serveCmd.PersistentFlags().StringVar(&exportFlags.Proxy, "proxy", "",
+		"Proxy URL for Bitbucket API and git traffic: http://, https://, socks5:// or socks5h:// (default: HTTPS_PROXY)")
+	serveCmd.PersistentFlags().StringVar(&exportFlags.CABundle, "ca-bundle", "",
+		"PEM file of CA certificates to trust for Bitbucket traffic, e.g. of a TLS-inspecting proxy")
+	serveCmd.PersistentFlags().BoolVar(&exportFlags.InsecureSkipTLS, "insecure-skip-tls", false,
+		"Skip TLS certificate verification for Bitbucket API and git traffic (not recommended)")
+	serveCmd.PersistentFlags().StringVarP(&exportFlags.OutputDir, "output", "o", "./bbc-exporter-jobs",
+		"Directory each job's export is written to, in a sub-directory named after the job")
+	serveCmd.PersistentFlags().StringVar(&exportFlags.TempDir, "temp-dir", "",
+		"Temporary directory for cloning (env: BITBUCKET_TEMP_DIR)")
+	serveCmd.PersistentFlags().StringVar(&exportFlags.ConfigFile, "config", "",
+		"YAML exporter configuration file applied to every job")
+	serveCmd.PersistentFlags().BoolVarP(&exportFlags.Debug, "debug", "d", false, "Enable debug logging")
+
+	return serveCmd
+}
+
+func runCmdServe(ctx context.Context, serveFlags *cmdServeFlags, exportFlags *data.CmdExportFlags, logger *zap.Logger) error {
+	utils.SetupEnvironmentCredentials(exportFlags)
+	if err := utils.ValidateExportFlags(exportFlags); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(exportFlags.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := utils.CheckRuntimePrerequisites(utils.DetectRuntime(), exportFlags.OutputDir, exportFlags.TempDir, logger); err != nil {
+		return err
+	}
+
+	server := utils.NewExportServer(*exportFlags, exportFlags.OutputDir, serveFlags.AuthToken, logger)
+	if err := server.Restore(); err != nil {
+		return err
+	}
+	server.StartWorkers(serveFlags.Workers)
+	defer server.Close()
+
+	httpServer := &http.Server{
+		Addr:              serveFlags.Listen,
+		Handler:           server.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- httpServer.ListenAndServe()
+	}()
+	logger.Info("Serving export API",
+		zap.String("listen", serveFlags.Listen),
+		zap.String("output", exportFlags.OutputDir),
+		zap.Int("workers", serveFlags.Workers))
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("export API stopped: %w", err)
+	case <-ctx.Done():
+	}
+
+	logger.Info("Shutting down export API; running jobs will be marked interrupted on the next start")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,63 @@
+package serve
+
+import (
+	"context"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestNewCmdServe(t *testing.T) {
+	cmd := NewCmdServe()
+
+	assert.NotNil(t, cmd)
+	assert.Equal(t, "serve [flags]", cmd.Use)
+	for _, name := range []string{"listen", "auth-token", "workers", "access-token", "output", "config", "debug"} {
+		assert.NotNil(t, cmd.PersistentFlags().Lookup(name), "flag %s should be defined", name)
+	}
+	assert.Equal(t, "127.0.0.1:8080", cmd.PersistentFlags().Lookup("listen").DefValue)
+}
+
+func TestServeRequiresAuthTokenOffLoopback(t *testing.T) {
+	t.Setenv("BBC_EXPORTER_SERVE_TOKEN", "")
+	cmd := NewCmdServe()
+	cmd.SetArgs([]string{"--listen", "0.0.0.0:8080"})
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "--auth-token is required when listening on 0.0.0.0:8080")
+}
+
+func TestServeRejectsZeroWorkers(t *testing.T) {
+	cmd := NewCmdServe()
+	cmd.SetArgs([]string{"--workers", "0"})
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "--workers must be at least 1")
+}
+
+func TestRunCmdServeRequiresCredentials(t *testing.T) {
+	for _, name := range []string{"BITBUCKET_ACCESS_TOKEN", "BITBUCKET_API_TOKEN", "BITBUCKET_EMAIL",
+		"BITBUCKET_USERNAME", "BITBUCKET_APP_PASSWORD"} {
+		t.Setenv(name, "")
+	}
+	serveFlags := &cmdServeFlags{Listen: "127.0.0.1:0", Workers: 1}
+	exportFlags := &data.CmdExportFlags{OutputDir: t.TempDir()}
+
+	err := runCmdServe(context.Background(), serveFlags, exportFlags, zap.NewNop())
+	assert.ErrorContains(t, err, "authentication credentials required")
+}
+
+func TestRunCmdServeStopsOnCancel(t *testing.T) {
+	serveFlags := &cmdServeFlags{Listen: "127.0.0.1:0", Workers: 1}
+	exportFlags := &data.CmdExportFlags{OutputDir: t.TempDir(), BitbucketAccessToken: "token"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.NoError(t, runCmdServe(ctx, serveFlags, exportFlags, zap.NewNop()))
+}
@@ -0,0 +1,65 @@
+package supportbundle
+
+import (
+	"fmt"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/log"
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+type cmdSupportBundleFlags struct {
+	ExportDir string
+	Output    string
+	LogFiles  []string
+	Debug     bool
+}
+
+func NewCmdSupportBundle() *cobra.Command {
+	bundleFlags := cmdSupportBundleFlags{}
+
+	supportBundleCmd := &cobra.Command{
+		Use:   "support-bundle [flags]",
+		Short: "Collect sanitized diagnostics from an export into a zip file",
+		Long: "Collect the export report, manifest, export log, recent failed API responses and " +
+			"version information of an export directory into a zip file that can be attached " +
+			"to a support request. Credentials are removed from all collected files.",
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if bundleFlags.ExportDir == "" && len(bundleFlags.LogFiles) == 0 {
+				return fmt.Errorf("an export directory or at least one log file must be specified")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			logger, err := log.NewLogger(bundleFlags.Debug)
+			if err != nil {
+				return fmt.Errorf("failed to initialize logger: %w", err)
+			}
+			defer func() {
+				_ = logger.Sync()
+			}()
+
+			bundlePath, err := utils.CreateSupportBundle(bundleFlags.ExportDir, bundleFlags.LogFiles,
+				bundleFlags.Output, logger)
+			if err != nil {
+				return err
+			}
+			logger.Info("Support bundle created", zap.String("path", bundlePath))
+			return nil
+		},
+	}
+
+	supportBundleCmd.Flags().StringVarP(&bundleFlags.ExportDir, "export-dir", "d", "",
+		"Export directory to collect the report, manifest and log from")
+	supportBundleCmd.Flags().StringVarP(&bundleFlags.Output, "output", "o", "",
+		"Path of the support bundle zip (default: ./bbc-exporter-support-TIMESTAMP.zip)")
+	supportBundleCmd.Flags().StringArrayVar(&bundleFlags.LogFiles, "log-file", nil,
+		"Additional log file to include, e.g. captured console output (can be repeated)")
+	supportBundleCmd.Flags().BoolVar(&bundleFlags.Debug, "debug", false,
+		"Enable debug logging")
+
+	return supportBundleCmd
+}
@@ -0,0 +1,57 @@
+package supportbundle
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCmdSupportBundle(t *testing.T) {
+	cmd := NewCmdSupportBundle()
+
+	assert.NotNil(t, cmd)
+	assert.Equal(t, "support-bundle [flags]", cmd.Use)
+	assert.Equal(t, "Collect sanitized diagnostics from an export into a zip file", cmd.Short)
+
+	for _, name := range []string{"export-dir", "output", "log-file", "debug"} {
+		assert.NotNil(t, cmd.Flags().Lookup(name), "flag %s should be defined", name)
+	}
+}
+
+func TestSupportBundleRequiresInput(t *testing.T) {
+	cmd := NewCmdSupportBundle()
+	cmd.SetArgs([]string{})
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "an export directory or at least one log file must be specified")
+}
+
+func TestSupportBundleCommandCreatesZip(t *testing.T) {
+	exportDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(exportDir, "export-report.json"),
+		[]byte(`{"status":"failed"}`), 0600))
+	output := filepath.Join(t.TempDir(), "bundle.zip")
+
+	cmd := NewCmdSupportBundle()
+	cmd.SetArgs([]string{"--export-dir", exportDir, "--output", output})
+	require.NoError(t, cmd.Execute())
+
+	reader, err := zip.OpenReader(output)
+	require.NoError(t, err)
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	var names []string
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	assert.Contains(t, names, "export-report.json")
+	assert.Contains(t, names, "support-info.json")
+}
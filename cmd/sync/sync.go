@@ -0,0 +1,185 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/log"
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func NewCmdSync() *cobra.Command {
+	exportFlags := data.CmdExportFlags{
+		GitOutput:      utils.GitOutputMirror,
+		ProgressFormat: utils.ProgressFormatText,
+	}
+	syncOpts := utils.SyncOptions{}
+
+	syncCmd := &cobra.Command{
+		Use:   "sync [flags]",
+		Short: "Keep an export directory up to date until the final migration",
+		Long: "Keep the export directory of a repository up to date between a cutover rehearsal and the final migration.\n\n" +
+			"Every --interval the mirror is fetched and only pull requests updated in Bitbucket since the last cycle are " +
+			"exported again, with their comments and reviews. Run it once more with --archive at cutover to produce the archive.",
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			var problems []error
+			if exportFlags.OutputDir == "" {
+				problems = append(problems, errors.New("--output must be set to the export directory to keep up to date"))
+			}
+			if syncOpts.Interval <= 0 {
+				problems = append(problems, fmt.Errorf("--interval must be positive, got %s", syncOpts.Interval))
+			}
+			problems = append(problems, utils.ValidateExportCommand(&exportFlags))
+			return utils.JoinProblems(problems...)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			logger, err := log.NewLogger(exportFlags.Debug)
+			if err != nil {
+				return fmt.Errorf("failed to initialize logger: %w", err)
+			}
+			defer func() {
+				_ = logger.Sync()
+			}()
+			zap.ReplaceGlobals(logger)
+
+			ctx, stop := utils.InterruptContext(cmd.Context(), logger)
+			defer stop()
+			return runCmdSync(ctx, &exportFlags, syncOpts, logger)
+		},
+	}
+
+	syncCmd.Flags().SortFlags = false
+	syncCmd.PersistentFlags().SortFlags = false
+
+	utils.SetupCommandUsageTemplate(syncCmd, 100)
+
+	syncCmd.PersistentFlags().StringVarP(&exportFlags.BitbucketAPIURL, "bbc-api-url", "a",
+		"https://api.bitbucket.org/2.0", "Bitbucket API to use")
+	syncCmd.PersistentFlags().StringVarP(&exportFlags.BitbucketAccessToken, "access-token", "t", "",
+		"Bitbucket workspace access token for authentication (env: BITBUCKET_ACCESS_TOKEN)")
+	syncCmd.PersistentFlags().StringVarP(&exportFlags.BitbucketAPIToken, "api-token", "", "",
+		"Bitbucket API token for authentication (env: BITBUCKET_API_TOKEN)")
+	syncCmd.PersistentFlags().StringVarP(&exportFlags.BitbucketEmail, "email", "e", "",
+		"Atlassian account email for API token authentication (env: BITBUCKET_EMAIL)")
+	syncCmd.PersistentFlags().StringVarP(&exportFlags.BitbucketUser, "user", "u", "",
+		"Bitbucket username for basic authentication (env: BITBUCKET_USERNAME)")
+	syncCmd.PersistentFlags().StringVarP(&exportFlags.BitbucketAppPass, "app-password", "p", "",
+		"Bitbucket app password for basic authentication (env: BITBUCKET_APP_PASSWORD)")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.BitbucketOAuthKey, "oauth-key", "",
+		"Bitbucket OAuth consumer key for client-credentials authentication (env: BITBUCKET_OAUTH_KEY)")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.BitbucketOAuthSecret, "oauth-secret", "",
+		"Bitbucket OAuth consumer secret for client-credentials authentication (env: BITBUCKET_OAUTH_SECRET)")
+	syncCmd.PersistentFlags().StringSliceVar(&exportFlags.AuthFallback, "auth-fallback", nil,
+		"Authentication methods to try in order when Bitbucket answers 401 or 403 for an endpoint: access-token, api-token, app-password")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.Proxy, "proxy", "",
+		"Proxy URL for Bitbucket API and git traffic: http://, https://, socks5:// or socks5h:// (default: HTTPS_PROXY)")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.CABundle, "ca-bundle", "",
+		"PEM file of CA certificates to trust for Bitbucket traffic, e.g. of a TLS-inspecting proxy")
+	syncCmd.PersistentFlags().BoolVar(&exportFlags.InsecureSkipTLS, "insecure-skip-tls", false,
+		"Skip TLS certificate verification for Bitbucket API and git traffic (not recommended)")
+	syncCmd.PersistentFlags().StringVarP(&exportFlags.Workspace, "workspace", "w", "",
+		"Bitbucket workspace name")
+	syncCmd.PersistentFlags().VarP(utils.NewRepositoryListValue(&exportFlags.Repository), "repo", "r",
+		"Name of the repository to keep up to date; repeat or separate with commas for several in one archive")
+	syncCmd.PersistentFlags().StringVarP(&exportFlags.OutputDir, "output", "o", "",
+		"Export directory to keep up to date (required); the first cycle exports into it")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.TempDir, "temp-dir", "",
+		"Temporary directory for cloning (env: BITBUCKET_TEMP_DIR)")
+	syncCmd.PersistentFlags().DurationVar(&syncOpts.Interval, "interval", utils.DefaultSyncInterval,
+		"Time between sync cycles (e.g. 30m, 1h)")
+	syncCmd.PersistentFlags().BoolVar(&syncOpts.Once, "once", false,
+		"Run a single sync cycle and exit, e.g. from cron")
+	syncCmd.PersistentFlags().BoolVar(&syncOpts.Archive, "archive", false,
+		"Run a final sync cycle and create the archive for the cutover; stop the running sync first")
+	syncCmd.PersistentFlags().BoolVar(&exportFlags.OpenPRsOnly, "open-prs-only", false,
+		"Export only open pull requests and ignore closed/merged ones")
+	syncCmd.PersistentFlags().StringVarP(&exportFlags.PRsFromDate, "prs-from-date", "", "",
+		"Export pull requests created on or after this date (format: YYYY-MM-DD)")
+	syncCmd.PersistentFlags().BoolVar(&exportFlags.SkipCommitLookup, "skip-commit-lookup", false,
+		"Skip Bitbucket API lookups to retrieve commit SHAs (use local lookup only)")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.ConfigFile, "config", "",
+		"YAML configuration file (e.g. per-endpoint API base URL overrides)")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.CommentFormatter, "comment-formatter", "markdown",
+		"Comment body format: markdown (raw Bitbucket Markdown), html-to-md (convert rendered HTML), or raw (unmodified)")
+	syncCmd.PersistentFlags().BoolVar(&exportFlags.Nice, "nice", false,
+		"Throttle Bitbucket API usage (one request at a time, delay between requests, half-size pages) for shared quotas")
+	syncCmd.PersistentFlags().StringArrayVar(&exportFlags.PruneRefs, "prune-ref", nil,
+		"Also delete refs under this prefix from the clone (refs/pull, refs/stash and refs/notes are pruned by default); repeatable")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.MaxPackSize, "max-pack-size", "1g",
+		"Repack cloned repositories whose packfiles exceed this size into smaller packs (0 disables)")
+	syncCmd.PersistentFlags().BoolVar(&exportFlags.CompactJSON, "compact-json", false,
+		"Write the JSON files in the archive minified instead of indented")
+	syncCmd.PersistentFlags().IntVar(&exportFlags.RecordsPerFile, "records-per-file", utils.DefaultRecordsPerFile,
+		"Records per archive JSON file; more go to numbered files such as pull_requests_000002.json (0 writes one file per type)")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.Wave, "wave", "",
+		"Migration wave name recorded in the manifest and report")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.UsersScope, "users-scope", "workspace",
+		"Users written to the archive: workspace (all members), contributors (pull request and comment authors), or none")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.LongPaths, "long-paths", "gnu",
+		"Archive paths over 100 characters outside git repositories: gnu (long-name entries), truncate, or error")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.TarFormat, "tar-format", "ustar",
+		"Archive header format: ustar (GNU entries only where needed), gnu, or pax (long names, large files, UTF-8)")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.TokenRefreshCmd, "token-refresh-cmd", "",
+		"Command that prints a new Bitbucket token; run on a 401 response before retrying the request")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.Consistency, "consistency", utils.ConsistencyBestEffort,
+		"How to handle pull requests newer than the fetch: best-effort (fetch missing commits) or strict (leave them out)")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.GhostUser, "ghost-user", utils.DefaultGhostUser,
+		"Login that pull requests and comments by deleted Bitbucket accounts are attributed to in the users file")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.MergeCommitCheck, "merge-commit-check", utils.MergeCommitCheckReport,
+		"Verify merge commits of merged pull requests against the mirror: report, clear (also remove mismatched merge commits) or off")
+	syncCmd.PersistentFlags().IntVar(&exportFlags.Concurrency, "concurrency", 1,
+		"Number of workers fetching pull request comments and resolving commit SHAs in parallel (1-16)")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.UserMappingFile, "user-mapping", "",
+		"CSV file mapping Bitbucket user UUIDs or display names to GitHub logins and emails (header: bitbucket,github_login,email)")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.RedactionRulesFile, "redaction-rules", "",
+		"YAML file of regular expressions replaced in pull request descriptions and comment bodies, e.g. internal hostnames")
+	syncCmd.PersistentFlags().BoolVar(&exportFlags.DownloadAttachments, "download-attachments", false,
+		"Store Bitbucket-hosted images and files linked from pull request descriptions and comments in the archive's attachments/")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.LinkTarget, "link-target", "",
+		"GitHub repository URL that commit, compare and source links in comments are rewritten to ({repository} is replaced with the repository slug)")
+	syncCmd.PersistentFlags().BoolVar(&exportFlags.VerifyArchive, "verify-archive", false,
+		"With --archive, read the archive back after creating it and fail if it does not match the export directory")
+	syncCmd.PersistentFlags().StringVar(&exportFlags.Encrypt, "encrypt", "",
+		"With --archive, encrypt the archive for a recipient (format: age:<recipient> or gpg:<recipient>)")
+	syncCmd.PersistentFlags().BoolVarP(&exportFlags.Debug, "debug", "d", false, "Enable debug logging")
+
+	if err := syncCmd.MarkPersistentFlagRequired("workspace"); err != nil {
+		fmt.Printf("Error marking workspace flag as required: %v\n", err)
+	}
+	if err := syncCmd.MarkPersistentFlagRequired("repo"); err != nil {
+		fmt.Printf("Error marking repository flag as required: %v\n", err)
+	}
+	syncCmd.MarkFlagsMutuallyExclusive("once", "archive")
+	return syncCmd
+}
+
+func runCmdSync(ctx context.Context, exportFlags *data.CmdExportFlags, syncOpts utils.SyncOptions, logger *zap.Logger) error {
+	logger.Info("Starting Bitbucket Cloud sync",
+		zap.String("workspace", exportFlags.Workspace),
+		zap.String("repository", exportFlags.Repository),
+		zap.String("output", exportFlags.OutputDir),
+		zap.Duration("interval", syncOpts.Interval))
+
+	utils.SetupEnvironmentCredentials(exportFlags)
+	if err := utils.ValidateExportFlags(exportFlags); err != nil {
+		return err
+	}
+	if err := utils.CheckRuntimePrerequisites(utils.DetectRuntime(), exportFlags.OutputDir, exportFlags.TempDir, logger); err != nil {
+		return err
+	}
+
+	output, err := utils.RunSync(ctx, exportFlags, syncOpts, logger)
+	if err != nil {
+		return err
+	}
+	if syncOpts.Once || syncOpts.Archive {
+		utils.PrintSuccessMessage(output)
+	}
+	return nil
+}
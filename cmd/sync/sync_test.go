@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestNewCmdSync(t *testing.T) {
+	cmd := NewCmdSync()
+
+	assert.NotNil(t, cmd)
+	assert.Equal(t, "sync [flags]", cmd.Use)
+	for _, name := range []string{"workspace", "repo", "output", "interval", "once", "archive", "access-token", "config", "debug"} {
+		assert.NotNil(t, cmd.PersistentFlags().Lookup(name), "flag %s should be defined", name)
+	}
+	assert.Equal(t, "1h0m0s", cmd.PersistentFlags().Lookup("interval").DefValue)
+}
+
+func TestSyncPreRunEReportsAllProblems(t *testing.T) {
+	cmd := NewCmdSync()
+	cmd.SetArgs([]string{"--workspace", "ws", "--repo", "repo", "--interval", "0s", "--comment-formatter", "bad"})
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "found 3 problems:")
+	assert.ErrorContains(t, err, "--output must be set")
+	assert.ErrorContains(t, err, "--interval must be positive")
+}
+
+func TestSyncRejectsOnceWithArchive(t *testing.T) {
+	cmd := NewCmdSync()
+	cmd.SetArgs([]string{"--workspace", "ws", "--repo", "repo", "--output", t.TempDir(), "--once", "--archive"})
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "none of the others can be")
+}
+
+func TestRunCmdSyncRequiresCredentials(t *testing.T) {
+	for _, name := range []string{"BITBUCKET_ACCESS_TOKEN", "BITBUCKET_API_TOKEN", "BITBUCKET_EMAIL",
+		"BITBUCKET_USERNAME", "BITBUCKET_APP_PASSWORD"} {
+		t.Setenv(name, "")
+	}
+	exportFlags := &data.CmdExportFlags{Workspace: "ws", Repository: "repo", OutputDir: t.TempDir()}
+
+	err := runCmdSync(context.Background(), exportFlags, utils.SyncOptions{Interval: time.Hour}, zap.NewNop())
+	assert.ErrorContains(t, err, "authentication credentials required")
+}
+
+func TestRunCmdSyncStopsOnCancel(t *testing.T) {
+	exportFlags := &data.CmdExportFlags{Workspace: "ws", Repository: "repo", OutputDir: t.TempDir(),
+		BitbucketAccessToken: "token", GitOutput: utils.GitOutputMirror}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := runCmdSync(ctx, exportFlags, utils.SyncOptions{Interval: time.Hour}, zap.NewNop())
+	assert.NoError(t, err, "a stopped sync is not an error")
+}
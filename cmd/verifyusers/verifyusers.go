@@ -0,0 +1,116 @@
+package verifyusers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/log"
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func NewCmdVerifyUsers() *cobra.Command {
+	migrateFlags := data.CmdMigrateFlags{}
+	verifyOpts := utils.UserVerificationOptions{}
+	var debug bool
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify-users [flags]",
+		Short: "Match the user emails of an export to GitHub accounts",
+		Long: "Search GitHub for the accounts behind the user emails of an export and predict how many mannequins " +
+			"the import will create.\n\n" +
+			"Users whose email belongs to exactly one GitHub account are reported as matched; every other user is " +
+			"expected to become a mannequin. Searches are spaced by --search-interval to stay within GitHub's search rate limit.",
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			var problems []error
+			if verifyOpts.ExportPath == "" {
+				problems = append(problems, errors.New("--export must be set to the export directory or archive"))
+			}
+			if verifyOpts.SearchInterval < 0 {
+				problems = append(problems, fmt.Errorf("--search-interval must not be negative, got %s", verifyOpts.SearchInterval))
+			}
+			if _, _, err := utils.GetAPIURLHost(migrateFlags.TargetAPIURL); err != nil {
+				problems = append(problems, err)
+			}
+			return utils.JoinProblems(problems...)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			logger, err := log.NewLogger(debug)
+			if err != nil {
+				return fmt.Errorf("failed to initialize logger: %w", err)
+			}
+			defer func() {
+				_ = logger.Sync()
+			}()
+			zap.ReplaceGlobals(logger)
+
+			host, _, err := utils.GetAPIURLHost(migrateFlags.TargetAPIURL)
+			if err != nil {
+				return fmt.Errorf("invalid target API URL: %w", err)
+			}
+			authToken, err := utils.GetGitHubAuthToken(&migrateFlags, logger)
+			if err != nil {
+				return fmt.Errorf("failed to get GitHub authentication token: %w", err)
+			}
+			restClient, err := api.NewRESTClient(api.ClientOptions{
+				Headers: map[string]string{
+					"Accept": "application/vnd.github+json",
+				},
+				Host:      host,
+				AuthToken: authToken,
+			})
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := utils.InterruptContext(cmd.Context(), logger)
+			defer stop()
+			return runCmdVerifyUsers(ctx, verifyOpts, utils.NewAPIGetter(&api.GraphQLClient{}, restClient, authToken), logger)
+		},
+	}
+
+	verifyCmd.Flags().SortFlags = false
+	verifyCmd.PersistentFlags().SortFlags = false
+
+	utils.SetupCommandUsageTemplate(verifyCmd, 100)
+
+	verifyCmd.PersistentFlags().StringVar(&verifyOpts.ExportPath, "export", "",
+		"Export directory or .tar.gz archive whose users to verify (required)")
+	verifyCmd.PersistentFlags().StringVar(&migrateFlags.GitHubPAT, "github-target-pat", "",
+		"GitHub Personal Access Token (env: GITHUB_PAT)")
+	verifyCmd.PersistentFlags().StringVar(&migrateFlags.TargetAPIURL, "target-api-url", "https://api.github.com",
+		"The URL of the target API, if not on github.com. Defaults to https://api.github.com")
+	verifyCmd.PersistentFlags().DurationVar(&verifyOpts.SearchInterval, "search-interval", utils.DefaultSearchInterval,
+		"Least time between GitHub user searches; the default stays within 30 searches a minute")
+	verifyCmd.PersistentFlags().StringVarP(&verifyOpts.Output, "output", "o", utils.DefaultUserVerificationReport,
+		"Path of the account-matching report")
+	verifyCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "Enable debug logging")
+
+	if err := verifyCmd.MarkPersistentFlagRequired("export"); err != nil {
+		fmt.Printf("Error marking export flag as required: %v\n", err)
+	}
+	return verifyCmd
+}
+
+func runCmdVerifyUsers(ctx context.Context, verifyOpts utils.UserVerificationOptions, g *utils.APIGetter, logger *zap.Logger) error {
+	logger.Info("Starting verification of user emails",
+		zap.String("export", verifyOpts.ExportPath),
+		zap.Duration("searchInterval", verifyOpts.SearchInterval),
+		zap.String("output", verifyOpts.Output))
+
+	report, err := utils.RunUserVerification(ctx, verifyOpts, g, logger)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Matched %d of %d users to GitHub accounts (%d ambiguous, %d unmatched, %d without email)\n",
+		report.Matched, report.Users, report.Ambiguous, report.Unmatched, report.WithoutEmail)
+	fmt.Printf("Predicted mannequins: %d\n", report.PredictedMannequins)
+	fmt.Printf("Report: %s\n", verifyOpts.Output)
+	return nil
+}
@@ -0,0 +1,31 @@
+package verifyusers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCmdVerifyUsers(t *testing.T) {
+	cmd := NewCmdVerifyUsers()
+
+	assert.NotNil(t, cmd)
+	assert.Equal(t, "verify-users [flags]", cmd.Use)
+	for _, name := range []string{"export", "github-target-pat", "target-api-url", "search-interval", "output", "debug"} {
+		assert.NotNil(t, cmd.PersistentFlags().Lookup(name), "flag %s should be defined", name)
+	}
+	assert.Equal(t, "2s", cmd.PersistentFlags().Lookup("search-interval").DefValue)
+	assert.Equal(t, "user-verification-report.json", cmd.PersistentFlags().Lookup("output").DefValue)
+}
+
+func TestVerifyUsersPreRunEReportsAllProblems(t *testing.T) {
+	cmd := NewCmdVerifyUsers()
+	cmd.SetArgs([]string{"--export", "", "--search-interval", "-1s"})
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+
+	err := cmd.Execute()
+	assert.ErrorContains(t, err, "found 2 problems:")
+	assert.ErrorContains(t, err, "--export must be set")
+	assert.ErrorContains(t, err, "--search-interval must not be negative")
+}
@@ -0,0 +1,103 @@
+package version
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+	"github.com/katiem0/gh-bbc-exporter/internal/version"
+	"github.com/spf13/cobra"
+)
+
+type versionReport struct {
+	version.BuildInfo
+	OS                      string   `json:"os"`
+	Arch                    string   `json:"arch"`
+	SupportedSchemaVersions []string `json:"supported_schema_versions"`
+	MinimumGitVersion       string   `json:"minimum_git_version"`
+	GitVersion              string   `json:"git_version,omitempty"`
+	GitVersionSupported     bool     `json:"git_version_supported"`
+	Container               bool     `json:"container"`
+}
+
+func NewCmdVersion() *cobra.Command {
+	var jsonOutput bool
+
+	versionCmd := &cobra.Command{
+		Use:   "version [flags]",
+		Short: "Show build information and archive schema compatibility",
+		Long: "Show the exporter version, commit and build date, the migration archive schema " +
+			"versions it supports, and whether the installed git meets the minimum version.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			report := newVersionReport(utils.GitVersion())
+			report.Container = utils.DetectRuntime().Container
+			return printVersion(cmd.OutOrStdout(), report, jsonOutput)
+		},
+	}
+
+	versionCmd.Flags().BoolVar(&jsonOutput, "json", false, "Print the version information as JSON")
+
+	return versionCmd
+}
+
+func newVersionReport(gitVersion string) versionReport {
+	return versionReport{
+		BuildInfo:               version.Get(),
+		OS:                      runtime.GOOS,
+		Arch:                    runtime.GOARCH,
+		SupportedSchemaVersions: version.SupportedSchemaVersions,
+		MinimumGitVersion:       version.MinimumGitVersion,
+		GitVersion:              gitVersion,
+		GitVersionSupported:     version.AtLeast(gitVersion, version.MinimumGitVersion),
+	}
+}
+
+func printVersion(out io.Writer, report versionReport, jsonOutput bool) error {
+	if jsonOutput {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	gitStatus := "not found"
+	if report.GitVersion != "" {
+		gitStatus = report.GitVersion
+		if !report.GitVersionSupported {
+			gitStatus += " (older than the minimum, please upgrade)"
+		}
+	}
+
+	lines := []string{
+		fmt.Sprintf("bbc-exporter %s", report.Version),
+		fmt.Sprintf("  Commit:                    %s", valueOrUnknown(report.Commit)),
+		fmt.Sprintf("  Build date:                %s", valueOrUnknown(report.BuildDate)),
+		fmt.Sprintf("  Distribution:              %s", report.Distribution),
+		fmt.Sprintf("  Go version:                %s", valueOrUnknown(report.GoVersion)),
+		fmt.Sprintf("  Platform:                  %s/%s", report.OS, report.Arch),
+		fmt.Sprintf("  Container:                 %s", yesNo(report.Container)),
+		fmt.Sprintf("  Archive schema versions:   %s", strings.Join(report.SupportedSchemaVersions, ", ")),
+		fmt.Sprintf("  Minimum git version:       %s", report.MinimumGitVersion),
+		fmt.Sprintf("  Installed git version:     %s", gitStatus),
+	}
+	_, err := fmt.Fprintln(out, strings.Join(lines, "\n"))
+	return err
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+func valueOrUnknown(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return value
+}
@@ -0,0 +1,69 @@
+package version
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCmdVersion(t *testing.T) {
+	cmd := NewCmdVersion()
+
+	assert.Equal(t, "version [flags]", cmd.Use)
+	assert.NotNil(t, cmd.Flags().Lookup("json"))
+}
+
+func TestVersionCommandOutput(t *testing.T) {
+	cmd := NewCmdVersion()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{})
+
+	require.NoError(t, cmd.Execute())
+	output := buf.String()
+	assert.Contains(t, output, "bbc-exporter "+version.Get().Version)
+	assert.Contains(t, output, "Archive schema versions:   "+version.ArchiveSchemaVersion)
+	assert.Contains(t, output, "Minimum git version:       "+version.MinimumGitVersion)
+}
+
+func TestVersionCommandJSON(t *testing.T) {
+	cmd := NewCmdVersion()
+	buf := new(bytes.Buffer)
+	cmd.SetOut(buf)
+	cmd.SetArgs([]string{"--json"})
+
+	require.NoError(t, cmd.Execute())
+	var report map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &report))
+	assert.Equal(t, version.Get().Version, report["version"])
+	assert.Equal(t, version.MinimumGitVersion, report["minimum_git_version"])
+	assert.Equal(t, []interface{}{version.ArchiveSchemaVersion}, report["supported_schema_versions"])
+}
+
+func TestPrintVersionGitStatus(t *testing.T) {
+	buf := new(bytes.Buffer)
+	require.NoError(t, printVersion(buf, newVersionReport(""), false))
+	assert.Contains(t, buf.String(), "Installed git version:     not found")
+
+	buf.Reset()
+	require.NoError(t, printVersion(buf, newVersionReport("2.20.1"), false))
+	assert.Contains(t, buf.String(), "2.20.1 (older than the minimum, please upgrade)")
+
+	buf.Reset()
+	require.NoError(t, printVersion(buf, newVersionReport("2.43.0"), false))
+	assert.NotContains(t, buf.String(), "older than the minimum")
+}
+
+func TestPrintVersionContainer(t *testing.T) {
+	report := newVersionReport("2.43.0")
+	report.Container = true
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, printVersion(buf, report, false))
+	assert.Contains(t, buf.String(), "Container:                 yes")
+	assert.Contains(t, buf.String(), "Distribution:              "+report.Distribution)
+}
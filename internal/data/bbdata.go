@@ -1,11 +1,22 @@
 package data
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type CmdExportFlags struct {
 	BitbucketAccessToken string
 	BitbucketUser        string // Will be deprecated with AppPass Sept 2025
 	BitbucketEmail       string // Will replace username after Sept 2025
 	BitbucketAppPass     string // Will be deprecated from BB Sept 2025
 	BitbucketAPIToken    string // Will replace AppPass after Sept 2025
+	BitbucketOAuthKey    string // OAuth consumer key for the client-credentials grant
+	BitbucketOAuthSecret string
+	AuthFallback         []string // access-token, api-token, app-password: tried in order on a 401 or 403
+	Proxy                string   // http, https, socks5 or socks5h proxy URL for API and git traffic
+	CABundle             string   // PEM file of extra trusted CA certificates
+	InsecureSkipTLS      bool
 	BitbucketAPIURL      string
 	Repository           string
 	Workspace            string
@@ -13,7 +24,77 @@ type CmdExportFlags struct {
 	TempDir              string
 	PRsFromDate          string // Format: YYYY-MM-DD
 	OpenPRsOnly          bool
-	SkipCommitLookup     bool // If true, do not call Bitbucket Cloud API to retrieve commit SHAs
+	SkipCommitLookup     bool   // If true, do not call Bitbucket Cloud API to retrieve commit SHAs
+	ColdStorageBefore    string // Format: YYYY-MM-DD
+	ColdStorageDeclined  bool
+	AllRepos             bool
+	GroupByProject       bool
+	IncludeReposFile     string   // With AllRepos, only export repositories listed in this file
+	ExcludeReposFile     string   // With AllRepos, skip repositories listed in this file
+	FailOnUnsafePaths    bool     // If true, abort when the repository contains paths GitHub rejects
+	PRsTouchingPaths     []string // Glob patterns; only PRs modifying a matching path are exported
+	SubdirSplits         []string // Format: path=new-repo-name
+	ReactionMapFile      string   // YAML file mapping Bitbucket shortcodes to GitHub reactions
+	RedactionRulesFile   string   // YAML file of regex replacements applied to PR and comment bodies
+	DownloadAttachments  bool     // Store Bitbucket-hosted files linked from PR and comment bodies in the archive
+	PredictPRNumbers     bool     // Write pr-number-map.json and point #123 references at the predicted GitHub numbers
+	PRNumberOffset       int      // Issues and pull requests the target GitHub repository already has
+	MaxDuration          time.Duration
+	ConfigFile           string        // YAML exporter configuration file
+	KeepAmbiguousPRs     bool          // If true, prefix ambiguous branch refs instead of dropping the PR
+	Encrypt              string        // Format: age:<recipient> or gpg:<recipient>
+	CommentFormatter     string        // markdown, html-to-md or raw
+	Nice                 bool          // Throttle API usage for workspaces with a shared quota
+	NDJSON               bool          // Also write flat NDJSON copies of PRs, comments and users
+	CompareStats         bool          // Compare the archive with Bitbucket's own repository statistics
+	PruneRefs            []string      // Extra ref prefixes to delete from cloned repositories
+	KeepNotes            bool          // If true, keep refs/notes instead of pruning them
+	MaxPackSize          string        // Split packfiles larger than this size, e.g. 1g; 0 disables
+	CompactJSON          bool          // Write archive JSON files without indentation
+	RecordsPerFile       int           // Most records per archive JSON file before the next numbered file; 0 writes one file
+	ExportRulesets       bool          // Translate Bitbucket branch restrictions into GitHub rulesets
+	ExportWatchers       bool          // List repository watchers in watchers.json outside the archive
+	ProtectionHealth     bool          // Correlate branch restrictions with recent push activity in protection-health.json
+	ProtectionHealthDays int           // Days of push activity analysed by --protection-health
+	ConvertPipelines     bool          // Translate bitbucket-pipelines.yml into GitHub Actions workflow stubs
+	IssueLinks           bool          // List the Bitbucket issues pull requests reference in issue-links.json
+	FixedTimestamps      bool          // Stamp generated records with a fixed time for reproducible archives
+	Wave                 string        // Migration wave recorded in the manifest, report and output name
+	OutputPrefix         string        // Prefix of the archive and report names, recorded in the manifest and report
+	UsersScope           string        // contributors, workspace or none
+	LongPaths            string        // gnu, truncate or error for long non-git archive paths
+	TarFormat            string        // ustar, gnu or pax archive headers
+	DropPendingReviews   bool          // Leave reviews with only unpublished (pending) comments out of the archive
+	ExportPatches        bool          // Save open pull request diffs under patches/ outside the archive
+	GenerateCodeowners   bool          // Write CODEOWNERS files from default reviewers under migration-notes/
+	TokenRefreshCmd      string        // Shell command printing a new token when Bitbucket returns 401
+	SplitLinkBase        string        // Base URL of the split targets, e.g. https://github.com/org
+	Consistency          string        // best-effort or strict reconciliation of API data with the clone
+	TopUpFetch           bool          // Fetch every mirror again after the metadata export, right before archiving
+	LocalMirror          string        // Existing bare mirror exported instead of cloning the repository from Bitbucket
+	CloneRetries         int           // Retries of a failed git clone per clone URL
+	CloneRetryDelay      time.Duration // Wait before the first clone retry; doubled for each further one
+	CloneProtocol        string        // https or ssh: clone URL tried first, the other is the fallback
+	FailOnCloneError     bool          // Fail instead of exporting an empty mirror or leaving out a wiki that failed to clone
+	SkipGit              bool          // Store empty placeholder repositories instead of cloning
+	GitDepth             int           // Commits of history cloned per branch; 0 clones the full history
+	VerifyFrozen         bool          // Fail if branches, tags or pull requests changed while the export ran
+	AsOf                 string        // Format: YYYY-MM-DD or RFC 3339; export the repository as it was then
+	GhostUser            string        // Login pull requests and comments by deleted accounts are attributed to
+	Resume               bool          // Continue the interrupted export recorded in the output directory's checkpoint
+	DownloadAvatar       bool          // Save the workspace avatar next to the archive
+	MergeCommitCheck     string        // report, clear or off: verify merge commits of merged pull requests
+	PRFooterTemplate     string        // Go template appended to every pull request description
+	CommentFooter        string        // Go template appended to every pull request comment
+	Concurrency          int           // Workers fetching pull request comments and commit SHAs
+	AnalyzeDocs          bool          // Flag markdown files relying on Bitbucket-specific syntax in docs-report.json
+	UserMappingFile      string        // CSV file mapping Bitbucket UUIDs or display names to GitHub logins and emails
+	GitOutput            string        // mirror, bundle or both: how repositories are stored in the archive
+	VerifyArchive        bool          // Re-read the archive after creating it and fail if it does not match the export directory
+	PreserveFileModes    bool          // Keep host permission bits in the archive instead of normalizing them to 0644/0755
+	LinkTarget           string        // GitHub repository URL commit, compare and src links in comments are rewritten to
+	ProgressFormat       string        // text or json: json streams progress events to stdout
+	FeatureChecklist     bool          // List the Bitbucket features in use and their migration status in feature-checklist.json
 	Debug                bool
 }
 
@@ -26,10 +107,34 @@ type BitbucketRepository struct {
 	Description string `json:"description"`
 	CreatedOn   string `json:"created_on"`
 	IsPrivate   bool   `json:"is_private"`
+	HasWiki     bool   `json:"has_wiki"`
+	HasIssues   bool   `json:"has_issues"`
+	SCM         string `json:"scm"`
+	Size        int64  `json:"size"`
 	MainBranch  *struct {
 		Name string `json:"name"`
 		Type string `json:"type"`
 	} `json:"mainbranch"`
+	Project *BitbucketProject `json:"project,omitempty"`
+}
+
+type BitbucketProject struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	UUID string `json:"uuid"`
+}
+
+// BitbucketCountResponse is used to read only the total size of a paginated
+// collection; Size is nil when Bitbucket does not report it. Values holds the
+// first page undecoded, for collections that never report a size.
+type BitbucketCountResponse struct {
+	Size   *int              `json:"size"`
+	Values []json.RawMessage `json:"values"`
+}
+
+type BitbucketRepositoryResponse struct {
+	Values []BitbucketRepository `json:"values"`
+	Next   string                `json:"next"`
 }
 
 type Owner struct {
@@ -110,6 +215,36 @@ type BitbucketUserResponse struct {
 	Next string `json:"next"`
 }
 
+type BitbucketWatcherResponse struct {
+	Values []BitbucketPRUser `json:"values"`
+	Next   string            `json:"next"`
+}
+
+type BitbucketUserPermission struct {
+	Permission string          `json:"permission"`
+	User       BitbucketPRUser `json:"user"`
+}
+
+type BitbucketUserPermissionResponse struct {
+	Values []BitbucketUserPermission `json:"values"`
+	Next   string                    `json:"next"`
+}
+
+type BitbucketGroupPermission struct {
+	Permission string         `json:"permission"`
+	Group      BitbucketGroup `json:"group"`
+}
+
+type BitbucketGroupPermissionResponse struct {
+	Values []BitbucketGroupPermission `json:"values"`
+	Next   string                     `json:"next"`
+}
+
+type BitbucketDefaultReviewersResponse struct {
+	Values []BitbucketPRUser `json:"values"`
+	Next   string            `json:"next"`
+}
+
 type BitbucketCommentResponse struct {
 	Values []BitbucketComment `json:"values"`
 	Next   string             `json:"next"`
@@ -118,13 +253,41 @@ type BitbucketCommentResponse struct {
 type BitbucketComment struct {
 	ID      int `json:"id"`
 	Content struct {
-		Raw string `json:"raw"`
+		Raw  string `json:"raw"`
+		HTML string `json:"html"`
 	} `json:"content"`
 	User      BitbucketPRUser `json:"user"`
 	CreatedOn string          `json:"created_on"`
 	UpdatedOn string          `json:"updated_on"`
 	Inline    *Inline         `json:"inline"`
 	Parent    *Parent         `json:"parent,omitempty"`
+	Pending   bool            `json:"pending"`
+}
+
+// BitbucketActivityResponse is a page of a pull request's activity log,
+// newest first. Pages are linked by cursor, not page number.
+type BitbucketActivityResponse struct {
+	Values []BitbucketActivity `json:"values"`
+	Next   string              `json:"next"`
+}
+
+// BitbucketActivity is one entry of the activity log; exactly one of its
+// fields is set for the entries the exporter uses.
+type BitbucketActivity struct {
+	Approval         *BitbucketApproval `json:"approval,omitempty"`
+	ChangesRequested *BitbucketApproval `json:"changes_requested,omitempty"`
+	Update           *BitbucketPRUpdate `json:"update,omitempty"`
+}
+
+type BitbucketApproval struct {
+	Date string          `json:"date"`
+	User BitbucketPRUser `json:"user"`
+}
+
+type BitbucketPRUpdate struct {
+	State  string          `json:"state"`
+	Date   string          `json:"date"`
+	Author BitbucketPRUser `json:"author"`
 }
 
 type Parent struct {
@@ -137,3 +300,76 @@ type Inline struct {
 	To   *int   `json:"to"`
 	Path string `json:"path"`
 }
+
+type RepositoryURL struct {
+	Host       string
+	Workspace  string // Project key for Bitbucket Server URLs
+	Repository string
+	Cloud      bool
+}
+
+type BitbucketWorkspace struct {
+	UUID    string `json:"uuid"`
+	Slug    string `json:"slug"`
+	Name    string `json:"name"`
+	Website string `json:"website"` // Only set for workspaces migrated from team accounts
+	Links   struct {
+		Avatar struct {
+			Href string `json:"href"`
+		} `json:"avatar"`
+	} `json:"links"`
+}
+
+type BitbucketRef struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"` // branch or tag
+	Target struct {
+		Hash string `json:"hash"`
+	} `json:"target"`
+}
+
+type BitbucketRefResponse struct {
+	Values []BitbucketRef `json:"values"`
+	Next   string         `json:"next"`
+}
+
+type BitbucketBranchRestriction struct {
+	ID              int              `json:"id"`
+	Kind            string           `json:"kind"`
+	BranchMatchKind string           `json:"branch_match_kind"`
+	BranchType      string           `json:"branch_type"`
+	Pattern         string           `json:"pattern"`
+	Value           *int             `json:"value"`
+	Users           []Owner          `json:"users"`
+	Groups          []BitbucketGroup `json:"groups"`
+}
+
+// BitbucketBranchingModel names the branches that branch restrictions on a
+// branch type apply to.
+type BitbucketBranchingModel struct {
+	Development *BranchingModelBranch `json:"development"`
+	Production  *BranchingModelBranch `json:"production"`
+	BranchTypes []BranchingModelType  `json:"branch_types"`
+}
+
+type BranchingModelBranch struct {
+	Name   string `json:"name"`
+	Branch *struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+}
+
+type BranchingModelType struct {
+	Kind   string `json:"kind"`
+	Prefix string `json:"prefix"`
+}
+
+type BitbucketGroup struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+}
+
+type BitbucketBranchRestrictionResponse struct {
+	Values []BitbucketBranchRestriction `json:"values"`
+	Next   string                       `json:"next"`
+}
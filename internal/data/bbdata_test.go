@@ -175,7 +175,8 @@ func TestBitbucketCommentResponseJSON(t *testing.T) {
 				CreatedOn: "2023-01-01T00:00:00Z",
 				UpdatedOn: "2023-01-01T00:00:00Z",
 				Content: struct {
-					Raw string `json:"raw"`
+					Raw  string `json:"raw"`
+					HTML string `json:"html"`
 				}{
 					Raw: "Test comment",
 				},
@@ -0,0 +1,642 @@
+package data
+
+type ColdStorageBundle struct {
+	Cutoff          string                     `json:"cutoff,omitempty"`
+	IncludeDeclined bool                       `json:"include_declined"`
+	PullRequests    []PullRequest              `json:"pull_requests"`
+	IssueComments   []IssueComment             `json:"issue_comments"`
+	ReviewComments  []PullRequestReviewComment `json:"pull_request_review_comments"`
+}
+
+type UnsafePath struct {
+	Repository string `json:"repository"`
+	Path       string `json:"path"`
+	Reason     string `json:"reason"`
+}
+
+type ImportSafetyReport struct {
+	UnsafePaths []UnsafePath `json:"unsafe_paths"`
+}
+
+// DocFinding is a line of a markdown file that renders differently, or
+// breaks, on GitHub because it relies on Bitbucket-specific syntax.
+type DocFinding struct {
+	Repository string `json:"repository"`
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Construct  string `json:"construct"`
+	Text       string `json:"text"`
+}
+
+type DocsReport struct {
+	Findings []DocFinding `json:"findings"`
+}
+
+// FeatureStatus is the migration status of a Bitbucket feature: exported,
+// manual_step_required, unsupported, not_in_use or unknown when it could not
+// be detected.
+type FeatureStatus struct {
+	Feature  string `json:"feature"`
+	Status   string `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Guidance string `json:"guidance,omitempty"`
+}
+
+type RepositoryFeatures struct {
+	Repository string          `json:"repository"`
+	Features   []FeatureStatus `json:"features"`
+}
+
+// FeatureChecklist is the gap analysis of an export: the Bitbucket features
+// the workspace and each repository use, and what is left to do for them.
+type FeatureChecklist struct {
+	Workspace         string               `json:"workspace"`
+	WorkspaceFeatures []FeatureStatus      `json:"workspace_features"`
+	Repositories      []RepositoryFeatures `json:"repositories"`
+}
+
+// WatchersReport lists who watched each exported repository on Bitbucket,
+// so they can be asked to watch it again on GitHub.
+type WatchersReport struct {
+	Note         string               `json:"note"`
+	Repositories []RepositoryWatchers `json:"repositories"`
+}
+
+type RepositoryWatchers struct {
+	SourceRepository string    `json:"source_repository"`
+	Repository       string    `json:"repository"`
+	Watchers         []Watcher `json:"watchers"`
+	Notes            []string  `json:"notes,omitempty"`
+}
+
+// Watcher is a Bitbucket watcher. Login is the GitHub login from
+// --user-mapping when Mapped is set, and the Bitbucket UUID otherwise.
+type Watcher struct {
+	DisplayName string `json:"display_name"`
+	Nickname    string `json:"nickname,omitempty"`
+	UUID        string `json:"uuid"`
+	Login       string `json:"login"`
+	Mapped      bool   `json:"mapped"`
+}
+
+// ProtectionHealthReport correlates the branch restrictions of each
+// repository with the commits and pull request merges that reached the
+// protected branches in the last WindowDays days.
+type ProtectionHealthReport struct {
+	Note         string                       `json:"note"`
+	Since        string                       `json:"since"`
+	WindowDays   int                          `json:"window_days"`
+	Repositories []RepositoryProtectionHealth `json:"repositories"`
+}
+
+// RepositoryProtectionHealth is the protection health of one repository.
+type RepositoryProtectionHealth struct {
+	SourceRepository string             `json:"source_repository"`
+	Patterns         []ProtectedPattern `json:"patterns"`
+	// UnprotectedBranches received pull request merges in the window but
+	// match no branch restriction.
+	UnprotectedBranches []BranchActivity `json:"unprotected_branches"`
+	Notes               []string         `json:"notes,omitempty"`
+}
+
+// ProtectedPattern is a Bitbucket branch pattern with the restriction kinds
+// set on it and the activity of the branches it matches. Status is
+// exercised, idle or no_matching_branches.
+type ProtectedPattern struct {
+	Pattern           string           `json:"pattern"`
+	Restrictions      []string         `json:"restrictions"`
+	Branches          []BranchActivity `json:"branches"`
+	Commits           int              `json:"commits"`
+	PullRequestMerges int              `json:"pull_request_merges"`
+	DirectCommits     int              `json:"direct_commits"`
+	Status            string           `json:"status"`
+	Recommendation    string           `json:"recommendation"`
+}
+
+// BranchActivity counts the first-parent commits of a branch in the window,
+// the pull requests merged into it, and the commits that arrived without a
+// pull request.
+type BranchActivity struct {
+	Branch            string `json:"branch"`
+	LastCommitAt      string `json:"last_commit_at,omitempty"`
+	Commits           int    `json:"commits"`
+	PullRequestMerges int    `json:"pull_request_merges"`
+	DirectCommits     int    `json:"direct_commits"`
+}
+
+// PipelinesConversionReport lists the GitHub Actions workflow stubs
+// translated from the bitbucket-pipelines.yml of each repository and the
+// pipeline features they do not cover.
+type PipelinesConversionReport struct {
+	Note         string                         `json:"note"`
+	Repositories []RepositoryPipelineConversion `json:"repositories"`
+}
+
+// RepositoryPipelineConversion is the pipeline conversion of one repository.
+// Workflows are paths relative to the export directory.
+type RepositoryPipelineConversion struct {
+	SourceRepository string                       `json:"source_repository"`
+	Workflows        []string                     `json:"workflows"`
+	Unsupported      []UnsupportedPipelineFeature `json:"unsupported"`
+	Error            string                       `json:"error,omitempty"`
+}
+
+// UnsupportedPipelineFeature is a part of a Bitbucket pipeline that the
+// workflow stubs leave out or only approximate.
+type UnsupportedPipelineFeature struct {
+	Pipeline string `json:"pipeline"`
+	Step     string `json:"step,omitempty"`
+	Feature  string `json:"feature"`
+	Detail   string `json:"detail"`
+}
+
+// IssueLinksReport lists the Bitbucket issues each pull request references
+// through its source branch, title or description.
+type IssueLinksReport struct {
+	Note         string                 `json:"note"`
+	Repositories []RepositoryIssueLinks `json:"repositories"`
+}
+
+type RepositoryIssueLinks struct {
+	SourceRepository string      `json:"source_repository"`
+	Links            []IssueLink `json:"links"`
+}
+
+type IssueLink struct {
+	PullRequest int    `json:"pull_request"`
+	Issue       int    `json:"issue"`
+	Source      string `json:"source"` // branch, title or description
+	Closes      bool   `json:"closes"` // Referenced with a closing keyword such as "fixes #12"
+}
+
+// PRNumberMap predicts the number GitHub gives each imported pull request:
+// the pull requests of a repository are numbered in creation order after
+// the items the target repository already has.
+type PRNumberMap struct {
+	Note         string                `json:"note"`
+	Repositories []RepositoryPRNumbers `json:"repositories"`
+}
+
+type RepositoryPRNumbers struct {
+	SourceRepository string     `json:"source_repository"`
+	Offset           int        `json:"offset"`
+	PullRequests     []PRNumber `json:"pull_requests"`
+}
+
+type PRNumber struct {
+	Bitbucket int `json:"bitbucket"`
+	GitHub    int `json:"github"`
+}
+
+type SubdirSplit struct {
+	Path     string `json:"path"`
+	RepoName string `json:"repo_name"`
+}
+
+// SplitManifest records which pull requests each --subdir-split target
+// received. It drives the rewriting of links to pull requests that moved to
+// another target.
+type SplitManifest struct {
+	SourceRepository string        `json:"source_repository"`
+	LinkBase         string        `json:"link_base,omitempty"`
+	Targets          []SplitTarget `json:"targets"`
+}
+
+type SplitTarget struct {
+	RepoName       string `json:"repo_name"`
+	Path           string `json:"path"`
+	Output         string `json:"output"`
+	PullRequests   []int  `json:"pull_requests"`
+	RewrittenLinks int    `json:"rewritten_links"`
+}
+
+type ReactionMappingConfig struct {
+	Reactions map[string]string `yaml:"reactions"`
+}
+
+// RedactionRulesConfig is the --redaction-rules file: regular expressions
+// replaced in pull request and comment bodies, in order.
+type RedactionRulesConfig struct {
+	Rules []RedactionRule `yaml:"rules"`
+}
+
+type RedactionRule struct {
+	Pattern string `yaml:"pattern"`
+	// Replacement may refer to capture groups as $1 or ${name}; nil
+	// replaces matches with [REDACTED].
+	Replacement *string `yaml:"replacement"`
+}
+
+type ExportCounts struct {
+	PullRequests            int `json:"pull_requests"`
+	IssueComments           int `json:"issue_comments"`
+	ReviewComments          int `json:"review_comments"`
+	ActivityReviews         int `json:"activity_reviews,omitempty"`
+	ColdStoragePullRequests int `json:"cold_storage_pull_requests"`
+	UnsafePaths             int `json:"unsafe_paths"`
+	// PendingReviewCommentsDropped counts comments of pending-only reviews
+	// left out with --drop-pending-reviews.
+	PendingReviewCommentsDropped int `json:"pending_review_comments_dropped,omitempty"`
+	// Reconciliation of API data with the mirror, see --consistency.
+	ConsistencyDroppedPullRequests int `json:"consistency_dropped_pull_requests,omitempty"`
+	ConsistencyDroppedComments     int `json:"consistency_dropped_comments,omitempty"`
+	RefetchedPullRequests          int `json:"refetched_pull_requests,omitempty"`
+	InconsistentPullRequests       int `json:"inconsistent_pull_requests,omitempty"`
+	TopUpUpdatedRefs               int `json:"top_up_updated_refs,omitempty"`
+	AsOfTrimmedRefs                int `json:"as_of_trimmed_refs,omitempty"`
+	AsOfDroppedPullRequests        int `json:"as_of_dropped_pull_requests,omitempty"`
+	AsOfReopenedPullRequests       int `json:"as_of_reopened_pull_requests,omitempty"`
+	AsOfDroppedComments            int `json:"as_of_dropped_comments,omitempty"`
+	GhostPullRequests              int `json:"ghost_pull_requests,omitempty"`
+	GhostComments                  int `json:"ghost_comments,omitempty"`
+	ClearedMergeCommits            int `json:"cleared_merge_commits,omitempty"`
+	DocFindings                    int `json:"doc_findings,omitempty"`
+	GitBundles                     int `json:"git_bundles,omitempty"`
+	Wikis                          int `json:"wikis,omitempty"`
+	CloneRetries                   int `json:"clone_retries,omitempty"`
+	PlaceholderRepositories        int `json:"placeholder_repositories,omitempty"`
+	ConvertedWorkflows             int `json:"converted_workflows,omitempty"`
+	UnsupportedPipelineFeatures    int `json:"unsupported_pipeline_features,omitempty"`
+	LFSObjects                     int `json:"lfs_objects,omitempty"`
+	ManualFeatureSteps             int `json:"manual_feature_steps,omitempty"`
+	RedactedBodies                 int `json:"redacted_bodies,omitempty"`
+	Watchers                       int `json:"watchers,omitempty"`
+	Attachments                    int `json:"attachments,omitempty"`
+	FailedAttachments              int `json:"failed_attachments,omitempty"`
+	RenumberedPRReferences         int `json:"renumbered_pr_references,omitempty"`
+	IssueLinks                     int `json:"issue_links,omitempty"`
+}
+
+type ExportReport struct {
+	Status          string       `json:"status"`
+	Workspace       string       `json:"workspace"`
+	Wave            string       `json:"wave,omitempty"`
+	OutputPrefix    string       `json:"output_prefix,omitempty"`
+	Repositories    []string     `json:"repositories"`
+	StartedAt       string       `json:"started_at"`
+	FinishedAt      string       `json:"finished_at"`
+	DurationSeconds float64      `json:"duration_seconds"`
+	LastStage       string       `json:"last_stage,omitempty"`
+	Error           string       `json:"error,omitempty"`
+	Archive         string       `json:"archive,omitempty"`
+	Counts          ExportCounts `json:"counts"`
+
+	AmbiguousPullRequests []AmbiguousPullRequest `json:"ambiguous_pull_requests,omitempty"`
+	IntegrityViolations   []IntegrityViolation   `json:"integrity_violations,omitempty"`
+	StatisticsComparisons []StatisticsComparison `json:"statistics_comparisons,omitempty"`
+	PrunedRefs            []PrunedRef            `json:"pruned_refs,omitempty"`
+	PackInventories       []PackInventory        `json:"pack_inventories,omitempty"`
+	Flags                 map[string]interface{} `json:"flags,omitempty"`
+	FailedAPIResponses    []FailedAPIResponse    `json:"failed_api_responses,omitempty"`
+	Throttling            *ThrottlingStats       `json:"throttling,omitempty"`
+	PermissionNotes       []PermissionNote       `json:"permission_notes,omitempty"`
+	FreezeViolations      []FreezeViolation      `json:"freeze_violations,omitempty"`
+	MergeCommitMismatches []MergeCommitMismatch  `json:"merge_commit_mismatches,omitempty"`
+	ActivityFailures      []ActivityFailure      `json:"activity_failures,omitempty"`
+}
+
+// ActivityFailure is a pull request whose activity log could not be
+// fetched, so its approvals and change requests are missing from the
+// reviews.
+type ActivityFailure struct {
+	Repository  string `json:"repository"`
+	PullRequest string `json:"pull_request,omitempty"`
+	Error       string `json:"error"`
+}
+
+// MergeCommitMismatch is a merged pull request whose merge commit is missing
+// from the mirror or does not descend from the pull request's commits.
+type MergeCommitMismatch struct {
+	Repository     string `json:"repository"`
+	PullRequest    string `json:"pull_request"`
+	MergeCommitSHA string `json:"merge_commit_sha"`
+	Reason         string `json:"reason"`
+	Cleared        bool   `json:"cleared,omitempty"`
+}
+
+// FreezeViolation is a change made in Bitbucket while an export that
+// required a frozen repository was running. Before or After is empty for
+// refs that were created or deleted.
+type FreezeViolation struct {
+	Repository string `json:"repository"`
+	Kind       string `json:"kind"` // ref or pull_request
+	Name       string `json:"name"`
+	Before     string `json:"before,omitempty"`
+	After      string `json:"after,omitempty"`
+}
+
+// PermissionNote explains a Bitbucket permission that does not carry over
+// to GitHub repository collaborators as-is.
+type PermissionNote struct {
+	Repository string `json:"repository"`
+	Subject    string `json:"subject"`
+	Permission string `json:"permission,omitempty"`
+	Note       string `json:"note"`
+}
+
+// ThrottlingStats records how much of an export was spent waiting on the
+// Bitbucket API, with advice for tuning the next run.
+type ThrottlingStats struct {
+	Requests             int      `json:"requests"`
+	Retries              int      `json:"retries"`
+	RateLimitHits        int      `json:"rate_limit_hits"`
+	LowQuotaWarnings     int      `json:"low_quota_warnings"`
+	TokenRefreshes       int      `json:"token_refreshes,omitempty"`
+	RateLimitWaitSeconds float64  `json:"rate_limit_wait_seconds"`
+	NiceDelaySeconds     float64  `json:"nice_delay_seconds"`
+	TotalWaitSeconds     float64  `json:"total_wait_seconds"`
+	WaitShare            float64  `json:"wait_share"` // Fraction of the run spent waiting
+	Advice               []string `json:"advice,omitempty"`
+}
+
+type FailedAPIResponse struct {
+	Time   string `json:"time"`
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	Body   string `json:"body,omitempty"`
+}
+
+type PrunedRef struct {
+	Repository string `json:"repository"`
+	Ref        string `json:"ref"`
+}
+
+// PackInventory lists the packfiles of an exported repository; Repacked is
+// true when oversized packs were split.
+type PackInventory struct {
+	Repository string     `json:"repository"`
+	Repacked   bool       `json:"repacked"`
+	TotalBytes int64      `json:"total_bytes"`
+	Packs      []PackFile `json:"packs"`
+}
+
+type PackFile struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+// RepositoryStatistics holds repository totals; -1 means the value is unknown.
+type RepositoryStatistics struct {
+	SizeBytes    int64 `json:"size_bytes"`
+	Commits      int   `json:"commits"`
+	Branches     int   `json:"branches"`
+	PullRequests int   `json:"pull_requests"`
+}
+
+type StatisticsComparison struct {
+	Repository    string               `json:"repository"`
+	Bitbucket     RepositoryStatistics `json:"bitbucket"`
+	Archive       RepositoryStatistics `json:"archive"`
+	Discrepancies []string             `json:"discrepancies,omitempty"`
+	Notes         []string             `json:"notes,omitempty"`
+}
+
+type IntegrityViolation struct {
+	File      string `json:"file"`
+	Record    string `json:"record"`
+	Field     string `json:"field"`
+	Reference string `json:"reference"`
+}
+
+type AmbiguousPullRequest struct {
+	Repository        string `json:"repository"`
+	ID                int    `json:"id"`
+	Title             string `json:"title"`
+	SourceBranch      string `json:"source_branch"`
+	DestinationBranch string `json:"destination_branch"`
+	Action            string `json:"action"` // "skipped" or "renamed"
+}
+
+// ExportCheckpoint records how far an export got, so --resume can continue
+// an interrupted run instead of starting over.
+type ExportCheckpoint struct {
+	Workspace          string                            `json:"workspace"`
+	Repositories       []string                          `json:"repositories"`
+	CompletedStages    []string                          `json:"completed_stages"`
+	ClonedRepositories map[string]string                 `json:"cloned_repositories,omitempty"` // Slug -> clone start (RFC 3339)
+	PullRequests       map[string]CheckpointPullRequests `json:"pull_requests,omitempty"`       // Slug -> fetch progress
+	CommentedPRs       map[string][]int                  `json:"commented_pull_requests,omitempty"`
+	Contributors       map[string]string                 `json:"contributors,omitempty"` // Author UUID -> display name
+	UpdatedAt          string                            `json:"updated_at"`
+}
+
+// CheckpointPullRequests records the pull request pages of a repository
+// fetched before an export was interrupted.
+type CheckpointPullRequests struct {
+	PagesFetched int   `json:"pages_fetched"`
+	Complete     bool  `json:"complete"`
+	IDs          []int `json:"ids"`
+}
+
+// SyncState records an export directory kept up to date by the sync
+// command. The pull requests a cycle fetched are cached next to it, so the
+// next cycle only fetches the ones changed in Bitbucket since.
+type SyncState struct {
+	Workspace           string            `json:"workspace"`
+	Repositories        []string          `json:"repositories"`
+	Cycles              int               `json:"cycles"`
+	LastSyncedAt        string            `json:"last_synced_at"`
+	UpdatedRefs         int               `json:"updated_refs"`           // Branches and tags the last cycle fetched
+	ChangedPullRequests int               `json:"changed_pull_requests"`  // Pull requests the last cycle fetched again
+	Contributors        map[string]string `json:"contributors,omitempty"` // Author UUID -> display name
+}
+
+type ManifestRepository struct {
+	Workspace string `json:"workspace"`
+	Slug      string `json:"slug"`
+	UUID      string `json:"uuid"`
+	HeadSHA   string `json:"head_sha,omitempty"`
+}
+
+type ExportManifest struct {
+	ExporterVersion string                 `json:"exporter_version"`
+	ExporterCommit  string                 `json:"exporter_commit,omitempty"`
+	GoVersion       string                 `json:"go_version,omitempty"`
+	GitVersion      string                 `json:"git_version,omitempty"`
+	SchemaVersion   string                 `json:"schema_version"`
+	BitbucketAPIURL string                 `json:"bitbucket_api_url"`
+	CreatedAt       string                 `json:"created_at"`
+	Wave            string                 `json:"wave,omitempty"`
+	OutputPrefix    string                 `json:"output_prefix,omitempty"`
+	Flags           map[string]interface{} `json:"flags"`
+	Repositories    []ManifestRepository   `json:"repositories"`
+}
+
+type ExporterConfig struct {
+	API        APIConfig        `yaml:"api"`
+	Codeowners CodeownersConfig `yaml:"codeowners"`
+	URLs       URLConfig        `yaml:"urls"`
+}
+
+// URLConfig controls the source URLs generated for archive records, for
+// example to point them at an internal read-only Bitbucket mirror.
+type URLConfig struct {
+	// Base replaces https://bitbucket.org in every default URL.
+	Base string `yaml:"base"`
+	// Templates override single URL kinds (repository, user, organization,
+	// pr, issue_comment, pr_review, pr_review_comment, pr_review_thread)
+	// with placeholders {base}, {workspace}, {repository}, {id} and {sub_id}.
+	Templates map[string]string `yaml:"templates"`
+}
+
+// CodeownersConfig supplies what Bitbucket does not know when generating
+// CODEOWNERS files: GitHub handles for Bitbucket users and path-based
+// reviewer conventions.
+type CodeownersConfig struct {
+	// Users maps a Bitbucket nickname, account ID or UUID to a GitHub
+	// handle, team (@org/team) or email.
+	Users map[string]string `yaml:"users"`
+	Rules []CodeownersRule  `yaml:"rules"`
+}
+
+type CodeownersRule struct {
+	Repository string   `yaml:"repository"` // Repository slug glob; empty matches every repository
+	Path       string   `yaml:"path"`       // CODEOWNERS path pattern
+	Owners     []string `yaml:"owners"`
+}
+
+type APIConfig struct {
+	EndpointOverrides map[string]string `yaml:"endpoint_overrides"`
+	Transport         TransportConfig   `yaml:"transport"`
+	MaxResponseSizeMB int64             `yaml:"max_response_size_mb"`
+}
+
+type TransportConfig struct {
+	UnixSocket   string   `yaml:"unix_socket"`
+	ProxyCommand []string `yaml:"proxy_command"`
+}
+
+type ArchiveEncryption struct {
+	Method    string // "age" or "gpg"
+	Recipient string
+}
+
+type AnalyticsPullRequest struct {
+	URL            string  `json:"url"`
+	Number         string  `json:"number"`
+	Repository     string  `json:"repository"`
+	Title          string  `json:"title"`
+	Body           string  `json:"body"`
+	State          string  `json:"state"`
+	Author         string  `json:"author"`
+	BaseRef        string  `json:"base_ref"`
+	BaseSHA        string  `json:"base_sha"`
+	HeadRef        string  `json:"head_ref"`
+	HeadSHA        string  `json:"head_sha"`
+	MergeCommitSHA *string `json:"merge_commit_sha"`
+	CreatedAt      string  `json:"created_at"`
+	MergedAt       *string `json:"merged_at"`
+	ClosedAt       *string `json:"closed_at"`
+	Draft          bool    `json:"draft"`
+}
+
+type AnalyticsComment struct {
+	URL         string  `json:"url"`
+	Kind        string  `json:"kind"` // "issue_comment" or "pull_request_review_comment"
+	PullRequest string  `json:"pull_request"`
+	Author      string  `json:"author"`
+	Body        string  `json:"body"`
+	Path        string  `json:"path,omitempty"`
+	Line        int     `json:"line,omitempty"`
+	CommitSHA   string  `json:"commit_sha,omitempty"`
+	InReplyTo   *string `json:"in_reply_to,omitempty"`
+	CreatedAt   string  `json:"created_at"`
+	UpdatedAt   string  `json:"updated_at,omitempty"`
+}
+
+type AnalyticsUser struct {
+	URL       string `json:"url"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ExportJobRequest is the body of a job submitted to the serve API. Paths,
+// credentials and commands are fixed by the server and cannot be set per job.
+type ExportJobRequest struct {
+	Workspace          string `json:"workspace"`
+	Repository         string `json:"repository,omitempty"`
+	AllRepos           bool   `json:"all_repos,omitempty"`
+	GroupByProject     bool   `json:"group_by_project,omitempty"`
+	OpenPRsOnly        bool   `json:"open_prs_only,omitempty"`
+	PRsFromDate        string `json:"prs_from_date,omitempty"`
+	SkipCommitLookup   bool   `json:"skip_commit_lookup,omitempty"`
+	Wave               string `json:"wave,omitempty"`
+	OutputPrefix       string `json:"output_prefix,omitempty"`
+	ExportRulesets     bool   `json:"export_rulesets,omitempty"`
+	ExportWatchers     bool   `json:"export_watchers,omitempty"`
+	GenerateCodeowners bool   `json:"generate_codeowners,omitempty"`
+	UsersScope         string `json:"users_scope,omitempty"`
+	Consistency        string `json:"consistency,omitempty"`
+	TopUpFetch         bool   `json:"top_up_fetch,omitempty"`
+	SkipGit            bool   `json:"skip_git,omitempty"`
+	GitDepth           int    `json:"git_depth,omitempty"`
+	AsOf               string `json:"as_of,omitempty"`
+	MaxDuration        string `json:"max_duration,omitempty"` // Go duration, e.g. 2h
+}
+
+type ExportJob struct {
+	ID          string           `json:"id"`
+	Status      string           `json:"status"` // queued, running, completed, failed or interrupted
+	Request     ExportJobRequest `json:"request"`
+	Attempts    int              `json:"attempts"`
+	SubmittedAt string           `json:"submitted_at"`
+	StartedAt   string           `json:"started_at,omitempty"`
+	FinishedAt  string           `json:"finished_at,omitempty"`
+	OutputDir   string           `json:"output_dir"`
+	Archives    []string         `json:"archives,omitempty"`
+	Reports     []string         `json:"reports,omitempty"`
+	Error       string           `json:"error,omitempty"`
+}
+
+// RepositorySample is what the estimate command learns about a repository
+// from a few cheap API requests: its size, its pull request count, and the
+// comments of the most recent pull requests.
+type RepositorySample struct {
+	Repository          string `json:"repository"`
+	SizeBytes           int64  `json:"size_bytes"`
+	PullRequests        int    `json:"pull_requests"`
+	SampledPullRequests int    `json:"sampled_pull_requests"`
+	SampledComments     int    `json:"sampled_comments"`
+}
+
+type RepositoryEstimate struct {
+	Repository   string `json:"repository"`
+	SizeBytes    int64  `json:"size_bytes"`
+	PullRequests int    `json:"pull_requests"`
+	Comments     int    `json:"comments"`
+	APIRequests  int    `json:"api_requests"`
+	ArchiveBytes int64  `json:"archive_bytes"`
+}
+
+// ExportEstimate is the simulated cost of exporting a set of repositories.
+type ExportEstimate struct {
+	Workspace            string               `json:"workspace"`
+	Repositories         []RepositoryEstimate `json:"repositories"`
+	SamplingRequests     int                  `json:"sampling_requests"` // Requests the estimate itself made
+	APIRequests          int                  `json:"api_requests"`
+	RateLimitPerHour     int                  `json:"rate_limit_per_hour"`
+	QuotaShare           float64              `json:"quota_share"` // API requests per hourly quota
+	Concurrency          int                  `json:"concurrency"`
+	APIDurationSeconds   float64              `json:"api_duration_seconds"`
+	CloneDurationSeconds float64              `json:"clone_duration_seconds"`
+	DurationSeconds      float64              `json:"duration_seconds"`
+	ArchiveBytes         int64                `json:"archive_bytes"`
+}
+
+// ProgressEvent is a line of the --progress-format json stream. Progress
+// events describe one phase of the export; the last event of a run has
+// Event "completed" or "failed".
+type ProgressEvent struct {
+	Event          string   `json:"event"`
+	Phase          string   `json:"phase,omitempty"`
+	Completed      int      `json:"completed"`
+	Total          int      `json:"total"`
+	Percent        float64  `json:"percent"`
+	ETASeconds     *float64 `json:"eta_seconds,omitempty"`
+	ElapsedSeconds float64  `json:"elapsed_seconds"`
+	Time           string   `json:"time"`
+	Outputs        []string `json:"outputs,omitempty"`
+	Error          string   `json:"error,omitempty"`
+}
@@ -0,0 +1,29 @@
+package data
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColdStorageBundleJSON(t *testing.T) {
+	bundle := ColdStorageBundle{
+		Cutoff:          "2020-01-01",
+		IncludeDeclined: true,
+		PullRequests: []PullRequest{
+			{Type: "pull_request", URL: "https://bitbucket.org/ws/repo/pull/1"},
+		},
+		IssueComments:  []IssueComment{},
+		ReviewComments: []PullRequestReviewComment{},
+	}
+
+	jsonData, err := json.Marshal(bundle)
+	assert.NoError(t, err)
+	assert.Contains(t, string(jsonData), `"cutoff":"2020-01-01"`)
+
+	var unmarshaled ColdStorageBundle
+	err = json.Unmarshal(jsonData, &unmarshaled)
+	assert.NoError(t, err)
+	assert.Equal(t, bundle, unmarshaled)
+}
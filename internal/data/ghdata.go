@@ -94,7 +94,7 @@ type Repository struct {
 	HasDownloads           bool                   `json:"has_downloads"`
 	Labels                 []Label                `json:"labels"`
 	Webhooks               []interface{}          `json:"webhooks"`
-	Collaborators          []interface{}          `json:"collaborators"`
+	Collaborators          []Collaborator         `json:"collaborators"`
 	CreatedAt              string                 `json:"created_at"`
 	GitURL                 string                 `json:"git_url"`
 	DefaultBranch          string                 `json:"default_branch"`
@@ -110,6 +110,13 @@ type Repository struct {
 	IsArchived             bool                   `json:"is_archived"`
 }
 
+// Collaborator grants a user explicit access to a repository; Permission is
+// admin, write or read.
+type Collaborator struct {
+	User       string `json:"user"`
+	Permission string `json:"permission"`
+}
+
 type Label struct {
 	Type        string `json:"type,omitempty"`
 	URL         string `json:"url"`
@@ -120,26 +127,33 @@ type Label struct {
 }
 
 type PullRequest struct {
-	Type                 string   `json:"type"`
-	URL                  string   `json:"url"`
-	User                 string   `json:"user"`
-	Repository           string   `json:"repository"`
-	Title                string   `json:"title"`
-	Body                 string   `json:"body"`
-	Base                 PRBranch `json:"base"`
-	Head                 PRBranch `json:"head"`
-	Labels               []string `json:"labels"`
-	MergedAt             *string  `json:"merged_at"`
-	ClosedAt             *string  `json:"closed_at"`
-	CreatedAt            string   `json:"created_at"`
-	Assignee             *string  `json:"assignee"`
-	Assignees            []string `json:"assignees"`
-	Milestone            *string  `json:"milestone"`
-	Reactions            []string `json:"reactions"`
-	ReviewRequests       []string `json:"review_requests"`
-	CloseIssueReferences []string `json:"close_issue_references"`
-	WorkInProgress       bool     `json:"work_in_progress"`
-	MergeCommitSHA       *string  `json:"merge_commit_sha"`
+	Type                 string     `json:"type"`
+	URL                  string     `json:"url"`
+	User                 string     `json:"user"`
+	Repository           string     `json:"repository"`
+	Title                string     `json:"title"`
+	Body                 string     `json:"body"`
+	Base                 PRBranch   `json:"base"`
+	Head                 PRBranch   `json:"head"`
+	Labels               []string   `json:"labels"`
+	MergedAt             *string    `json:"merged_at"`
+	ClosedAt             *string    `json:"closed_at"`
+	CreatedAt            string     `json:"created_at"`
+	Assignee             *string    `json:"assignee"`
+	Assignees            []string   `json:"assignees"`
+	Milestone            *string    `json:"milestone"`
+	Reactions            []Reaction `json:"reactions"`
+	ReviewRequests       []string   `json:"review_requests"`
+	CloseIssueReferences []string   `json:"close_issue_references"`
+	WorkInProgress       bool       `json:"work_in_progress"`
+	MergeCommitSHA       *string    `json:"merge_commit_sha"`
+}
+
+// Reaction is a reaction of a user to a pull request.
+type Reaction struct {
+	User      string `json:"user"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
 }
 
 type PRBranch struct {
@@ -197,6 +211,22 @@ type PullRequestReviewComment struct {
 	UpdatedAt               string   `json:"updated_at"`
 }
 
+// Attachment is a file stored under attachments/ in the archive and
+// referenced by the body of the pull request or comment it belongs to. One
+// of PullRequest, IssueComment and PullRequestReviewComment is set.
+type Attachment struct {
+	Type                     string `json:"type"`
+	URL                      string `json:"url"`
+	User                     string `json:"user"`
+	AssetName                string `json:"asset_name"`
+	AssetContentType         string `json:"asset_content_type"`
+	AssetURL                 string `json:"asset_url"`
+	PullRequest              string `json:"pull_request,omitempty"`
+	IssueComment             string `json:"issue_comment,omitempty"`
+	PullRequestReviewComment string `json:"pull_request_review_comment,omitempty"`
+	CreatedAt                string `json:"created_at"`
+}
+
 type MigrationArchive struct {
 	RepositoryName string `json:"repository_name"`
 	Owner          string `json:"owner"`
@@ -216,3 +246,74 @@ type CommitData struct {
 	Date    string `json:"date"`
 	Message string `json:"message"`
 }
+
+type UploadState struct {
+	ArchiveName   string         `json:"archive_name"`
+	ArchiveSize   int64          `json:"archive_size"`
+	OrgID         int            `json:"org_id"`
+	PartSize      int64          `json:"part_size"`
+	GUID          string         `json:"guid"`
+	UploadID      string         `json:"upload_id"`
+	NextLocation  string         `json:"next_location"`
+	LastLocation  string         `json:"last_location"`
+	UploadedBytes int64          `json:"uploaded_bytes"`
+	PartsComplete bool           `json:"parts_complete"`
+	Parts         []UploadedPart `json:"parts"`
+}
+
+type UploadedPart struct {
+	Number int    `json:"number"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// GitHubRuleset is a request payload for the GitHub repository rulesets API.
+type GitHubRuleset struct {
+	Name        string                  `json:"name"`
+	Target      string                  `json:"target"`
+	Enforcement string                  `json:"enforcement"`
+	Conditions  GitHubRulesetConditions `json:"conditions"`
+	Rules       []GitHubRulesetRule     `json:"rules"`
+}
+
+type GitHubRulesetConditions struct {
+	RefName GitHubRulesetRefName `json:"ref_name"`
+}
+
+type GitHubRulesetRefName struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+type GitHubRulesetRule struct {
+	Type       string                       `json:"type"`
+	Parameters *GitHubPullRequestParameters `json:"parameters,omitempty"`
+}
+
+type GitHubPullRequestParameters struct {
+	DismissStaleReviewsOnPush      bool `json:"dismiss_stale_reviews_on_push"`
+	RequireCodeOwnerReview         bool `json:"require_code_owner_review"`
+	RequireLastPushApproval        bool `json:"require_last_push_approval"`
+	RequiredApprovingReviewCount   int  `json:"required_approving_review_count"`
+	RequiredReviewThreadResolution bool `json:"required_review_thread_resolution"`
+}
+
+type RulesetsExport struct {
+	Repositories []RepositoryRulesets `json:"repositories"`
+}
+
+type RepositoryRulesets struct {
+	SourceRepository string                     `json:"source_repository"`
+	Repository       string                     `json:"repository"`
+	Rulesets         []GitHubRuleset            `json:"rulesets"`
+	Skipped          []SkippedBranchRestriction `json:"skipped,omitempty"`
+	Notes            []string                   `json:"notes,omitempty"`
+}
+
+type SkippedBranchRestriction struct {
+	ID      int    `json:"id"`
+	Kind    string `json:"kind"`
+	Pattern string `json:"pattern,omitempty"`
+	Reason  string `json:"reason"`
+}
@@ -75,7 +75,7 @@ func TestPullRequestJSON(t *testing.T) {
 		Assignee:             nil,
 		Assignees:            []string{},
 		Milestone:            nil,
-		Reactions:            []string{},
+		Reactions:            []Reaction{},
 		ReviewRequests:       []string{},
 		CloseIssueReferences: []string{},
 		WorkInProgress:       false,
@@ -14,6 +14,50 @@ type CmdMigrateFlags struct {
 	TargetRepoVisibility RepoVisibility
 	TargetAPIURL         string
 	GitHubPAT            string
+	ArchivePath          string // Existing archive to upload instead of exporting
+}
+
+// RepairReport lists the records of an export that are missing from the
+// GitHub repository it was imported into. Records are identified by their
+// source URL.
+type RepairReport struct {
+	Repository     string   `json:"repository"`
+	Target         string   `json:"target"`
+	Format         string   `json:"format"`
+	PullRequests   []string `json:"pull_requests"`
+	IssueComments  []string `json:"issue_comments"`
+	ReviewComments []string `json:"pull_request_review_comments"`
+}
+
+// Missing returns the number of records in the report.
+func (r RepairReport) Missing() int {
+	return len(r.PullRequests) + len(r.IssueComments) + len(r.ReviewComments)
+}
+
+// UserVerificationReport predicts how the users of an export are attributed
+// on GitHub: users whose email belongs to exactly one GitHub account can be
+// attributed to it, every other user becomes a mannequin.
+type UserVerificationReport struct {
+	Export              string             `json:"export"`
+	Users               int                `json:"users"`
+	Matched             int                `json:"matched"`
+	Ambiguous           int                `json:"ambiguous"`
+	Unmatched           int                `json:"unmatched"`
+	WithoutEmail        int                `json:"without_email"`
+	PredictedMannequins int                `json:"predicted_mannequins"`
+	Searches            int                `json:"searches"`
+	RateLimitWaits      int                `json:"rate_limit_waits"`
+	Accounts            []UserVerification `json:"accounts"`
+}
+
+// UserVerification is the GitHub account match of one exported user.
+type UserVerification struct {
+	URL          string   `json:"url"`
+	Login        string   `json:"login"`
+	Name         string   `json:"name"`
+	Emails       []string `json:"emails"`
+	Status       string   `json:"status"`
+	GitHubLogins []string `json:"github_logins"`
 }
 
 type OrganizationIDQuery struct {
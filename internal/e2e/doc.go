@@ -0,0 +1,4 @@
+// Package e2e holds the opt-in end-to-end tests that seed a repository in a
+// sandbox Bitbucket workspace, export it with the real CLI and validate the
+// archive. They are built only with the e2e tag; run them with `make e2e`.
+package e2e
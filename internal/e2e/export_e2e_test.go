@@ -0,0 +1,118 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/cmd/export"
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// sandboxClient builds a client from BBC_E2E_WORKSPACE and the usual
+// BITBUCKET_* credential variables, skipping the test when they are missing.
+func sandboxClient(t *testing.T) (*utils.Client, string) {
+	t.Helper()
+	workspace := os.Getenv("BBC_E2E_WORKSPACE")
+	if workspace == "" {
+		t.Skip("BBC_E2E_WORKSPACE is not set; skipping end-to-end test")
+	}
+
+	flags := &data.CmdExportFlags{BitbucketAPIURL: "https://api.bitbucket.org/2.0"}
+	if apiURL := os.Getenv("BBC_E2E_API_URL"); apiURL != "" {
+		flags.BitbucketAPIURL = apiURL
+	}
+	utils.SetupEnvironmentCredentials(flags)
+	if err := utils.ValidateExportFlags(flags); err != nil {
+		t.Skipf("Bitbucket credentials are not configured: %v", err)
+	}
+
+	logger, err := zap.NewDevelopment()
+	require.NoError(t, err)
+	client := utils.NewClient(flags.BitbucketAPIURL, flags.BitbucketAccessToken, flags.BitbucketAPIToken,
+		flags.BitbucketEmail, flags.BitbucketUser, flags.BitbucketAppPass, logger, "", false)
+	return client, workspace
+}
+
+func readArchive(t *testing.T, archivePath string) map[string]string {
+	t.Helper()
+	file, err := os.Open(archivePath)
+	require.NoError(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+	gzipReader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	tarReader := tar.NewReader(gzipReader)
+
+	entries := make(map[string]string)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content := ""
+		if header.Typeflag == tar.TypeReg && strings.HasSuffix(header.Name, ".json") {
+			raw, err := io.ReadAll(tarReader)
+			require.NoError(t, err)
+			content = string(raw)
+		}
+		entries[header.Name] = content
+	}
+	return entries
+}
+
+func TestE2EExportSeededRepository(t *testing.T) {
+	client, workspace := sandboxClient(t)
+	slug := fmt.Sprintf("bbc-exporter-e2e-%d", time.Now().UnixNano())
+
+	t.Cleanup(func() {
+		if os.Getenv("BBC_E2E_KEEP") != "" {
+			t.Logf("Keeping sandbox repository %s/%s", workspace, slug)
+			return
+		}
+		if err := client.DeleteSandboxRepository(workspace, slug); err != nil {
+			t.Errorf("Failed to clean up sandbox repository %s/%s: %v", workspace, slug, err)
+		}
+	})
+
+	seeded, err := client.SeedSandboxRepository(workspace, slug)
+	require.NoError(t, err)
+
+	outputDir := filepath.Join(t.TempDir(), "export")
+	cmd := export.NewCmdExport()
+	cmd.SetArgs([]string{"--workspace", workspace, "--repo", slug, "--output", outputDir})
+	require.NoError(t, cmd.Execute())
+
+	entries := readArchive(t, outputDir+".tar.gz")
+	repoPrefix := fmt.Sprintf("repositories/%s/%s.git/", workspace, slug)
+	assert.Contains(t, entries, repoPrefix+"HEAD")
+	assert.Contains(t, entries, "schema.json")
+	assert.Contains(t, entries, "users_000001.json")
+	require.Contains(t, entries, "pull_requests_000001.json")
+	assert.Contains(t, entries["pull_requests_000001.json"], "End-to-end test pull request")
+	assert.Contains(t, entries["pull_requests_000001.json"], seeded.FeatureBranch)
+	require.Contains(t, entries, "issue_comments_000001.json")
+	assert.Contains(t, entries["issue_comments_000001.json"], seeded.CommentText)
+
+	var report data.ExportReport
+	reportContent, err := os.ReadFile(filepath.Join(outputDir, "export-report.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(reportContent, &report))
+	assert.Equal(t, "completed", report.Status)
+	assert.Equal(t, 1, report.Counts.PullRequests)
+}
@@ -0,0 +1,196 @@
+// Package i18n translates the messages the CLI shows to operators: the
+// final result of an export and the actionable validation errors. Logs stay
+// in English so they can be shared with support and searched.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	English  = "en"
+	Japanese = "ja"
+	German   = "de"
+
+	// EnvVar selects the language when --lang is not set.
+	EnvVar = "BBC_EXPORTER_LANG"
+)
+
+// Supported lists the languages with a full catalog, English first.
+var Supported = []string{English, Japanese, German}
+
+var current atomic.Value
+
+// Key identifies a translated message. The English text of each key is a
+// fmt format; translations take the same arguments in the same order.
+type Key string
+
+const (
+	WorkspaceRequired   Key = "workspace_required"
+	RepositoryRequired  Key = "repository_required"
+	RepoWithAllRepos    Key = "repo_with_all_repos"
+	AuthRequired        Key = "auth_required"
+	AuthMixed           Key = "auth_mixed"
+	EmailRequired       Key = "email_required"
+	APITokenRequired    Key = "api_token_required"
+	AppPasswordRequired Key = "app_password_required"
+	UsernameRequired    Key = "username_required"
+	OAuthSecretRequired Key = "oauth_secret_required"
+	OAuthKeyRequired    Key = "oauth_key_required"
+	InvalidDate         Key = "invalid_date"
+	ExportSuccessful    Key = "export_successful"
+	ArchiveCreated      Key = "archive_created"
+	ArchiveUsage        Key = "archive_usage"
+	OutputDirectory     Key = "output_directory"
+	UnsupportedLanguage Key = "unsupported_language"
+)
+
+var catalog = map[Key]map[string]string{
+	WorkspaceRequired: {
+		English:  "a bitbucket workspace must be specified",
+		Japanese: "Bitbucket ワークスペースを指定してください",
+		German:   "ein Bitbucket-Workspace muss angegeben werden",
+	},
+	RepositoryRequired: {
+		English:  "a bitbucket repository must be specified",
+		Japanese: "Bitbucket リポジトリを指定してください",
+		German:   "ein Bitbucket-Repository muss angegeben werden",
+	},
+	RepoWithAllRepos: {
+		English:  "--repo cannot be combined with --all-repos",
+		Japanese: "--repo と --all-repos は同時に指定できません",
+		German:   "--repo kann nicht mit --all-repos kombiniert werden",
+	},
+	AuthRequired: {
+		English:  "authentication credentials required: either provide a workspace access token with --access-token, an API token with email (--api-token and --email), both username (--user) and app password (--app-password), or an OAuth consumer key and secret (--oauth-key and --oauth-secret)",
+		Japanese: "認証情報が必要です: --access-token でワークスペースアクセストークンを指定するか、API トークンとメールアドレス (--api-token と --email)、ユーザー名 (--user) とアプリパスワード (--app-password) の両方、または OAuth コンシューマーのキーとシークレット (--oauth-key と --oauth-secret) を指定してください",
+		German:   "Anmeldedaten erforderlich: Geben Sie entweder ein Workspace-Zugriffstoken mit --access-token, ein API-Token mit E-Mail-Adresse (--api-token und --email) Benutzername (--user) und App-Passwort (--app-password) oder Schlüssel und Geheimnis eines OAuth-Consumers (--oauth-key und --oauth-secret) an",
+	},
+	AuthMixed: {
+		English:  "mixed authentication methods: provide either workspace token OR (API token + email) OR (username + app-password) OR (OAuth key + secret), not multiple types",
+		Japanese: "複数の認証方式が指定されています: ワークスペーストークン、(API トークン + メールアドレス)、(ユーザー名 + アプリパスワード)、(OAuth キー + シークレット) のいずれか 1 つだけを指定してください",
+		German:   "mehrere Anmeldeverfahren angegeben: Geben Sie entweder ein Workspace-Token ODER (API-Token + E-Mail-Adresse) ODER (Benutzername + App-Passwort) ODER (OAuth-Schlüssel + Geheimnis) an, nicht mehrere",
+	},
+	EmailRequired: {
+		English:  "email is required when using API token authentication. Please provide it with --email or BITBUCKET_EMAIL environment variable",
+		Japanese: "API トークン認証にはメールアドレスが必要です。--email または環境変数 BITBUCKET_EMAIL で指定してください",
+		German:   "für die Anmeldung mit API-Token ist eine E-Mail-Adresse erforderlich. Geben Sie sie mit --email oder der Umgebungsvariable BITBUCKET_EMAIL an",
+	},
+	APITokenRequired: {
+		English:  "API token is required when using email authentication. Please provide it with --api-token or BITBUCKET_API_TOKEN environment variable",
+		Japanese: "メールアドレスによる認証には API トークンが必要です。--api-token または環境変数 BITBUCKET_API_TOKEN で指定してください",
+		German:   "für die Anmeldung mit E-Mail-Adresse ist ein API-Token erforderlich. Geben Sie es mit --api-token oder der Umgebungsvariable BITBUCKET_API_TOKEN an",
+	},
+	AppPasswordRequired: {
+		English:  "app password is required when using username authentication. Please provide it with --app-password or BITBUCKET_APP_PASSWORD environment variable",
+		Japanese: "ユーザー名による認証にはアプリパスワードが必要です。--app-password または環境変数 BITBUCKET_APP_PASSWORD で指定してください",
+		German:   "für die Anmeldung mit Benutzername ist ein App-Passwort erforderlich. Geben Sie es mit --app-password oder der Umgebungsvariable BITBUCKET_APP_PASSWORD an",
+	},
+	UsernameRequired: {
+		English:  "username is required when using app password authentication. Please provide it with --user or BITBUCKET_USERNAME environment variable",
+		Japanese: "アプリパスワードによる認証にはユーザー名が必要です。--user または環境変数 BITBUCKET_USERNAME で指定してください",
+		German:   "für die Anmeldung mit App-Passwort ist ein Benutzername erforderlich. Geben Sie ihn mit --user oder der Umgebungsvariable BITBUCKET_USERNAME an",
+	},
+	OAuthSecretRequired: {
+		English:  "OAuth consumer secret is required when using an OAuth consumer key. Please provide it with --oauth-secret or BITBUCKET_OAUTH_SECRET environment variable",
+		Japanese: "OAuth コンシューマーキーによる認証にはシークレットが必要です。--oauth-secret または環境変数 BITBUCKET_OAUTH_SECRET で指定してください",
+		German:   "für die Anmeldung mit OAuth-Consumer-Schlüssel ist ein Geheimnis erforderlich. Geben Sie es mit --oauth-secret oder der Umgebungsvariable BITBUCKET_OAUTH_SECRET an",
+	},
+	OAuthKeyRequired: {
+		English:  "OAuth consumer key is required when using an OAuth consumer secret. Please provide it with --oauth-key or BITBUCKET_OAUTH_KEY environment variable",
+		Japanese: "OAuth コンシューマーシークレットによる認証にはキーが必要です。--oauth-key または環境変数 BITBUCKET_OAUTH_KEY で指定してください",
+		German:   "für die Anmeldung mit OAuth-Consumer-Geheimnis ist ein Schlüssel erforderlich. Geben Sie ihn mit --oauth-key oder der Umgebungsvariable BITBUCKET_OAUTH_KEY an",
+	},
+	InvalidDate: {
+		English:  "invalid date format for %s: %v (expected format: YYYY-MM-DD)",
+		Japanese: "%s の日付形式が正しくありません: %v (YYYY-MM-DD 形式で指定してください)",
+		German:   "ungültiges Datumsformat für %s: %v (erwartetes Format: JJJJ-MM-TT)",
+	},
+	ExportSuccessful: {
+		English:  "Export successful!",
+		Japanese: "エクスポートが完了しました。",
+		German:   "Export erfolgreich!",
+	},
+	ArchiveCreated: {
+		English:  "Archive created: %s",
+		Japanese: "作成されたアーカイブ: %s",
+		German:   "Archiv erstellt: %s",
+	},
+	ArchiveUsage: {
+		English:  "You can use this archive with GitHub's repository importer.",
+		Japanese: "このアーカイブは GitHub のリポジトリインポーターで使用できます。",
+		German:   "Dieses Archiv kann mit dem Repository-Importer von GitHub verwendet werden.",
+	},
+	OutputDirectory: {
+		English:  "Output directory: %s",
+		Japanese: "出力ディレクトリ: %s",
+		German:   "Ausgabeverzeichnis: %s",
+	},
+	UnsupportedLanguage: {
+		English:  "invalid value for --lang: %q (supported: %s)",
+		Japanese: "--lang の値が正しくありません: %q (対応言語: %s)",
+		German:   "ungültiger Wert für --lang: %q (unterstützt: %s)",
+	},
+}
+
+// SetLanguage selects the language of translated messages. Locale names
+// such as ja_JP.UTF-8 or de-DE select their language; an empty value selects
+// English.
+func SetLanguage(lang string) error {
+	normalized := normalize(lang)
+	if normalized == "" {
+		normalized = English
+	}
+	for _, supported := range Supported {
+		if normalized == supported {
+			current.Store(normalized)
+			return nil
+		}
+	}
+	return Errorf(UnsupportedLanguage, lang, strings.Join(Supported, ", "))
+}
+
+// Language returns the selected language.
+func Language() string {
+	if lang, ok := current.Load().(string); ok {
+		return lang
+	}
+	return English
+}
+
+// normalize reduces a locale name to its lower-case language code.
+func normalize(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+	return lang
+}
+
+// T returns the message for key in the selected language, formatted with
+// args. Messages missing from a translation fall back to English.
+func T(key Key, args ...interface{}) string {
+	if len(args) == 0 {
+		return message(key)
+	}
+	return fmt.Sprintf(message(key), args...)
+}
+
+// Errorf returns an error with the message for key. Like fmt.Errorf, a %w
+// verb in the message wraps its argument.
+func Errorf(key Key, args ...interface{}) error {
+	return fmt.Errorf(message(key), args...)
+}
+
+func message(key Key) string {
+	messages := catalog[key]
+	if format, ok := messages[Language()]; ok {
+		return format
+	}
+	if format, ok := messages[English]; ok {
+		return format
+	}
+	return string(key)
+}
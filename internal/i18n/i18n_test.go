@@ -0,0 +1,75 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetLanguage(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() { _ = SetLanguage(English) })
+}
+
+func TestSetLanguage(t *testing.T) {
+	resetLanguage(t)
+
+	tests := []struct {
+		lang     string
+		expected string
+	}{
+		{"", English},
+		{"en", English},
+		{"ja", Japanese},
+		{"ja_JP.UTF-8", Japanese},
+		{"DE-de", German},
+		{" de ", German},
+	}
+	for _, tt := range tests {
+		require.NoError(t, SetLanguage(tt.lang), tt.lang)
+		assert.Equal(t, tt.expected, Language(), tt.lang)
+	}
+}
+
+func TestSetLanguageUnsupported(t *testing.T) {
+	resetLanguage(t)
+	require.NoError(t, SetLanguage(German))
+
+	err := SetLanguage("fr")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"fr"`)
+	assert.Contains(t, err.Error(), "en, ja, de")
+	assert.Equal(t, German, Language(), "language unchanged")
+}
+
+func TestTranslate(t *testing.T) {
+	resetLanguage(t)
+
+	assert.Equal(t, "Archive created: out.tar.gz", T(ArchiveCreated, "out.tar.gz"))
+	require.NoError(t, SetLanguage(German))
+	assert.Equal(t, "Archiv erstellt: out.tar.gz", T(ArchiveCreated, "out.tar.gz"))
+	require.NoError(t, SetLanguage(Japanese))
+	assert.Equal(t, "エクスポートが完了しました。", T(ExportSuccessful))
+
+	assert.Equal(t, "missing_key", T(Key("missing_key")))
+}
+
+func TestErrorf(t *testing.T) {
+	resetLanguage(t)
+
+	err := Errorf(InvalidDate, "--prs-from-date", "2024-13-01")
+	assert.EqualError(t, err, "invalid date format for --prs-from-date: 2024-13-01 (expected format: YYYY-MM-DD)")
+
+	require.NoError(t, SetLanguage(Japanese))
+	err = Errorf(WorkspaceRequired)
+	assert.EqualError(t, err, "Bitbucket ワークスペースを指定してください")
+}
+
+func TestCatalogComplete(t *testing.T) {
+	for key, messages := range catalog {
+		for _, lang := range Supported {
+			assert.NotEmpty(t, messages[lang], "%s has no %s message", key, lang)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package log
 
 import (
+	"os"
+
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/term"
 )
 
 func NewLogger(debug bool) (*zap.Logger, error) {
@@ -15,7 +19,7 @@ func NewLogger(debug bool) (*zap.Logger, error) {
 	loggerConfig := zap.Config{
 		Level:             zap.NewAtomicLevelAt(level),
 		Encoding:          "console",
-		EncoderConfig:     zap.NewDevelopmentEncoderConfig(),
+		EncoderConfig:     encoderConfig(term.IsTerminal(int(os.Stderr.Fd()))),
 		DisableStacktrace: true,
 		OutputPaths:       []string{"stderr"},
 		ErrorOutputPaths:  []string{"stderr"},
@@ -23,3 +27,13 @@ func NewLogger(debug bool) (*zap.Logger, error) {
 
 	return loggerConfig.Build()
 }
+
+// encoderConfig colours log levels only on a terminal, so logs collected
+// from CI runners and containers stay free of escape sequences.
+func encoderConfig(interactive bool) zapcore.EncoderConfig {
+	config := zap.NewDevelopmentEncoderConfig()
+	if interactive {
+		config.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+	return config
+}
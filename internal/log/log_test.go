@@ -82,3 +82,15 @@ func TestLogOutputs(t *testing.T) {
 	assert.Contains(t, buf.String(), "count")
 	assert.Contains(t, buf.String(), "42")
 }
+
+func TestEncoderConfigColoursOnlyTerminals(t *testing.T) {
+	var buf bytes.Buffer
+	core := zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig(false)), zapcore.AddSync(&buf), zap.InfoLevel)
+	zap.New(core).Info("plain")
+	assert.NotContains(t, buf.String(), "\x1b[")
+
+	buf.Reset()
+	core = zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig(true)), zapcore.AddSync(&buf), zap.InfoLevel)
+	zap.New(core).Info("coloured")
+	assert.Contains(t, buf.String(), "\x1b[")
+}
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// declineReviewBody is the body of the review recording that a pull request
+// was declined, as GitHub reviews have no declined state.
+const declineReviewBody = "Declined this pull request in Bitbucket."
+
+// GetPullRequestReviews builds pull request reviews from the approvals,
+// change requests and declines in the activity log of each pull request.
+// Reviews synthesized from inline comments cannot carry these, so without
+// them approvals without comments would be lost. Entries by deleted accounts
+// are skipped.
+func (c *Client) GetPullRequestReviews(workspace, repoSlug string, pullRequests []data.PullRequest) ([]map[string]interface{}, error) {
+	c.logger.Info("Fetching pull request activity", zap.String("repository", repoSlug))
+
+	var reviews []map[string]interface{}
+	var mu sync.Mutex
+	failedPRs := 0
+	var abortErr error
+	c.forEach(len(pullRequests), func(i int) {
+		pr := pullRequests[i]
+		prReviews, cached := c.syncCache.reviews(repoSlug, pr)
+		var err error
+		if !cached {
+			prReviews, err = c.fetchPullRequestActivity(workspace, repoSlug, pr)
+			if err == nil {
+				c.syncCache.recordReviews(repoSlug, pr, prReviews)
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		reviews = append(reviews, prReviews...)
+		if err != nil {
+			if exportAborted(err) {
+				abortErr = err
+			}
+			c.logger.Warn("Failed to fetch PR activity",
+				zap.String("pull_request", pr.URL),
+				zap.Error(err))
+			c.activityFailures = append(c.activityFailures, data.ActivityFailure{
+				Repository:  workspace + "/" + repoSlug,
+				PullRequest: pr.URL,
+				Error:       SanitizeSupportText(err.Error()),
+			})
+			failedPRs++
+		}
+	})
+	if abortErr != nil {
+		return nil, abortErr
+	}
+
+	// Workers finish in any order; keep the output stable.
+	sort.SliceStable(reviews, func(i, j int) bool {
+		return reviews[i]["url"].(string) < reviews[j]["url"].(string)
+	})
+	c.logger.Info("Pull request activity fetched",
+		zap.String("repository", repoSlug),
+		zap.Int("reviews", len(reviews)),
+		zap.Int("failed_prs", failedPRs))
+	return reviews, nil
+}
+
+// takeActivityFailures returns the pull requests whose activity could not be
+// fetched since the last call.
+func (c *Client) takeActivityFailures() []data.ActivityFailure {
+	failures := c.activityFailures
+	c.activityFailures = nil
+	sort.SliceStable(failures, func(i, j int) bool {
+		return failures[i].PullRequest < failures[j].PullRequest
+	})
+	return failures
+}
+
+// fetchPullRequestActivity returns the reviews in the activity log of one
+// pull request. The reviews of the pages fetched before an error are
+// returned with it.
+func (c *Client) fetchPullRequestActivity(workspace, repoSlug string, pr data.PullRequest) ([]map[string]interface{}, error) {
+	prNumber := extractPRNumber(c.urls, pr.URL)
+	var reviews []map[string]interface{}
+	endpoint := fmt.Sprintf("repositories/%s/%s/pullrequests/%s/activity?pagelen=%d",
+		workspace, repoSlug, prNumber, c.pageLen(50))
+	for endpoint != "" {
+		var response data.BitbucketActivityResponse
+		if err := c.makeRequest("GET", endpoint, &response); err != nil {
+			return reviews, err
+		}
+		for _, activity := range response.Values {
+			if review := c.activityReview(workspace, repoSlug, prNumber, pr, activity); review != nil {
+				reviews = append(reviews, review)
+			}
+		}
+		endpoint = response.Next
+	}
+	return reviews, nil
+}
+
+// activityReview converts an approval, change request or decline into a
+// review, or returns nil for other activity.
+func (c *Client) activityReview(workspace, repoSlug, prNumber string, pr data.PullRequest,
+	activity data.BitbucketActivity) map[string]interface{} {
+	var kind, date string
+	var user data.BitbucketPRUser
+	var state int
+	var body interface{}
+	switch {
+	case activity.Approval != nil:
+		kind, date, user, state = "approval", activity.Approval.Date, activity.Approval.User, reviewStateApproved
+	case activity.ChangesRequested != nil:
+		kind, date, user = "changes-requested", activity.ChangesRequested.Date, activity.ChangesRequested.User
+		state = reviewStateChangesRequested
+	case activity.Update != nil && activity.Update.State == "DECLINED":
+		kind, date, user, state = "decline", activity.Update.Date, activity.Update.Author, reviewStateCommented
+		body = declineReviewBody
+	default:
+		return nil
+	}
+	if strings.Trim(user.UUID, "{}") == "" {
+		c.logger.Debug("Skipping pull request activity by a deleted account",
+			zap.String("pull_request", pr.URL),
+			zap.String("activity", kind))
+		return nil
+	}
+
+	reviewID := fmt.Sprintf("%s-%s", kind, HashString(user.UUID+date))
+	submittedAt := formatDateToZ(date)
+	return map[string]interface{}{
+		"type":         "pull_request_review",
+		"url":          c.formatURL("pr_review", workspace, repoSlug, prNumber, reviewID),
+		"pull_request": pr.URL,
+		"user":         c.authorURL(workspace, user),
+		"body":         body,
+		"head_sha":     pr.Head.SHA,
+		"formatter":    "markdown",
+		"state":        state,
+		"reactions":    []interface{}{},
+		"created_at":   submittedAt,
+		"submitted_at": submittedAt,
+	}
+}
+
+// filterReviewsAsOf drops reviews submitted after the --as-of snapshot.
+func (e *Exporter) filterReviewsAsOf(reviews []map[string]interface{}) []map[string]interface{} {
+	if e.asOf.IsZero() {
+		return reviews
+	}
+	kept := make([]map[string]interface{}, 0, len(reviews))
+	for _, review := range reviews {
+		if submittedAt, _ := review["submitted_at"].(string); e.afterAsOf(submittedAt) {
+			continue
+		}
+		kept = append(kept, review)
+	}
+	return kept
+}
+
+// filterReviewsOfPullRequests keeps the reviews of the given pull requests.
+func filterReviewsOfPullRequests(reviews []map[string]interface{}, prs []data.PullRequest) []map[string]interface{} {
+	urls := make(map[string]bool, len(prs))
+	for _, pr := range prs {
+		urls[pr.URL] = true
+	}
+	kept := make([]map[string]interface{}, 0, len(reviews))
+	for _, review := range reviews {
+		if url, _ := review["pull_request"].(string); urls[url] {
+			kept = append(kept, review)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGetPullRequestReviews(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repositories/workspace/repo/pullrequests/1/activity" && r.URL.Query().Get("ctx") == "":
+			writeResponse(t, w, []byte(`{"values": [
+				{"approval": {"date": "2024-03-02T10:00:00.000000+00:00", "user": {"uuid": "{alice}", "display_name": "Alice"}}},
+				{"comment": {"id": 5}},
+				{"update": {"state": "OPEN", "date": "2024-03-01T09:00:00+00:00", "author": {"uuid": "{bob}"}}}],
+				"next": "`+server.URL+`/repositories/workspace/repo/pullrequests/1/activity?ctx=abc"}`))
+		case r.URL.Path == "/repositories/workspace/repo/pullrequests/1/activity":
+			writeResponse(t, w, []byte(`{"values": [
+				{"changes_requested": {"date": "2024-03-01T12:00:00+00:00", "user": {"uuid": "{carol}", "display_name": "Carol"}}},
+				{"approval": {"date": "2024-03-01T11:00:00+00:00", "user": {"display_name": "Former user"}}}]}`))
+		case r.URL.Path == "/repositories/workspace/repo/pullrequests/2/activity":
+			writeResponse(t, w, []byte(`{"values": [
+				{"update": {"state": "DECLINED", "date": "2024-04-01T08:00:00+00:00", "author": {"uuid": "{bob}", "display_name": "Bob"}}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop(), concurrency: 2}
+	prs := []data.PullRequest{
+		{URL: "https://bitbucket.org/workspace/repo/pull/1", Head: data.PRBranch{SHA: "head1"}},
+		{URL: "https://bitbucket.org/workspace/repo/pull/2", Head: data.PRBranch{SHA: "head2"}},
+	}
+
+	reviews, err := client.GetPullRequestReviews("workspace", "repo", prs)
+
+	require.NoError(t, err)
+	require.Len(t, reviews, 3, "other activity and activity by deleted accounts is skipped")
+	byState := make(map[int]map[string]interface{})
+	for _, review := range reviews {
+		byState[review["state"].(int)] = review
+	}
+
+	approval := byState[reviewStateApproved]
+	require.NotNil(t, approval)
+	assert.Equal(t, "pull_request_review", approval["type"])
+	assert.Equal(t, prs[0].URL, approval["pull_request"])
+	assert.Equal(t, "https://bitbucket.org/alice", approval["user"])
+	assert.Equal(t, "head1", approval["head_sha"])
+	assert.Nil(t, approval["body"])
+	assert.Equal(t, "2024-03-02T10:00:00Z", approval["submitted_at"])
+	assert.Contains(t, approval["url"], "pull/1/files#pullrequestreview-approval-")
+
+	changes := byState[reviewStateChangesRequested]
+	require.NotNil(t, changes)
+	assert.Equal(t, "https://bitbucket.org/carol", changes["user"])
+
+	decline := byState[reviewStateCommented]
+	require.NotNil(t, decline)
+	assert.Equal(t, prs[1].URL, decline["pull_request"])
+	assert.Equal(t, "https://bitbucket.org/bob", decline["user"])
+	assert.Equal(t, declineReviewBody, decline["body"])
+
+	assert.Equal(t, "Alice", client.contributors["alice"], "reviewers are recorded as contributors")
+}
+
+func TestFilterReviews(t *testing.T) {
+	reviews := []map[string]interface{}{
+		{"pull_request": "pr-1", "submitted_at": "2024-01-01T00:00:00Z"},
+		{"pull_request": "pr-2", "submitted_at": "2024-06-01T00:00:00Z"},
+	}
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+
+	assert.Len(t, exporter.filterReviewsAsOf(reviews), 2)
+	exporter.asOf = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, reviews[:1], exporter.filterReviewsAsOf(reviews))
+
+	assert.Equal(t, reviews[1:], filterReviewsOfPullRequests(reviews, []data.PullRequest{{URL: "pr-2"}}))
+}
+
+func TestContributorUsersIncludesReviewers(t *testing.T) {
+	client := &Client{logger: zap.NewNop()}
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	exporter.activityReviews = []map[string]interface{}{{"user": format.URL("user", "ws", "", "dave")}}
+
+	users := exporter.contributorUsers(nil, nil, nil)
+
+	require.Len(t, users, 1)
+	assert.Equal(t, "dave", users[0].Login)
+}
+
+func TestExportContinuesWithoutActivity(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repositories/workspace/repo":
+			writeResponse(t, w, []byte(`{"slug": "repo", "name": "repo", "scm": "git", "mainbranch": {"name": "main"}}`))
+		case "/repositories/workspace/repo/pullrequests":
+			writeResponse(t, w, []byte(`{"values": [
+				{"id": 1, "title": "Login", "state": "OPEN", "author": {"uuid": "{bob}"},
+					"source": {"branch": {"name": "login"}, "commit": {"hash": "1234567890123456789012345678901234567890"}},
+					"destination": {"branch": {"name": "main"}, "commit": {"hash": "0987654321098765432109876543210987654321"}}},
+				{"id": 2, "title": "Search", "state": "OPEN", "author": {"uuid": "{bob}"},
+					"source": {"branch": {"name": "search"}, "commit": {"hash": "1234567890123456789012345678901234567890"}},
+					"destination": {"branch": {"name": "main"}, "commit": {"hash": "0987654321098765432109876543210987654321"}}}]}`))
+		case "/repositories/workspace/repo/pullrequests/1/activity":
+			writeResponse(t, w, []byte(`{"values": [
+				{"approval": {"date": "2024-03-02T10:00:00+00:00", "user": {"uuid": "{alice}", "display_name": "Alice"}}}]}`))
+		case "/repositories/workspace/repo/pullrequests/2/activity":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			writeResponse(t, w, []byte(`{"values": []}`))
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop(),
+		commitSHACache: make(map[string]string), skipCommitLookup: true}
+	outputDir := t.TempDir()
+	exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+	require.NoError(t, exporter.ApplyExportFlags(&data.CmdExportFlags{SkipGit: true}))
+
+	require.NoError(t, exporter.ExportRepositories("workspace", []string{"repo"}))
+
+	var report data.ExportReport
+	readReportFile(t, filepath.Join(outputDir, exportReportFile), &report)
+	assert.Equal(t, reportStatusCompleted, report.Status)
+	require.Len(t, report.ActivityFailures, 1)
+	assert.Equal(t, "workspace/repo", report.ActivityFailures[0].Repository)
+	assert.Equal(t, "https://bitbucket.org/workspace/repo/pull/2", report.ActivityFailures[0].PullRequest)
+	assert.NotEmpty(t, report.ActivityFailures[0].Error)
+	assert.Equal(t, 1, report.Counts.ActivityReviews, "the activity of the other pull request is exported")
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	ambiguousRefPrefix = "bb-"
+
+	ambiguousActionSkipped = "skipped"
+	ambiguousActionRenamed = "renamed"
+)
+
+// SetKeepAmbiguousPRs keeps pull requests whose branch names look like commit
+// SHAs by prefixing the branch refs instead of dropping the pull request.
+func (c *Client) SetKeepAmbiguousPRs(keep bool) {
+	c.keepAmbiguousPRs = keep
+}
+
+func prefixAmbiguousRef(name string) string {
+	if hexPatternRegex.MatchString(name) {
+		return ambiguousRefPrefix + name
+	}
+	return name
+}
+
+// recordAmbiguousPR notes a pull request with an ambiguous branch name for the
+// export report. It reports whether the pull request should still be exported,
+// in which case its branch refs have been rewritten.
+func (c *Client) recordAmbiguousPR(repoSlug string, pr *data.BitbucketPR) bool {
+	entry := data.AmbiguousPullRequest{
+		Repository:        repoSlug,
+		ID:                pr.ID,
+		Title:             pr.Title,
+		SourceBranch:      pr.Source.Branch.Name,
+		DestinationBranch: pr.Destination.Branch.Name,
+		Action:            ambiguousActionSkipped,
+	}
+
+	if c.keepAmbiguousPRs {
+		entry.Action = ambiguousActionRenamed
+		pr.Source.Branch.Name = prefixAmbiguousRef(pr.Source.Branch.Name)
+		pr.Destination.Branch.Name = prefixAmbiguousRef(pr.Destination.Branch.Name)
+		c.logger.Warn("Renaming ambiguous branch refs of pull request",
+			zap.String("repository", repoSlug),
+			zap.Int("pr_id", pr.ID),
+			zap.String("source_branch", pr.Source.Branch.Name),
+			zap.String("destination_branch", pr.Destination.Branch.Name))
+	} else {
+		c.logger.Warn("Skipping pull request with a branch name that looks like a commit SHA",
+			zap.String("repository", repoSlug),
+			zap.Int("pr_id", pr.ID),
+			zap.String("title", pr.Title),
+			zap.String("source_branch", entry.SourceBranch),
+			zap.String("destination_branch", entry.DestinationBranch))
+	}
+
+	c.ambiguousPRs = append(c.ambiguousPRs, entry)
+	return c.keepAmbiguousPRs
+}
+
+// takeAmbiguousPRs returns the pull requests recorded since the last call.
+func (c *Client) takeAmbiguousPRs() []data.AmbiguousPullRequest {
+	prs := c.ambiguousPRs
+	c.ambiguousPRs = nil
+	return prs
+}
+
+// renameAmbiguousBranches applies the same prefix used for pull request refs
+// to branches in the cloned repository, so the renamed refs still resolve.
+func (e *Exporter) renameAmbiguousBranches(repoPath string) error {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname:short)", "refs/heads/")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	for _, branch := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		branch = strings.TrimPrefix(strings.TrimSpace(branch), "heads/")
+		if !hexPatternRegex.MatchString(branch) {
+			continue
+		}
+		renamed := prefixAmbiguousRef(branch)
+		renameCmd := exec.Command("git", "branch", "-m", branch, renamed)
+		renameCmd.Dir = repoPath
+		if out, err := renameCmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to rename ambiguous branch %s: %s: %w", branch, string(out), err)
+		}
+		e.logger.Warn("Renamed ambiguous branch",
+			zap.String("branch", branch),
+			zap.String("renamed_to", renamed))
+	}
+	return nil
+}
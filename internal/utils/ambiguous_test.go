@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const ambiguousPRResponse = `{"values": [
+	{"id": 1, "title": "Normal PR", "state": "OPEN",
+	 "source": {"branch": {"name": "feature"}}, "destination": {"branch": {"name": "main"}}},
+	{"id": 2, "title": "SHA-like PR", "state": "OPEN",
+	 "source": {"branch": {"name": "0123456789abcdef0123456789abcdef01234567"}}, "destination": {"branch": {"name": "main"}}}
+], "next": null}`
+
+func newAmbiguousTestClient(t *testing.T) *Client {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		writeResponse(t, w, []byte(ambiguousPRResponse))
+	}))
+	t.Cleanup(testServer.Close)
+
+	return &Client{
+		baseURL:          testServer.URL,
+		httpClient:       testServer.Client(),
+		logger:           zap.NewNop(),
+		commitSHACache:   make(map[string]string),
+		skipCommitLookup: true,
+	}
+}
+
+func TestGetPullRequestsRecordsAmbiguousPRs(t *testing.T) {
+	client := newAmbiguousTestClient(t)
+
+	prs, err := client.GetPullRequests("workspace", "repo", false, "")
+	require.NoError(t, err)
+	require.Len(t, prs, 1)
+	assert.Equal(t, "Normal PR", prs[0].Title)
+
+	recorded := client.takeAmbiguousPRs()
+	require.Len(t, recorded, 1)
+	assert.Equal(t, "repo", recorded[0].Repository)
+	assert.Equal(t, 2, recorded[0].ID)
+	assert.Equal(t, "SHA-like PR", recorded[0].Title)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", recorded[0].SourceBranch)
+	assert.Equal(t, "main", recorded[0].DestinationBranch)
+	assert.Equal(t, ambiguousActionSkipped, recorded[0].Action)
+
+	assert.Empty(t, client.takeAmbiguousPRs(), "records should be drained")
+}
+
+func TestGetPullRequestsKeepsAmbiguousPRs(t *testing.T) {
+	client := newAmbiguousTestClient(t)
+	client.SetKeepAmbiguousPRs(true)
+
+	prs, err := client.GetPullRequests("workspace", "repo", false, "")
+	require.NoError(t, err)
+	require.Len(t, prs, 2)
+	assert.Equal(t, "bb-0123456789abcdef0123456789abcdef01234567", prs[1].Head.Ref)
+	assert.Equal(t, "main", prs[1].Base.Ref)
+
+	recorded := client.takeAmbiguousPRs()
+	require.Len(t, recorded, 1)
+	assert.Equal(t, ambiguousActionRenamed, recorded[0].Action)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef01234567", recorded[0].SourceBranch)
+}
+
+func TestPrefixAmbiguousRef(t *testing.T) {
+	assert.Equal(t, "feature", prefixAmbiguousRef("feature"))
+	assert.Equal(t, "bb-deadbeefdeadbeefdeadbeefdeadbeefdeadbeef",
+		prefixAmbiguousRef("deadbeefdeadbeefdeadbeefdeadbeefdeadbeef"))
+}
+
+func TestRenameAmbiguousBranches(t *testing.T) {
+	repoDir := t.TempDir()
+	if err := exec.Command("git", "init", "-b", "main", repoDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	runGit(t, repoDir, "commit", "--allow-empty", "-m", "initial")
+	sha := "0123456789abcdef0123456789abcdef01234567"
+	runGit(t, repoDir, "branch", sha)
+
+	exporter := &Exporter{logger: zap.NewNop()}
+	require.NoError(t, exporter.renameAmbiguousBranches(repoDir))
+
+	branches := runGit(t, repoDir, "for-each-ref", "--format=%(refname:short)", "refs/heads/")
+	assert.Contains(t, branches, "bb-"+sha)
+	assert.Contains(t, branches, "main")
+	assert.NotContains(t, strings.Split(branches, "\n"), sha)
+	assert.NoError(t, exporter.validateGitReferences(repoDir))
+}
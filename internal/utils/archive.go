@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// archiveSplitDepth is how deep the export directory is divided into
+	// independently built tar segments; at 3 every repositories/<ws>/<repo>.git
+	// is its own segment.
+	archiveSplitDepth = 3
+	maxArchiveWorkers = 8
+)
+
+func defaultArchiveWorkers() int {
+	return min(runtime.NumCPU(), maxArchiveWorkers)
+}
+
+// archiveUnit is one segment of the archive: either a single entry or, with
+// subtree set, a directory and everything below it.
+type archiveUnit struct {
+	path    string
+	relPath string
+	info    os.FileInfo
+	subtree bool
+}
+
+// skipArchiveEntry reports whether an entry of the export directory is left
+// out of the archive: leftover temporary files and sidecar files.
+func (e *Exporter) skipArchiveEntry(relPath string, info os.FileInfo) bool {
+	if !info.IsDir() && isAtomicTempFile(info.Name()) {
+		e.logger.Warn("Skipping leftover temporary file", zap.String("path", relPath))
+		return true
+	}
+	unixPath := ToUnixPath(relPath)
+	return sidecarPaths[unixPath] || isExportReportFile(unixPath)
+}
+
+// planArchiveUnits lists the archive segments of sourceDir in the order a
+// serial filepath.Walk would visit them, so that concatenating the segments
+// reproduces the serial archive byte for byte.
+func (e *Exporter) planArchiveUnits(sourceDir string) ([]archiveUnit, error) {
+	var units []archiveUnit
+	var expand func(dir, relDir string, depth int) error
+	expand = func(dir, relDir string, depth int) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			relPath := filepath.Join(relDir, entry.Name())
+			info, err := os.Lstat(path)
+			if err != nil {
+				return err
+			}
+			if e.skipArchiveEntry(relPath, info) {
+				continue
+			}
+			if info.IsDir() && depth < archiveSplitDepth {
+				units = append(units, archiveUnit{path: path, relPath: relPath, info: info})
+				if err := expand(path, relPath, depth+1); err != nil {
+					return err
+				}
+				continue
+			}
+			units = append(units, archiveUnit{path: path, relPath: relPath, info: info, subtree: info.IsDir()})
+		}
+		return nil
+	}
+
+	if err := expand(sourceDir, "", 1); err != nil {
+		return nil, err
+	}
+	return units, nil
+}
+
+func (e *Exporter) writeArchiveUnit(sourceDir string, unit archiveUnit, tarWriter *tar.Writer) error {
+	if !unit.subtree {
+		return e.addFileToArchive(tarWriter, unit.path, unit.relPath, unit.info)
+	}
+	return filepath.Walk(unit.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		if e.skipArchiveEntry(relPath, info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return e.addFileToArchive(tarWriter, path, relPath, info)
+	})
+}
+
+// archiveDirectory writes the tar entries of sourceDir to w, without the
+// end-of-archive marker. Independent sub-trees are built in parallel into
+// temporary segment files and appended to w in walk order.
+func (e *Exporter) archiveDirectory(sourceDir string, w io.Writer) error {
+	units, err := e.planArchiveUnits(sourceDir)
+	if err != nil {
+		return err
+	}
+
+	if e.archiveWorkers <= 1 || len(units) <= 1 {
+		tarWriter := tar.NewWriter(w)
+		for _, unit := range units {
+			if err := e.writeArchiveUnit(sourceDir, unit, tarWriter); err != nil {
+				return err
+			}
+		}
+		return tarWriter.Flush()
+	}
+
+	segmentDir, err := os.MkdirTemp(filepath.Dir(sourceDir), ".bbc-archive-segments-")
+	if err != nil {
+		return fmt.Errorf("failed to create archive segment directory: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(segmentDir); err != nil {
+			e.logger.Warn("Failed to remove archive segment directory",
+				zap.String("path", segmentDir), zap.Error(err))
+		}
+	}()
+
+	e.logger.Debug("Building archive segments in parallel",
+		zap.Int("segments", len(units)),
+		zap.Int("workers", e.archiveWorkers))
+
+	// The consumer hands out the next unit each time it appends a segment,
+	// so at most archiveWorkers segments wait on disk at any time.
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	jobs := make(chan int, e.archiveWorkers)
+	defer close(jobs)
+	results := make([]chan error, len(units))
+	for i := range results {
+		results[i] = make(chan error, 1)
+	}
+	segmentPath := func(i int) string {
+		return filepath.Join(segmentDir, fmt.Sprintf("segment-%06d.tar", i))
+	}
+
+	for worker := 0; worker < e.archiveWorkers; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if failed.Load() {
+					results[i] <- fmt.Errorf("archive build cancelled")
+					continue
+				}
+				results[i] <- e.buildArchiveSegment(sourceDir, units[i], segmentPath(i))
+			}
+		}()
+	}
+
+	next := 0
+	dispatch := func() {
+		if next < len(units) {
+			jobs <- next
+			next++
+		}
+	}
+	for next < e.archiveWorkers && next < len(units) {
+		dispatch()
+	}
+
+	for i := range units {
+		if err := <-results[i]; err != nil {
+			failed.Store(true)
+			return err
+		}
+		if err := appendSegment(w, segmentPath(i)); err != nil {
+			failed.Store(true)
+			return err
+		}
+		dispatch()
+	}
+	return nil
+}
+
+func (e *Exporter) buildArchiveSegment(sourceDir string, unit archiveUnit, path string) error {
+	segment, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive segment: %w", err)
+	}
+	tarWriter := tar.NewWriter(segment)
+	if err := e.writeArchiveUnit(sourceDir, unit, tarWriter); err != nil {
+		_ = segment.Close()
+		return err
+	}
+	if err := tarWriter.Flush(); err != nil {
+		_ = segment.Close()
+		return fmt.Errorf("failed to write archive segment: %w", err)
+	}
+	if err := segment.Close(); err != nil {
+		return fmt.Errorf("failed to write archive segment: %w", err)
+	}
+	return nil
+}
+
+// appendSegment copies a finished segment to the archive and removes it, so
+// segments only use disk space until their turn comes.
+func appendSegment(w io.Writer, path string) error {
+	segment, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive segment: %w", err)
+	}
+	_, copyErr := io.Copy(w, segment)
+	_ = segment.Close()
+	if copyErr != nil {
+		return fmt.Errorf("failed to append archive segment: %w", copyErr)
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// createArchiveFixture builds an export directory with nested repositories,
+// top-level JSON files, a sidecar file and a leftover temporary file.
+func createArchiveFixture(t *testing.T) string {
+	t.Helper()
+	outputDir := filepath.Join(t.TempDir(), "export")
+	files := map[string]string{
+		"schema.json":                          `{"version":"1.0.1"}`,
+		"users_000001.json":                    `[{"login":"user"}]`,
+		"pull_requests_000001.json":            `[{"number":1}]`,
+		exportReportFile:                       `{"status":"completed"}`,
+		".users_000001.json.tmp-123":           `[{"lo`,
+		coldStorageDir + "/pull_requests.json": `[]`,
+	}
+	for _, repo := range []string{"alpha", "beta", "gamma"} {
+		base := fmt.Sprintf("repositories/workspace/%s.git", repo)
+		files[base+"/HEAD"] = "ref: refs/heads/main\n"
+		files[base+"/config"] = "[core]\n\tbare = true\n"
+		files[base+"/refs/heads/main"] = "0123456789abcdef0123456789abcdef01234567\n"
+		for i := 0; i < 20; i++ {
+			files[fmt.Sprintf("%s/objects/%02x/%038d", base, i, i)] = fmt.Sprintf("object %s %d", repo, i)
+		}
+		files[fmt.Sprintf("repositories/workspace/%s.git.info/info.json", repo)] = `{}`
+	}
+	for name, content := range files {
+		path := filepath.Join(outputDir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return outputDir
+}
+
+func buildArchiveBytes(t *testing.T, sourceDir string, workers int) []byte {
+	t.Helper()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, sourceDir, zap.NewNop(), false, "")
+	exporter.archiveWorkers = workers
+
+	var buf bytes.Buffer
+	require.NoError(t, exporter.archiveDirectory(sourceDir, &buf))
+	require.NoError(t, tar.NewWriter(&buf).Close())
+	return buf.Bytes()
+}
+
+func TestArchiveDirectoryParallelMatchesSerial(t *testing.T) {
+	sourceDir := createArchiveFixture(t)
+
+	serial := buildArchiveBytes(t, sourceDir, 1)
+	for _, workers := range []int{2, 4, 8} {
+		parallel := buildArchiveBytes(t, sourceDir, workers)
+		assert.True(t, bytes.Equal(serial, parallel), "archive built with %d workers differs from the serial archive", workers)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(sourceDir))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "segment files should be removed")
+
+	var names []string
+	reader := tar.NewReader(bytes.NewReader(serial))
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	assert.Contains(t, names, "repositories/workspace/beta.git/objects/05/"+fmt.Sprintf("%038d", 5))
+	assert.Contains(t, names, "users_000001.json")
+	assert.NotContains(t, names, exportReportFile)
+	assert.NotContains(t, names, coldStorageDir)
+	assert.NotContains(t, names, ".users_000001.json.tmp-123")
+}
+
+// segmentCountingWriter records the most archive segments on disk while the
+// archive is written.
+type segmentCountingWriter struct {
+	written int
+	pattern string
+	max     int
+}
+
+func (w *segmentCountingWriter) Write(p []byte) (int, error) {
+	if matches, _ := filepath.Glob(w.pattern); len(matches) > w.max {
+		w.max = len(matches)
+	}
+	w.written += len(p)
+	return len(p), nil
+}
+
+func TestArchiveDirectoryParallelBoundsSegments(t *testing.T) {
+	sourceDir := createArchiveFixture(t)
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, sourceDir, zap.NewNop(), false, "")
+	exporter.archiveWorkers = 2
+	units, err := exporter.planArchiveUnits(sourceDir)
+	require.NoError(t, err)
+	require.Greater(t, len(units), 2*exporter.archiveWorkers)
+
+	writer := &segmentCountingWriter{
+		pattern: filepath.Join(filepath.Dir(sourceDir), ".bbc-archive-segments-*", "segment-*.tar"),
+	}
+	require.NoError(t, exporter.archiveDirectory(sourceDir, writer))
+
+	assert.Positive(t, writer.max)
+	assert.LessOrEqual(t, writer.max, exporter.archiveWorkers, "segments are built at most one per worker ahead")
+}
+
+func TestPlanArchiveUnits(t *testing.T) {
+	sourceDir := createArchiveFixture(t)
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, sourceDir, zap.NewNop(), false, "")
+
+	units, err := exporter.planArchiveUnits(sourceDir)
+	require.NoError(t, err)
+
+	var subtrees []string
+	for _, unit := range units {
+		if unit.subtree {
+			subtrees = append(subtrees, ToUnixPath(unit.relPath))
+		}
+	}
+	assert.Equal(t, []string{
+		"repositories/workspace/alpha.git",
+		"repositories/workspace/alpha.git.info",
+		"repositories/workspace/beta.git",
+		"repositories/workspace/beta.git.info",
+		"repositories/workspace/gamma.git",
+		"repositories/workspace/gamma.git.info",
+	}, subtrees)
+}
+
+func TestArchiveDirectoryParallelError(t *testing.T) {
+	sourceDir := createArchiveFixture(t)
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, sourceDir, zap.NewNop(), false, "")
+	exporter.archiveWorkers = 4
+
+	// A dangling symlink inside a segment is skipped like in the serial walk
+	require.NoError(t, os.Symlink("missing", filepath.Join(sourceDir, "repositories", "workspace", "beta.git", "dangling")))
+	var buf bytes.Buffer
+	require.NoError(t, exporter.archiveDirectory(sourceDir, &buf))
+
+	err := exporter.archiveDirectory(filepath.Join(sourceDir, "missing"), &buf)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestCreateArchiveCancelled(t *testing.T) {
+	sourceDir := createArchiveFixture(t)
+	client := &Client{logger: zap.NewNop()}
+	exporter := NewExporter(client, sourceDir, zap.NewNop(), false, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.SetContext(ctx)
+
+	for _, workers := range []int{1, 4} {
+		exporter.archiveWorkers = workers
+		_, err := exporter.CreateArchive()
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NoFileExists(t, sourceDir+".tar.gz", "incomplete archive is removed")
+	}
+}
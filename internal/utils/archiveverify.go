@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// ErrArchiveVerification is returned when the archive read back after
+// creation does not match the export directory.
+var ErrArchiveVerification = errors.New("archive verification failed")
+
+// archiveContents counts the entries of an archive and the bytes of its
+// files.
+type archiveContents struct {
+	entries int
+	bytes   int64
+}
+
+// SetVerifyArchive re-reads the archive after it is created and fails the
+// export when it does not match the export directory.
+func (e *Exporter) SetVerifyArchive(enabled bool) {
+	e.verifyArchive = enabled
+}
+
+// expectedArchiveContents counts what CreateArchive writes for sourceDir:
+// directories, regular files and the files symlinks point to, without the
+// skipped entries.
+func (e *Exporter) expectedArchiveContents(sourceDir string) (archiveContents, error) {
+	var expected archiveContents
+	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == sourceDir {
+			return nil
+		}
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		if e.skipArchiveEntry(relPath, info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case info.IsDir():
+			expected.entries++
+		case info.Mode().IsRegular():
+			expected.entries++
+			expected.bytes += info.Size()
+		case info.Mode()&os.ModeSymlink != 0:
+			if target, err := os.Stat(path); err == nil && target.Mode().IsRegular() {
+				expected.entries++
+				expected.bytes += target.Size()
+			}
+		}
+		return nil
+	})
+	return expected, err
+}
+
+// readArchiveContents reads a tar.gz archive end to end. Truncated entries
+// and gzip checksum mismatches are returned as errors.
+func readArchiveContents(archivePath string) (archiveContents, error) {
+	var contents archiveContents
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return contents, err
+	}
+	defer func() { _ = file.Close() }()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return contents, fmt.Errorf("invalid gzip stream: %w", err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return contents, fmt.Errorf("entry %d: %w", contents.entries+1, err)
+		}
+		contents.entries++
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		n, err := io.Copy(io.Discard, tarReader)
+		if err != nil {
+			return contents, fmt.Errorf("%s: %w", header.Name, err)
+		}
+		if n != header.Size {
+			return contents, fmt.Errorf("%s: read %d of %d bytes", header.Name, n, header.Size)
+		}
+		contents.bytes += n
+	}
+	// The gzip checksum is only checked once the stream is read to its end.
+	if _, err := io.Copy(io.Discard, gzipReader); err != nil {
+		return contents, fmt.Errorf("invalid gzip stream: %w", err)
+	}
+	return contents, gzipReader.Close()
+}
+
+// verifyArchiveContents compares the archive with the export directory it
+// was built from and removes it when they differ.
+func (e *Exporter) verifyArchiveContents(archivePath string) error {
+	if !e.verifyArchive {
+		return nil
+	}
+
+	expected, err := e.expectedArchiveContents(e.outputDir)
+	if err != nil {
+		return fmt.Errorf("%w: failed to read the export directory: %v", ErrArchiveVerification, err)
+	}
+	actual, err := readArchiveContents(archivePath)
+	if err == nil && actual != expected {
+		err = fmt.Errorf("archive has %d entries and %d bytes, the export directory %d entries and %d bytes",
+			actual.entries, actual.bytes, expected.entries, expected.bytes)
+	}
+	if err != nil {
+		if removeErr := os.Remove(archivePath); removeErr != nil {
+			e.logger.Warn("Failed to remove corrupt archive", zap.Error(removeErr))
+		}
+		return fmt.Errorf("%w: %s: %v", ErrArchiveVerification, archivePath, err)
+	}
+
+	e.logger.Info("Verified archive",
+		zap.String("archive", archivePath),
+		zap.Int("entries", actual.entries),
+		zap.Int64("bytes", actual.bytes))
+	return nil
+}
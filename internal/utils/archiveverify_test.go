@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func archiveVerifyFixture(t *testing.T) *Exporter {
+	t.Helper()
+	outputDir := filepath.Join(t.TempDir(), "export")
+	reposDir := filepath.Join(outputDir, "repositories", "workspace", "repo.git")
+	require.NoError(t, os.MkdirAll(reposDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(reposDir, "HEAD"), []byte("ref: refs/heads/main\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "schema.json"), []byte(`{"version": "1.2.0"}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, exportReportFile), []byte(`{}`), 0644))
+	require.NoError(t, os.Symlink("schema.json", filepath.Join(outputDir, "link.json")))
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.SetVerifyArchive(true)
+	return exporter
+}
+
+func TestVerifyArchiveContents(t *testing.T) {
+	exporter := archiveVerifyFixture(t)
+	archivePath, err := exporter.CreateArchive()
+	require.NoError(t, err)
+
+	require.NoError(t, exporter.verifyArchiveContents(archivePath))
+	contents, err := readArchiveContents(archivePath)
+	require.NoError(t, err)
+	assert.Equal(t, 6, contents.entries, "3 directories, 2 files and the symlinked file; sidecars are left out")
+	assert.Equal(t, int64(2*len(`{"version": "1.2.0"}`)+len("ref: refs/heads/main\n")), contents.bytes)
+}
+
+func TestVerifyArchiveContentsMismatch(t *testing.T) {
+	exporter := archiveVerifyFixture(t)
+	archivePath, err := exporter.CreateArchive()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(exporter.outputDir, "late.json"), []byte("[]"), 0644))
+
+	err = exporter.verifyArchiveContents(archivePath)
+
+	assert.ErrorIs(t, err, ErrArchiveVerification)
+	assert.ErrorContains(t, err, "archive has 6 entries")
+	assert.NoFileExists(t, archivePath, "a mismatched archive is removed")
+}
+
+func TestVerifyArchiveContentsTruncated(t *testing.T) {
+	exporter := archiveVerifyFixture(t)
+	archivePath, err := exporter.CreateArchive()
+	require.NoError(t, err)
+	info, err := os.Stat(archivePath)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(archivePath, info.Size()-12))
+
+	err = exporter.verifyArchiveContents(archivePath)
+
+	assert.ErrorIs(t, err, ErrArchiveVerification)
+	assert.NoFileExists(t, archivePath)
+}
+
+func TestVerifyArchiveContentsDisabled(t *testing.T) {
+	exporter := archiveVerifyFixture(t)
+	exporter.SetVerifyArchive(false)
+
+	assert.NoError(t, exporter.verifyArchiveContents(filepath.Join(t.TempDir(), "missing.tar.gz")))
+}
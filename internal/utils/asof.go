@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// ParseAsOf parses an --as-of value into the first instant after the
+// snapshot: a date (YYYY-MM-DD) includes that whole day in UTC, an RFC 3339
+// timestamp is exact. The zero time means no --as-of was given.
+func ParseAsOf(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date.AddDate(0, 0, 1), nil
+	}
+	if timestamp, err := time.Parse(time.RFC3339, value); err == nil {
+		return timestamp, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid value for --as-of: %q (expected YYYY-MM-DD or an RFC 3339 timestamp)", value)
+}
+
+// SetAsOf exports the repository as it was at the given time: later pull
+// requests and comments are left out and branches and tags are moved back
+// to the commits they could have pointed to then.
+func (e *Exporter) SetAsOf(value string) error {
+	asOf, err := ParseAsOf(value)
+	if err != nil {
+		return err
+	}
+	e.asOf = asOf
+	return nil
+}
+
+// afterAsOf reports whether an RFC 3339 timestamp lies after the snapshot.
+func (e *Exporter) afterAsOf(timestamp string) bool {
+	if e.asOf.IsZero() || timestamp == "" {
+		return false
+	}
+	parsed, err := time.Parse(time.RFC3339, timestamp)
+	return err == nil && !parsed.Before(e.asOf)
+}
+
+// now returns the moment the export describes: the --as-of time when set,
+// otherwise the current time of the client's clock.
+func (e *Exporter) now() time.Time {
+	if !e.asOf.IsZero() {
+		return e.asOf
+	}
+	return e.client.now()
+}
+
+// trimRefsAsOf moves each branch back to its newest commit committed before
+// the snapshot and deletes branches without one and tags of later commits.
+// Commit dates are used because mirrors carry no reflog.
+func (e *Exporter) trimRefsAsOf(repoPath string) error {
+	if e.asOf.IsZero() {
+		return nil
+	}
+	refs, err := mirrorRefs(repoPath)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(refs))
+	for ref := range refs {
+		names = append(names, ref)
+	}
+	sort.Strings(names)
+
+	for _, ref := range names {
+		var target string
+		if strings.HasPrefix(ref, "refs/tags/") {
+			committed, err := commitTime(repoPath, ref)
+			if err != nil {
+				// Tags of trees or blobs have no date to compare.
+				continue
+			}
+			if committed.Before(e.asOf) {
+				continue
+			}
+		} else {
+			if target, err = newestCommitBefore(repoPath, ref, e.asOf); err != nil {
+				return err
+			}
+			if target == refs[ref] {
+				continue
+			}
+		}
+
+		args := []string{"update-ref", ref, target, refs[ref]}
+		if target == "" {
+			args = []string{"update-ref", "-d", ref, refs[ref]}
+		}
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoPath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to trim %s to --as-of: %s: %w", ref, strings.TrimSpace(string(output)), err)
+		}
+		e.report.Counts.AsOfTrimmedRefs++
+		e.logger.Debug("Trimmed ref to --as-of",
+			zap.String("ref", ref),
+			zap.String("from", refs[ref]),
+			zap.String("to", target))
+	}
+	return nil
+}
+
+// applyAsOf leaves out pull requests opened after the snapshot and shows
+// the others as they were then: pull requests closed later are open again
+// and their commits are moved back like the branches.
+func (e *Exporter) applyAsOf(workspace, repoSlug string, prs []data.PullRequest) []data.PullRequest {
+	if e.asOf.IsZero() {
+		return prs
+	}
+	repoPath := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+
+	kept := make([]data.PullRequest, 0, len(prs))
+	for _, pr := range prs {
+		if e.afterAsOf(pr.CreatedAt) {
+			e.report.Counts.AsOfDroppedPullRequests++
+			continue
+		}
+		if pr.ClosedAt != nil && e.afterAsOf(*pr.ClosedAt) {
+			pr.ClosedAt = nil
+			pr.MergedAt = nil
+			pr.MergeCommitSHA = nil
+			e.report.Counts.AsOfReopenedPullRequests++
+		}
+		for _, branch := range []*data.PRBranch{&pr.Head, &pr.Base} {
+			committed, err := commitTime(repoPath, branch.SHA)
+			if err != nil || committed.Before(e.asOf) {
+				continue
+			}
+			if target, err := newestCommitBefore(repoPath, branch.SHA, e.asOf); err == nil && target != "" {
+				branch.SHA = target
+			}
+		}
+		kept = append(kept, pr)
+	}
+	if dropped := len(prs) - len(kept); dropped > 0 {
+		e.logger.Info("Leaving out pull requests created after --as-of",
+			zap.String("repository", repoSlug),
+			zap.Int("count", dropped))
+	}
+	return kept
+}
+
+// filterCommentsAsOf leaves out comments created after the snapshot.
+func (e *Exporter) filterCommentsAsOf(regular []data.IssueComment,
+	review []data.PullRequestReviewComment) ([]data.IssueComment, []data.PullRequestReviewComment) {
+	if e.asOf.IsZero() {
+		return regular, review
+	}
+
+	keptRegular := make([]data.IssueComment, 0, len(regular))
+	for _, comment := range regular {
+		if e.afterAsOf(comment.CreatedAt) {
+			e.report.Counts.AsOfDroppedComments++
+			continue
+		}
+		keptRegular = append(keptRegular, comment)
+	}
+	keptReview := make([]data.PullRequestReviewComment, 0, len(review))
+	for _, comment := range review {
+		if e.afterAsOf(comment.CreatedAt) {
+			e.report.Counts.AsOfDroppedComments++
+			continue
+		}
+		keptReview = append(keptReview, comment)
+	}
+	return keptRegular, keptReview
+}
+
+// commitTime returns the committer date of a commit.
+func commitTime(repoPath, rev string) (time.Time, error) {
+	cmd := exec.Command("git", "log", "-1", "--format=%ct", rev+"^{commit}", "--")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read the commit date of %s: %w", rev, err)
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(string(output)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse the commit date of %s: %w", rev, err)
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// newestCommitBefore returns the newest commit reachable from rev that was
+// committed before cutoff, or "" if there is none.
+func newestCommitBefore(repoPath, rev string, cutoff time.Time) (string, error) {
+	// --before includes commits dated exactly at its argument.
+	before := fmt.Sprintf("--before=@%d", cutoff.Unix()-1)
+	cmd := exec.Command("git", "rev-list", "-1", before, rev, "--")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the commit of %s before %s: %w", rev, cutoff.Format(time.RFC3339), err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// asOfFixture creates an export mirror with one commit on main in January
+// and one in March, a feature branch and a tag from March.
+func asOfFixture(t *testing.T) (*Exporter, string, string, string) {
+	t.Helper()
+	outputDir := t.TempDir()
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	commit := func(date, content string) string {
+		t.Setenv("GIT_COMMITTER_DATE", date)
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, "README.md"), []byte(content), 0644))
+		runGit(t, workDir, "add", "-A")
+		runGit(t, workDir, "commit", "--date", date, "-m", content)
+		return runGit(t, workDir, "rev-parse", "HEAD")
+	}
+	january := commit("2024-01-15T12:00:00Z", "january\n")
+	march := commit("2024-03-15T12:00:00Z", "march\n")
+	runGit(t, workDir, "tag", "v2", march)
+	runGit(t, workDir, "checkout", "-b", "feature")
+	feature := commit("2024-03-20T12:00:00Z", "feature\n")
+
+	mirrorPath := filepath.Join(outputDir, "repositories", "workspace", "repo.git")
+	require.NoError(t, exec.Command("git", "clone", "--mirror", workDir, mirrorPath).Run())
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	require.NoError(t, exporter.SetAsOf("2024-02-01"))
+	return exporter, january, march, feature
+}
+
+func TestParseAsOf(t *testing.T) {
+	asOf, err := ParseAsOf("2024-02-01")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 2, 2, 0, 0, 0, 0, time.UTC), asOf)
+
+	asOf, err = ParseAsOf("2024-02-01T10:30:00+02:00")
+	require.NoError(t, err)
+	assert.True(t, asOf.Equal(time.Date(2024, 2, 1, 8, 30, 0, 0, time.UTC)))
+
+	asOf, err = ParseAsOf("")
+	require.NoError(t, err)
+	assert.True(t, asOf.IsZero())
+
+	_, err = ParseAsOf("01/02/2024")
+	assert.ErrorContains(t, err, "--as-of")
+}
+
+func TestTrimRefsAsOf(t *testing.T) {
+	exporter, january, _, _ := asOfFixture(t)
+	mirrorPath := filepath.Join(exporter.outputDir, "repositories", "workspace", "repo.git")
+
+	require.NoError(t, exporter.trimRefsAsOf(mirrorPath))
+
+	refs, err := mirrorRefs(mirrorPath)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"refs/heads/main":    january,
+		"refs/heads/feature": january,
+	}, refs)
+	assert.Equal(t, 3, exporter.report.Counts.AsOfTrimmedRefs)
+}
+
+func TestTrimRefsAsOfDeletesBranchesCreatedLater(t *testing.T) {
+	exporter, _, _, _ := asOfFixture(t)
+	require.NoError(t, exporter.SetAsOf("2024-01-01"))
+	mirrorPath := filepath.Join(exporter.outputDir, "repositories", "workspace", "repo.git")
+
+	require.NoError(t, exporter.trimRefsAsOf(mirrorPath))
+
+	refs, err := mirrorRefs(mirrorPath)
+	require.NoError(t, err)
+	assert.Empty(t, refs)
+}
+
+func TestTrimRefsAsOfNotSet(t *testing.T) {
+	exporter, _, march, feature := asOfFixture(t)
+	require.NoError(t, exporter.SetAsOf(""))
+	mirrorPath := filepath.Join(exporter.outputDir, "repositories", "workspace", "repo.git")
+
+	require.NoError(t, exporter.trimRefsAsOf(mirrorPath))
+
+	refs, err := mirrorRefs(mirrorPath)
+	require.NoError(t, err)
+	assert.Equal(t, march, refs["refs/heads/main"])
+	assert.Equal(t, feature, refs["refs/heads/feature"])
+	assert.Zero(t, exporter.report.Counts.AsOfTrimmedRefs)
+}
+
+func TestApplyAsOf(t *testing.T) {
+	exporter, january, march, feature := asOfFixture(t)
+	mergedAt := "2024-03-21T00:00:00Z"
+	mergeCommit := march
+	prs := []data.PullRequest{
+		{URL: "https://bitbucket.org/workspace/repo/pull/1", CreatedAt: "2024-01-20T00:00:00Z",
+			ClosedAt: &mergedAt, MergedAt: &mergedAt, MergeCommitSHA: &mergeCommit,
+			Base: data.PRBranch{Ref: "main", SHA: march}, Head: data.PRBranch{Ref: "feature", SHA: feature}},
+		{URL: "https://bitbucket.org/workspace/repo/pull/2", CreatedAt: "2024-03-01T00:00:00Z",
+			Base: data.PRBranch{Ref: "main", SHA: march}, Head: data.PRBranch{Ref: "feature", SHA: feature}},
+	}
+
+	kept := exporter.applyAsOf("workspace", "repo", prs)
+
+	require.Equal(t, []string{"1"}, prURLs(kept))
+	assert.Nil(t, kept[0].ClosedAt)
+	assert.Nil(t, kept[0].MergedAt)
+	assert.Nil(t, kept[0].MergeCommitSHA)
+	assert.Equal(t, january, kept[0].Base.SHA)
+	assert.Equal(t, january, kept[0].Head.SHA)
+	assert.Equal(t, 1, exporter.report.Counts.AsOfDroppedPullRequests)
+	assert.Equal(t, 1, exporter.report.Counts.AsOfReopenedPullRequests)
+}
+
+func TestFilterCommentsAsOf(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	require.NoError(t, exporter.SetAsOf("2024-02-01T00:00:00Z"))
+
+	regular, review := exporter.filterCommentsAsOf(
+		[]data.IssueComment{{CreatedAt: "2024-01-31T23:59:59Z"}, {CreatedAt: "2024-02-01T00:00:00Z"}},
+		[]data.PullRequestReviewComment{{CreatedAt: "2024-03-01T00:00:00Z"}},
+	)
+
+	assert.Len(t, regular, 1)
+	assert.Empty(t, review)
+	assert.Equal(t, 2, exporter.report.Counts.AsOfDroppedComments)
+}
+
+func TestValidateExportFlagsAsOf(t *testing.T) {
+	flags := &data.CmdExportFlags{
+		Workspace:            "workspace",
+		Repository:           "repo",
+		BitbucketAccessToken: "token",
+		AsOf:                 "yesterday",
+	}
+	assert.ErrorContains(t, ValidateExportFlags(flags), "--as-of")
+
+	flags.AsOf = "2024-02-01"
+	flags.TopUpFetch = true
+	assert.ErrorContains(t, ValidateExportFlags(flags), "--top-up-fetch cannot be combined with --as-of")
+}
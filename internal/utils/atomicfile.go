@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const atomicTempMarker = ".tmp-"
+
+// ErrCorruptExportFile is returned when a file written earlier in the export
+// can no longer be parsed. Later stages must not continue from such a file.
+var ErrCorruptExportFile = errors.New("corrupt export file")
+
+// writeFileAtomic writes a file through a temporary file in the same
+// directory and renames it into place, so a crash never leaves a truncated
+// file at path.
+func writeFileAtomic(path string, perm os.FileMode, write func(w io.Writer) error) (err error) {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+atomicTempMarker+"*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+	defer func() {
+		if err != nil {
+			_ = tmpFile.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if err = write(tmpFile); err != nil {
+		return err
+	}
+	if err = tmpFile.Sync(); err != nil {
+		return err
+	}
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// isAtomicTempFile reports whether name is a temporary file left behind by an
+// interrupted writeFileAtomic.
+func isAtomicTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.Contains(name, atomicTempMarker)
+}
+
+// readExportFile parses a JSON file previously written to the export
+// directory. Parse failures wrap ErrCorruptExportFile.
+func (e *Exporter) readExportFile(fileName string, v interface{}) error {
+	fileData, err := os.ReadFile(filepath.Join(e.outputDir, fileName))
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", fileName, err)
+	}
+	if err := json.Unmarshal(fileData, v); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrCorruptExportFile, fileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"old": true}`), 0644))
+
+	// A failed write leaves the previous content and no temporary file
+	err := writeFileAtomic(path, 0644, func(w io.Writer) error {
+		_, _ = io.WriteString(w, `{"new": tr`)
+		return errors.New("interrupted")
+	})
+	assert.ErrorContains(t, err, "interrupted")
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"old": true}`, string(content))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	require.NoError(t, writeFileAtomic(path, 0600, func(w io.Writer) error {
+		_, err := io.WriteString(w, `{"new": true}`)
+		return err
+	}))
+	content, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"new": true}`, string(content))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	if os.PathSeparator == '/' {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+}
+
+func TestIsAtomicTempFile(t *testing.T) {
+	assert.True(t, isAtomicTempFile(".users_000001.json.tmp-123456"))
+	assert.False(t, isAtomicTempFile("users_000001.json"))
+	assert.False(t, isAtomicTempFile(".gitignore"))
+}
+
+func TestReadExportFileCorrupt(t *testing.T) {
+	outputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, usersFile), []byte(`[{"login": "al`), 0644))
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+
+	var users []map[string]interface{}
+	assert.ErrorIs(t, exporter.readExportFile(usersFile, &users), ErrCorruptExportFile)
+	assert.NoError(t, exporter.validateExportData())
+
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, pullRequestsFile), []byte(`[{"url": "ht`), 0644))
+	assert.ErrorIs(t, exporter.validateExportData(), ErrCorruptExportFile)
+}
+
+func TestArchiveSkipsLeftoverTempFiles(t *testing.T) {
+	outputDir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	require.NoError(t, exporter.writeJSONFile(usersFile, []string{}))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "."+usersFile+".tmp-42"), []byte("[{"), 0644))
+
+	archivePath, err := exporter.CreateArchive()
+	require.NoError(t, err)
+	defer func() { _ = os.Remove(archivePath) }()
+
+	archiveFile, err := os.Open(archivePath)
+	require.NoError(t, err)
+	defer func() { _ = archiveFile.Close() }()
+	gzipReader, err := gzip.NewReader(archiveFile)
+	require.NoError(t, err)
+	tarReader := tar.NewReader(gzipReader)
+
+	var names []string
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	assert.Contains(t, names, usersFile)
+	assert.NotContains(t, names, "."+usersFile+".tmp-42")
+}
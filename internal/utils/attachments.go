@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	attachmentsDir  = "attachments"
+	attachmentsFile = "attachments_000001.json"
+
+	// maxAttachmentSize is the largest file GitHub accepts as a comment
+	// attachment. Larger files keep their Bitbucket link.
+	maxAttachmentSize = 25 << 20
+)
+
+// attachmentURLPattern matches files hosted by Bitbucket that stop resolving
+// once the repository is gone: images pasted into pull requests and
+// comments, repository downloads and issue attachments.
+var attachmentURLPattern = regexp.MustCompile(
+	`https://(?:bitbucket\.org|bytebucket\.org)/(?:repo/[^/\s]+/images|[^/\s]+/[^/\s]+/(?:downloads|images|issues/attachments))/[^\s()<>\[\]"'` + "`" + `]+`)
+
+// unsafeAttachmentName matches the characters replaced in the file names of
+// stored attachments.
+var unsafeAttachmentName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// SetDownloadAttachments stores the Bitbucket-hosted files linked from pull
+// request descriptions and comments under attachments/ in the archive and
+// points the links at them.
+func (e *Exporter) SetDownloadAttachments(enabled bool) {
+	e.downloadAttachments = enabled
+}
+
+// storedAttachment is an attachment written to the export directory.
+type storedAttachment struct {
+	url         string // tarball:// URL of the file in the archive
+	name        string
+	contentType string
+}
+
+// rehostAttachments downloads the attachments linked from the bodies of pull
+// requests and comments, rewrites the links to the archive copies and
+// returns one attachment record per linking record. A file linked several
+// times is stored once. Attachments that cannot be stored keep their link.
+func (e *Exporter) rehostAttachments(prs []data.PullRequest, issueComments []data.IssueComment,
+	reviewComments []data.PullRequestReviewComment) []data.Attachment {
+	stored := make(map[string]*storedAttachment)
+	attachments := []data.Attachment{}
+
+	rehost := func(body string, parent data.Attachment) string {
+		linked := make(map[string]bool)
+		return attachmentURLPattern.ReplaceAllStringFunc(body, func(match string) string {
+			source := strings.TrimRight(match, ".,;:!?")
+			suffix := match[len(source):]
+			attachment, ok := stored[source]
+			if !ok {
+				attachment = e.storeAttachment(source)
+				stored[source] = attachment
+			}
+			if attachment == nil {
+				return match
+			}
+			if !linked[source] {
+				linked[source] = true
+				record := parent
+				record.Type = "attachment"
+				record.URL = attachment.url
+				record.AssetName = attachment.name
+				record.AssetContentType = attachment.contentType
+				record.AssetURL = attachment.url
+				attachments = append(attachments, record)
+			}
+			return attachment.url + suffix
+		})
+	}
+
+	for i, pr := range prs {
+		prs[i].Body = rehost(pr.Body, data.Attachment{User: pr.User, PullRequest: pr.URL, CreatedAt: pr.CreatedAt})
+	}
+	for i, comment := range issueComments {
+		issueComments[i].Body = rehost(comment.Body,
+			data.Attachment{User: comment.User, IssueComment: comment.URL, CreatedAt: comment.CreatedAt})
+	}
+	for i, comment := range reviewComments {
+		reviewComments[i].Body = rehost(comment.Body,
+			data.Attachment{User: comment.User, PullRequestReviewComment: comment.URL, CreatedAt: comment.CreatedAt})
+	}
+
+	e.logger.Info("Stored linked attachments in the archive",
+		zap.Int("files", e.report.Counts.Attachments),
+		zap.Int("references", len(attachments)),
+		zap.Int("failed", e.report.Counts.FailedAttachments))
+	return attachments
+}
+
+// storeAttachment downloads source into attachments/<hash>/<name>. It
+// returns nil, after logging why, when the file cannot be stored.
+func (e *Exporter) storeAttachment(source string) *storedAttachment {
+	content, contentType, err := e.client.download(source, "attachment", maxAttachmentSize)
+	if err != nil {
+		e.report.Counts.FailedAttachments++
+		e.logger.Warn("Failed to download attachment; the link is left pointing at Bitbucket",
+			zap.String("url", source),
+			zap.Error(err))
+		return nil
+	}
+
+	sum := sha256.Sum256([]byte(source))
+	dir := hex.EncodeToString(sum[:8])
+	name := attachmentName(source)
+	relPath := path.Join(attachmentsDir, dir, name)
+	target := filepath.Join(e.outputDir, filepath.FromSlash(relPath))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		e.report.Counts.FailedAttachments++
+		e.logger.Warn("Failed to create attachment directory", zap.String("path", target), zap.Error(err))
+		return nil
+	}
+	if err := writeFileAtomic(target, 0644, func(w io.Writer) error {
+		_, err := w.Write(content)
+		return err
+	}); err != nil {
+		e.report.Counts.FailedAttachments++
+		e.logger.Warn("Failed to write attachment", zap.String("path", target), zap.Error(err))
+		return nil
+	}
+
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType, _, _ = strings.Cut(http.DetectContentType(content), ";")
+	}
+	e.report.Counts.Attachments++
+	return &storedAttachment{
+		url:         "tarball://root/" + relPath,
+		name:        name,
+		contentType: contentType,
+	}
+}
+
+// attachmentName returns the file name of an attachment URL, restricted to
+// characters that are safe in archive paths and URLs.
+func attachmentName(source string) string {
+	name := "attachment"
+	if parsed, err := url.Parse(source); err == nil {
+		if base := path.Base(parsed.Path); base != "." && base != "/" {
+			name = base
+		}
+	}
+	name = strings.Trim(unsafeAttachmentName.ReplaceAllString(name, "_"), "._")
+	if name == "" {
+		return "attachment"
+	}
+	return name
+}
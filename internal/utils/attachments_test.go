@@ -0,0 +1,158 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestAttachmentURLPattern(t *testing.T) {
+	for _, link := range []string{
+		"https://bitbucket.org/repo/xk7Rq9/images/1234-screenshot.png",
+		"https://bitbucket.org/workspace/repo/downloads/build-log.txt",
+		"https://bitbucket.org/workspace/repo/issues/attachments/12/workspace/repo/1700000000.0/12/trace.log",
+		"https://bytebucket.org/workspace/repo/images/diagram.svg",
+	} {
+		assert.Equal(t, link, attachmentURLPattern.FindString("see !["+link+"]("+link+") now"), link)
+	}
+	for _, link := range []string{
+		"https://bitbucket.org/workspace/repo/pull-requests/3",
+		"https://bitbucket.org/workspace/repo/src/main/images/logo.png",
+		"https://example.com/workspace/repo/downloads/file.zip",
+	} {
+		assert.Empty(t, attachmentURLPattern.FindString(link), link)
+	}
+}
+
+func TestAttachmentName(t *testing.T) {
+	assert.Equal(t, "1234-screenshot.png", attachmentName("https://bitbucket.org/repo/x/images/1234-screenshot.png"))
+	assert.Equal(t, "my_report_final_.pdf", attachmentName("https://bitbucket.org/ws/repo/downloads/my%20report%20(final).pdf"))
+	assert.Equal(t, "attachment", attachmentName("https://bitbucket.org/ws/repo/downloads/..."))
+}
+
+func TestRehostAttachments(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/repo/x/images/1-screenshot.png":
+			assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"), "Bitbucket downloads are authenticated")
+			w.Header().Set("Content-Type", "image/png")
+			writeResponse(t, w, []byte("\x89PNG\r\n\x1a\nimage"))
+		case "/ws/repo/downloads/log.txt":
+			writeResponse(t, w, []byte("build log"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	client := &Client{baseURL: "https://api.bitbucket.org/2.0", accessToken: "test-token",
+		httpClient: &http.Client{Transport: testServerTransport{server}}, logger: zap.NewNop()}
+	exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+	exporter.SetDownloadAttachments(true)
+
+	image := "https://bitbucket.org/repo/x/images/1-screenshot.png"
+	prs := []data.PullRequest{{URL: "pr-1", User: "alice", CreatedAt: "2024-01-01T00:00:00Z",
+		Body: "![screenshot](" + image + ") and again " + image + "."}}
+	issueComments := []data.IssueComment{{URL: "comment-1", User: "bob",
+		Body: "Log: https://bitbucket.org/ws/repo/downloads/log.txt, screenshot " + image}}
+	reviewComments := []data.PullRequestReviewComment{{URL: "review-1", User: "carol",
+		Body: "Gone: https://bitbucket.org/ws/repo/downloads/missing.zip"}}
+
+	attachments := exporter.rehostAttachments(prs, issueComments, reviewComments)
+
+	imageURL := "tarball://root/attachments/" + filepath.Base(filepath.Dir(findAttachment(t, outputDir, "1-screenshot.png"))) + "/1-screenshot.png"
+	logURL := "tarball://root/attachments/" + filepath.Base(filepath.Dir(findAttachment(t, outputDir, "log.txt"))) + "/log.txt"
+	assert.Equal(t, "![screenshot]("+imageURL+") and again "+imageURL+".", prs[0].Body)
+	assert.Equal(t, "Log: "+logURL+", screenshot "+imageURL, issueComments[0].Body)
+	assert.Equal(t, "Gone: https://bitbucket.org/ws/repo/downloads/missing.zip", reviewComments[0].Body,
+		"files that cannot be downloaded keep their link")
+
+	assert.Equal(t, []data.Attachment{
+		{Type: "attachment", URL: imageURL, User: "alice", AssetName: "1-screenshot.png", AssetContentType: "image/png",
+			AssetURL: imageURL, PullRequest: "pr-1", CreatedAt: "2024-01-01T00:00:00Z"},
+		{Type: "attachment", URL: logURL, User: "bob", AssetName: "log.txt", AssetContentType: "text/plain",
+			AssetURL: logURL, IssueComment: "comment-1"},
+		{Type: "attachment", URL: imageURL, User: "bob", AssetName: "1-screenshot.png", AssetContentType: "image/png",
+			AssetURL: imageURL, IssueComment: "comment-1"},
+	}, attachments)
+	assert.Equal(t, 3, requests, "each file is downloaded once")
+	assert.Equal(t, 2, exporter.report.Counts.Attachments)
+	assert.Equal(t, 1, exporter.report.Counts.FailedAttachments)
+}
+
+func TestRehostAttachmentsSkipsLargeFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(t, w, make([]byte, maxAttachmentSize+1))
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	client := &Client{httpClient: &http.Client{Transport: testServerTransport{server}}, logger: zap.NewNop()}
+	exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+
+	link := "https://bitbucket.org/ws/repo/downloads/huge.iso"
+	prs := []data.PullRequest{{URL: "pr-1", Body: link}}
+	attachments := exporter.rehostAttachments(prs, nil, nil)
+
+	assert.Empty(t, attachments)
+	assert.Equal(t, link, prs[0].Body)
+	assert.NoDirExists(t, filepath.Join(outputDir, attachmentsDir))
+}
+
+func TestDownloadAttachmentChecksContentLength(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(maxAttachmentSize+1))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	client := &Client{httpClient: server.Client(), logger: zap.NewNop()}
+
+	_, _, err := client.download(server.URL+"/ws/repo/downloads/huge.iso", "attachment", maxAttachmentSize)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "attachment of 26214401 bytes exceeds the limit of 26214400 bytes")
+}
+
+func TestDownloadAttachmentWithholdsCredentialsAfterRedirect(t *testing.T) {
+	var redirectedAuth string
+	contentServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirectedAuth = r.Header.Get("Authorization")
+		writeResponse(t, w, []byte("image"))
+	}))
+	defer contentServer.Close()
+	bitbucketServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer test-token", r.Header.Get("Authorization"))
+		http.Redirect(w, r, contentServer.URL+"/repo/x/images/1-screenshot.png", http.StatusFound)
+	}))
+	defer bitbucketServer.Close()
+	client := &Client{baseURL: bitbucketServer.URL, accessToken: "test-token",
+		httpClient: bitbucketServer.Client(), logger: zap.NewNop()}
+
+	content, _, err := client.download(bitbucketServer.URL+"/repo/x/images/1-screenshot.png", "attachment", maxAttachmentSize)
+
+	require.NoError(t, err)
+	assert.Equal(t, []byte("image"), content)
+	assert.Empty(t, redirectedAuth, "credentials stay with the Bitbucket host")
+}
+
+// findAttachment returns the path of the stored attachment named name.
+func findAttachment(t *testing.T, outputDir, name string) string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(outputDir, attachmentsDir, "*", name))
+	require.NoError(t, err)
+	require.Len(t, matches, 1, name)
+	_, err = os.Stat(matches[0])
+	require.NoError(t, err)
+	return matches[0]
+}
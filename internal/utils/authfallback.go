@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// Authentication methods of --auth-fallback.
+const (
+	AuthMethodAccessToken = "access-token"
+	AuthMethodAPIToken    = "api-token"
+	AuthMethodAppPassword = "app-password"
+)
+
+var authMethods = []string{AuthMethodAccessToken, AuthMethodAPIToken, AuthMethodAppPassword}
+
+// ValidateAuthFallback checks --auth-fallback: every listed method is known,
+// listed once and has its credentials, and every method with credentials is
+// listed.
+func ValidateAuthFallback(cmdFlags *data.CmdExportFlags) error {
+	if len(cmdFlags.AuthFallback) == 0 {
+		return nil
+	}
+	configured := map[string]bool{
+		AuthMethodAccessToken: cmdFlags.BitbucketAccessToken != "",
+		AuthMethodAPIToken:    cmdFlags.BitbucketAPIToken != "",
+		AuthMethodAppPassword: cmdFlags.BitbucketUser != "" || cmdFlags.BitbucketAppPass != "",
+	}
+
+	var problems []error
+	listed := make(map[string]bool)
+	for _, method := range cmdFlags.AuthFallback {
+		_, known := configured[method]
+		switch {
+		case !known:
+			problems = append(problems, fmt.Errorf("invalid value for --auth-fallback: %q (supported: %s)",
+				method, strings.Join(authMethods, ", ")))
+		case listed[method]:
+			problems = append(problems, fmt.Errorf("--auth-fallback lists %s more than once", method))
+		case !configured[method]:
+			problems = append(problems, fmt.Errorf("--auth-fallback lists %s but its credentials are not set", method))
+		}
+		listed[method] = true
+	}
+	for _, method := range authMethods {
+		if configured[method] && !listed[method] {
+			problems = append(problems, fmt.Errorf("credentials for %s are set but --auth-fallback does not list it", method))
+		}
+	}
+	if cmdFlags.BitbucketOAuthKey != "" || cmdFlags.BitbucketOAuthSecret != "" {
+		problems = append(problems, errors.New("--auth-fallback cannot be combined with OAuth consumer authentication"))
+	}
+	return JoinProblems(problems...)
+}
+
+// SetAuthFallback sets the authentication methods tried in order when
+// Bitbucket answers 401 or 403. The method that works is remembered per
+// endpoint scope, so later requests to it skip the ones that failed. An
+// empty list uses the configured credentials only.
+func (c *Client) SetAuthFallback(methods []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.authFallback = methods
+	c.endpointAuth = make(map[string]int)
+}
+
+// primaryAuthMethod returns the first method of the fallback chain, or the
+// method the configured credentials select.
+func (c *Client) primaryAuthMethod() string {
+	switch {
+	case len(c.authFallback) > 0:
+		return c.authFallback[0]
+	case c.accessToken != "":
+		return AuthMethodAccessToken
+	case c.apiToken != "":
+		return AuthMethodAPIToken
+	case c.username != "" || c.appPass != "":
+		return AuthMethodAppPassword
+	}
+	return ""
+}
+
+// authScope returns the part of an endpoint that decides which credentials
+// Bitbucket accepts for it: the resource under a repository or workspace,
+// such as repositories/pullrequests or workspaces/members, or else the first
+// path segment.
+func (c *Client) authScope(endpoint string) string {
+	endpointPath, _, _ := strings.Cut(endpoint, "?")
+	bases := []string{c.baseURL}
+	for _, overrideURL := range c.endpointOverrides {
+		bases = append(bases, overrideURL)
+	}
+	for _, base := range bases {
+		if base != "" && strings.HasPrefix(endpointPath, base) {
+			endpointPath = strings.TrimPrefix(endpointPath, base)
+			break
+		}
+	}
+
+	segments := strings.Split(strings.Trim(endpointPath, "/"), "/")
+	switch {
+	case segments[0] == "repositories" && len(segments) > 3:
+		return "repositories/" + segments[3]
+	case segments[0] == "workspaces" && len(segments) > 2:
+		return "workspaces/" + segments[2]
+	}
+	return segments[0]
+}
+
+// authMethodFor returns the index of the fallback method requests to scope
+// use.
+func (c *Client) authMethodFor(scope string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.endpointAuth[scope]
+}
+
+// fallBackAuth moves scope past the method at index failed after Bitbucket
+// rejected it. It reports whether another method is left to try.
+func (c *Client) fallBackAuth(scope string, failed, status int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if failed+1 >= len(c.authFallback) {
+		return false
+	}
+	if c.endpointAuth[scope] == failed {
+		c.endpointAuth[scope] = failed + 1
+		c.logger.Warn("Authentication method rejected; falling back to the next one for this endpoint",
+			zap.String("scope", scope),
+			zap.Int("status", status),
+			zap.String("rejected", c.authFallback[failed]),
+			zap.String("next", c.authFallback[failed+1]))
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidateAuthFallback(t *testing.T) {
+	credentials := data.CmdExportFlags{
+		BitbucketAccessToken: "workspace-token",
+		BitbucketAPIToken:    "api-token",
+		BitbucketEmail:       "user@example.com",
+	}
+	tests := []struct {
+		name     string
+		fallback []string
+		oauth    bool
+		wantErrs []string
+	}{
+		{name: "not set"},
+		{name: "every configured method", fallback: []string{AuthMethodAPIToken, AuthMethodAccessToken}},
+		{name: "unknown method", fallback: []string{AuthMethodAccessToken, AuthMethodAPIToken, "password"},
+			wantErrs: []string{`invalid value for --auth-fallback: "password"`}},
+		{name: "duplicate", fallback: []string{AuthMethodAccessToken, AuthMethodAPIToken, AuthMethodAccessToken},
+			wantErrs: []string{"lists access-token more than once"}},
+		{name: "missing credentials", fallback: []string{AuthMethodAccessToken, AuthMethodAPIToken, AuthMethodAppPassword},
+			wantErrs: []string{"lists app-password but its credentials are not set"}},
+		{name: "unlisted credentials", fallback: []string{AuthMethodAccessToken},
+			wantErrs: []string{"credentials for api-token are set but --auth-fallback does not list it"}},
+		{name: "OAuth", fallback: []string{AuthMethodAccessToken, AuthMethodAPIToken}, oauth: true,
+			wantErrs: []string{"cannot be combined with OAuth"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			flags := credentials
+			flags.AuthFallback = tc.fallback
+			if tc.oauth {
+				flags.BitbucketOAuthKey = "key"
+			}
+			err := ValidateAuthFallback(&flags)
+			if len(tc.wantErrs) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tc.wantErrs {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestValidateCredentialsWithAuthFallback(t *testing.T) {
+	flags := data.CmdExportFlags{
+		BitbucketAccessToken: "workspace-token",
+		BitbucketUser:        "user",
+		BitbucketAppPass:     "app-password",
+	}
+	err := validateCredentials(&flags)
+	require.Error(t, err, "several methods without a chain are rejected")
+
+	flags.AuthFallback = []string{AuthMethodAccessToken, AuthMethodAppPassword}
+	assert.NoError(t, validateCredentials(&flags))
+}
+
+func TestAuthScope(t *testing.T) {
+	client := &Client{baseURL: "https://api.bitbucket.org/2.0",
+		endpointOverrides: map[string]string{"workspaces": "https://gateway.example.com/bb"}}
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"repositories/ws/repo", "repositories"},
+		{"/repositories/ws/repo/pullrequests/12/comments?pagelen=100", "repositories/pullrequests"},
+		{"https://api.bitbucket.org/2.0/repositories/ws/other/pullrequests?page=2", "repositories/pullrequests"},
+		{"repositories/ws/repo/pipelines/", "repositories/pipelines"},
+		{"https://gateway.example.com/bb/workspaces/ws/members?page=3", "workspaces/members"},
+		{"workspaces/ws", "workspaces"},
+		{"user", "user"},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, client.authScope(tc.endpoint), tc.endpoint)
+	}
+}
+
+func TestMakeRequestFallsBackPerEndpoint(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		mu.Lock()
+		requests = append(requests, r.URL.Path+" "+strings.Fields(auth)[0])
+		mu.Unlock()
+		// Pipelines reject the workspace token, everything else accepts it.
+		if strings.Contains(r.URL.Path, "/pipelines") && strings.HasPrefix(auth, "Bearer ") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		writeResponse(t, w, []byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop(),
+		accessToken: "workspace-token", apiToken: "api-token", email: "user@example.com"}
+	client.SetAuthFallback([]string{AuthMethodAccessToken, AuthMethodAPIToken})
+
+	require.NoError(t, client.makeRequest("GET", "repositories/ws/repo/pipelines/", &struct{}{}))
+	require.NoError(t, client.makeRequest("GET", "repositories/ws/repo/pipelines/?page=2", &struct{}{}))
+	require.NoError(t, client.makeRequest("GET", "repositories/ws/repo/pullrequests", &struct{}{}))
+
+	assert.Equal(t, []string{
+		"/repositories/ws/repo/pipelines/ Bearer",
+		"/repositories/ws/repo/pipelines/ Basic",
+		"/repositories/ws/repo/pipelines/ Basic",
+		"/repositories/ws/repo/pullrequests Bearer",
+	}, requests, "the method that worked is remembered for the endpoint only")
+}
+
+func TestMakeRequestFailsWhenEveryMethodIsRejected(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop(),
+		accessToken: "workspace-token", username: "user", appPass: "app-password"}
+	client.SetAuthFallback([]string{AuthMethodAccessToken, AuthMethodAppPassword})
+
+	err := client.makeRequest("GET", "repositories/ws/repo", &struct{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+	assert.Equal(t, 2, requests)
+}
+
+func TestGitCredentialsUseFirstFallbackMethod(t *testing.T) {
+	client := &Client{accessToken: "workspace-token", apiToken: "api-token"}
+	client.SetAuthFallback([]string{AuthMethodAPIToken, AuthMethodAccessToken})
+
+	user, secret := client.gitCredentials()
+	assert.Equal(t, "x-bitbucket-api-token-auth", user)
+	assert.Equal(t, "api-token", secret)
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,24 +13,68 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
 	"go.uber.org/zap"
 )
 
 type Client struct {
-	baseURL          string
-	httpClient       *http.Client
-	accessToken      string // Workspace Access Token
-	apiToken         string // API Token replacing AppPass after Sept 2025
-	email            string // Will replace username after Sept 2025
-	username         string // Will be removed after Sept 2025 with appPass
-	appPass          string // To be deprecated Sept 2025
-	logger           *zap.Logger
-	commitSHACache   map[string]string
-	exportDir        string
-	skipCommitLookup bool
+	baseURL           string
+	httpClient        *http.Client
+	accessToken       string // Workspace Access Token
+	apiToken          string // API Token replacing AppPass after Sept 2025
+	email             string // Will replace username after Sept 2025
+	username          string // Will be removed after Sept 2025 with appPass
+	appPass           string // To be deprecated Sept 2025
+	logger            *zap.Logger
+	commitSHACache    map[string]string
+	exportDir         string
+	skipCommitLookup  bool
+	schemaDriftSeen   map[string]bool
+	deadline          time.Time
+	ctx               context.Context   // Cancels requests and git commands of an embedded export; nil never cancels
+	endpointOverrides map[string]string // API path prefix -> gateway base URL
+	maxResponseSize   int64             // Bytes; 0 uses defaultMaxResponseSize
+	urls              format.URLs       // Source URL policy of the config file
+	keepAmbiguousPRs  bool              // Rename ambiguous branch refs instead of dropping the PR
+	ambiguousPRs      []data.AmbiguousPullRequest
+	activityFailures  []data.ActivityFailure
+	commentFormatter  string             // markdown (default), html-to-md or raw
+	linkTarget        string             // GitHub repository URL source links in comments point to; empty keeps Bitbucket
+	prFooter          *template.Template // Provenance footer appended to PR descriptions; nil appends none
+	commentFooter     *template.Template // Provenance footer appended to PR comments; nil appends none
+	redactionRules    *RedactionRules    // Replacements applied to PR and comment bodies; nil applies none
+	redactedBodies    int
+	niceMode          bool
+	requestDelay      time.Duration // Minimum gap between API requests
+	lastRequestAt     time.Time
+	backoffUntil      time.Time // Workers wait until a rate-limit backoff is over
+	concurrency       int       // Workers fetching pull request details; 0 or 1 fetches serially
+	clock             Clock     // Timestamps for generated records; nil uses the system clock
+	failedResponses   []data.FailedAPIResponse
+	contributors      map[string]string // Exported author login -> display name seen in PRs and comments
+	ghostUser         string            // Login for authors of deleted accounts; empty uses DefaultGhostUser
+	userMapping       *UserMapping      // Bitbucket users exported under GitHub logins; nil keeps UUIDs
+	progress          *fetchProgress    // Records fetched pages for --resume; nil when not exporting
+	syncCache         *syncCache        // Pull requests of the last sync cycle; nil when not syncing
+	progressEvents    *progressReporter // JSON progress stream of --progress-format json; nil writes none
+	tokenRefreshCmd   string            // Shell command printing a new token after a 401
+	oauthKey          string            // OAuth consumer key; accessToken then holds the token it obtained
+	oauthSecret       string
+	oauthTokenURL     string
+	oauthExpiry       time.Time
+	network           NetworkOptions // Proxy and TLS settings, also passed to git
+	tokenRefreshes    int
+	retiredSecrets    []string       // Tokens replaced by a refresh
+	authFallback      []string       // Authentication methods tried in order on a 401 or 403; empty uses the configured one
+	endpointAuth      map[string]int // Endpoint scope -> index of the authFallback method it uses
+	throttling        throttlingCounters
+	mu                sync.Mutex // Guards state shared by concurrent workers
+	refreshMu         sync.Mutex // Serializes token refreshes
 }
 
 func NewClient(baseURL, accessToken, apiToken, email, username, appPass string, logger *zap.Logger, exportDir string, skipCommitLookup bool) *Client {
@@ -97,13 +143,62 @@ func (c *Client) GetRepository(workspace, repoSlug string) (*data.BitbucketRepos
 	return &repo, nil
 }
 
+func (c *Client) setAuthHeader(req *http.Request) {
+	c.setAuthHeaderFor(req, 0)
+}
+
+// setAuthHeaderFor authenticates req with the method at index method of the
+// --auth-fallback chain, or with the configured credentials without a chain.
+func (c *Client) setAuthHeaderFor(req *http.Request, method int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	authMethod := c.primaryAuthMethod()
+	if method < len(c.authFallback) {
+		authMethod = c.authFallback[method]
+	}
+	switch authMethod {
+	case AuthMethodAccessToken:
+		req.Header.Set("Authorization", "Bearer "+c.accessToken)
+	case AuthMethodAPIToken:
+		if c.email != "" {
+			req.SetBasicAuth(c.email, c.apiToken)
+		} else {
+			req.SetBasicAuth("x-bitbucket-api-token-auth", c.apiToken)
+		}
+	case AuthMethodAppPassword:
+		if c.username != "" && c.appPass != "" {
+			req.SetBasicAuth(c.username, c.appPass)
+		}
+	}
+}
+
 func (c *Client) makeRequest(method, endpoint string, v interface{}) error {
+	return c.makeRequestContext(c.context(), method, endpoint, v)
+}
+
+// GetJSON fetches an API endpoint, or a full "next" page URL, and decodes the
+// JSON response into v, with the client's retries, rate-limit handling and
+// response size limit. It stops when ctx is cancelled.
+func (c *Client) GetJSON(ctx context.Context, endpoint string, v interface{}) error {
+	return c.makeRequestContext(ctx, "GET", endpoint, v)
+}
+
+func (c *Client) makeRequestContext(ctx context.Context, method, endpoint string, v interface{}) error {
 	var fullURL string
 	maxRetries := 5
 	baseDelay := 1 * time.Second
+	refreshed := false
+	scope := c.authScope(endpoint)
 
 	for attempt := 0; attempt < maxRetries; attempt++ {
-		if strings.HasPrefix(endpoint, c.baseURL) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.checkDeadline(endpoint); err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(endpoint, c.baseURL) || c.isOverrideURL(endpoint) {
 			fullURL = endpoint
 		} else {
 			endpointPath := endpoint
@@ -115,7 +210,7 @@ func (c *Client) makeRequest(method, endpoint string, v interface{}) error {
 				queryParams = parts[1]
 			}
 
-			baseURL := strings.TrimSuffix(c.baseURL, "/")
+			baseURL := c.baseURLFor(endpointPath)
 			endpointPath = strings.TrimPrefix(endpointPath, "/")
 
 			// Build the full URL
@@ -132,25 +227,20 @@ func (c *Client) makeRequest(method, endpoint string, v interface{}) error {
 				zap.String("url", fullURL))
 		}
 
-		req, err := http.NewRequest(method, fullURL, nil)
+		req, err := http.NewRequestWithContext(ctx, method, fullURL, nil)
 		if err != nil {
 			return err
 		}
 
-		if c.accessToken != "" {
-			req.Header.Set("Authorization", "Bearer "+c.accessToken)
-		} else if c.apiToken != "" {
-			if c.email != "" {
-				req.SetBasicAuth(c.email, c.apiToken)
-			} else {
-				req.SetBasicAuth("x-bitbucket-api-token-auth", c.apiToken)
-			}
-		} else if c.username != "" && c.appPass != "" {
-			req.SetBasicAuth(c.username, c.appPass)
+		if err := c.ensureOAuthToken(ctx); err != nil {
+			return fmt.Errorf("%s: %w", endpoint, err)
 		}
-
+		tokenGeneration := c.tokenGeneration()
+		authMethod := c.authMethodFor(scope)
+		c.setAuthHeaderFor(req, authMethod)
 		req.Header.Set("Content-Type", "application/json")
 
+		c.throttle()
 		resp, err := c.httpClient.Do(req)
 		if err != nil {
 			return err
@@ -168,6 +258,9 @@ func (c *Client) makeRequest(method, endpoint string, v interface{}) error {
 			remainingInt, _ := strconv.Atoi(remaining)
 			limitInt, _ := strconv.Atoi(limit)
 			if limitInt > 0 && float64(remainingInt)/float64(limitInt) < 0.1 {
+				c.mu.Lock()
+				c.throttling.lowQuotaWarnings++
+				c.mu.Unlock()
 				c.logger.Warn("Low API rate limit remaining",
 					zap.String("remaining", remaining),
 					zap.String("limit", limit))
@@ -186,24 +279,86 @@ func (c *Client) makeRequest(method, endpoint string, v interface{}) error {
 				delay = 5 * time.Minute // Max delay
 			}
 
+			c.backOff(delay)
 			c.logger.Warn("Rate limit hit - waiting before retrying",
 				zap.Duration("delay", delay),
 				zap.Int("attempt", attempt+1),
 				zap.Int("max_retries", maxRetries))
 
-			time.Sleep(delay)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
 			continue // Retry the request
 		}
 
+		// A revoked OAuth token is replaced with a new grant once per request.
+		if resp.StatusCode == http.StatusUnauthorized && c.usesOAuth() && !refreshed {
+			refreshed = true
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+			if err := c.fetchOAuthToken(ctx, true, tokenGeneration); err != nil {
+				return fmt.Errorf("%s: %w", endpoint, err)
+			}
+			c.mu.Lock()
+			c.throttling.retries++
+			c.mu.Unlock()
+			continue
+		}
+
+		// A short-lived token may expire mid-run; fetch a new one once per
+		// request and retry instead of aborting the export.
+		if resp.StatusCode == http.StatusUnauthorized && c.tokenRefreshCmd != "" && !refreshed && authMethod == 0 {
+			refreshed = true
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+			if err := c.refreshToken(ctx, tokenGeneration); err != nil {
+				return fmt.Errorf("%s: %w", endpoint, err)
+			}
+			c.mu.Lock()
+			c.throttling.retries++
+			c.mu.Unlock()
+			continue
+		}
+
+		// Some scopes only accept certain token types; try the next method
+		// of the --auth-fallback chain for this endpoint.
+		if (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) &&
+			c.fallBackAuth(scope, authMethod, resp.StatusCode) {
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+			continue
+		}
+
 		// If the request was successful, break out of the retry loop
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			return json.NewDecoder(resp.Body).Decode(v)
+			respBody, err := c.limitResponseBody(resp.Body, resp.ContentLength)
+			if err != nil {
+				return fmt.Errorf("%s: %w", endpoint, err)
+			}
+
+			if raw, ok := v.(*[]byte); ok {
+				*raw, err = io.ReadAll(respBody)
+				return err
+			}
+			if !c.schemaValidationEnabled() {
+				return json.NewDecoder(respBody).Decode(v)
+			}
+
+			body, err := io.ReadAll(respBody)
+			if err != nil {
+				return err
+			}
+			if err := json.NewDecoder(bytes.NewReader(body)).Decode(v); err != nil {
+				return err
+			}
+			c.detectSchemaDrift(endpoint, body, v)
+			return nil
 		}
 
 		// Handle other errors
-		bodyBytes, _ := io.ReadAll(resp.Body)
+		errorBody := readErrorBody(resp.Body)
+		c.recordFailedResponse(method, fullURL, resp.StatusCode, errorBody)
 		err = fmt.Errorf("API request failed with status %d: %s: %s",
-			resp.StatusCode, resp.Status, string(bodyBytes))
+			resp.StatusCode, resp.Status, errorBody)
 		c.logger.Error("API request failed", zap.Error(err))
 		return err
 	}
@@ -215,8 +370,9 @@ func (c *Client) GetUsers(workspace, repoSlug string) ([]data.User, error) {
 	c.logger.Info("Fetching workspace members")
 
 	var allUsers []data.User
+	seenLogins := make(map[string]bool)
 	page := 1
-	pageLen := 100
+	pageLen := c.pageLen(100)
 	hasMore := true
 
 	for hasMore {
@@ -232,14 +388,14 @@ func (c *Client) GetUsers(workspace, repoSlug string) ([]data.User, error) {
 			return []data.User{
 				{
 					Type:      "user",
-					URL:       fmt.Sprintf("https://bitbucket.org/%s", workspace),
+					URL:       c.formatURL("user", workspace, ""),
 					Login:     workspace,
 					Name:      workspace,
 					Company:   nil,
 					Website:   nil,
 					Location:  nil,
 					Emails:    []data.Email{},
-					CreatedAt: formatDateToZ(time.Now().Format(time.RFC3339)),
+					CreatedAt: formatDateToZ(c.now().Format(time.RFC3339)),
 				},
 			}, nil
 		}
@@ -247,18 +403,24 @@ func (c *Client) GetUsers(workspace, repoSlug string) ([]data.User, error) {
 		for _, member := range response.Values {
 			user := member.User
 
-			profileURL := fmt.Sprintf("https://bitbucket.org/%s", strings.Trim(user.UUID, "{}"))
+			login := c.userLogin(user.UUID, user.DisplayName)
+			if seenLogins[login] {
+				// Several Bitbucket accounts mapped to one GitHub login.
+				continue
+			}
+			seenLogins[login] = true
+			profileURL := c.formatURL("user", workspace, "", login)
 
 			newUser := data.User{
 				Type:      "user",
 				URL:       profileURL,
-				Login:     strings.Trim(user.UUID, "{}"),
+				Login:     login,
 				Name:      user.DisplayName,
 				Company:   nil,
 				Website:   nil,
 				Location:  nil,
-				Emails:    []data.Email{},
-				CreatedAt: formatDateToZ(time.Now().Format(time.RFC3339)),
+				Emails:    c.userEmails(login),
+				CreatedAt: formatDateToZ(c.now().Format(time.RFC3339)),
 			}
 
 			allUsers = append(allUsers, newUser)
@@ -273,14 +435,14 @@ func (c *Client) GetUsers(workspace, repoSlug string) ([]data.User, error) {
 		c.logger.Warn("No workspace members found, using fallback user")
 		allUsers = append(allUsers, data.User{
 			Type:      "user",
-			URL:       fmt.Sprintf("https://bitbucket.org/%s", workspace),
+			URL:       c.formatURL("user", workspace, ""),
 			Login:     workspace,
 			Name:      workspace,
 			Company:   nil,
 			Website:   nil,
 			Location:  nil,
 			Emails:    []data.Email{},
-			CreatedAt: formatDateToZ(time.Now().Format(time.RFC3339)),
+			CreatedAt: formatDateToZ(c.now().Format(time.RFC3339)),
 		})
 	}
 
@@ -298,7 +460,7 @@ func (c *Client) GetPullRequests(workspace, repoSlug string, openPRsOnly bool, p
 
 	var pullRequests []data.PullRequest
 	page := 1
-	pageLen := 50
+	pageLen := c.pageLen(50)
 	hasMore := true
 
 	var fromDate time.Time
@@ -329,7 +491,22 @@ func (c *Client) GetPullRequests(workspace, repoSlug string, openPRsOnly bool, p
 	skippedAmbiguous := 0
 	skippedByDate := 0
 
+	if resumedPages, resumedPRs, complete := c.progress.pullRequests(repoSlug); resumedPages > 0 {
+		pullRequests = resumedPRs
+		c.logger.Info("Reusing pull requests fetched before the interruption",
+			zap.String("repository", repoSlug),
+			zap.Int("pages", resumedPages),
+			zap.Int("count", len(resumedPRs)))
+		if complete {
+			return pullRequests, nil
+		}
+		page = resumedPages + 1
+	}
+
 	for hasMore {
+		pageStart := len(pullRequests)
+		var pageIDs []int
+		var selected []data.BitbucketPR
 		baseURL, parseErr := url.Parse(fmt.Sprintf("repositories/%s/%s/pullrequests", workspace, repoSlug))
 		if parseErr != nil {
 			c.logger.Error("failed to parse base URL", zap.Error(parseErr))
@@ -372,20 +549,11 @@ func (c *Client) GetPullRequests(workspace, repoSlug string, openPRsOnly bool, p
 
 		for _, pr := range response.Values {
 
-			if hexPatternRegex.MatchString(pr.Source.Branch.Name) {
-				skippedAmbiguous++
-				c.logger.Debug("Skipping PR with ambiguous source branch",
-					zap.Int("pr_id", pr.ID),
-					zap.String("branch_name", pr.Source.Branch.Name))
-				continue
-			}
-
-			if hexPatternRegex.MatchString(pr.Destination.Branch.Name) {
-				skippedAmbiguous++
-				c.logger.Debug("Skipping PR with ambiguous destination branch",
-					zap.Int("pr_id", pr.ID),
-					zap.String("branch_name", pr.Destination.Branch.Name))
-				continue
+			if hexPatternRegex.MatchString(pr.Source.Branch.Name) || hexPatternRegex.MatchString(pr.Destination.Branch.Name) {
+				if !c.recordAmbiguousPR(repoSlug, &pr) {
+					skippedAmbiguous++
+					continue
+				}
 			}
 
 			if fromDateProvided {
@@ -409,77 +577,29 @@ func (c *Client) GetPullRequests(workspace, repoSlug string, openPRsOnly bool, p
 					zap.Time("pr_created_at", prCreatedAt))
 			}
 
-			var mergedAt, closedAt *string
-			switch pr.State {
-			case "MERGED":
-				mergedStr := formatDateToZ(pr.UpdatedOn)
-				mergedAt = &mergedStr
-				closedStr := formatDateToZ(pr.UpdatedOn)
-				closedAt = &closedStr
-			case "DECLINED":
-				closedStr := formatDateToZ(pr.UpdatedOn)
-				closedAt = &closedStr
-			}
-
-			prURL := formatURL("pr", workspace, repoSlug, pr.ID)
-			userURL := formatURL("user", workspace, "", strings.Trim(pr.Author.UUID, "{}"))
-			repoURL := formatURL("repository", workspace, repoSlug)
-			prUser := formatURL("user", workspace, "")
-
-			// Resolve commit SHAs
-			baseSHA, _ := c.GetFullCommitSHA(workspace, repoSlug, pr.Destination.Commit.Hash)
-			headSHA, _ := c.GetFullCommitSHA(workspace, repoSlug, pr.Source.Commit.Hash)
-
-			description := ""
-			if pr.Description != nil {
-				description = *pr.Description
-			}
-
-			// Format merge commit SHA if available
-			var mergeCommitSHA *string
-			if pr.MergeCommit != nil && pr.State == "MERGED" {
-				fullMergeSHA, _ := c.GetFullCommitSHA(workspace, repoSlug, pr.MergeCommit.Hash)
-				mergeCommitSHA = &fullMergeSHA
-			}
+			selected = append(selected, pr)
+		}
 
-			// Create the Pull Request with GitHub-compatible structure
-			pullRequest := data.PullRequest{
-				Type:       "pull_request",
-				URL:        prURL,
-				User:       userURL,
-				Repository: repoURL,
-				Title:      pr.Title,
-				Body:       description,
-				Base: data.PRBranch{
-					Ref:  pr.Destination.Branch.Name,
-					SHA:  baseSHA,
-					User: prUser,
-					Repo: repoURL,
-				},
-				Head: data.PRBranch{
-					Ref:  pr.Source.Branch.Name,
-					SHA:  headSHA,
-					User: prUser,
-					Repo: repoURL,
-				},
-				Labels:               []string{},
-				MergedAt:             mergedAt,
-				ClosedAt:             closedAt,
-				CreatedAt:            formatDateToZ(pr.CreatedOn),
-				Assignee:             nil,
-				Assignees:            []string{},
-				Milestone:            nil,
-				Reactions:            []string{},
-				ReviewRequests:       []string{},
-				CloseIssueReferences: []string{},
-				WorkInProgress:       pr.Draft,
-				MergeCommitSHA:       mergeCommitSHA,
+		// Resolving commit SHAs can take several requests per pull request,
+		// so pull requests of a page are converted concurrently.
+		converted := make([]data.PullRequest, len(selected))
+		c.forEach(len(selected), func(i int) {
+			if cached, ok := c.syncCache.pullRequest(repoSlug, selected[i]); ok {
+				converted[i] = cached
+				return
 			}
-
-			pullRequests = append(pullRequests, pullRequest)
+			converted[i] = c.convertPullRequest(workspace, repoSlug, selected[i])
+			c.syncCache.recordPullRequest(repoSlug, selected[i], converted[i])
+		})
+		pullRequests = append(pullRequests, converted...)
+		for _, pr := range selected {
+			pageIDs = append(pageIDs, pr.ID)
 		}
 
 		hasMore = response.Next != ""
+		if err := c.progress.recordPullRequestPage(repoSlug, page, pullRequests[pageStart:], pageIDs, !hasMore); err != nil {
+			c.logger.Warn("Failed to record pull request page in the checkpoint", zap.Error(err))
+		}
 		if hasMore {
 			page++
 		}
@@ -493,12 +613,88 @@ func (c *Client) GetPullRequests(workspace, repoSlug string, openPRsOnly bool, p
 	return pullRequests, nil
 }
 
+// convertPullRequest turns a Bitbucket pull request into the GitHub
+// migration format, resolving its abbreviated commit SHAs.
+func (c *Client) convertPullRequest(workspace, repoSlug string, pr data.BitbucketPR) data.PullRequest {
+	var mergedAt, closedAt *string
+	switch pr.State {
+	case "MERGED":
+		mergedStr := formatDateToZ(pr.UpdatedOn)
+		mergedAt = &mergedStr
+		closedStr := formatDateToZ(pr.UpdatedOn)
+		closedAt = &closedStr
+	case "DECLINED":
+		closedStr := formatDateToZ(pr.UpdatedOn)
+		closedAt = &closedStr
+	}
+
+	prURL := c.formatURL("pr", workspace, repoSlug, pr.ID)
+	userURL := c.authorURL(workspace, pr.Author)
+	repoURL := c.formatURL("repository", workspace, repoSlug)
+	prUser := c.formatURL("user", workspace, "")
+
+	// Resolve commit SHAs
+	baseSHA, _ := c.GetFullCommitSHA(workspace, repoSlug, pr.Destination.Commit.Hash)
+	headSHA, _ := c.GetFullCommitSHA(workspace, repoSlug, pr.Source.Commit.Hash)
+
+	description := ""
+	if pr.Description != nil {
+		description = *pr.Description
+	}
+	description = c.prBody(c.redact(description), pr, workspace, repoSlug)
+
+	// Format merge commit SHA if available
+	var mergeCommitSHA *string
+	if pr.MergeCommit != nil && pr.State == "MERGED" {
+		fullMergeSHA, _ := c.GetFullCommitSHA(workspace, repoSlug, pr.MergeCommit.Hash)
+		mergeCommitSHA = &fullMergeSHA
+	}
+
+	// Create the Pull Request with GitHub-compatible structure
+	pullRequest := data.PullRequest{
+		Type:       "pull_request",
+		URL:        prURL,
+		User:       userURL,
+		Repository: repoURL,
+		Title:      pr.Title,
+		Body:       description,
+		Base: data.PRBranch{
+			Ref:  pr.Destination.Branch.Name,
+			SHA:  baseSHA,
+			User: prUser,
+			Repo: repoURL,
+		},
+		Head: data.PRBranch{
+			Ref:  pr.Source.Branch.Name,
+			SHA:  headSHA,
+			User: prUser,
+			Repo: repoURL,
+		},
+		Labels:               []string{},
+		MergedAt:             mergedAt,
+		ClosedAt:             closedAt,
+		CreatedAt:            formatDateToZ(pr.CreatedOn),
+		Assignee:             nil,
+		Assignees:            []string{},
+		Milestone:            nil,
+		Reactions:            []data.Reaction{},
+		ReviewRequests:       []string{},
+		CloseIssueReferences: []string{},
+		WorkInProgress:       pr.Draft,
+		MergeCommitSHA:       mergeCommitSHA,
+	}
+
+	return pullRequest
+}
+
 func (c *Client) GetFullCommitSHA(workspace, repoSlug, commitHash string) (string, error) {
-	if len(commitHash) == 40 {
+	// Some Bitbucket Server endpoints return commit IDs in upper case.
+	commitHash = strings.ToLower(commitHash)
+	if isFullCommitSHA(commitHash) {
 		return commitHash, nil
 	}
 
-	if fullSHA, exists := c.commitSHACache[commitHash]; exists {
+	if fullSHA, exists := c.cachedCommitSHA(commitHash); exists {
 		return fullSHA, nil
 	}
 
@@ -508,7 +704,7 @@ func (c *Client) GetFullCommitSHA(workspace, repoSlug, commitHash string) (strin
 		fullSHA, err := GetFullCommitSHAFromLocalRepo(repoPath, commitHash)
 		if err == nil {
 			// Cache the result
-			c.commitSHACache[commitHash] = fullSHA
+			c.cacheCommitSHA(commitHash, fullSHA)
 			c.logger.Debug("Resolved full SHA from local repository",
 				zap.String("shortSHA", commitHash),
 				zap.String("fullSHA", fullSHA))
@@ -527,7 +723,7 @@ func (c *Client) GetFullCommitSHA(workspace, repoSlug, commitHash string) (strin
 			zap.String("sha", commitHash),
 			zap.Int("sha_length", len(commitHash)),
 			zap.String("impact", "This may cause failures if full SHA required"))
-		c.commitSHACache[commitHash] = commitHash
+		c.cacheCommitSHA(commitHash, commitHash)
 		return commitHash, nil
 	}
 
@@ -542,9 +738,9 @@ func (c *Client) GetFullCommitSHA(workspace, repoSlug, commitHash string) (strin
 		return commitHash, fmt.Errorf("failed to fetch full commit SHA: %w", err)
 	}
 
-	if len(response.Hash) == 40 {
-		c.commitSHACache[commitHash] = response.Hash
-		return response.Hash, nil
+	if fullSHA := strings.ToLower(response.Hash); isFullCommitSHA(fullSHA) {
+		c.cacheCommitSHA(commitHash, fullSHA)
+		return fullSHA, nil
 	}
 
 	return commitHash, nil
@@ -556,7 +752,7 @@ func (c *Client) GetPullRequestComments(workspace, repoSlug string, pullRequests
 	var regularComments []data.IssueComment
 	var reviewComments []data.PullRequestReviewComment
 
-	prURLMap := make(map[int]string)
+	var prIDs []int
 	prCommitMap := make(map[int]string)
 
 	for _, pr := range pullRequests {
@@ -564,158 +760,217 @@ func (c *Client) GetPullRequestComments(workspace, repoSlug string, pullRequests
 		if len(parts) > 0 {
 			prID, err := strconv.Atoi(parts[len(parts)-1])
 			if err == nil {
-				prURLMap[prID] = pr.URL
+				if _, seen := prCommitMap[prID]; !seen {
+					prIDs = append(prIDs, prID)
+				}
 				prCommitMap[prID] = pr.Head.SHA
 			}
 		}
 	}
 
-	resolvedSHAs := make(map[int]bool)
 	failedPRs := 0
 
-	for prID := range prURLMap {
-		page := 1
-		pageLen := 100
-		hasMore := true
+	cached := c.progress.comments(repoSlug)
+	if len(cached) > 0 {
+		c.logger.Info("Reusing comments fetched before the interruption",
+			zap.String("repository", repoSlug),
+			zap.Int("pull_requests", len(cached)))
+	}
+	defer c.progress.flush()
+
+	// Comments are collected per pull request and flattened in the order of
+	// pullRequests, as workers finish in any order.
+	byPR := make([]pullRequestComments, len(prIDs))
+	var pending []int
+	for i, prID := range prIDs {
+		comments, ok := cached[prID]
+		if !ok {
+			comments, ok = c.syncCache.comments(repoSlug, prID)
+		}
+		if ok {
+			byPR[i] = comments
+			c.progressEvents.advance(1)
+			continue
+		}
+		pending = append(pending, i)
+	}
 
-		for hasMore {
-			baseEndpoint := fmt.Sprintf("repositories/%s/%s/pullrequests/%d/comments",
-				workspace, repoSlug, prID)
+	// Workers fetch pull requests concurrently; recording their comments in
+	// the checkpoint is serialized.
+	var mu sync.Mutex
+	c.forEach(len(pending), func(i int) {
+		index := pending[i]
+		prID := prIDs[index]
+		regular, review, err := c.fetchPullRequestComments(workspace, repoSlug, prID, prCommitMap[prID])
+		defer c.progressEvents.advance(1)
+
+		mu.Lock()
+		defer mu.Unlock()
+		byPR[index] = pullRequestComments{PullRequest: prID, IssueComments: regular, ReviewComments: review}
+		if err != nil {
+			c.logger.Warn("Failed to fetch PR comments",
+				zap.Int("pr_id", prID),
+				zap.Error(err))
+			failedPRs++
+			return
+		}
+		c.syncCache.recordComments(repoSlug, prID, regular, review)
+		if err := c.progress.recordComments(repoSlug, prID, regular, review); err != nil {
+			c.logger.Warn("Failed to record pull request comments in the checkpoint", zap.Error(err))
+		}
+	})
 
-			params := url.Values{}
-			params.Add("q", "deleted=false")
-			params.Add("page", strconv.Itoa(page))
-			params.Add("pagelen", strconv.Itoa(pageLen))
+	for _, comments := range byPR {
+		regularComments = append(regularComments, comments.IssueComments...)
+		reviewComments = append(reviewComments, comments.ReviewComments...)
+	}
 
-			endpoint := baseEndpoint + "?" + params.Encode()
+	c.logger.Info("Pull request comments fetched",
+		zap.Int("regular_comments", len(regularComments)),
+		zap.Int("review_comments", len(reviewComments)),
+		zap.Int("failed_prs", failedPRs))
 
-			var response data.BitbucketCommentResponse
+	return regularComments, reviewComments, nil
+}
 
-			err := c.makeRequest("GET", endpoint, &response)
-			if err != nil {
-				c.logger.Warn("Failed to fetch PR comments",
-					zap.Int("pr_id", prID),
-					zap.Error(err))
-				break
-			}
+// fetchPullRequestComments fetches the comments of one pull request. The
+// comments of the pages fetched before an error are returned with it.
+func (c *Client) fetchPullRequestComments(workspace, repoSlug string, prID int, headSHA string) ([]data.IssueComment, []data.PullRequestReviewComment, error) {
+	var regularComments []data.IssueComment
+	var reviewComments []data.PullRequestReviewComment
+	commitSHA := headSHA
+	resolvedSHA := false
 
-			for _, comment := range response.Values {
-				createdAt := formatDateToZ(comment.CreatedOn)
-				updatedAt := formatDateToZ(comment.UpdatedOn)
-				transformedBody := c.transformCommentBody(comment.Content.Raw, workspace, repoSlug)
-				prNumber := fmt.Sprintf("%d", prID)
-
-				if comment.Inline != nil && comment.Inline.Path != "" {
-					if !resolvedSHAs[prID] {
-						shortSHA := prCommitMap[prID]
-						fullSHA, err := c.GetFullCommitSHA(workspace, repoSlug, shortSHA)
-						if err != nil {
-							c.logger.Warn("Failed to resolve full SHA for PR",
-								zap.Int("pr_id", prID),
-								zap.String("short_sha", shortSHA),
-								zap.Error(err))
-							fullSHA = shortSHA
-						}
-						prCommitMap[prID] = fullSHA
-						resolvedSHAs[prID] = true
+	page := 1
+	pageLen := c.pageLen(100)
+	hasMore := true
 
-					}
+	for hasMore {
+		baseEndpoint := fmt.Sprintf("repositories/%s/%s/pullrequests/%d/comments",
+			workspace, repoSlug, prID)
 
-					lineNumber := 1
-					if comment.Inline.To != nil {
-						lineNumber = *comment.Inline.To
-					} else if comment.Inline.From != nil {
-						lineNumber = *comment.Inline.From
-					}
+		params := url.Values{}
+		params.Add("q", "deleted=false")
+		params.Add("page", strconv.Itoa(page))
+		params.Add("pagelen", strconv.Itoa(pageLen))
 
-					// Create a unique thread identifier based on the file path and line number
-					// rather than the comment ID
-					threadKey := fmt.Sprintf("%s-%s-%d", workspace, comment.Inline.Path, lineNumber)
-					threadId := fmt.Sprintf("thread-%s", HashString(threadKey))
-
-					// Generate stable comment ID
-					commentId := fmt.Sprintf("%d", comment.ID)
-
-					// Handle parent-child relationship
-					var inReplyTo *string
-					var reviewId string
-
-					if comment.Parent != nil {
-						// This is a reply - use parent's ID for the review ID
-						parentId := fmt.Sprintf("%d", comment.Parent.ID)
-						inReplyTo = &parentId
-						reviewId = fmt.Sprintf("review-%d", comment.Parent.ID)
-					} else {
-						// This is a top-level comment - use its own ID for the review ID
-						reviewId = fmt.Sprintf("review-%d", comment.ID)
-					}
+		endpoint := baseEndpoint + "?" + params.Encode()
+
+		var response data.BitbucketCommentResponse
 
-					commentURL := formatURL("pr_review_comment", workspace, repoSlug, prNumber, commentId)
-					reviewURL := formatURL("pr_review", workspace, repoSlug, prNumber, reviewId)
-					threadURL := formatURL("pr_review_thread", workspace, repoSlug, prNumber, threadId)
-					prFullURL := formatURL("pr", workspace, repoSlug, prNumber)
-					userURL := formatURL("user", workspace, "", strings.Trim(comment.User.UUID, "{}"))
-					commitSHA := prCommitMap[prID]
-
-					// Create diff hunk
-					diffHunk := fmt.Sprintf("@@ -0,0 +1,%d @@\n+%s", lineNumber, transformedBody)
-
-					// Create review comment with correct format
-					reviewComment := data.PullRequestReviewComment{
-						Type:                    "pull_request_review_comment",
-						URL:                     commentURL,
-						PullRequest:             prFullURL,
-						PullRequestReview:       reviewURL,
-						PullRequestReviewThread: threadURL,
-						User:                    userURL,
-						CommitID:                commitSHA,
-						OriginalCommitId:        commitSHA,
-						Path:                    comment.Inline.Path,
-						Position:                lineNumber,
-						OriginalPosition:        lineNumber,
-						Body:                    transformedBody,
-						CreatedAt:               createdAt,
-						UpdatedAt:               updatedAt,
-						Formatter:               "markdown",
-						DiffHunk:                diffHunk,
-						State:                   1,
-						InReplyTo:               inReplyTo,
-						Reactions:               []string{},
-						SubjectType:             "line",
+		if err := c.makeRequest("GET", endpoint, &response); err != nil {
+			return regularComments, reviewComments, err
+		}
+
+		for _, comment := range response.Values {
+			createdAt := formatDateToZ(comment.CreatedOn)
+			updatedAt := formatDateToZ(comment.UpdatedOn)
+			transformedBody := c.redact(c.commentBody(comment, workspace, repoSlug))
+			body := c.commentBodyWithFooter(transformedBody, comment, workspace, repoSlug, prID)
+			prNumber := fmt.Sprintf("%d", prID)
+
+			if comment.Inline != nil && comment.Inline.Path != "" {
+				if !resolvedSHA {
+					fullSHA, err := c.GetFullCommitSHA(workspace, repoSlug, headSHA)
+					if err != nil {
+						c.logger.Warn("Failed to resolve full SHA for PR",
+							zap.Int("pr_id", prID),
+							zap.String("short_sha", headSHA),
+							zap.Error(err))
+						fullSHA = headSHA
 					}
+					commitSHA = fullSHA
+					resolvedSHA = true
+				}
+
+				lineNumber := 1
+				if comment.Inline.To != nil {
+					lineNumber = *comment.Inline.To
+				} else if comment.Inline.From != nil {
+					lineNumber = *comment.Inline.From
+				}
+
+				// Create a unique thread identifier based on the file path and line number
+				// rather than the comment ID
+				threadKey := fmt.Sprintf("%s-%s-%d", workspace, comment.Inline.Path, lineNumber)
+				threadId := fmt.Sprintf("thread-%s", HashString(threadKey))
+
+				// Generate stable comment ID
+				commentId := fmt.Sprintf("%d", comment.ID)
+
+				// Handle parent-child relationship
+				var inReplyTo *string
+				var reviewId string
 
-					reviewComments = append(reviewComments, reviewComment)
+				if comment.Parent != nil {
+					// This is a reply - use parent's ID for the review ID
+					parentId := fmt.Sprintf("%d", comment.Parent.ID)
+					inReplyTo = &parentId
+					reviewId = fmt.Sprintf("review-%d", comment.Parent.ID)
 				} else {
-					commentURL := formatURL("issue_comment", workspace, repoSlug, prNumber, comment.ID)
-					prURL := formatURL("pr", workspace, repoSlug, prNumber)
-					userURL := formatURL("user", workspace, "", strings.Trim(comment.User.UUID, "{}"))
-
-					regularComment := data.IssueComment{
-						Type:        "issue_comment",
-						URL:         commentURL,
-						User:        userURL,
-						Body:        transformedBody,
-						CreatedAt:   createdAt,
-						Formatter:   "markdown",
-						Reactions:   []string{},
-						PullRequest: prURL,
-					}
+					// This is a top-level comment - use its own ID for the review ID
+					reviewId = fmt.Sprintf("review-%d", comment.ID)
+				}
 
-					regularComments = append(regularComments, regularComment)
+				commentURL := c.formatURL("pr_review_comment", workspace, repoSlug, prNumber, commentId)
+				reviewURL := c.formatURL("pr_review", workspace, repoSlug, prNumber, reviewId)
+				threadURL := c.formatURL("pr_review_thread", workspace, repoSlug, prNumber, threadId)
+				prFullURL := c.formatURL("pr", workspace, repoSlug, prNumber)
+				userURL := c.authorURL(workspace, comment.User)
+
+				// Create diff hunk
+				diffHunk := fmt.Sprintf("@@ -0,0 +1,%d @@\n+%s", lineNumber, transformedBody)
+
+				// Create review comment with correct format
+				reviewComment := data.PullRequestReviewComment{
+					Type:                    "pull_request_review_comment",
+					URL:                     commentURL,
+					PullRequest:             prFullURL,
+					PullRequestReview:       reviewURL,
+					PullRequestReviewThread: threadURL,
+					User:                    userURL,
+					CommitID:                commitSHA,
+					OriginalCommitId:        commitSHA,
+					Path:                    comment.Inline.Path,
+					Position:                lineNumber,
+					OriginalPosition:        lineNumber,
+					Body:                    body,
+					CreatedAt:               createdAt,
+					UpdatedAt:               updatedAt,
+					Formatter:               "markdown",
+					DiffHunk:                diffHunk,
+					State:                   reviewCommentState(comment),
+					InReplyTo:               inReplyTo,
+					Reactions:               []string{},
+					SubjectType:             "line",
+				}
+
+				reviewComments = append(reviewComments, reviewComment)
+			} else {
+				commentURL := c.formatURL("issue_comment", workspace, repoSlug, prNumber, comment.ID)
+				prURL := c.formatURL("pr", workspace, repoSlug, prNumber)
+				userURL := c.authorURL(workspace, comment.User)
+
+				regularComment := data.IssueComment{
+					Type:        "issue_comment",
+					URL:         commentURL,
+					User:        userURL,
+					Body:        body,
+					CreatedAt:   createdAt,
+					Formatter:   "markdown",
+					Reactions:   []string{},
+					PullRequest: prURL,
 				}
-			}
 
-			hasMore = response.Next != ""
-			if hasMore {
-				page++
+				regularComments = append(regularComments, regularComment)
 			}
 		}
-	}
 
-	c.logger.Info("Pull request comments fetched",
-		zap.Int("regular_comments", len(regularComments)),
-		zap.Int("review_comments", len(reviewComments)),
-		zap.Int("failed_prs", failedPRs))
+		hasMore = response.Next != ""
+		if hasMore {
+			page++
+		}
+	}
 
 	return regularComments, reviewComments, nil
 }
@@ -727,16 +982,15 @@ func (c *Client) transformCommentBody(body, workspace, repoSlug string) string {
 
 	pattern := fmt.Sprintf("https://bitbucket.org/%s/%s/pull-requests/(\\d+)",
 		regexp.QuoteMeta(workspace), regexp.QuoteMeta(repoSlug))
-	replacement := fmt.Sprintf("https://bitbucket.org/%s/%s/pull/$1",
-		workspace, repoSlug)
+	replacement := c.formatURL("pr", workspace, repoSlug, "${1}")
 
 	re := regexp.MustCompile(pattern)
 	transformedBody := re.ReplaceAllString(body, replacement)
+	transformedBody = c.rewriteSourceLinks(transformedBody, workspace, repoSlug)
 
 	transformedBody = prNumberPattern.ReplaceAllStringFunc(transformedBody, func(match string) string {
 		numStr := match[1:] // Remove the # prefix
-		return fmt.Sprintf("[%s](%s)", match, fmt.Sprintf("https://bitbucket.org/%s/%s/pull/%s",
-			workspace, repoSlug, numStr))
+		return fmt.Sprintf("[%s](%s)", match, c.formatURL("pr", workspace, repoSlug, numStr))
 	})
 
 	return transformedBody
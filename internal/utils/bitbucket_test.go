@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -701,7 +702,7 @@ func TestGetPullRequestCommentsWithThreads(t *testing.T) {
 	assert.Equal(t, "123", *reviewComments[1].InReplyTo, "Reply should reference parent ID")
 
 	// Check review IDs - Updated to match the actual format used in the code
-	expectedReviewURL := formatURL("pr_review", "workspace", "repo", "1", "review-123")
+	expectedReviewURL := format.URL("pr_review", "workspace", "repo", "1", "review-123")
 	assert.Equal(t, expectedReviewURL, reviewComments[0].PullRequestReview, "Parent comment should use own ID for review")
 	assert.Equal(t, expectedReviewURL, reviewComments[1].PullRequestReview, "Reply should use parent ID for review")
 }
@@ -906,6 +907,11 @@ func TestGetFullCommitSHAFastPaths(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, full, got)
 
+	// Upper-case SHAs are lowercased rather than looked up
+	got, err = client.GetFullCommitSHA("ws", "repo", strings.Repeat("A", 40))
+	assert.NoError(t, err)
+	assert.Equal(t, full, got)
+
 	// 2) Cache hit (no network)
 	client.commitSHACache["abc123"] = strings.Repeat("b", 40)
 	got, err = client.GetFullCommitSHA("ws", "repo", "abc123")
@@ -919,7 +925,7 @@ func TestGetFullCommitSHAWithAPICall(t *testing.T) {
 	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Return full SHA for short SHA
 		w.WriteHeader(http.StatusOK)
-		writeResponse(t, w, []byte(`{"hash": "1234567890abcdef1234567890abcdef12345678"}`))
+		writeResponse(t, w, []byte(`{"hash": "1234567890ABCDEF1234567890abcdef12345678"}`))
 	}))
 	defer testServer.Close()
 
@@ -932,14 +938,14 @@ func TestGetFullCommitSHAWithAPICall(t *testing.T) {
 	}
 
 	// Test with short SHA that needs API call
-	shortSHA := "abc123"
+	shortSHA := "ABC123"
 	fullSHA, err := client.GetFullCommitSHA("workspace", "repo", shortSHA)
 
 	assert.NoError(t, err)
 	assert.Equal(t, "1234567890abcdef1234567890abcdef12345678", fullSHA)
 
-	// Verify it was cached
-	assert.Equal(t, fullSHA, client.commitSHACache[shortSHA])
+	// Verify it was cached under the lowercased SHA
+	assert.Equal(t, fullSHA, client.commitSHACache["abc123"])
 
 	// Second call should use cache (server won't be called)
 	cachedSHA, err := client.GetFullCommitSHA("workspace", "repo", shortSHA)
@@ -1034,6 +1040,47 @@ func TestGetPullRequestCommentsConcurrent(t *testing.T) {
 	t.Logf("Fetching comments for %d PRs took %v", len(prs), elapsed)
 }
 
+func TestGetPullRequestCommentsStableOrder(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var prID int
+		_, _ = fmt.Sscanf(r.URL.Path, "/repositories/workspace/repo/pullrequests/%d/comments", &prID)
+		// Later pull requests answer first.
+		time.Sleep(time.Duration(6-prID) * 20 * time.Millisecond)
+		writeResponse(t, w, []byte(fmt.Sprintf(`{"values": [
+			{"id": %d, "content": {"raw": "first"}, "created_on": "2023-01-01T12:00:00Z", "user": {"uuid": "{test-uuid}"}},
+			{"id": %d, "content": {"raw": "second"}, "created_on": "2023-01-01T12:00:00Z", "user": {"uuid": "{test-uuid}"}}]}`,
+			prID*10, prID*10+1)))
+	}))
+	defer testServer.Close()
+
+	client := &Client{
+		baseURL:        testServer.URL,
+		httpClient:     testServer.Client(),
+		logger:         zap.NewNop(),
+		commitSHACache: make(map[string]string),
+		concurrency:    5,
+	}
+	prs := []data.PullRequest{}
+	for i := 1; i <= 5; i++ {
+		prs = append(prs, data.PullRequest{
+			URL:  fmt.Sprintf("https://bitbucket.org/workspace/repo/pull/%d", i),
+			Head: data.PRBranch{SHA: "abc123"},
+		})
+	}
+
+	regularComments, _, err := client.GetPullRequestComments("workspace", "repo", prs)
+	require.NoError(t, err)
+
+	var order []string
+	for _, comment := range regularComments {
+		order = append(order, comment.PullRequest[strings.LastIndex(comment.PullRequest, "/")+1:]+":"+comment.Body)
+	}
+	assert.Equal(t, []string{
+		"1:first", "1:second", "2:first", "2:second", "3:first", "3:second",
+		"4:first", "4:second", "5:first", "5:second",
+	}, order, "comments follow the order of the pull requests, not of the responses")
+}
+
 func TestExportUpdatesClientExportDir(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "export-dir-test-")
 	assert.NoError(t, err)
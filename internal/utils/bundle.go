@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// GitOutputMirror stores each repository as a bare mirror, the layout
+	// GitHub Enterprise Importer reads.
+	GitOutputMirror = "mirror"
+	// GitOutputBundle stores each repository as a single git bundle instead
+	// of the mirror. Such archives are for manual pushes and cannot be
+	// imported by GitHub Enterprise Importer.
+	GitOutputBundle = "bundle"
+	// GitOutputBoth stores the bundle next to the mirror.
+	GitOutputBoth = "both"
+)
+
+// ValidateGitOutput checks the --git-output value.
+func ValidateGitOutput(value string) error {
+	switch value {
+	case "", GitOutputMirror, GitOutputBundle, GitOutputBoth:
+		return nil
+	}
+	return fmt.Errorf("invalid value for --git-output: %q (supported: %s, %s, %s)",
+		value, GitOutputMirror, GitOutputBundle, GitOutputBoth)
+}
+
+func (e *Exporter) gitOutput() string {
+	if e.flags == nil || e.flags.GitOutput == "" {
+		return GitOutputMirror
+	}
+	return e.flags.GitOutput
+}
+
+// writeGitBundles bundles every cloned repository with all its refs into
+// repositories/<workspace>/<repo>.bundle and checks the bundle with
+// git bundle verify. With --git-output bundle the mirror is removed once its
+// bundle is verified; repositories without refs cannot be bundled and keep
+// their mirror.
+func (e *Exporter) writeGitBundles(workspace string, repoSlugs []string) error {
+	output := e.gitOutput()
+	if output == GitOutputMirror {
+		return nil
+	}
+
+	for _, repoSlug := range repoSlugs {
+		repoPath := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+		bundlePath := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".bundle"))
+		if !hasRefs(repoPath) {
+			e.logger.Warn("Repository has no refs to bundle; keeping its mirror",
+				zap.String("repository", repoSlug))
+			continue
+		}
+
+		ctx, cancel := e.deadlineContext()
+		err := createGitBundle(ctx, repoPath, bundlePath)
+		cancel()
+		if err != nil {
+			if deadlineErr := e.checkDeadline(stageArchive); deadlineErr != nil {
+				return deadlineErr
+			}
+			return fmt.Errorf("failed to bundle %s: %w", repoSlug, err)
+		}
+		e.report.Counts.GitBundles++
+		e.logger.Info("Created git bundle",
+			zap.String("repository", repoSlug),
+			zap.String("path", bundlePath))
+
+		if output == GitOutputBundle {
+			if err := os.RemoveAll(repoPath); err != nil {
+				return fmt.Errorf("failed to remove the mirror of %s: %w", repoSlug, err)
+			}
+		}
+	}
+
+	if output == GitOutputBundle {
+		e.logger.Warn("The archive holds git bundles instead of bare mirrors and cannot be imported by GitHub Enterprise Importer; use --git-output both to keep the mirrors")
+	}
+	return nil
+}
+
+// createGitBundle writes a bundle of all refs of a repository and verifies
+// it. A bundle that fails verification, or is interrupted by ctx, is removed.
+func createGitBundle(ctx context.Context, repoPath, bundlePath string) error {
+	cmd := exec.CommandContext(ctx, "git", "bundle", "create", bundlePath, "--all")
+	cmd.Dir = repoPath
+	if output, err := cmd.CombinedOutput(); err != nil {
+		_ = os.Remove(bundlePath)
+		return fmt.Errorf("git bundle create: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	verify := exec.CommandContext(ctx, "git", "bundle", "verify", "--quiet", bundlePath)
+	verify.Dir = repoPath
+	if output, err := verify.CombinedOutput(); err != nil {
+		_ = os.Remove(bundlePath)
+		return fmt.Errorf("git bundle verify: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// hasRefs reports whether a repository has at least one ref.
+func hasRefs(repoPath string) bool {
+	cmd := exec.Command("git", "for-each-ref", "--count=1", "--format=%(refname)")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	return err == nil && strings.TrimSpace(string(output)) != ""
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidateGitOutput(t *testing.T) {
+	for _, value := range []string{"", GitOutputMirror, GitOutputBundle, GitOutputBoth} {
+		assert.NoError(t, ValidateGitOutput(value))
+	}
+	assert.ErrorContains(t, ValidateGitOutput("tarball"), `invalid value for --git-output: "tarball"`)
+}
+
+func bundleFixture(t *testing.T, output string) (*Exporter, string) {
+	t.Helper()
+	outputDir := t.TempDir()
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "README.md"), []byte("hello\n"), 0644))
+	runGit(t, workDir, "add", "-A")
+	runGit(t, workDir, "commit", "-m", "initial")
+	runGit(t, workDir, "tag", "v1")
+	reposDir := filepath.Join(outputDir, "repositories", "workspace")
+	runGit(t, t.TempDir(), "clone", "--mirror", workDir, filepath.Join(reposDir, "repo.git"))
+	runGit(t, t.TempDir(), "init", "--bare", filepath.Join(reposDir, "empty.git"))
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.flags = &data.CmdExportFlags{GitOutput: output}
+	return exporter, reposDir
+}
+
+func TestWriteGitBundles(t *testing.T) {
+	t.Run("mirror writes no bundles", func(t *testing.T) {
+		exporter, reposDir := bundleFixture(t, "")
+		require.NoError(t, exporter.writeGitBundles("workspace", []string{"repo"}))
+		assert.NoFileExists(t, filepath.Join(reposDir, "repo.bundle"))
+	})
+
+	t.Run("both keeps the mirror", func(t *testing.T) {
+		exporter, reposDir := bundleFixture(t, GitOutputBoth)
+		require.NoError(t, exporter.writeGitBundles("workspace", []string{"repo", "empty"}))
+
+		bundle := filepath.Join(reposDir, "repo.bundle")
+		require.FileExists(t, bundle)
+		assert.DirExists(t, filepath.Join(reposDir, "repo.git"))
+		heads := runGit(t, reposDir, "bundle", "list-heads", bundle)
+		assert.Contains(t, heads, "refs/heads/main")
+		assert.Contains(t, heads, "refs/tags/v1")
+		assert.NoFileExists(t, filepath.Join(reposDir, "empty.bundle"), "repositories without refs are not bundled")
+		assert.Equal(t, 1, exporter.report.Counts.GitBundles)
+	})
+
+	t.Run("bundle replaces the mirror", func(t *testing.T) {
+		exporter, reposDir := bundleFixture(t, GitOutputBundle)
+		require.NoError(t, exporter.writeGitBundles("workspace", []string{"repo", "empty"}))
+
+		assert.FileExists(t, filepath.Join(reposDir, "repo.bundle"))
+		assert.NoDirExists(t, filepath.Join(reposDir, "repo.git"))
+		assert.DirExists(t, filepath.Join(reposDir, "empty.git"), "a repository that cannot be bundled keeps its mirror")
+	})
+}
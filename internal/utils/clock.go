@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Clock supplies the timestamps stamped onto generated records.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock always returns the same instant, so re-exports of unchanged
+// data produce identical metadata.
+type FixedClock struct {
+	Time time.Time
+}
+
+func (c FixedClock) Now() time.Time {
+	return c.Time
+}
+
+// FixedTimestamp returns the instant used by --fixed-timestamps: the
+// SOURCE_DATE_EPOCH environment variable when set, otherwise the Unix epoch.
+func FixedTimestamp() (time.Time, error) {
+	epoch := os.Getenv("SOURCE_DATE_EPOCH")
+	if epoch == "" {
+		return time.Unix(0, 0).UTC(), nil
+	}
+	seconds, err := strconv.ParseInt(epoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: must be a Unix timestamp in seconds", epoch)
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// SetClock replaces the clock used for generated timestamps.
+func (c *Client) SetClock(clock Clock) {
+	c.clock = clock
+}
+
+// now returns the current time of the configured clock. Deadlines, report
+// timings and rate limiting always use the real time.
+func (c *Client) now() time.Time {
+	if c == nil || c.clock == nil {
+		return systemClock{}.Now()
+	}
+	return c.clock.Now()
+}
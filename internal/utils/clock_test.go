@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestFixedTimestamp(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "")
+	timestamp, err := FixedTimestamp()
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(0, 0).UTC(), timestamp)
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	timestamp, err = FixedTimestamp()
+	require.NoError(t, err)
+	assert.Equal(t, "2023-11-14T22:13:20Z", timestamp.Format(time.RFC3339))
+
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	_, err = FixedTimestamp()
+	assert.ErrorContains(t, err, "invalid SOURCE_DATE_EPOCH")
+}
+
+func TestClientClock(t *testing.T) {
+	var nilClient *Client
+	assert.WithinDuration(t, time.Now(), nilClient.now(), time.Minute)
+
+	client := &Client{logger: zap.NewNop()}
+	require.NoError(t, ConfigureClient(client, &data.CmdExportFlags{}))
+	assert.Nil(t, client.clock)
+
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	require.NoError(t, ConfigureClient(client, &data.CmdExportFlags{FixedTimestamps: true}))
+	assert.Equal(t, int64(1700000000), client.now().Unix())
+}
+
+func TestFixedTimestampsReproducibleRecords(t *testing.T) {
+	fixed := FixedClock{Time: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+
+	newExporter := func(outputDir string) *Exporter {
+		client := &Client{logger: zap.NewNop()}
+		client.SetClock(fixed)
+		return NewExporter(client, outputDir, zap.NewNop(), false, "")
+	}
+
+	first := newExporter(t.TempDir()).createBasicUsers("ws")
+	second := newExporter(t.TempDir()).createBasicUsers("ws")
+	assert.Equal(t, first, second)
+	assert.Equal(t, "2024-05-01T12:00:00Z", first[0].CreatedAt)
+
+	outputDir := t.TempDir()
+	exporter := newExporter(outputDir)
+	require.NoError(t, exporter.createRepositoryInfoFiles("ws", "repo"))
+	lastSync, err := os.ReadFile(filepath.Join(outputDir, "repositories", "ws", "repo.git", "info", "last-sync"))
+	require.NoError(t, err)
+	assert.Equal(t, "2024-05-01T12:00:00", string(lastSync))
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// Clone protocols of --clone-protocol. The protocol that is not chosen is
+// tried when cloning with the chosen one fails.
+const (
+	CloneProtocolHTTPS = "https"
+	CloneProtocolSSH   = "ssh"
+)
+
+const (
+	DefaultCloneRetries    = 2
+	DefaultCloneRetryDelay = 5 * time.Second
+
+	maxCloneRetryDelay = 5 * time.Minute
+)
+
+// permanentCloneErrors are git clone failures that retrying the same URL
+// does not fix.
+var permanentCloneErrors = []string{
+	"Authentication failed",
+	"could not read Username",
+	"Permission denied",
+	"Host key verification failed",
+	"not found",
+	"does not appear to be a git repository",
+	"returned error: 401",
+	"returned error: 403",
+}
+
+// ValidateCloneOptions checks --clone-retries, --clone-retry-delay and
+// --clone-protocol.
+func ValidateCloneOptions(cmdFlags *data.CmdExportFlags) error {
+	var problems []error
+	if cmdFlags.CloneRetries < 0 {
+		problems = append(problems, fmt.Errorf("invalid value for --clone-retries: %d (must be 0 or more)", cmdFlags.CloneRetries))
+	}
+	if cmdFlags.CloneRetryDelay < 0 {
+		problems = append(problems, fmt.Errorf("invalid value for --clone-retry-delay: %s (must be 0 or more)", cmdFlags.CloneRetryDelay))
+	}
+	switch cmdFlags.CloneProtocol {
+	case "", CloneProtocolHTTPS, CloneProtocolSSH:
+	default:
+		problems = append(problems, fmt.Errorf("invalid value for --clone-protocol: %q (supported: %s, %s)",
+			cmdFlags.CloneProtocol, CloneProtocolHTTPS, CloneProtocolSSH))
+	}
+	return JoinProblems(problems...)
+}
+
+// SetCloneRetries retries a failed clone of a repository up to retries times
+// per clone URL, waiting delay before the first retry and twice as long
+// before each further one. Failures that a retry does not fix, such as
+// rejected credentials, are not retried.
+func (e *Exporter) SetCloneRetries(retries int, delay time.Duration) {
+	e.cloneRetries = retries
+	e.cloneRetryDelay = delay
+}
+
+// SetCloneProtocol clones repositories over protocol first and falls back to
+// the other of https and ssh when that fails. An empty protocol only uses the
+// https clone URL.
+func (e *Exporter) SetCloneProtocol(protocol string) {
+	e.cloneProtocol = protocol
+}
+
+// SetFailOnCloneError fails the export instead of exporting what was cloned
+// when a repository clone comes back without the branches Bitbucket lists,
+// a wiki cannot be cloned, or the top-up fetch fails.
+func (e *Exporter) SetFailOnCloneError(enabled bool) {
+	e.failOnCloneError = enabled
+}
+
+// sshCloneURL returns the ssh form of an https Bitbucket clone URL, or "" for
+// other URLs.
+func sshCloneURL(httpsURL string) string {
+	parsed, err := url.Parse(httpsURL)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" {
+		return ""
+	}
+	return fmt.Sprintf("git@%s:%s", parsed.Hostname(), strings.TrimPrefix(parsed.Path, "/"))
+}
+
+// cloneURLs returns the URLs to clone a repository from, in the order of
+// --clone-protocol.
+func (e *Exporter) cloneURLs(httpsURL string) []string {
+	sshURL := sshCloneURL(httpsURL)
+	switch {
+	case e.cloneProtocol == "" || sshURL == "":
+		return []string{httpsURL}
+	case e.cloneProtocol == CloneProtocolSSH:
+		return []string{sshURL, httpsURL}
+	}
+	return []string{httpsURL, sshURL}
+}
+
+// transientCloneError reports whether retrying a failed clone may succeed.
+func transientCloneError(err error) bool {
+	message := err.Error()
+	for _, permanent := range permanentCloneErrors {
+		if strings.Contains(message, permanent) {
+			return false
+		}
+	}
+	return true
+}
+
+// cloneMirror clones the repository into tempDir as a bare mirror. Each clone
+// URL is retried with exponential backoff, and when one keeps failing the
+// next is tried.
+func (e *Exporter) cloneMirror(cloneURL, tempDir string) error {
+	candidates := e.cloneURLs(cloneURL)
+	var failures []error
+	for i, candidate := range candidates {
+		err := e.cloneWithRetries(candidate, tempDir)
+		if err == nil {
+			if i > 0 {
+				e.logger.Info("Cloned repository from fallback URL", zap.String("url", candidate))
+			}
+			return nil
+		}
+		if exportAborted(err) {
+			return err
+		}
+		failures = append(failures, err)
+		if i+1 < len(candidates) {
+			e.logger.Warn("Failed to clone repository; trying the next clone URL",
+				zap.String("url", candidate),
+				zap.String("next", candidates[i+1]),
+				zap.Error(err))
+		}
+	}
+	return errors.Join(failures...)
+}
+
+// cloneWithRetries clones cloneURL into tempDir, retrying transient failures.
+func (e *Exporter) cloneWithRetries(cloneURL, tempDir string) error {
+	delay := e.cloneRetryDelay
+	for attempt := 0; ; attempt++ {
+		err := e.cloneOnce(cloneURL, tempDir)
+		if err == nil || exportAborted(err) || attempt >= e.cloneRetries || !transientCloneError(err) {
+			return err
+		}
+
+		e.report.Counts.CloneRetries++
+		e.logger.Warn("Failed to clone repository; retrying",
+			zap.String("url", cloneURL),
+			zap.Int("attempt", attempt+1),
+			zap.Int("max_retries", e.cloneRetries),
+			zap.Duration("delay", delay),
+			zap.Error(err))
+		ctx, cancel := e.deadlineContext()
+		select {
+		case <-ctx.Done():
+			cancel()
+			if deadlineErr := e.checkDeadline(stageGitClone); deadlineErr != nil {
+				return deadlineErr
+			}
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		cancel()
+		delay = min(delay*2, maxCloneRetryDelay)
+
+		// git clone needs an empty directory.
+		if err := os.RemoveAll(tempDir); err != nil {
+			return fmt.Errorf("failed to reset temporary directory: %w", err)
+		}
+		if err := os.MkdirAll(tempDir, 0755); err != nil {
+			return fmt.Errorf("failed to reset temporary directory: %w", err)
+		}
+	}
+}
+
+// cloneOnce runs git clone --mirror once. https URLs use the client's
+// credentials; ssh URLs use the ssh keys of the user running the export.
+func (e *Exporter) cloneOnce(cloneURL, tempDir string) error {
+	env := append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_ASKPASS=",
+		"SSH_ASKPASS=")
+	removeCredentials := func() {}
+	if strings.Contains(cloneURL, "://") {
+		credentialEnv, remove, err := e.client.gitCredentialHelper(cloneURL)
+		if err != nil {
+			return err
+		}
+		removeCredentials = remove
+		env = append(env, e.client.gitNetworkEnv()...)
+		env = append(env, credentialEnv...)
+	} else if os.Getenv("GIT_SSH_COMMAND") == "" {
+		// Fail instead of prompting for a passphrase or an unknown host key.
+		env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+	}
+
+	e.logger.Debug("Cloning repository to temporary directory first", zap.String("url", cloneURL))
+	ctx, cancel := e.deadlineContext()
+	defer cancel()
+	args := append([]string{"clone", "--mirror"}, e.cloneDepthArgs()...)
+	cmd := exec.CommandContext(ctx, "git", append(args, cloneURL, tempDir)...)
+	cmd.Env = env
+
+	output, err := cmd.CombinedOutput()
+	removeCredentials()
+	if err != nil {
+		if deadlineErr := e.checkDeadline(stageGitClone); deadlineErr != nil {
+			return deadlineErr
+		}
+		return fmt.Errorf("failed to clone repository from %s: %s: %w", cloneURL, SanitizeSupportText(string(output)), err)
+	}
+
+	e.logger.Debug("Clone to temporary directory successful",
+		zap.String("output", string(output)))
+	return nil
+}
+
+// checkClonedBranches looks for a clone that came back without branches
+// although Bitbucket lists some. It fails with --fail-on-clone-error and
+// otherwise warns that the repository is exported empty.
+func (e *Exporter) checkClonedBranches(workspace, repoSlug, repoDir string) error {
+	refs, err := mirrorRefs(repoDir)
+	if err != nil {
+		return err
+	}
+	if countBranches(refs) > 0 {
+		return nil
+	}
+	remoteRefs, err := e.client.GetRepositoryRefs(workspace, repoSlug)
+	if err != nil {
+		e.logger.Warn("Clone has no branches and the branches on Bitbucket could not be listed",
+			zap.String("repository", repoSlug),
+			zap.Error(err))
+		return nil
+	}
+	branches := countBranches(remoteRefs)
+	if branches == 0 {
+		return nil
+	}
+	if e.failOnCloneError {
+		return fmt.Errorf("clone of %s/%s has no branches but Bitbucket lists %d (--fail-on-clone-error)",
+			workspace, repoSlug, branches)
+	}
+	e.logger.Warn("Clone has no branches although Bitbucket lists some; the repository is exported empty",
+		zap.String("repository", repoSlug),
+		zap.Int("bitbucket_branches", branches))
+	return nil
+}
+
+// countBranches returns the number of refs/heads entries in refs.
+func countBranches(refs map[string]string) int {
+	count := 0
+	for ref := range refs {
+		if strings.HasPrefix(ref, "refs/heads/") {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidateCloneOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   data.CmdExportFlags
+		wantErr string
+	}{
+		{name: "defaults", flags: data.CmdExportFlags{CloneRetries: DefaultCloneRetries,
+			CloneRetryDelay: DefaultCloneRetryDelay, CloneProtocol: CloneProtocolHTTPS}},
+		{name: "ssh first", flags: data.CmdExportFlags{CloneProtocol: CloneProtocolSSH}},
+		{name: "negative retries", flags: data.CmdExportFlags{CloneRetries: -1}, wantErr: "--clone-retries"},
+		{name: "negative delay", flags: data.CmdExportFlags{CloneRetryDelay: -time.Second}, wantErr: "--clone-retry-delay"},
+		{name: "unknown protocol", flags: data.CmdExportFlags{CloneProtocol: "git"}, wantErr: `--clone-protocol: "git"`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateCloneOptions(&tc.flags)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestCloneURLs(t *testing.T) {
+	httpsURL := "https://bitbucket.org/ws/repo.git"
+	sshURL := "git@bitbucket.org:ws/repo.git"
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+
+	assert.Equal(t, []string{httpsURL}, exporter.cloneURLs(httpsURL), "no fallback unless a protocol is set")
+
+	exporter.SetCloneProtocol(CloneProtocolHTTPS)
+	assert.Equal(t, []string{httpsURL, sshURL}, exporter.cloneURLs(httpsURL))
+
+	exporter.SetCloneProtocol(CloneProtocolSSH)
+	assert.Equal(t, []string{sshURL, httpsURL}, exporter.cloneURLs(httpsURL))
+	assert.Equal(t, []string{"file:///tmp/repo"}, exporter.cloneURLs("file:///tmp/repo"), "only https URLs have an ssh form")
+}
+
+func TestTransientCloneError(t *testing.T) {
+	assert.True(t, transientCloneError(errors.New("fatal: unable to access: Could not resolve host: bitbucket.org")))
+	assert.True(t, transientCloneError(errors.New("error: RPC failed; curl 92 HTTP/2 stream 5 was not closed cleanly")))
+	assert.False(t, transientCloneError(errors.New("fatal: Authentication failed for 'https://bitbucket.org/ws/repo.git/'")))
+	assert.False(t, transientCloneError(errors.New("remote: Repository not found.")))
+	assert.False(t, transientCloneError(errors.New("git@bitbucket.org: Permission denied (publickey).")))
+}
+
+func TestCloneMirrorRetriesTransientFailures(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available for testing")
+	}
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.SetCloneRetries(2, 0)
+
+	err := exporter.cloneMirror("invalid://url", filepath.Join(t.TempDir(), "mirror"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to clone repository")
+	assert.Equal(t, 2, exporter.report.Counts.CloneRetries)
+
+	exporter.report.Counts.CloneRetries = 0
+	err = exporter.cloneMirror("file://"+filepath.Join(t.TempDir(), "missing"), filepath.Join(t.TempDir(), "mirror"))
+	require.Error(t, err)
+	assert.Zero(t, exporter.report.Counts.CloneRetries, "a missing repository is not retried")
+}
+
+func TestCheckClonedBranches(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available for testing")
+	}
+	emptyMirror := t.TempDir()
+	runGit(t, emptyMirror, "init", "--bare", "--quiet")
+
+	refs := `{"values": [{"type": "branch", "name": "main", "target": {"hash": "abc123"}}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repositories/ws/empty/refs" {
+			writeResponse(t, w, []byte(`{"values": []}`))
+			return
+		}
+		writeResponse(t, w, []byte(refs))
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+
+	assert.NoError(t, exporter.checkClonedBranches("ws", "repo", emptyMirror), "without the flag the empty mirror is exported")
+	assert.NoError(t, exporter.checkClonedBranches("ws", "empty", emptyMirror), "the repository is empty on Bitbucket too")
+
+	exporter.SetFailOnCloneError(true)
+	err := exporter.checkClonedBranches("ws", "repo", emptyMirror)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "has no branches but Bitbucket lists 1")
+	assert.NoError(t, exporter.checkClonedBranches("ws", "empty", emptyMirror))
+}
@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	migrationNotesDir = "migration-notes"
+	codeownersFile    = "CODEOWNERS"
+)
+
+// repositoryCodeowners is the generated CODEOWNERS file of one repository.
+type repositoryCodeowners struct {
+	repoSlug string
+	content  string
+}
+
+// SetCodeowners generates a CODEOWNERS file per repository under
+// migration-notes/ from Bitbucket default reviewers and the path rules and
+// user mapping of the configuration file. A nil config generates files from
+// the default reviewers alone.
+func (e *Exporter) SetCodeowners(enabled bool, config *data.CodeownersConfig) {
+	e.generateCodeowners = enabled
+	if config == nil {
+		config = &data.CodeownersConfig{}
+	}
+	e.codeownersConfig = config
+}
+
+func (c *Client) GetDefaultReviewers(workspace, repoSlug string) ([]data.BitbucketPRUser, error) {
+	c.logger.Debug("Fetching default reviewers",
+		zap.String("workspace", workspace),
+		zap.String("repository", repoSlug))
+
+	var reviewers []data.BitbucketPRUser
+	page := 1
+	pageLen := c.pageLen(100)
+	hasMore := true
+
+	for hasMore {
+		endpoint := fmt.Sprintf("repositories/%s/%s/default-reviewers?page=%d&pagelen=%d",
+			workspace, repoSlug, page, pageLen)
+
+		var response data.BitbucketDefaultReviewersResponse
+		if err := c.makeRequest("GET", endpoint, &response); err != nil {
+			return nil, fmt.Errorf("failed to list default reviewers for %s/%s: %w", workspace, repoSlug, err)
+		}
+		reviewers = append(reviewers, response.Values...)
+
+		hasMore = response.Next != ""
+		if hasMore {
+			page++
+		}
+	}
+	return reviewers, nil
+}
+
+// codeownerHandle returns the configured GitHub owner for a Bitbucket user,
+// looked up by nickname, account ID or UUID.
+func codeownerHandle(users map[string]string, user data.BitbucketPRUser) (string, bool) {
+	for _, key := range []string{user.Nickname, user.AccountID, user.UUID, strings.Trim(user.UUID, "{}")} {
+		if key == "" {
+			continue
+		}
+		if handle, ok := users[key]; ok && handle != "" {
+			return normalizeCodeowner(handle), true
+		}
+	}
+	return "", false
+}
+
+// normalizeCodeowner adds the @ GitHub expects in front of user and team
+// names; email addresses are kept as they are.
+func normalizeCodeowner(owner string) string {
+	owner = strings.TrimSpace(owner)
+	if strings.HasPrefix(owner, "@") || strings.Contains(owner, "@") {
+		return owner
+	}
+	return "@" + owner
+}
+
+func describeBitbucketUser(user data.BitbucketPRUser) string {
+	switch {
+	case user.DisplayName != "" && user.Nickname != "":
+		return fmt.Sprintf("%s (%s)", user.DisplayName, user.Nickname)
+	case user.DisplayName != "":
+		return user.DisplayName
+	case user.Nickname != "":
+		return user.Nickname
+	default:
+		return user.UUID
+	}
+}
+
+// buildCodeowners renders a CODEOWNERS file. The default reviewers become
+// the catch-all "*" owners and come first, so that the configured path
+// rules, which GitHub evaluates last-match-wins, take precedence.
+// permissions holds the exported collaborator permission by user UUID;
+// reviewers with read access are flagged, as GitHub ignores code owners
+// without write access.
+func buildCodeowners(workspace, repoSlug string, reviewers []data.BitbucketPRUser, config *data.CodeownersConfig,
+	permissions map[string]string) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# Generated by gh-bbc-exporter for %s/%s.\n", workspace, repoSlug)
+	out.WriteString("# Review the owners, then commit this file as .github/CODEOWNERS after the import.\n")
+
+	if len(reviewers) > 0 {
+		var owners, unmapped, readOnly []string
+		for _, reviewer := range reviewers {
+			if permissions[strings.Trim(reviewer.UUID, "{}")] == "read" {
+				readOnly = append(readOnly, describeBitbucketUser(reviewer))
+			}
+			if handle, ok := codeownerHandle(config.Users, reviewer); ok {
+				owners = append(owners, handle)
+			} else {
+				unmapped = append(unmapped, describeBitbucketUser(reviewer))
+			}
+		}
+
+		out.WriteString("\n# Bitbucket default reviewers\n")
+		if len(owners) > 0 {
+			fmt.Fprintf(&out, "* %s\n", strings.Join(owners, " "))
+		}
+		if len(unmapped) > 0 {
+			fmt.Fprintf(&out, "# Not mapped to GitHub users (add them under codeowners.users in --config): %s\n",
+				strings.Join(unmapped, ", "))
+		}
+		if len(readOnly) > 0 {
+			fmt.Fprintf(&out, "# Read-only collaborators; grant write access or GitHub ignores them as code owners: %s\n",
+				strings.Join(readOnly, ", "))
+		}
+	}
+
+	var rules []data.CodeownersRule
+	for _, rule := range config.Rules {
+		if rule.Repository != "" {
+			if matched, _ := path.Match(strings.ToLower(rule.Repository), strings.ToLower(repoSlug)); !matched {
+				continue
+			}
+		}
+		if rule.Path == "" || len(rule.Owners) == 0 {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) > 0 {
+		out.WriteString("\n# Path rules from the configuration file\n")
+		for _, rule := range rules {
+			owners := make([]string, 0, len(rule.Owners))
+			for _, owner := range rule.Owners {
+				if handle, ok := config.Users[owner]; ok && handle != "" {
+					owner = handle
+				}
+				owners = append(owners, normalizeCodeowner(owner))
+			}
+			fmt.Fprintf(&out, "%s %s\n", rule.Path, strings.Join(owners, " "))
+		}
+	}
+	return out.String()
+}
+
+// collectCodeowners fetches the default reviewers of a repository and
+// renders its CODEOWNERS file.
+func (e *Exporter) collectCodeowners(workspace, repoSlug string) {
+	if !e.generateCodeowners {
+		return
+	}
+
+	reviewers, err := e.client.GetDefaultReviewers(workspace, repoSlug)
+	if err != nil {
+		e.logger.Warn("Failed to fetch default reviewers; CODEOWNERS will only contain configured path rules",
+			zap.String("repository", repoSlug),
+			zap.Error(err))
+	}
+	e.codeowners = append(e.codeowners, repositoryCodeowners{
+		repoSlug: repoSlug,
+		content:  buildCodeowners(workspace, repoSlug, reviewers, e.codeownersConfig, e.repoPermissions[repoSlug]),
+	})
+}
+
+// writeCodeowners writes migration-notes/<repo>/CODEOWNERS for every
+// exported repository.
+func (e *Exporter) writeCodeowners() error {
+	if !e.generateCodeowners {
+		return nil
+	}
+
+	for _, entry := range e.codeowners {
+		dir := filepath.Join(e.outputDir, migrationNotesDir, entry.repoSlug)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create %s: %w", dir, err)
+		}
+		err := writeFileAtomic(filepath.Join(dir, codeownersFile), 0644, func(w io.Writer) error {
+			_, err := io.WriteString(w, entry.content)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write CODEOWNERS for %s: %w", entry.repoSlug, err)
+		}
+	}
+
+	e.logger.Info("Wrote CODEOWNERS files (excluded from import archive)",
+		zap.String("path", filepath.Join(e.outputDir, migrationNotesDir)),
+		zap.Int("repositories", len(e.codeowners)))
+	return nil
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestBuildCodeowners(t *testing.T) {
+	reviewers := []data.BitbucketPRUser{
+		{DisplayName: "Alice", Nickname: "alice"},
+		{DisplayName: "Bob", UUID: "{bob-uuid}"},
+		{DisplayName: "Carol", Nickname: "carol"},
+	}
+	config := &data.CodeownersConfig{
+		Users: map[string]string{"alice": "alice-gh", "bob-uuid": "@bob-gh", "docs": "@acme/docs"},
+		Rules: []data.CodeownersRule{
+			{Path: "/docs/", Owners: []string{"docs"}},
+			{Repository: "api-*", Path: "*.go", Owners: []string{"@acme/backend", "dev@example.com"}},
+			{Repository: "web", Path: "*.ts", Owners: []string{"@acme/frontend"}},
+			{Path: "/empty/"},
+		},
+	}
+
+	content := buildCodeowners("ws", "api-server", reviewers, config, map[string]string{"bob-uuid": "read"})
+
+	assert.Contains(t, content, "# Generated by gh-bbc-exporter for ws/api-server.")
+	assert.Contains(t, content, "* @alice-gh @bob-gh\n")
+	assert.Contains(t, content, "Carol (carol)")
+	assert.Contains(t, content, "# Read-only collaborators; grant write access or GitHub ignores them as code owners: Bob\n")
+	assert.Contains(t, content, "/docs/ @acme/docs\n")
+	assert.Contains(t, content, "*.go @acme/backend dev@example.com\n")
+	assert.NotContains(t, content, "*.ts")
+	assert.NotContains(t, content, "/empty/")
+	assert.Less(t, strings.Index(content, "* @alice-gh"), strings.Index(content, "/docs/"),
+		"path rules must follow the catch-all owners to take precedence")
+}
+
+func TestBuildCodeownersWithoutReviewers(t *testing.T) {
+	content := buildCodeowners("ws", "repo", nil, &data.CodeownersConfig{}, nil)
+	assert.NotContains(t, content, "default reviewers")
+	assert.NotContains(t, content, "\n* ")
+}
+
+func TestGetDefaultReviewers(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repositories/ws/repo/default-reviewers", r.URL.Path)
+		if r.URL.Query().Get("page") == "1" {
+			writeResponse(t, w, []byte(`{"values": [{"nickname": "alice"}], "next": "`+server.URL+`?page=2"}`))
+			return
+		}
+		writeResponse(t, w, []byte(`{"values": [{"nickname": "bob"}]}`))
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	reviewers, err := client.GetDefaultReviewers("ws", "repo")
+	require.NoError(t, err)
+	require.Len(t, reviewers, 2)
+	assert.Equal(t, "bob", reviewers[1].Nickname)
+}
+
+func TestWriteCodeowners(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(t, w, []byte(`{"values": [{"nickname": "alice"}]}`))
+	}))
+	defer server.Close()
+
+	outputDir := t.TempDir()
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+	exporter.SetCodeowners(true, &data.CodeownersConfig{Users: map[string]string{"alice": "alice-gh"}})
+
+	exporter.collectCodeowners("ws", "repo")
+	require.NoError(t, exporter.writeCodeowners())
+
+	content, err := os.ReadFile(filepath.Join(outputDir, migrationNotesDir, "repo", codeownersFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "* @alice-gh\n")
+	assert.True(t, sidecarPaths[migrationNotesDir], "migration notes stay out of the archive")
+}
+
+func TestCollectCodeownersDisabled(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.collectCodeowners("ws", "repo")
+	assert.Empty(t, exporter.codeowners)
+	require.NoError(t, exporter.writeCodeowners())
+}
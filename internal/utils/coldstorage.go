@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	coldStorageDir        = "cold-storage"
+	coldStorageBundleFile = "pull_requests_bundle.json"
+)
+
+func (e *Exporter) SetColdStorage(before string, declined bool) {
+	e.coldStorageBefore = before
+	e.coldStorageDeclined = declined
+}
+
+func (e *Exporter) coldStorageEnabled() bool {
+	return e.coldStorageBefore != "" || e.coldStorageDeclined
+}
+
+// isColdPullRequest reports whether a pull request belongs in the cold-storage
+// bundle instead of the import archive.
+func (e *Exporter) isColdPullRequest(pr data.PullRequest) bool {
+	if e.coldStorageDeclined && pr.ClosedAt != nil && pr.MergedAt == nil {
+		return true
+	}
+
+	if e.coldStorageBefore == "" {
+		return false
+	}
+
+	cutoff, err := time.Parse("2006-01-02", e.coldStorageBefore)
+	if err != nil {
+		return false
+	}
+
+	createdAt, err := time.Parse(time.RFC3339, pr.CreatedAt)
+	if err != nil {
+		e.logger.Debug("Could not parse PR creation date for cold storage, keeping PR in archive",
+			zap.String("pr_url", pr.URL),
+			zap.String("created_at", pr.CreatedAt))
+		return false
+	}
+
+	return createdAt.Before(cutoff)
+}
+
+func (e *Exporter) partitionColdPullRequests(prs []data.PullRequest) ([]data.PullRequest, []data.PullRequest) {
+	hot := []data.PullRequest{}
+	cold := []data.PullRequest{}
+
+	for _, pr := range prs {
+		if e.isColdPullRequest(pr) {
+			cold = append(cold, pr)
+		} else {
+			hot = append(hot, pr)
+		}
+	}
+
+	return hot, cold
+}
+
+func partitionColdIssueComments(comments []data.IssueComment, coldPRs map[string]bool) ([]data.IssueComment, []data.IssueComment) {
+	hot := []data.IssueComment{}
+	cold := []data.IssueComment{}
+
+	for _, comment := range comments {
+		if coldPRs[comment.PullRequest] {
+			cold = append(cold, comment)
+		} else {
+			hot = append(hot, comment)
+		}
+	}
+
+	return hot, cold
+}
+
+func partitionColdReviewComments(comments []data.PullRequestReviewComment, coldPRs map[string]bool) ([]data.PullRequestReviewComment, []data.PullRequestReviewComment) {
+	hot := []data.PullRequestReviewComment{}
+	cold := []data.PullRequestReviewComment{}
+
+	for _, comment := range comments {
+		if coldPRs[comment.PullRequest] {
+			cold = append(cold, comment)
+		} else {
+			hot = append(hot, comment)
+		}
+	}
+
+	return hot, cold
+}
+
+func (e *Exporter) writeColdStorageBundle(bundle data.ColdStorageBundle) error {
+	if err := os.MkdirAll(filepath.Join(e.outputDir, coldStorageDir), 0755); err != nil {
+		return fmt.Errorf("failed to create cold storage directory: %w", err)
+	}
+
+	if err := e.writeJSONFile(filepath.Join(coldStorageDir, coldStorageBundleFile), bundle); err != nil {
+		return err
+	}
+
+	e.logger.Info("Wrote cold-storage bundle (excluded from import archive)",
+		zap.Int("pull_requests", len(bundle.PullRequests)),
+		zap.Int("issue_comments", len(bundle.IssueComments)),
+		zap.Int("review_comments", len(bundle.ReviewComments)))
+
+	return nil
+}
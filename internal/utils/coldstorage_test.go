@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPartitionColdPullRequests(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	exporter := NewExporter(&Client{}, t.TempDir(), logger, false, "")
+
+	closedAt := "2021-06-01T00:00:00Z"
+	mergedAt := "2021-06-01T00:00:00Z"
+	prs := []data.PullRequest{
+		{URL: "https://bitbucket.org/ws/repo/pull/1", CreatedAt: "2019-01-01T00:00:00Z"},
+		{URL: "https://bitbucket.org/ws/repo/pull/2", CreatedAt: "2021-01-01T00:00:00Z", ClosedAt: &closedAt},
+		{URL: "https://bitbucket.org/ws/repo/pull/3", CreatedAt: "2021-01-01T00:00:00Z", ClosedAt: &closedAt, MergedAt: &mergedAt},
+		{URL: "https://bitbucket.org/ws/repo/pull/4", CreatedAt: "2022-01-01T00:00:00Z"},
+	}
+
+	testCases := []struct {
+		name         string
+		before       string
+		declined     bool
+		expectedCold []string
+	}{
+		{
+			name:         "cutoff only",
+			before:       "2020-01-01",
+			expectedCold: []string{"https://bitbucket.org/ws/repo/pull/1"},
+		},
+		{
+			name:         "declined only",
+			declined:     true,
+			expectedCold: []string{"https://bitbucket.org/ws/repo/pull/2"},
+		},
+		{
+			name:     "cutoff and declined",
+			before:   "2020-01-01",
+			declined: true,
+			expectedCold: []string{
+				"https://bitbucket.org/ws/repo/pull/1",
+				"https://bitbucket.org/ws/repo/pull/2",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter.SetColdStorage(tc.before, tc.declined)
+			hot, cold := exporter.partitionColdPullRequests(prs)
+
+			var coldURLs []string
+			for _, pr := range cold {
+				coldURLs = append(coldURLs, pr.URL)
+			}
+			assert.Equal(t, tc.expectedCold, coldURLs)
+			assert.Len(t, hot, len(prs)-len(cold))
+		})
+	}
+}
+
+func TestPartitionColdComments(t *testing.T) {
+	coldPRs := map[string]bool{"https://bitbucket.org/ws/repo/pull/1": true}
+
+	issueComments := []data.IssueComment{
+		{URL: "c1", PullRequest: "https://bitbucket.org/ws/repo/pull/1"},
+		{URL: "c2", PullRequest: "https://bitbucket.org/ws/repo/pull/2"},
+	}
+	hot, cold := partitionColdIssueComments(issueComments, coldPRs)
+	assert.Len(t, hot, 1)
+	assert.Len(t, cold, 1)
+	assert.Equal(t, "c1", cold[0].URL)
+
+	reviewComments := []data.PullRequestReviewComment{
+		{URL: "r1", PullRequest: "https://bitbucket.org/ws/repo/pull/2"},
+	}
+	hotReview, coldReview := partitionColdReviewComments(reviewComments, coldPRs)
+	assert.Len(t, hotReview, 1)
+	assert.Empty(t, coldReview)
+}
+
+func TestColdStorageBundleExcludedFromArchive(t *testing.T) {
+	baseDir := t.TempDir()
+	outputDir := filepath.Join(baseDir, "export")
+	require.NoError(t, os.MkdirAll(outputDir, 0755))
+
+	logger, _ := zap.NewDevelopment()
+	exporter := NewExporter(&Client{}, outputDir, logger, false, "")
+
+	require.NoError(t, exporter.writeJSONFile("schema.json", data.MigrationArchiveSchema{Version: "1.0.1"}))
+	require.NoError(t, exporter.writeColdStorageBundle(data.ColdStorageBundle{
+		PullRequests: []data.PullRequest{{URL: "https://bitbucket.org/ws/repo/pull/1"}},
+	}))
+
+	bundleData, err := os.ReadFile(filepath.Join(outputDir, coldStorageDir, coldStorageBundleFile))
+	require.NoError(t, err)
+	var bundle data.ColdStorageBundle
+	require.NoError(t, json.Unmarshal(bundleData, &bundle))
+	assert.Len(t, bundle.PullRequests, 1)
+
+	archivePath, err := exporter.CreateArchive()
+	require.NoError(t, err)
+
+	archiveFile, err := os.Open(archivePath)
+	require.NoError(t, err)
+	defer func() { _ = archiveFile.Close() }()
+
+	gzipReader, err := gzip.NewReader(archiveFile)
+	require.NoError(t, err)
+	tarReader := tar.NewReader(gzipReader)
+
+	var names []string
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		names = append(names, header.Name)
+	}
+
+	assert.Contains(t, names, "schema.json")
+	for _, name := range names {
+		assert.NotContains(t, name, coldStorageDir)
+	}
+}
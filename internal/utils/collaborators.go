@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// collaboratorPermissions maps Bitbucket repository permissions to GitHub
+// collaborator permissions.
+var collaboratorPermissions = map[string]string{
+	"admin": "admin",
+	"write": "write",
+	"read":  "read",
+}
+
+const permissionModelNote = "Bitbucket also grants access through workspace and project permissions; " +
+	"these are inherited rather than set on the repository and are not exported as collaborators"
+
+func (c *Client) GetRepositoryUserPermissions(workspace, repoSlug string) ([]data.BitbucketUserPermission, error) {
+	var permissions []data.BitbucketUserPermission
+	endpoint := fmt.Sprintf("repositories/%s/%s/permissions-config/users?pagelen=%d",
+		workspace, repoSlug, c.pageLen(100))
+	for endpoint != "" {
+		var response data.BitbucketUserPermissionResponse
+		if err := c.makeRequest("GET", endpoint, &response); err != nil {
+			return nil, fmt.Errorf("failed to list user permissions for %s/%s: %w", workspace, repoSlug, err)
+		}
+		permissions = append(permissions, response.Values...)
+		endpoint = response.Next
+	}
+	return permissions, nil
+}
+
+func (c *Client) GetRepositoryGroupPermissions(workspace, repoSlug string) ([]data.BitbucketGroupPermission, error) {
+	var permissions []data.BitbucketGroupPermission
+	endpoint := fmt.Sprintf("repositories/%s/%s/permissions-config/groups?pagelen=%d",
+		workspace, repoSlug, c.pageLen(100))
+	for endpoint != "" {
+		var response data.BitbucketGroupPermissionResponse
+		if err := c.makeRequest("GET", endpoint, &response); err != nil {
+			return nil, fmt.Errorf("failed to list group permissions for %s/%s: %w", workspace, repoSlug, err)
+		}
+		permissions = append(permissions, response.Values...)
+		endpoint = response.Next
+	}
+	return permissions, nil
+}
+
+// collectCollaborators turns the explicit user permissions of a repository
+// into archive collaborators. Group permissions have no collaborator
+// equivalent and, like inherited permissions, are documented in the report.
+func (e *Exporter) collectCollaborators(workspace, repoSlug string) []data.Collaborator {
+	collaborators := []data.Collaborator{}
+	source := fmt.Sprintf("%s/%s", workspace, repoSlug)
+
+	userPermissions, err := e.client.GetRepositoryUserPermissions(workspace, repoSlug)
+	if err != nil {
+		e.logger.Warn("Failed to fetch repository permissions; no collaborators will be exported",
+			zap.String("repository", source),
+			zap.Error(err))
+		e.addPermissionNote(source, "users", "",
+			fmt.Sprintf("failed to read explicit user permissions (the token needs admin access to the repository): %v", err))
+		return collaborators
+	}
+
+	permissions := make(map[string]string, len(userPermissions))
+	for _, permission := range userPermissions {
+		login := strings.Trim(permission.User.UUID, "{}")
+		mapped, ok := collaboratorPermissions[permission.Permission]
+		if login == "" || !ok {
+			e.addPermissionNote(source, describeBitbucketUser(permission.User), permission.Permission,
+				"permission has no GitHub collaborator equivalent and was not exported")
+			continue
+		}
+		permissions[login] = mapped
+		e.client.recordContributor(permission.User)
+		collaborators = append(collaborators, data.Collaborator{
+			User:       e.client.formatURL("user", workspace, "", e.client.userLogin(permission.User.UUID, permission.User.DisplayName)),
+			Permission: mapped,
+		})
+	}
+	sort.Slice(collaborators, func(i, j int) bool {
+		return collaborators[i].User < collaborators[j].User
+	})
+	if e.repoPermissions == nil {
+		e.repoPermissions = make(map[string]map[string]string)
+	}
+	e.repoPermissions[repoSlug] = permissions
+
+	groupPermissions, err := e.client.GetRepositoryGroupPermissions(workspace, repoSlug)
+	if err != nil {
+		e.logger.Warn("Failed to fetch repository group permissions",
+			zap.String("repository", source),
+			zap.Error(err))
+		e.addPermissionNote(source, "groups", "", fmt.Sprintf("failed to read group permissions: %v", err))
+	}
+	for _, permission := range groupPermissions {
+		name := permission.Group.Name
+		if name == "" {
+			name = permission.Group.Slug
+		}
+		e.addPermissionNote(source, "group:"+name, permission.Permission,
+			"GitHub collaborators are individual users; recreate the group as a team and grant it access after the import")
+	}
+	e.addPermissionNote(source, "workspace", "", permissionModelNote)
+
+	e.logger.Debug("Collected repository collaborators",
+		zap.String("repository", source),
+		zap.Int("collaborators", len(collaborators)),
+		zap.Int("groups", len(groupPermissions)))
+	return collaborators
+}
+
+func (e *Exporter) addPermissionNote(repository, subject, permission, note string) {
+	e.permissionNotes = append(e.permissionNotes, data.PermissionNote{
+		Repository: repository,
+		Subject:    subject,
+		Permission: permission,
+		Note:       note,
+	})
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func permissionsServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repositories/ws/repo/permissions-config/users":
+			if r.URL.Query().Get("page") == "2" {
+				writeResponse(t, w, []byte(`{"values": [
+					{"permission": "read", "user": {"uuid": "{carol}", "display_name": "Carol"}}]}`))
+				return
+			}
+			writeResponse(t, w, []byte(`{"values": [
+				{"permission": "write", "user": {"uuid": "{bob}", "display_name": "Bob"}},
+				{"permission": "admin", "user": {"uuid": "{alice}", "display_name": "Alice"}},
+				{"permission": "owner", "user": {"uuid": "{dave}", "display_name": "Dave"}}],
+				"next": "`+server.URL+`/repositories/ws/repo/permissions-config/users?page=2"}`))
+		case "/repositories/ws/repo/permissions-config/groups":
+			writeResponse(t, w, []byte(`{"values": [
+				{"permission": "write", "group": {"slug": "developers", "name": "Developers"}}]}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	return server
+}
+
+func TestCollectCollaborators(t *testing.T) {
+	server := permissionsServer(t)
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+
+	collaborators := exporter.collectCollaborators("ws", "repo")
+
+	assert.Equal(t, []data.Collaborator{
+		{User: format.URL("user", "ws", "", "alice"), Permission: "admin"},
+		{User: format.URL("user", "ws", "", "bob"), Permission: "write"},
+		{User: format.URL("user", "ws", "", "carol"), Permission: "read"},
+	}, collaborators)
+	assert.Equal(t, "read", exporter.repoPermissions["repo"]["carol"])
+	assert.Equal(t, "Alice", client.contributors["alice"])
+
+	subjects := []string{}
+	for _, note := range exporter.permissionNotes {
+		assert.Equal(t, "ws/repo", note.Repository)
+		subjects = append(subjects, note.Subject)
+	}
+	assert.Equal(t, []string{"Dave", "group:Developers", "workspace"}, subjects)
+}
+
+func TestCollectCollaboratorsWithoutAccess(t *testing.T) {
+	server := permissionsServer(t)
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+
+	collaborators := exporter.collectCollaborators("ws", "private")
+
+	assert.Empty(t, collaborators)
+	assert.NotNil(t, collaborators, "collaborators are written as an empty list")
+	require.Len(t, exporter.permissionNotes, 1)
+	assert.Contains(t, exporter.permissionNotes[0].Note, "failed to read explicit user permissions")
+}
+
+func TestPermissionNotesInReport(t *testing.T) {
+	outputDir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.beginReport("ws", []string{"repo"})
+	exporter.addPermissionNote("ws/repo", "workspace", "", permissionModelNote)
+	exporter.finishReport(nil)
+
+	var report data.ExportReport
+	readReportFile(t, filepath.Join(outputDir, exportReportFile), &report)
+	require.Len(t, report.PermissionNotes, 1)
+	assert.Equal(t, permissionModelNote, report.PermissionNotes[0].Note)
+}
+
+func TestContributorUsersIncludeCollaborators(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.repositories = []data.Repository{{Collaborators: []data.Collaborator{
+		{User: format.URL("user", "ws", "", "alice"), Permission: "admin"},
+	}}}
+
+	users := exporter.contributorUsers([]data.PullRequest{{User: format.URL("user", "ws", "", "bob")}}, nil, nil)
+	require.Len(t, users, 2)
+	assert.Equal(t, "alice", users[0].Login)
+}
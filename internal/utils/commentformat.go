@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+)
+
+const (
+	commentFormatterMarkdown = "markdown"
+	commentFormatterHTMLToMD = "html-to-md"
+	commentFormatterRaw      = "raw"
+)
+
+var commentFormatters = []string{commentFormatterMarkdown, commentFormatterHTMLToMD, commentFormatterRaw}
+
+// ValidateCommentFormatter checks a --comment-formatter value. An empty value
+// selects the default markdown formatter.
+func ValidateCommentFormatter(formatter string) error {
+	if formatter == "" {
+		return nil
+	}
+	for _, known := range commentFormatters {
+		if formatter == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value for --comment-formatter: %q (supported: %s)",
+		formatter, strings.Join(commentFormatters, ", "))
+}
+
+// SetCommentFormatter selects how pull request comment bodies are exported:
+//   - markdown: Bitbucket's raw Markdown with pull request links rewritten
+//   - html-to-md: Bitbucket's rendered HTML converted to Markdown, which
+//     expands wiki-style macros the raw text leaves unconverted
+//   - raw: the raw text exactly as Bitbucket stores it
+func (c *Client) SetCommentFormatter(formatter string) {
+	c.commentFormatter = formatter
+}
+
+func (c *Client) commentBody(comment data.BitbucketComment, workspace, repoSlug string) string {
+	switch c.commentFormatter {
+	case commentFormatterRaw:
+		return comment.Content.Raw
+	case commentFormatterHTMLToMD:
+		if comment.Content.HTML != "" {
+			return c.transformCommentBody(htmlToMarkdown(comment.Content.HTML), workspace, repoSlug)
+		}
+	}
+	return c.transformCommentBody(comment.Content.Raw, workspace, repoSlug)
+}
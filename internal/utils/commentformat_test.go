@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestValidateCommentFormatter(t *testing.T) {
+	for _, formatter := range []string{"", "markdown", "html-to-md", "raw"} {
+		assert.NoError(t, ValidateCommentFormatter(formatter), formatter)
+	}
+
+	err := ValidateCommentFormatter("wiki")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid value for --comment-formatter")
+}
+
+func TestCommentBody(t *testing.T) {
+	comment := data.BitbucketComment{}
+	comment.Content.Raw = "See https://bitbucket.org/ws/repo/pull-requests/3 {{macro}}"
+	comment.Content.HTML = `<p>See <a href="https://bitbucket.org/ws/repo/pull-requests/3">PR 3</a> <strong>expanded</strong></p>`
+
+	client := &Client{logger: zap.NewNop()}
+
+	assert.Equal(t, "See https://bitbucket.org/ws/repo/pull/3 {{macro}}",
+		client.commentBody(comment, "ws", "repo"), "default formatter")
+
+	client.SetCommentFormatter(commentFormatterMarkdown)
+	assert.Equal(t, "See https://bitbucket.org/ws/repo/pull/3 {{macro}}",
+		client.commentBody(comment, "ws", "repo"))
+
+	client.SetCommentFormatter(commentFormatterRaw)
+	assert.Equal(t, comment.Content.Raw, client.commentBody(comment, "ws", "repo"))
+
+	client.SetCommentFormatter(commentFormatterHTMLToMD)
+	assert.Equal(t, "See [PR 3](https://bitbucket.org/ws/repo/pull/3) **expanded**",
+		client.commentBody(comment, "ws", "repo"))
+
+	comment.Content.HTML = ""
+	assert.Equal(t, "See https://bitbucket.org/ws/repo/pull/3 {{macro}}",
+		client.commentBody(comment, "ws", "repo"), "falls back to raw markdown without rendered HTML")
+}
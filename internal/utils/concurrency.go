@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+)
+
+// maxConcurrency caps --concurrency. Bitbucket's hourly quota is shared by
+// the whole workspace, so more workers only reach the rate limit sooner.
+const maxConcurrency = 16
+
+// ValidateConcurrency checks a --concurrency value. Zero selects the
+// default of one worker.
+func ValidateConcurrency(workers int) error {
+	if workers < 0 || workers > maxConcurrency {
+		return fmt.Errorf("invalid value for --concurrency: %d (must be between 1 and %d)", workers, maxConcurrency)
+	}
+	return nil
+}
+
+// SetConcurrency sets how many workers fetch pull request comments and
+// resolve commit SHAs at the same time. Workers wait out each other's
+// rate-limit backoffs. Nice mode always uses a single worker.
+func (c *Client) SetConcurrency(workers int) {
+	c.concurrency = workers
+}
+
+// forEach calls fn for each index below n on up to c.concurrency workers and
+// returns when all calls have finished. fn must only touch shared state
+// through the client's synchronized helpers or its own locking.
+func (c *Client) forEach(n int, fn func(i int)) {
+	workers := min(c.concurrency, n)
+	if workers <= 1 || c.niceMode {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+func (c *Client) cachedCommitSHA(commitHash string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fullSHA, exists := c.commitSHACache[commitHash]
+	return fullSHA, exists
+}
+
+func (c *Client) cacheCommitSHA(commitHash, fullSHA string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.commitSHACache == nil {
+		c.commitSHACache = make(map[string]string)
+	}
+	c.commitSHACache[commitHash] = fullSHA
+}
+
+// contributorNames returns a copy of the recorded contributors that workers
+// can keep adding to while it is written out.
+func (c *Client) contributorNames() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.contributors)
+}
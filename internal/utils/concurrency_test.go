@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestForEach(t *testing.T) {
+	for _, workers := range []int{0, 1, 4} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			client := &Client{concurrency: workers}
+			var mu sync.Mutex
+			var running, peak int32
+			seen := make([]bool, 20)
+
+			client.forEach(len(seen), func(i int) {
+				current := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				mu.Lock()
+				seen[i] = true
+				if current > peak {
+					peak = current
+				}
+				mu.Unlock()
+				time.Sleep(time.Millisecond)
+			})
+
+			for i, ok := range seen {
+				assert.True(t, ok, "index %d", i)
+			}
+			assert.LessOrEqual(t, peak, int32(max(workers, 1)))
+		})
+	}
+}
+
+func TestForEachInNiceMode(t *testing.T) {
+	client := &Client{concurrency: 4, niceMode: true}
+	var order []int
+
+	client.forEach(5, func(i int) {
+		order = append(order, i)
+	})
+
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, order, "nice mode runs one worker")
+}
+
+func TestGetPullRequestCommentsConcurrently(t *testing.T) {
+	var running, peak int32
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		prID := strings.Split(r.URL.Path, "/")[5]
+		writeResponse(t, w, []byte(`{"values": [
+			{"id": `+prID+`1, "created_on": "2024-01-01T00:00:00Z", "content": {"raw": "general"},
+			 "user": {"uuid": "{alice-`+prID+`}", "display_name": "Alice"}},
+			{"id": `+prID+`2, "created_on": "2024-01-01T00:00:00Z", "content": {"raw": "inline"},
+			 "user": {"uuid": "{bob}", "display_name": "Bob"}, "inline": {"path": "main.go", "to": 3}}],
+			"next": null}`))
+	}))
+	defer testServer.Close()
+
+	progress, _ := testProgress(t)
+	client := resumeClient(testServer.URL, progress)
+	client.skipCommitLookup = true
+	client.SetConcurrency(4)
+	var prs []data.PullRequest
+	for i := 1; i <= 12; i++ {
+		prs = append(prs, data.PullRequest{
+			URL:  fmt.Sprintf("https://bitbucket.org/workspace/repo/pull/%d", i),
+			Head: data.PRBranch{SHA: "abc1234"},
+		})
+	}
+
+	regular, review, err := client.GetPullRequestComments("workspace", "repo", prs)
+
+	require.NoError(t, err)
+	assert.Len(t, regular, 12)
+	assert.Len(t, review, 12)
+	assert.Len(t, progress.checkpoint.CommentedPRs["repo"], 12)
+	assert.Len(t, client.contributorNames(), 13)
+	assert.Greater(t, peak, int32(1), "pull requests are fetched in parallel")
+	assert.LessOrEqual(t, peak, int32(4))
+}
+
+func TestGetPullRequestsResolvesCommitsConcurrently(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hash, found := strings.CutPrefix(r.URL.Path, "/repositories/workspace/repo/commit/"); found {
+			time.Sleep(5 * time.Millisecond)
+			writeResponse(t, w, []byte(`{"hash": "`+strings.Repeat(hash[:1], 40)+`"}`))
+			return
+		}
+		var values []string
+		for i := 1; i <= 8; i++ {
+			values = append(values, fmt.Sprintf(`{"id": %d, "title": "PR %d", "state": "OPEN",
+				"source": {"commit": {"hash": "%d"}}, "destination": {"commit": {"hash": "a"}}}`, i, i, i))
+		}
+		writeResponse(t, w, []byte(`{"values": [`+strings.Join(values, ",")+`], "next": null}`))
+	}))
+	defer testServer.Close()
+
+	client := resumeClient(testServer.URL, nil)
+	client.SetConcurrency(4)
+
+	prs, err := client.GetPullRequests("workspace", "repo", false, "")
+
+	require.NoError(t, err)
+	require.Len(t, prs, 8)
+	for i, pr := range prs {
+		assert.Equal(t, fmt.Sprintf("PR %d", i+1), pr.Title, "page order is kept")
+		assert.Equal(t, strings.Repeat(fmt.Sprint(i+1), 40), pr.Head.SHA)
+		assert.Equal(t, strings.Repeat("a", 40), pr.Base.SHA)
+	}
+}
+
+func TestThrottleWaitsForBackoff(t *testing.T) {
+	client := &Client{}
+	client.backOff(50 * time.Millisecond)
+
+	start := time.Now()
+	client.throttle()
+
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	assert.Equal(t, 1, client.throttling.rateLimitHits)
+	assert.Equal(t, 1, client.throttling.requests)
+	assert.Zero(t, client.throttling.niceDelay, "backoff waits are not nice-mode delays")
+}
+
+func TestRefreshTokenOnceForConcurrentRejections(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available for testing")
+	}
+
+	client := &Client{logger: zap.NewNop(), accessToken: "expired"}
+	client.SetTokenRefreshCommand("echo fresh")
+	require.NoError(t, client.refreshToken(t.Context(), 0))
+	assert.Equal(t, "fresh", client.accessToken)
+
+	client.SetTokenRefreshCommand("exit 1")
+	assert.NoError(t, client.refreshToken(t.Context(), 0), "another worker already refreshed the token")
+	assert.Equal(t, 1, client.tokenGeneration())
+}
+
+func TestValidateConcurrency(t *testing.T) {
+	assert.NoError(t, ValidateConcurrency(0))
+	assert.NoError(t, ValidateConcurrency(1))
+	assert.NoError(t, ValidateConcurrency(maxConcurrency))
+	assert.ErrorContains(t, ValidateConcurrency(-1), "invalid value for --concurrency")
+	assert.ErrorContains(t, ValidateConcurrency(maxConcurrency+1), "invalid value for --concurrency")
+}
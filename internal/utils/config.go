@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"gopkg.in/yaml.v3"
+)
+
+// LoadConfig reads the YAML exporter configuration file.
+func LoadConfig(path string) (*data.ExporterConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var config data.ExporterConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, suggestConfigKeys(err))
+	}
+
+	for prefix, baseURL := range config.API.EndpointOverrides {
+		parsed, err := url.Parse(baseURL)
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid base URL %q for endpoint override %q in %s", baseURL, prefix, path)
+		}
+	}
+	if err := ValidateURLConfig(config.URLs); err != nil {
+		return nil, fmt.Errorf("%w in %s", err, path)
+	}
+
+	return &config, nil
+}
+
+var unknownConfigKeyPattern = regexp.MustCompile(`field (\S+) not found in type (\S+)`)
+
+// suggestConfigKeys adds the closest known key to the unknown key errors of
+// a strict YAML decode, so a typo does not silently disable a setting.
+func suggestConfigKeys(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	keys := map[string][]string{}
+	collectConfigKeys(reflect.TypeOf(data.ExporterConfig{}), keys)
+	suggested := &yaml.TypeError{Errors: make([]string, len(typeErr.Errors))}
+	for i, message := range typeErr.Errors {
+		suggested.Errors[i] = message
+		match := unknownConfigKeyPattern.FindStringSubmatch(message)
+		if match == nil {
+			continue
+		}
+		suggested.Errors[i] = fmt.Sprintf("%sunknown key %q", message[:strings.Index(message, match[0])], match[1])
+		if suggestions := suggestNames(match[1], keys[match[2]]); len(suggestions) > 0 {
+			suggested.Errors[i] += fmt.Sprintf(" (did you mean %q?)", suggestions[0])
+		}
+	}
+	return suggested
+}
+
+// collectConfigKeys maps the name of every struct type reachable from t to
+// its YAML keys.
+func collectConfigKeys(t reflect.Type, keys map[string][]string) {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map:
+		collectConfigKeys(t.Elem(), keys)
+		return
+	case reflect.Struct:
+	default:
+		return
+	}
+	if _, seen := keys[t.String()]; seen {
+		return
+	}
+	keys[t.String()] = []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		keys[t.String()] = append(keys[t.String()], name)
+		collectConfigKeys(field.Type, keys)
+	}
+}
+
+// ConfigureClient applies client-level flags and the optional --config file
+// to a Bitbucket client.
+func ConfigureClient(client *Client, flags *data.CmdExportFlags) error {
+	client.SetNiceMode(flags.Nice)
+	client.SetOAuthConsumer(flags.BitbucketOAuthKey, flags.BitbucketOAuthSecret)
+	client.SetAuthFallback(flags.AuthFallback)
+	if flags.FixedTimestamps {
+		timestamp, err := FixedTimestamp()
+		if err != nil {
+			return err
+		}
+		client.SetClock(FixedClock{Time: timestamp})
+	}
+
+	network := NetworkOptionsFromFlags(flags)
+	if flags.ConfigFile == "" {
+		return client.SetNetworkOptions(network)
+	}
+
+	config, err := LoadConfig(flags.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	client.SetURLTemplates(config.URLs)
+	client.SetEndpointOverrides(config.API.EndpointOverrides)
+	client.SetMaxResponseSize(config.API.MaxResponseSizeMB << 20)
+
+	transport, err := NewTransport(config.API.Transport)
+	if err != nil {
+		return err
+	}
+	if transport != nil {
+		client.SetTransport(transport)
+	}
+	return client.SetNetworkOptions(network)
+}
+
+// SetEndpointOverrides routes API paths starting with a given prefix (for
+// example "repositories" or "workspaces") to a different base URL.
+func (c *Client) SetEndpointOverrides(overrides map[string]string) {
+	c.endpointOverrides = make(map[string]string, len(overrides))
+	for prefix, baseURL := range overrides {
+		c.endpointOverrides[strings.Trim(prefix, "/")] = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// baseURLFor returns the base URL for an endpoint path, using the longest
+// matching override prefix on path segment boundaries.
+func (c *Client) baseURLFor(endpointPath string) string {
+	endpointPath = strings.Trim(endpointPath, "/")
+	baseURL := strings.TrimSuffix(c.baseURL, "/")
+
+	longest := -1
+	for prefix, overrideURL := range c.endpointOverrides {
+		if endpointPath != prefix && !strings.HasPrefix(endpointPath, prefix+"/") {
+			continue
+		}
+		if len(prefix) > longest {
+			longest = len(prefix)
+			baseURL = overrideURL
+		}
+	}
+	return baseURL
+}
+
+func (c *Client) isOverrideURL(endpoint string) bool {
+	for _, overrideURL := range c.endpointOverrides {
+		if strings.HasPrefix(endpoint, overrideURL) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfigFile(t, `
+api:
+  endpoint_overrides:
+    /repositories: https://repos-gateway.example.com/2.0/
+    workspaces: https://ws-gateway.example.com/2.0
+`)
+
+	config, err := LoadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, "https://repos-gateway.example.com/2.0/", config.API.EndpointOverrides["/repositories"])
+	assert.Equal(t, "https://ws-gateway.example.com/2.0", config.API.EndpointOverrides["workspaces"])
+
+	_, err = LoadConfig(writeConfigFile(t, "api:\n  endpoint_overrides:\n    repositories: not-a-url\n"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid base URL")
+
+	_, err = LoadConfig(writeConfigFile(t, "api: [broken"))
+	assert.Error(t, err)
+
+	_, err = LoadConfig(writeConfigFile(t, "api:\n  transport:\n    unix_sockt: /run/proxy.sock\n"))
+	assert.ErrorContains(t, err, `line 3: unknown key "unix_sockt" (did you mean "unix_socket"?)`)
+
+	config, err = LoadConfig(writeConfigFile(t, ""))
+	require.NoError(t, err, "an empty file is a valid configuration")
+	assert.Empty(t, config.API.EndpointOverrides)
+
+	_, err = LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestBaseURLFor(t *testing.T) {
+	client := &Client{baseURL: "https://api.bitbucket.org/2.0"}
+	client.SetEndpointOverrides(map[string]string{
+		"/repositories":           "https://repos.example.com/2.0/",
+		"repositories/special-ws": "https://special.example.com/2.0",
+		"workspaces":              "https://ws.example.com/2.0",
+	})
+
+	assert.Equal(t, "https://repos.example.com/2.0", client.baseURLFor("repositories/ws/repo"))
+	assert.Equal(t, "https://special.example.com/2.0", client.baseURLFor("/repositories/special-ws/repo"))
+	assert.Equal(t, "https://ws.example.com/2.0", client.baseURLFor("workspaces/ws/members"))
+	assert.Equal(t, "https://api.bitbucket.org/2.0", client.baseURLFor("repositoriesx/ws"))
+	assert.Equal(t, "https://api.bitbucket.org/2.0", client.baseURLFor("user"))
+}
+
+func TestMakeRequestUsesEndpointOverride(t *testing.T) {
+	defaultServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("request should have been routed to the gateway: %s", r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer defaultServer.Close()
+
+	gatewayServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/gateway/repositories/ws/repo", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		writeResponse(t, w, []byte(`{"name": "repo", "slug": "repo"}`))
+	}))
+	defer gatewayServer.Close()
+
+	logger, _ := zap.NewDevelopment()
+	client := &Client{
+		baseURL:    defaultServer.URL,
+		httpClient: gatewayServer.Client(),
+		logger:     logger,
+	}
+
+	path := writeConfigFile(t, "api:\n  endpoint_overrides:\n    repositories: "+gatewayServer.URL+"/gateway\n")
+	require.NoError(t, ConfigureClient(client, &data.CmdExportFlags{ConfigFile: path}))
+
+	repo, err := client.GetRepository("ws", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, "repo", repo.Slug)
+}
+
+func TestConfigureClientWithoutConfig(t *testing.T) {
+	client := &Client{baseURL: "https://api.bitbucket.org/2.0"}
+	require.NoError(t, ConfigureClient(client, &data.CmdExportFlags{}))
+	assert.Nil(t, client.endpointOverrides)
+}
@@ -0,0 +1,211 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	// ConsistencyBestEffort fetches the branches of the mirror forward when
+	// pull requests reference commits missing from it, and keeps pull
+	// requests it cannot repair.
+	ConsistencyBestEffort = "best-effort"
+	// ConsistencyStrict exports the repository as of the clone: pull requests
+	// and comments created afterwards, and pull requests whose commits are
+	// missing from the mirror, are left out.
+	ConsistencyStrict = "strict"
+
+	// refetchRefspec fetches branches without forcing, so existing branches
+	// are only fast-forwarded.
+	refetchRefspec = "refs/heads/*:refs/heads/*"
+)
+
+// ValidateConsistency checks the --consistency value.
+func ValidateConsistency(value string) error {
+	switch value {
+	case "", ConsistencyBestEffort, ConsistencyStrict:
+		return nil
+	}
+	return fmt.Errorf("invalid value for --consistency: %q (supported: %s, %s)",
+		value, ConsistencyBestEffort, ConsistencyStrict)
+}
+
+func (e *Exporter) consistency() string {
+	if e.flags == nil || e.flags.Consistency == "" {
+		return ConsistencyBestEffort
+	}
+	return e.flags.Consistency
+}
+
+// recordCloneTime remembers when the mirror of a repository was taken. The
+// refs a clone sees are fixed when it starts, so anything created after this
+// moment may be missing from the mirror.
+func (e *Exporter) recordCloneTime(repoSlug string) {
+	if e.cloneTimes == nil {
+		e.cloneTimes = make(map[string]time.Time)
+	}
+	e.cloneTimes[repoSlug] = time.Now()
+}
+
+// createdAfterClone reports whether an RFC 3339 timestamp lies after the
+// clone of a repository.
+func (e *Exporter) createdAfterClone(repoSlug, createdAt string) bool {
+	cloneTime, ok := e.cloneTimes[repoSlug]
+	if !ok {
+		return false
+	}
+	created, err := time.Parse(time.RFC3339, createdAt)
+	return err == nil && created.After(cloneTime)
+}
+
+// missingPullRequestCommits lists the commits of a pull request that are
+// not in the mirror.
+func missingPullRequestCommits(repoPath string, pr data.PullRequest) []string {
+	shas := []string{pr.Base.SHA, pr.Head.SHA}
+	if pr.MergeCommitSHA != nil {
+		shas = append(shas, *pr.MergeCommitSHA)
+	}
+	var missing []string
+	for _, sha := range shas {
+		if sha != "" && !commitExists(repoPath, sha) {
+			missing = append(missing, sha)
+		}
+	}
+	return missing
+}
+
+// enforceConsistency reconciles the pull requests fetched from the API with
+// the mirror cloned earlier in the run, according to --consistency.
+func (e *Exporter) enforceConsistency(workspace, repoSlug string, prs []data.PullRequest) ([]data.PullRequest, error) {
+	if _, cloned := e.cloneTimes[repoSlug]; !cloned {
+		return prs, nil
+	}
+	repoPath := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+	if _, err := os.Stat(repoPath); err != nil {
+		return prs, nil
+	}
+
+	strict := e.consistency() == ConsistencyStrict
+	candidates := make([]data.PullRequest, 0, len(prs))
+	missing := make([][]string, 0, len(prs))
+	refetch := false
+	for _, pr := range prs {
+		if strict && e.createdAfterClone(repoSlug, pr.CreatedAt) {
+			e.logger.Info("Leaving out pull request created after the clone",
+				zap.String("pull_request", pr.URL),
+				zap.String("created_at", pr.CreatedAt))
+			e.report.Counts.ConsistencyDroppedPullRequests++
+			continue
+		}
+		candidates = append(candidates, pr)
+		missing = append(missing, missingPullRequestCommits(repoPath, pr))
+		refetch = refetch || len(missing[len(missing)-1]) > 0
+	}
+
+	if refetch && e.refetchAllowed() {
+		if err := e.fetchPullRequestBranches(workspace, repoSlug, repoPath); err != nil {
+			return nil, err
+		}
+		for i, pr := range candidates {
+			if len(missing[i]) == 0 {
+				continue
+			}
+			if missing[i] = missingPullRequestCommits(repoPath, pr); len(missing[i]) == 0 {
+				e.report.Counts.RefetchedPullRequests++
+			}
+		}
+	}
+
+	kept := make([]data.PullRequest, 0, len(candidates))
+	for i, pr := range candidates {
+		if len(missing[i]) > 0 {
+			if strict {
+				e.logger.Info("Leaving out pull request with commits missing from the mirror",
+					zap.String("pull_request", pr.URL),
+					zap.Strings("missing_commits", missing[i]))
+				e.report.Counts.ConsistencyDroppedPullRequests++
+				continue
+			}
+			e.logger.Warn("Pull request references commits missing from the mirror; the import may fail for it",
+				zap.String("pull_request", pr.URL),
+				zap.Strings("missing_commits", missing[i]))
+			e.report.Counts.InconsistentPullRequests++
+		}
+		kept = append(kept, pr)
+	}
+	return kept, nil
+}
+
+// refetchAllowed reports whether best-effort mode may fetch into the mirror.
+// Fetching would move branches past an --as-of snapshot, fetch the branches
+// --keep-ambiguous-prs renamed again next to their copies, and deepen a
+// shallow clone; placeholder repositories have nothing to fetch into.
+func (e *Exporter) refetchAllowed() bool {
+	return e.consistency() == ConsistencyBestEffort && e.asOf.IsZero() &&
+		!e.client.keepAmbiguousPRs && e.gitDepth == 0 && !e.skipGit
+}
+
+// fetchPullRequestBranches fetches the branches of the origin into the
+// mirror once per repository, so commits pushed to pull requests after the
+// clone are included. Branches only move forward: a branch rewritten
+// upstream keeps the commit it was cloned at.
+func (e *Exporter) fetchPullRequestBranches(workspace, repoSlug, repoPath string) error {
+	before, err := mirrorRefs(repoPath)
+	if err != nil {
+		return err
+	}
+	if err := e.fetchOrigin(repoPath, "--no-tags", "origin", refetchRefspec); err != nil {
+		if deadlineErr := e.checkDeadline(stagePullRequests); deadlineErr != nil {
+			return deadlineErr
+		}
+		// git fetch also fails when only some branches were rejected.
+		e.logger.Debug("Failed to fetch pull request branches into the mirror",
+			zap.String("repository", repoSlug),
+			zap.Error(err))
+	}
+	after, err := mirrorRefs(repoPath)
+	if err != nil {
+		return err
+	}
+
+	updated := changedRefs(before, after)
+	e.logger.Debug("Fetched pull request branches into the mirror",
+		zap.String("repository", repoSlug),
+		zap.Int("updated_refs", updated))
+	if updated == 0 {
+		return nil
+	}
+	return e.recheckMirror(workspace, repoSlug, repoPath)
+}
+
+// filterCommentsAfterClone leaves out comments created after the clone of
+// the repository in strict mode.
+func (e *Exporter) filterCommentsAfterClone(repoSlug string, regular []data.IssueComment,
+	review []data.PullRequestReviewComment) ([]data.IssueComment, []data.PullRequestReviewComment) {
+	if e.consistency() != ConsistencyStrict {
+		return regular, review
+	}
+
+	keptRegular := make([]data.IssueComment, 0, len(regular))
+	for _, comment := range regular {
+		if e.createdAfterClone(repoSlug, comment.CreatedAt) {
+			e.report.Counts.ConsistencyDroppedComments++
+			continue
+		}
+		keptRegular = append(keptRegular, comment)
+	}
+	keptReview := make([]data.PullRequestReviewComment, 0, len(review))
+	for _, comment := range review {
+		if e.createdAfterClone(repoSlug, comment.CreatedAt) {
+			e.report.Counts.ConsistencyDroppedComments++
+			continue
+		}
+		keptReview = append(keptReview, comment)
+	}
+	return keptRegular, keptReview
+}
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// consistencyFixture clones a work repository into an export mirror, then
+// adds a commit on a feature branch that only exists upstream.
+func consistencyFixture(t *testing.T, consistency string) (*Exporter, string, string) {
+	t.Helper()
+	outputDir := t.TempDir()
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "README.md"), []byte("hello\n"), 0644))
+	runGit(t, workDir, "add", "-A")
+	runGit(t, workDir, "commit", "-m", "initial")
+	base := runGit(t, workDir, "rev-parse", "HEAD")
+
+	mirrorPath := filepath.Join(outputDir, "repositories", "workspace", "repo.git")
+	require.NoError(t, exec.Command("git", "clone", "--mirror", workDir, mirrorPath).Run())
+
+	runGit(t, workDir, "checkout", "-b", "feature")
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "README.md"), []byte("hello\nworld\n"), 0644))
+	runGit(t, workDir, "commit", "-am", "feature")
+	head := runGit(t, workDir, "rev-parse", "HEAD")
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.flags = &data.CmdExportFlags{Consistency: consistency}
+	exporter.cloneTimes = map[string]time.Time{"repo": time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+	return exporter, base, head
+}
+
+func consistencyPullRequests(base, head string) []data.PullRequest {
+	return []data.PullRequest{
+		{URL: "https://bitbucket.org/workspace/repo/pull/1", CreatedAt: "2024-05-01T00:00:00Z",
+			Base: data.PRBranch{Ref: "main", SHA: base}, Head: data.PRBranch{Ref: "main", SHA: base}},
+		{URL: "https://bitbucket.org/workspace/repo/pull/2", CreatedAt: "2024-05-02T00:00:00Z",
+			Base: data.PRBranch{Ref: "main", SHA: base}, Head: data.PRBranch{Ref: "feature", SHA: head}},
+		{URL: "https://bitbucket.org/workspace/repo/pull/3", CreatedAt: "2024-07-01T00:00:00Z",
+			Base: data.PRBranch{Ref: "main", SHA: base}, Head: data.PRBranch{Ref: "main", SHA: base}},
+	}
+}
+
+func prURLs(prs []data.PullRequest) []string {
+	urls := []string{}
+	for _, pr := range prs {
+		urls = append(urls, pr.URL[strings.LastIndex(pr.URL, "/")+1:])
+	}
+	return urls
+}
+
+func TestEnforceConsistencyStrict(t *testing.T) {
+	exporter, base, head := consistencyFixture(t, ConsistencyStrict)
+
+	kept, err := exporter.enforceConsistency("workspace", "repo", consistencyPullRequests(base, head))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"1"}, prURLs(kept))
+	assert.Equal(t, 2, exporter.report.Counts.ConsistencyDroppedPullRequests)
+}
+
+func TestEnforceConsistencyBestEffortRefetches(t *testing.T) {
+	exporter, base, head := consistencyFixture(t, ConsistencyBestEffort)
+	mirrorPath := filepath.Join(exporter.outputDir, "repositories", "workspace", "repo.git")
+	require.False(t, commitExists(mirrorPath, head))
+
+	kept, err := exporter.enforceConsistency("workspace", "repo", consistencyPullRequests(base, head))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"1", "2", "3"}, prURLs(kept))
+	assert.True(t, commitExists(mirrorPath, head), "the feature branch was fetched into the mirror")
+	assert.Equal(t, 1, exporter.report.Counts.RefetchedPullRequests)
+	assert.Zero(t, exporter.report.Counts.InconsistentPullRequests)
+}
+
+func TestEnforceConsistencyBestEffortKeepsUnrepairable(t *testing.T) {
+	exporter, base, _ := consistencyFixture(t, "")
+	prs := []data.PullRequest{{URL: "https://bitbucket.org/workspace/repo/pull/4",
+		Base: data.PRBranch{Ref: "main", SHA: base},
+		Head: data.PRBranch{Ref: "deleted", SHA: strings.Repeat("1", 40)}}}
+
+	kept, err := exporter.enforceConsistency("workspace", "repo", prs)
+	require.NoError(t, err)
+
+	assert.Len(t, kept, 1)
+	assert.Equal(t, 1, exporter.report.Counts.InconsistentPullRequests)
+}
+
+func TestEnforceConsistencyWithoutClone(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.flags = &data.CmdExportFlags{Consistency: ConsistencyStrict}
+	prs := []data.PullRequest{{URL: "https://bitbucket.org/workspace/repo/pull/1", Head: data.PRBranch{SHA: "abc"}}}
+
+	kept, err := exporter.enforceConsistency("workspace", "repo", prs)
+	require.NoError(t, err)
+	assert.Equal(t, prs, kept)
+}
+
+func TestEnforceConsistencyBestEffortKeepsExistingBranches(t *testing.T) {
+	exporter, base, head := consistencyFixture(t, ConsistencyBestEffort)
+	mirrorPath := filepath.Join(exporter.outputDir, "repositories", "workspace", "repo.git")
+	workDir := runGit(t, mirrorPath, "remote", "get-url", "origin")
+
+	// Rewrite main upstream so it no longer descends from the cloned tip.
+	runGit(t, workDir, "checkout", "main")
+	runGit(t, workDir, "commit", "--amend", "-m", "rewritten")
+
+	kept, err := exporter.enforceConsistency("workspace", "repo", consistencyPullRequests(base, head))
+	require.NoError(t, err)
+
+	assert.Len(t, kept, 3)
+	assert.True(t, commitExists(mirrorPath, head), "new commits are fetched")
+	assert.Equal(t, base, runGit(t, mirrorPath, "rev-parse", "refs/heads/main"), "main is not force-updated")
+}
+
+func TestEnforceConsistencyBestEffortSkipsRefetch(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(*Exporter)
+	}{
+		{"keep ambiguous pull requests", func(e *Exporter) { e.client.keepAmbiguousPRs = true }},
+		{"shallow clone", func(e *Exporter) { e.gitDepth = 1 }},
+		{"skip git", func(e *Exporter) { e.skipGit = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter, base, head := consistencyFixture(t, ConsistencyBestEffort)
+			tt.setup(exporter)
+			mirrorPath := filepath.Join(exporter.outputDir, "repositories", "workspace", "repo.git")
+
+			kept, err := exporter.enforceConsistency("workspace", "repo", consistencyPullRequests(base, head))
+			require.NoError(t, err)
+
+			assert.Len(t, kept, 3)
+			assert.False(t, commitExists(mirrorPath, head), "the mirror is not fetched")
+			assert.Zero(t, exporter.report.Counts.RefetchedPullRequests)
+			assert.Equal(t, 1, exporter.report.Counts.InconsistentPullRequests)
+		})
+	}
+}
+
+func TestFilterCommentsAfterClone(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.cloneTimes = map[string]time.Time{"repo": time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+	regular := []data.IssueComment{{URL: "old", CreatedAt: "2024-05-01T00:00:00Z"}, {URL: "new", CreatedAt: "2024-06-02T00:00:00Z"}}
+	review := []data.PullRequestReviewComment{{URL: "new", CreatedAt: "2024-06-02T00:00:00Z"}}
+
+	keptRegular, keptReview := exporter.filterCommentsAfterClone("repo", regular, review)
+	assert.Len(t, keptRegular, 2, "best-effort keeps newer comments")
+	assert.Len(t, keptReview, 1)
+
+	exporter.flags = &data.CmdExportFlags{Consistency: ConsistencyStrict}
+	keptRegular, keptReview = exporter.filterCommentsAfterClone("repo", regular, review)
+	require.Len(t, keptRegular, 1)
+	assert.Equal(t, "old", keptRegular[0].URL)
+	assert.Empty(t, keptReview)
+	assert.Equal(t, 2, exporter.report.Counts.ConsistencyDroppedComments)
+}
+
+func TestValidateConsistency(t *testing.T) {
+	assert.NoError(t, ValidateConsistency(""))
+	assert.NoError(t, ValidateConsistency(ConsistencyStrict))
+	assert.NoError(t, ValidateConsistency(ConsistencyBestEffort))
+	err := ValidateConsistency("eventual")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid value for --consistency")
+}
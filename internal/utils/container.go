@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/version"
+	"go.uber.org/zap"
+	"golang.org/x/term"
+)
+
+const (
+	// minContainerFreeSpace is the free space below which an export in a
+	// container warns that its working directory is likely too small.
+	minContainerFreeSpace = 2 << 30
+
+	// containerImage is the image .goreleaser.yaml publishes from the
+	// Dockerfile; keep the two in step.
+	containerImage = "ghcr.io/katiem0/gh-bbc-exporter"
+)
+
+var (
+	// containerMarkerFiles are created by Docker and Podman in every container.
+	containerMarkerFiles = []string{"/.dockerenv", "/run/.containerenv"}
+	cgroupFile           = "/proc/1/cgroup"
+	cgroupMarkers        = []string{"docker", "kubepods", "containerd", "libpod"}
+)
+
+// RuntimeEnvironment describes where the exporter is running.
+type RuntimeEnvironment struct {
+	Container   bool // Running in a container, e.g. an ephemeral CI runner
+	Interactive bool // stderr is a terminal
+}
+
+// DetectRuntime reports whether the exporter runs in a container and
+// whether its output goes to a terminal. BBC_EXPORTER_CONTAINER=1 forces
+// container mode where detection fails, and =0 disables it.
+func DetectRuntime() RuntimeEnvironment {
+	return RuntimeEnvironment{
+		Container:   inContainer(),
+		Interactive: term.IsTerminal(int(os.Stderr.Fd())),
+	}
+}
+
+func inContainer() bool {
+	switch strings.ToLower(os.Getenv("BBC_EXPORTER_CONTAINER")) {
+	case "1", "true", "yes":
+		return true
+	case "0", "false", "no":
+		return false
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true
+	}
+	for _, marker := range containerMarkerFiles {
+		if _, err := os.Stat(marker); err == nil {
+			return true
+		}
+	}
+	content, err := os.ReadFile(cgroupFile)
+	if err != nil {
+		return false
+	}
+	for _, marker := range cgroupMarkers {
+		if strings.Contains(string(content), marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRuntimePrerequisites fails early when git is missing, and in a
+// container warns when the directories the export writes to have little
+// free space, which is common for the writable layer and /tmp of runners.
+func CheckRuntimePrerequisites(env RuntimeEnvironment, outputDir, tempDir string, logger *zap.Logger) error {
+	if !isExecutableInPath("git") {
+		if env.Container {
+			return fmt.Errorf("git was not found in PATH: use the %s image or install git in the container", containerImage)
+		}
+		return fmt.Errorf("git was not found in PATH: install Git %s or higher", version.MinimumGitVersion)
+	}
+
+	if !env.Container {
+		return nil
+	}
+	logger.Info("Running in a container",
+		zap.Bool("interactive", env.Interactive))
+
+	dirs := []string{outputDir, os.TempDir()}
+	if tempDir != "" {
+		dirs = append(dirs, tempDir)
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			dir = "."
+		}
+		free, ok := freeDiskSpace(existingParent(dir))
+		if !ok || free >= minContainerFreeSpace {
+			continue
+		}
+		logger.Warn("Little free space for the export in this container; mount a volume with --output or --temp-dir",
+			zap.String("path", dir),
+			zap.String("free", FormatBytes(free)))
+	}
+	return nil
+}
+
+// existingParent returns dir or its closest ancestor that exists, so free
+// space can be checked before the output directory is created.
+func existingParent(dir string) string {
+	dir = filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// FormatBytes renders a byte count with binary units, e.g. 1.5 GiB.
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func withContainerMarkers(t *testing.T, markers []string, cgroup string) {
+	t.Helper()
+	originalMarkers, originalCgroup := containerMarkerFiles, cgroupFile
+	t.Cleanup(func() {
+		containerMarkerFiles, cgroupFile = originalMarkers, originalCgroup
+	})
+	containerMarkerFiles = markers
+	cgroupFile = cgroup
+}
+
+func TestInContainer(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("BBC_EXPORTER_CONTAINER", "")
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	withContainerMarkers(t, []string{filepath.Join(dir, ".dockerenv")}, filepath.Join(dir, "cgroup"))
+	assert.False(t, inContainer())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "cgroup"), []byte("0::/kubepods/burstable/pod123\n"), 0644))
+	assert.True(t, inContainer())
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "cgroup")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".dockerenv"), nil, 0644))
+	assert.True(t, inContainer())
+
+	t.Setenv("BBC_EXPORTER_CONTAINER", "0")
+	assert.False(t, inContainer(), "the environment variable overrides detection")
+}
+
+func TestInContainerForcedByEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	withContainerMarkers(t, nil, filepath.Join(dir, "cgroup"))
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	t.Setenv("BBC_EXPORTER_CONTAINER", "1")
+	assert.True(t, inContainer())
+}
+
+func TestCheckRuntimePrerequisitesMissingGit(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	err := CheckRuntimePrerequisites(RuntimeEnvironment{Container: true}, t.TempDir(), "", zap.NewNop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "git was not found")
+	assert.Contains(t, err.Error(), containerImage)
+
+	err = CheckRuntimePrerequisites(RuntimeEnvironment{}, t.TempDir(), "", zap.NewNop())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "or higher")
+}
+
+func TestCheckRuntimePrerequisitesInContainer(t *testing.T) {
+	if !isExecutableInPath("git") {
+		t.Skip("git not available")
+	}
+	core, logs := observer.New(zapcore.InfoLevel)
+
+	outputDir := filepath.Join(t.TempDir(), "not", "created", "yet")
+	require.NoError(t, CheckRuntimePrerequisites(RuntimeEnvironment{Container: true}, outputDir, "", zap.New(core)))
+	assert.Equal(t, 1, logs.FilterMessage("Running in a container").Len())
+
+	logs.TakeAll()
+	require.NoError(t, CheckRuntimePrerequisites(RuntimeEnvironment{}, outputDir, "", zap.New(core)))
+	assert.Equal(t, 0, logs.Len())
+}
+
+func TestExistingParent(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, dir, existingParent(filepath.Join(dir, "a", "b")))
+	assert.Equal(t, dir, existingParent(dir))
+}
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "512 B", FormatBytes(512))
+	assert.Equal(t, "1.5 KiB", FormatBytes(1536))
+	assert.Equal(t, "2.0 GiB", FormatBytes(2<<30))
+}
+
+func TestContainerImageIsPublished(t *testing.T) {
+	config, err := os.ReadFile(filepath.Join("..", "..", ".goreleaser.yaml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(config), `"`+containerImage+`:{{ .Tag }}"`, "the image named in errors is the one released")
+}
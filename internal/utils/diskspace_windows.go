@@ -0,0 +1,9 @@
+//go:build windows
+
+package utils
+
+// freeDiskSpace is not implemented on Windows, where the exporter does not
+// run in Linux containers.
+func freeDiskSpace(path string) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	docsReportFile = "docs-report.json"
+
+	// maxDocBytes skips markdown files too large to be hand-written docs.
+	maxDocBytes = 1 << 20
+	// maxFindingText caps the line quoted in a finding.
+	maxFindingText = 200
+
+	docTOCMacro         = "toc_macro"
+	docWikiLink         = "wiki_link"
+	docLexerHint        = "lexer_hint"
+	docRelativeSrcLink  = "relative_src_link"
+	docBitbucketSrcLink = "bitbucket_src_link"
+)
+
+var (
+	markdownExtensions = map[string]bool{
+		".md": true, ".markdown": true, ".mdown": true, ".mkd": true, ".mkdn": true,
+	}
+
+	// Bitbucket renders markdown with Python-Markdown extensions that GitHub
+	// shows verbatim: the [TOC] macro, [[wiki links]] and :::lang or #!lang
+	// lexer hints on the first line of indented code blocks.
+	tocMacroPattern   = regexp.MustCompile(`^\s*\[TOC\]\s*$`)
+	wikiLinkPattern   = regexp.MustCompile(`\[\[[^\[\]]+\]\]`)
+	lexerHintPattern  = regexp.MustCompile(`^(?: {4}|\t)\s*(?::::|#!)[A-Za-z][\w+-]*\s*$`)
+	codeFencePattern  = regexp.MustCompile("^\\s*(```|~~~)")
+	codeSpanPattern   = regexp.MustCompile("`[^`]*`")
+	markdownLinkRegex = regexp.MustCompile(`\]\(\s*<?([^)\s>]+)`)
+	htmlLinkPattern   = regexp.MustCompile(`(?i)\b(?:src|href)\s*=\s*["']([^"']+)["']`)
+)
+
+// SetAnalyzeDocs scans the markdown files of every repository for
+// Bitbucket-specific syntax and lists them in docs-report.json.
+func (e *Exporter) SetAnalyzeDocs(enabled bool) {
+	e.analyzeDocs = enabled
+}
+
+// scanDocs records the Bitbucket-specific constructs in the markdown files
+// on the default branch of a cloned repository.
+func (e *Exporter) scanDocs(workspace, repoSlug string) {
+	if !e.analyzeDocs {
+		return
+	}
+
+	repository := workspace + "/" + repoSlug
+	repoPath := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+	findings, err := scanMarkdownFiles(repoPath, repository)
+	if err != nil {
+		e.logger.Warn("Failed to analyze markdown files",
+			zap.String("repository", repository),
+			zap.Error(err))
+		return
+	}
+	e.logger.Debug("Analyzed markdown files",
+		zap.String("repository", repository),
+		zap.Int("findings", len(findings)))
+	e.docFindings = append(e.docFindings, findings...)
+}
+
+// scanMarkdownFiles returns the findings in the markdown files at HEAD of a
+// repository. A repository without commits has none.
+func scanMarkdownFiles(repoPath, repository string) ([]data.DocFinding, error) {
+	cmd := exec.Command("git", "ls-tree", "-r", "-l", "-z", "HEAD")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		if !commitExists(repoPath, "HEAD") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list repository files: %w", err)
+	}
+
+	type blob struct{ oid, path string }
+	var docs []blob
+	files := make(map[string]bool)
+	for _, entry := range strings.Split(string(output), "\x00") {
+		meta, filePath, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 4 || fields[1] != "blob" {
+			continue
+		}
+		files[filePath] = true
+		for dir := path.Dir(filePath); dir != "."; dir = path.Dir(dir) {
+			files[dir] = true
+		}
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+		if markdownExtensions[strings.ToLower(path.Ext(filePath))] && size <= maxDocBytes {
+			docs = append(docs, blob{oid: fields[2], path: filePath})
+		}
+	}
+
+	findings := []data.DocFinding{}
+	for _, doc := range docs {
+		cmd := exec.Command("git", "cat-file", "blob", doc.oid)
+		cmd.Dir = repoPath
+		content, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", doc.path, err)
+		}
+		findings = append(findings, scanMarkdown(repository, doc.path, content, files)...)
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings, nil
+}
+
+// scanMarkdown returns the Bitbucket-specific constructs of one markdown
+// file. files holds the paths and directories of the repository, to tell
+// Bitbucket source browser links from links to files that exist. Fenced code
+// blocks and code spans are skipped.
+func scanMarkdown(repository, file string, content []byte, files map[string]bool) []data.DocFinding {
+	var findings []data.DocFinding
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxDocBytes)
+	fence := ""
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if match := codeFencePattern.FindStringSubmatch(line); match != nil {
+			switch fence {
+			case "":
+				fence = match[1]
+			case match[1]:
+				fence = ""
+			}
+			continue
+		}
+		if fence != "" {
+			continue
+		}
+
+		add := func(construct string) {
+			text := strings.TrimSpace(line)
+			if len(text) > maxFindingText {
+				text = text[:maxFindingText]
+			}
+			findings = append(findings, data.DocFinding{
+				Repository: repository,
+				File:       file,
+				Line:       lineNumber,
+				Construct:  construct,
+				Text:       text,
+			})
+		}
+
+		if lexerHintPattern.MatchString(line) {
+			add(docLexerHint)
+			continue
+		}
+		if tocMacroPattern.MatchString(line) {
+			add(docTOCMacro)
+		}
+		prose := codeSpanPattern.ReplaceAllString(line, "")
+		if wikiLinkPattern.MatchString(prose) {
+			add(docWikiLink)
+		}
+		var targets []string
+		for _, match := range markdownLinkRegex.FindAllStringSubmatch(prose, -1) {
+			targets = append(targets, match[1])
+		}
+		for _, match := range htmlLinkPattern.FindAllStringSubmatch(prose, -1) {
+			targets = append(targets, match[1])
+		}
+		for _, construct := range uniqueConstructs(file, targets, files) {
+			add(construct)
+		}
+	}
+	return findings
+}
+
+// uniqueConstructs classifies the link targets of a line, reporting each
+// kind of problem once.
+func uniqueConstructs(file string, targets []string, files map[string]bool) []string {
+	var constructs []string
+	for _, target := range targets {
+		construct := srcLinkConstruct(file, target, files)
+		if construct != "" && !slices.Contains(constructs, construct) {
+			constructs = append(constructs, construct)
+		}
+	}
+	return constructs
+}
+
+// srcLinkConstruct reports links into Bitbucket's source browser: absolute
+// bitbucket.org/.../src/ URLs, which keep pointing at Bitbucket, and
+// relative links through a src/ segment to paths that do not exist in the
+// repository, which only resolve on Bitbucket. GitHub browses files under
+// blob/ instead.
+func srcLinkConstruct(file, target string, files map[string]bool) string {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return ""
+	}
+	if parsed.Scheme != "" || parsed.Host != "" {
+		host := strings.ToLower(parsed.Hostname())
+		if (host == "bitbucket.org" || strings.HasSuffix(host, ".bitbucket.org")) &&
+			strings.Contains(parsed.Path, "/src/") {
+			return docBitbucketSrcLink
+		}
+		return ""
+	}
+	if parsed.Path == "" || !strings.Contains("/"+parsed.Path, "/src/") {
+		return ""
+	}
+
+	resolved := strings.TrimPrefix(parsed.Path, "/")
+	if !strings.HasPrefix(parsed.Path, "/") {
+		resolved = path.Join(path.Dir(file), parsed.Path)
+	}
+	if files[path.Clean(resolved)] {
+		return ""
+	}
+	return docRelativeSrcLink
+}
+
+// writeDocsReport writes the markdown findings next to the archive.
+func (e *Exporter) writeDocsReport() error {
+	if !e.analyzeDocs {
+		return nil
+	}
+
+	report := data.DocsReport{Findings: e.docFindings}
+	if report.Findings == nil {
+		report.Findings = []data.DocFinding{}
+	}
+	if err := e.writeJSONFile(docsReportFile, report); err != nil {
+		return err
+	}
+
+	e.logger.Info("Wrote markdown analysis report (excluded from import archive)",
+		zap.String("file", docsReportFile),
+		zap.Int("findings", len(e.docFindings)))
+	return nil
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestScanMarkdown(t *testing.T) {
+	files := map[string]bool{"docs": true, "docs/src": true, "docs/src/setup.md": true, "README.md": true}
+	content := "[TOC]\n" +
+		"See [[Getting Started]] and `[[not a link]]`.\n" +
+		"Read [setup](src/setup.md) and [usage](../src/master/docs/usage.md).\n" +
+		"<img src=\"/acme/api/src/master/logo.png\"> [upstream](https://bitbucket.org/acme/api/src/master/)\n" +
+		"```\n[TOC]\n```\n" +
+		"    :::python\n" +
+		"    #!/bin/sh\n" +
+		"[GitHub](https://github.com/acme/api/blob/main/src/x.go)\n"
+
+	findings := scanMarkdown("acme/api", "docs/README.md", []byte(content), files)
+
+	type finding struct {
+		line      int
+		construct string
+	}
+	var got []finding
+	for _, f := range findings {
+		assert.Equal(t, "acme/api", f.Repository)
+		assert.Equal(t, "docs/README.md", f.File)
+		got = append(got, finding{f.Line, f.Construct})
+	}
+	assert.Equal(t, []finding{
+		{1, docTOCMacro},
+		{2, docWikiLink},
+		{3, docRelativeSrcLink},
+		{4, docBitbucketSrcLink},
+		{4, docRelativeSrcLink},
+		{8, docLexerHint},
+	}, got)
+	assert.Equal(t, "[TOC]", findings[0].Text)
+}
+
+func TestScanDocs(t *testing.T) {
+	outputDir := t.TempDir()
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "README.md"), []byte("# API\n\n[TOC]\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "notes.txt"), []byte("[TOC]\n"), 0644))
+	runGit(t, workDir, "add", "-A")
+	runGit(t, workDir, "commit", "-m", "initial")
+	mirror := filepath.Join(outputDir, "repositories", "workspace", "repo.git")
+	runGit(t, t.TempDir(), "clone", "--mirror", workDir, mirror)
+	emptyMirror := filepath.Join(outputDir, "repositories", "workspace", "empty.git")
+	runGit(t, t.TempDir(), "init", "--bare", emptyMirror)
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.scanDocs("workspace", "repo")
+	assert.Empty(t, exporter.docFindings, "markdown is only analyzed when requested")
+
+	exporter.SetAnalyzeDocs(true)
+	exporter.scanDocs("workspace", "repo")
+	exporter.scanDocs("workspace", "empty")
+	require.NoError(t, exporter.writeDocsReport())
+
+	content, err := os.ReadFile(filepath.Join(outputDir, docsReportFile))
+	require.NoError(t, err)
+	var report data.DocsReport
+	require.NoError(t, json.Unmarshal(content, &report))
+	assert.Equal(t, []data.DocFinding{{
+		Repository: "workspace/repo",
+		File:       "README.md",
+		Line:       3,
+		Construct:  docTOCMacro,
+		Text:       "[TOC]",
+	}}, report.Findings)
+	assert.True(t, sidecarPaths[docsReportFile])
+}
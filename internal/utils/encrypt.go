@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	encryptionAge = "age"
+	encryptionGPG = "gpg"
+)
+
+// ParseEncryption parses an --encrypt value of the form method:recipient.
+// An empty value disables encryption.
+func ParseEncryption(spec string) (*data.ArchiveEncryption, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	method, recipient, found := strings.Cut(spec, ":")
+	method = strings.ToLower(strings.TrimSpace(method))
+	recipient = strings.TrimSpace(recipient)
+	if !found || recipient == "" {
+		return nil, fmt.Errorf("invalid value for --encrypt: %q (expected format: age:<recipient> or gpg:<recipient>)", spec)
+	}
+	if method != encryptionAge && method != encryptionGPG {
+		return nil, fmt.Errorf("invalid value for --encrypt: unsupported method %q (supported: age, gpg)", method)
+	}
+
+	return &data.ArchiveEncryption{Method: method, Recipient: recipient}, nil
+}
+
+// SetEncryption encrypts the produced archive for the given recipient.
+func (e *Exporter) SetEncryption(encryption *data.ArchiveEncryption) {
+	e.encryption = encryption
+}
+
+func encryptionCommandArgs(encryption *data.ArchiveEncryption, inputPath, outputPath string) []string {
+	if encryption.Method == encryptionGPG {
+		return []string{"gpg", "--batch", "--yes", "--trust-model", "always",
+			"--encrypt", "--recipient", encryption.Recipient,
+			"--output", outputPath, inputPath}
+	}
+	return []string{"age", "--encrypt", "--recipient", encryption.Recipient,
+		"--output", outputPath, inputPath}
+}
+
+// encryptArchive replaces the plaintext archive with an encrypted copy and
+// returns the new path. The plaintext archive is removed even when encryption
+// fails, so an unencrypted archive is never left behind for upload.
+func (e *Exporter) encryptArchive(archivePath string) (string, error) {
+	encryptedPath := archivePath + "." + e.encryption.Method
+
+	e.logger.Info("Encrypting archive",
+		zap.String("method", e.encryption.Method),
+		zap.String("recipient", e.encryption.Recipient),
+		zap.String("archive", encryptedPath))
+
+	ctx, cancel := e.deadlineContext()
+	defer cancel()
+	args := encryptionCommandArgs(e.encryption, archivePath, encryptedPath)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	output, cmdErr := cmd.CombinedOutput()
+
+	if err := os.Remove(archivePath); err != nil {
+		e.logger.Warn("Failed to remove unencrypted archive",
+			zap.String("archive", archivePath),
+			zap.Error(err))
+	}
+
+	if cmdErr != nil {
+		if err := os.Remove(encryptedPath); err != nil && !os.IsNotExist(err) {
+			e.logger.Warn("Failed to remove partial encrypted archive", zap.Error(err))
+		}
+		if deadlineErr := e.checkDeadline(stageArchive); deadlineErr != nil {
+			return "", deadlineErr
+		}
+		return "", fmt.Errorf("failed to encrypt archive with %s: %s: %w",
+			e.encryption.Method, strings.TrimSpace(string(output)), cmdErr)
+	}
+
+	return encryptedPath, nil
+}
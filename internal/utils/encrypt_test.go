@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseEncryption(t *testing.T) {
+	encryption, err := ParseEncryption("")
+	assert.NoError(t, err)
+	assert.Nil(t, encryption)
+
+	encryption, err = ParseEncryption("age:age1examplerecipient")
+	require.NoError(t, err)
+	assert.Equal(t, &data.ArchiveEncryption{Method: "age", Recipient: "age1examplerecipient"}, encryption)
+
+	encryption, err = ParseEncryption("GPG:ops@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "gpg", encryption.Method)
+	assert.Equal(t, "ops@example.com", encryption.Recipient)
+
+	_, err = ParseEncryption("age")
+	assert.ErrorContains(t, err, "expected format")
+
+	_, err = ParseEncryption("zip:secret")
+	assert.ErrorContains(t, err, "unsupported method")
+}
+
+func TestEncryptionCommandArgs(t *testing.T) {
+	args := encryptionCommandArgs(&data.ArchiveEncryption{Method: "age", Recipient: "age1abc"}, "in.tar.gz", "in.tar.gz.age")
+	assert.Equal(t, []string{"age", "--encrypt", "--recipient", "age1abc", "--output", "in.tar.gz.age", "in.tar.gz"}, args)
+
+	args = encryptionCommandArgs(&data.ArchiveEncryption{Method: "gpg", Recipient: "ops@example.com"}, "in.tar.gz", "in.tar.gz.gpg")
+	assert.Equal(t, "gpg", args[0])
+	assert.Contains(t, args, "ops@example.com")
+	assert.Equal(t, "in.tar.gz", args[len(args)-1])
+}
+
+func TestEncryptArchiveWithGPG(t *testing.T) {
+	if _, err := exec.LookPath("gpg"); err != nil {
+		t.Skip("gpg not available for testing")
+	}
+	gnupgHome, err := os.MkdirTemp("", "gnupg")
+	require.NoError(t, err)
+	defer func() {
+		_ = exec.Command("gpgconf", "--homedir", gnupgHome, "--kill", "all").Run()
+		_ = os.RemoveAll(gnupgHome)
+	}()
+	t.Setenv("GNUPGHOME", gnupgHome)
+
+	genKey := exec.Command("gpg", "--batch", "--passphrase", "", "--quick-gen-key", "export@example.com", "default", "default", "never")
+	if output, err := genKey.CombinedOutput(); err != nil {
+		t.Skipf("could not generate gpg key: %s", output)
+	}
+
+	archivePath := filepath.Join(t.TempDir(), "export.tar.gz")
+	require.NoError(t, os.WriteFile(archivePath, []byte("archive contents"), 0644))
+
+	exporter := &Exporter{logger: zap.NewNop()}
+	exporter.SetEncryption(&data.ArchiveEncryption{Method: "gpg", Recipient: "export@example.com"})
+
+	encryptedPath, err := exporter.encryptArchive(archivePath)
+	require.NoError(t, err)
+	assert.Equal(t, archivePath+".gpg", encryptedPath)
+	assert.NoFileExists(t, archivePath)
+
+	decrypted, err := exec.Command("gpg", "--batch", "--quiet", "--decrypt", encryptedPath).Output()
+	require.NoError(t, err)
+	assert.Equal(t, "archive contents", string(decrypted))
+}
+
+func TestEncryptArchiveFailureRemovesPlaintext(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	archivePath := filepath.Join(t.TempDir(), "export.tar.gz")
+	require.NoError(t, os.WriteFile(archivePath, []byte("archive contents"), 0644))
+
+	exporter := &Exporter{logger: zap.NewNop()}
+	exporter.SetEncryption(&data.ArchiveEncryption{Method: "age", Recipient: "age1abc"})
+
+	_, err := exporter.encryptArchive(archivePath)
+	assert.ErrorContains(t, err, "failed to encrypt archive with age")
+	assert.NoFileExists(t, archivePath)
+	assert.NoFileExists(t, archivePath+".age")
+}
@@ -0,0 +1,235 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultRateLimitPerHour is Bitbucket Cloud's hourly quota for
+	// authenticated requests to repository data.
+	DefaultRateLimitPerHour = 1000
+	// DefaultRequestLatency is the typical round trip of a Bitbucket API
+	// request.
+	DefaultRequestLatency = 400 * time.Millisecond
+	// DefaultCloneThroughput is the assumed clone speed in MiB per second.
+	DefaultCloneThroughput = 20.0
+
+	// estimateSamplePullRequests is how many of the most recent pull
+	// requests of each repository are sampled for their comment count.
+	estimateSamplePullRequests = 50
+	// exportWorkspaceRequests are made once per export: the workspace
+	// details and the first page of workspace members.
+	exportWorkspaceRequests = 2
+	// pullRequestJSONBytes and commentJSONBytes are the average sizes of an
+	// exported pull request and comment record, and metadataCompression the
+	// gzip ratio of those records. Git packs do not compress further.
+	pullRequestJSONBytes = 3 << 10
+	commentJSONBytes     = 1 << 10
+	metadataCompression  = 5
+)
+
+// EstimateOptions are the assumptions of an export estimate.
+type EstimateOptions struct {
+	Concurrency      int           // Workers fetching comments, as --concurrency
+	Nice             bool          // Simulate --nice
+	RateLimitPerHour int           // Hourly API quota of the workspace
+	RequestLatency   time.Duration // Average API round trip
+	CloneThroughput  float64       // MiB per second
+}
+
+// SampleRepository reads the size and pull request count of a repository
+// and the comment counts of its most recent pull requests, in one request
+// when the repository details are already known.
+func (c *Client) SampleRepository(workspace string, repo data.BitbucketRepository, openPRsOnly bool) (data.RepositorySample, error) {
+	sample := data.RepositorySample{Repository: repo.Slug, SizeBytes: repo.Size}
+
+	state := "ALL"
+	if openPRsOnly {
+		state = "OPEN"
+	}
+	var response data.BitbucketPRResponse
+	endpoint := fmt.Sprintf("repositories/%s/%s/pullrequests?state=%s&pagelen=%d",
+		workspace, repo.Slug, state, estimateSamplePullRequests)
+	if err := c.makeRequest("GET", endpoint, &response); err != nil {
+		return sample, fmt.Errorf("failed to sample pull requests of %s: %w", repo.Slug, err)
+	}
+	sample.PullRequests = max(response.Size, len(response.Values))
+	sample.SampledPullRequests = len(response.Values)
+	for _, pr := range response.Values {
+		sample.SampledComments += pr.CommentCount
+	}
+	return sample, nil
+}
+
+// EstimateExport simulates the API requests, duration and archive size of
+// exporting the sampled repositories with default options. Comment counts
+// of the sampled pull requests are extrapolated to all pull requests.
+func EstimateExport(workspace string, samples []data.RepositorySample, options EstimateOptions) data.ExportEstimate {
+	rateLimit := options.RateLimitPerHour
+	if rateLimit <= 0 {
+		rateLimit = DefaultRateLimitPerHour
+	}
+	latency := options.RequestLatency
+	if latency <= 0 {
+		latency = DefaultRequestLatency
+	}
+	throughput := options.CloneThroughput
+	if throughput <= 0 {
+		throughput = DefaultCloneThroughput
+	}
+	workers := max(options.Concurrency, 1)
+	prPageLen, commentPageLen := 50, 100
+	if options.Nice {
+		workers = 1
+		latency = max(latency, niceRequestDelay)
+		prPageLen, commentPageLen = prPageLen/2, commentPageLen/2
+	}
+
+	estimate := data.ExportEstimate{
+		Workspace:        workspace,
+		Repositories:     []data.RepositoryEstimate{},
+		APIRequests:      exportWorkspaceRequests,
+		RateLimitPerHour: rateLimit,
+		Concurrency:      workers,
+	}
+	// Pull request pages and repository details are fetched serially; the
+	// comments and activity of pull requests are spread over the workers.
+	serialRequests := exportWorkspaceRequests
+	var cloneBytes int64
+	for _, sample := range samples {
+		comments := 0
+		commentRequests := sample.PullRequests
+		if sample.SampledPullRequests > 0 {
+			perPR := float64(sample.SampledComments) / float64(sample.SampledPullRequests)
+			comments = int(math.Round(perPR * float64(sample.PullRequests)))
+			commentRequests = sample.PullRequests * pages(int(math.Ceil(perPR)), commentPageLen)
+		}
+		listRequests := 1 + pages(sample.PullRequests, prPageLen)
+		// Reviews come from one page of activity per pull request.
+		activityRequests := sample.PullRequests
+		repoEstimate := data.RepositoryEstimate{
+			Repository:   sample.Repository,
+			SizeBytes:    sample.SizeBytes,
+			PullRequests: sample.PullRequests,
+			Comments:     comments,
+			APIRequests:  listRequests + commentRequests + activityRequests,
+			ArchiveBytes: sample.SizeBytes +
+				int64(sample.PullRequests*pullRequestJSONBytes+comments*commentJSONBytes)/metadataCompression,
+		}
+		estimate.Repositories = append(estimate.Repositories, repoEstimate)
+		estimate.APIRequests += repoEstimate.APIRequests
+		estimate.ArchiveBytes += repoEstimate.ArchiveBytes
+		serialRequests += listRequests
+		cloneBytes += sample.SizeBytes
+	}
+
+	parallelRequests := estimate.APIRequests - serialRequests
+	apiDuration := latency.Seconds() *
+		(float64(serialRequests) + math.Ceil(float64(parallelRequests)/float64(workers)))
+	// The first hour's quota is available at once; every further request
+	// waits for the quota to refill.
+	if overQuota := estimate.APIRequests - rateLimit; overQuota > 0 {
+		apiDuration = math.Max(apiDuration, float64(overQuota)/float64(rateLimit)*time.Hour.Seconds())
+	}
+	estimate.QuotaShare = math.Round(float64(estimate.APIRequests)/float64(rateLimit)*100) / 100
+	estimate.APIDurationSeconds = math.Round(apiDuration)
+	estimate.CloneDurationSeconds = math.Round(float64(cloneBytes) / (throughput * (1 << 20)))
+	estimate.DurationSeconds = estimate.APIDurationSeconds + estimate.CloneDurationSeconds
+	return estimate
+}
+
+// pages returns how many pages of pageLen items hold n items; an empty
+// collection still takes one request.
+func pages(n, pageLen int) int {
+	if n <= 0 {
+		return 1
+	}
+	return (n + pageLen - 1) / pageLen
+}
+
+// RunEstimate samples the repositories selected by cmdFlags and estimates
+// the cost of exporting them.
+func RunEstimate(cmdFlags *data.CmdExportFlags, options EstimateOptions, logger *zap.Logger) (*data.ExportEstimate, error) {
+	client := NewClient(
+		cmdFlags.BitbucketAPIURL,
+		cmdFlags.BitbucketAccessToken,
+		cmdFlags.BitbucketAPIToken,
+		cmdFlags.BitbucketEmail,
+		cmdFlags.BitbucketUser,
+		cmdFlags.BitbucketAppPass,
+		logger,
+		"",
+		true,
+	)
+	if err := ConfigureClient(client, cmdFlags); err != nil {
+		return nil, err
+	}
+
+	repositories, err := estimateRepositories(client, cmdFlags, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	var samples []data.RepositorySample
+	for _, repo := range repositories {
+		sample, err := client.SampleRepository(cmdFlags.Workspace, repo, cmdFlags.OpenPRsOnly)
+		if err != nil {
+			return nil, err
+		}
+		logger.Debug("Sampled repository",
+			zap.String("repository", sample.Repository),
+			zap.Int64("size_bytes", sample.SizeBytes),
+			zap.Int("pull_requests", sample.PullRequests))
+		samples = append(samples, sample)
+	}
+
+	estimate := EstimateExport(cmdFlags.Workspace, samples, options)
+	estimate.SamplingRequests = client.throttling.requests
+	return &estimate, nil
+}
+
+// estimateRepositories returns the repositories an export with the same
+// flags would include.
+func estimateRepositories(client *Client, cmdFlags *data.CmdExportFlags, logger *zap.Logger) ([]data.BitbucketRepository, error) {
+	if !cmdFlags.AllRepos {
+		var repositories []data.BitbucketRepository
+		for _, repoSlug := range RepositorySlugs(cmdFlags) {
+			repo, err := client.GetRepository(cmdFlags.Workspace, repoSlug)
+			if err != nil {
+				return nil, err
+			}
+			if repo.Slug == "" {
+				repo.Slug = repoSlug
+			}
+			repositories = append(repositories, *repo)
+		}
+		return repositories, nil
+	}
+
+	repositories, err := client.GetWorkspaceRepositories(cmdFlags.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	repositories = filterGitRepositories(repositories, logger)
+	var include, exclude []string
+	if cmdFlags.IncludeReposFile != "" {
+		if include, err = LoadRepositoryList(cmdFlags.IncludeReposFile); err != nil {
+			return nil, err
+		}
+	}
+	if cmdFlags.ExcludeReposFile != "" {
+		if exclude, err = LoadRepositoryList(cmdFlags.ExcludeReposFile); err != nil {
+			return nil, err
+		}
+	}
+	repositories = FilterRepositoriesByList(repositories, include, exclude, logger)
+	if len(repositories) == 0 {
+		return nil, fmt.Errorf("no repositories found in workspace %s", cmdFlags.Workspace)
+	}
+	return repositories, nil
+}
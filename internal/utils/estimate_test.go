@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestEstimateExport(t *testing.T) {
+	samples := []data.RepositorySample{
+		{Repository: "api", SizeBytes: 200 << 20, PullRequests: 120, SampledPullRequests: 50, SampledComments: 150},
+		{Repository: "docs", SizeBytes: 10 << 20},
+	}
+
+	estimate := EstimateExport("workspace", samples, EstimateOptions{
+		Concurrency: 4, RequestLatency: time.Second, CloneThroughput: 10})
+
+	require.Len(t, estimate.Repositories, 2)
+	api := estimate.Repositories[0]
+	assert.Equal(t, 360, api.Comments, "3 comments per sampled pull request")
+	assert.Equal(t, 1+3+120+120, api.APIRequests,
+		"repository, 3 pull request pages, 1 comment and 1 activity page per pull request")
+	assert.Equal(t, int64(200<<20)+int64(120*pullRequestJSONBytes+360*commentJSONBytes)/metadataCompression, api.ArchiveBytes)
+	docs := estimate.Repositories[1]
+	assert.Equal(t, 2, docs.APIRequests, "repository and an empty pull request page")
+
+	assert.Equal(t, 2+244+2, estimate.APIRequests)
+	assert.Equal(t, DefaultRateLimitPerHour, estimate.RateLimitPerHour)
+	assert.Equal(t, 0.25, estimate.QuotaShare)
+	assert.Equal(t, float64(2+4+2+60), estimate.APIDurationSeconds, "comment and activity requests are spread over 4 workers")
+	assert.Equal(t, float64(21), estimate.CloneDurationSeconds)
+	assert.Equal(t, estimate.APIDurationSeconds+estimate.CloneDurationSeconds, estimate.DurationSeconds)
+}
+
+func TestEstimateExportRateLimited(t *testing.T) {
+	samples := []data.RepositorySample{
+		{Repository: "monorepo", PullRequests: 5000, SampledPullRequests: 50, SampledComments: 100},
+	}
+
+	fast := EstimateExport("workspace", samples, EstimateOptions{Concurrency: 16, RateLimitPerHour: 1000})
+	assert.Equal(t, 2+1+100+5000+5000, fast.APIRequests)
+	assert.InDelta(t, 10.1, fast.QuotaShare, 0.01)
+	assert.Equal(t, float64(32771), fast.APIDurationSeconds, "9103 requests over the first hour wait for the quota, however many workers")
+
+	nice := EstimateExport("workspace", samples, EstimateOptions{Concurrency: 16, Nice: true})
+	assert.Equal(t, 1, nice.Concurrency, "nice mode uses a single worker")
+	assert.Equal(t, 2+1+200+5000+5000, nice.APIRequests, "nice mode halves page sizes")
+}
+
+func TestRunEstimate(t *testing.T) {
+	var paths []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/repositories/workspace":
+			writeResponse(t, w, []byte(`{"values": [
+				{"slug": "api", "scm": "git", "size": 1048576},
+				{"slug": "legacy", "scm": "git", "size": 2048}], "next": null}`))
+		case "/repositories/workspace/api/pullrequests", "/repositories/workspace/legacy/pullrequests":
+			assert.Equal(t, "ALL", r.URL.Query().Get("state"))
+			writeResponse(t, w, []byte(`{"size": 80, "values": [{"id": 2, "comment_count": 4}, {"id": 1, "comment_count": 0}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	flags := &data.CmdExportFlags{BitbucketAPIURL: testServer.URL, BitbucketAccessToken: "token",
+		Workspace: "workspace", AllRepos: true}
+	estimate, err := RunEstimate(flags, EstimateOptions{}, zap.NewNop())
+
+	require.NoError(t, err)
+	require.Len(t, estimate.Repositories, 2)
+	assert.Equal(t, "api", estimate.Repositories[0].Repository)
+	assert.Equal(t, int64(1048576), estimate.Repositories[0].SizeBytes)
+	assert.Equal(t, 80, estimate.Repositories[0].PullRequests)
+	assert.Equal(t, 160, estimate.Repositories[0].Comments)
+	assert.Equal(t, 3, estimate.SamplingRequests, "the repository list already holds the sizes")
+	assert.Len(t, paths, 3)
+}
+
+func TestRunEstimateSingleRepository(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repositories/workspace/api":
+			writeResponse(t, w, []byte(`{"slug": "api", "size": 4096}`))
+		case "/repositories/workspace/api/pullrequests":
+			assert.Equal(t, "OPEN", r.URL.Query().Get("state"))
+			writeResponse(t, w, []byte(`{"size": 0, "values": []}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	flags := &data.CmdExportFlags{BitbucketAPIURL: testServer.URL, BitbucketAccessToken: "token",
+		Workspace: "workspace", Repository: "api", OpenPRsOnly: true}
+	estimate, err := RunEstimate(flags, EstimateOptions{}, zap.NewNop())
+
+	require.NoError(t, err)
+	require.Len(t, estimate.Repositories, 1)
+	assert.Equal(t, int64(4096), estimate.Repositories[0].SizeBytes)
+	assert.Zero(t, estimate.Repositories[0].Comments)
+	assert.Equal(t, 2, estimate.SamplingRequests)
+}
@@ -3,18 +3,19 @@ package utils
 import (
 	"archive/tar"
 	"compress/gzip"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"time"
 
 	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
 	"go.uber.org/zap"
 )
 
@@ -25,15 +26,153 @@ type Exporter struct {
 	openPRsOnly bool
 	prsFromDate string
 	tempDir     string
+
+	coldStorageBefore   string
+	coldStorageDeclined bool
+
+	failOnUnsafePaths bool
+	unsafePaths       []data.UnsafePath
+
+	prPathPatterns []string
+	prPathMatchers []*regexp.Regexp
+
+	splitSubdir  string
+	deferArchive bool // Leave archiving to completeDeferredArchive, used by multi-target splits
+
+	reactionMapping map[string]string
+
+	encryption *data.ArchiveEncryption
+
+	// repositories holds the repository records for the duration of the
+	// export; they are written to the repositories files once at the end.
+	repositories []data.Repository
+
+	analyticsOutput bool
+
+	prunedRefPrefixes []string
+	maxPackSize       int64
+	compactJSON       bool
+	recordsPerFile    int
+	archiveWorkers    int
+
+	dropPendingReviews bool
+	exportPatches      bool
+	topUpFetch         bool
+
+	verifyFrozen bool
+	frozenSince  time.Time
+	frozenRefs   map[string]map[string]string // Repository slug -> ref -> commit at the start
+
+	asOf time.Time // First instant after the --as-of snapshot; zero when not set
+
+	resume     bool
+	checkpoint data.ExportCheckpoint
+
+	sync            bool // Update the mirrors and records of an earlier sync cycle
+	syncState       data.SyncState
+	syncUpdatedRefs int
+
+	downloadAvatar bool
+
+	analyzeDocs bool
+	docFindings []data.DocFinding
+
+	featureChecklist bool
+	features         []data.RepositoryFeatures
+
+	activityReviews []map[string]interface{} // Approvals, change requests and declines of exported pull requests
+
+	verifyArchive bool
+
+	exportRulesets bool
+	rulesets       []data.RepositoryRulesets
+
+	exportWatchers bool
+	watchers       []data.RepositoryWatchers
+
+	issueLinks       bool
+	issueLinkEntries []data.RepositoryIssueLinks
+
+	protectionHealth     bool
+	protectionHealthDays int // Days of push activity correlated with branch restrictions
+
+	downloadAttachments bool
+
+	predictPRNumbers bool
+	prNumberOffset   int
+	prNumbers        []data.RepositoryPRNumbers
+
+	cloneTimes       map[string]time.Time // Repository slug -> start of its mirror clone
+	localMirror      string               // Bare mirror copied instead of cloning from Bitbucket
+	cloneRetries     int                  // Retries of a failed clone per clone URL
+	cloneRetryDelay  time.Duration        // Wait before the first retry; doubled for each further one
+	cloneProtocol    string               // https or ssh, tried first; empty only clones over https
+	failOnCloneError bool
+	skipGit          bool // Store empty placeholder repositories instead of cloning
+	gitDepth         int  // Commits of history cloned per branch; 0 clones everything
+
+	repoPermissions map[string]map[string]string // Repository slug -> user UUID -> collaborator permission
+	permissionNotes []data.PermissionNote
+
+	generateCodeowners bool
+	codeownersConfig   *data.CodeownersConfig
+	codeowners         []repositoryCodeowners
+
+	convertPipelines    bool
+	pipelineConversions []data.RepositoryPipelineConversion
+	pipelineWorkflows   []repositoryWorkflows
+
+	compareStats   bool
+	bitbucketStats map[string]data.RepositoryStatistics
+
+	deadline        time.Time
+	startedAt       time.Time
+	completedStages []string
+	report          data.ExportReport
+	reportWritten   bool
+	progressEvents  *progressReporter
+
+	flags *data.CmdExportFlags
+}
+
+// sidecarPaths lists top-level entries of the export directory that are kept
+// next to the archive for operators but never shipped to the importer.
+var sidecarPaths = map[string]bool{
+	coldStorageDir:          true,
+	importSafetyReportFile:  true,
+	exportReportFile:        true,
+	exportCheckpointFile:    true,
+	checkpointCacheDir:      true,
+	syncStateFile:           true,
+	syncCacheDir:            true,
+	organizationAvatarDir:   true,
+	analyticsDir:            true,
+	exportLogFile:           true,
+	rulesetsFile:            true,
+	rulesetsScriptFile:      true,
+	watchersFile:            true,
+	issueLinksFile:          true,
+	prNumberMapFile:         true,
+	protectionHealthFile:    true,
+	patchesDir:              true,
+	migrationNotesDir:       true,
+	docsReportFile:          true,
+	featureChecklistFile:    true,
+	pipelinesConversionFile: true,
+	repairReportFile:        true,
+	repairScriptFile:        true,
 }
 
 func NewExporter(client *Client, outputDir string, logger *zap.Logger, openPRsOnly bool, prsFromDate string) *Exporter {
 	return &Exporter{
-		client:      client,
-		outputDir:   outputDir,
-		logger:      logger,
-		openPRsOnly: openPRsOnly,
-		prsFromDate: prsFromDate,
+		client:            client,
+		outputDir:         outputDir,
+		logger:            logger,
+		openPRsOnly:       openPRsOnly,
+		prsFromDate:       prsFromDate,
+		prunedRefPrefixes: defaultPrunedRefPrefixes,
+		maxPackSize:       defaultMaxPackSize,
+		archiveWorkers:    defaultArchiveWorkers(),
 	}
 }
 
@@ -41,14 +180,131 @@ func (e *Exporter) SetTempDir(tempDir string) {
 	e.tempDir = tempDir
 }
 
+// ApplyExportFlags configures the optional exporter behaviour shared by the
+// export and migrate commands.
+func (e *Exporter) ApplyExportFlags(flags *data.CmdExportFlags) error {
+	e.flags = flags
+	e.SetColdStorage(flags.ColdStorageBefore, flags.ColdStorageDeclined)
+	e.SetFailOnUnsafePaths(flags.FailOnUnsafePaths)
+	e.SetAnalyticsOutput(flags.NDJSON)
+	e.SetCompareStats(flags.CompareStats)
+	e.SetExportRulesets(flags.ExportRulesets)
+	e.SetExportWatchers(flags.ExportWatchers)
+	e.SetProtectionHealth(flags.ProtectionHealth, flags.ProtectionHealthDays)
+	e.SetDownloadAttachments(flags.DownloadAttachments)
+	e.SetPRNumberPrediction(flags.PredictPRNumbers, flags.PRNumberOffset)
+	e.SetCompactJSON(flags.CompactJSON)
+	e.SetRecordsPerFile(flags.RecordsPerFile)
+	e.SetDropPendingReviews(flags.DropPendingReviews)
+	e.SetExportPatches(flags.ExportPatches)
+	e.SetTopUpFetch(flags.TopUpFetch)
+	e.SetLocalMirror(flags.LocalMirror)
+	e.SetCloneRetries(flags.CloneRetries, flags.CloneRetryDelay)
+	e.SetCloneProtocol(flags.CloneProtocol)
+	e.SetFailOnCloneError(flags.FailOnCloneError)
+	e.SetSkipGit(flags.SkipGit)
+	e.SetGitDepth(flags.GitDepth)
+	e.SetVerifyFrozen(flags.VerifyFrozen)
+	e.SetResume(flags.Resume)
+	e.SetDownloadAvatar(flags.DownloadAvatar)
+	e.SetAnalyzeDocs(flags.AnalyzeDocs)
+	e.SetFeatureChecklist(flags.FeatureChecklist)
+	e.SetConvertPipelines(flags.ConvertPipelines)
+	e.SetIssueLinks(flags.IssueLinks)
+	e.SetVerifyArchive(flags.VerifyArchive)
+	e.SetProgressFormat(flags.ProgressFormat)
+	e.client.SetKeepAmbiguousPRs(flags.KeepAmbiguousPRs)
+	e.client.SetCommentFormatter(flags.CommentFormatter)
+	e.client.SetLinkTarget(flags.LinkTarget)
+	e.client.SetTokenRefreshCommand(flags.TokenRefreshCmd)
+	e.client.SetGhostUser(flags.GhostUser)
+	e.client.SetConcurrency(flags.Concurrency)
+
+	mapping, err := LoadReactionMapping(flags.ReactionMapFile)
+	if err != nil {
+		return err
+	}
+	e.SetReactionMapping(mapping)
+
+	userMapping, err := LoadUserMapping(flags.UserMappingFile)
+	if err != nil {
+		return err
+	}
+	if userMapping != nil {
+		e.logger.Info("Loaded user mapping",
+			zap.String("file", flags.UserMappingFile),
+			zap.Int("users", userMapping.Len()))
+	}
+	e.client.SetUserMapping(userMapping)
+
+	redactionRules, err := LoadRedactionRules(flags.RedactionRulesFile)
+	if err != nil {
+		return err
+	}
+	if redactionRules != nil {
+		e.logger.Info("Loaded redaction rules",
+			zap.String("file", flags.RedactionRulesFile),
+			zap.Int("rules", redactionRules.Len()))
+	}
+	e.client.SetRedactionRules(redactionRules)
+
+	if err := e.client.SetPRFooterTemplate(flags.PRFooterTemplate); err != nil {
+		return err
+	}
+	if err := e.client.SetCommentFooterTemplate(flags.CommentFooter); err != nil {
+		return err
+	}
+	if err := e.SetAsOf(flags.AsOf); err != nil {
+		return err
+	}
+	if err := e.SetRefPruning(flags.PruneRefs, flags.KeepNotes); err != nil {
+		return err
+	}
+	if err := e.SetMaxPackSize(flags.MaxPackSize); err != nil {
+		return err
+	}
+	if flags.GenerateCodeowners {
+		var codeownersConfig *data.CodeownersConfig
+		if flags.ConfigFile != "" {
+			config, err := LoadConfig(flags.ConfigFile)
+			if err != nil {
+				return err
+			}
+			codeownersConfig = &config.Codeowners
+		}
+		e.SetCodeowners(true, codeownersConfig)
+	}
+
+	encryption, err := ParseEncryption(flags.Encrypt)
+	if err != nil {
+		return err
+	}
+	e.SetEncryption(encryption)
+
+	if flags.MaxDuration > 0 && e.deadline.IsZero() {
+		e.SetDeadline(ExportDeadline(flags))
+	}
+
+	return e.SetPRPathFilters(flags.PRsTouchingPaths)
+}
+
 func (e *Exporter) Export(workspace, repoSlug string) error {
+	return e.ExportRepositories(workspace, []string{repoSlug})
+}
+
+// ExportRepositories exports one or more repositories of a workspace into a
+// single migration archive with shared users and organization records.
+func (e *Exporter) ExportRepositories(workspace string, repoSlugs []string) (err error) {
+	if len(repoSlugs) == 0 {
+		return fmt.Errorf("no repositories specified for export")
+	}
+
 	e.logger.Info("Starting export",
 		zap.String("workspace", workspace),
-		zap.String("repository", repoSlug))
+		zap.String("repository", strings.Join(repoSlugs, ",")))
 
 	if e.outputDir == "" {
-		timestamp := time.Now().Format("20060102-150405")
-		e.outputDir = fmt.Sprintf("./bitbucket-export-%s", timestamp)
+		e.outputDir = DefaultOutputDir(e.wave())
 	}
 	e.client.exportDir = e.outputDir
 
@@ -57,124 +313,298 @@ func (e *Exporter) Export(workspace, repoSlug string) error {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	reposDir := filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git")
-	if err := os.MkdirAll(reposDir, 0755); err != nil {
-		return fmt.Errorf("failed to create repositories directory: %w", err)
+	restoreLogger := e.startExportLog()
+	defer restoreLogger()
+
+	e.beginReport(workspace, repoSlugs)
+	defer func() {
+		if err != nil || !e.deferArchive {
+			e.finishReport(err)
+		}
+	}()
+
+	if err := e.startCheckpoint(workspace, repoSlugs); err != nil {
+		return err
+	}
+	if err := e.startSync(workspace, repoSlugs); err != nil {
+		return err
 	}
 
-	repo, err := e.client.GetRepository(workspace, repoSlug)
-	if err != nil {
-		return fmt.Errorf("failed to fetch repository data: %w", err)
+	if err := e.snapshotFrozenState(workspace, repoSlugs); err != nil {
+		return err
+	}
+
+	e.repositories = []data.Repository{}
+	e.activityReviews = nil
+	manifestRepos := []data.ManifestRepository{}
+	bitbucketRepos := make(map[string]*data.BitbucketRepository)
+	e.progressEvents.startPhase(stageRepositoryMetadata, len(repoSlugs))
+	for _, repoSlug := range repoSlugs {
+		reposDir := filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git")
+		if err := os.MkdirAll(reposDir, 0755); err != nil {
+			return fmt.Errorf("failed to create repositories directory: %w", err)
+		}
+
+		repo, err := e.client.GetRepository(workspace, repoSlug)
+		if err != nil {
+			return fmt.Errorf("failed to fetch repository data: %w", err)
+		}
+		if err := checkRepositorySCM(workspace, repoSlug, repo); err != nil {
+			return err
+		}
+		e.collectBitbucketStatistics(workspace, repoSlug, repo)
+		repoData := e.createRepositoriesData(repo, workspace)
+		repoData[0].Collaborators = e.collectCollaborators(workspace, repoSlug)
+		e.collectRulesets(workspace, repoSlug, repoData[0].Name)
+		e.collectWatchers(workspace, repoSlug, repoData[0].Name)
+		e.collectCodeowners(workspace, repoSlug)
+		e.repositories = append(e.repositories, repoData...)
+		bitbucketRepos[repoSlug] = repo
+		manifestRepos = append(manifestRepos, data.ManifestRepository{
+			Workspace: workspace,
+			Slug:      repoSlug,
+			UUID:      repo.UUID,
+		})
+		e.progressEvents.advance(1)
 	}
 
 	schema := data.MigrationArchiveSchema{
-		Version: "1.0.1",
+		Version: migrationSchemaVersion,
 	}
 	if err := e.writeJSONFile("schema.json", schema); err != nil {
 		return err
 	}
 
-	repositories := e.createRepositoriesData(repo, workspace)
-	if err := e.writeJSONFile("repositories_000001.json", repositories); err != nil {
+	if err := e.completeStage(stageRepositoryMetadata); err != nil {
 		return err
 	}
 
-	var cloneURL string
-	if e.client.accessToken != "" {
-		cloneURL = fmt.Sprintf("https://x-token-auth:%s@bitbucket.org/%s/%s.git",
-			url.QueryEscape(e.client.accessToken), workspace, repoSlug)
-	} else if e.client.apiToken != "" {
-		cloneURL = fmt.Sprintf("https://x-bitbucket-api-token-auth:%s@bitbucket.org/%s/%s.git",
-			url.QueryEscape(e.client.apiToken), workspace, repoSlug)
-	} else {
-		encodedUsername := url.QueryEscape(e.client.username)
-		encodedAppPass := url.QueryEscape(e.client.appPass)
-		cloneURL = fmt.Sprintf("https://%s:%s@bitbucket.org/%s/%s.git",
-			encodedUsername, encodedAppPass, workspace, repoSlug)
+	e.progressEvents.startPhase(stageGitClone, len(repoSlugs))
+	if e.gitDepth > 0 {
+		e.logger.Warn("Cloning shallow mirrors; pull requests whose commits are older than the cloned history cannot be imported",
+			zap.Int("git_depth", e.gitDepth))
+	}
+	for _, repoSlug := range repoSlugs {
+		if e.skipGit {
+			if err := e.writeGitPlaceholder(workspace, repoSlug); err != nil {
+				return err
+			}
+			e.progressEvents.advance(1)
+			continue
+		}
+		resumed, err := e.syncMirror(workspace, repoSlug)
+		if err != nil {
+			return err
+		}
+		if !resumed {
+			resumed, err = e.resumeClone(workspace, repoSlug)
+			if err != nil {
+				return err
+			}
+		}
+		if !resumed {
+			if err := e.exportGitRepository(workspace, repoSlug); err != nil {
+				return err
+			}
+			e.recordClone(repoSlug)
+		}
+		e.progressEvents.advance(1)
+	}
+	for _, repoSlug := range repoSlugs {
+		e.scanDocs(workspace, repoSlug)
+		e.collectPipelineWorkflows(workspace, repoSlug)
+		e.detectFeatures(workspace, repoSlug, bitbucketRepos[repoSlug])
 	}
 
-	e.logger.Debug("Attempting to clone repository",
-		zap.String("repository", repoSlug))
+	if err := e.writeImportSafetyReport(); err != nil {
+		e.logger.Warn("Failed to write import-safety report", zap.Error(err))
+	}
+	if err := e.writeDocsReport(); err != nil {
+		e.logger.Warn("Failed to write markdown analysis report", zap.Error(err))
+	}
+	if err := e.writeFeatureChecklist(workspace); err != nil {
+		e.logger.Warn("Failed to write feature checklist", zap.Error(err))
+	}
+	if err := e.writeManifest(manifestRepos); err != nil {
+		e.logger.Warn("Failed to write export manifest", zap.Error(err))
+	}
+	if err := e.completeStage(stageGitClone); err != nil {
+		return err
+	}
 
-	if err := e.CloneRepository(workspace, repoSlug, cloneURL); err != nil {
-		// Check if this is an ambiguous reference error - if so, fail immediately
-		if strings.Contains(err.Error(), "ambiguous") ||
-			strings.Contains(err.Error(), "repository validation failed") {
-			e.logger.Debug("Export cancelled due to ambiguous Git references",
-				zap.String("workspace", workspace),
-				zap.String("repository", repoSlug))
+	// Contributor users are only known once pull requests and comments
+	// have been fetched, so they are written after the comments stage.
+	e.progressEvents.startPhase(stageUsers, 1)
+	users := []data.User{}
+	if e.usersScope() != UsersScopeContributors {
+		users = e.exportUsers(workspace, repoSlugs[0])
+		if err := writeRecordFiles(e, usersFile, users); err != nil {
 			return err
 		}
+	}
 
-		// Check if this is an authentication error - fail immediately
-		if strings.Contains(err.Error(), "Authentication failed") ||
-			strings.Contains(err.Error(), "401") ||
-			strings.Contains(err.Error(), "403") {
-			e.logger.Error("Authentication failed when cloning repository",
-				zap.String("workspace", workspace),
+	orgs := e.exportOrganization(workspace)
+	if err := e.writeJSONFile(organizationsFile, orgs); err != nil {
+		return err
+	}
+	e.progressEvents.advance(1)
+	if err := e.completeStage(stageUsers); err != nil {
+		return err
+	}
+
+	prsByRepo := make(map[string][]data.PullRequest)
+	prs := []data.PullRequest{}
+	e.progressEvents.startPhase(stagePullRequests, len(repoSlugs))
+	for _, repoSlug := range repoSlugs {
+		repoPRs, err := e.client.GetPullRequests(workspace, repoSlug, e.openPRsOnly, e.prsFromDate)
+		e.report.AmbiguousPullRequests = append(e.report.AmbiguousPullRequests, e.client.takeAmbiguousPRs()...)
+		if exportAborted(err) {
+			return err
+		}
+		if err != nil {
+			e.logger.Warn("Failed to fetch pull requests",
 				zap.String("repository", repoSlug),
-				zap.String("auth_method", getAuthMethodDescription(e.client)))
+				zap.Error(err))
+			repoPRs = []data.PullRequest{}
+		} else {
+			e.logger.Info("Successfully fetched pull requests",
+				zap.String("repository", repoSlug),
+				zap.Int("count", len(repoPRs)),
+				zap.Bool("open_only", e.openPRsOnly),
+				zap.String("from_date", e.prsFromDate))
+			repoPRs = e.filterPullRequestsByPath(workspace, repoSlug, repoPRs)
+			repoPRs = e.applyAsOf(workspace, repoSlug, repoPRs)
+			if repoPRs, err = e.enforceConsistency(workspace, repoSlug, repoPRs); err != nil {
+				return err
+			}
+			repoPRs = e.verifyMergeCommits(workspace, repoSlug, repoPRs)
+		}
+		prsByRepo[repoSlug] = repoPRs
+		e.collectIssueLinks(workspace, repoSlug, repoPRs, bitbucketRepos[repoSlug])
+		prs = append(prs, repoPRs...)
+		e.progressEvents.advance(1)
+	}
+
+	if e.exportPatches {
+		if err := e.writePullRequestPatches(workspace, repoSlugs, prsByRepo); err != nil {
 			return err
 		}
+	}
 
-		// For any other clone error, fail the export instead of creating empty repo
-		e.logger.Error("Failed to clone repository",
-			zap.String("workspace", workspace),
-			zap.String("repository", repoSlug),
-			zap.Error(err))
+	coldBundle := data.ColdStorageBundle{
+		Cutoff:          e.coldStorageBefore,
+		IncludeDeclined: e.coldStorageDeclined,
+		IssueComments:   []data.IssueComment{},
+		ReviewComments:  []data.PullRequestReviewComment{},
+	}
+	coldPRURLs := make(map[string]bool)
+	if e.coldStorageEnabled() {
+		prs, coldBundle.PullRequests = e.partitionColdPullRequests(prs)
+		for _, pr := range coldBundle.PullRequests {
+			coldPRURLs[pr.URL] = true
+		}
+		e.logger.Info("Moving pull requests to cold storage",
+			zap.Int("cold", len(coldBundle.PullRequests)),
+			zap.Int("remaining", len(prs)))
+	}
+	e.report.Counts.PullRequests = len(prs)
+	e.report.Counts.ColdStoragePullRequests = len(coldBundle.PullRequests)
+	if err := e.completeStage(stagePullRequests); err != nil {
 		return err
 	}
 
-	e.logger.Info("Repository clone successful")
-	// Repository was cloned successfully, create repo info files
-	if err := e.createRepositoryInfoFiles(workspace, repoSlug); err != nil {
-		e.logger.Warn("Failed to create repository info files",
-			zap.String("repository", repoSlug),
-			zap.Error(err))
+	regularComments := []data.IssueComment{}
+	reviewComments := []data.PullRequestReviewComment{}
+	commentsFetched := false
+	// The client advances the comments phase once per pull request.
+	e.progressEvents.startPhase(stageComments, countPullRequests(prsByRepo))
+	for _, repoSlug := range repoSlugs {
+		repoRegular, repoReview, err := e.client.GetPullRequestComments(workspace, repoSlug, prsByRepo[repoSlug])
+		if exportAborted(err) {
+			return err
+		}
+		if err != nil {
+			e.logger.Warn("Failed to fetch pull request comments",
+				zap.String("repository", repoSlug),
+				zap.Error(err))
+			continue
+		}
+		commentsFetched = true
+		repoRegular, repoReview = e.filterCommentsAfterClone(repoSlug, repoRegular, repoReview)
+		repoRegular, repoReview = e.filterCommentsAsOf(repoRegular, repoReview)
+		regularComments = append(regularComments, repoRegular...)
+		reviewComments = append(reviewComments, repoReview...)
+
+		repoReviews, err := e.client.GetPullRequestReviews(workspace, repoSlug, prsByRepo[repoSlug])
+		e.report.ActivityFailures = append(e.report.ActivityFailures, e.client.takeActivityFailures()...)
+		if exportAborted(err) {
+			return err
+		}
+		if err != nil {
+			// Reviews synthesized from comments are still exported.
+			e.logger.Warn("Failed to fetch pull request activity; approvals and change requests are not exported",
+				zap.String("repository", repoSlug),
+				zap.Error(err))
+			e.report.ActivityFailures = append(e.report.ActivityFailures, data.ActivityFailure{
+				Repository: workspace + "/" + repoSlug,
+				Error:      SanitizeSupportText(err.Error()),
+			})
+			continue
+		}
+		e.activityReviews = append(e.activityReviews, e.filterReviewsAsOf(repoReviews)...)
 	}
+	if len(coldPRURLs) > 0 {
+		e.activityReviews = filterReviewsOfPullRequests(e.activityReviews, prs)
+	}
+	e.addApprovalReactions(prs, e.activityReviews)
 
-	e.logger.Debug("Fetching users")
-	users, err := e.client.GetUsers(workspace, repoSlug)
-	if err != nil {
-		e.logger.Warn("Failed to fetch users", zap.Error(err))
-		users = e.createBasicUsers(workspace)
+	reviewComments = e.filterPendingReviews(reviewComments)
+
+	if commentsFetched && len(coldPRURLs) > 0 {
+		regularComments, coldBundle.IssueComments = partitionColdIssueComments(regularComments, coldPRURLs)
+		reviewComments, coldBundle.ReviewComments = partitionColdReviewComments(reviewComments, coldPRURLs)
 	}
-	if err := e.writeJSONFile("users_000001.json", users); err != nil {
-		return err
+
+	if e.topUpFetch {
+		if err := e.topUpMirrors(workspace, repoSlugs); err != nil {
+			return err
+		}
 	}
 
-	orgs := e.createOrganizationData(workspace)
-	if err := e.writeJSONFile("organizations_000001.json", orgs); err != nil {
-		return err
+	if e.splitSubdir != "" {
+		if err := e.applySubdirSplit(workspace, repoSlugs[0], prs, reviewComments); err != nil {
+			return err
+		}
+		reviewComments = e.filterSplitReviewComments(reviewComments)
 	}
 
-	prs, err := e.client.GetPullRequests(workspace, repoSlug, e.openPRsOnly, e.prsFromDate)
-	if err != nil {
-		e.logger.Warn("Failed to fetch pull requests", zap.Error(err))
-		prs = []data.PullRequest{}
-	} else {
-		e.logger.Info("Successfully fetched pull requests",
-			zap.Int("count", len(prs)),
-			zap.Bool("open_only", e.openPRsOnly),
-			zap.String("from_date", e.prsFromDate))
+	if e.downloadAttachments {
+		attachments := e.rehostAttachments(prs, regularComments, reviewComments)
+		if err := writeRecordFiles(e, attachmentsFile, attachments); err != nil {
+			return fmt.Errorf("failed to write attachments: %w", err)
+		}
+	}
+
+	if e.predictPRNumbers {
+		e.renumberPullRequestReferences(workspace, repoSlugs, bitbucketRepos, prs, regularComments, reviewComments)
+		if err := e.writePRNumberMap(); err != nil {
+			e.logger.Warn("Failed to write pull request number map", zap.Error(err))
+		}
 	}
 
 	if len(prs) > 0 {
-		if err := e.writeJSONFile("pull_requests_000001.json", prs); err != nil {
+		if err := writeRecordFiles(e, pullRequestsFile, prs); err != nil {
 			return fmt.Errorf("failed to write pull requests: %w", err)
 		}
 	}
 
-	regularComments, reviewComments, err := e.client.GetPullRequestComments(workspace, repoSlug, prs)
-	if err != nil {
-		e.logger.Warn("Failed to fetch pull request comments", zap.Error(err))
-	} else {
+	if commentsFetched {
 		e.logger.Info("Successfully fetched pull request comments",
 			zap.Int("regular_comments", len(regularComments)),
 			zap.Int("review_comments", len(reviewComments)),
 			zap.Int("total_comments", len(regularComments)+len(reviewComments)))
 		if len(regularComments) > 0 {
-			if err := e.writeJSONFile("issue_comments_000001.json", regularComments); err != nil {
+			if err := writeRecordFiles(e, issueCommentsFile, regularComments); err != nil {
 				e.logger.Warn("Failed to write issue comments", zap.Error(err))
 			} else {
 				e.logger.Debug("Issue comments written", zap.Int("count", len(regularComments)))
@@ -182,32 +612,150 @@ func (e *Exporter) Export(workspace, repoSlug string) error {
 		}
 
 		if len(reviewComments) > 0 {
-			if err := e.writeJSONFile("pull_request_review_comments_000001.json", reviewComments); err != nil {
+			if err := writeRecordFiles(e, reviewCommentsFile, reviewComments); err != nil {
 				e.logger.Warn("Failed to write pull request review comments", zap.Error(err))
 			} else {
 				e.logger.Debug("Pull request review comments written", zap.Int("count", len(reviewComments)))
 			}
 
 			threads := e.createReviewThreads(reviewComments)
-			if err := e.writeJSONFile("pull_request_review_threads_000001.json", threads); err != nil {
+			if err := writeRecordFiles(e, reviewThreadsFile, threads); err != nil {
 				e.logger.Warn("Failed to write review threads", zap.Error(err))
 			}
 
-			reviews := e.createReviews(reviewComments)
-			if err := e.writeJSONFile("pull_request_reviews_000001.json", reviews); err != nil {
+		}
+
+		reviews := append(e.createReviews(reviewComments), e.activityReviews...)
+		if len(reviews) > 0 {
+			if err := writeRecordFiles(e, reviewsFile, reviews); err != nil {
 				e.logger.Warn("Failed to write reviews", zap.Error(err))
 			}
 		}
 	}
 
+	e.report.Counts.IssueComments = len(regularComments)
+	e.report.Counts.ReviewComments = len(reviewComments)
+	e.report.Counts.ActivityReviews = len(e.activityReviews)
+	if err := e.completeStage(stageComments); err != nil {
+		return err
+	}
+
+	if e.usersScope() == UsersScopeContributors {
+		users = e.contributorUsers(prs, regularComments, reviewComments)
+	}
+	users, addedGhost := e.addGhostUser(workspace, users, prs, regularComments, reviewComments)
+	if e.usersScope() == UsersScopeContributors || addedGhost {
+		if err := writeRecordFiles(e, usersFile, users); err != nil {
+			return err
+		}
+	}
+
+	if len(coldBundle.PullRequests) > 0 {
+		if err := e.writeColdStorageBundle(coldBundle); err != nil {
+			e.logger.Warn("Failed to write cold storage bundle", zap.Error(err))
+		}
+	}
+
+	if e.analyticsOutput {
+		if err := e.writeAnalyticsFiles(prs, regularComments, reviewComments, users); err != nil {
+			e.logger.Warn("Failed to write NDJSON analytics files", zap.Error(err))
+		}
+	}
+
+	if err := e.writeRepositoriesFile(); err != nil {
+		return err
+	}
+
+	e.compareRepositoryStatistics(workspace, repoSlugs, prsByRepo)
+	if err := e.writeProtectionHealth(workspace, repoSlugs, prsByRepo); err != nil {
+		e.logger.Warn("Failed to write branch protection health", zap.Error(err))
+	}
+
+	if err := e.writeRulesets(); err != nil {
+		e.logger.Warn("Failed to write GitHub rulesets", zap.Error(err))
+	}
+	if err := e.writeCodeowners(); err != nil {
+		e.logger.Warn("Failed to write CODEOWNERS files", zap.Error(err))
+	}
+	if err := e.writePipelineWorkflows(); err != nil {
+		e.logger.Warn("Failed to write converted pipeline workflows", zap.Error(err))
+	}
+	if err := e.writeWatchers(); err != nil {
+		e.logger.Warn("Failed to write watchers report", zap.Error(err))
+	}
+	if err := e.writeIssueLinks(); err != nil {
+		e.logger.Warn("Failed to write issue links", zap.Error(err))
+	}
+
 	if err := e.validateExportData(); err != nil {
+		if errors.Is(err, ErrCorruptExportFile) {
+			return err
+		}
 		e.logger.Warn("Export validation issues detected", zap.Error(err))
 	}
+	if err := e.reportIntegrityViolations(); err != nil {
+		return err
+	}
+	if err := e.checkFrozenState(workspace, repoSlugs); err != nil {
+		return err
+	}
+	if err := e.writeGitBundles(workspace, repoSlugs); err != nil {
+		return err
+	}
 
-	archivePath, err := e.CreateArchive()
-	if err != nil {
-		e.logger.Warn("Failed to create archive", zap.Error(err))
+	if err := e.saveSync(); err != nil {
+		return err
+	}
+
+	if e.deferArchive {
+		return nil
+	}
+	return e.archiveExport()
+}
+
+// completeDeferredArchive archives an export run with deferArchive set and
+// writes its report.
+func (e *Exporter) completeDeferredArchive() (err error) {
+	defer func() {
+		e.finishReport(err)
+	}()
+	return e.archiveExport()
+}
+
+// archiveExport packs the export directory, encrypts the archive when
+// requested and writes the final report.
+func (e *Exporter) archiveExport() error {
+	e.progressEvents.startPhase(stageArchive, 1)
+	archivePath, archiveErr := e.CreateArchive()
+	if archiveErr == nil {
+		if err := e.verifyArchiveContents(archivePath); err != nil {
+			return err
+		}
+	}
+	if archiveErr == nil && e.encryption != nil {
+		encryptedPath, encryptErr := e.encryptArchive(archivePath)
+		if encryptErr != nil {
+			return encryptErr
+		}
+		archivePath = encryptedPath
+	}
+	if errors.Is(archiveErr, ErrLongArchivePath) || exportAborted(archiveErr) {
+		return archiveErr
+	}
+	if archiveErr != nil {
+		e.logger.Warn("Failed to create archive", zap.Error(archiveErr))
 	} else {
+		e.progressEvents.advance(1)
+		e.report.Archive = archivePath
+		e.completedStages = append(e.completedStages, stageArchive)
+		e.report.LastStage = stageArchive
+	}
+
+	// The report lives next to the export directory contents, so it must be
+	// written before outputDir is switched to the archive path.
+	e.finishReport(nil)
+
+	if archiveErr == nil {
 		e.logger.Debug("Created archive of export directory",
 			zap.String("archive", archivePath))
 		e.outputDir = archivePath
@@ -217,6 +765,66 @@ func (e *Exporter) Export(workspace, repoSlug string) error {
 	return nil
 }
 
+// exportGitRepository clones a single repository into the export directory and
+// writes the info files the importer expects next to it.
+func (e *Exporter) exportGitRepository(workspace, repoSlug string) error {
+	// Credentials are passed to git through a one-time credential helper (see
+	// gitCredentialHelper) so they are never stored in the mirror's config.
+	cloneURL := fmt.Sprintf("https://bitbucket.org/%s/%s.git", workspace, repoSlug)
+
+	e.logger.Debug("Attempting to clone repository",
+		zap.String("repository", repoSlug))
+
+	if err := e.CloneRepository(workspace, repoSlug, cloneURL); err != nil {
+		if exportAborted(err) {
+			return err
+		}
+
+		// Check if this is an ambiguous reference error - if so, fail immediately
+		if strings.Contains(err.Error(), "ambiguous") ||
+			strings.Contains(err.Error(), "repository validation failed") {
+			e.logger.Debug("Export cancelled due to ambiguous Git references",
+				zap.String("workspace", workspace),
+				zap.String("repository", repoSlug))
+			return err
+		}
+
+		// Check if this is an authentication error - fail immediately
+		if strings.Contains(err.Error(), "Authentication failed") ||
+			strings.Contains(err.Error(), "401") ||
+			strings.Contains(err.Error(), "403") {
+			e.logger.Error("Authentication failed when cloning repository",
+				zap.String("workspace", workspace),
+				zap.String("repository", repoSlug),
+				zap.String("auth_method", getAuthMethodDescription(e.client)))
+			return err
+		}
+
+		// For any other clone error, fail the export instead of creating empty repo
+		e.logger.Error("Failed to clone repository",
+			zap.String("workspace", workspace),
+			zap.String("repository", repoSlug),
+			zap.Error(err))
+		return err
+	}
+
+	e.logger.Info("Repository clone successful")
+
+	repoPath := filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git")
+	if err := e.checkImportSafety(workspace, repoSlug, ToNativePath(repoPath)); err != nil {
+		return err
+	}
+
+	// Repository was cloned successfully, create repo info files
+	if err := e.createRepositoryInfoFiles(workspace, repoSlug); err != nil {
+		e.logger.Warn("Failed to create repository info files",
+			zap.String("repository", repoSlug),
+			zap.Error(err))
+	}
+
+	return e.exportWiki(workspace, repoSlug, cloneURL)
+}
+
 func (e *Exporter) CloneRepository(workspace, repoSlug, cloneURL string) error {
 	repoPath := filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git")
 	repoDir := ToNativePath(repoPath)
@@ -270,24 +878,57 @@ func (e *Exporter) CloneRepository(workspace, repoSlug, cloneURL string) error {
 		}
 	}()
 
-	e.logger.Debug("Cloning repository to temporary directory first")
-	cmd := exec.Command("git", "clone", "--mirror", cloneURL, tempDir)
-	cmd.Env = append(os.Environ(),
-		"GIT_TERMINAL_PROMPT=0",
-		"GIT_SSL_NO_VERIFY=true")
-
-	output, err := cmd.CombinedOutput()
+	e.recordCloneTime(repoSlug)
+	if e.localMirror != "" {
+		err = e.copyLocalMirror(workspace, repoSlug, tempDir)
+	} else {
+		err = e.cloneMirror(cloneURL, tempDir)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to clone repository: %s: %w", string(output), err)
+		return err
+	}
+
+	if err := e.checkClonedBranches(workspace, repoSlug, tempDir); err != nil {
+		return err
 	}
 
-	e.logger.Debug("Clone to temporary directory successful",
-		zap.String("output", string(output)))
+	if err := e.scrubRepositoryCredentials(tempDir); err != nil {
+		return err
+	}
+
+	if e.client.keepAmbiguousPRs {
+		if err := e.renameAmbiguousBranches(tempDir); err != nil {
+			return err
+		}
+	}
+
+	if err := e.pruneRefs(workspace, repoSlug, tempDir); err != nil {
+		return err
+	}
+
+	if err := e.trimRefsAsOf(tempDir); err != nil {
+		return err
+	}
 
 	if err := e.validateGitReferences(tempDir); err != nil {
 		return err
 	}
 
+	if err := e.splitPacks(workspace, repoSlug, tempDir); err != nil {
+		return err
+	}
+
+	objectFormat, err := GetRepositoryObjectFormat(tempDir)
+	if err != nil {
+		e.logger.Warn("Could not detect repository object format, assuming SHA-1", zap.Error(err))
+		objectFormat = objectFormatSHA1
+	}
+	if objectFormat == objectFormatSHA256 {
+		e.logger.Warn("Repository uses the SHA-256 object format; commit SHAs will be 64 hex characters",
+			zap.String("repository", fmt.Sprintf("%s/%s", workspace, repoSlug)),
+			zap.String("impact", "GitHub may reject SHA-256 repositories during import"))
+	}
+
 	if _, err := os.Stat(repoDir); err == nil {
 		if err := os.RemoveAll(repoDir); err != nil {
 			return fmt.Errorf("failed to remove existing repository directory: %w", err)
@@ -299,7 +940,7 @@ func (e *Exporter) CloneRepository(workspace, repoSlug, cloneURL string) error {
 	}
 
 	e.logger.Debug("Updating remote URL")
-	cmd = exec.Command("git", "remote", "set-url", "origin",
+	cmd := exec.Command("git", "remote", "set-url", "origin",
 		fmt.Sprintf("https://bitbucket.org/%s/%s.git", workspace, repoSlug))
 	cmd.Dir = repoDir
 	if err := cmd.Run(); err != nil {
@@ -389,11 +1030,15 @@ func (e *Exporter) CloneRepository(workspace, repoSlug, cloneURL string) error {
 		}
 	}
 
-	e.logger.Debug("Updating repositories_000001.json")
+	e.logger.Debug("Updating repository record")
 	gitURL := fmt.Sprintf("tarball://root/repositories/%s/%s.git", workspace, repoSlug)
 	e.updateRepositoryField(repoSlug, "default_branch", defaultBranch)
 	e.updateRepositoryField(repoSlug, "git_url", gitURL)
 
+	if err := e.fetchLFSObjects(repoSlug, repoDir, cloneURL); err != nil {
+		return err
+	}
+
 	e.logger.Info("Repository clone and setup complete",
 		zap.String("default_branch", defaultBranch))
 
@@ -484,27 +1129,39 @@ func (e *Exporter) createBasicUsers(workspace string) []data.User {
 	return []data.User{
 		{
 			Type:      "user",
-			URL:       formatURL("user", workspace, ""),
+			URL:       e.client.formatURL("user", workspace, ""),
 			Login:     workspace,
 			Name:      workspace,
 			Company:   nil,
 			Website:   nil,
 			Location:  nil,
 			Emails:    []data.Email{},
-			CreatedAt: formatDateToZ(time.Now().Format(time.RFC3339)),
+			CreatedAt: formatDateToZ(e.client.now().Format(time.RFC3339)),
 		},
 	}
 }
 
-func (e *Exporter) createOrganizationData(workspace string) []data.Organization {
+// createOrganizationData builds the organization record for a workspace,
+// named and linked like the workspace when its details are known.
+func (e *Exporter) createOrganizationData(workspace string, details *data.BitbucketWorkspace) []data.Organization {
+	name := workspace
+	var website *string
+	if details != nil {
+		if details.Name != "" {
+			name = details.Name
+		}
+		if details.Website != "" {
+			website = &details.Website
+		}
+	}
 	return []data.Organization{
 		{
 			Type:        "organization",
-			URL:         formatURL("organization", workspace, ""),
+			URL:         e.client.formatURL("organization", workspace, ""),
 			Login:       workspace,
-			Name:        workspace,
+			Name:        name,
 			Description: "",
-			Website:     nil,
+			Website:     website,
 			Location:    nil,
 			Email:       nil,
 			Members:     []data.Member{},
@@ -536,20 +1193,20 @@ func (e *Exporter) createRepositoriesData(repo *data.BitbucketRepository, worksp
 	return []data.Repository{
 		{
 			Type:             "repository",
-			URL:              formatURL("repository", workspace, repo.Slug),
-			Owner:            formatURL("user", workspace, ""),
+			URL:              e.client.formatURL("repository", workspace, repo.Slug),
+			Owner:            e.client.formatURL("user", workspace, ""),
 			Name:             repoName,
 			Slug:             repo.Slug,
 			Description:      sanitizedDescription,
 			Private:          repo.IsPrivate,
 			HasIssues:        true,
-			HasWiki:          true,
+			HasWiki:          repo.HasWiki,
 			HasDownloads:     true,
 			Labels:           []data.Label{},
 			Webhooks:         []interface{}{},
-			Collaborators:    []interface{}{},
+			Collaborators:    []data.Collaborator{},
 			CreatedAt:        createdAt,
-			GitURL:           formatURL("git", workspace, repo.Slug),
+			GitURL:           e.client.formatURL("git", workspace, repo.Slug),
 			DefaultBranch:    "main",
 			PublicKeys:       []interface{}{},
 			Page:             nil,
@@ -691,7 +1348,7 @@ func (e *Exporter) createReviews(comments []data.PullRequestReviewComment) []map
 			"body":         nil,
 			"head_sha":     comment.CommitID,
 			"formatter":    "markdown",
-			"state":        comment.State,
+			"state":        reviewState(reviewComments),
 			"reactions":    []interface{}{},
 			"created_at":   comment.CreatedAt,
 			"submitted_at": comment.CreatedAt,
@@ -704,9 +1361,7 @@ func (e *Exporter) createReviews(comments []data.PullRequestReviewComment) []map
 }
 
 func (e *Exporter) CreateArchive() (string, error) {
-	baseDir := filepath.Dir(e.outputDir)
-	exportDirName := filepath.Base(e.outputDir)
-	archivePath := filepath.Join(baseDir, exportDirName+".tar.gz")
+	archivePath := e.archivePath()
 
 	e.logger.Debug("Creating archive",
 		zap.String("source", e.outputDir),
@@ -737,41 +1392,30 @@ func (e *Exporter) CreateArchive() (string, error) {
 		}
 	}()
 
-	if err := e.archiveDirectory(e.outputDir, tarWriter); err != nil {
+	// Entries are written to the gzip stream directly; the tar writer only
+	// adds the end-of-archive marker when it is closed.
+	if err := e.archiveDirectory(e.outputDir, gzipWriter); err != nil {
+		if removeErr := os.Remove(archivePath); removeErr != nil {
+			e.logger.Warn("Failed to remove incomplete archive", zap.Error(removeErr))
+		}
 		return "", fmt.Errorf("failed to build archive: %w", err)
 	}
 
 	return archivePath, nil
 }
 
-func (e *Exporter) archiveDirectory(sourceDir string, tarWriter *tar.Writer) error {
-	return filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		relPath, err := filepath.Rel(sourceDir, path)
-		if err != nil {
-			return fmt.Errorf("failed to get relative path: %w", err)
-		}
-
-		if relPath == "." {
-			return nil
-		}
-
-		return e.addFileToArchive(tarWriter, path, relPath, info)
-	})
-}
-
 func (e *Exporter) addFileToArchive(tarWriter *tar.Writer, path, relPath string, info os.FileInfo) error {
+	if err := e.client.context().Err(); err != nil {
+		return fmt.Errorf("archive cancelled: %w", err)
+	}
 	header, err := tar.FileInfoHeader(info, "")
 	if err != nil {
 		return fmt.Errorf("failed to create tar header: %w", err)
 	}
 
-	header.Name = ToUnixPath(relPath)
 	needsFileContents := false
 	var fileToRead string
+	mode := info.Mode()
 
 	switch header.Typeflag {
 	case tar.TypeDir:
@@ -807,8 +1451,9 @@ func (e *Exporter) addFileToArchive(tarWriter *tar.Writer, path, relPath string,
 				return nil
 			}
 
-			// Update header with target file's size
+			// Update header with target file's size and mode
 			header.Size = targetInfo.Size()
+			mode = targetInfo.Mode()
 			fileToRead = targetPath
 		} else {
 			// For hardlinks, use the original path
@@ -840,33 +1485,12 @@ func (e *Exporter) addFileToArchive(tarWriter *tar.Writer, path, relPath string,
 		return nil
 	}
 
-	header.Format = tar.FormatUSTAR
-	isInGitRepo := strings.Contains(header.Name, ".git/") || strings.HasSuffix(header.Name, ".git")
-
-	if isInGitRepo {
-		if len(header.Name) > 100 {
-			header.Format = tar.FormatGNU
-			e.logger.Debug("Using GNU format for long Git path",
-				zap.String("path", header.Name))
-		}
-	} else if len(header.Name) > 100 {
-		dir, file := filepath.Split(header.Name)
-		if len(file) > 80 {
-			file = file[:77] + "..."
-		}
-		// Keep the immediate parent directory for context
-		parentDir := filepath.Base(dir)
-		if parentDir != "" && parentDir != "." {
-			header.Name = filepath.Join(parentDir, file)
-		} else {
-			header.Name = file
-		}
-		e.logger.Warn("Path was too long and has been truncated",
-			zap.String("original", relPath),
-			zap.String("truncated", header.Name))
+	if err := e.setArchiveEntryName(header, relPath); err != nil {
+		return err
 	}
 
-	// Ensure consistent timestamps and ownership
+	// Ensure consistent timestamps, ownership and permissions
+	header.Mode = e.archiveEntryMode(header.Typeflag, mode)
 	modTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
 	header.ModTime = modTime
 	header.AccessTime = time.Time{}
@@ -900,6 +1524,12 @@ func (e *Exporter) addFileToArchive(tarWriter *tar.Writer, path, relPath string,
 }
 
 func getAuthMethodDescription(c *Client) string {
+	if c.usesOAuth() {
+		return "OAuth consumer"
+	}
+	if len(c.authFallback) > 0 {
+		return "fallback chain " + strings.Join(c.authFallback, ", ")
+	}
 	if c.accessToken != "" {
 		return "workspace access token"
 	}
@@ -916,49 +1546,15 @@ func getAuthMethodDescription(c *Client) string {
 }
 
 func sanitizeDescription(description string) string {
-	sanitized := whitespaceRegex.ReplaceAllString(description, " ")
-	return strings.TrimSpace(sanitized)
+	return format.Description(description)
 }
 
 func (e *Exporter) validateExportData() error {
-	// 1. Handle repository description newlines (existing functionality)
-	repoFilePath := filepath.Join(e.outputDir, "repositories_000001.json")
-	if _, err := os.Stat(repoFilePath); err == nil {
-		var repos []data.Repository
-		fileData, err := os.ReadFile(repoFilePath)
+	// 1. Check for ambiguous Git references in pull request files
+	if _, err := os.Stat(filepath.Join(e.outputDir, pullRequestsFile)); err == nil {
+		prs, err := readRecordFiles[data.PullRequest](e.outputDir, pullRequestsFile)
 		if err != nil {
-			return fmt.Errorf("failed to read repositories file: %w", err)
-		}
-
-		if err := json.Unmarshal(fileData, &repos); err != nil {
-			return fmt.Errorf("failed to parse repositories data: %w", err)
-		}
-
-		for i, repo := range repos {
-			repos[i].Description = sanitizeDescription(repo.Description)
-		}
-
-		updatedData, err := json.MarshalIndent(repos, "", "  ")
-		if err != nil {
-			return fmt.Errorf("failed to encode repositories data: %w", err)
-		}
-
-		if err := os.WriteFile(repoFilePath, updatedData, 0644); err != nil {
-			return fmt.Errorf("failed to write updated repositories file: %w", err)
-		}
-	}
-
-	// 2. Check for ambiguous Git references in pull request files
-	pullRequestsPath := filepath.Join(e.outputDir, "pull_requests_000001.json")
-	if _, err := os.Stat(pullRequestsPath); err == nil {
-		var prs []data.PullRequest
-		fileData, err := os.ReadFile(pullRequestsPath)
-		if err != nil {
-			return fmt.Errorf("failed to read pull requests file: %w", err)
-		}
-
-		if err := json.Unmarshal(fileData, &prs); err != nil {
-			return fmt.Errorf("failed to parse pull requests data: %w", err)
+			return err
 		}
 
 		for _, pr := range prs {
@@ -979,7 +1575,7 @@ func (e *Exporter) validateExportData() error {
 		}
 	}
 
-	// 3. Check Git repositories for ambiguous references
+	// 2. Check Git repositories for ambiguous references
 	reposDir := filepath.Join(e.outputDir, "repositories")
 	if _, err := os.Stat(reposDir); err == nil {
 		// Walk through each repository and validate its references
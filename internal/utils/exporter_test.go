@@ -51,11 +51,20 @@ func TestCreateOrganizationData(t *testing.T) {
 	client := &Client{}
 	exporter := NewExporter(client, "output", logger, false, "")
 
-	orgs := exporter.createOrganizationData("testworkspace")
+	orgs := exporter.createOrganizationData("testworkspace", nil)
 
 	assert.Len(t, orgs, 1)
 	assert.Equal(t, "organization", orgs[0].Type)
 	assert.Equal(t, "testworkspace", orgs[0].Login)
+	assert.Equal(t, "testworkspace", orgs[0].Name)
+	assert.Nil(t, orgs[0].Website)
+
+	details := &data.BitbucketWorkspace{Name: "Test Workspace", Website: "https://example.com"}
+	orgs = exporter.createOrganizationData("testworkspace", details)
+	assert.Equal(t, "testworkspace", orgs[0].Login)
+	assert.Equal(t, "Test Workspace", orgs[0].Name)
+	require.NotNil(t, orgs[0].Website)
+	assert.Equal(t, "https://example.com", *orgs[0].Website)
 }
 
 func TestWriteJSONFile(t *testing.T) {
@@ -546,7 +555,7 @@ func TestUpdateRepositoryField(t *testing.T) {
 	logger, _ := zap.NewDevelopment()
 	exporter := NewExporter(&Client{}, tempDir, logger, false, "")
 
-	// Seed repositories_000001.json with a minimal repository entry
+	// Seed the in-memory records with a minimal repository entry
 	initial := []data.Repository{
 		{
 			Type:          "repository",
@@ -556,13 +565,16 @@ func TestUpdateRepositoryField(t *testing.T) {
 			GitURL:        "",
 		},
 	}
-	err = exporter.writeJSONFile("repositories_000001.json", initial)
-	assert.NoError(t, err)
+	exporter.repositories = initial
 
 	// Update default_branch and git_url
 	exporter.updateRepositoryField("group-test-ui", "default_branch", "develop")
 	exporter.updateRepositoryField("group-test-ui", "git_url", "tarball://root/repositories/ws/group-test-ui.git")
 
+	// Nothing is written until the end of the export
+	assert.NoFileExists(t, filepath.Join(tempDir, "repositories_000001.json"))
+	assert.NoError(t, exporter.writeRepositoriesFile())
+
 	// Read back and assert changes
 	b, err := os.ReadFile(filepath.Join(tempDir, "repositories_000001.json"))
 	assert.NoError(t, err)
@@ -1551,26 +1563,6 @@ func TestCreateRepositoryInfoFilesErrors(t *testing.T) {
 	assert.Error(t, err)
 }
 
-func TestUpdateRepositoryFieldWithInvalidJSON(t *testing.T) {
-	tempDir, err := os.MkdirTemp("", "invalid-json-test-")
-	assert.NoError(t, err)
-	defer func() { _ = os.RemoveAll(tempDir) }()
-
-	logger, _ := zap.NewDevelopment()
-	exporter := NewExporter(&Client{}, tempDir, logger, false, "")
-
-	// Create an invalid JSON file
-	invalidJSON := filepath.Join(tempDir, "repositories_000001.json")
-	err = os.WriteFile(invalidJSON, []byte("not valid json"), 0644)
-	assert.NoError(t, err)
-
-	// This should handle the error gracefully
-	exporter.updateRepositoryField("test-repo", "default_branch", "main")
-
-	// File should still exist but be unchanged
-	assert.FileExists(t, invalidJSON)
-}
-
 func TestSanitizeDescriptionEdgeCases(t *testing.T) {
 	testCases := []struct {
 		name     string
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	featureChecklistFile = "feature-checklist.json"
+
+	featureExported     = "exported"
+	featureManualStep   = "manual_step_required"
+	featureUnsupported  = "unsupported"
+	featureNotInUse     = "not_in_use"
+	featureUnknown      = "unknown"
+	pipelinesConfigFile = "bitbucket-pipelines.yml"
+)
+
+// SetFeatureChecklist detects the Bitbucket features each repository uses
+// and lists their migration status in feature-checklist.json.
+func (e *Exporter) SetFeatureChecklist(enabled bool) {
+	e.featureChecklist = enabled
+}
+
+// collectionInUse reports whether a paginated collection has any values.
+// Some collections never report a size, so the first page is checked too.
+func (c *Client) collectionInUse(endpoint string) (bool, error) {
+	var response data.BitbucketCountResponse
+	if err := c.makeRequest("GET", endpoint, &response); err != nil {
+		return false, err
+	}
+	return (response.Size != nil && *response.Size > 0) || len(response.Values) > 0, nil
+}
+
+// detectFeatures records the migration status of the Bitbucket features a
+// cloned repository uses. repo is the repository as returned by Bitbucket.
+func (e *Exporter) detectFeatures(workspace, repoSlug string, repo *data.BitbucketRepository) {
+	if !e.featureChecklist {
+		return
+	}
+
+	repoPath := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+	pipelines := e.pipelinesFeature(repoPath)
+	features := []data.FeatureStatus{
+		e.lfsFeature(repoSlug, repoPath),
+		pipelines,
+		e.wikiFeature(workspace, repoSlug, repo),
+		e.issuesFeature(workspace, repoSlug, repo),
+		e.branchRestrictionsFeature(workspace, repoSlug),
+		e.deploymentsFeature(workspace, repoSlug, pipelines.Status != featureNotInUse),
+		e.codeInsightsFeature(workspace, repoSlug, repoPath),
+	}
+	e.features = append(e.features, data.RepositoryFeatures{
+		Repository: workspace + "/" + repoSlug,
+		Features:   features,
+	})
+}
+
+func (e *Exporter) lfsFeature(repoSlug, repoPath string) data.FeatureStatus {
+	feature := data.FeatureStatus{Feature: "git_lfs", Status: featureNotInUse}
+	if !usesLFS(repoPath) {
+		return feature
+	}
+	for _, record := range e.repositories {
+		if record.Slug == repoSlug && record.GeneralSettings["git_lfs_in_archives"] == true {
+			feature.Status = featureExported
+			feature.Detail = "LFS objects are included in the archive"
+			return feature
+		}
+	}
+	feature.Status = featureManualStep
+	feature.Detail = "the repository tracks files with Git LFS but no LFS objects were exported"
+	feature.Guidance = "Push the LFS objects to GitHub with 'git lfs push --all' from a clone of the Bitbucket repository."
+	return feature
+}
+
+func (e *Exporter) pipelinesFeature(repoPath string) data.FeatureStatus {
+	feature := data.FeatureStatus{Feature: "pipelines", Status: featureNotInUse}
+	cmd := exec.Command("git", "cat-file", "-e", "HEAD:"+pipelinesConfigFile)
+	cmd.Dir = repoPath
+	if cmd.Run() != nil {
+		return feature
+	}
+	feature.Status = featureManualStep
+	feature.Detail = pipelinesConfigFile + " found on the default branch"
+	feature.Guidance = "Convert the pipeline to GitHub Actions workflows, for example with GitHub Actions Importer, and recreate repository variables as secrets."
+	if e.convertPipelines {
+		feature.Guidance = "Review the workflow stubs in " + migrationNotesDir + " and " + pipelinesConversionFile + ", then recreate repository variables as secrets."
+	}
+	return feature
+}
+
+func (e *Exporter) wikiFeature(workspace, repoSlug string, repo *data.BitbucketRepository) data.FeatureStatus {
+	feature := data.FeatureStatus{Feature: "wiki", Status: featureNotInUse}
+	if repo == nil || !repo.HasWiki {
+		return feature
+	}
+	if hasRefs(e.wikiRepoPath(workspace, repoSlug)) {
+		feature.Status = featureExported
+		feature.Detail = "the wiki is included in the archive"
+		return feature
+	}
+	feature.Status = featureManualStep
+	feature.Detail = "the wiki is enabled but could not be exported or has no pages"
+	feature.Guidance = "Check the Bitbucket wiki and copy any pages to the GitHub wiki by hand."
+	return feature
+}
+
+func (e *Exporter) issuesFeature(workspace, repoSlug string, repo *data.BitbucketRepository) data.FeatureStatus {
+	feature := data.FeatureStatus{Feature: "issues", Status: featureNotInUse}
+	if repo == nil || !repo.HasIssues {
+		return feature
+	}
+	inUse, err := e.client.collectionInUse(fmt.Sprintf("repositories/%s/%s/issues?pagelen=1", workspace, repoSlug))
+	if err != nil {
+		return unknownFeature(feature, err)
+	}
+	if !inUse {
+		return feature
+	}
+	feature.Status = featureManualStep
+	feature.Detail = "Bitbucket issues are not part of the migration archive"
+	feature.Guidance = "Export the issues from the repository settings in Bitbucket and import them with the GitHub issue import API or another migration tool."
+	return feature
+}
+
+func (e *Exporter) branchRestrictionsFeature(workspace, repoSlug string) data.FeatureStatus {
+	feature := data.FeatureStatus{Feature: "branch_restrictions", Status: featureNotInUse}
+	inUse, err := e.client.collectionInUse(fmt.Sprintf("repositories/%s/%s/branch-restrictions?pagelen=1", workspace, repoSlug))
+	if err != nil {
+		return unknownFeature(feature, err)
+	}
+	if !inUse {
+		return feature
+	}
+	if e.exportRulesets {
+		feature.Status = featureExported
+		feature.Detail = "translated into GitHub rulesets in " + rulesetsFile
+		feature.Guidance = "Run " + rulesetsScriptFile + " after the import and review the restrictions it reports as skipped."
+		return feature
+	}
+	feature.Status = featureManualStep
+	feature.Detail = "branch restrictions are not part of the migration archive"
+	feature.Guidance = "Export again with --export-rulesets, or recreate the restrictions as GitHub rulesets or branch protection rules."
+	return feature
+}
+
+// deploymentsFeature only queries Bitbucket when the repository has a
+// pipeline, since deployments are always made by Pipelines.
+func (e *Exporter) deploymentsFeature(workspace, repoSlug string, hasPipelines bool) data.FeatureStatus {
+	feature := data.FeatureStatus{Feature: "deployments", Status: featureNotInUse}
+	if !hasPipelines {
+		return feature
+	}
+	inUse, err := e.client.collectionInUse(fmt.Sprintf("repositories/%s/%s/deployments/?pagelen=1", workspace, repoSlug))
+	if err != nil {
+		return unknownFeature(feature, err)
+	}
+	if !inUse {
+		return feature
+	}
+	feature.Status = featureManualStep
+	feature.Detail = "deployment environments and their history are not part of the migration archive"
+	feature.Guidance = "Recreate the environments, their variables and protection rules in the GitHub repository settings."
+	return feature
+}
+
+// codeInsightsFeature looks for Code Insights reports on the default branch
+// head, where tools that publish them report on every build.
+func (e *Exporter) codeInsightsFeature(workspace, repoSlug, repoPath string) data.FeatureStatus {
+	feature := data.FeatureStatus{Feature: "code_insights", Status: featureNotInUse}
+	cmd := exec.Command("git", "rev-parse", "--verify", "-q", "HEAD^{commit}")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return feature
+	}
+	head := strings.TrimSpace(string(output))
+	inUse, err := e.client.collectionInUse(fmt.Sprintf("repositories/%s/%s/commit/%s/reports?pagelen=1", workspace, repoSlug, head))
+	if err != nil {
+		return unknownFeature(feature, err)
+	}
+	if !inUse {
+		return feature
+	}
+	feature.Status = featureUnsupported
+	feature.Detail = "Code Insights reports and annotations cannot be migrated"
+	feature.Guidance = "Configure the tools that published reports to post GitHub check runs or code scanning results instead."
+	return feature
+}
+
+func (e *Exporter) snippetsFeature(workspace string) data.FeatureStatus {
+	feature := data.FeatureStatus{Feature: "snippets", Status: featureNotInUse}
+	inUse, err := e.client.collectionInUse(fmt.Sprintf("snippets/%s?pagelen=1", workspace))
+	if err != nil {
+		return unknownFeature(feature, err)
+	}
+	if !inUse {
+		return feature
+	}
+	feature.Status = featureManualStep
+	feature.Detail = "workspace snippets are not part of the migration archive"
+	feature.Guidance = "Recreate the snippets as GitHub gists or move them into a repository."
+	return feature
+}
+
+func unknownFeature(feature data.FeatureStatus, err error) data.FeatureStatus {
+	feature.Status = featureUnknown
+	feature.Detail = fmt.Sprintf("detection failed: %s", SanitizeSupportText(err.Error()))
+	feature.Guidance = "Check this feature in Bitbucket by hand."
+	return feature
+}
+
+// writeFeatureChecklist writes the features detected in every repository,
+// plus the workspace-level ones, to feature-checklist.json.
+func (e *Exporter) writeFeatureChecklist(workspace string) error {
+	if !e.featureChecklist {
+		return nil
+	}
+
+	checklist := data.FeatureChecklist{
+		Workspace:         workspace,
+		WorkspaceFeatures: []data.FeatureStatus{e.snippetsFeature(workspace)},
+		Repositories:      e.features,
+	}
+	if checklist.Repositories == nil {
+		checklist.Repositories = []data.RepositoryFeatures{}
+	}
+	if err := e.writeJSONFile(featureChecklistFile, checklist); err != nil {
+		return err
+	}
+
+	manual := 0
+	for _, feature := range checklist.WorkspaceFeatures {
+		manual += e.logFeature(workspace, feature)
+	}
+	for _, repo := range checklist.Repositories {
+		for _, feature := range repo.Features {
+			manual += e.logFeature(repo.Repository, feature)
+		}
+	}
+	e.report.Counts.ManualFeatureSteps = manual
+	e.logger.Info("Wrote feature checklist (excluded from import archive)",
+		zap.String("file", featureChecklistFile),
+		zap.Int("manual_steps", manual))
+	return nil
+}
+
+// logFeature warns about a feature that needs attention after the import and
+// returns 1 when it needs a manual step.
+func (e *Exporter) logFeature(scope string, feature data.FeatureStatus) int {
+	switch feature.Status {
+	case featureManualStep, featureUnsupported, featureUnknown:
+		e.logger.Warn("Bitbucket feature needs attention after the migration",
+			zap.String("scope", scope),
+			zap.String("feature", feature.Feature),
+			zap.String("status", feature.Status),
+			zap.String("detail", feature.Detail))
+	}
+	if feature.Status == featureManualStep {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// featuresServer answers the feature detection endpoints; issues fail with
+// a server error to exercise the unknown status.
+func featuresServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/issues"):
+			w.WriteHeader(http.StatusInternalServerError)
+		case strings.HasSuffix(r.URL.Path, "/branch-restrictions"):
+			writeResponse(t, w, []byte(`{"size": 2, "values": [{"id": 1}]}`))
+		case strings.HasSuffix(r.URL.Path, "/deployments/"):
+			writeResponse(t, w, []byte(`{"values": [{"uuid": "{d1}"}]}`))
+		case strings.HasSuffix(r.URL.Path, "/reports"):
+			writeResponse(t, w, []byte(`{"values": []}`))
+		case strings.HasPrefix(r.URL.Path, "/snippets/"):
+			writeResponse(t, w, []byte(`{"size": 0, "values": []}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+		}
+	}))
+}
+
+// featuresFixture mirrors a repository with a pipeline into the export
+// directory outputDir.
+func featuresFixture(t *testing.T, outputDir string) {
+	t.Helper()
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, pipelinesConfigFile), []byte("pipelines: {}\n"), 0644))
+	runGit(t, workDir, "add", "-A")
+	runGit(t, workDir, "commit", "-m", "Add pipeline")
+	repoDir := filepath.Join(outputDir, "repositories", "ws", "repo.git")
+	runGit(t, t.TempDir(), "clone", "--mirror", workDir, repoDir)
+}
+
+func TestDetectFeatures(t *testing.T) {
+	server := featuresServer(t)
+	defer server.Close()
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	featuresFixture(t, exporter.outputDir)
+	repo := &data.BitbucketRepository{Name: "repo", Slug: "repo", HasWiki: true, HasIssues: true}
+	exporter.repositories = exporter.createRepositoriesData(repo, "ws")
+
+	exporter.detectFeatures("ws", "repo", repo)
+	assert.Empty(t, exporter.features, "disabled by default")
+
+	exporter.SetFeatureChecklist(true)
+	exporter.detectFeatures("ws", "repo", repo)
+	require.Len(t, exporter.features, 1)
+	assert.Equal(t, "ws/repo", exporter.features[0].Repository)
+
+	statuses := map[string]string{}
+	for _, feature := range exporter.features[0].Features {
+		statuses[feature.Feature] = feature.Status
+	}
+	assert.Equal(t, map[string]string{
+		"git_lfs":             featureNotInUse,
+		"pipelines":           featureManualStep,
+		"wiki":                featureManualStep,
+		"issues":              featureUnknown,
+		"branch_restrictions": featureManualStep,
+		"deployments":         featureManualStep,
+		"code_insights":       featureNotInUse,
+	}, statuses)
+
+	require.NoError(t, exporter.writeFeatureChecklist("ws"))
+	content, err := os.ReadFile(filepath.Join(exporter.outputDir, featureChecklistFile))
+	require.NoError(t, err)
+	var checklist data.FeatureChecklist
+	require.NoError(t, json.Unmarshal(content, &checklist))
+	assert.Equal(t, "ws", checklist.Workspace)
+	require.Len(t, checklist.WorkspaceFeatures, 1)
+	assert.Equal(t, data.FeatureStatus{Feature: "snippets", Status: featureNotInUse}, checklist.WorkspaceFeatures[0])
+	assert.Len(t, checklist.Repositories, 1)
+	assert.Equal(t, 4, exporter.report.Counts.ManualFeatureSteps)
+	assert.True(t, sidecarPaths[featureChecklistFile])
+}
+
+func TestBranchRestrictionsFeatureWithRulesets(t *testing.T) {
+	server := featuresServer(t)
+	defer server.Close()
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	exporter.SetExportRulesets(true)
+
+	feature := exporter.branchRestrictionsFeature("ws", "repo")
+	assert.Equal(t, featureExported, feature.Status)
+	assert.Contains(t, feature.Guidance, rulesetsScriptFile)
+}
+
+func TestFeaturesWithoutRepositoryContent(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	repoPath := t.TempDir()
+
+	assert.Equal(t, featureNotInUse, exporter.pipelinesFeature(repoPath).Status)
+	assert.Equal(t, featureNotInUse, exporter.codeInsightsFeature("ws", "repo", repoPath).Status, "no commits to report on")
+	assert.Equal(t, featureNotInUse, exporter.deploymentsFeature("ws", "repo", false).Status)
+	assert.Equal(t, featureNotInUse, exporter.wikiFeature("ws", "repo", &data.BitbucketRepository{}).Status)
+	assert.Equal(t, featureNotInUse, exporter.issuesFeature("ws", "repo", nil).Status)
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"archive/tar"
+	"os"
+)
+
+const (
+	// normalizedDirMode is written for directories and executable files,
+	// normalizedFileMode for all other files.
+	normalizedDirMode  = 0755
+	normalizedFileMode = 0644
+)
+
+// archiveEntryMode returns the permission bits of an archive entry. Host
+// modes depend on the umask and operating system of the machine running the
+// export and can carry setuid, setgid or sticky bits, so unless
+// --preserve-file-modes is set they are normalized: 0755 for directories and
+// files executable by anyone, 0644 for everything else.
+func (e *Exporter) archiveEntryMode(typeflag byte, mode os.FileMode) int64 {
+	if e.flags != nil && e.flags.PreserveFileModes {
+		return int64(mode.Perm()) | specialModeBits(mode)
+	}
+	switch {
+	case typeflag == tar.TypeDir, mode.Perm()&0111 != 0:
+		return normalizedDirMode
+	default:
+		return normalizedFileMode
+	}
+}
+
+// specialModeBits converts the setuid, setgid and sticky bits of an
+// os.FileMode to their tar header values.
+func specialModeBits(mode os.FileMode) int64 {
+	var bits int64
+	if mode&os.ModeSetuid != 0 {
+		bits |= 04000
+	}
+	if mode&os.ModeSetgid != 0 {
+		bits |= 02000
+	}
+	if mode&os.ModeSticky != 0 {
+		bits |= 01000
+	}
+	return bits
+}
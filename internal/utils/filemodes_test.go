@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"archive/tar"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestArchiveEntryMode(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+
+	assert.Equal(t, int64(0755), exporter.archiveEntryMode(tar.TypeDir, os.ModeDir|0700))
+	assert.Equal(t, int64(0755), exporter.archiveEntryMode(tar.TypeReg, os.ModeSetuid|0750))
+	assert.Equal(t, int64(0644), exporter.archiveEntryMode(tar.TypeReg, 0600))
+	assert.Equal(t, int64(0644), exporter.archiveEntryMode(tar.TypeReg, 0666))
+
+	exporter.flags = &data.CmdExportFlags{PreserveFileModes: true}
+	assert.Equal(t, int64(04750), exporter.archiveEntryMode(tar.TypeReg, os.ModeSetuid|0750))
+	assert.Equal(t, int64(0600), exporter.archiveEntryMode(tar.TypeReg, 0600))
+}
+
+func TestArchiveNormalizesFileModes(t *testing.T) {
+	sourceDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "schema.json"), []byte(`{}`), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "hook.sh"), []byte("#!/bin/sh\n"), 0700))
+	require.NoError(t, os.Mkdir(filepath.Join(sourceDir, "repositories"), 0700))
+	require.NoError(t, os.Symlink("hook.sh", filepath.Join(sourceDir, "link.sh")))
+
+	modes := make(map[string]int64)
+	for _, header := range archiveHeaders(t, sourceDir, "") {
+		modes[header.Name] = header.Mode
+	}
+
+	assert.Equal(t, int64(0644), modes["schema.json"])
+	assert.Equal(t, int64(0755), modes["hook.sh"])
+	assert.Equal(t, int64(0755), modes["repositories"])
+	assert.Equal(t, int64(0755), modes["link.sh"], "a symlink takes the mode of its target")
+}
@@ -0,0 +1,209 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	freezeKindRef         = "ref"
+	freezeKindPullRequest = "pull_request"
+)
+
+// ErrRepositoryNotFrozen is returned by --verify-frozen when a repository
+// changed in Bitbucket while it was being exported.
+var ErrRepositoryNotFrozen = errors.New("repository changed during the export")
+
+// SetVerifyFrozen makes the export fail if branches, tags or pull requests
+// change in Bitbucket between its start and end, to enforce a code freeze
+// at cutover.
+func (e *Exporter) SetVerifyFrozen(enabled bool) {
+	e.verifyFrozen = enabled
+}
+
+// GetRepositoryRefs returns the commit each branch and tag points to, keyed
+// by refs/heads/<name> and refs/tags/<name>.
+func (c *Client) GetRepositoryRefs(workspace, repoSlug string) (map[string]string, error) {
+	c.logger.Debug("Fetching branches and tags",
+		zap.String("workspace", workspace),
+		zap.String("repository", repoSlug))
+
+	refs := make(map[string]string)
+	page := 1
+	pageLen := c.pageLen(100)
+	hasMore := true
+
+	for hasMore {
+		endpoint := fmt.Sprintf("repositories/%s/%s/refs?page=%d&pagelen=%d", workspace, repoSlug, page, pageLen)
+
+		var response data.BitbucketRefResponse
+		if err := c.makeRequest("GET", endpoint, &response); err != nil {
+			return nil, fmt.Errorf("failed to list refs for %s/%s: %w", workspace, repoSlug, err)
+		}
+		for _, ref := range response.Values {
+			prefix := "refs/heads/"
+			if ref.Type == "tag" {
+				prefix = "refs/tags/"
+			}
+			refs[prefix+ref.Name] = ref.Target.Hash
+		}
+
+		hasMore = response.Next != ""
+		if hasMore {
+			page++
+		}
+	}
+	return refs, nil
+}
+
+// GetPullRequestsUpdatedSince returns the IDs of pull requests in any state
+// that were updated after since.
+func (c *Client) GetPullRequestsUpdatedSince(workspace, repoSlug string, since time.Time) ([]int, error) {
+	var ids []int
+	page := 1
+	pageLen := c.pageLen(50)
+	hasMore := true
+
+	for hasMore {
+		params := url.Values{}
+		params.Set("q", fmt.Sprintf("updated_on > %s", since.UTC().Format(time.RFC3339)))
+		for _, state := range []string{"OPEN", "MERGED", "DECLINED", "SUPERSEDED"} {
+			params.Add("state", state)
+		}
+		params.Set("fields", "values.id,next")
+		params.Set("page", strconv.Itoa(page))
+		params.Set("pagelen", strconv.Itoa(pageLen))
+		endpoint := fmt.Sprintf("repositories/%s/%s/pullrequests?%s", workspace, repoSlug, params.Encode())
+
+		var response data.BitbucketPRResponse
+		if err := c.makeRequest("GET", endpoint, &response); err != nil {
+			return nil, fmt.Errorf("failed to list updated pull requests for %s/%s: %w", workspace, repoSlug, err)
+		}
+		for _, pr := range response.Values {
+			ids = append(ids, pr.ID)
+		}
+
+		hasMore = response.Next != ""
+		if hasMore {
+			page++
+		}
+	}
+	return ids, nil
+}
+
+// snapshotFrozenState records the branches and tags of every repository at
+// the start of an export run with --verify-frozen.
+func (e *Exporter) snapshotFrozenState(workspace string, repoSlugs []string) error {
+	if !e.verifyFrozen {
+		return nil
+	}
+	e.frozenSince = time.Now()
+	e.frozenRefs = make(map[string]map[string]string)
+	for _, repoSlug := range repoSlugs {
+		refs, err := e.client.GetRepositoryRefs(workspace, repoSlug)
+		if err != nil {
+			return fmt.Errorf("--verify-frozen could not record the starting state: %w", err)
+		}
+		e.frozenRefs[repoSlug] = refs
+	}
+	e.logger.Info("Recorded repository state to verify the freeze",
+		zap.Strings("repositories", repoSlugs))
+	return nil
+}
+
+// checkFrozenState compares the branches and tags recorded at the start
+// with their current state and looks for pull requests updated since, and
+// fails the export if anything changed.
+func (e *Exporter) checkFrozenState(workspace string, repoSlugs []string) error {
+	if !e.verifyFrozen {
+		return nil
+	}
+
+	var violations []data.FreezeViolation
+	for _, repoSlug := range repoSlugs {
+		repository := workspace + "/" + repoSlug
+		refs, err := e.client.GetRepositoryRefs(workspace, repoSlug)
+		if err != nil {
+			return fmt.Errorf("--verify-frozen could not check the final state: %w", err)
+		}
+		violations = append(violations, refViolations(repository, e.frozenRefs[repoSlug], refs)...)
+
+		updated, err := e.client.GetPullRequestsUpdatedSince(workspace, repoSlug, e.frozenSince)
+		if err != nil {
+			return fmt.Errorf("--verify-frozen could not check the final state: %w", err)
+		}
+		for _, id := range updated {
+			violations = append(violations, data.FreezeViolation{
+				Repository: repository,
+				Kind:       freezeKindPullRequest,
+				Name:       strconv.Itoa(id),
+			})
+		}
+	}
+
+	if len(violations) == 0 {
+		e.logger.Info("Repositories did not change during the export")
+		return nil
+	}
+
+	e.report.FreezeViolations = violations
+	for _, violation := range violations {
+		e.logger.Error("Repository changed during the export",
+			zap.String("repository", violation.Repository),
+			zap.String("kind", violation.Kind),
+			zap.String("name", violation.Name))
+	}
+	return fmt.Errorf("%w: %d change(s) to %s; see freeze_violations in %s",
+		ErrRepositoryNotFrozen, len(violations), describeViolatedRepositories(violations), e.reportFile())
+}
+
+// refViolations lists refs that were created, moved or deleted.
+func refViolations(repository string, before, after map[string]string) []data.FreezeViolation {
+	names := make(map[string]bool)
+	for ref := range before {
+		names[ref] = true
+	}
+	for ref := range after {
+		names[ref] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for ref := range names {
+		sorted = append(sorted, ref)
+	}
+	sort.Strings(sorted)
+
+	var violations []data.FreezeViolation
+	for _, ref := range sorted {
+		if before[ref] == after[ref] {
+			continue
+		}
+		violations = append(violations, data.FreezeViolation{
+			Repository: repository,
+			Kind:       freezeKindRef,
+			Name:       ref,
+			Before:     before[ref],
+			After:      after[ref],
+		})
+	}
+	return violations
+}
+
+func describeViolatedRepositories(violations []data.FreezeViolation) string {
+	var repositories []string
+	seen := make(map[string]bool)
+	for _, violation := range violations {
+		if !seen[violation.Repository] {
+			seen[violation.Repository] = true
+			repositories = append(repositories, violation.Repository)
+		}
+	}
+	return strings.Join(repositories, ", ")
+}
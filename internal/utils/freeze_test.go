@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// freezeServer serves the refs of ws/repo; once changed is set, main has
+// moved, a tag was created and pull request 7 was updated.
+func freezeServer(t *testing.T, changed *atomic.Bool) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repositories/ws/repo/refs":
+			if r.URL.Query().Get("page") == "2" {
+				writeResponse(t, w, []byte(`{"values": [{"name": "feature", "type": "branch", "target": {"hash": "bbb"}}]}`))
+				return
+			}
+			values := `{"name": "main", "type": "branch", "target": {"hash": "aaa"}}`
+			if changed.Load() {
+				values = `{"name": "main", "type": "branch", "target": {"hash": "ccc"}},
+					{"name": "v1.0", "type": "tag", "target": {"hash": "ccc"}}`
+			}
+			writeResponse(t, w, []byte(`{"values": [`+values+`],
+				"next": "`+server.URL+`/repositories/ws/repo/refs?page=2"}`))
+		case "/repositories/ws/repo/pullrequests":
+			query := r.URL.Query()
+			assert.True(t, strings.HasPrefix(query.Get("q"), "updated_on > "), query.Get("q"))
+			assert.ElementsMatch(t, []string{"OPEN", "MERGED", "DECLINED", "SUPERSEDED"}, query["state"])
+			if changed.Load() {
+				writeResponse(t, w, []byte(`{"values": [{"id": 7}]}`))
+				return
+			}
+			writeResponse(t, w, []byte(`{"values": []}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server
+}
+
+func TestGetRepositoryRefs(t *testing.T) {
+	var changed atomic.Bool
+	server := freezeServer(t, &changed)
+	defer server.Close()
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+
+	refs, err := client.GetRepositoryRefs("ws", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"refs/heads/main": "aaa", "refs/heads/feature": "bbb"}, refs)
+}
+
+func TestVerifyFrozenPassesWithoutChanges(t *testing.T) {
+	var changed atomic.Bool
+	server := freezeServer(t, &changed)
+	defer server.Close()
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	exporter.SetVerifyFrozen(true)
+
+	require.NoError(t, exporter.snapshotFrozenState("ws", []string{"repo"}))
+	require.NoError(t, exporter.checkFrozenState("ws", []string{"repo"}))
+	assert.Empty(t, exporter.report.FreezeViolations)
+}
+
+func TestVerifyFrozenReportsChanges(t *testing.T) {
+	var changed atomic.Bool
+	server := freezeServer(t, &changed)
+	defer server.Close()
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	exporter.SetVerifyFrozen(true)
+
+	require.NoError(t, exporter.snapshotFrozenState("ws", []string{"repo"}))
+	changed.Store(true)
+	err := exporter.checkFrozenState("ws", []string{"repo"})
+
+	assert.ErrorIs(t, err, ErrRepositoryNotFrozen)
+	assert.ErrorContains(t, err, "3 change(s) to ws/repo")
+	assert.Equal(t, []data.FreezeViolation{
+		{Repository: "ws/repo", Kind: "ref", Name: "refs/heads/main", Before: "aaa", After: "ccc"},
+		{Repository: "ws/repo", Kind: "ref", Name: "refs/tags/v1.0", After: "ccc"},
+		{Repository: "ws/repo", Kind: "pull_request", Name: "7"},
+	}, exporter.report.FreezeViolations)
+}
+
+func TestVerifyFrozenDisabled(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+
+	assert.NoError(t, exporter.snapshotFrozenState("ws", []string{"repo"}))
+	assert.NoError(t, exporter.checkFrozenState("ws", []string{"repo"}))
+}
+
+func TestRefViolationsDeletedRef(t *testing.T) {
+	violations := refViolations("ws/repo", map[string]string{"refs/heads/old": "aaa"}, map[string]string{})
+	assert.Equal(t, []data.FreezeViolation{
+		{Repository: "ws/repo", Kind: "ref", Name: "refs/heads/old", Before: "aaa"},
+	}, violations)
+}
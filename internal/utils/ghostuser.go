@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// DefaultGhostUser is the login deleted Bitbucket accounts are exported as,
+// matching the account GitHub shows for deleted users.
+const DefaultGhostUser = "ghost"
+
+var ghostUserPattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?$`)
+
+// ValidateGhostUser checks a --ghost-user login. An empty value selects
+// DefaultGhostUser.
+func ValidateGhostUser(login string) error {
+	if login == "" || ghostUserPattern.MatchString(login) {
+		return nil
+	}
+	return fmt.Errorf("invalid value for --ghost-user: %q (use letters, digits and inner hyphens)", login)
+}
+
+// SetGhostUser sets the login pull requests and comments by deleted
+// Bitbucket accounts are attributed to.
+func (c *Client) SetGhostUser(login string) {
+	c.ghostUser = login
+}
+
+func (c *Client) ghostLogin() string {
+	if c.ghostUser == "" {
+		return DefaultGhostUser
+	}
+	return c.ghostUser
+}
+
+// authorURL returns the user URL for a pull request or comment author.
+// Deleted accounts come back without a UUID and are attributed to the ghost
+// user instead of producing a URL without a login.
+func (c *Client) authorURL(workspace string, user data.BitbucketPRUser) string {
+	login := c.userLogin(user.UUID, user.DisplayName)
+	if strings.Trim(user.UUID, "{}") == "" {
+		c.logger.Debug("Attributing author without UUID to the ghost user",
+			zap.String("display_name", user.DisplayName),
+			zap.String("ghost_user", c.ghostLogin()))
+		login = c.ghostLogin()
+	} else {
+		c.recordContributor(user)
+	}
+	return c.formatURL("user", workspace, "", login)
+}
+
+// addGhostUser counts the pull requests and comments attributed to the
+// ghost user and, when there are any, adds its record to users. It reports
+// whether users changed.
+func (e *Exporter) addGhostUser(workspace string, users []data.User, prs []data.PullRequest,
+	regularComments []data.IssueComment, reviewComments []data.PullRequestReviewComment) ([]data.User, bool) {
+	ghostURL := e.client.formatURL("user", workspace, "", e.client.ghostLogin())
+	for _, pr := range prs {
+		if pr.User == ghostURL {
+			e.report.Counts.GhostPullRequests++
+		}
+	}
+	for _, comment := range regularComments {
+		if comment.User == ghostURL {
+			e.report.Counts.GhostComments++
+		}
+	}
+	for _, comment := range reviewComments {
+		if comment.User == ghostURL {
+			e.report.Counts.GhostComments++
+		}
+	}
+	if e.report.Counts.GhostPullRequests+e.report.Counts.GhostComments == 0 {
+		return users, false
+	}
+
+	e.logger.Info("Attributed pull requests and comments by deleted accounts to the ghost user",
+		zap.String("ghost_user", e.client.ghostLogin()),
+		zap.Int("pull_requests", e.report.Counts.GhostPullRequests),
+		zap.Int("comments", e.report.Counts.GhostComments))
+	if e.usersScope() == UsersScopeNone {
+		return users, false
+	}
+	for _, user := range users {
+		if user.URL == ghostURL {
+			return users, false
+		}
+	}
+	return append(users, data.User{
+		Type:      "user",
+		URL:       ghostURL,
+		Login:     e.client.ghostLogin(),
+		Name:      "Deleted user",
+		Company:   nil,
+		Website:   nil,
+		Location:  nil,
+		Emails:    []data.Email{},
+		CreatedAt: formatDateToZ(e.client.now().Format(time.RFC3339)),
+	}), true
+}
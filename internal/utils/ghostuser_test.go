@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidateGhostUser(t *testing.T) {
+	for _, login := range []string{"", "ghost", "deleted-user", "Former1"} {
+		assert.NoError(t, ValidateGhostUser(login), login)
+	}
+	for _, login := range []string{"-ghost", "ghost-", "deleted user", "ws/ghost"} {
+		assert.ErrorContains(t, ValidateGhostUser(login), "--ghost-user", login)
+	}
+}
+
+func TestAuthorURL(t *testing.T) {
+	client := &Client{logger: zap.NewNop()}
+
+	assert.Equal(t, "https://bitbucket.org/alice",
+		client.authorURL("ws", data.BitbucketPRUser{UUID: "{alice}", DisplayName: "Alice"}))
+	assert.Equal(t, "https://bitbucket.org/ghost",
+		client.authorURL("ws", data.BitbucketPRUser{DisplayName: "Former user"}))
+	assert.Equal(t, map[string]string{"alice": "Alice"}, client.contributors)
+
+	client.SetGhostUser("deleted-user")
+	assert.Equal(t, "https://bitbucket.org/deleted-user", client.authorURL("ws", data.BitbucketPRUser{}))
+}
+
+func TestAddGhostUser(t *testing.T) {
+	client := &Client{logger: zap.NewNop()}
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	ghostURL := format.URL("user", "ws", "", DefaultGhostUser)
+	members := []data.User{{Type: "user", URL: format.URL("user", "ws", "", "alice"), Login: "alice"}}
+
+	users, added := exporter.addGhostUser("ws", members,
+		[]data.PullRequest{{User: ghostURL}, {User: members[0].URL}},
+		[]data.IssueComment{{User: ghostURL}},
+		[]data.PullRequestReviewComment{{User: ghostURL}},
+	)
+
+	require.True(t, added)
+	require.Len(t, users, 2)
+	assert.Equal(t, DefaultGhostUser, users[1].Login)
+	assert.Equal(t, ghostURL, users[1].URL)
+	assert.Equal(t, "Deleted user", users[1].Name)
+	assert.NotNil(t, users[1].Emails)
+	assert.Equal(t, 1, exporter.report.Counts.GhostPullRequests)
+	assert.Equal(t, 2, exporter.report.Counts.GhostComments)
+}
+
+func TestAddGhostUserWithoutDeletedAuthors(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	members := []data.User{{URL: format.URL("user", "ws", "", "alice"), Login: "alice"}}
+
+	users, added := exporter.addGhostUser("ws", members,
+		[]data.PullRequest{{User: members[0].URL}}, nil, nil)
+
+	assert.False(t, added)
+	assert.Equal(t, members, users)
+	assert.Zero(t, exporter.report.Counts.GhostPullRequests)
+}
+
+func TestAddGhostUserUsersScopeNone(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.flags = &data.CmdExportFlags{UsersScope: UsersScopeNone}
+
+	users, added := exporter.addGhostUser("ws", nil, nil,
+		[]data.IssueComment{{User: format.URL("user", "ws", "", DefaultGhostUser)}}, nil)
+
+	assert.False(t, added)
+	assert.Empty(t, users)
+	assert.Equal(t, 1, exporter.report.Counts.GhostComments)
+}
+
+func TestContributorUsersSkipsGhostUser(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	ghostURL := format.URL("user", "ws", "", DefaultGhostUser)
+
+	users := exporter.contributorUsers([]data.PullRequest{{User: ghostURL}}, nil, nil)
+	users, added := exporter.addGhostUser("ws", users, []data.PullRequest{{User: ghostURL}}, nil, nil)
+
+	assert.True(t, added)
+	require.Len(t, users, 1)
+	assert.Equal(t, "Deleted user", users[0].Name)
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// gitCredentials returns the user and secret git uses for HTTPS access to
+// Bitbucket with the client's authentication method, the first one of an
+// --auth-fallback chain.
+func (c *Client) gitCredentials() (string, string) {
+	switch c.primaryAuthMethod() {
+	case AuthMethodAccessToken:
+		return "x-token-auth", c.accessToken
+	case AuthMethodAPIToken:
+		return "x-bitbucket-api-token-auth", c.apiToken
+	case AuthMethodAppPassword:
+		return c.username, c.appPass
+	}
+	return "", ""
+}
+
+// gitCredentialHelper writes the credentials for cloneURL's host to a private,
+// one-time file read by git's built-in store helper, and returns the
+// GIT_CONFIG_* environment that points git at it. The secret itself never
+// appears in the clone URL, the command line or the environment, so it does
+// not show up in process listings during long clones. The returned cleanup
+// function removes the file and must be called once the clone finished.
+func (c *Client) gitCredentialHelper(cloneURL string) ([]string, func(), error) {
+	noop := func() {}
+	if err := c.ensureOAuthToken(c.context()); err != nil {
+		return nil, noop, err
+	}
+	user, secret := c.gitCredentials()
+	parsed, err := url.Parse(cloneURL)
+	if (user == "" && secret == "") || err != nil || parsed.Host == "" ||
+		(parsed.Scheme != "https" && parsed.Scheme != "http") {
+		return nil, noop, nil
+	}
+
+	helperDir, err := os.MkdirTemp("", "bbc-exporter-credentials-")
+	if err != nil {
+		return nil, noop, fmt.Errorf("failed to create credential helper directory: %w", err)
+	}
+	cleanup := func() {
+		_ = os.RemoveAll(helperDir)
+	}
+
+	credentialURL := url.URL{Scheme: parsed.Scheme, Host: parsed.Host, User: url.UserPassword(user, secret)}
+	credentialFile := filepath.Join(helperDir, "credentials")
+	if err := os.WriteFile(credentialFile, []byte(credentialURL.String()+"\n"), 0600); err != nil {
+		cleanup()
+		return nil, noop, fmt.Errorf("failed to write credential helper file: %w", err)
+	}
+
+	// Append after any GIT_CONFIG_* entries the user already set. The empty
+	// helper entry resets helpers from the user's git config, so the
+	// credentials are not copied into a system keychain.
+	index := 0
+	if count, err := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT")); err == nil && count > 0 {
+		index = count
+	}
+	env := []string{
+		fmt.Sprintf("GIT_CONFIG_COUNT=%d", index+2),
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=credential.helper", index),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=", index),
+		fmt.Sprintf("GIT_CONFIG_KEY_%d=credential.helper", index+1),
+		fmt.Sprintf("GIT_CONFIG_VALUE_%d=store --file=%s", index+1, shellQuote(filepath.ToSlash(credentialFile))),
+	}
+	return env, cleanup, nil
+}
+
+// minDetectableSecretLength avoids false positives when checking a config for
+// leftover credentials; shorter values match ordinary paths and names.
+const minDetectableSecretLength = 8
+
+// gitSecrets lists the credential values that must never appear in an
+// exported repository.
+func (c *Client) gitSecrets() []string {
+	var secrets []string
+	candidates := append([]string{c.accessToken, c.apiToken, c.appPass, c.oauthSecret}, c.retiredSecrets...)
+	for _, secret := range candidates {
+		if len(secret) >= minDetectableSecretLength {
+			secrets = append(secrets, secret, url.QueryEscape(secret))
+		}
+	}
+	return secrets
+}
+
+// scrubRepositoryCredentials removes credentials from the config of a cloned
+// repository: userinfo in remote URLs and any http.extraHeader entries. It
+// then checks that none of the client's secrets remain in the config file.
+func (e *Exporter) scrubRepositoryCredentials(repoDir string) error {
+	cmd := exec.Command("git", "config", "--local", "--get-regexp", `^remote\..*\.(url|pushurl)$`)
+	cmd.Dir = repoDir
+	output, _ := cmd.Output()
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		key, value, found := strings.Cut(line, " ")
+		if !found {
+			continue
+		}
+		parsed, err := url.Parse(value)
+		if err != nil || parsed.User == nil {
+			continue
+		}
+		parsed.User = nil
+		cmd := exec.Command("git", "config", "--local", key, parsed.String())
+		cmd.Dir = repoDir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to remove credentials from %s: %s: %w", key, string(output), err)
+		}
+	}
+
+	cmd = exec.Command("git", "config", "--local", "--name-only", "--get-regexp", `^http\..*extraheader$`)
+	cmd.Dir = repoDir
+	output, _ = cmd.Output()
+	for _, key := range strings.Fields(string(output)) {
+		cmd := exec.Command("git", "config", "--local", "--unset-all", key)
+		cmd.Dir = repoDir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("failed to remove %s: %s: %w", key, string(output), err)
+		}
+	}
+
+	cmd = exec.Command("git", "rev-parse", "--git-path", "config")
+	cmd.Dir = repoDir
+	configPath, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to locate repository config: %w", err)
+	}
+	path := strings.TrimSpace(string(configPath))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(repoDir, path)
+	}
+	config, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read repository config: %w", err)
+	}
+	if e.client != nil {
+		for _, secret := range e.client.gitSecrets() {
+			if strings.Contains(string(config), secret) {
+				return fmt.Errorf("repository config still contains credentials after scrubbing")
+			}
+		}
+	}
+	return nil
+}
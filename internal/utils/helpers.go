@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"net/url"
 	"os"
 	"os/exec"
@@ -15,6 +16,8 @@ import (
 
 	"github.com/cli/go-gh/v2/pkg/auth"
 	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/i18n"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"go.uber.org/zap"
@@ -23,8 +26,7 @@ import (
 
 var (
 	repoNameInvalidCharsRegex = regexp.MustCompile(`[^a-zA-Z0-9\-\._]|^\.|\.$/`)
-	whitespaceRegex           = regexp.MustCompile(`\s+`)
-	hexPatternRegex           = regexp.MustCompile(`^[0-9a-f]{40}$`)
+	hexPatternRegex           = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
 	prNumberPattern           = regexp.MustCompile(`\b#(\d+)\b`)
 )
 
@@ -86,35 +88,44 @@ func GetAPIURLHost(apiURL string) (string, string, error) {
 	return host, host, nil
 }
 
+// formatDateToZ converts a Bitbucket timestamp to the archive's UTC layout.
 func formatDateToZ(inputDate string) string {
-	if inputDate == "" {
-		return ""
-	}
+	return format.Date(inputDate)
+}
 
-	formats := []string{
-		"2006-01-02T15:04:05.999999+00:00",
-		"2006-01-02T15:04:05.999999-07:00",
-		"2006-01-02T15:04:05Z",
-		"2006-01-02T15:04:05.999Z",
-		"2006-01-02 15:04:05 -0700",
-		"2006-01-02T15:04:05.999999Z",
-		"2006-01-02T15:04:05",
-		"2006-01-02 15:04:05",
-		"2006/01/02 15:04:05",
-	}
+const (
+	objectFormatSHA1   = "sha1"
+	objectFormatSHA256 = "sha256"
+)
 
-	for _, format := range formats {
-		t, err := time.Parse(format, inputDate)
-		if err == nil {
-			return t.UTC().Format("2006-01-02T15:04:05Z")
-		}
+// isFullCommitSHA reports whether sha is a complete SHA-1 (40 hex) or
+// SHA-256 (64 hex) object name.
+func isFullCommitSHA(sha string) bool {
+	return hexPatternRegex.MatchString(sha)
+}
+
+// GetRepositoryObjectFormat returns the hash algorithm ("sha1" or "sha256")
+// used by the git repository at repoPath.
+func GetRepositoryObjectFormat(repoPath string) (string, error) {
+	cmd := exec.Command("git", "rev-parse", "--show-object-format")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to detect repository object format: %w", err)
 	}
 
-	return ""
+	format := strings.TrimSpace(string(output))
+	switch format {
+	case objectFormatSHA1, objectFormatSHA256:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported repository object format: %s", format)
+	}
 }
 
 func GetFullCommitSHAFromLocalRepo(repoPath string, shortSHA string) (string, error) {
-	if len(shortSHA) == 40 {
+	shortSHA = strings.ToLower(shortSHA)
+	if isFullCommitSHA(shortSHA) {
 		return shortSHA, nil
 	}
 
@@ -127,32 +138,39 @@ func GetFullCommitSHAFromLocalRepo(repoPath string, shortSHA string) (string, er
 	}
 
 	fullSHA := strings.TrimSpace(string(output))
-	if len(fullSHA) == 40 {
+	if isFullCommitSHA(fullSHA) {
 		return fullSHA, nil
 	}
 
 	return "", fmt.Errorf("unexpected output from git rev-parse: %s", fullSHA)
 }
 
+// SetCompactJSON writes the JSON files inside the archive without
+// indentation. Files kept next to the archive, such as the export report,
+// stay indented for reading.
+func (e *Exporter) SetCompactJSON(enabled bool) {
+	e.compactJSON = enabled
+}
+
 func (e *Exporter) writeJSONFile(filename string, data interface{}) error {
 	filepath := filepath.Join(e.outputDir, filename)
 	e.logger.Debug("Writing file", zap.String("path", filepath))
+	topLevel, _, _ := strings.Cut(ToUnixPath(filename), "/")
+	indent := !e.compactJSON || sidecarPaths[topLevel]
 
-	file, err := os.Create(filepath)
-	if err != nil {
-		return fmt.Errorf("failed to create file %s: %w", filename, err)
-	}
-	defer func() {
-		if err := file.Close(); err != nil {
-			e.logger.Warn("Failed to close file", zap.String("file", filepath), zap.Error(err))
+	err := writeFileAtomic(filepath, 0644, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		if indent {
+			encoder.SetIndent("", "  ")
+		}
+		if err := encoder.Encode(data); err != nil {
+			return fmt.Errorf("failed to encode data for %s: %w", filename, err)
 		}
-	}()
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(data); err != nil {
-		return fmt.Errorf("failed to encode data for %s: %w", filename, err)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write file %s: %w", filename, err)
 	}
-
 	return nil
 }
 
@@ -180,7 +198,7 @@ func (e *Exporter) createRepositoryInfoFiles(workspace, repoSlug string) error {
 	}
 
 	// Create last-sync file with current timestamp
-	syncTime := time.Now().Format("2006-01-02T15:04:05")
+	syncTime := e.client.now().Format("2006-01-02T15:04:05")
 	if err := os.WriteFile(filepath.Join(infoDir, "last-sync"), []byte(syncTime), 0644); err != nil {
 		return fmt.Errorf("failed to create last-sync file: %w", err)
 	}
@@ -188,55 +206,15 @@ func (e *Exporter) createRepositoryInfoFiles(workspace, repoSlug string) error {
 	return nil
 }
 
-func formatURL(urlType string, workspace, repoSlug string, id ...interface{}) string {
-	switch urlType {
-	case "repository":
-		return fmt.Sprintf("https://bitbucket.org/%s/%s", workspace, repoSlug)
-	case "user":
-		userID := workspace
-		if len(id) > 0 && id[0] != nil {
-			userID = fmt.Sprintf("%v", id[0])
-		}
-		return fmt.Sprintf("https://bitbucket.org/%s", userID)
-	case "organization":
-		return fmt.Sprintf("https://bitbucket.org/%s", workspace)
-	case "pr":
-		if len(id) > 0 {
-			return fmt.Sprintf("https://bitbucket.org/%s/%s/pull/%v", workspace, repoSlug, id[0])
-		}
-		return fmt.Sprintf("https://bitbucket.org/%s/%s/pulls", workspace, repoSlug)
-	case "issue_comment":
-		if len(id) > 1 {
-			return fmt.Sprintf("https://bitbucket.org/%s/%s/pull/%v#issuecomment-%v",
-				workspace, repoSlug, id[0], id[1])
+// extractPRNumber returns the pull request number of a pull request URL
+// generated with urls.
+func extractPRNumber(urls format.URLs, prURL string) string {
+	if _, ok := urls.Templates["pr"]; ok {
+		if ids := idsFromURL(urls, "pr", prURL); len(ids) > 0 {
+			return ids[0]
 		}
-		return fmt.Sprintf("https://bitbucket.org/%s/%s/pull/comments", workspace, repoSlug)
-	case "pr_review":
-		if len(id) > 0 {
-			return fmt.Sprintf("https://bitbucket.org/%s/%s/pull/%v/files#pullrequestreview-%v",
-				workspace, repoSlug, id[0], id[1])
-		}
-		return fmt.Sprintf("https://bitbucket.org/%s/%s/pull/reviews", workspace, repoSlug)
-	case "pr_review_comment":
-		if len(id) > 0 {
-			return fmt.Sprintf("https://bitbucket.org/%s/%s/pull/%v/files#r%v",
-				workspace, repoSlug, id[0], id[1])
-		}
-		return fmt.Sprintf("https://bitbucket.org/%s/%s/pull/comments", workspace, repoSlug)
-	case "pr_review_thread":
-		if len(id) > 0 {
-			return fmt.Sprintf("https://bitbucket.org/%s/%s/pull/%v/files#pullrequestreviewthread-%v",
-				workspace, repoSlug, id[0], id[1])
-		}
-		return fmt.Sprintf("https://bitbucket.org/%s/%s/pull/threads", workspace, repoSlug)
-	case "git":
-		return fmt.Sprintf("tarball://root/repositories/%s/%s.git", workspace, repoSlug)
-	default:
-		return fmt.Sprintf("https://bitbucket.org/%s/%s", workspace, repoSlug)
+		return ""
 	}
-}
-
-func extractPRNumber(prURL string) string {
 	if !strings.Contains(prURL, "/pull/") {
 		return ""
 	}
@@ -254,66 +232,64 @@ func extractPRNumber(prURL string) string {
 	return ""
 }
 
+// updateRepositoryField updates the in-memory record of a repository, matched
+// by name (case-insensitive) or slug.
 func (e *Exporter) updateRepositoryField(repoSlug string, field string, value interface{}) {
-	filePath := filepath.Join(e.outputDir, "repositories_000001.json")
-	fileData, err := os.ReadFile(filePath)
-	if err != nil {
-		e.logger.Warn("Failed to read repositories file", zap.Error(err))
-		return
-	}
-
-	var repositories []data.Repository
-	if err := json.Unmarshal(fileData, &repositories); err != nil {
-		e.logger.Warn("Failed to parse repositories file", zap.Error(err))
-		return
-	}
-
-	repoUpdated := false
-	for i, repo := range repositories {
+	for i, repo := range e.repositories {
 		// Case-insensitive comparison for name, exact match for slug (always lowercase)
 		if strings.EqualFold(repo.Name, repoSlug) || repo.Slug == repoSlug {
 			switch field {
 			case "default_branch":
-				repositories[i].DefaultBranch = value.(string)
+				e.repositories[i].DefaultBranch = value.(string)
 			case "git_url":
-				repositories[i].GitURL = value.(string)
+				e.repositories[i].GitURL = value.(string)
+			case "git_lfs_in_archives":
+				e.repositories[i].GeneralSettings["git_lfs_in_archives"] = value.(bool)
+			case "wiki_url":
+				wikiURL := value.(string)
+				e.repositories[i].HasWiki = true
+				e.repositories[i].WikiURL = &wikiURL
 				// Add other fields as needed
 			}
-			repoUpdated = true
-			break
+			e.logger.Debug(fmt.Sprintf("Updated %s in repository record", field),
+				zap.String(field, fmt.Sprintf("%v", value)))
+			return
 		}
 	}
 
-	if !repoUpdated {
-		e.logger.Warn("Repository not found in repositories file",
-			zap.String("repo", repoSlug))
-		return
-	}
+	e.logger.Warn("Repository not found in repository records",
+		zap.String("repo", repoSlug))
+}
 
-	updatedData, err := json.MarshalIndent(repositories, "", "  ")
-	if err != nil {
-		e.logger.Warn("Failed to encode repositories data", zap.Error(err))
-		return
+// writeRepositoriesFile writes the in-memory repository records.
+func (e *Exporter) writeRepositoriesFile() error {
+	for i, repo := range e.repositories {
+		e.repositories[i].Description = sanitizeDescription(repo.Description)
 	}
-
-	if err := os.WriteFile(filePath, updatedData, 0644); err != nil {
-		e.logger.Warn("Failed to write updated repositories file", zap.Error(err))
-		return
+	if err := writeRecordFiles(e, repositoriesFile, e.repositories); err != nil {
+		return fmt.Errorf("failed to write repositories file: %w", err)
 	}
-
-	e.logger.Debug(fmt.Sprintf("Updated %s in repositories file", field),
-		zap.String(field, fmt.Sprintf("%v", value)))
+	return nil
 }
 
+// ValidateExportFlags checks the credentials and export options, and reports
+// every problem found in one *ValidationError.
 func ValidateExportFlags(cmdFlags *data.CmdExportFlags) error {
+	return JoinProblems(validateCredentials(cmdFlags), ValidateExportOptions(cmdFlags))
+}
+
+// validateCredentials returns the first problem with the authentication
+// flags; the remaining checks depend on which method was meant.
+func validateCredentials(cmdFlags *data.CmdExportFlags) error {
 	hasToken := cmdFlags.BitbucketAccessToken != ""
 	hasAPIToken := cmdFlags.BitbucketAPIToken != ""
 	hasEmail := cmdFlags.BitbucketEmail != ""
 	hasBasicAuth := cmdFlags.BitbucketUser != "" && cmdFlags.BitbucketAppPass != ""
+	hasOAuth := cmdFlags.BitbucketOAuthKey != "" && cmdFlags.BitbucketOAuthSecret != ""
 
-	hasValidAuth := hasToken || (hasAPIToken && hasEmail) || hasBasicAuth
+	hasValidAuth := hasToken || (hasAPIToken && hasEmail) || hasBasicAuth || hasOAuth
 	if !hasValidAuth {
-		return fmt.Errorf("authentication credentials required: either provide a workspace access token with --access-token, an API token with email (--api-token and --email), or both username (--user) and app password (--app-password)")
+		return i18n.Errorf(i18n.AuthRequired)
 	}
 
 	// Check for mixed auth methods
@@ -327,39 +303,131 @@ func ValidateExportFlags(cmdFlags *data.CmdExportFlags) error {
 	if hasBasicAuth {
 		authMethodsCount++
 	}
+	if hasOAuth {
+		authMethodsCount++
+	}
 
-	if authMethodsCount > 1 {
-		return fmt.Errorf("mixed authentication methods: provide either workspace token OR (API token + email) OR (username + app-password), not multiple types")
+	if len(cmdFlags.AuthFallback) > 0 {
+		if err := ValidateAuthFallback(cmdFlags); err != nil {
+			return err
+		}
+	} else if authMethodsCount > 1 {
+		return i18n.Errorf(i18n.AuthMixed)
 	}
 
 	// Validate that API token comes with email
 	if hasAPIToken && !hasEmail {
-		return fmt.Errorf("email is required when using API token authentication. Please provide it with --email or BITBUCKET_EMAIL environment variable")
+		return i18n.Errorf(i18n.EmailRequired)
 	}
 
 	// Validate that email comes with API token
 	if hasEmail && !hasAPIToken {
-		return fmt.Errorf("API token is required when using email authentication. Please provide it with --api-token or BITBUCKET_API_TOKEN environment variable")
+		return i18n.Errorf(i18n.APITokenRequired)
 	}
 
 	// Validate that username comes with app password
 	if cmdFlags.BitbucketUser != "" && cmdFlags.BitbucketAppPass == "" {
-		return fmt.Errorf("app password is required when using username authentication. Please provide it with --app-password or BITBUCKET_APP_PASSWORD environment variable")
+		return i18n.Errorf(i18n.AppPasswordRequired)
 	}
 
 	// Validate that app password comes with username
 	if cmdFlags.BitbucketAppPass != "" && cmdFlags.BitbucketUser == "" {
-		return fmt.Errorf("username is required when using app password authentication. Please provide it with --user or BITBUCKET_USERNAME environment variable")
+		return i18n.Errorf(i18n.UsernameRequired)
 	}
 
-	// Validate PRsFromDate format if provided
+	// Validate that the OAuth key and secret come together
+	if cmdFlags.BitbucketOAuthKey != "" && cmdFlags.BitbucketOAuthSecret == "" {
+		return i18n.Errorf(i18n.OAuthSecretRequired)
+	}
+	if cmdFlags.BitbucketOAuthSecret != "" && cmdFlags.BitbucketOAuthKey == "" {
+		return i18n.Errorf(i18n.OAuthKeyRequired)
+	}
+	return nil
+}
+
+// ValidateExportOptions checks the export option flags other than the
+// credentials, which may still come from environment variables.
+func ValidateExportOptions(cmdFlags *data.CmdExportFlags) error {
+	var problems []error
+
 	if cmdFlags.PRsFromDate != "" {
 		if _, err := time.Parse("2006-01-02", cmdFlags.PRsFromDate); err != nil {
-			return fmt.Errorf("invalid date format for --prs-from-date: %v (expected format: YYYY-MM-DD)", err)
+			problems = append(problems, i18n.Errorf(i18n.InvalidDate, "--prs-from-date", err))
 		}
 	}
 
-	return nil
+	if cmdFlags.ColdStorageBefore != "" {
+		if _, err := time.Parse("2006-01-02", cmdFlags.ColdStorageBefore); err != nil {
+			problems = append(problems, i18n.Errorf(i18n.InvalidDate, "--cold-storage-before", err))
+		}
+	}
+
+	for _, pattern := range cmdFlags.PRsTouchingPaths {
+		if _, err := compilePathPattern(pattern); err != nil {
+			problems = append(problems, fmt.Errorf("invalid value for --prs-touching-path: %w", err))
+		}
+	}
+
+	if _, err := ParseSubdirSplits(cmdFlags.SubdirSplits); err != nil {
+		problems = append(problems, err)
+	}
+
+	if _, err := ParseEncryption(cmdFlags.Encrypt); err != nil {
+		problems = append(problems, err)
+	}
+
+	problems = append(problems,
+		ValidateCommentFormatter(cmdFlags.CommentFormatter),
+		ValidateUsersScope(cmdFlags.UsersScope),
+		ValidateLongPaths(cmdFlags.LongPaths),
+		ValidateTarFormat(cmdFlags.TarFormat),
+	)
+	if cmdFlags.TarFormat != "" && cmdFlags.TarFormat != TarFormatUSTAR &&
+		cmdFlags.LongPaths != "" && cmdFlags.LongPaths != LongPathsGNU {
+		problems = append(problems, fmt.Errorf("--long-paths %s only applies to --tar-format ustar", cmdFlags.LongPaths))
+	}
+
+	problems = append(problems,
+		ValidateConsistency(cmdFlags.Consistency),
+		ValidateMergeCommitCheck(cmdFlags.MergeCommitCheck),
+		ValidateConcurrency(cmdFlags.Concurrency),
+		ValidateRecordsPerFile(cmdFlags.RecordsPerFile),
+		ValidateLocalMirror(cmdFlags),
+		ValidatePRNumberPrediction(cmdFlags),
+		ValidateCloneOptions(cmdFlags),
+		ValidateProtectionHealth(cmdFlags),
+		ValidateGitContentOptions(cmdFlags),
+		ValidateNetworkOptions(NetworkOptionsFromFlags(cmdFlags)),
+		ValidateGitOutput(cmdFlags.GitOutput),
+		ValidateLinkTarget(cmdFlags.LinkTarget),
+		ValidateProgressFormat(cmdFlags.ProgressFormat),
+	)
+
+	if cmdFlags.PRFooterTemplate != "" {
+		if _, err := ParsePRFooterTemplate(cmdFlags.PRFooterTemplate); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if cmdFlags.CommentFooter != "" {
+		if _, err := ParseCommentFooterTemplate(cmdFlags.CommentFooter); err != nil {
+			problems = append(problems, err)
+		}
+	}
+
+	if cmdFlags.Resume && cmdFlags.OutputDir == "" {
+		problems = append(problems, fmt.Errorf("--resume requires --output pointing at the directory of the interrupted export"))
+	}
+
+	problems = append(problems, ValidateGhostUser(cmdFlags.GhostUser))
+
+	if _, err := ParseAsOf(cmdFlags.AsOf); err != nil {
+		problems = append(problems, err)
+	}
+	if cmdFlags.AsOf != "" && cmdFlags.TopUpFetch {
+		problems = append(problems, fmt.Errorf("--top-up-fetch cannot be combined with --as-of"))
+	}
+
+	return JoinProblems(problems...)
 }
 
 func SetupEnvironmentCredentials(cmdFlags *data.CmdExportFlags) {
@@ -378,15 +446,22 @@ func SetupEnvironmentCredentials(cmdFlags *data.CmdExportFlags) {
 	if cmdFlags.BitbucketEmail == "" {
 		cmdFlags.BitbucketEmail = os.Getenv("BITBUCKET_EMAIL")
 	}
+	if cmdFlags.BitbucketOAuthKey == "" {
+		cmdFlags.BitbucketOAuthKey = os.Getenv("BITBUCKET_OAUTH_KEY")
+	}
+	if cmdFlags.BitbucketOAuthSecret == "" {
+		cmdFlags.BitbucketOAuthSecret = os.Getenv("BITBUCKET_OAUTH_SECRET")
+	}
 	if cmdFlags.TempDir == "" {
 		cmdFlags.TempDir = os.Getenv("BITBUCKET_TEMP_DIR")
 	}
 
-	// Add warning for multiple auth methods
-	if cmdFlags.BitbucketAccessToken != "" &&
+	// Add warning for multiple auth methods; --auth-fallback uses all of them
+	singleMethod := len(cmdFlags.AuthFallback) == 0
+	if singleMethod && cmdFlags.BitbucketAccessToken != "" &&
 		((cmdFlags.BitbucketUser != "" && cmdFlags.BitbucketAppPass != "") || (cmdFlags.BitbucketAPIToken != "" && cmdFlags.BitbucketEmail != "")) {
 		fmt.Fprintf(os.Stderr, "Warning: Multiple authentication methods detected. Workspace access token authentication will be used.\n")
-	} else if (cmdFlags.BitbucketAPIToken != "" && cmdFlags.BitbucketEmail != "") &&
+	} else if singleMethod && (cmdFlags.BitbucketAPIToken != "" && cmdFlags.BitbucketEmail != "") &&
 		(cmdFlags.BitbucketUser != "" && cmdFlags.BitbucketAppPass != "") {
 		fmt.Fprintf(os.Stderr, "Warning: Both API token and username/password are set. API token authentication will be used.\n")
 	}
@@ -400,10 +475,10 @@ func SetupEnvironmentCredentials(cmdFlags *data.CmdExportFlags) {
 
 func PrintSuccessMessage(outputPath string) {
 	if strings.HasSuffix(outputPath, ".tar.gz") {
-		fmt.Printf("\nExport successful!\nArchive created: %s\n", outputPath)
-		fmt.Println("You can use this archive with GitHub's repository importer.")
+		fmt.Printf("\n%s\n%s\n", i18n.T(i18n.ExportSuccessful), i18n.T(i18n.ArchiveCreated, outputPath))
+		fmt.Println(i18n.T(i18n.ArchiveUsage))
 	} else {
-		fmt.Printf("\nExport successful!\nOutput directory: %s\n", outputPath)
+		fmt.Printf("\n%s\n%s\n", i18n.T(i18n.ExportSuccessful), i18n.T(i18n.OutputDirectory, outputPath))
 	}
 }
 
@@ -414,7 +489,7 @@ func HashString(s string) string {
 }
 
 func ToUnixPath(path string) string {
-	return strings.ReplaceAll(path, "\\", "/")
+	return format.UnixPath(path)
 }
 
 func NormalizePath(path string) string {
@@ -422,10 +497,7 @@ func NormalizePath(path string) string {
 }
 
 func ToNativePath(path string) string {
-	if runtime.GOOS == "windows" {
-		return strings.ReplaceAll(path, "/", "\\")
-	}
-	return path
+	return format.NativePath(path)
 }
 
 func ExecuteCommand(command string, args []string, workingDir string, skipSSLVerify bool) ([]byte, error) {
@@ -470,10 +542,10 @@ func validateGitReference(reference string) error {
 		return fmt.Errorf("empty reference")
 	}
 
-	// Check if the reference is exactly 40 hex characters (SHA-1 format)
+	// Check if the reference is exactly 40 (SHA-1) or 64 (SHA-256) hex characters
 	// This is ambiguous because Git can't determine if it's a branch name or commit SHA
 	if hexPatternRegex.MatchString(reference) {
-		return fmt.Errorf("ambiguous git reference: %s (exactly 40 hex characters)", reference)
+		return fmt.Errorf("ambiguous git reference: %s (exactly %d hex characters)", reference, len(reference))
 	}
 
 	// Check for other invalid characters in branch names
@@ -15,8 +15,10 @@ import (
 	"time"
 
 	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest/observer"
 )
@@ -115,6 +117,25 @@ func TestValidateExportFlags(t *testing.T) {
 	assert.Error(t, err, "Expected error when multiple authentication methods are provided")
 	assert.Contains(t, err.Error(), "mixed authentication methods")
 
+	// Test case 9d: OAuth consumer key and secret provided
+	cmdFlags = &data.CmdExportFlags{}
+	cmdFlags.BitbucketOAuthKey = "testkey"
+	cmdFlags.BitbucketOAuthSecret = "testsecret"
+	err = ValidateExportFlags(cmdFlags)
+	assert.NoError(t, err, "Expected no error when OAuth key and secret are provided")
+
+	// Test case 9e: Mixed authentication methods - access token with OAuth consumer
+	cmdFlags.BitbucketAccessToken = "testtoken"
+	err = ValidateExportFlags(cmdFlags)
+	assert.Error(t, err, "Expected error when multiple authentication methods are provided")
+	assert.Contains(t, err.Error(), "mixed authentication methods")
+
+	// Test case 9f: OAuth key next to another method, missing its secret
+	cmdFlags.BitbucketOAuthSecret = ""
+	err = ValidateExportFlags(cmdFlags)
+	assert.Error(t, err, "Expected error when the OAuth secret is missing")
+	assert.Contains(t, err.Error(), "OAuth consumer secret is required")
+
 	// Test case 10: Valid date format for PRsFromDate
 	cmdFlags = &data.CmdExportFlags{}
 	cmdFlags.BitbucketAccessToken = "testtoken"
@@ -129,6 +150,30 @@ func TestValidateExportFlags(t *testing.T) {
 	err = ValidateExportFlags(cmdFlags)
 	assert.Error(t, err, "Expected error with invalid date format")
 	assert.Contains(t, err.Error(), "invalid date format for --prs-from-date", "Error should mention invalid date format")
+
+	// Test case 12: Invalid date format for ColdStorageBefore
+	cmdFlags = &data.CmdExportFlags{}
+	cmdFlags.BitbucketAccessToken = "testtoken"
+	cmdFlags.ColdStorageBefore = "2023/01/01"
+	err = ValidateExportFlags(cmdFlags)
+	assert.Error(t, err, "Expected error with invalid cold storage date format")
+	assert.Contains(t, err.Error(), "invalid date format for --cold-storage-before")
+
+	// Test case 13: Empty path pattern for PRsTouchingPaths
+	cmdFlags = &data.CmdExportFlags{}
+	cmdFlags.BitbucketAccessToken = "testtoken"
+	cmdFlags.PRsTouchingPaths = []string{"src/service-a/**", " "}
+	err = ValidateExportFlags(cmdFlags)
+	assert.Error(t, err, "Expected error with empty path pattern")
+	assert.Contains(t, err.Error(), "invalid value for --prs-touching-path")
+
+	// Test case 14: Unsupported archive encryption method
+	cmdFlags = &data.CmdExportFlags{}
+	cmdFlags.BitbucketAccessToken = "testtoken"
+	cmdFlags.Encrypt = "zip:secret"
+	err = ValidateExportFlags(cmdFlags)
+	assert.Error(t, err, "Expected error with unsupported encryption method")
+	assert.Contains(t, err.Error(), "invalid value for --encrypt")
 }
 
 func TestSetupEnvironmentCredentials(t *testing.T) {
@@ -434,11 +479,11 @@ func TestFormatURL(t *testing.T) {
 			// Pass the arguments based on the test case
 			switch len(tc.id) {
 			case 0:
-				result = formatURL(tc.urlType, tc.workspace, tc.repository)
+				result = format.URL(tc.urlType, tc.workspace, tc.repository)
 			case 1:
-				result = formatURL(tc.urlType, tc.workspace, tc.repository, tc.id[0])
+				result = format.URL(tc.urlType, tc.workspace, tc.repository, tc.id[0])
 			case 2:
-				result = formatURL(tc.urlType, tc.workspace, tc.repository, tc.id[0], tc.id[1])
+				result = format.URL(tc.urlType, tc.workspace, tc.repository, tc.id[0], tc.id[1])
 			default:
 				t.Fatalf("Unsupported number of ID parameters: %d", len(tc.id))
 			}
@@ -558,7 +603,7 @@ func TestExtractPRNumber(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := extractPRNumber(tc.prURL)
+			result := extractPRNumber(format.URLs{}, tc.prURL)
 			assert.Equal(t, tc.expected, result, "For URL %s, expected PR number %s but got %s", tc.prURL, tc.expected, result)
 		})
 	}
@@ -849,11 +894,11 @@ func TestUpdateRepositoryFieldPreservesWikiURLNull(t *testing.T) {
 			WikiURL:       nil,
 		},
 	}
-	err = exporter.writeJSONFile("repositories_000001.json", initial)
-	assert.NoError(t, err)
+	exporter.repositories = initial
 
 	exporter.updateRepositoryField("r", "default_branch", "develop")
 	exporter.updateRepositoryField("r", "git_url", "tarball://root/repositories/ws/r.git")
+	assert.NoError(t, exporter.writeRepositoriesFile())
 
 	b, err := os.ReadFile(filepath.Join(tempDir, "repositories_000001.json"))
 	assert.NoError(t, err)
@@ -903,11 +948,11 @@ func TestUpdateRepositoryFieldCaseInsensitive(t *testing.T) {
 			GitURL:        "",
 		},
 	}
-	err = exporter.writeJSONFile("repositories_000001.json", repos)
-	assert.NoError(t, err)
+	exporter.repositories = repos
 
 	// Test case 1: Update using the capitalized name
 	exporter.updateRepositoryField("RepositoryUIName", "default_branch", "develop")
+	assert.NoError(t, exporter.writeRepositoriesFile())
 
 	// Read back and verify
 	b, err := os.ReadFile(filepath.Join(tempDir, "repositories_000001.json"))
@@ -921,6 +966,7 @@ func TestUpdateRepositoryFieldCaseInsensitive(t *testing.T) {
 
 	// Test case 2: Update using the lowercase slug
 	exporter.updateRepositoryField("repositoryuiname", "git_url", "tarball://root/repositories/workspace/RepositoryUIName.git")
+	assert.NoError(t, exporter.writeRepositoriesFile())
 
 	// Read back and verify
 	b, err = os.ReadFile(filepath.Join(tempDir, "repositories_000001.json"))
@@ -1271,6 +1317,12 @@ func TestValidateGitReference(t *testing.T) {
 			expectError: true,
 			errorMsg:    "ambiguous git reference",
 		},
+		{
+			name:        "Exactly 64 hex characters (ambiguous SHA-256)",
+			reference:   "1234567890abcdef1234567890abcdef1234567890abcdef1234567890abcdef",
+			expectError: true,
+			errorMsg:    "exactly 64 hex characters",
+		},
 		{
 			name:        "39 hex characters (valid)",
 			reference:   "1234567890abcdef1234567890abcdef1234567",
@@ -1412,8 +1464,8 @@ func TestValidateExportDataIntegration(t *testing.T) {
 			Description: "Line 1\nLine 2\r\nLine 3",
 		},
 	}
-	err = exporter.writeJSONFile("repositories_000001.json", repos)
-	assert.NoError(t, err)
+	exporter.repositories = repos
+	assert.NoError(t, exporter.writeRepositoriesFile())
 
 	// Test Case 2: Pull requests - ambiguous refs should have been filtered during fetch
 	// So we'll only include valid PRs
@@ -2089,10 +2141,10 @@ func TestSetupCommandUsageTemplateWithStringSliceFlags(t *testing.T) {
 
 func TestFormatURLWithIntegerID(t *testing.T) {
 	// Test formatURL with integer IDs
-	result := formatURL("pr", "workspace", "repo", 123)
+	result := format.URL("pr", "workspace", "repo", 123)
 	assert.Equal(t, "https://bitbucket.org/workspace/repo/pull/123", result)
 
-	result = formatURL("pr_review", "workspace", "repo", 123, 456)
+	result = format.URL("pr_review", "workspace", "repo", 123, 456)
 	assert.Equal(t, "https://bitbucket.org/workspace/repo/pull/123/files#pullrequestreview-456", result)
 }
 
@@ -2144,11 +2196,11 @@ func TestFormatURLEdgeCases(t *testing.T) {
 			var result string
 			switch len(tc.ids) {
 			case 0:
-				result = formatURL(tc.urlType, tc.workspace, tc.repository)
+				result = format.URL(tc.urlType, tc.workspace, tc.repository)
 			case 1:
-				result = formatURL(tc.urlType, tc.workspace, tc.repository, tc.ids[0])
+				result = format.URL(tc.urlType, tc.workspace, tc.repository, tc.ids[0])
 			case 2:
-				result = formatURL(tc.urlType, tc.workspace, tc.repository, tc.ids[0], tc.ids[1])
+				result = format.URL(tc.urlType, tc.workspace, tc.repository, tc.ids[0], tc.ids[1])
 			}
 			assert.Equal(t, tc.expected, result)
 		})
@@ -2344,7 +2396,7 @@ func TestExtractPRNumberVariousFormats(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := extractPRNumber(tc.url)
+			result := extractPRNumber(format.URLs{}, tc.url)
 			assert.Equal(t, tc.expected, result)
 		})
 	}
@@ -2380,6 +2432,35 @@ func TestValidateGitReferenceMoreCases(t *testing.T) {
 	}
 }
 
+func TestWriteJSONFileCompact(t *testing.T) {
+	tempDir := t.TempDir()
+	exporter := NewExporter(&Client{}, tempDir, zap.NewNop(), false, "")
+	exporter.SetCompactJSON(true)
+
+	records := []map[string]interface{}{{"login": "user", "type": "user"}}
+	require.NoError(t, exporter.writeJSONFile("users_000001.json", records))
+	require.NoError(t, exporter.writeJSONFile(exportReportFile, records))
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, coldStorageDir), 0755))
+	require.NoError(t, exporter.writeJSONFile(coldStorageDir+"/pull_requests.json", records))
+
+	content, err := os.ReadFile(filepath.Join(tempDir, "users_000001.json"))
+	require.NoError(t, err)
+	assert.Equal(t, `[{"login":"user","type":"user"}]`+"\n", string(content))
+
+	// Files kept outside the archive stay readable
+	for _, name := range []string{exportReportFile, filepath.Join(coldStorageDir, "pull_requests.json")} {
+		content, err := os.ReadFile(filepath.Join(tempDir, name))
+		require.NoError(t, err)
+		assert.Contains(t, string(content), "\n  {", name)
+	}
+
+	exporter.SetCompactJSON(false)
+	require.NoError(t, exporter.writeJSONFile("users_000001.json", records))
+	content, err = os.ReadFile(filepath.Join(tempDir, "users_000001.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "\n  {")
+}
+
 func TestWriteJSONFileWithNestedData(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "write-json-nested-")
 	assert.NoError(t, err)
@@ -2415,41 +2496,17 @@ func TestWriteJSONFileWithNestedData(t *testing.T) {
 	assert.Equal(t, true, readData["bool"])
 }
 
-func TestUpdateRepositoryFieldNonExistentFile(t *testing.T) {
-	tempDir, err := os.MkdirTemp("", "update-repo-nonexistent-")
-	assert.NoError(t, err)
-	defer func() { _ = os.RemoveAll(tempDir) }()
-
+func TestUpdateRepositoryFieldNoRecords(t *testing.T) {
 	core, observedLogs := observer.New(zap.WarnLevel)
-	logger := zap.New(core)
-	exporter := NewExporter(&Client{}, tempDir, logger, false, "")
+	exporter := NewExporter(&Client{}, t.TempDir(), zap.New(core), false, "")
 
-	// Try to update without creating the file first
+	// Updating before any repository record exists only logs a warning
 	exporter.updateRepositoryField("test-repo", "default_branch", "main")
 
-	// Should log a warning
 	logs := observedLogs.All()
-	assert.GreaterOrEqual(t, len(logs), 1)
-}
-
-func TestUpdateRepositoryFieldInvalidJSON(t *testing.T) {
-	tempDir, err := os.MkdirTemp("", "update-repo-invalid-json-")
-	assert.NoError(t, err)
-	defer func() { _ = os.RemoveAll(tempDir) }()
-
-	// Write invalid JSON
-	err = os.WriteFile(filepath.Join(tempDir, "repositories_000001.json"), []byte("not valid json"), 0644)
-	assert.NoError(t, err)
-
-	core, observedLogs := observer.New(zap.WarnLevel)
-	logger := zap.New(core)
-	exporter := NewExporter(&Client{}, tempDir, logger, false, "")
-
-	exporter.updateRepositoryField("test-repo", "default_branch", "main")
-
-	// Should log a warning about parsing
-	logs := observedLogs.All()
-	assert.GreaterOrEqual(t, len(logs), 1)
+	require.Len(t, logs, 1)
+	assert.Contains(t, logs[0].Message, "Repository not found")
+	assert.Empty(t, exporter.repositories)
 }
 
 func TestCreateRepositoryInfoFilesWithSpecialChars(t *testing.T) {
@@ -2583,7 +2640,7 @@ func TestExtractPRNumberWithPullRequestsPath(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			result := extractPRNumber(tc.url)
+			result := extractPRNumber(format.URLs{}, tc.url)
 			assert.Equal(t, tc.expected, result)
 		})
 	}
@@ -2707,11 +2764,11 @@ func TestFormatURLAllTypes(t *testing.T) {
 			var result string
 			switch len(tc.ids) {
 			case 0:
-				result = formatURL(tc.urlType, tc.ws, tc.repo)
+				result = format.URL(tc.urlType, tc.ws, tc.repo)
 			case 1:
-				result = formatURL(tc.urlType, tc.ws, tc.repo, tc.ids[0])
+				result = format.URL(tc.urlType, tc.ws, tc.repo, tc.ids[0])
 			case 2:
-				result = formatURL(tc.urlType, tc.ws, tc.repo, tc.ids[0], tc.ids[1])
+				result = format.URL(tc.urlType, tc.ws, tc.repo, tc.ids[0], tc.ids[1])
 			}
 			assert.Equal(t, tc.expected, result)
 		})
@@ -3263,3 +3320,61 @@ func TestGetGitHubAuthTokenWithTargetAPIURL(t *testing.T) {
 		}
 	})
 }
+
+func TestIsFullCommitSHA(t *testing.T) {
+	assert.True(t, isFullCommitSHA(strings.Repeat("a", 40)))
+	assert.True(t, isFullCommitSHA(strings.Repeat("b", 64)))
+	assert.False(t, isFullCommitSHA(strings.Repeat("c", 41)))
+	assert.False(t, isFullCommitSHA("abc1234"))
+	assert.False(t, isFullCommitSHA(strings.Repeat("z", 40)))
+}
+
+func TestGetRepositoryObjectFormat(t *testing.T) {
+	for _, format := range []string{objectFormatSHA1, objectFormatSHA256} {
+		t.Run(format, func(t *testing.T) {
+			repoPath := filepath.Join(t.TempDir(), "repo.git")
+			cmd := exec.Command("git", "init", "--bare", "--object-format="+format, repoPath)
+			if err := cmd.Run(); err != nil {
+				t.Skipf("git does not support %s repositories: %v", format, err)
+			}
+
+			detected, err := GetRepositoryObjectFormat(repoPath)
+			require.NoError(t, err)
+			assert.Equal(t, format, detected)
+		})
+	}
+
+	_, err := GetRepositoryObjectFormat(t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestGetFullCommitSHAFromLocalRepoSHA256(t *testing.T) {
+	repoPath := filepath.Join(t.TempDir(), "repo")
+	if err := exec.Command("git", "init", "--object-format=sha256", repoPath).Run(); err != nil {
+		t.Skip("git does not support SHA-256 repositories")
+	}
+
+	cmd := exec.Command("git", "-c", "user.name=test", "-c", "user.email=test@example.com",
+		"commit", "--allow-empty", "-m", "initial")
+	cmd.Dir = repoPath
+	require.NoError(t, cmd.Run())
+
+	cmd = exec.Command("git", "rev-parse", "HEAD")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	fullSHA := strings.TrimSpace(string(output))
+	require.Len(t, fullSHA, 64)
+
+	resolved, err := GetFullCommitSHAFromLocalRepo(repoPath, fullSHA[:12])
+	require.NoError(t, err)
+	assert.Equal(t, fullSHA, resolved)
+
+	resolved, err = GetFullCommitSHAFromLocalRepo(repoPath, fullSHA)
+	require.NoError(t, err)
+	assert.Equal(t, fullSHA, resolved)
+
+	resolved, err = GetFullCommitSHAFromLocalRepo(repoPath, strings.ToUpper(fullSHA))
+	require.NoError(t, err)
+	assert.Equal(t, fullSHA, resolved, "upper-case SHAs are lowercased")
+}
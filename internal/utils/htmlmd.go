@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlTagPattern       = regexp.MustCompile(`<(/?)([a-zA-Z][a-zA-Z0-9]*)([^>]*?)(/?)>`)
+	htmlAttrPattern      = regexp.MustCompile(`([a-zA-Z_:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	htmlWhitespace       = regexp.MustCompile(`\s+`)
+	markdownBlankLines   = regexp.MustCompile(`\n{3,}`)
+	markdownTrailingTabs = regexp.MustCompile(`[ \t]+\n`)
+)
+
+var htmlVoidElements = map[string]bool{
+	"br": true, "hr": true, "img": true, "input": true, "meta": true, "link": true,
+}
+
+// htmlNode is a minimal element tree; text nodes have an empty tag.
+type htmlNode struct {
+	tag      string
+	attrs    map[string]string
+	text     string
+	children []*htmlNode
+}
+
+func parseHTMLFragment(fragment string) *htmlNode {
+	root := &htmlNode{tag: "#root"}
+	stack := []*htmlNode{root}
+	top := func() *htmlNode { return stack[len(stack)-1] }
+
+	addText := func(text string) {
+		if text != "" {
+			top().children = append(top().children, &htmlNode{text: html.UnescapeString(text)})
+		}
+	}
+
+	pos := 0
+	for _, m := range htmlTagPattern.FindAllStringSubmatchIndex(fragment, -1) {
+		addText(fragment[pos:m[0]])
+		pos = m[1]
+
+		closing := fragment[m[2]:m[3]] == "/"
+		tag := strings.ToLower(fragment[m[4]:m[5]])
+		selfClosing := fragment[m[8]:m[9]] == "/"
+
+		if closing {
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].tag == tag {
+					stack = stack[:i]
+					break
+				}
+			}
+			continue
+		}
+
+		node := &htmlNode{tag: tag, attrs: map[string]string{}}
+		for _, attr := range htmlAttrPattern.FindAllStringSubmatch(fragment[m[6]:m[7]], -1) {
+			value := attr[2]
+			if value == "" {
+				value = attr[3]
+			}
+			node.attrs[strings.ToLower(attr[1])] = html.UnescapeString(value)
+		}
+		top().children = append(top().children, node)
+		if !selfClosing && !htmlVoidElements[tag] {
+			stack = append(stack, node)
+		}
+	}
+	addText(fragment[pos:])
+
+	return root
+}
+
+// htmlToMarkdown converts the rendered HTML Bitbucket returns for comments
+// into GitHub-flavoured Markdown. Only the elements Bitbucket's renderer emits
+// are handled; unknown elements contribute their text content.
+func htmlToMarkdown(fragment string) string {
+	markdown := renderMarkdownChildren(parseHTMLFragment(fragment), false)
+	markdown = markdownTrailingTabs.ReplaceAllString(markdown, "\n")
+	markdown = markdownBlankLines.ReplaceAllString(markdown, "\n\n")
+	return strings.TrimSpace(markdown)
+}
+
+func renderMarkdownChildren(node *htmlNode, preformatted bool) string {
+	var sb strings.Builder
+	for _, child := range node.children {
+		sb.WriteString(renderMarkdown(child, preformatted))
+	}
+	return sb.String()
+}
+
+func htmlNodeText(node *htmlNode) string {
+	if node.tag == "" {
+		return node.text
+	}
+	var sb strings.Builder
+	for _, child := range node.children {
+		sb.WriteString(htmlNodeText(child))
+	}
+	return sb.String()
+}
+
+func renderMarkdown(node *htmlNode, preformatted bool) string {
+	if node.tag == "" {
+		if preformatted {
+			return node.text
+		}
+		return htmlWhitespace.ReplaceAllString(node.text, " ")
+	}
+
+	inner := func() string { return renderMarkdownChildren(node, preformatted) }
+
+	switch node.tag {
+	case "p", "div":
+		return "\n\n" + strings.TrimSpace(inner()) + "\n\n"
+	case "br":
+		return "\n"
+	case "hr":
+		return "\n\n---\n\n"
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(node.tag[1] - '0')
+		return "\n\n" + strings.Repeat("#", level) + " " + strings.TrimSpace(inner()) + "\n\n"
+	case "strong", "b":
+		return wrapMarkdown(inner(), "**")
+	case "em", "i":
+		return wrapMarkdown(inner(), "_")
+	case "del", "s", "strike":
+		return wrapMarkdown(inner(), "~~")
+	case "code":
+		if preformatted {
+			return htmlNodeText(node)
+		}
+		return "`" + htmlNodeText(node) + "`"
+	case "pre":
+		code := strings.TrimRight(htmlNodeText(node), "\n")
+		return "\n\n```" + codeLanguage(node) + "\n" + code + "\n```\n\n"
+	case "a":
+		text := strings.TrimSpace(inner())
+		href := node.attrs["href"]
+		if href == "" || href == text {
+			return text
+		}
+		return fmt.Sprintf("[%s](%s)", text, href)
+	case "img":
+		return fmt.Sprintf("![%s](%s)", node.attrs["alt"], node.attrs["src"])
+	case "ul", "ol":
+		return "\n\n" + renderMarkdownList(node) + "\n\n"
+	case "blockquote":
+		quoted := strings.TrimSpace(markdownBlankLines.ReplaceAllString(inner(), "\n\n"))
+		lines := strings.Split(quoted, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		return "\n\n" + strings.Join(lines, "\n") + "\n\n"
+	case "script", "style":
+		return ""
+	default:
+		return inner()
+	}
+}
+
+func wrapMarkdown(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	return marker + trimmed + marker
+}
+
+func codeLanguage(pre *htmlNode) string {
+	for _, node := range append([]*htmlNode{pre}, pre.children...) {
+		for _, class := range strings.Fields(node.attrs["class"]) {
+			if lang, ok := strings.CutPrefix(class, "language-"); ok {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+func renderMarkdownList(list *htmlNode) string {
+	var items []string
+	index := 1
+	for _, child := range list.children {
+		if child.tag != "li" {
+			continue
+		}
+		marker := "- "
+		if list.tag == "ol" {
+			marker = fmt.Sprintf("%d. ", index)
+			index++
+		}
+
+		body := strings.TrimSpace(markdownBlankLines.ReplaceAllString(renderMarkdownChildren(child, false), "\n\n"))
+		body = strings.ReplaceAll(body, "\n\n", "\n")
+		lines := strings.Split(body, "\n")
+		indent := strings.Repeat(" ", len(marker))
+		for i := 1; i < len(lines); i++ {
+			if lines[i] != "" {
+				lines[i] = indent + lines[i]
+			}
+		}
+		items = append(items, marker+strings.Join(lines, "\n"))
+	}
+	return strings.Join(items, "\n")
+}
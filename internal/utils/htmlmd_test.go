@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		html     string
+		expected string
+	}{
+		{
+			name:     "paragraphs and inline formatting",
+			html:     "<p>Hello <strong>bold</strong> and <em>italic</em> with <code>x := 1</code></p>\n<p>Second &amp; last</p>",
+			expected: "Hello **bold** and _italic_ with `x := 1`\n\nSecond & last",
+		},
+		{
+			name:     "links and images",
+			html:     `<p>See <a href="https://example.com/docs" rel="nofollow">the docs</a> and <img src="https://example.com/a.png" alt="diagram" /></p>`,
+			expected: "See [the docs](https://example.com/docs) and ![diagram](https://example.com/a.png)",
+		},
+		{
+			name:     "link text equal to href",
+			html:     `<a href="https://example.com">https://example.com</a>`,
+			expected: "https://example.com",
+		},
+		{
+			name:     "heading and line break",
+			html:     "<h2>Summary</h2><p>line one<br>line two</p>",
+			expected: "## Summary\n\nline one\nline two",
+		},
+		{
+			name:     "code block keeps whitespace",
+			html:     "<div class=\"codehilite language-go\"><pre><span></span>func main() {\n    fmt.Println(&quot;hi&quot;)\n}\n</pre></div>",
+			expected: "```\nfunc main() {\n    fmt.Println(\"hi\")\n}\n```",
+		},
+		{
+			name:     "code block language",
+			html:     "<pre><code class=\"language-python\">print(1)\n</code></pre>",
+			expected: "```python\nprint(1)\n```",
+		},
+		{
+			name:     "nested lists",
+			html:     "<ul><li>one</li><li>two<ol><li>a</li><li>b</li></ol></li></ul>",
+			expected: "- one\n- two\n  1. a\n  2. b",
+		},
+		{
+			name:     "blockquote",
+			html:     "<blockquote><p>quoted text</p></blockquote><p>reply</p>",
+			expected: "> quoted text\n\nreply",
+		},
+		{
+			name:     "mention span and unknown tags",
+			html:     `<p><span class="ap-mention">@alice</span> please review</p>`,
+			expected: "@alice please review",
+		},
+		{
+			name:     "plain text",
+			html:     "just text",
+			expected: "just text",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, htmlToMarkdown(tt.html))
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const importSafetyReportFile = "import-safety-report.json"
+
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+func (e *Exporter) SetFailOnUnsafePaths(fail bool) {
+	e.failOnUnsafePaths = fail
+}
+
+// unsafePathReason returns why a tree path would be rejected or mangled by
+// the GitHub importer, or an empty string if the path is safe.
+func unsafePathReason(path string) string {
+	for _, component := range strings.Split(path, "/") {
+		if strings.EqualFold(component, ".git") {
+			return "contains a .git path component"
+		}
+
+		for _, r := range component {
+			if r < 0x20 {
+				return "contains a control character"
+			}
+			if strings.ContainsRune(`<>:"|?*\`, r) {
+				return fmt.Sprintf("contains NTFS-invalid character %q", r)
+			}
+		}
+
+		if strings.HasSuffix(component, ".") || strings.HasSuffix(component, " ") {
+			return "path component ends with a dot or space"
+		}
+
+		base := component
+		if idx := strings.Index(base, "."); idx >= 0 {
+			base = base[:idx]
+		}
+		if windowsReservedNames[strings.ToUpper(base)] {
+			return fmt.Sprintf("uses reserved Windows device name %s", strings.ToUpper(base))
+		}
+	}
+
+	return ""
+}
+
+// scanUnsafePaths lists every path introduced by any commit reachable from
+// the repository refs and returns the ones that are unsafe to import.
+func scanUnsafePaths(repoPath, repository string) ([]data.UnsafePath, error) {
+	cmd := exec.Command("git", "log", "--all", "--format=", "--name-only", "--no-renames", "-z")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list repository paths: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	unsafe := []data.UnsafePath{}
+	for _, path := range strings.Split(string(output), "\x00") {
+		path = strings.Trim(path, "\n")
+		if path == "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if reason := unsafePathReason(path); reason != "" {
+			unsafe = append(unsafe, data.UnsafePath{
+				Repository: repository,
+				Path:       path,
+				Reason:     reason,
+			})
+		}
+	}
+
+	sort.Slice(unsafe, func(i, j int) bool { return unsafe[i].Path < unsafe[j].Path })
+	return unsafe, nil
+}
+
+// checkImportSafety scans a cloned repository for paths GitHub rejects and
+// records them for the import-safety report.
+func (e *Exporter) checkImportSafety(workspace, repoSlug, repoPath string) error {
+	repository := fmt.Sprintf("%s/%s", workspace, repoSlug)
+	unsafe, err := scanUnsafePaths(repoPath, repository)
+	if err != nil {
+		e.logger.Warn("Failed to scan repository for unsafe paths",
+			zap.String("repository", repository),
+			zap.Error(err))
+		return nil
+	}
+
+	// A repository is scanned again after a top-up fetch; only report
+	// paths that were not found before.
+	unsafe = slices.DeleteFunc(unsafe, func(path data.UnsafePath) bool {
+		return slices.Contains(e.unsafePaths, path)
+	})
+	if len(unsafe) == 0 {
+		e.logger.Debug("No unsafe paths found", zap.String("repository", repository))
+		return nil
+	}
+
+	for _, path := range unsafe {
+		e.logger.Warn("Found path that may be rejected by GitHub import",
+			zap.String("repository", repository),
+			zap.String("path", path.Path),
+			zap.String("reason", path.Reason))
+	}
+	e.unsafePaths = append(e.unsafePaths, unsafe...)
+
+	if e.failOnUnsafePaths {
+		return fmt.Errorf("repository %s contains %d unsafe path(s); rewrite history to remove them or rerun without --fail-on-unsafe-paths",
+			repository, len(unsafe))
+	}
+
+	return nil
+}
+
+func (e *Exporter) writeImportSafetyReport() error {
+	if len(e.unsafePaths) == 0 {
+		return nil
+	}
+
+	report := data.ImportSafetyReport{UnsafePaths: e.unsafePaths}
+	if err := e.writeJSONFile(importSafetyReportFile, report); err != nil {
+		return err
+	}
+
+	e.logger.Warn("Wrote import-safety report (excluded from import archive)",
+		zap.String("file", importSafetyReportFile),
+		zap.Int("unsafe_paths", len(e.unsafePaths)))
+	return nil
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestUnsafePathReason(t *testing.T) {
+	testCases := []struct {
+		path   string
+		unsafe bool
+	}{
+		{path: "src/main.go", unsafe: false},
+		{path: "docs/readme.md", unsafe: false},
+		{path: "vendor/lib/.git", unsafe: true},
+		{path: "vendor/lib/.GIT/config", unsafe: true},
+		{path: "notes/what?.txt", unsafe: true},
+		{path: "a:b", unsafe: true},
+		{path: "windows/CON", unsafe: true},
+		{path: "windows/aux.txt", unsafe: true},
+		{path: "console.log", unsafe: false},
+		{path: "trailing./file", unsafe: true},
+		{path: "trailing /file", unsafe: true},
+		{path: ".gitignore", unsafe: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			reason := unsafePathReason(tc.path)
+			if tc.unsafe {
+				assert.NotEmpty(t, reason)
+			} else {
+				assert.Empty(t, reason)
+			}
+		})
+	}
+}
+
+func createRepoWithFiles(t *testing.T, files ...string) string {
+	t.Helper()
+	repoPath := filepath.Join(t.TempDir(), "repo")
+	if err := exec.Command("git", "init", repoPath).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+
+	for _, file := range files {
+		fullPath := filepath.Join(repoPath, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte("content"), 0644))
+	}
+
+	for _, args := range [][]string{
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoPath
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+	}
+
+	return repoPath
+}
+
+func TestScanUnsafePaths(t *testing.T) {
+	repoPath := createRepoWithFiles(t, "src/main.go", "docs/what?.md", "windows/nul.txt")
+
+	unsafe, err := scanUnsafePaths(repoPath, "workspace/repo")
+	require.NoError(t, err)
+	require.Len(t, unsafe, 2)
+	assert.Equal(t, "docs/what?.md", unsafe[0].Path)
+	assert.Equal(t, "windows/nul.txt", unsafe[1].Path)
+	assert.Equal(t, "workspace/repo", unsafe[0].Repository)
+}
+
+func TestCheckImportSafety(t *testing.T) {
+	repoPath := createRepoWithFiles(t, "bad|name.txt")
+	logger, _ := zap.NewDevelopment()
+
+	t.Run("Report only", func(t *testing.T) {
+		outputDir := t.TempDir()
+		exporter := NewExporter(&Client{logger: logger}, outputDir, logger, false, "")
+
+		err := exporter.checkImportSafety("workspace", "repo", repoPath)
+		assert.NoError(t, err)
+		require.Len(t, exporter.unsafePaths, 1)
+
+		require.NoError(t, exporter.writeImportSafetyReport())
+		assert.FileExists(t, filepath.Join(outputDir, importSafetyReportFile))
+	})
+
+	t.Run("Fail on unsafe paths", func(t *testing.T) {
+		exporter := NewExporter(&Client{logger: logger}, t.TempDir(), logger, false, "")
+		exporter.SetFailOnUnsafePaths(true)
+
+		err := exporter.checkImportSafety("workspace", "repo", repoPath)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "1 unsafe path(s)")
+	})
+
+	t.Run("No report when clean", func(t *testing.T) {
+		outputDir := t.TempDir()
+		exporter := NewExporter(&Client{logger: logger}, outputDir, logger, false, "")
+
+		require.NoError(t, exporter.writeImportSafetyReport())
+		assert.NoFileExists(t, filepath.Join(outputDir, importSafetyReportFile))
+	})
+}
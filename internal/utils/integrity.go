@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	usersFile               = "users_000001.json"
+	organizationsFile       = "organizations_000001.json"
+	repositoriesFile        = "repositories_000001.json"
+	pullRequestsFile        = "pull_requests_000001.json"
+	issueCommentsFile       = "issue_comments_000001.json"
+	reviewCommentsFile      = "pull_request_review_comments_000001.json"
+	reviewThreadsFile       = "pull_request_review_threads_000001.json"
+	reviewsFile             = "pull_request_reviews_000001.json"
+	maxLoggedIntegrityIssue = 20
+)
+
+// integrityRule states that a field of every record in a file must match the
+// url of a record in one of the target files.
+type integrityRule struct {
+	file    string
+	field   string // dot-separated path, e.g. "base.repo"
+	targets []string
+}
+
+var integrityRules = []integrityRule{
+	{repositoriesFile, "owner", []string{usersFile, organizationsFile}},
+	{pullRequestsFile, "user", []string{usersFile}},
+	{pullRequestsFile, "repository", []string{repositoriesFile}},
+	{pullRequestsFile, "base.repo", []string{repositoriesFile}},
+	{pullRequestsFile, "head.repo", []string{repositoriesFile}},
+	{pullRequestsFile, "base.user", []string{usersFile, organizationsFile}},
+	{pullRequestsFile, "head.user", []string{usersFile, organizationsFile}},
+	{issueCommentsFile, "user", []string{usersFile}},
+	{issueCommentsFile, "pull_request", []string{pullRequestsFile}},
+	{reviewCommentsFile, "user", []string{usersFile}},
+	{reviewCommentsFile, "pull_request", []string{pullRequestsFile}},
+	{reviewCommentsFile, "pull_request_review", []string{reviewsFile}},
+	{reviewCommentsFile, "pull_request_review_thread", []string{reviewThreadsFile}},
+	{reviewThreadsFile, "pull_request", []string{pullRequestsFile}},
+	{reviewThreadsFile, "pull_request_review", []string{reviewsFile}},
+	{reviewsFile, "user", []string{usersFile}},
+	{reviewsFile, "pull_request", []string{pullRequestsFile}},
+}
+
+func (e *Exporter) readExportRecords(fileName string) ([]map[string]interface{}, error) {
+	return readRecordFiles[map[string]interface{}](e.outputDir, fileName)
+}
+
+func recordField(record map[string]interface{}, path string) string {
+	var value interface{} = record
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		value = object[key]
+	}
+	text, _ := value.(string)
+	return text
+}
+
+// checkReferentialIntegrity verifies that every URL reference between the
+// exported JSON files resolves to a record, e.g. that each review comment's
+// review and thread exist and each pull request author is a known user.
+func (e *Exporter) checkReferentialIntegrity() ([]data.IntegrityViolation, error) {
+	records := make(map[string][]map[string]interface{})
+	urls := make(map[string]map[string]bool)
+	for _, rule := range integrityRules {
+		for _, fileName := range append([]string{rule.file}, rule.targets...) {
+			if _, loaded := records[fileName]; loaded {
+				continue
+			}
+			fileRecords, err := e.readExportRecords(fileName)
+			if err != nil {
+				return nil, err
+			}
+			records[fileName] = fileRecords
+			urls[fileName] = make(map[string]bool, len(fileRecords))
+			for _, record := range fileRecords {
+				if url := recordField(record, "url"); url != "" {
+					urls[fileName][url] = true
+				}
+			}
+		}
+	}
+
+	var violations []data.IntegrityViolation
+	for _, rule := range integrityRules {
+		if e.usersScope() == UsersScopeNone && len(rule.targets) == 1 && rule.targets[0] == usersFile {
+			// Authors are deliberately left out of the users file.
+			continue
+		}
+		for _, record := range records[rule.file] {
+			reference := recordField(record, rule.field)
+			if reference == "" {
+				continue
+			}
+			resolved := false
+			for _, target := range rule.targets {
+				if urls[target][reference] {
+					resolved = true
+					break
+				}
+			}
+			if !resolved {
+				violations = append(violations, data.IntegrityViolation{
+					File:      rule.file,
+					Record:    recordField(record, "url"),
+					Field:     rule.field,
+					Reference: reference,
+				})
+			}
+		}
+	}
+
+	return violations, nil
+}
+
+// reportIntegrityViolations runs the referential-integrity check, logs what it
+// finds, and records the violations in the export report. Only a corrupt
+// export file is returned as an error.
+func (e *Exporter) reportIntegrityViolations() error {
+	violations, err := e.checkReferentialIntegrity()
+	if errors.Is(err, ErrCorruptExportFile) {
+		return err
+	}
+	if err != nil {
+		e.logger.Warn("Referential-integrity check could not be completed", zap.Error(err))
+		return nil
+	}
+	e.report.IntegrityViolations = violations
+	if len(violations) == 0 {
+		e.logger.Debug("Referential-integrity check passed")
+		return nil
+	}
+
+	for i, violation := range violations {
+		if i == maxLoggedIntegrityIssue {
+			e.logger.Warn("Further referential-integrity violations omitted from the log; see the export report",
+				zap.Int("omitted", len(violations)-maxLoggedIntegrityIssue))
+			break
+		}
+		e.logger.Warn("Unresolved reference in export data",
+			zap.String("file", violation.File),
+			zap.String("record", violation.Record),
+			zap.String("field", violation.Field),
+			zap.String("reference", violation.Reference))
+	}
+	e.logger.Warn("Referential-integrity check found unresolved references",
+		zap.Int("violations", len(violations)),
+		zap.String("report", e.reportFile()))
+	return nil
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func writeExportFixture(t *testing.T, dir, name string, v interface{}) {
+	t.Helper()
+	content, err := json.Marshal(v)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), content, 0644))
+}
+
+func TestCheckReferentialIntegrity(t *testing.T) {
+	outputDir := t.TempDir()
+	const (
+		userURL   = "https://bitbucket.org/alice"
+		orgURL    = "https://bitbucket.org/ws"
+		repoURL   = "https://bitbucket.org/ws/repo"
+		prURL     = "https://bitbucket.org/ws/repo/pull/1"
+		reviewURL = "https://bitbucket.org/ws/repo/pull/1/files#pullrequestreview-review-10"
+		threadURL = "https://bitbucket.org/ws/repo/pull/1/files#pullrequestreviewthread-thread-a"
+	)
+
+	writeExportFixture(t, outputDir, usersFile, []data.User{{Type: "user", URL: userURL}})
+	writeExportFixture(t, outputDir, organizationsFile, []data.Organization{{Type: "organization", URL: orgURL}})
+	writeExportFixture(t, outputDir, repositoriesFile, []data.Repository{{Type: "repository", URL: repoURL, Owner: orgURL}})
+	writeExportFixture(t, outputDir, pullRequestsFile, []data.PullRequest{
+		{URL: prURL, User: userURL, Repository: repoURL,
+			Base: data.PRBranch{Repo: repoURL, User: orgURL}, Head: data.PRBranch{Repo: repoURL, User: orgURL}},
+		{URL: "https://bitbucket.org/ws/repo/pull/2", User: "https://bitbucket.org/departed", Repository: repoURL},
+	})
+	writeExportFixture(t, outputDir, issueCommentsFile, []data.IssueComment{
+		{URL: prURL + "#issuecomment-5", User: userURL, PullRequest: "https://bitbucket.org/ws/repo/pull/99"},
+	})
+	writeExportFixture(t, outputDir, reviewCommentsFile, []data.PullRequestReviewComment{
+		{URL: prURL + "/files#r11", User: userURL, PullRequest: prURL,
+			PullRequestReview: reviewURL, PullRequestReviewThread: threadURL},
+	})
+	writeExportFixture(t, outputDir, reviewThreadsFile, []map[string]interface{}{
+		{"url": threadURL, "pull_request": prURL, "pull_request_review": "https://bitbucket.org/ws/repo/pull/1/files#pullrequestreview-missing"},
+	})
+	writeExportFixture(t, outputDir, reviewsFile, []map[string]interface{}{
+		{"url": reviewURL, "pull_request": prURL, "user": userURL, "body": nil},
+	})
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	violations, err := exporter.checkReferentialIntegrity()
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []data.IntegrityViolation{
+		{File: pullRequestsFile, Record: "https://bitbucket.org/ws/repo/pull/2", Field: "user", Reference: "https://bitbucket.org/departed"},
+		{File: issueCommentsFile, Record: prURL + "#issuecomment-5", Field: "pull_request", Reference: "https://bitbucket.org/ws/repo/pull/99"},
+		{File: reviewThreadsFile, Record: threadURL, Field: "pull_request_review", Reference: "https://bitbucket.org/ws/repo/pull/1/files#pullrequestreview-missing"},
+	}, violations)
+
+	assert.NoError(t, exporter.reportIntegrityViolations())
+	assert.Len(t, exporter.report.IntegrityViolations, 3)
+}
+
+func TestCheckReferentialIntegrityMissingFiles(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	violations, err := exporter.checkReferentialIntegrity()
+	assert.NoError(t, err)
+	assert.Empty(t, violations)
+}
+
+func TestCheckReferentialIntegrityMalformedFile(t *testing.T) {
+	outputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, pullRequestsFile), []byte("{not json"), 0644))
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	_, err := exporter.checkReferentialIntegrity()
+	assert.ErrorIs(t, err, ErrCorruptExportFile)
+	assert.ErrorContains(t, err, pullRequestsFile)
+	assert.ErrorIs(t, exporter.reportIntegrityViolations(), ErrCorruptExportFile)
+}
+
+func TestRecordField(t *testing.T) {
+	record := map[string]interface{}{
+		"url":  "a",
+		"base": map[string]interface{}{"repo": "b"},
+		"body": nil,
+	}
+	assert.Equal(t, "a", recordField(record, "url"))
+	assert.Equal(t, "b", recordField(record, "base.repo"))
+	assert.Equal(t, "", recordField(record, "body"))
+	assert.Equal(t, "", recordField(record, "url.nested"))
+	assert.Equal(t, "", recordField(record, "missing"))
+}
@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	issueLinksFile = "issue-links.json"
+
+	issueLinksNote = "Bitbucket issues are not part of the migration archive, so these links are not written to the " +
+		"pull requests' close_issue_references; use them to relink pull requests once the issues are migrated"
+
+	issueLinkBranch      = "branch"
+	issueLinkTitle       = "title"
+	issueLinkDescription = "description"
+)
+
+var (
+	// issueBranchPattern matches issue numbers in branch names such as
+	// issue/12-login, bugfix/issue-12 or feature/#12.
+	issueBranchPattern = regexp.MustCompile(`(?i)(?:(?:^|[/_-])(?:issues?|bugs?)[-_/]?#?|(?:^|/)#)(\d+)\b`)
+
+	// issueWordPattern matches "issue 12" and "bug 12" without a #.
+	issueWordPattern = regexp.MustCompile(`(?i)\b(?:issue|bug)\s+(\d+)\b`)
+
+	// pullRequestPrefix matches text before a #12 that makes it a pull
+	// request reference in Bitbucket markup.
+	pullRequestPrefix = regexp.MustCompile(`(?i)\b(?:pull request|pr)\s*$`)
+
+	// closingPrefix matches the Bitbucket keywords that resolve the issue
+	// referenced after them.
+	closingPrefix = regexp.MustCompile(`(?i)\b(?:clos(?:e|es|ed|ing)|fix(?:es|ed|ing)?|resolv(?:e|es|ed|ing))\s+(?:(?:issue|bug)\s+)?$`)
+
+	// issueURLPattern matches the URL of a Bitbucket issue and captures its
+	// workspace, repository and number.
+	issueURLPattern = regexp.MustCompile(`(?i)https?://bitbucket\.org/([^/\s]+)/([^/\s]+)/issues/(\d+)`)
+)
+
+// SetIssueLinks lists the Bitbucket issues each exported pull request
+// references in issue-links.json next to the archive.
+func (e *Exporter) SetIssueLinks(enabled bool) {
+	e.issueLinks = enabled
+}
+
+// issueReferences returns the issues referenced in text and whether each is
+// referenced with a closing keyword. URLs of the issues of other
+// repositories are ignored.
+func issueReferences(text, workspace, repoSlug string) map[int]bool {
+	references := make(map[int]bool)
+	add := func(number string, closes bool) {
+		if issue, err := strconv.Atoi(number); err == nil && issue > 0 {
+			references[issue] = references[issue] || closes
+		}
+	}
+
+	for _, match := range prReferencePattern.FindAllStringSubmatchIndex(text, -1) {
+		before := text[:match[4]-1]
+		if pullRequestPrefix.MatchString(before) {
+			continue
+		}
+		add(text[match[4]:match[5]], closingPrefix.MatchString(before))
+	}
+	for _, match := range issueWordPattern.FindAllStringSubmatchIndex(text, -1) {
+		add(text[match[2]:match[3]], closingPrefix.MatchString(text[:match[0]]))
+	}
+	for _, match := range issueURLPattern.FindAllStringSubmatchIndex(text, -1) {
+		if !strings.EqualFold(text[match[2]:match[3]], workspace) || !strings.EqualFold(text[match[4]:match[5]], repoSlug) {
+			continue
+		}
+		add(text[match[6]:match[7]], closingPrefix.MatchString(text[:match[0]]))
+	}
+	return references
+}
+
+// pullRequestIssueLinks returns the issue links of one pull request.
+func pullRequestIssueLinks(pr data.PullRequest, number int, workspace, repoSlug string) []data.IssueLink {
+	var links []data.IssueLink
+	addLinks := func(source string, references map[int]bool) {
+		issues := make([]int, 0, len(references))
+		for issue := range references {
+			issues = append(issues, issue)
+		}
+		sort.Ints(issues)
+		for _, issue := range issues {
+			links = append(links, data.IssueLink{PullRequest: number, Issue: issue, Source: source, Closes: references[issue]})
+		}
+	}
+
+	branch := make(map[int]bool)
+	for _, match := range issueBranchPattern.FindAllStringSubmatch(pr.Head.Ref, -1) {
+		if issue, err := strconv.Atoi(match[1]); err == nil && issue > 0 {
+			branch[issue] = false
+		}
+	}
+	addLinks(issueLinkBranch, branch)
+	addLinks(issueLinkTitle, issueReferences(pr.Title, workspace, repoSlug))
+	addLinks(issueLinkDescription, issueReferences(pr.Body, workspace, repoSlug))
+	return links
+}
+
+// collectIssueLinks records the issues the pull requests of a repository
+// reference. Repositories without an issue tracker are skipped, as their
+// #12 references cannot be issues.
+func (e *Exporter) collectIssueLinks(workspace, repoSlug string, prs []data.PullRequest, repo *data.BitbucketRepository) {
+	if !e.issueLinks {
+		return
+	}
+	if repo == nil || !repo.HasIssues {
+		e.logger.Debug("Repository has no issue tracker; skipping issue links", zap.String("repository", repoSlug))
+		return
+	}
+
+	entry := data.RepositoryIssueLinks{
+		SourceRepository: fmt.Sprintf("%s/%s", workspace, repoSlug),
+		Links:            []data.IssueLink{},
+	}
+	for _, pr := range prs {
+		number, err := strconv.Atoi(extractPRNumber(e.client.sourceURLs(), pr.URL))
+		if err != nil {
+			continue
+		}
+		entry.Links = append(entry.Links, pullRequestIssueLinks(pr, number, workspace, repoSlug)...)
+	}
+	sort.SliceStable(entry.Links, func(i, j int) bool {
+		return entry.Links[i].PullRequest < entry.Links[j].PullRequest
+	})
+
+	e.report.Counts.IssueLinks += len(entry.Links)
+	e.issueLinkEntries = append(e.issueLinkEntries, entry)
+	e.logger.Debug("Collected pull request issue links",
+		zap.String("repository", entry.SourceRepository),
+		zap.Int("links", len(entry.Links)))
+}
+
+// writeIssueLinks writes the issue links report.
+func (e *Exporter) writeIssueLinks() error {
+	if !e.issueLinks {
+		return nil
+	}
+
+	report := data.IssueLinksReport{Note: issueLinksNote, Repositories: e.issueLinkEntries}
+	if report.Repositories == nil {
+		report.Repositories = []data.RepositoryIssueLinks{}
+	}
+	if err := e.writeJSONFile(issueLinksFile, report); err != nil {
+		return err
+	}
+	e.logger.Info("Wrote pull request issue links (excluded from import archive)",
+		zap.String("file", issueLinksFile),
+		zap.Int("links", e.report.Counts.IssueLinks))
+	return nil
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestIssueReferences(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want map[int]bool
+	}{
+		{"plain reference", "Relates to #12.", map[int]bool{12: false}},
+		{"closing keyword", "Fixes #12 and closes issue #13", map[int]bool{12: true, 13: true}},
+		{"pull request reference", "Follow-up to pull request #7 and PR #8", map[int]bool{}},
+		{"issue word", "See issue 21; resolves bug 22", map[int]bool{21: false, 22: true}},
+		{"issue URL", "Resolves https://bitbucket.org/ws/repo/issues/30/crash", map[int]bool{30: true}},
+		{"other repository URL", "See https://bitbucket.org/ws/other/issues/31", map[int]bool{}},
+		{"issue URL in another case", "See https://Bitbucket.org/WS/Repo/issues/32", map[int]bool{32: false}},
+		{"HTML entity and fragment", "&#39; and https://example.com/page#5", map[int]bool{}},
+		{"referenced twice", "See #4, fixes #4", map[int]bool{4: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, issueReferences(tt.text, "ws", "repo"))
+		})
+	}
+}
+
+func TestPullRequestIssueLinks(t *testing.T) {
+	tests := []struct {
+		branch string
+		want   []int
+	}{
+		{"issue/12-login-page", []int{12}},
+		{"bugfix/issue-13", []int{13}},
+		{"feature/#14-search", []int{14}},
+		{"bug_15", []int{15}},
+		{"release/1.2", nil},
+		{"feature/tissue-16", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.branch, func(t *testing.T) {
+			var issues []int
+			for _, link := range pullRequestIssueLinks(data.PullRequest{Head: data.PRBranch{Ref: tt.branch}}, 1, "ws", "repo") {
+				assert.Equal(t, issueLinkBranch, link.Source)
+				issues = append(issues, link.Issue)
+			}
+			assert.Equal(t, tt.want, issues)
+		})
+	}
+
+	pr := data.PullRequest{
+		Title: "Fix #3: login",
+		Body:  "Closes #3, see #5",
+		Head:  data.PRBranch{Ref: "issue/3-login"},
+	}
+	assert.Equal(t, []data.IssueLink{
+		{PullRequest: 9, Issue: 3, Source: issueLinkBranch},
+		{PullRequest: 9, Issue: 3, Source: issueLinkTitle, Closes: true},
+		{PullRequest: 9, Issue: 3, Source: issueLinkDescription, Closes: true},
+		{PullRequest: 9, Issue: 5, Source: issueLinkDescription},
+	}, pullRequestIssueLinks(pr, 9, "ws", "repo"))
+}
+
+func TestWriteIssueLinks(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	prs := []data.PullRequest{
+		{URL: "https://github.com/ws/repo/pull/2", Title: "Login", Body: "Fixes #1", Head: data.PRBranch{Ref: "feature/login"}},
+		{URL: "https://github.com/ws/repo/pull/1", Title: "Search", Head: data.PRBranch{Ref: "issue/4-search"}},
+	}
+	withIssues := &data.BitbucketRepository{Slug: "repo", HasIssues: true}
+
+	exporter.collectIssueLinks("ws", "repo", prs, withIssues)
+	assert.Empty(t, exporter.issueLinkEntries, "disabled by default")
+
+	exporter.SetIssueLinks(true)
+	exporter.collectIssueLinks("ws", "repo", prs, withIssues)
+	exporter.collectIssueLinks("ws", "no-tracker", prs, &data.BitbucketRepository{Slug: "no-tracker"})
+	require.NoError(t, exporter.writeIssueLinks())
+
+	var report data.IssueLinksReport
+	readReportFile(t, filepath.Join(exporter.outputDir, issueLinksFile), &report)
+	assert.Equal(t, issueLinksNote, report.Note)
+	require.Len(t, report.Repositories, 1)
+	assert.Equal(t, "ws/repo", report.Repositories[0].SourceRepository)
+	assert.Equal(t, []data.IssueLink{
+		{PullRequest: 1, Issue: 4, Source: issueLinkBranch},
+		{PullRequest: 2, Issue: 1, Source: issueLinkDescription, Closes: true},
+	}, report.Repositories[0].Links)
+	assert.Equal(t, 2, exporter.report.Counts.IssueLinks)
+	assert.True(t, sidecarPaths[issueLinksFile])
+}
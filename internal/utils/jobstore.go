@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+)
+
+const (
+	jobStoreFile     = "jobs.json"
+	jobStoreLockFile = "jobs.json.lock"
+	jobIDPrefix      = "job-"
+
+	// A lock older than this was left by a process that died while
+	// updating the store; updates take milliseconds.
+	staleJobStoreLock = 30 * time.Second
+	jobStoreLockWait  = 10 * time.Second
+)
+
+// ErrJobNotFound is returned for job IDs that are not in the job store.
+var ErrJobNotFound = errors.New("job not found")
+
+// jobStore keeps the serve jobs in jobs.json in the server's output
+// directory. Every change reads and rewrites the file under a lock file, so
+// the jobs commands and a running server see each other's changes.
+type jobStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func newJobStore(dir string) *jobStore {
+	return &jobStore{dir: dir}
+}
+
+// jobs returns the stored jobs in submission order.
+func (s *jobStore) jobs() ([]data.ExportJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+func (s *jobStore) job(id string) (data.ExportJob, error) {
+	jobs, err := s.jobs()
+	if err != nil {
+		return data.ExportJob{}, err
+	}
+	for _, job := range jobs {
+		if job.ID == id {
+			return job, nil
+		}
+	}
+	return data.ExportJob{}, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+}
+
+// update applies change to the stored jobs and writes them back when they
+// changed, unless change fails.
+func (s *jobStore) update(change func(jobs []data.ExportJob) ([]data.ExportJob, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create job store directory: %w", err)
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	jobs, err := s.load()
+	if err != nil {
+		return err
+	}
+	before, err := json.Marshal(jobs)
+	if err != nil {
+		return err
+	}
+	jobs, err = change(jobs)
+	if err != nil {
+		return err
+	}
+	after, err := json.Marshal(jobs)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(before, after) {
+		return nil
+	}
+	return writeFileAtomic(filepath.Join(s.dir, jobStoreFile), 0600, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(jobs)
+	})
+}
+
+// lock creates the store's lock file, waiting while another process holds
+// it and taking over locks left by processes that died.
+func (s *jobStore) lock() (func(), error) {
+	path := filepath.Join(s.dir, jobStoreLockFile)
+	deadline := time.Now().Add(jobStoreLockWait)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_ = file.Close()
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock job store: %w", err)
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > staleJobStoreLock {
+			_ = os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("job store is locked by another process; remove %s if none is running", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (s *jobStore) load() ([]data.ExportJob, error) {
+	content, err := os.ReadFile(filepath.Join(s.dir, jobStoreFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return []data.ExportJob{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job store: %w", err)
+	}
+	var jobs []data.ExportJob
+	if err := json.Unmarshal(content, &jobs); err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrCorruptExportFile, jobStoreFile, err)
+	}
+	return jobs, nil
+}
+
+// nextJobID returns the ID following the highest stored job number.
+func nextJobID(jobs []data.ExportJob) string {
+	highest := 0
+	for _, job := range jobs {
+		if n, err := strconv.Atoi(strings.TrimPrefix(job.ID, jobIDPrefix)); err == nil && n > highest {
+			highest = n
+		}
+	}
+	return fmt.Sprintf("%s%06d", jobIDPrefix, highest+1)
+}
+
+// ListJobs returns the jobs recorded by serve in an output directory.
+func ListJobs(outputDir string) ([]data.ExportJob, error) {
+	return newJobStore(outputDir).jobs()
+}
+
+// RetryJob queues a failed or interrupted job again. Its earlier output is
+// removed; a running server picks the job up, otherwise it runs when serve
+// next starts.
+func RetryJob(outputDir, id string) (data.ExportJob, error) {
+	return newJobStore(outputDir).retry(id)
+}
+
+func (s *jobStore) retry(id string) (data.ExportJob, error) {
+	var retried data.ExportJob
+	err := s.update(func(jobs []data.ExportJob) ([]data.ExportJob, error) {
+		for i := range jobs {
+			if jobs[i].ID != id {
+				continue
+			}
+			job := &jobs[i]
+			if job.Status != JobStatusFailed && job.Status != JobStatusInterrupted {
+				return nil, fmt.Errorf("job %s is %s; only failed or interrupted jobs can be retried", id, job.Status)
+			}
+			if err := removeJobOutput(job.OutputDir); err != nil {
+				return nil, err
+			}
+			job.Status = JobStatusQueued
+			job.StartedAt = ""
+			job.FinishedAt = ""
+			job.Archives = nil
+			job.Reports = nil
+			job.Error = ""
+			retried = *job
+			return jobs, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, id)
+	})
+	return retried, err
+}
+
+// interruptRunning marks jobs left running by a server that stopped, so they
+// can be retried. It must only be called before workers start.
+func (s *jobStore) interruptRunning() (int, error) {
+	interrupted := 0
+	err := s.update(func(jobs []data.ExportJob) ([]data.ExportJob, error) {
+		for i := range jobs {
+			if jobs[i].Status == JobStatusRunning {
+				jobs[i].Status = JobStatusInterrupted
+				jobs[i].FinishedAt = time.Now().UTC().Format(time.RFC3339)
+				jobs[i].Error = "the server stopped while the job was running"
+				interrupted++
+			}
+		}
+		return jobs, nil
+	})
+	return interrupted, err
+}
+
+// claimNext marks the oldest queued job as running and returns it.
+func (s *jobStore) claimNext() (data.ExportJob, bool, error) {
+	var claimed data.ExportJob
+	found := false
+	err := s.update(func(jobs []data.ExportJob) ([]data.ExportJob, error) {
+		for i := range jobs {
+			if jobs[i].Status == JobStatusQueued {
+				jobs[i].Status = JobStatusRunning
+				jobs[i].StartedAt = time.Now().UTC().Format(time.RFC3339)
+				jobs[i].Attempts++
+				claimed = jobs[i]
+				found = true
+				break
+			}
+		}
+		return jobs, nil
+	})
+	return claimed, found, err
+}
+
+// removeJobOutput deletes a job's output directory and the archives created
+// next to it.
+func removeJobOutput(outputDir string) error {
+	if outputDir == "" {
+		return nil
+	}
+	archives, _ := filepath.Glob(outputDir + ".tar.gz*")
+	for _, path := range append([]string{outputDir}, archives...) {
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to remove earlier job output: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func seedJobStore(t *testing.T, dir string, jobs ...data.ExportJob) {
+	t.Helper()
+	require.NoError(t, newJobStore(dir).update(func([]data.ExportJob) ([]data.ExportJob, error) {
+		return jobs, nil
+	}))
+}
+
+func TestJobStorePersistsJobs(t *testing.T) {
+	dir := t.TempDir()
+	seedJobStore(t, dir,
+		data.ExportJob{ID: "job-000001", Status: JobStatusCompleted},
+		data.ExportJob{ID: "job-000002", Status: JobStatusQueued})
+
+	jobs, err := ListJobs(dir)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "job-000002", jobs[1].ID)
+	assert.Equal(t, "job-000003", nextJobID(jobs))
+
+	info, err := os.Stat(filepath.Join(dir, jobStoreFile))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestListJobsWithoutStore(t *testing.T) {
+	jobs, err := ListJobs(t.TempDir())
+	require.NoError(t, err)
+	assert.Empty(t, jobs)
+}
+
+func TestListJobsCorruptStore(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, jobStoreFile), []byte("{"), 0600))
+
+	_, err := ListJobs(dir)
+	assert.ErrorIs(t, err, ErrCorruptExportFile)
+}
+
+func TestJobStoreClaimNext(t *testing.T) {
+	store := newJobStore(t.TempDir())
+	require.NoError(t, store.update(func([]data.ExportJob) ([]data.ExportJob, error) {
+		return []data.ExportJob{
+			{ID: "job-000001", Status: JobStatusCompleted},
+			{ID: "job-000002", Status: JobStatusQueued},
+			{ID: "job-000003", Status: JobStatusQueued},
+		}, nil
+	}))
+
+	job, found, err := store.claimNext()
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "job-000002", job.ID)
+	assert.Equal(t, JobStatusRunning, job.Status)
+	assert.Equal(t, 1, job.Attempts)
+	assert.NotEmpty(t, job.StartedAt)
+
+	job, found, err = store.claimNext()
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "job-000003", job.ID)
+
+	_, found, err = store.claimNext()
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestExportServerRestoreInterruptsRunningJobs(t *testing.T) {
+	dir := t.TempDir()
+	seedJobStore(t, dir,
+		data.ExportJob{ID: "job-000001", Status: JobStatusRunning},
+		data.ExportJob{ID: "job-000002", Status: JobStatusQueued})
+
+	server := NewExportServer(data.CmdExportFlags{}, dir, "", zap.NewNop())
+	require.NoError(t, server.Restore())
+
+	jobs, err := ListJobs(dir)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusInterrupted, jobs[0].Status)
+	assert.Contains(t, jobs[0].Error, "server stopped")
+	assert.Equal(t, JobStatusQueued, jobs[1].Status)
+}
+
+func TestRetryJob(t *testing.T) {
+	dir := t.TempDir()
+	outputDir := filepath.Join(dir, "job-000001")
+	require.NoError(t, os.MkdirAll(outputDir, 0755))
+	require.NoError(t, os.WriteFile(outputDir+".tar.gz", []byte("partial"), 0644))
+	seedJobStore(t, dir,
+		data.ExportJob{ID: "job-000001", Status: JobStatusInterrupted, OutputDir: outputDir, Attempts: 1,
+			StartedAt: "2024-01-01T00:00:00Z", Error: "the server stopped while the job was running"},
+		data.ExportJob{ID: "job-000002", Status: JobStatusRunning})
+
+	job, err := RetryJob(dir, "job-000001")
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusQueued, job.Status)
+	assert.Empty(t, job.StartedAt)
+	assert.Empty(t, job.Error)
+	assert.Equal(t, 1, job.Attempts)
+	assert.NoDirExists(t, outputDir)
+	assert.NoFileExists(t, outputDir+".tar.gz")
+
+	stored, err := newJobStore(dir).job("job-000001")
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusQueued, stored.Status)
+
+	_, err = RetryJob(dir, "job-000002")
+	assert.ErrorContains(t, err, "job job-000002 is running")
+	_, err = RetryJob(dir, "job-000009")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestExportServerPicksUpRetriedJobs(t *testing.T) {
+	dir := t.TempDir()
+	original := jobPollInterval
+	jobPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { jobPollInterval = original })
+	seedJobStore(t, dir, data.ExportJob{ID: "job-000001", Status: JobStatusFailed,
+		Request: data.ExportJobRequest{Workspace: "workspace", Repository: "repo"}})
+
+	server := NewExportServer(data.CmdExportFlags{BitbucketAccessToken: "token"}, dir, "", zap.NewNop())
+	server.run = func(ctx context.Context, flags *data.CmdExportFlags, logger *zap.Logger) ([]string, error) {
+		return []string{flags.OutputDir}, nil
+	}
+	server.StartWorkers(1)
+	defer server.Close()
+
+	_, err := RetryJob(dir, "job-000001")
+	require.NoError(t, err)
+	job := waitForJob(t, server, "job-000001")
+	assert.Equal(t, JobStatusCompleted, job.Status, job.Error)
+}
+
+func TestJobStoreTakesOverStaleLock(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, jobStoreLockFile)
+	require.NoError(t, os.WriteFile(lockPath, nil, 0600))
+	stale := time.Now().Add(-2 * staleJobStoreLock)
+	require.NoError(t, os.Chtimes(lockPath, stale, stale))
+
+	seedJobStore(t, dir, data.ExportJob{ID: "job-000001", Status: JobStatusQueued})
+
+	jobs, err := ListJobs(dir)
+	require.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.NoFileExists(t, lockPath)
+}
@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// lfsAvailable reports whether the git-lfs extension is installed. It is a
+// variable so tests can run without git-lfs.
+var lfsAvailable = func() bool {
+	return exec.Command("git", "lfs", "version").Run() == nil
+}
+
+// usesLFS reports whether a .gitattributes file on any branch of a
+// repository tracks files with Git LFS.
+func usesLFS(repoDir string) bool {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(objectname)", "refs/heads")
+	cmd.Dir = repoDir
+	output, err := cmd.Output()
+	if err != nil {
+		return false
+	}
+	tips := strings.Fields(string(output))
+	if len(tips) == 0 {
+		return false
+	}
+
+	args := append([]string{"grep", "-l", "-e", "filter=lfs"}, tips...)
+	args = append(args, "--", "*.gitattributes")
+	grep := exec.Command("git", args...)
+	grep.Dir = repoDir
+	matches, err := grep.Output()
+	return err == nil && strings.TrimSpace(string(matches)) != ""
+}
+
+// fetchLFSObjects downloads the Git LFS objects of every ref of a repository
+// that uses LFS into <repo>.git/lfs/objects, where the importer reads them,
+// and turns on git_lfs_in_archives in its record. Without the objects the
+// imported repository only holds pointer files, so a missing git-lfs
+// extension or a failed fetch stops the export.
+func (e *Exporter) fetchLFSObjects(repoSlug, repoDir, cloneURL string) error {
+	if !usesLFS(repoDir) {
+		return nil
+	}
+	if e.localMirror != "" && countLFSObjects(repoDir) > 0 {
+		// Objects the local mirror brought along are used as they are.
+		e.recordLFSObjects(repoSlug, repoDir)
+		return nil
+	}
+	if !lfsAvailable() {
+		return fmt.Errorf("repository %s uses Git LFS but git-lfs is not installed; install it from https://git-lfs.com and run the export again", repoSlug)
+	}
+
+	credentialEnv, removeCredentials, err := e.client.gitCredentialHelper(cloneURL)
+	if err != nil {
+		return err
+	}
+
+	e.logger.Info("Fetching Git LFS objects", zap.String("repository", repoSlug))
+	ctx, cancel := e.deadlineContext()
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "lfs", "fetch", "--all", "origin")
+	cmd.Dir = repoDir
+	cmd.Env = append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_ASKPASS=",
+		"SSH_ASKPASS=")
+	cmd.Env = append(cmd.Env, e.client.gitNetworkEnv()...)
+	cmd.Env = append(cmd.Env, credentialEnv...)
+
+	output, err := cmd.CombinedOutput()
+	removeCredentials()
+	if err != nil {
+		if deadlineErr := e.checkDeadline(stageGitClone); deadlineErr != nil {
+			return deadlineErr
+		}
+		return fmt.Errorf("failed to fetch Git LFS objects of %s: %s: %w", repoSlug, SanitizeSupportText(string(output)), err)
+	}
+
+	// Only lfs/objects belongs in the archive.
+	if err := os.RemoveAll(filepath.Join(repoDir, "lfs", "tmp")); err != nil {
+		e.logger.Warn("Failed to remove Git LFS temporary directory", zap.Error(err))
+	}
+	e.recordLFSObjects(repoSlug, repoDir)
+	return nil
+}
+
+// recordLFSObjects counts the LFS objects stored in a repository and marks
+// its record as carrying them.
+func (e *Exporter) recordLFSObjects(repoSlug, repoDir string) {
+	count := countLFSObjects(repoDir)
+	if count == 0 {
+		return
+	}
+	e.report.Counts.LFSObjects += count
+	e.updateRepositoryField(repoSlug, "git_lfs_in_archives", true)
+	e.logger.Info("Git LFS objects added to the archive",
+		zap.String("repository", repoSlug),
+		zap.Int("objects", count))
+}
+
+// countLFSObjects returns the number of objects in <repo>.git/lfs/objects.
+func countLFSObjects(repoDir string) int {
+	count := 0
+	_ = filepath.Walk(filepath.Join(repoDir, "lfs", "objects"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			count++
+		}
+		return nil
+	})
+	return count
+}
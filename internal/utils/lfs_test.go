@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// lfsFixture mirrors a repository whose "assets" branch tracks *.bin files
+// with LFS in a nested .gitattributes, or one without LFS.
+func lfsFixture(t *testing.T, withLFS bool) string {
+	t.Helper()
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, ".gitattributes"), []byte("*.sh text eol=lf\n"), 0644))
+	runGit(t, workDir, "add", "-A")
+	runGit(t, workDir, "commit", "-m", "initial")
+	if withLFS {
+		runGit(t, workDir, "checkout", "-b", "assets")
+		require.NoError(t, os.MkdirAll(filepath.Join(workDir, "assets"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, "assets", ".gitattributes"),
+			[]byte("*.bin filter=lfs diff=lfs merge=lfs -text\n"), 0644))
+		runGit(t, workDir, "add", "-A")
+		runGit(t, workDir, "commit", "-m", "Track binaries with LFS")
+	}
+
+	repoDir := filepath.Join(t.TempDir(), "repo.git")
+	runGit(t, t.TempDir(), "clone", "--mirror", workDir, repoDir)
+	return repoDir
+}
+
+func TestUsesLFS(t *testing.T) {
+	assert.True(t, usesLFS(lfsFixture(t, true)), "LFS tracked on any branch")
+	assert.False(t, usesLFS(lfsFixture(t, false)))
+	assert.False(t, usesLFS(t.TempDir()), "not a repository")
+}
+
+func TestFetchLFSObjectsRequiresGitLFS(t *testing.T) {
+	original := lfsAvailable
+	lfsAvailable = func() bool { return false }
+	defer func() { lfsAvailable = original }()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+
+	assert.NoError(t, exporter.fetchLFSObjects("repo", lfsFixture(t, false), "https://bitbucket.org/ws/repo.git"))
+	err := exporter.fetchLFSObjects("repo", lfsFixture(t, true), "https://bitbucket.org/ws/repo.git")
+	assert.ErrorContains(t, err, "repository repo uses Git LFS but git-lfs is not installed")
+}
+
+func TestRecordLFSObjects(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.repositories = exporter.createRepositoriesData(&data.BitbucketRepository{Name: "repo", Slug: "repo"}, "ws")
+	repoDir := t.TempDir()
+
+	exporter.recordLFSObjects("repo", repoDir)
+	assert.Equal(t, false, exporter.repositories[0].GeneralSettings["git_lfs_in_archives"])
+
+	objectDir := filepath.Join(repoDir, "lfs", "objects", "ab", "cd")
+	require.NoError(t, os.MkdirAll(objectDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(objectDir, "abcd1234"), []byte("binary"), 0644))
+
+	exporter.recordLFSObjects("repo", repoDir)
+	assert.Equal(t, true, exporter.repositories[0].GeneralSettings["git_lfs_in_archives"])
+	assert.Equal(t, 1, exporter.report.Counts.LFSObjects)
+}
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// maxLoggedMirrorRefs caps the branch names listed in the warning about a
+// local mirror that differs from Bitbucket.
+const maxLoggedMirrorRefs = 10
+
+// ValidateLocalMirror checks --local-mirror: it replaces the clone of exactly
+// one repository and must be an existing directory.
+func ValidateLocalMirror(cmdFlags *data.CmdExportFlags) error {
+	if cmdFlags.LocalMirror == "" {
+		return nil
+	}
+	var problems []error
+	if cmdFlags.AllRepos || len(RepositorySlugs(cmdFlags)) != 1 {
+		problems = append(problems, errors.New("--local-mirror requires exactly one --repo"))
+	}
+	if cmdFlags.TopUpFetch {
+		problems = append(problems, errors.New("--top-up-fetch cannot be combined with --local-mirror"))
+	}
+	if info, err := os.Stat(cmdFlags.LocalMirror); err != nil {
+		problems = append(problems, fmt.Errorf("invalid value for --local-mirror: %w", err))
+	} else if !info.IsDir() {
+		problems = append(problems, fmt.Errorf("invalid value for --local-mirror: %s is not a directory", cmdFlags.LocalMirror))
+	}
+	return JoinProblems(problems...)
+}
+
+// SetLocalMirror exports the bare mirror at path instead of cloning the
+// repository from Bitbucket. An empty path clones as usual.
+func (e *Exporter) SetLocalMirror(path string) {
+	e.localMirror = path
+}
+
+// copyLocalMirror checks the local mirror and clones it into tempDir, with
+// its Git LFS objects.
+func (e *Exporter) copyLocalMirror(workspace, repoSlug, tempDir string) error {
+	mirror, err := filepath.Abs(e.localMirror)
+	if err != nil {
+		return fmt.Errorf("failed to resolve --local-mirror: %w", err)
+	}
+	if err := e.validateLocalMirror(workspace, repoSlug, mirror); err != nil {
+		return err
+	}
+
+	e.logger.Info("Copying local mirror instead of cloning from Bitbucket",
+		zap.String("repository", repoSlug),
+		zap.String("mirror", mirror))
+	ctx, cancel := e.deadlineContext()
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "clone", "--mirror", mirror, tempDir)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if deadlineErr := e.checkDeadline(stageGitClone); deadlineErr != nil {
+			return deadlineErr
+		}
+		return fmt.Errorf("failed to copy local mirror %s: %s: %w", mirror, strings.TrimSpace(string(output)), err)
+	}
+	return copyLFSObjects(mirror, tempDir)
+}
+
+// validateLocalMirror fails unless mirror is a bare repository that passes
+// git fsck and has at least one branch. Branches that are missing from the
+// mirror or point elsewhere than on Bitbucket are logged; --consistency
+// decides what happens to their pull requests.
+func (e *Exporter) validateLocalMirror(workspace, repoSlug, mirror string) error {
+	cmd := exec.Command("git", "rev-parse", "--is-bare-repository")
+	cmd.Dir = mirror
+	output, err := cmd.Output()
+	if err != nil || strings.TrimSpace(string(output)) != "true" {
+		return fmt.Errorf("local mirror %s is not a bare git repository", mirror)
+	}
+
+	e.logger.Debug("Checking local mirror with git fsck", zap.String("mirror", mirror))
+	ctx, cancel := e.deadlineContext()
+	defer cancel()
+	cmd = exec.CommandContext(ctx, "git", "fsck", "--full", "--no-progress", "--no-dangling")
+	cmd.Dir = mirror
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if deadlineErr := e.checkDeadline(stageGitClone); deadlineErr != nil {
+			return deadlineErr
+		}
+		return fmt.Errorf("local mirror %s failed git fsck: %s: %w", mirror, strings.TrimSpace(string(output)), err)
+	}
+
+	refs, err := mirrorRefs(mirror)
+	if err != nil {
+		return err
+	}
+	if countBranches(refs) == 0 {
+		return fmt.Errorf("local mirror %s has no branches", mirror)
+	}
+
+	remoteRefs, err := e.client.GetRepositoryRefs(workspace, repoSlug)
+	if err != nil {
+		e.logger.Warn("Could not compare the local mirror with the branches on Bitbucket", zap.Error(err))
+		return nil
+	}
+	missing, stale := compareMirrorBranches(refs, remoteRefs)
+	if len(missing) > 0 || len(stale) > 0 {
+		e.logger.Warn("Local mirror differs from the branches on Bitbucket",
+			zap.String("repository", repoSlug),
+			zap.Int("missing", len(missing)),
+			zap.Int("outdated", len(stale)),
+			zap.Strings("missing_branches", firstRefs(missing)),
+			zap.Strings("outdated_branches", firstRefs(stale)))
+	}
+	return nil
+}
+
+// compareMirrorBranches returns the Bitbucket branches missing from a mirror
+// and those the mirror has at another commit, sorted by name.
+func compareMirrorBranches(mirror, bitbucket map[string]string) (missing, stale []string) {
+	for ref, hash := range bitbucket {
+		name, ok := strings.CutPrefix(ref, "refs/heads/")
+		if !ok {
+			continue
+		}
+		object, found := mirror[ref]
+		switch {
+		case !found:
+			missing = append(missing, name)
+		case !strings.HasPrefix(object, hash):
+			stale = append(stale, name)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	return missing, stale
+}
+
+func firstRefs(names []string) []string {
+	if len(names) > maxLoggedMirrorRefs {
+		return names[:maxLoggedMirrorRefs]
+	}
+	return names
+}
+
+// copyLFSObjects copies <mirror>/lfs/objects into repoDir, which git clone
+// leaves behind. Files are hard-linked where possible.
+func copyLFSObjects(mirror, repoDir string) error {
+	source := filepath.Join(mirror, "lfs", "objects")
+	if _, err := os.Stat(source); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(mirror, path)
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(repoDir, relPath)
+		if entry.IsDir() {
+			return os.MkdirAll(targetPath, 0755)
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		return linkOrCopyFile(path, targetPath)
+	})
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// localMirrorFixture creates a bare mirror with a main and a feature branch
+// and returns its path and the commit of main.
+func localMirrorFixture(t *testing.T) (string, string) {
+	t.Helper()
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "README.md"), []byte("hello\n"), 0644))
+	runGit(t, workDir, "add", "-A")
+	runGit(t, workDir, "commit", "-m", "initial")
+	runGit(t, workDir, "branch", "feature")
+	head := runGit(t, workDir, "rev-parse", "HEAD")
+
+	mirror := filepath.Join(t.TempDir(), "repo.git")
+	require.NoError(t, exec.Command("git", "clone", "--mirror", workDir, mirror).Run())
+	return mirror, head
+}
+
+func localMirrorServer(t *testing.T, head string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/refs") {
+			writeResponse(t, w, []byte(fmt.Sprintf(`{"values": [
+				{"type": "branch", "name": "main", "target": {"hash": %q}},
+				{"type": "branch", "name": "release", "target": {"hash": %q}}], "next": null}`, head, head)))
+			return
+		}
+		writeResponse(t, w, []byte(`{"name": "repo", "mainbranch": {"name": "main"}}`))
+	}))
+}
+
+func TestValidateLocalMirror(t *testing.T) {
+	mirror := t.TempDir()
+	file := filepath.Join(mirror, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+
+	assert.NoError(t, ValidateLocalMirror(&data.CmdExportFlags{}))
+	assert.NoError(t, ValidateLocalMirror(&data.CmdExportFlags{Repository: "repo", LocalMirror: mirror}))
+
+	err := ValidateLocalMirror(&data.CmdExportFlags{Repository: "one,two", LocalMirror: mirror})
+	assert.ErrorContains(t, err, "exactly one --repo")
+	err = ValidateLocalMirror(&data.CmdExportFlags{AllRepos: true, LocalMirror: mirror})
+	assert.ErrorContains(t, err, "exactly one --repo")
+	err = ValidateLocalMirror(&data.CmdExportFlags{Repository: "repo", LocalMirror: mirror, TopUpFetch: true})
+	assert.ErrorContains(t, err, "--top-up-fetch")
+	err = ValidateLocalMirror(&data.CmdExportFlags{Repository: "repo", LocalMirror: file})
+	assert.ErrorContains(t, err, "is not a directory")
+	err = ValidateLocalMirror(&data.CmdExportFlags{Repository: "repo", LocalMirror: filepath.Join(mirror, "missing")})
+	assert.ErrorContains(t, err, "invalid value for --local-mirror")
+}
+
+func TestCloneRepositoryFromLocalMirror(t *testing.T) {
+	mirror, head := localMirrorFixture(t)
+	lfsObject := filepath.Join(mirror, "lfs", "objects", "ab", "cd", "abcd1234")
+	require.NoError(t, os.MkdirAll(filepath.Dir(lfsObject), 0755))
+	require.NoError(t, os.WriteFile(lfsObject, []byte("large file"), 0644))
+
+	testServer := localMirrorServer(t, head)
+	defer testServer.Close()
+
+	outputDir := t.TempDir()
+	client := &Client{baseURL: testServer.URL, httpClient: testServer.Client(), logger: zap.NewNop(),
+		commitSHACache: make(map[string]string)}
+	exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+	exporter.SetLocalMirror(mirror)
+
+	// The clone URL is never contacted: the mirror is copied instead.
+	require.NoError(t, exporter.CloneRepository("workspace", "repo", "https://bitbucket.invalid/workspace/repo.git"))
+
+	repoDir := filepath.Join(outputDir, "repositories", "workspace", "repo.git")
+	refs, err := mirrorRefs(repoDir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"refs/heads/main": head, "refs/heads/feature": head}, refs)
+	assert.FileExists(t, filepath.Join(repoDir, "lfs", "objects", "ab", "cd", "abcd1234"))
+	assert.Equal(t, "https://bitbucket.org/workspace/repo.git", runGit(t, repoDir, "remote", "get-url", "origin"),
+		"the copy points at Bitbucket, not the local mirror")
+	assert.FileExists(t, lfsObject, "the local mirror is left untouched")
+}
+
+func TestCloneRepositoryRejectsInvalidLocalMirror(t *testing.T) {
+	mirror, head := localMirrorFixture(t)
+	testServer := localMirrorServer(t, head)
+	defer testServer.Close()
+
+	clone := func(localMirror string) error {
+		client := &Client{baseURL: testServer.URL, httpClient: testServer.Client(), logger: zap.NewNop(),
+			commitSHACache: make(map[string]string)}
+		exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+		exporter.SetLocalMirror(localMirror)
+		return exporter.CloneRepository("workspace", "repo", "https://bitbucket.invalid/workspace/repo.git")
+	}
+
+	assert.ErrorContains(t, clone(t.TempDir()), "is not a bare git repository")
+
+	empty := filepath.Join(t.TempDir(), "empty.git")
+	require.NoError(t, exec.Command("git", "init", "--bare", empty).Run())
+	assert.ErrorContains(t, clone(empty), "has no branches")
+
+	commit := runGit(t, mirror, "cat-file", "-p", head)
+	tree := strings.Fields(strings.SplitN(commit, "\n", 2)[0])[1]
+	require.NoError(t, os.Remove(filepath.Join(mirror, "objects", tree[:2], tree[2:])))
+	assert.ErrorContains(t, clone(mirror), "failed git fsck")
+}
+
+func TestCompareMirrorBranches(t *testing.T) {
+	mirror := map[string]string{
+		"refs/heads/main":    "1111111111111111111111111111111111111111",
+		"refs/heads/feature": "2222222222222222222222222222222222222222",
+		"refs/tags/v1":       "3333333333333333333333333333333333333333",
+	}
+	bitbucket := map[string]string{
+		"refs/heads/main":    "1111111111111111111111111111111111111111",
+		"refs/heads/feature": "4444444444444444444444444444444444444444",
+		"refs/heads/release": "5555555555555555555555555555555555555555",
+		"refs/tags/v2":       "6666666666666666666666666666666666666666",
+	}
+
+	missing, stale := compareMirrorBranches(mirror, bitbucket)
+	assert.Equal(t, []string{"release"}, missing, "tags are not compared")
+	assert.Equal(t, []string{"feature"}, stale)
+}
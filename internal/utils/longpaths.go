@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// ustarNameLimit is the longest name a plain ustar header holds.
+	ustarNameLimit = 100
+
+	LongPathsGNU      = "gnu"
+	LongPathsTruncate = "truncate"
+	LongPathsError    = "error"
+)
+
+// ErrLongArchivePath is returned when --long-paths error finds an archive
+// path that does not fit a plain tar header.
+var ErrLongArchivePath = errors.New("archive path too long")
+
+var longPathModes = []string{LongPathsGNU, LongPathsTruncate, LongPathsError}
+
+// ValidateLongPaths checks a --long-paths value. An empty value selects GNU
+// long-name entries.
+func ValidateLongPaths(mode string) error {
+	if mode == "" {
+		return nil
+	}
+	for _, known := range longPathModes {
+		if mode == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value for --long-paths: %q (supported: %s)",
+		mode, strings.Join(longPathModes, ", "))
+}
+
+// longPathMode returns how archive entries outside git repositories with
+// names over 100 characters are written:
+//   - gnu: a GNU long-name entry, as for git repository paths
+//   - truncate: the name is shortened to its parent directory and file name
+//   - error: the archive is not created
+func (e *Exporter) longPathMode() string {
+	if e.flags == nil || e.flags.LongPaths == "" {
+		return LongPathsGNU
+	}
+	return e.flags.LongPaths
+}
+
+// setArchiveEntryName sets the name and tar format of an archive entry.
+// With ustar headers, names over 100 characters use GNU long-name entries;
+// for files outside git repositories --long-paths can instead truncate them
+// or fail.
+func (e *Exporter) setArchiveEntryName(header *tar.Header, relPath string) error {
+	header.Name = ToUnixPath(relPath)
+	header.Format = e.tarFormat()
+	if header.Format != tar.FormatUSTAR || len(header.Name) <= ustarNameLimit {
+		return nil
+	}
+
+	isInGitRepo := strings.Contains(header.Name, ".git/") || strings.HasSuffix(header.Name, ".git")
+	mode := e.longPathMode()
+	if isInGitRepo || mode == LongPathsGNU {
+		header.Format = tar.FormatGNU
+		e.logger.Debug("Using GNU format for long path",
+			zap.String("path", header.Name))
+		return nil
+	}
+
+	if mode == LongPathsError {
+		return fmt.Errorf("%w: %s is longer than %d characters (use --long-paths gnu to keep it)",
+			ErrLongArchivePath, header.Name, ustarNameLimit)
+	}
+
+	dir, file := filepath.Split(header.Name)
+	if len(file) > 80 {
+		file = file[:77] + "..."
+	}
+	// Keep the immediate parent directory for context
+	parentDir := filepath.Base(dir)
+	if parentDir != "" && parentDir != "." {
+		header.Name = ToUnixPath(filepath.Join(parentDir, file))
+	} else {
+		header.Name = file
+	}
+	e.logger.Warn("Path was too long and has been truncated",
+		zap.String("original", relPath),
+		zap.String("truncated", header.Name))
+	return nil
+}
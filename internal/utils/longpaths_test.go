@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidateLongPaths(t *testing.T) {
+	for _, mode := range []string{"", LongPathsGNU, LongPathsTruncate, LongPathsError} {
+		assert.NoError(t, ValidateLongPaths(mode), mode)
+	}
+	err := ValidateLongPaths("pax")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--long-paths")
+}
+
+func longPathFixture(t *testing.T) (string, string) {
+	t.Helper()
+	sourceDir := t.TempDir()
+	relPath := filepath.Join("attachments", strings.Repeat("nested-directory/", 5), strings.Repeat("x", 40)+".json")
+	path := filepath.Join(sourceDir, relPath)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(`{"kept":true}`), 0644))
+	require.Greater(t, len(ToUnixPath(relPath)), ustarNameLimit)
+	return sourceDir, ToUnixPath(relPath)
+}
+
+func archiveWithLongPaths(t *testing.T, sourceDir, mode string) (map[string]string, error) {
+	t.Helper()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, sourceDir, zap.NewNop(), false, "")
+	exporter.flags = &data.CmdExportFlags{LongPaths: mode}
+	exporter.archiveWorkers = 1
+
+	var buf bytes.Buffer
+	if err := exporter.archiveDirectory(sourceDir, &buf); err != nil {
+		return nil, err
+	}
+	require.NoError(t, tar.NewWriter(&buf).Close())
+
+	files := make(map[string]string)
+	reader := tar.NewReader(&buf)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if header.Typeflag == tar.TypeReg {
+			content, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			files[header.Name] = string(content)
+		}
+	}
+	return files, nil
+}
+
+func TestArchiveLongPathsGNUKeepsFullName(t *testing.T) {
+	sourceDir, longName := longPathFixture(t)
+
+	files, err := archiveWithLongPaths(t, sourceDir, "")
+	require.NoError(t, err)
+	assert.Equal(t, `{"kept":true}`, files[longName])
+}
+
+func TestArchiveLongPathsTruncate(t *testing.T) {
+	sourceDir, longName := longPathFixture(t)
+
+	files, err := archiveWithLongPaths(t, sourceDir, LongPathsTruncate)
+	require.NoError(t, err)
+	assert.NotContains(t, files, longName)
+	assert.Equal(t, `{"kept":true}`, files["nested-directory/"+strings.Repeat("x", 40)+".json"])
+}
+
+func TestArchiveLongPathsError(t *testing.T) {
+	sourceDir, longName := longPathFixture(t)
+
+	_, err := archiveWithLongPaths(t, sourceDir, LongPathsError)
+	require.ErrorIs(t, err, ErrLongArchivePath)
+	assert.Contains(t, err.Error(), longName)
+}
+
+func TestSetArchiveEntryNameGitPathsIgnoreMode(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.flags = &data.CmdExportFlags{LongPaths: LongPathsError}
+
+	relPath := "repositories/workspace/repo.git/objects/pack/" + strings.Repeat("a", 80) + ".pack"
+	header := &tar.Header{}
+	require.NoError(t, exporter.setArchiveEntryName(header, relPath))
+	assert.Equal(t, relPath, header.Name)
+	assert.Equal(t, tar.FormatGNU, header.Format)
+
+	short := &tar.Header{}
+	require.NoError(t, exporter.setArchiveEntryName(short, "schema.json"))
+	assert.Equal(t, tar.FormatUSTAR, short.Format)
+}
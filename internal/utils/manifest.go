@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/version"
+)
+
+const (
+	manifestFile           = "manifest.json"
+	migrationSchemaVersion = version.ArchiveSchemaVersion
+	redactedValue          = "[REDACTED]"
+)
+
+var sensitiveFlagMarkers = []string{"Token", "Pass", "PAT", "Secret", "Email"}
+
+// urlFlags are flag fields holding URLs that may embed credentials.
+var urlFlags = map[string]bool{"Proxy": true, "BitbucketAPIURL": true}
+
+// SanitizeFlags returns the fields of a flag struct keyed by name, with
+// credentials, e-mail addresses and the userinfo of URLs redacted.
+func SanitizeFlags(flags interface{}) map[string]interface{} {
+	sanitized := make(map[string]interface{})
+	value := reflect.ValueOf(flags)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return sanitized
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return sanitized
+	}
+
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+		if isSensitiveFlag(field.Name) && !fieldValue.IsZero() {
+			sanitized[field.Name] = redactedValue
+			continue
+		}
+		if rawURL, ok := fieldValue.Interface().(string); ok && urlFlags[field.Name] {
+			sanitized[field.Name] = redactURL(rawURL)
+			continue
+		}
+		if duration, ok := fieldValue.Interface().(time.Duration); ok {
+			sanitized[field.Name] = duration.String()
+			continue
+		}
+		sanitized[field.Name] = fieldValue.Interface()
+	}
+	return sanitized
+}
+
+func isSensitiveFlag(name string) bool {
+	for _, marker := range sensitiveFlagMarkers {
+		if strings.Contains(name, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// GitVersion returns the version of the installed git, or "" when git is not
+// available.
+func GitVersion() string {
+	output, err := exec.Command("git", "--version").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(output)), "git version"))
+}
+
+func repositoryHeadSHA(repoPath string) string {
+	cmd := exec.Command("git", "rev-parse", "--verify", "HEAD")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// writeManifest records how the archive was produced so it can be traced back
+// to the exporter build, flags and source repository state.
+func (e *Exporter) writeManifest(repositories []data.ManifestRepository) error {
+	for i := range repositories {
+		repoPath := filepath.Join(e.outputDir, "repositories", repositories[i].Workspace, repositories[i].Slug+".git")
+		repositories[i].HeadSHA = repositoryHeadSHA(ToNativePath(repoPath))
+	}
+
+	buildInfo := version.Get()
+	manifest := data.ExportManifest{
+		ExporterVersion: buildInfo.Version,
+		ExporterCommit:  buildInfo.Commit,
+		GoVersion:       buildInfo.GoVersion,
+		GitVersion:      GitVersion(),
+		SchemaVersion:   migrationSchemaVersion,
+		BitbucketAPIURL: e.client.baseURL,
+		CreatedAt:       e.client.now().UTC().Format(time.RFC3339),
+		Wave:            e.wave(),
+		OutputPrefix:    e.outputPrefix(),
+		Flags:           SanitizeFlags(e.flags),
+		Repositories:    repositories,
+	}
+
+	return e.writeJSONFile(manifestFile, manifest)
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	// MergeCommitCheckReport lists merged pull requests whose merge commit
+	// does not fit their history in the report.
+	MergeCommitCheckReport = "report"
+	// MergeCommitCheckClear also clears those merge commits so the importer
+	// does not reject the pull requests.
+	MergeCommitCheckClear = "clear"
+	// MergeCommitCheckOff skips the check.
+	MergeCommitCheckOff = "off"
+
+	mergeCommitMissing        = "missing"
+	mergeCommitNotAfterBase   = "not_descendant_of_base"
+	mergeCommitNotAfterHead   = "not_descendant_of_head"
+	mergeCommitAncestryFailed = "ancestry_check_failed"
+)
+
+// ValidateMergeCommitCheck checks the --merge-commit-check value.
+func ValidateMergeCommitCheck(value string) error {
+	switch value {
+	case "", MergeCommitCheckReport, MergeCommitCheckClear, MergeCommitCheckOff:
+		return nil
+	}
+	return fmt.Errorf("invalid value for --merge-commit-check: %q (supported: %s, %s, %s)",
+		value, MergeCommitCheckReport, MergeCommitCheckClear, MergeCommitCheckOff)
+}
+
+func (e *Exporter) mergeCommitCheck() string {
+	if e.flags == nil || e.flags.MergeCommitCheck == "" {
+		return MergeCommitCheckReport
+	}
+	return e.flags.MergeCommitCheck
+}
+
+// verifyMergeCommits checks that the merge commit of every merged pull
+// request is in the mirror and descends from its base and head commits,
+// which history rewrites after the merge break. Mismatches are reported and,
+// with --merge-commit-check clear, their merge commits are cleared.
+// Placeholder and shallow mirrors lack the history to check against, so
+// their pull requests are left as they are.
+func (e *Exporter) verifyMergeCommits(workspace, repoSlug string, prs []data.PullRequest) []data.PullRequest {
+	check := e.mergeCommitCheck()
+	if check == MergeCommitCheckOff || e.skipGit {
+		return prs
+	}
+
+	repository := workspace + "/" + repoSlug
+	repoPath := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+	if e.gitDepth > 0 || isShallowRepository(repoPath) {
+		e.logger.Info("Skipping the merge commit check of a shallow mirror",
+			zap.String("repository", repository))
+		return prs
+	}
+	mismatches := 0
+	for i := range prs {
+		pr := &prs[i]
+		if pr.MergeCommitSHA == nil || *pr.MergeCommitSHA == "" {
+			continue
+		}
+		reason := mergeCommitProblem(repoPath, *pr)
+		if reason == "" {
+			continue
+		}
+
+		mismatches++
+		mismatch := data.MergeCommitMismatch{
+			Repository:     repository,
+			PullRequest:    extractPRNumber(e.client.sourceURLs(), pr.URL),
+			MergeCommitSHA: *pr.MergeCommitSHA,
+			Reason:         reason,
+		}
+		if check == MergeCommitCheckClear {
+			pr.MergeCommitSHA = nil
+			mismatch.Cleared = true
+			e.report.Counts.ClearedMergeCommits++
+		}
+		e.report.MergeCommitMismatches = append(e.report.MergeCommitMismatches, mismatch)
+		e.logger.Debug("Merge commit does not match pull request history",
+			zap.String("repository", repository),
+			zap.String("pull_request", mismatch.PullRequest),
+			zap.String("merge_commit", mismatch.MergeCommitSHA),
+			zap.String("reason", reason))
+	}
+
+	if mismatches > 0 {
+		e.logger.Warn("Merged pull requests with unverifiable merge commits; see merge_commit_mismatches in the export report",
+			zap.String("repository", repository),
+			zap.Int("count", mismatches),
+			zap.Bool("cleared", check == MergeCommitCheckClear))
+	}
+	return prs
+}
+
+// mergeCommitProblem returns why a merged pull request's merge commit does
+// not fit its history, or "" when it does. Squash and rebase merges create
+// commits with one parent that do not contain the head commit, so only
+// merge commits with several parents must descend from the head. Base or
+// head commits missing from the mirror are left to --consistency.
+func mergeCommitProblem(repoPath string, pr data.PullRequest) string {
+	merge := *pr.MergeCommitSHA
+	if !commitExists(repoPath, merge) {
+		return mergeCommitMissing
+	}
+
+	if pr.Base.SHA != "" && commitExists(repoPath, pr.Base.SHA) {
+		ancestor, err := isAncestor(repoPath, pr.Base.SHA, merge)
+		if err != nil {
+			return mergeCommitAncestryFailed
+		}
+		if !ancestor {
+			return mergeCommitNotAfterBase
+		}
+	}
+
+	if pr.Head.SHA != "" && commitExists(repoPath, pr.Head.SHA) {
+		ancestor, err := isAncestor(repoPath, pr.Head.SHA, merge)
+		if err != nil {
+			return mergeCommitAncestryFailed
+		}
+		if !ancestor && commitParentCount(repoPath, merge) > 1 {
+			return mergeCommitNotAfterHead
+		}
+	}
+	return ""
+}
+
+// isShallowRepository reports whether a mirror was cloned with limited
+// history, for example a --local-mirror taken with --depth.
+func isShallowRepository(repoPath string) bool {
+	_, err := os.Stat(filepath.Join(repoPath, "shallow"))
+	return err == nil
+}
+
+// isAncestor reports whether ancestor is reachable from descendant.
+func isAncestor(repoPath, ancestor, descendant string) (bool, error) {
+	cmd := exec.Command("git", "merge-base", "--is-ancestor", ancestor, descendant)
+	cmd.Dir = repoPath
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func commitParentCount(repoPath, sha string) int {
+	cmd := exec.Command("git", "rev-list", "--parents", "-n", "1", sha)
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return 0
+	}
+	return len(strings.Fields(string(output))) - 1
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+type mergeCommitHistory struct {
+	base, head, merge, squash, unrelated string
+}
+
+// mergeCommitFixture mirrors a repository where feature was merged with a
+// merge commit and squashed onto main, plus an orphan commit standing in for
+// a merge commit left behind by a history rewrite.
+func mergeCommitFixture(t *testing.T, check string) (*Exporter, mergeCommitHistory) {
+	t.Helper()
+	outputDir := t.TempDir()
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, name), []byte(content), 0644))
+		runGit(t, workDir, "add", "-A")
+	}
+
+	var history mergeCommitHistory
+	write("README.md", "hello\n")
+	runGit(t, workDir, "commit", "-m", "initial")
+	history.base = runGit(t, workDir, "rev-parse", "HEAD")
+
+	runGit(t, workDir, "checkout", "-b", "feature")
+	write("feature.txt", "feature\n")
+	runGit(t, workDir, "commit", "-m", "feature")
+	history.head = runGit(t, workDir, "rev-parse", "HEAD")
+
+	runGit(t, workDir, "checkout", "main")
+	write("main.txt", "main\n")
+	runGit(t, workDir, "commit", "-m", "main")
+	runGit(t, workDir, "merge", "--no-ff", "-m", "merge feature", "feature")
+	history.merge = runGit(t, workDir, "rev-parse", "HEAD")
+
+	runGit(t, workDir, "checkout", "-b", "squashed", history.base)
+	write("feature.txt", "feature\n")
+	runGit(t, workDir, "commit", "-m", "squash feature")
+	history.squash = runGit(t, workDir, "rev-parse", "HEAD")
+
+	runGit(t, workDir, "checkout", "--orphan", "rewritten")
+	write("README.md", "rewritten\n")
+	runGit(t, workDir, "commit", "-m", "rewritten")
+	history.unrelated = runGit(t, workDir, "rev-parse", "HEAD")
+
+	mirrorPath := filepath.Join(outputDir, "repositories", "workspace", "repo.git")
+	require.NoError(t, exec.Command("git", "clone", "--mirror", workDir, mirrorPath).Run())
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.flags = &data.CmdExportFlags{MergeCommitCheck: check}
+	return exporter, history
+}
+
+func mergedPullRequest(number, base, head, merge string) data.PullRequest {
+	return data.PullRequest{
+		URL:            "https://bitbucket.org/workspace/repo/pull/" + number,
+		Base:           data.PRBranch{Ref: "main", SHA: base},
+		Head:           data.PRBranch{Ref: "feature", SHA: head},
+		MergeCommitSHA: &merge,
+	}
+}
+
+func TestVerifyMergeCommits(t *testing.T) {
+	exporter, history := mergeCommitFixture(t, "")
+	prs := []data.PullRequest{
+		mergedPullRequest("1", history.base, history.head, history.merge),
+		mergedPullRequest("2", history.base, history.head, history.squash),
+		mergedPullRequest("3", history.base, history.head, history.unrelated),
+		mergedPullRequest("4", history.base, history.head, "0123456789abcdef0123456789abcdef01234567"),
+		mergedPullRequest("5", history.head, history.base, history.merge),
+		{URL: "https://bitbucket.org/workspace/repo/pull/6", Base: data.PRBranch{SHA: history.base}},
+	}
+
+	prs = exporter.verifyMergeCommits("workspace", "repo", prs)
+
+	assert.Equal(t, []data.MergeCommitMismatch{
+		{Repository: "workspace/repo", PullRequest: "3", MergeCommitSHA: history.unrelated, Reason: mergeCommitNotAfterBase},
+		{Repository: "workspace/repo", PullRequest: "4", MergeCommitSHA: "0123456789abcdef0123456789abcdef01234567", Reason: mergeCommitMissing},
+	}, exporter.report.MergeCommitMismatches)
+	assert.Zero(t, exporter.report.Counts.ClearedMergeCommits)
+	for _, pr := range prs[:5] {
+		assert.NotNil(t, pr.MergeCommitSHA, "report mode keeps merge commits")
+	}
+}
+
+func TestVerifyMergeCommitsNotDescendantOfHead(t *testing.T) {
+	exporter, history := mergeCommitFixture(t, MergeCommitCheckReport)
+	prs := []data.PullRequest{mergedPullRequest("1", history.base, history.squash, history.merge)}
+
+	exporter.verifyMergeCommits("workspace", "repo", prs)
+
+	require.Len(t, exporter.report.MergeCommitMismatches, 1)
+	assert.Equal(t, mergeCommitNotAfterHead, exporter.report.MergeCommitMismatches[0].Reason)
+}
+
+func TestVerifyMergeCommitsClear(t *testing.T) {
+	exporter, history := mergeCommitFixture(t, MergeCommitCheckClear)
+	prs := []data.PullRequest{
+		mergedPullRequest("1", history.base, history.head, history.merge),
+		mergedPullRequest("2", history.base, history.head, history.unrelated),
+	}
+
+	prs = exporter.verifyMergeCommits("workspace", "repo", prs)
+
+	require.NotNil(t, prs[0].MergeCommitSHA)
+	assert.Nil(t, prs[1].MergeCommitSHA)
+	assert.Equal(t, 1, exporter.report.Counts.ClearedMergeCommits)
+	require.Len(t, exporter.report.MergeCommitMismatches, 1)
+	assert.True(t, exporter.report.MergeCommitMismatches[0].Cleared)
+}
+
+func TestVerifyMergeCommitsOff(t *testing.T) {
+	exporter, history := mergeCommitFixture(t, MergeCommitCheckOff)
+	prs := []data.PullRequest{mergedPullRequest("1", history.base, history.head, history.unrelated)}
+
+	prs = exporter.verifyMergeCommits("workspace", "repo", prs)
+
+	assert.NotNil(t, prs[0].MergeCommitSHA)
+	assert.Empty(t, exporter.report.MergeCommitMismatches)
+}
+
+func TestVerifyMergeCommitsWithoutFullHistory(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(*testing.T, *Exporter)
+	}{
+		{"skip git", func(t *testing.T, e *Exporter) {
+			e.skipGit = true
+			require.NoError(t, os.RemoveAll(filepath.Join(e.outputDir, "repositories", "workspace", "repo.git")))
+			require.NoError(t, e.writeGitPlaceholder("workspace", "repo"))
+		}},
+		{"git depth", func(t *testing.T, e *Exporter) { e.gitDepth = 1 }},
+		{"shallow mirror", func(t *testing.T, e *Exporter) {
+			mirrorPath := filepath.Join(e.outputDir, "repositories", "workspace", "repo.git")
+			shallowPath := filepath.Join(t.TempDir(), "repo.git")
+			runGit(t, t.TempDir(), "clone", "--mirror", "--depth", "1", "file://"+mirrorPath, shallowPath)
+			require.NoError(t, os.RemoveAll(mirrorPath))
+			require.NoError(t, os.Rename(shallowPath, mirrorPath))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter, history := mergeCommitFixture(t, MergeCommitCheckClear)
+			tt.setup(t, exporter)
+			prs := []data.PullRequest{
+				mergedPullRequest("1", history.base, history.head, history.merge),
+				mergedPullRequest("2", "0123456789abcdef0123456789abcdef01234567", history.head, history.squash),
+			}
+
+			prs = exporter.verifyMergeCommits("workspace", "repo", prs)
+
+			for _, pr := range prs {
+				assert.NotNil(t, pr.MergeCommitSHA)
+			}
+			assert.Empty(t, exporter.report.MergeCommitMismatches)
+			assert.Zero(t, exporter.report.Counts.ClearedMergeCommits)
+		})
+	}
+}
+
+func TestValidateMergeCommitCheck(t *testing.T) {
+	assert.NoError(t, ValidateMergeCommitCheck(""))
+	assert.NoError(t, ValidateMergeCommitCheck(MergeCommitCheckReport))
+	assert.NoError(t, ValidateMergeCommitCheck(MergeCommitCheckClear))
+	assert.NoError(t, ValidateMergeCommitCheck(MergeCommitCheckOff))
+	assert.ErrorContains(t, ValidateMergeCommitCheck("strict"), "invalid value for --merge-commit-check")
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	analyticsDir              = "analytics"
+	analyticsPullRequestsFile = "pull_requests.ndjson"
+	analyticsCommentsFile     = "comments.ndjson"
+	analyticsUsersFile        = "users.ndjson"
+)
+
+// SetAnalyticsOutput writes flat newline-delimited JSON copies of the pull
+// requests, comments and users next to the archive for analytics tools.
+func (e *Exporter) SetAnalyticsOutput(enabled bool) {
+	e.analyticsOutput = enabled
+}
+
+func pullRequestState(pr data.PullRequest) string {
+	switch {
+	case pr.MergedAt != nil:
+		return "merged"
+	case pr.ClosedAt != nil:
+		return "closed"
+	default:
+		return "open"
+	}
+}
+
+func analyticsPullRequests(prs []data.PullRequest) []data.AnalyticsPullRequest {
+	records := make([]data.AnalyticsPullRequest, 0, len(prs))
+	for _, pr := range prs {
+		records = append(records, data.AnalyticsPullRequest{
+			URL:            pr.URL,
+			Number:         path.Base(pr.URL),
+			Repository:     pr.Repository,
+			Title:          pr.Title,
+			Body:           pr.Body,
+			State:          pullRequestState(pr),
+			Author:         pr.User,
+			BaseRef:        pr.Base.Ref,
+			BaseSHA:        pr.Base.SHA,
+			HeadRef:        pr.Head.Ref,
+			HeadSHA:        pr.Head.SHA,
+			MergeCommitSHA: pr.MergeCommitSHA,
+			CreatedAt:      pr.CreatedAt,
+			MergedAt:       pr.MergedAt,
+			ClosedAt:       pr.ClosedAt,
+			Draft:          pr.WorkInProgress,
+		})
+	}
+	return records
+}
+
+func analyticsComments(issueComments []data.IssueComment, reviewComments []data.PullRequestReviewComment) []data.AnalyticsComment {
+	records := make([]data.AnalyticsComment, 0, len(issueComments)+len(reviewComments))
+	for _, comment := range issueComments {
+		records = append(records, data.AnalyticsComment{
+			URL:         comment.URL,
+			Kind:        comment.Type,
+			PullRequest: comment.PullRequest,
+			Author:      comment.User,
+			Body:        comment.Body,
+			CreatedAt:   comment.CreatedAt,
+		})
+	}
+	for _, comment := range reviewComments {
+		records = append(records, data.AnalyticsComment{
+			URL:         comment.URL,
+			Kind:        comment.Type,
+			PullRequest: comment.PullRequest,
+			Author:      comment.User,
+			Body:        comment.Body,
+			Path:        comment.Path,
+			Line:        comment.Position,
+			CommitSHA:   comment.CommitID,
+			InReplyTo:   comment.InReplyTo,
+			CreatedAt:   comment.CreatedAt,
+			UpdatedAt:   comment.UpdatedAt,
+		})
+	}
+	return records
+}
+
+func analyticsUsers(users []data.User) []data.AnalyticsUser {
+	records := make([]data.AnalyticsUser, 0, len(users))
+	for _, user := range users {
+		records = append(records, data.AnalyticsUser{
+			URL:       user.URL,
+			Login:     user.Login,
+			Name:      user.Name,
+			CreatedAt: user.CreatedAt,
+		})
+	}
+	return records
+}
+
+func writeNDJSONFile[T any](filePath string, records []T) error {
+	return writeFileAtomic(filePath, 0644, func(w io.Writer) error {
+		writer := bufio.NewWriter(w)
+		encoder := json.NewEncoder(writer)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return fmt.Errorf("failed to encode %s: %w", filepath.Base(filePath), err)
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", filepath.Base(filePath), err)
+		}
+		return nil
+	})
+}
+
+// writeAnalyticsFiles writes the NDJSON copies into the analytics sidecar
+// directory, which is excluded from the import archive.
+func (e *Exporter) writeAnalyticsFiles(prs []data.PullRequest, issueComments []data.IssueComment,
+	reviewComments []data.PullRequestReviewComment, users []data.User) error {
+	dir := filepath.Join(e.outputDir, analyticsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create analytics directory: %w", err)
+	}
+
+	if err := writeNDJSONFile(filepath.Join(dir, analyticsPullRequestsFile), analyticsPullRequests(prs)); err != nil {
+		return err
+	}
+	if err := writeNDJSONFile(filepath.Join(dir, analyticsCommentsFile), analyticsComments(issueComments, reviewComments)); err != nil {
+		return err
+	}
+	if err := writeNDJSONFile(filepath.Join(dir, analyticsUsersFile), analyticsUsers(users)); err != nil {
+		return err
+	}
+
+	e.logger.Info("Wrote NDJSON analytics files (excluded from import archive)",
+		zap.String("directory", dir),
+		zap.Int("pull_requests", len(prs)),
+		zap.Int("comments", len(issueComments)+len(reviewComments)),
+		zap.Int("users", len(users)))
+	return nil
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func readNDJSON[T any](t *testing.T, path string) []T {
+	t.Helper()
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+
+	var records []T
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record T
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record), scanner.Text())
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestWriteAnalyticsFiles(t *testing.T) {
+	outputDir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+
+	merged := "2024-01-02T00:00:00Z"
+	replyTo := "11"
+	prs := []data.PullRequest{
+		{URL: "https://bitbucket.org/ws/repo/pull/7", Repository: "https://bitbucket.org/ws/repo", Title: "Add feature",
+			Body: "multi\nline", User: "https://bitbucket.org/alice", MergedAt: &merged, ClosedAt: &merged,
+			Base: data.PRBranch{Ref: "main", SHA: "aaa"}, Head: data.PRBranch{Ref: "feature", SHA: "bbb"}},
+		{URL: "https://bitbucket.org/ws/repo/pull/8", Title: "Draft", WorkInProgress: true},
+	}
+	issueComments := []data.IssueComment{
+		{Type: "issue_comment", URL: "https://bitbucket.org/ws/repo/pull/7#issuecomment-10",
+			PullRequest: prs[0].URL, User: "https://bitbucket.org/bob", Body: "LGTM"},
+	}
+	reviewComments := []data.PullRequestReviewComment{
+		{Type: "pull_request_review_comment", URL: "https://bitbucket.org/ws/repo/pull/7/files#r12",
+			PullRequest: prs[0].URL, User: "https://bitbucket.org/alice", Body: "nit", Path: "main.go",
+			Position: 4, CommitID: "bbb", InReplyTo: &replyTo},
+	}
+	users := []data.User{{URL: "https://bitbucket.org/alice", Login: "alice", Name: "Alice"}}
+
+	exporter.SetAnalyticsOutput(true)
+	require.NoError(t, exporter.writeAnalyticsFiles(prs, issueComments, reviewComments, users))
+
+	prRecords := readNDJSON[data.AnalyticsPullRequest](t, filepath.Join(outputDir, analyticsDir, analyticsPullRequestsFile))
+	require.Len(t, prRecords, 2)
+	assert.Equal(t, "7", prRecords[0].Number)
+	assert.Equal(t, "merged", prRecords[0].State)
+	assert.Equal(t, "multi\nline", prRecords[0].Body)
+	assert.Equal(t, "feature", prRecords[0].HeadRef)
+	assert.Equal(t, "open", prRecords[1].State)
+	assert.True(t, prRecords[1].Draft)
+
+	commentRecords := readNDJSON[data.AnalyticsComment](t, filepath.Join(outputDir, analyticsDir, analyticsCommentsFile))
+	require.Len(t, commentRecords, 2)
+	assert.Equal(t, "issue_comment", commentRecords[0].Kind)
+	assert.Equal(t, "pull_request_review_comment", commentRecords[1].Kind)
+	assert.Equal(t, "main.go", commentRecords[1].Path)
+	assert.Equal(t, 4, commentRecords[1].Line)
+	require.NotNil(t, commentRecords[1].InReplyTo)
+	assert.Equal(t, "11", *commentRecords[1].InReplyTo)
+
+	userRecords := readNDJSON[data.AnalyticsUser](t, filepath.Join(outputDir, analyticsDir, analyticsUsersFile))
+	require.Len(t, userRecords, 1)
+	assert.Equal(t, "alice", userRecords[0].Login)
+}
+
+func TestAnalyticsDirExcludedFromArchive(t *testing.T) {
+	assert.True(t, sidecarPaths[analyticsDir])
+}
+
+func TestPullRequestState(t *testing.T) {
+	closed := "2024-01-01T00:00:00Z"
+	assert.Equal(t, "open", pullRequestState(data.PullRequest{}))
+	assert.Equal(t, "closed", pullRequestState(data.PullRequest{ClosedAt: &closed}))
+	assert.Equal(t, "merged", pullRequestState(data.PullRequest{MergedAt: &closed, ClosedAt: &closed}))
+}
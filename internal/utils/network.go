@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// proxySchemes are the --proxy URL schemes supported by both the API client
+// and git.
+var proxySchemes = map[string]bool{"http": true, "https": true, "socks5": true, "socks5h": true}
+
+// NetworkOptions routes Bitbucket API and git traffic through a proxy and
+// configures how TLS certificates are verified.
+type NetworkOptions struct {
+	Proxy           string // http, https, socks5 or socks5h URL; empty uses the proxy environment variables
+	CABundle        string // PEM file of CA certificates trusted besides the system ones
+	InsecureSkipTLS bool
+}
+
+// NetworkOptionsFromFlags returns the network options of the export flags.
+func NetworkOptionsFromFlags(flags *data.CmdExportFlags) NetworkOptions {
+	return NetworkOptions{Proxy: flags.Proxy, CABundle: flags.CABundle, InsecureSkipTLS: flags.InsecureSkipTLS}
+}
+
+// ValidateNetworkOptions checks the --proxy URL and that --ca-bundle holds
+// at least one PEM certificate.
+func ValidateNetworkOptions(opts NetworkOptions) error {
+	var problems []error
+	if opts.Proxy != "" {
+		if _, err := parseProxyURL(opts.Proxy); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if opts.CABundle != "" {
+		if _, err := loadCABundle(opts.CABundle); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return JoinProblems(problems...)
+}
+
+func parseProxyURL(proxy string) (*url.URL, error) {
+	parsed, err := url.Parse(proxy)
+	if err != nil || parsed.Host == "" || !proxySchemes[parsed.Scheme] {
+		return nil, fmt.Errorf("invalid value for --proxy: %q (expected an http://, https://, socks5:// or socks5h:// URL)", redactURL(proxy))
+	}
+	return parsed, nil
+}
+
+// redactURL hides the password of a URL, e.g. of an authenticating proxy.
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.User == nil {
+		return rawURL
+	}
+	return parsed.Redacted()
+}
+
+// loadCABundle returns the system certificate pool with the certificates of
+// a PEM file added.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --ca-bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("invalid --ca-bundle %s: no PEM certificates found", path)
+	}
+	return pool, nil
+}
+
+// SetNetworkOptions applies a proxy, CA bundle and TLS verification setting
+// to the client's transport, and to the git commands the export runs. Call
+// it after SetTransport.
+func (c *Client) SetNetworkOptions(opts NetworkOptions) error {
+	if opts == (NetworkOptions{}) {
+		return nil
+	}
+	c.network = opts
+
+	var transport *http.Transport
+	switch current := c.transport().(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = current.Clone()
+	default:
+		return fmt.Errorf("--proxy, --ca-bundle and --insecure-skip-tls cannot be combined with a custom API transport")
+	}
+
+	if opts.Proxy != "" {
+		if transport.DialContext != nil && transport.Proxy == nil {
+			return fmt.Errorf("--proxy cannot be combined with api.transport in the config file")
+		}
+		proxyURL, err := parseProxyURL(opts.Proxy)
+		if err != nil {
+			return err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+		c.logger.Info("Using proxy for Bitbucket traffic", zap.String("proxy", redactURL(opts.Proxy)))
+	}
+
+	if opts.CABundle != "" || opts.InsecureSkipTLS {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if transport.TLSClientConfig != nil {
+			tlsConfig = transport.TLSClientConfig.Clone()
+		}
+		if opts.CABundle != "" {
+			pool, err := loadCABundle(opts.CABundle)
+			if err != nil {
+				return err
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if opts.InsecureSkipTLS {
+			c.logger.Warn("TLS certificate verification is disabled for Bitbucket traffic")
+			tlsConfig.InsecureSkipVerify = true
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	c.SetTransport(transport)
+	return nil
+}
+
+func (c *Client) transport() http.RoundTripper {
+	if c.httpClient == nil {
+		return nil
+	}
+	return c.httpClient.Transport
+}
+
+// gitNetworkEnv returns the environment that routes git and git-lfs through
+// the client's proxy with its TLS settings.
+func (c *Client) gitNetworkEnv() []string {
+	var env []string
+	if c.network.Proxy != "" {
+		env = append(env, "http_proxy="+c.network.Proxy, "https_proxy="+c.network.Proxy)
+	}
+	if c.network.CABundle != "" {
+		env = append(env, "GIT_SSL_CAINFO="+c.network.CABundle)
+	}
+	if c.network.InsecureSkipTLS {
+		env = append(env, "GIT_SSL_NO_VERIFY=true")
+	}
+	return env
+}
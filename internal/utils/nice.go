@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// niceRequestDelay is the minimum gap between API requests in nice mode.
+var niceRequestDelay = 500 * time.Millisecond
+
+// SetNiceMode throttles the client for workspaces whose API quota is shared
+// with production integrations: requests are spaced out by niceRequestDelay
+// and paginated endpoints fetch half as many items per page. Nice mode keeps
+// to one request at a time, whatever --concurrency says.
+func (c *Client) SetNiceMode(enabled bool) {
+	c.niceMode = enabled
+	if enabled {
+		c.requestDelay = niceRequestDelay
+		c.logger.Info("Nice mode enabled: throttling Bitbucket API requests",
+			zap.Duration("request_delay", c.requestDelay))
+	} else {
+		c.requestDelay = 0
+	}
+}
+
+// pageLen returns the page size to request for a paginated endpoint.
+func (c *Client) pageLen(pageLen int) int {
+	if c.niceMode && pageLen > 1 {
+		return pageLen / 2
+	}
+	return pageLen
+}
+
+// throttle counts a request and waits until requestDelay has passed since
+// the previous request and any rate-limit backoff of another worker is over.
+// Concurrent callers reserve consecutive slots.
+func (c *Client) throttle() {
+	c.mu.Lock()
+	c.throttling.requests++
+	now := time.Now()
+	start := now
+	if c.requestDelay > 0 {
+		if !c.lastRequestAt.IsZero() {
+			if next := c.lastRequestAt.Add(c.requestDelay); next.After(start) {
+				start = next
+			}
+		}
+		c.throttling.niceDelay += start.Sub(now)
+	}
+	if c.backoffUntil.After(start) {
+		start = c.backoffUntil
+	}
+	if c.requestDelay > 0 {
+		c.lastRequestAt = start
+	}
+	c.mu.Unlock()
+
+	if wait := start.Sub(now); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// backOff records a rate-limit hit and holds back the requests of all
+// workers for delay, so they do not keep hitting the exhausted quota while
+// the rejected request waits to retry.
+func (c *Client) backOff(delay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.throttling.rateLimitHits++
+	c.throttling.rateLimitWait += delay
+	c.throttling.retries++
+	if until := time.Now().Add(delay); until.After(c.backoffUntil) {
+		c.backoffUntil = until
+	}
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNiceModePageLen(t *testing.T) {
+	client := &Client{logger: zap.NewNop()}
+	assert.Equal(t, 100, client.pageLen(100))
+
+	client.SetNiceMode(true)
+	assert.Equal(t, 50, client.pageLen(100))
+	assert.Equal(t, 25, client.pageLen(50))
+	assert.Equal(t, 1, client.pageLen(1))
+
+	client.SetNiceMode(false)
+	assert.Equal(t, 100, client.pageLen(100))
+	assert.Zero(t, client.requestDelay)
+}
+
+func TestNiceModeThrottlesRequests(t *testing.T) {
+	oldDelay := niceRequestDelay
+	niceRequestDelay = 50 * time.Millisecond
+	defer func() { niceRequestDelay = oldDelay }()
+
+	var mu sync.Mutex
+	var requestTimes []time.Time
+	var pageLens []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requestTimes = append(requestTimes, time.Now())
+		pageLens = append(pageLens, r.URL.Query().Get("pagelen"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+		writeResponse(t, w, []byte(`{"values": [], "next": ""}`))
+	}))
+	defer testServer.Close()
+
+	client := &Client{
+		baseURL:    testServer.URL,
+		httpClient: testServer.Client(),
+		logger:     zap.NewNop(),
+	}
+	require.NoError(t, ConfigureClient(client, &data.CmdExportFlags{Nice: true}))
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetWorkspaceRepositories("workspace")
+		require.NoError(t, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, requestTimes, 3)
+	for i := 1; i < len(requestTimes); i++ {
+		assert.GreaterOrEqual(t, requestTimes[i].Sub(requestTimes[i-1]), 45*time.Millisecond)
+	}
+	assert.Equal(t, []string{"50", "50", "50"}, pageLens)
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultOAuthTokenURL is the Bitbucket Cloud endpoint OAuth consumers get
+// access tokens from.
+const DefaultOAuthTokenURL = "https://bitbucket.org/site/oauth2/access_token"
+
+// oauthExpiryMargin renews an access token this long before it expires, so
+// that a request or clone started just before does not run into the expiry.
+const oauthExpiryMargin = 5 * time.Minute
+
+// oauthTokenResponse holds the fields read from the token endpoint.
+type oauthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"` // Seconds
+}
+
+// SetOAuthConsumer authenticates the client as a Bitbucket OAuth consumer.
+// An access token is obtained with the client-credentials grant before the
+// first request, renewed when it expires or is rejected with 401, and sent
+// as a bearer token; git authenticates with it as x-token-auth. An empty
+// key disables OAuth.
+func (c *Client) SetOAuthConsumer(key, secret string) {
+	c.oauthKey = key
+	c.oauthSecret = secret
+	if c.oauthTokenURL == "" {
+		c.oauthTokenURL = DefaultOAuthTokenURL
+	}
+	if key != "" {
+		c.logger.Debug("Using OAuth consumer authentication", zap.String("tokenURL", c.oauthTokenURL))
+	}
+}
+
+func (c *Client) usesOAuth() bool {
+	return c.oauthKey != ""
+}
+
+// ensureOAuthToken obtains an access token when the client authenticates as
+// an OAuth consumer and has no token or one about to expire.
+func (c *Client) ensureOAuthToken(ctx context.Context) error {
+	if !c.usesOAuth() || c.oauthTokenValid() {
+		return nil
+	}
+	return c.fetchOAuthToken(ctx, false, c.tokenGeneration())
+}
+
+func (c *Client) oauthTokenValid() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.accessToken != "" && time.Now().Add(oauthExpiryMargin).Before(c.oauthExpiry)
+}
+
+// fetchOAuthToken performs the client-credentials grant and swaps the new
+// access token in. Unless rejected is set, a valid token obtained by another
+// worker in the meantime is kept. When rejected is set, seen is the
+// tokenGeneration the rejected request was sent with, as for refreshToken.
+func (c *Client) fetchOAuthToken(ctx context.Context, rejected bool, seen int) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if (!rejected && c.oauthTokenValid()) || (rejected && c.tokenGeneration() != seen) {
+		return nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.oauthTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.oauthKey, c.oauthSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	c.logger.Debug("Requesting OAuth access token", zap.String("url", c.oauthTokenURL))
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("OAuth token request failed: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.logger.Warn("Error closing response body", zap.Error(err))
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("OAuth token request failed with status %d: %s", resp.StatusCode, readErrorBody(resp.Body))
+	}
+	var token oauthTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("failed to decode OAuth token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("OAuth token response contains no access token")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.accessToken != "" {
+		c.retiredSecrets = append(c.retiredSecrets, c.accessToken)
+		c.tokenRefreshes++
+	}
+	c.accessToken = token.AccessToken
+	c.oauthExpiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	c.logger.Debug("Obtained OAuth access token", zap.Time("expires", c.oauthExpiry))
+	return nil
+}
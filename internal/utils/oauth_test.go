@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// oauthServer serves a client-credentials token endpoint at /token, handing
+// out oauth-token-1, oauth-token-2, ... valid for expiresIn seconds, and an
+// API that only accepts the bearer token accepted.
+func oauthServer(t *testing.T, expiresIn int, accepted string) (*httptest.Server, *int) {
+	t.Helper()
+	grants := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			key, secret, ok := r.BasicAuth()
+			require.True(t, ok)
+			require.NoError(t, r.ParseForm())
+			if key != "consumer-key" || secret != "consumer-secret" || r.PostForm.Get("grant_type") != "client_credentials" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = fmt.Fprint(w, `{"error":"invalid_client"}`)
+				return
+			}
+			grants++
+			writeResponse(t, w, []byte(fmt.Sprintf(`{"access_token":"oauth-token-%d","expires_in":%d,"token_type":"bearer"}`, grants, expiresIn)))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+accepted {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeResponse(t, w, []byte(`{"slug": "repo"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &grants
+}
+
+func newOAuthTestClient(server *httptest.Server, key, secret string) *Client {
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop(), oauthTokenURL: server.URL + "/token"}
+	client.SetOAuthConsumer(key, secret)
+	return client
+}
+
+func TestMakeRequestObtainsOAuthToken(t *testing.T) {
+	server, grants := oauthServer(t, 7200, "oauth-token-1")
+	client := newOAuthTestClient(server, "consumer-key", "consumer-secret")
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, client.makeRequest("GET", "repositories/ws/repo", &struct{}{}))
+	}
+	assert.Equal(t, 1, *grants, "a valid token is reused")
+	assert.Equal(t, 0, client.tokenRefreshes)
+	assert.Equal(t, "OAuth consumer", getAuthMethodDescription(client))
+
+	user, secret := client.gitCredentials()
+	assert.Equal(t, "x-token-auth", user)
+	assert.Equal(t, "oauth-token-1", secret)
+	assert.Contains(t, client.gitSecrets(), "consumer-secret")
+}
+
+func TestMakeRequestRenewsExpiredOAuthToken(t *testing.T) {
+	// Tokens expiring within oauthExpiryMargin are renewed before every request.
+	server, grants := oauthServer(t, 60, "oauth-token-2")
+	client := newOAuthTestClient(server, "consumer-key", "consumer-secret")
+
+	require.NoError(t, client.ensureOAuthToken(client.context()))
+	require.NoError(t, client.makeRequest("GET", "repositories/ws/repo", &struct{}{}))
+	assert.Equal(t, 2, *grants)
+	assert.Equal(t, 1, client.tokenRefreshes)
+	assert.Contains(t, client.gitSecrets(), "oauth-token-1", "replaced tokens are still scrubbed from clones")
+}
+
+func TestMakeRequestRenewsRejectedOAuthToken(t *testing.T) {
+	server, grants := oauthServer(t, 7200, "oauth-token-2")
+	client := newOAuthTestClient(server, "consumer-key", "consumer-secret")
+
+	require.NoError(t, client.makeRequest("GET", "repositories/ws/repo", &struct{}{}))
+	assert.Equal(t, 2, *grants)
+	assert.Equal(t, 1, client.throttling.retries)
+}
+
+func TestMakeRequestOAuthGrantFails(t *testing.T) {
+	server, grants := oauthServer(t, 7200, "oauth-token-1")
+	client := newOAuthTestClient(server, "consumer-key", "wrong-secret")
+
+	err := client.makeRequest("GET", "repositories/ws/repo", &struct{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "OAuth token request failed with status 401")
+	assert.Equal(t, 0, *grants)
+}
+
+func TestSetOAuthConsumerDisabled(t *testing.T) {
+	client := &Client{logger: zap.NewNop(), accessToken: "workspace-token"}
+	client.SetOAuthConsumer("", "")
+
+	assert.False(t, client.usesOAuth())
+	require.NoError(t, client.ensureOAuthToken(client.context()))
+	assert.Equal(t, "workspace-token", client.accessToken)
+}
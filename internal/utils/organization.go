@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// organizationAvatarDir holds the workspace avatar saved with
+// --download-avatar. GitHub does not import organization avatars, so it is
+// kept next to the archive for operators to upload.
+const organizationAvatarDir = "organization"
+
+var avatarExtensions = map[string]string{
+	"image/png":     ".png",
+	"image/jpeg":    ".jpg",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/svg+xml": ".svg",
+}
+
+// SetDownloadAvatar saves the workspace avatar under organization/ next to
+// the archive.
+func (e *Exporter) SetDownloadAvatar(enabled bool) {
+	e.downloadAvatar = enabled
+}
+
+// GetWorkspace returns the display name, avatar and, for workspaces that
+// were team accounts, the website of a workspace.
+func (c *Client) GetWorkspace(workspace string) (*data.BitbucketWorkspace, error) {
+	var details data.BitbucketWorkspace
+	if err := c.makeRequest("GET", fmt.Sprintf("workspaces/%s", workspace), &details); err != nil {
+		return nil, fmt.Errorf("failed to fetch workspace %s: %w", workspace, err)
+	}
+	return &details, nil
+}
+
+// downloadAvatar fetches an avatar image and returns it with its content
+// type.
+func (c *Client) downloadAvatar(avatarURL string) ([]byte, string, error) {
+	image, contentType, err := c.download(avatarURL, "avatar", 0)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, known := avatarExtensions[contentType]; !known {
+		contentType = http.DetectContentType(image)
+	}
+	return image, contentType, nil
+}
+
+// download fetches a file and returns it with the media type the server
+// declared. Credentials are only sent to the Bitbucket hosts the client
+// talks to, including after redirects. what names the file in errors;
+// files larger than maxSize bytes are rejected without reading them in
+// full, and a maxSize of 0 applies the response size limit.
+func (c *Client) download(rawURL, what string, maxSize int64) ([]byte, string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, "", fmt.Errorf("invalid %s URL %q", what, rawURL)
+	}
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if c.isBitbucketHost(parsed.Host) {
+		if err := c.ensureOAuthToken(c.context()); err != nil {
+			return nil, "", err
+		}
+		c.setAuthHeader(req)
+	}
+
+	httpClient := *c.httpClient
+	httpClient.CheckRedirect = c.withholdCredentialsOnRedirect
+	c.throttle()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.logger.Warn("Error closing response body", zap.Error(err))
+		}
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("%s download failed with status %d", what, resp.StatusCode)
+	}
+
+	var content []byte
+	if maxSize > 0 {
+		if resp.ContentLength > maxSize {
+			return nil, "", fmt.Errorf("%s of %d bytes exceeds the limit of %d bytes", what, resp.ContentLength, maxSize)
+		}
+		content, err = io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+		if err == nil && int64(len(content)) > maxSize {
+			err = fmt.Errorf("%s exceeds the limit of %d bytes", what, maxSize)
+		}
+	} else {
+		var body io.Reader
+		if body, err = c.limitResponseBody(resp.Body, resp.ContentLength); err == nil {
+			content, err = io.ReadAll(body)
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	contentType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	return content, strings.TrimSpace(contentType), nil
+}
+
+// withholdCredentialsOnRedirect drops the Authorization header when a
+// download is redirected away from the Bitbucket hosts, for example from
+// bitbucket.org to bytebucket.org.
+func (c *Client) withholdCredentialsOnRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if !c.isBitbucketHost(req.URL.Host) {
+		req.Header.Del("Authorization")
+	}
+	return nil
+}
+
+// isBitbucketHost reports whether host is bitbucket.org, one of its
+// subdomains, or the host of the configured API.
+func (c *Client) isBitbucketHost(host string) bool {
+	if host == "bitbucket.org" || strings.HasSuffix(host, ".bitbucket.org") {
+		return true
+	}
+	base, err := url.Parse(c.baseURL)
+	return err == nil && base.Host == host
+}
+
+// exportOrganization builds the organization record from the workspace
+// details, falling back to the workspace slug when they cannot be read, and
+// saves the avatar when requested.
+func (e *Exporter) exportOrganization(workspace string) []data.Organization {
+	details, err := e.client.GetWorkspace(workspace)
+	if err != nil {
+		e.logger.Warn("Failed to fetch workspace details; naming the organization after the workspace",
+			zap.String("workspace", workspace),
+			zap.Error(err))
+		return e.createOrganizationData(workspace, nil)
+	}
+
+	if e.downloadAvatar {
+		if err := e.writeOrganizationAvatar(details); err != nil {
+			e.logger.Warn("Failed to download workspace avatar",
+				zap.String("workspace", workspace),
+				zap.Error(err))
+		}
+	}
+	return e.createOrganizationData(workspace, details)
+}
+
+// writeOrganizationAvatar saves the workspace avatar as
+// organization/avatar.<ext>.
+func (e *Exporter) writeOrganizationAvatar(details *data.BitbucketWorkspace) error {
+	if details.Links.Avatar.Href == "" {
+		return fmt.Errorf("workspace has no avatar")
+	}
+	image, contentType, err := e.client.downloadAvatar(details.Links.Avatar.Href)
+	if err != nil {
+		return err
+	}
+	extension, ok := avatarExtensions[contentType]
+	if !ok {
+		return fmt.Errorf("avatar has unsupported content type %q", contentType)
+	}
+
+	dir := filepath.Join(e.outputDir, organizationAvatarDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create organization directory: %w", err)
+	}
+	path := filepath.Join(dir, "avatar"+extension)
+	if err := writeFileAtomic(path, 0644, func(w io.Writer) error {
+		_, err := w.Write(image)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	e.logger.Info("Saved workspace avatar", zap.String("path", path))
+	return nil
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func organizationServer(t *testing.T, avatarAuth *string) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/workspaces/workspace":
+			writeResponse(t, w, []byte(`{"slug": "workspace", "name": "Acme Corp", "website": "https://acme.example",
+				"links": {"avatar": {"href": "`+server.URL+`/workspaces/workspace/avatar/"}}}`))
+		case "/workspaces/workspace/avatar/":
+			if avatarAuth != nil {
+				*avatarAuth = r.Header.Get("Authorization")
+			}
+			w.Header().Set("Content-Type", "image/png")
+			writeResponse(t, w, testPNG)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExportOrganization(t *testing.T) {
+	var avatarAuth string
+	server := organizationServer(t, &avatarAuth)
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop(), accessToken: "secret"}
+	outputDir := t.TempDir()
+	exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+
+	orgs := exporter.exportOrganization("workspace")
+	require.Len(t, orgs, 1)
+	assert.Equal(t, "workspace", orgs[0].Login)
+	assert.Equal(t, "Acme Corp", orgs[0].Name)
+	require.NotNil(t, orgs[0].Website)
+	assert.Equal(t, "https://acme.example", *orgs[0].Website)
+	assert.NoDirExists(t, filepath.Join(outputDir, organizationAvatarDir), "avatars are only saved when requested")
+
+	exporter.SetDownloadAvatar(true)
+	exporter.exportOrganization("workspace")
+	avatar, err := os.ReadFile(filepath.Join(outputDir, organizationAvatarDir, "avatar.png"))
+	require.NoError(t, err)
+	assert.Equal(t, testPNG, avatar)
+	assert.Equal(t, "Bearer secret", avatarAuth, "the configured API host receives credentials")
+	assert.True(t, sidecarPaths[organizationAvatarDir])
+}
+
+func TestExportOrganizationFallsBackToWorkspace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+
+	orgs := exporter.exportOrganization("workspace")
+
+	require.Len(t, orgs, 1)
+	assert.Equal(t, "workspace", orgs[0].Name)
+	assert.Nil(t, orgs[0].Website)
+}
+
+func TestDownloadAvatarWithholdsCredentialsFromOtherHosts(t *testing.T) {
+	var avatarAuth string
+	avatarServer := organizationServer(t, &avatarAuth)
+	client := &Client{baseURL: "https://api.bitbucket.org/2.0", httpClient: avatarServer.Client(),
+		logger: zap.NewNop(), accessToken: "secret"}
+
+	image, contentType, err := client.downloadAvatar(avatarServer.URL + "/workspaces/workspace/avatar/")
+
+	require.NoError(t, err)
+	assert.Equal(t, testPNG, image)
+	assert.Equal(t, "image/png", contentType)
+	assert.Empty(t, avatarAuth)
+}
+
+func TestIsBitbucketHost(t *testing.T) {
+	client := &Client{baseURL: "https://gateway.example.com/bitbucket/2.0"}
+
+	assert.True(t, client.isBitbucketHost("bitbucket.org"))
+	assert.True(t, client.isBitbucketHost("avatar-management--avatars.us-west-2.prod.public.atl-paas.net.bitbucket.org"))
+	assert.True(t, client.isBitbucketHost("gateway.example.com"))
+	assert.False(t, client.isBitbucketHost("evilbitbucket.org"))
+	assert.False(t, client.isBitbucketHost("secure.gravatar.com"))
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ValidateOutputPrefix checks that an --output-prefix can start file names.
+func ValidateOutputPrefix(prefix string) error {
+	return validateArtifactName("output prefix", prefix)
+}
+
+// outputPrefix returns the prefix of the archive and report names, if any.
+func (e *Exporter) outputPrefix() string {
+	if e.flags == nil {
+		return ""
+	}
+	return e.flags.OutputPrefix
+}
+
+// reportFile returns the name of the export report in the export directory,
+// tagged with the migration wave and prefixed with --output-prefix.
+func (e *Exporter) reportFile() string {
+	prefix := e.outputPrefix()
+	if wave := e.wave(); wave != "" && !hasNamePart(prefix, wave) {
+		prefix += wave + "-"
+	}
+	return prefix + exportReportFile
+}
+
+// archivePath returns the path of the archive of the export directory: next
+// to it, named after it, tagged with the migration wave and prefixed with
+// --output-prefix unless the directory name already carries them.
+func (e *Exporter) archivePath() string {
+	prefix := e.outputPrefix()
+	name := strings.TrimPrefix(filepath.Base(e.outputDir), prefix)
+	if wave := e.wave(); wave != "" && !hasNamePart(prefix+name, wave) {
+		name = wave + "-" + name
+	}
+	return filepath.Join(filepath.Dir(e.outputDir), prefix+name+".tar.gz")
+}
+
+// namePartDelimiter matches a character that separates the parts of an
+// artifact name.
+var namePartDelimiter = regexp.MustCompile(`^[^A-Za-z0-9]`)
+
+// hasNamePart reports whether part appears in name delimited by the start or
+// end of name or by characters other than letters and digits.
+func hasNamePart(name, part string) bool {
+	if part == "" {
+		return false
+	}
+	for offset := 0; ; {
+		index := strings.Index(name[offset:], part)
+		if index < 0 {
+			return false
+		}
+		start, end := offset+index, offset+index+len(part)
+		if (start == 0 || namePartDelimiter.MatchString(name[start-1:])) &&
+			(end == len(name) || namePartDelimiter.MatchString(name[end:])) {
+			return true
+		}
+		offset = start + 1
+	}
+}
+
+// exportReportPath returns the path of the export report in an export
+// directory, which carries the output prefix of the export if it had one.
+func exportReportPath(exportDir string) string {
+	path := filepath.Join(exportDir, exportReportFile)
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	if matches, _ := filepath.Glob(filepath.Join(exportDir, "*"+exportReportFile)); len(matches) > 0 {
+		return matches[0]
+	}
+	return path
+}
+
+// isExportReportFile reports whether a path relative to the export directory
+// is an export report, with or without an output prefix.
+func isExportReportFile(relPath string) bool {
+	return !strings.Contains(relPath, "/") && strings.HasSuffix(relPath, exportReportFile)
+}
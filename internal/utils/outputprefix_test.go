@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidateOutputPrefix(t *testing.T) {
+	for _, prefix := range []string{"", "acme-wave2-", "tenant_1.", "acme"} {
+		assert.NoError(t, ValidateOutputPrefix(prefix), prefix)
+	}
+	for _, prefix := range []string{"../acme-", "acme/", "-acme", "acme wave"} {
+		assert.ErrorContains(t, ValidateOutputPrefix(prefix), "invalid output prefix", prefix)
+	}
+}
+
+func TestHasNamePart(t *testing.T) {
+	assert.True(t, hasNamePart("wave2", "wave2"))
+	assert.True(t, hasNamePart("acme-wave2-repo", "wave2"))
+	assert.True(t, hasNamePart("acme.wave2", "wave2"))
+	assert.True(t, hasNamePart("wave20-wave2", "wave2"), "a later delimited occurrence counts")
+	assert.False(t, hasNamePart("acme-wave20", "wave2"))
+	assert.False(t, hasNamePart("newave2", "wave2"))
+	assert.False(t, hasNamePart("acme", ""))
+}
+
+func TestArchivePath(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, filepath.Join("exports", "bitbucket-export-1"), zap.NewNop(), false, "")
+	assert.Equal(t, filepath.Join("exports", "bitbucket-export-1.tar.gz"), exporter.archivePath())
+
+	exporter.flags = &data.CmdExportFlags{OutputPrefix: "acme-wave2-"}
+	assert.Equal(t, filepath.Join("exports", "acme-wave2-bitbucket-export-1.tar.gz"), exporter.archivePath())
+
+	exporter.outputDir = filepath.Join("exports", "acme-wave2-repo")
+	assert.Equal(t, filepath.Join("exports", "acme-wave2-repo.tar.gz"), exporter.archivePath(),
+		"a directory that already carries the prefix is not prefixed twice")
+}
+
+func TestPrefixedExportReport(t *testing.T) {
+	outputDir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.flags = &data.CmdExportFlags{OutputPrefix: "acme-"}
+	exporter.beginReport("workspace", []string{"repo"})
+	assert.Equal(t, "acme-", exporter.report.OutputPrefix)
+	exporter.finishReport(nil)
+
+	var report data.ExportReport
+	readReportFile(t, filepath.Join(outputDir, "acme-export-report.json"), &report)
+	assert.Equal(t, "acme-", report.OutputPrefix)
+	assert.NoFileExists(t, filepath.Join(outputDir, exportReportFile))
+	assert.Equal(t, filepath.Join(outputDir, "acme-export-report.json"), exportReportPath(outputDir))
+
+	info, err := os.Stat(filepath.Join(outputDir, "acme-export-report.json"))
+	require.NoError(t, err)
+	assert.True(t, exporter.skipArchiveEntry("acme-export-report.json", info), "the report stays out of the archive")
+	assert.False(t, isExportReportFile("repositories/export-report.json"))
+}
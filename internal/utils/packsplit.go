@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	// defaultMaxPackSize keeps every packfile well below the 2 GiB that
+	// single packs fail to import at.
+	defaultMaxPackSize int64 = 1 << 30
+	minMaxPackSize     int64 = 1 << 20 // git's lower bound for --max-pack-size
+)
+
+// ParseByteSize parses sizes such as "512m", "1g" or "1073741824". The k, m
+// and g suffixes are binary multiples, as in git's own size options.
+func ParseByteSize(value string) (int64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	trimmed := strings.TrimSuffix(value, "b")
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(trimmed, "k"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(trimmed, "m"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(trimmed, "g"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		trimmed = trimmed[:len(trimmed)-1]
+	}
+	size, err := strconv.ParseInt(trimmed, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size %q (expected e.g. 512m or 1g)", value)
+	}
+	return size * multiplier, nil
+}
+
+// SetMaxPackSize sets the size above which cloned repositories are repacked
+// into several smaller packfiles; "0" disables splitting.
+func (e *Exporter) SetMaxPackSize(value string) error {
+	if value == "" {
+		return nil
+	}
+	size, err := ParseByteSize(value)
+	if err != nil {
+		return fmt.Errorf("invalid --max-pack-size: %w", err)
+	}
+	if size != 0 && size < minMaxPackSize {
+		return fmt.Errorf("invalid --max-pack-size: must be 0 or at least 1m")
+	}
+	e.maxPackSize = size
+	return nil
+}
+
+// listPacks returns the packfiles of a bare repository sorted by name.
+func listPacks(repoPath string) ([]data.PackFile, error) {
+	matches, err := filepath.Glob(filepath.Join(repoPath, "objects", "pack", "*.pack"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	packs := make([]data.PackFile, 0, len(matches))
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read packfile: %w", err)
+		}
+		packs = append(packs, data.PackFile{Name: filepath.Base(match), SizeBytes: info.Size()})
+	}
+	return packs, nil
+}
+
+func largestPack(packs []data.PackFile) int64 {
+	var largest int64
+	for _, pack := range packs {
+		if pack.SizeBytes > largest {
+			largest = pack.SizeBytes
+		}
+	}
+	return largest
+}
+
+// splitPacks repacks a cloned repository into packfiles no larger than the
+// configured maximum when any pack exceeds it, and records the resulting pack
+// inventory in the export report, replacing an earlier inventory of the
+// repository.
+func (e *Exporter) splitPacks(workspace, repoSlug, repoPath string) error {
+	packs, err := listPacks(repoPath)
+	if err != nil {
+		return err
+	}
+
+	repository := fmt.Sprintf("%s/%s", workspace, repoSlug)
+	inventory := data.PackInventory{Repository: repository}
+	if e.maxPackSize > 0 && largestPack(packs) > e.maxPackSize {
+		e.logger.Info("Splitting packfiles larger than the maximum pack size",
+			zap.String("repository", repository),
+			zap.Int64("largest_pack_bytes", largestPack(packs)),
+			zap.Int64("max_pack_size_bytes", e.maxPackSize))
+
+		ctx, cancel := e.deadlineContext()
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "git", "repack", "-a", "-d", "-q",
+			fmt.Sprintf("--max-pack-size=%d", e.maxPackSize))
+		cmd.Dir = repoPath
+		if output, err := cmd.CombinedOutput(); err != nil {
+			if deadlineErr := e.checkDeadline(stageGitClone); deadlineErr != nil {
+				return deadlineErr
+			}
+			return fmt.Errorf("failed to split packfiles of %s: %s: %w", repository, string(output), err)
+		}
+
+		if packs, err = listPacks(repoPath); err != nil {
+			return err
+		}
+		inventory.Repacked = true
+		// A single object larger than the limit still ends up in its own pack.
+		if largestPack(packs) > e.maxPackSize {
+			e.logger.Warn("A packfile is still larger than the maximum pack size because it holds a single large object",
+				zap.String("repository", repository),
+				zap.Int64("largest_pack_bytes", largestPack(packs)))
+		}
+	}
+
+	inventory.Packs = packs
+	for _, pack := range packs {
+		inventory.TotalBytes += pack.SizeBytes
+	}
+	for i := range e.report.PackInventories {
+		if e.report.PackInventories[i].Repository == repository {
+			e.report.PackInventories[i] = inventory
+			return nil
+		}
+	}
+	e.report.PackInventories = append(e.report.PackInventories, inventory)
+	return nil
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseByteSize(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{"0", 0, false},
+		{"1048576", 1 << 20, false},
+		{"512k", 512 << 10, false},
+		{"512m", 512 << 20, false},
+		{"1g", 1 << 30, false},
+		{"2GB", 2 << 30, false},
+		{" 100M ", 100 << 20, false},
+		{"", 0, true},
+		{"1.5g", 0, true},
+		{"-1g", 0, true},
+		{"lots", 0, true},
+	}
+
+	for _, tc := range testCases {
+		size, err := ParseByteSize(tc.input)
+		if tc.wantErr {
+			assert.Error(t, err, tc.input)
+			continue
+		}
+		require.NoError(t, err, tc.input)
+		assert.Equal(t, tc.expected, size, tc.input)
+	}
+}
+
+func TestSetMaxPackSize(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	assert.Equal(t, defaultMaxPackSize, exporter.maxPackSize)
+
+	require.NoError(t, exporter.SetMaxPackSize(""))
+	assert.Equal(t, defaultMaxPackSize, exporter.maxPackSize)
+	require.NoError(t, exporter.SetMaxPackSize("500m"))
+	assert.Equal(t, int64(500<<20), exporter.maxPackSize)
+	require.NoError(t, exporter.SetMaxPackSize("0"))
+	assert.Equal(t, int64(0), exporter.maxPackSize)
+
+	assert.ErrorContains(t, exporter.SetMaxPackSize("512k"), "must be 0 or at least 1m")
+	assert.ErrorContains(t, exporter.SetMaxPackSize("big"), "invalid --max-pack-size")
+}
+
+// createPackedMirror returns a bare repository holding roughly sizeMB of
+// incompressible data in a single packfile.
+func createPackedMirror(t *testing.T, sizeMB int) string {
+	t.Helper()
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	for i := 0; i < sizeMB*2; i++ {
+		content := make([]byte, 512<<10)
+		_, err := rand.Read(content)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(workDir, fmt.Sprintf("blob-%d.bin", i)), content, 0644))
+	}
+	runGit(t, workDir, "add", ".")
+	runGit(t, workDir, "commit", "-m", "large files")
+
+	mirror := filepath.Join(t.TempDir(), "repo.git")
+	require.NoError(t, exec.Command("git", "clone", "--mirror", "file://"+workDir, mirror).Run())
+	runGit(t, mirror, "repack", "-a", "-d", "-q")
+	return mirror
+}
+
+func TestSplitPacks(t *testing.T) {
+	mirror := createPackedMirror(t, 3)
+	packs, err := listPacks(mirror)
+	require.NoError(t, err)
+	require.Len(t, packs, 1)
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	require.NoError(t, exporter.SetMaxPackSize("1m"))
+	require.NoError(t, exporter.splitPacks("workspace", "repo", mirror))
+
+	require.Len(t, exporter.report.PackInventories, 1)
+	inventory := exporter.report.PackInventories[0]
+	assert.Equal(t, "workspace/repo", inventory.Repository)
+	assert.True(t, inventory.Repacked)
+	assert.Greater(t, len(inventory.Packs), 1)
+	var total int64
+	for _, pack := range inventory.Packs {
+		assert.LessOrEqual(t, pack.SizeBytes, int64(1<<20)+(1<<10), pack.Name)
+		total += pack.SizeBytes
+	}
+	assert.Equal(t, total, inventory.TotalBytes)
+
+	fsck := exec.Command("git", "fsck", "--full")
+	fsck.Dir = mirror
+	output, err := fsck.CombinedOutput()
+	assert.NoError(t, err, string(output))
+}
+
+func TestSplitPacksRecordsInventoryWithoutRepacking(t *testing.T) {
+	mirror := createPackedMirror(t, 1)
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	require.NoError(t, exporter.splitPacks("workspace", "repo", mirror))
+	require.NoError(t, exporter.SetMaxPackSize("0"))
+	require.NoError(t, exporter.splitPacks("workspace", "other", mirror))
+
+	require.Len(t, exporter.report.PackInventories, 2)
+	for _, inventory := range exporter.report.PackInventories {
+		assert.False(t, inventory.Repacked)
+		assert.Len(t, inventory.Packs, 1)
+		assert.Greater(t, inventory.TotalBytes, int64(0))
+	}
+	assert.Equal(t, exporter.report.PackInventories[0].Packs,
+		exporter.report.PackInventories[1].Packs)
+}
+
+func TestSplitPacksReplacesInventoryOnRescan(t *testing.T) {
+	mirror := createPackedMirror(t, 1)
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	require.NoError(t, exporter.splitPacks("workspace", "repo", mirror))
+	require.NoError(t, exporter.splitPacks("workspace", "repo", mirror))
+
+	require.Len(t, exporter.report.PackInventories, 1)
+	assert.Equal(t, "workspace/repo", exporter.report.PackInventories[0].Repository)
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const patchesDir = "patches"
+
+// SetExportPatches saves the diff of every open pull request under patches/
+// next to the archive, as a reviewable snapshot of in-flight work.
+func (e *Exporter) SetExportPatches(enabled bool) {
+	e.exportPatches = enabled
+}
+
+// GetPullRequestDiff returns the diff Bitbucket shows for a pull request.
+func (c *Client) GetPullRequestDiff(workspace, repoSlug string, prID string) ([]byte, error) {
+	endpoint := fmt.Sprintf("repositories/%s/%s/pullrequests/%s/diff", workspace, repoSlug, prID)
+	var diff []byte
+	if err := c.makeRequest("GET", endpoint, &diff); err != nil {
+		return nil, err
+	}
+	return diff, nil
+}
+
+// localPullRequestDiff diffs a pull request's head against its merge base
+// with the destination in the exported mirror, which matches the diff
+// Bitbucket shows. It fails when either commit is not in the mirror, e.g.
+// for pull requests from forks.
+func localPullRequestDiff(repoPath string, pr data.PullRequest) ([]byte, error) {
+	if pr.Base.SHA == "" || pr.Head.SHA == "" {
+		return nil, errors.New("pull request commits are unknown")
+	}
+	for _, sha := range []string{pr.Base.SHA, pr.Head.SHA} {
+		if err := exec.Command("git", "-C", repoPath, "cat-file", "-e", sha+"^{commit}").Run(); err != nil {
+			return nil, fmt.Errorf("commit %s is not in the mirror", sha)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "-C", repoPath, "diff", "--binary", "--no-color", "--no-ext-diff",
+		pr.Base.SHA+"..."+pr.Head.SHA)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git diff failed: %w: %s", err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// writePullRequestPatches saves the diff of each open pull request as
+// patches/<pr-id>.patch, or patches/<repo>/<pr-id>.patch when several
+// repositories share the export. The diff comes from the exported mirror
+// when it has both commits, and from the Bitbucket API otherwise.
+func (e *Exporter) writePullRequestPatches(workspace string, repoSlugs []string, prsByRepo map[string][]data.PullRequest) error {
+	written := 0
+	for _, repoSlug := range repoSlugs {
+		dir := filepath.Join(e.outputDir, patchesDir)
+		if len(repoSlugs) > 1 {
+			dir = filepath.Join(dir, repoSlug)
+		}
+		repoPath := filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git")
+
+		for _, pr := range prsByRepo[repoSlug] {
+			if pullRequestState(pr) != "open" {
+				continue
+			}
+			prID := extractPRNumber(e.client.sourceURLs(), pr.URL)
+			if prID == "" {
+				continue
+			}
+
+			diff, err := localPullRequestDiff(repoPath, pr)
+			if err != nil {
+				e.logger.Debug("Falling back to the Bitbucket API for pull request diff",
+					zap.String("repository", repoSlug),
+					zap.String("pull_request", prID),
+					zap.String("reason", err.Error()))
+				diff, err = e.client.GetPullRequestDiff(workspace, repoSlug, prID)
+			}
+			if exportAborted(err) {
+				return err
+			}
+			if err != nil {
+				e.logger.Warn("Failed to export pull request diff",
+					zap.String("repository", repoSlug),
+					zap.String("pull_request", prID),
+					zap.Error(err))
+				continue
+			}
+
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return fmt.Errorf("failed to create patches directory: %w", err)
+			}
+			patchPath := filepath.Join(dir, prID+".patch")
+			if err := writeFileAtomic(patchPath, 0644, func(w io.Writer) error {
+				_, err := w.Write(diff)
+				return err
+			}); err != nil {
+				return fmt.Errorf("failed to write %s: %w", patchPath, err)
+			}
+			written++
+		}
+	}
+
+	e.logger.Info("Exported open pull request diffs",
+		zap.Int("patches", written),
+		zap.String("path", filepath.Join(e.outputDir, patchesDir)))
+	return nil
+}
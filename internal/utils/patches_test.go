@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestWritePullRequestPatches(t *testing.T) {
+	outputDir := t.TempDir()
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "README.md"), []byte("hello\n"), 0644))
+	runGit(t, workDir, "add", "-A")
+	runGit(t, workDir, "commit", "-m", "initial")
+	base := runGit(t, workDir, "rev-parse", "HEAD")
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, "README.md"), []byte("hello\nworld\n"), 0644))
+	runGit(t, workDir, "commit", "-am", "feature")
+	head := runGit(t, workDir, "rev-parse", "HEAD")
+
+	mirrorPath := filepath.Join(outputDir, "repositories", "workspace", "repo.git")
+	require.NoError(t, exec.Command("git", "clone", "--mirror", workDir, mirrorPath).Run())
+
+	var apiRequests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiRequests = append(apiRequests, r.URL.Path)
+		_, _ = w.Write([]byte("diff --git a/fork.txt b/fork.txt\n"))
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+	exporter.SetExportPatches(true)
+
+	merged := "2024-01-01T00:00:00Z"
+	prs := []data.PullRequest{
+		{URL: "https://bitbucket.org/workspace/repo/pull/1", Base: data.PRBranch{SHA: base}, Head: data.PRBranch{SHA: head}},
+		{URL: "https://bitbucket.org/workspace/repo/pull/2", Base: data.PRBranch{SHA: base},
+			Head: data.PRBranch{SHA: strings.Repeat("1", 40)}},
+		{URL: "https://bitbucket.org/workspace/repo/pull/3", MergedAt: &merged,
+			Base: data.PRBranch{SHA: base}, Head: data.PRBranch{SHA: head}},
+	}
+
+	require.NoError(t, exporter.writePullRequestPatches("workspace", []string{"repo"},
+		map[string][]data.PullRequest{"repo": prs}))
+
+	local, err := os.ReadFile(filepath.Join(outputDir, patchesDir, "1.patch"))
+	require.NoError(t, err)
+	assert.Contains(t, string(local), "+world")
+
+	fromAPI, err := os.ReadFile(filepath.Join(outputDir, patchesDir, "2.patch"))
+	require.NoError(t, err)
+	assert.Equal(t, "diff --git a/fork.txt b/fork.txt\n", string(fromAPI))
+	assert.Equal(t, []string{"/repositories/workspace/repo/pullrequests/2/diff"}, apiRequests)
+
+	assert.NoFileExists(t, filepath.Join(outputDir, patchesDir, "3.patch"), "merged pull requests are not exported")
+	assert.True(t, sidecarPaths[patchesDir], "patches stay out of the archive")
+}
+
+func TestWritePullRequestPatchesPerRepository(t *testing.T) {
+	outputDir := t.TempDir()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("diff\n"))
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+
+	prsByRepo := map[string][]data.PullRequest{
+		"alpha": {{URL: "https://bitbucket.org/workspace/alpha/pull/4"}},
+		"beta":  {{URL: "https://bitbucket.org/workspace/beta/pull/4"}},
+	}
+	require.NoError(t, exporter.writePullRequestPatches("workspace", []string{"alpha", "beta"}, prsByRepo))
+
+	assert.FileExists(t, filepath.Join(outputDir, patchesDir, "alpha", "4.patch"))
+	assert.FileExists(t, filepath.Join(outputDir, patchesDir, "beta", "4.patch"))
+}
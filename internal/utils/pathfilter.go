@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+func (e *Exporter) SetPRPathFilters(patterns []string) error {
+	matchers := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		matcher, err := compilePathPattern(pattern)
+		if err != nil {
+			return err
+		}
+		matchers = append(matchers, matcher)
+	}
+	e.prPathPatterns = patterns
+	e.prPathMatchers = matchers
+	return nil
+}
+
+// compilePathPattern converts a glob such as "src/service-a/**" into a
+// regular expression. "**" matches across directories, "*" and "?" match
+// within a single path component.
+func compilePathPattern(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.Trim(strings.TrimSpace(ToUnixPath(pattern)), "/")
+	if pattern == "" {
+		return nil, fmt.Errorf("empty path pattern")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				i++
+				if i+1 < len(pattern) && pattern[i+1] == '/' {
+					i++
+					sb.WriteString("(.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	// A pattern naming a directory also matches everything below it.
+	sb.WriteString("(/.*)?$")
+
+	matcher, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid path pattern %q: %w", pattern, err)
+	}
+	return matcher, nil
+}
+
+func (e *Exporter) matchesPRPathFilter(path string) bool {
+	for _, matcher := range e.prPathMatchers {
+		if matcher.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// pullRequestChangedPaths lists the files a pull request modified, using the
+// local mirror. Merged pull requests whose source commit is gone fall back to
+// the merge commit.
+func pullRequestChangedPaths(repoPath string, pr data.PullRequest) ([]string, error) {
+	var args []string
+	switch {
+	case pr.Base.SHA != "" && pr.Head.SHA != "" && commitExists(repoPath, pr.Base.SHA) && commitExists(repoPath, pr.Head.SHA):
+		args = []string{"diff", "--name-only", "--no-renames", "-z", pr.Base.SHA + "..." + pr.Head.SHA}
+	case pr.MergeCommitSHA != nil && *pr.MergeCommitSHA != "" && commitExists(repoPath, *pr.MergeCommitSHA):
+		args = []string{"diff", "--name-only", "--no-renames", "-z", *pr.MergeCommitSHA + "^1", *pr.MergeCommitSHA}
+	default:
+		return nil, fmt.Errorf("commits for pull request %s are not available in the local mirror", pr.URL)
+	}
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff pull request %s: %w", pr.URL, err)
+	}
+
+	paths := []string{}
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+func commitExists(repoPath, sha string) bool {
+	cmd := exec.Command("git", "cat-file", "-e", sha+"^{commit}")
+	cmd.Dir = repoPath
+	return cmd.Run() == nil
+}
+
+// filterPullRequestsByPath keeps only pull requests that modified a path
+// matching one of the --prs-touching-path patterns. Pull requests whose
+// changes cannot be determined are kept so no metadata is lost silently.
+func (e *Exporter) filterPullRequestsByPath(workspace, repoSlug string, prs []data.PullRequest) []data.PullRequest {
+	if len(e.prPathMatchers) == 0 {
+		return prs
+	}
+
+	repoPath := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+	filtered := []data.PullRequest{}
+	skipped := 0
+	for _, pr := range prs {
+		paths, err := pullRequestChangedPaths(repoPath, pr)
+		if err != nil {
+			e.logger.Warn("Could not determine files changed by pull request, keeping it",
+				zap.String("pr_url", pr.URL),
+				zap.Error(err))
+			filtered = append(filtered, pr)
+			continue
+		}
+
+		touches := false
+		for _, path := range paths {
+			if e.matchesPRPathFilter(path) {
+				touches = true
+				break
+			}
+		}
+		if touches {
+			filtered = append(filtered, pr)
+		} else {
+			skipped++
+		}
+	}
+
+	e.logger.Info("Filtered pull requests by touched paths",
+		zap.String("repository", repoSlug),
+		zap.Strings("patterns", e.prPathPatterns),
+		zap.Int("kept", len(filtered)),
+		zap.Int("skipped", skipped))
+
+	return filtered
+}
@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCompilePathPattern(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{pattern: "src/service-a/**", path: "src/service-a/main.go", match: true},
+		{pattern: "src/service-a/**", path: "src/service-a/pkg/deep/file.go", match: true},
+		{pattern: "src/service-a/**", path: "src/service-b/main.go", match: false},
+		{pattern: "src/service-a", path: "src/service-a/main.go", match: true},
+		{pattern: "src/service-a", path: "src/service-ab/main.go", match: false},
+		{pattern: "src/*.go", path: "src/main.go", match: true},
+		{pattern: "src/*.go", path: "src/pkg/main.go", match: false},
+		{pattern: "**/README.md", path: "README.md", match: true},
+		{pattern: "**/README.md", path: "docs/api/README.md", match: true},
+		{pattern: "file?.txt", path: "file1.txt", match: true},
+		{pattern: "/docs/", path: "docs/index.md", match: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern+" "+tc.path, func(t *testing.T) {
+			matcher, err := compilePathPattern(tc.pattern)
+			require.NoError(t, err)
+			assert.Equal(t, tc.match, matcher.MatchString(tc.path))
+		})
+	}
+
+	_, err := compilePathPattern("  ")
+	assert.Error(t, err)
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+	return strings.TrimSpace(string(output))
+}
+
+func TestFilterPullRequestsByPath(t *testing.T) {
+	outputDir := t.TempDir()
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+
+	commitFile := func(path string) string {
+		fullPath := filepath.Join(workDir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+		require.NoError(t, os.WriteFile(fullPath, []byte(path), 0644))
+		runGit(t, workDir, "add", "-A")
+		runGit(t, workDir, "commit", "-m", "add "+path)
+		return runGit(t, workDir, "rev-parse", "HEAD")
+	}
+
+	base := commitFile("README.md")
+	serviceA := commitFile("src/service-a/main.go")
+	serviceB := commitFile("src/service-b/main.go")
+
+	mirrorPath := filepath.Join(outputDir, "repositories", "workspace", "repo.git")
+	require.NoError(t, exec.Command("git", "clone", "--mirror", workDir, mirrorPath).Run())
+
+	prs := []data.PullRequest{
+		{URL: "pr-a", Base: data.PRBranch{SHA: base}, Head: data.PRBranch{SHA: serviceA}},
+		{URL: "pr-b", Base: data.PRBranch{SHA: serviceA}, Head: data.PRBranch{SHA: serviceB}},
+		{URL: "pr-missing", Base: data.PRBranch{SHA: strings.Repeat("0", 40)}, Head: data.PRBranch{SHA: strings.Repeat("1", 40)}},
+	}
+
+	logger, _ := zap.NewDevelopment()
+	exporter := NewExporter(&Client{logger: logger}, outputDir, logger, false, "")
+	require.NoError(t, exporter.SetPRPathFilters([]string{"src/service-a/**"}))
+
+	filtered := exporter.filterPullRequestsByPath("workspace", "repo", prs)
+	require.Len(t, filtered, 2)
+	assert.Equal(t, "pr-a", filtered[0].URL)
+	assert.Equal(t, "pr-missing", filtered[1].URL)
+}
+
+func TestFilterPullRequestsByPathDisabled(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	exporter := NewExporter(&Client{logger: logger}, t.TempDir(), logger, false, "")
+
+	prs := []data.PullRequest{{URL: "pr-1"}, {URL: "pr-2"}}
+	assert.Equal(t, prs, exporter.filterPullRequestsByPath("workspace", "repo", prs))
+}
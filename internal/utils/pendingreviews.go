@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// GitHub pull request review states used in the migration archive.
+const (
+	reviewStatePending          = 0
+	reviewStateCommented        = 1
+	reviewStateChangesRequested = 30
+	reviewStateApproved         = 40
+)
+
+// reviewCommentState maps a Bitbucket inline comment to a GitHub review
+// state: comments Bitbucket still holds as pending (drafts that were never
+// published) stay pending, everything else is a submitted comment.
+func reviewCommentState(comment data.BitbucketComment) int {
+	if comment.Pending {
+		return reviewStatePending
+	}
+	return reviewStateCommented
+}
+
+// reviewState infers the state of a review from its comments: the state of
+// the first published comment, or pending when none of them was published.
+func reviewState(comments []data.PullRequestReviewComment) int {
+	for _, comment := range comments {
+		if comment.State != reviewStatePending {
+			return comment.State
+		}
+	}
+	return reviewStatePending
+}
+
+// SetDropPendingReviews leaves reviews whose comments are all pending out of
+// the archive.
+func (e *Exporter) SetDropPendingReviews(drop bool) {
+	e.dropPendingReviews = drop
+}
+
+// filterPendingReviews removes the comments of pending-only reviews when
+// --drop-pending-reviews is set, and records how many were dropped.
+func (e *Exporter) filterPendingReviews(comments []data.PullRequestReviewComment) []data.PullRequestReviewComment {
+	if !e.dropPendingReviews {
+		return comments
+	}
+
+	commentsByReview := make(map[string][]data.PullRequestReviewComment)
+	for _, comment := range comments {
+		commentsByReview[comment.PullRequestReview] = append(commentsByReview[comment.PullRequestReview], comment)
+	}
+
+	kept := make([]data.PullRequestReviewComment, 0, len(comments))
+	droppedReviews := make(map[string]bool)
+	for _, comment := range comments {
+		if reviewState(commentsByReview[comment.PullRequestReview]) == reviewStatePending {
+			droppedReviews[comment.PullRequestReview] = true
+			continue
+		}
+		kept = append(kept, comment)
+	}
+
+	if len(droppedReviews) > 0 {
+		e.logger.Info("Dropped pending-only pull request reviews",
+			zap.Int("reviews", len(droppedReviews)),
+			zap.Int("comments", len(comments)-len(kept)))
+	}
+	e.report.Counts.PendingReviewCommentsDropped += len(comments) - len(kept)
+	return kept
+}
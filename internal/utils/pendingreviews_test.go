@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReviewState(t *testing.T) {
+	pending := data.PullRequestReviewComment{State: reviewStatePending}
+	published := data.PullRequestReviewComment{State: reviewStateCommented}
+
+	assert.Equal(t, reviewStatePending, reviewState([]data.PullRequestReviewComment{pending, pending}))
+	assert.Equal(t, reviewStateCommented, reviewState([]data.PullRequestReviewComment{pending, published}))
+	assert.Equal(t, reviewStateCommented, reviewCommentState(data.BitbucketComment{}))
+	assert.Equal(t, reviewStatePending, reviewCommentState(data.BitbucketComment{Pending: true}))
+}
+
+func TestGetPullRequestCommentsPendingState(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "comments") {
+			writeResponse(t, w, []byte(`{"values": [
+				{"id": 1, "content": {"raw": "published"}, "user": {"uuid": "{a}"},
+				 "inline": {"path": "a.go", "to": 3}},
+				{"id": 2, "content": {"raw": "draft"}, "user": {"uuid": "{a}"}, "pending": true,
+				 "inline": {"path": "b.go", "to": 7}}
+			]}`))
+			return
+		}
+		writeResponse(t, w, []byte(`{"hash": "1234567890123456789012345678901234567890"}`))
+	}))
+	defer testServer.Close()
+
+	client := &Client{
+		baseURL:        testServer.URL,
+		httpClient:     testServer.Client(),
+		logger:         zap.NewNop(),
+		commitSHACache: make(map[string]string),
+	}
+	prs := []data.PullRequest{{URL: "https://bitbucket.org/workspace/repo/pull/1", Head: data.PRBranch{SHA: "abcdef"}}}
+
+	_, reviewComments, err := client.GetPullRequestComments("workspace", "repo", prs)
+	require.NoError(t, err)
+	require.Len(t, reviewComments, 2)
+	assert.Equal(t, reviewStateCommented, reviewComments[0].State)
+	assert.Equal(t, reviewStatePending, reviewComments[1].State)
+
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	states := make(map[string]interface{})
+	for _, review := range exporter.createReviews(reviewComments) {
+		states[review["url"].(string)] = review["state"]
+	}
+	assert.Equal(t, reviewStateCommented, states[reviewComments[0].PullRequestReview])
+	assert.Equal(t, reviewStatePending, states[reviewComments[1].PullRequestReview])
+}
+
+func TestFilterPendingReviews(t *testing.T) {
+	comments := []data.PullRequestReviewComment{
+		{URL: "c1", PullRequestReview: "review-1", State: reviewStatePending},
+		{URL: "c2", PullRequestReview: "review-1", State: reviewStateCommented},
+		{URL: "c3", PullRequestReview: "review-2", State: reviewStatePending},
+		{URL: "c4", PullRequestReview: "review-2", State: reviewStatePending},
+	}
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+
+	assert.Len(t, exporter.filterPendingReviews(comments), 4, "comments are kept unless the flag is set")
+
+	exporter.SetDropPendingReviews(true)
+	kept := exporter.filterPendingReviews(comments)
+	require.Len(t, kept, 2)
+	assert.Equal(t, "c1", kept[0].URL)
+	assert.Equal(t, "c2", kept[1].URL)
+	assert.Equal(t, 2, exporter.report.Counts.PendingReviewCommentsDropped)
+}
@@ -0,0 +1,754 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	pipelinesConversionFile = "pipelines-conversion.json"
+	workflowsDir            = ".github/workflows"
+
+	pipelinesConversionNote = "The workflows are best-effort stubs written to migration-notes/<repo>/.github/workflows; " +
+		"review them, recreate repository and deployment variables as GitHub secrets or variables, and commit them to the repository"
+
+	defaultRunner = "ubuntu-latest"
+)
+
+// predefinedCaches are the paths of the caches Bitbucket Pipelines defines
+// without a definitions entry.
+var predefinedCaches = map[string]string{
+	"bundler":    "vendor/bundle",
+	"composer":   "~/.composer/cache",
+	"dotnetcore": "~/.nuget/packages",
+	"gradle":     "~/.gradle/caches",
+	"ivy2":       "~/.ivy2/cache",
+	"maven":      "~/.m2/repository",
+	"node":       "node_modules",
+	"pip":        "~/.cache/pip",
+	"sbt":        "~/.sbt",
+}
+
+// bitbucketVariables maps the Bitbucket Pipelines variables that have a
+// GitHub Actions equivalent to it. Scripts keep using the Bitbucket names; the
+// job defines them from the GitHub context.
+var bitbucketVariables = map[string]string{
+	"BITBUCKET_BRANCH":                "${{ github.ref_name }}",
+	"BITBUCKET_BUILD_NUMBER":          "${{ github.run_number }}",
+	"BITBUCKET_CLONE_DIR":             "${{ github.workspace }}",
+	"BITBUCKET_COMMIT":                "${{ github.sha }}",
+	"BITBUCKET_GIT_HTTP_ORIGIN":       "${{ github.server_url }}/${{ github.repository }}",
+	"BITBUCKET_PIPELINE_UUID":         "${{ github.run_id }}",
+	"BITBUCKET_PR_DESTINATION_BRANCH": "${{ github.base_ref }}",
+	"BITBUCKET_PR_DESTINATION_COMMIT": "${{ github.event.pull_request.base.sha }}",
+	"BITBUCKET_PR_ID":                 "${{ github.event.pull_request.number }}",
+	"BITBUCKET_REPO_FULL_NAME":        "${{ github.repository }}",
+	"BITBUCKET_REPO_OWNER":            "${{ github.repository_owner }}",
+	"BITBUCKET_REPO_SLUG":             "${{ github.event.repository.name }}",
+	"BITBUCKET_STEP_RUN_NUMBER":       "${{ github.run_attempt }}",
+	"BITBUCKET_STEP_TRIGGERER_UUID":   "${{ github.actor }}",
+	"BITBUCKET_TAG":                   "${{ github.ref_name }}",
+	"BITBUCKET_WORKSPACE":             "${{ github.repository_owner }}",
+}
+
+var (
+	bitbucketVariablePattern = regexp.MustCompile(`\$\{?(BITBUCKET_[A-Z0-9_]+)`)
+	credentialVariable       = regexp.MustCompile(`^\$\{?([A-Za-z_][A-Za-z0-9_]*)\}?$`)
+	unsafeJobID              = regexp.MustCompile(`[^a-z0-9_-]+`)
+)
+
+// SetConvertPipelines translates the bitbucket-pipelines.yml on the default
+// branch of every repository into GitHub Actions workflow stubs under
+// migration-notes/<repo>/.github/workflows and lists what they leave out in
+// pipelines-conversion.json.
+func (e *Exporter) SetConvertPipelines(enabled bool) {
+	e.convertPipelines = enabled
+}
+
+// repositoryWorkflows are the workflow stubs converted from one repository's
+// pipelines.
+type repositoryWorkflows struct {
+	repoSlug  string
+	workflows []convertedWorkflow
+}
+
+// convertedWorkflow is a workflow file and its content.
+type convertedWorkflow struct {
+	file    string
+	content []byte
+}
+
+// collectPipelineWorkflows converts the pipelines of a cloned repository.
+// Repositories without bitbucket-pipelines.yml are skipped.
+func (e *Exporter) collectPipelineWorkflows(workspace, repoSlug string) {
+	if !e.convertPipelines {
+		return
+	}
+
+	repoPath := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+	cmd := exec.Command("git", "show", "HEAD:"+pipelinesConfigFile)
+	cmd.Dir = repoPath
+	config, err := cmd.Output()
+	if err != nil {
+		e.logger.Debug("No pipelines configuration to convert", zap.String("repository", repoSlug))
+		return
+	}
+
+	conversion := data.RepositoryPipelineConversion{
+		SourceRepository: workspace + "/" + repoSlug,
+		Workflows:        []string{},
+		Unsupported:      []data.UnsupportedPipelineFeature{},
+	}
+	workflows, unsupported, err := convertPipelines(config)
+	if err != nil {
+		conversion.Error = err.Error()
+		e.logger.Warn("Failed to convert Bitbucket Pipelines configuration",
+			zap.String("repository", repoSlug),
+			zap.Error(err))
+	}
+	for _, workflow := range workflows {
+		conversion.Workflows = append(conversion.Workflows,
+			path.Join(migrationNotesDir, repoSlug, workflowsDir, workflow.file))
+	}
+	conversion.Unsupported = append(conversion.Unsupported, unsupported...)
+
+	e.report.Counts.ConvertedWorkflows += len(workflows)
+	e.report.Counts.UnsupportedPipelineFeatures += len(unsupported)
+	e.pipelineConversions = append(e.pipelineConversions, conversion)
+	e.pipelineWorkflows = append(e.pipelineWorkflows, repositoryWorkflows{repoSlug: repoSlug, workflows: workflows})
+}
+
+// writePipelineWorkflows writes the converted workflows and the conversion
+// report.
+func (e *Exporter) writePipelineWorkflows() error {
+	if !e.convertPipelines {
+		return nil
+	}
+
+	for _, repository := range e.pipelineWorkflows {
+		dir := filepath.Join(e.outputDir, migrationNotesDir, repository.repoSlug, filepath.FromSlash(workflowsDir))
+		if len(repository.workflows) > 0 {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %w", dir, err)
+			}
+		}
+		for _, workflow := range repository.workflows {
+			err := writeFileAtomic(filepath.Join(dir, workflow.file), 0644, func(w io.Writer) error {
+				_, err := w.Write(workflow.content)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("failed to write workflow %s for %s: %w", workflow.file, repository.repoSlug, err)
+			}
+		}
+	}
+
+	report := data.PipelinesConversionReport{Note: pipelinesConversionNote, Repositories: e.pipelineConversions}
+	if report.Repositories == nil {
+		report.Repositories = []data.RepositoryPipelineConversion{}
+	}
+	if err := e.writeJSONFile(pipelinesConversionFile, report); err != nil {
+		return err
+	}
+	e.logger.Info("Converted Bitbucket Pipelines to GitHub Actions workflow stubs (excluded from import archive)",
+		zap.String("file", pipelinesConversionFile),
+		zap.Int("workflows", e.report.Counts.ConvertedWorkflows),
+		zap.Int("unsupported_features", e.report.Counts.UnsupportedPipelineFeatures))
+	return nil
+}
+
+// convertPipelines translates a bitbucket-pipelines.yml into one workflow
+// per pipeline and lists the features the workflows do not cover.
+func convertPipelines(config []byte) ([]convertedWorkflow, []data.UnsupportedPipelineFeature, error) {
+	var root map[string]interface{}
+	if err := yaml.Unmarshal(config, &root); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", pipelinesConfigFile, err)
+	}
+	pipelines := asMap(root["pipelines"])
+	if pipelines == nil {
+		return nil, nil, fmt.Errorf("%s has no pipelines section", pipelinesConfigFile)
+	}
+
+	c := &pipelineConverter{root: root, clone: asMap(root["clone"]), files: make(map[string]bool)}
+	options := asMap(root["options"])
+	if size := asString(options["size"]); size != "" && size != "1x" {
+		c.unsupported("", "", "options.size", fmt.Sprintf("steps run with size %s; use a larger GitHub-hosted runner if needed", size))
+	}
+
+	branchPatterns := sortedKeys(asMap(pipelines["branches"]))
+
+	if items, ok := pipelines["default"]; ok {
+		trigger := map[string]interface{}{"branches": []string{"**"}}
+		if len(branchPatterns) > 0 {
+			trigger = map[string]interface{}{"branches-ignore": branchPatterns}
+		}
+		c.convert("default", "bitbucket-default", map[string]interface{}{"push": trigger}, items)
+	}
+	for _, pattern := range branchPatterns {
+		c.convert("branches: "+pattern, "bitbucket-branches-"+fileSlug(pattern),
+			map[string]interface{}{"push": map[string]interface{}{"branches": []string{pattern}}},
+			asMap(pipelines["branches"])[pattern])
+	}
+	for _, pattern := range sortedKeys(asMap(pipelines["tags"])) {
+		c.convert("tags: "+pattern, "bitbucket-tags-"+fileSlug(pattern),
+			map[string]interface{}{"push": map[string]interface{}{"tags": []string{pattern}}},
+			asMap(pipelines["tags"])[pattern])
+	}
+	for _, pattern := range sortedKeys(asMap(pipelines["pull-requests"])) {
+		name := "pull-requests: " + pattern
+		if pattern != "**" && pattern != "*" {
+			c.unsupported(name, "", "source branch filter",
+				fmt.Sprintf("Bitbucket runs this pipeline for pull requests from branches matching %s; the workflow runs for all pull requests", pattern))
+		}
+		c.convert(name, "bitbucket-pull-requests-"+fileSlug(pattern),
+			map[string]interface{}{"pull_request": nil},
+			asMap(pipelines["pull-requests"])[pattern])
+	}
+	for _, custom := range sortedKeys(asMap(pipelines["custom"])) {
+		c.convert("custom: "+custom, "bitbucket-custom-"+fileSlug(custom),
+			map[string]interface{}{"workflow_dispatch": nil},
+			asMap(pipelines["custom"])[custom])
+	}
+	return c.workflows, c.unsupportedFeatures, nil
+}
+
+// pipelineConverter translates the pipelines of one configuration file.
+type pipelineConverter struct {
+	root                map[string]interface{}
+	clone               map[string]interface{}
+	files               map[string]bool
+	workflows           []convertedWorkflow
+	unsupportedFeatures []data.UnsupportedPipelineFeature
+
+	// State of the pipeline being converted.
+	pipeline     string
+	jobs         []actionsNamedJob
+	jobIDs       map[string]bool
+	artifacts    bool // An earlier step uploaded artifacts
+	dispatchVars []string
+}
+
+func (c *pipelineConverter) unsupported(pipeline, step, feature, detail string) {
+	c.unsupportedFeatures = append(c.unsupportedFeatures, data.UnsupportedPipelineFeature{
+		Pipeline: pipeline,
+		Step:     step,
+		Feature:  feature,
+		Detail:   detail,
+	})
+}
+
+// convert translates the items of one pipeline into a workflow named file.
+func (c *pipelineConverter) convert(pipeline, file string, on map[string]interface{}, items interface{}) {
+	c.pipeline = pipeline
+	c.jobs = nil
+	c.jobIDs = make(map[string]bool)
+	c.artifacts = false
+	c.dispatchVars = nil
+
+	if shared := asMap(items); shared != nil && shared["import"] != nil {
+		c.unsupported(pipeline, "", "import", fmt.Sprintf("the pipeline is imported from %s; convert the shared pipeline's repository instead", asString(shared["import"])))
+		return
+	}
+
+	var previous []string
+	for _, item := range asList(items) {
+		previous = c.convertItem(asMap(item), previous, "")
+	}
+	if len(c.jobs) == 0 {
+		return
+	}
+
+	workflow := actionsWorkflow{Name: "Bitbucket " + pipeline, On: on, Jobs: c.jobs}
+	if len(c.dispatchVars) > 0 {
+		inputs := make(map[string]interface{})
+		for _, name := range c.dispatchVars {
+			inputs[name] = c.dispatchInput(name, items)
+		}
+		workflow.On = map[string]interface{}{"workflow_dispatch": map[string]interface{}{"inputs": inputs}}
+	}
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "# Converted from the %q pipeline of %s by gh-bbc-exporter.\n"+
+		"# This is a best-effort stub: review it before committing it to .github/workflows.\n", pipeline, pipelinesConfigFile)
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(workflow); err != nil {
+		c.unsupported(pipeline, "", "conversion", err.Error())
+		return
+	}
+
+	name := file
+	for i := 2; c.files[name]; i++ {
+		name = fmt.Sprintf("%s-%d", file, i)
+	}
+	c.files[name] = true
+	c.workflows = append(c.workflows, convertedWorkflow{file: name + ".yml", content: content.Bytes()})
+}
+
+// convertItem converts a step, parallel group, stage or variables entry
+// whose jobs need previous, and returns the jobs later items need.
+func (c *pipelineConverter) convertItem(item map[string]interface{}, previous []string, environment string) []string {
+	switch {
+	case item["step"] != nil:
+		return []string{c.convertStep(asMap(item["step"]), previous, environment)}
+	case item["parallel"] != nil:
+		steps := asList(item["parallel"])
+		if group := asMap(item["parallel"]); group != nil {
+			steps = asList(group["steps"])
+		}
+		var ids []string
+		for _, step := range steps {
+			ids = append(ids, c.convertItem(asMap(step), previous, environment)...)
+		}
+		return ids
+	case item["stage"] != nil:
+		stage := asMap(item["stage"])
+		name := asString(stage["name"])
+		if stage["trigger"] != nil || stage["condition"] != nil {
+			c.unsupported(c.pipeline, name, "stage trigger or condition",
+				"stage triggers and conditions are not translated; use environment protection rules or job conditions")
+		}
+		for _, step := range asList(stage["steps"]) {
+			previous = c.convertItem(asMap(step), previous, asString(stage["deployment"]))
+		}
+		return previous
+	case item["variables"] != nil:
+		for _, variable := range asList(item["variables"]) {
+			if name := asString(asMap(variable)["name"]); name != "" {
+				c.dispatchVars = append(c.dispatchVars, name)
+			}
+		}
+		return previous
+	}
+	return previous
+}
+
+// dispatchInput returns the workflow_dispatch input of a custom pipeline
+// variable.
+func (c *pipelineConverter) dispatchInput(name string, items interface{}) map[string]interface{} {
+	input := map[string]interface{}{"required": false, "type": "string"}
+	for _, item := range asList(items) {
+		for _, variable := range asList(asMap(item)["variables"]) {
+			definition := asMap(variable)
+			if asString(definition["name"]) != name {
+				continue
+			}
+			if value := asString(definition["default"]); value != "" {
+				input["default"] = value
+			}
+			if description := asString(definition["description"]); description != "" {
+				input["description"] = description
+			}
+			if allowed := asList(definition["allowed-values"]); len(allowed) > 0 {
+				var options []string
+				for _, value := range allowed {
+					options = append(options, asString(value))
+				}
+				input["type"] = "choice"
+				input["options"] = options
+			}
+		}
+	}
+	return input
+}
+
+// convertStep converts a step into a job that needs previous and returns its
+// ID.
+func (c *pipelineConverter) convertStep(step map[string]interface{}, previous []string, environment string) string {
+	name := asString(step["name"])
+	if name == "" {
+		name = fmt.Sprintf("Step %d", len(c.jobs)+1)
+	}
+	id := c.jobID(name)
+	job := actionsJob{Name: name, Needs: previous, RunsOn: defaultRunner}
+
+	if labels := asList(step["runs-on"]); len(labels) > 0 || asString(step["runs-on"]) != "" {
+		if len(labels) == 0 {
+			labels = []interface{}{step["runs-on"]}
+		}
+		var runsOn []string
+		for _, label := range labels {
+			runsOn = append(runsOn, strings.ReplaceAll(asString(label), "self.hosted", "self-hosted"))
+		}
+		job.RunsOn = runsOn
+	}
+	if size := asString(step["size"]); size != "" && size != "1x" {
+		c.unsupported(c.pipeline, name, "size", fmt.Sprintf("the step runs with size %s; use a larger GitHub-hosted runner if needed", size))
+	}
+
+	maxTime := step["max-time"]
+	if maxTime == nil {
+		maxTime = asMap(c.root["options"])["max-time"]
+	}
+	if minutes, err := strconv.Atoi(asString(maxTime)); err == nil && minutes > 0 {
+		job.TimeoutMinutes = minutes
+	}
+
+	if deployment := asString(step["deployment"]); deployment != "" {
+		environment = deployment
+	}
+	job.Environment = environment
+	if asString(step["trigger"]) == "manual" {
+		c.unsupported(c.pipeline, name, "manual trigger",
+			"GitHub Actions jobs cannot wait for a manual start; require reviewers on the job's environment instead")
+	}
+	if step["condition"] != nil {
+		c.unsupported(c.pipeline, name, "condition",
+			"changeset conditions are not translated; add a paths filter to the workflow or a job condition")
+	}
+	if step["oidc"] == true {
+		job.Permissions = map[string]string{"contents": "read", "id-token": "write"}
+	}
+	if step["fail-fast"] != nil {
+		c.unsupported(c.pipeline, name, "fail-fast", "fail-fast of parallel steps is not translated")
+	}
+
+	job.Container = c.container(step["image"], name)
+	if job.Container == nil {
+		job.Container = c.container(c.root["image"], name)
+	}
+	job.Services = c.services(asList(step["services"]), name)
+
+	clone := c.clone
+	if step["clone"] != nil {
+		clone = asMap(step["clone"])
+	}
+	if clone == nil || clone["enabled"] != false {
+		checkout := actionsStep{Uses: "actions/checkout@v4"}
+		with := make(map[string]interface{})
+		if depth := asString(clone["depth"]); depth == "full" {
+			with["fetch-depth"] = 0
+		} else if value, err := strconv.Atoi(depth); err == nil {
+			with["fetch-depth"] = value
+		}
+		if clone["lfs"] == true {
+			with["lfs"] = true
+		}
+		if len(with) > 0 {
+			checkout.With = with
+		}
+		job.Steps = append(job.Steps, checkout)
+	}
+
+	artifacts := asList(step["artifacts"])
+	download := true
+	if definition := asMap(step["artifacts"]); definition != nil {
+		artifacts = asList(definition["paths"])
+		download = definition["download"] != false
+	}
+	if c.artifacts && download {
+		job.Steps = append(job.Steps, actionsStep{
+			Name: "Download artifacts of earlier steps",
+			Uses: "actions/download-artifact@v4",
+			With: map[string]interface{}{"merge-multiple": true, "path": "."},
+		})
+	}
+
+	for _, cache := range asList(step["caches"]) {
+		if cacheStep, ok := c.cache(asString(cache), name); ok {
+			job.Steps = append(job.Steps, cacheStep)
+		}
+	}
+
+	var scripts []string
+	scripts = append(scripts, c.scriptSteps(&job, asList(step["script"]), name, "")...)
+	if afterScript := asList(step["after-script"]); len(afterScript) > 0 {
+		scripts = append(scripts, c.scriptSteps(&job, afterScript, name, "always()")...)
+	}
+	job.Env = c.variables(scripts, name)
+
+	if len(artifacts) > 0 {
+		var paths []string
+		for _, artifact := range artifacts {
+			paths = append(paths, asString(artifact))
+		}
+		job.Steps = append(job.Steps, actionsStep{
+			Name: "Upload artifacts",
+			Uses: "actions/upload-artifact@v4",
+			With: map[string]interface{}{"name": id, "path": strings.Join(paths, "\n")},
+		})
+		c.artifacts = true
+	}
+
+	c.jobs = append(c.jobs, actionsNamedJob{id: id, job: job})
+	return id
+}
+
+// scriptSteps appends the run steps of a script to job and returns the
+// script lines. Pipes become failing placeholder steps.
+func (c *pipelineConverter) scriptSteps(job *actionsJob, script []interface{}, stepName, condition string) []string {
+	var lines, all []string
+	flush := func() {
+		if len(lines) == 0 {
+			return
+		}
+		run := actionsStep{Run: strings.Join(lines, "\n"), If: condition}
+		if condition != "" {
+			run.Name = "After script"
+		}
+		job.Steps = append(job.Steps, run)
+		lines = nil
+	}
+	for _, command := range script {
+		if pipe := asMap(command); pipe != nil && pipe["pipe"] != nil {
+			flush()
+			name := asString(pipe["pipe"])
+			c.unsupported(c.pipeline, stepName, "pipe",
+				fmt.Sprintf("the pipe %s has no automatic translation; replace it with an equivalent action", name))
+			job.Steps = append(job.Steps, actionsStep{
+				Name: "Bitbucket pipe " + name,
+				If:   condition,
+				Run:  fmt.Sprintf("echo \"::error::Replace the Bitbucket pipe %s with an equivalent action\"\nexit 1", name),
+			})
+			continue
+		}
+		line := asString(command)
+		lines = append(lines, line)
+		all = append(all, line)
+	}
+	flush()
+	return all
+}
+
+// variables defines the Bitbucket variables a step's scripts use from the
+// GitHub context.
+func (c *pipelineConverter) variables(scripts []string, stepName string) map[string]string {
+	env := make(map[string]string)
+	missing := make(map[string]bool)
+	for _, line := range scripts {
+		for _, match := range bitbucketVariablePattern.FindAllStringSubmatch(line, -1) {
+			variable := match[1]
+			if value, ok := bitbucketVariables[variable]; ok {
+				env[variable] = value
+			} else if !missing[variable] {
+				missing[variable] = true
+				c.unsupported(c.pipeline, stepName, "variable "+variable, "the variable has no GitHub Actions equivalent")
+			}
+		}
+	}
+	if len(env) == 0 {
+		return nil
+	}
+	return env
+}
+
+// container returns the job container of a Bitbucket image, which is a name
+// or a map with name and credentials.
+func (c *pipelineConverter) container(image interface{}, stepName string) *actionsContainer {
+	definition := asMap(image)
+	if definition == nil {
+		if name := asString(image); name != "" {
+			return &actionsContainer{Image: name}
+		}
+		return nil
+	}
+	if asString(definition["name"]) == "" {
+		return nil
+	}
+	container := &actionsContainer{Image: asString(definition["name"])}
+	if definition["username"] != nil || definition["password"] != nil {
+		container.Credentials = map[string]string{
+			"username": secretReference(asString(definition["username"])),
+			"password": secretReference(asString(definition["password"])),
+		}
+	}
+	if definition["aws"] != nil {
+		c.unsupported(c.pipeline, stepName, "image aws credentials",
+			"images pulled from Amazon ECR need a login step, for example aws-actions/amazon-ecr-login")
+	}
+	return container
+}
+
+// services returns the service containers of a step from the service
+// definitions.
+func (c *pipelineConverter) services(names []interface{}, stepName string) map[string]actionsContainer {
+	definitions := asMap(asMap(c.root["definitions"])["services"])
+	services := make(map[string]actionsContainer)
+	for _, value := range names {
+		name := asString(value)
+		if name == "docker" {
+			c.unsupported(c.pipeline, stepName, "docker service",
+				"GitHub-hosted runners have Docker installed; jobs running in a container need the Docker socket mounted")
+			continue
+		}
+		definition := asMap(definitions[name])
+		service := c.container(definition["image"], stepName)
+		if service == nil {
+			c.unsupported(c.pipeline, stepName, "service "+name, "the service has no image definition")
+			continue
+		}
+		if variables := asMap(definition["variables"]); len(variables) > 0 {
+			service.Env = make(map[string]string)
+			for key, variable := range variables {
+				service.Env[key] = asString(variable)
+			}
+		}
+		services[name] = *service
+	}
+	if len(services) == 0 {
+		return nil
+	}
+	return services
+}
+
+// cache returns the actions/cache step of a predefined or custom cache.
+func (c *pipelineConverter) cache(name, stepName string) (actionsStep, bool) {
+	cachePath, ok := predefinedCaches[name]
+	var keyFiles []string
+	if definition, defined := asMap(asMap(c.root["definitions"])["caches"])[name]; defined {
+		cachePath, ok = asString(definition), true
+		if custom := asMap(definition); custom != nil {
+			cachePath = asString(custom["path"])
+			for _, file := range asList(asMap(custom["key"])["files"]) {
+				keyFiles = append(keyFiles, fmt.Sprintf("'%s'", asString(file)))
+			}
+		}
+	}
+	if !ok || cachePath == "" {
+		c.unsupported(c.pipeline, stepName, "cache "+name, "the cache has no GitHub Actions equivalent; the step runs without it")
+		return actionsStep{}, false
+	}
+
+	key := fmt.Sprintf("${{ runner.os }}-%s-${{ github.sha }}", name)
+	if len(keyFiles) > 0 {
+		key = fmt.Sprintf("${{ runner.os }}-%s-${{ hashFiles(%s) }}", name, strings.Join(keyFiles, ", "))
+	}
+	return actionsStep{
+		Name: "Cache " + name,
+		Uses: "actions/cache@v4",
+		With: map[string]interface{}{
+			"path":         cachePath,
+			"key":          key,
+			"restore-keys": fmt.Sprintf("${{ runner.os }}-%s-", name),
+		},
+	}, true
+}
+
+// jobID returns a unique job ID for a step name.
+func (c *pipelineConverter) jobID(name string) string {
+	id := fileSlug(name)
+	if id[0] >= '0' && id[0] <= '9' {
+		id = "step-" + id
+	}
+	unique := id
+	for i := 2; c.jobIDs[unique]; i++ {
+		unique = fmt.Sprintf("%s-%d", id, i)
+	}
+	c.jobIDs[unique] = true
+	return unique
+}
+
+// secretReference turns a $VARIABLE credential into a secrets reference.
+func secretReference(value string) string {
+	if match := credentialVariable.FindStringSubmatch(value); match != nil {
+		return fmt.Sprintf("${{ secrets.%s }}", match[1])
+	}
+	return value
+}
+
+// fileSlug returns a workflow file name part for a branch pattern or custom
+// pipeline name.
+func fileSlug(pattern string) string {
+	slug := strings.Trim(unsafeJobID.ReplaceAllString(strings.ToLower(pattern), "-"), "-")
+	if slug == "" {
+		return "all"
+	}
+	return slug
+}
+
+func asMap(value interface{}) map[string]interface{} {
+	m, _ := value.(map[string]interface{})
+	return m
+}
+
+func asList(value interface{}) []interface{} {
+	list, _ := value.([]interface{})
+	return list
+}
+
+func asString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// actionsWorkflow is a GitHub Actions workflow file.
+type actionsWorkflow struct {
+	Name string                 `yaml:"name"`
+	On   map[string]interface{} `yaml:"on"`
+	Jobs actionsJobs            `yaml:"jobs"`
+}
+
+type actionsJob struct {
+	Name           string                      `yaml:"name"`
+	Needs          []string                    `yaml:"needs,omitempty"`
+	RunsOn         interface{}                 `yaml:"runs-on"`
+	Environment    string                      `yaml:"environment,omitempty"`
+	TimeoutMinutes int                         `yaml:"timeout-minutes,omitempty"`
+	Permissions    map[string]string           `yaml:"permissions,omitempty"`
+	Container      *actionsContainer           `yaml:"container,omitempty"`
+	Services       map[string]actionsContainer `yaml:"services,omitempty"`
+	Env            map[string]string           `yaml:"env,omitempty"`
+	Steps          []actionsStep               `yaml:"steps"`
+}
+
+type actionsContainer struct {
+	Image       string            `yaml:"image"`
+	Credentials map[string]string `yaml:"credentials,omitempty"`
+	Env         map[string]string `yaml:"env,omitempty"`
+}
+
+type actionsStep struct {
+	Name string                 `yaml:"name,omitempty"`
+	If   string                 `yaml:"if,omitempty"`
+	Uses string                 `yaml:"uses,omitempty"`
+	With map[string]interface{} `yaml:"with,omitempty"`
+	Run  string                 `yaml:"run,omitempty"`
+}
+
+type actionsNamedJob struct {
+	id  string
+	job actionsJob
+}
+
+// actionsJobs keeps the jobs of a workflow in pipeline order.
+type actionsJobs []actionsNamedJob
+
+func (jobs actionsJobs) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, named := range jobs {
+		var value yaml.Node
+		if err := value.Encode(named.job); err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: named.id}, &value)
+	}
+	return node, nil
+}
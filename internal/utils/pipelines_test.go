@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+const samplePipelines = `
+image: node:20
+definitions:
+  caches:
+    npm:
+      key:
+        files:
+          - package-lock.json
+      path: ~/.npm
+  services:
+    postgres:
+      image:
+        name: postgres:16
+        username: $REGISTRY_USER
+        password: $REGISTRY_PASSWORD
+      variables:
+        POSTGRES_PASSWORD: secret
+  steps:
+    - step: &build
+        name: Build
+        caches:
+          - npm
+          - node
+        script:
+          - npm ci
+          - npm run build -- --sha $BITBUCKET_COMMIT
+        artifacts:
+          - dist/**
+pipelines:
+  default:
+    - step: *build
+  branches:
+    main:
+      - step: *build
+      - parallel:
+          - step:
+              name: Unit tests
+              services:
+                - postgres
+              script:
+                - npm test
+          - step:
+              name: Lint
+              script:
+                - npm run lint
+      - step:
+          name: Deploy
+          deployment: production
+          trigger: manual
+          oidc: true
+          max-time: 15
+          script:
+            - pipe: atlassian/aws-s3-deploy:1.1.0
+              variables:
+                S3_BUCKET: my-bucket
+            - echo $BITBUCKET_DEPLOY_KEY
+          after-script:
+            - echo done
+  custom:
+    release:
+      - variables:
+          - name: VERSION
+            default: "1.0"
+      - step:
+          name: Release
+          runs-on:
+            - self.hosted
+            - linux
+          clone:
+            depth: full
+          script:
+            - ./release.sh $VERSION
+`
+
+// workflowByFile returns the parsed workflow of a converted file.
+func workflowByFile(t *testing.T, workflows []convertedWorkflow, file string) map[string]interface{} {
+	t.Helper()
+	for _, workflow := range workflows {
+		if workflow.file == file {
+			var parsed map[string]interface{}
+			require.NoError(t, yaml.Unmarshal(workflow.content, &parsed))
+			return parsed
+		}
+	}
+	t.Fatalf("workflow %s not converted", file)
+	return nil
+}
+
+func TestConvertPipelines(t *testing.T) {
+	workflows, unsupported, err := convertPipelines([]byte(samplePipelines))
+	require.NoError(t, err)
+
+	var files []string
+	for _, workflow := range workflows {
+		files = append(files, workflow.file)
+	}
+	assert.Equal(t, []string{
+		"bitbucket-default.yml",
+		"bitbucket-branches-main.yml",
+		"bitbucket-custom-release.yml",
+	}, files)
+	assert.Contains(t, string(workflows[0].content), "# Converted from the \"default\" pipeline")
+
+	defaultWorkflow := workflowByFile(t, workflows, "bitbucket-default.yml")
+	assert.Equal(t, map[string]interface{}{"push": map[string]interface{}{"branches-ignore": []interface{}{"main"}}}, defaultWorkflow["on"])
+	build := asMap(asMap(defaultWorkflow["jobs"])["build"])
+	require.NotNil(t, build)
+	assert.Equal(t, map[string]interface{}{"image": "node:20"}, build["container"])
+	assert.Equal(t, map[string]interface{}{"BITBUCKET_COMMIT": "${{ github.sha }}"}, build["env"])
+	steps := asList(build["steps"])
+	require.Len(t, steps, 5)
+	assert.Equal(t, "actions/checkout@v4", asMap(steps[0])["uses"])
+	assert.Equal(t, "~/.npm", asMap(asMap(steps[1])["with"])["path"])
+	assert.Equal(t, "${{ runner.os }}-npm-${{ hashFiles('package-lock.json') }}", asMap(asMap(steps[1])["with"])["key"])
+	assert.Equal(t, "node_modules", asMap(asMap(steps[2])["with"])["path"])
+	assert.Equal(t, "npm ci\nnpm run build -- --sha $BITBUCKET_COMMIT", asMap(steps[3])["run"])
+	assert.Equal(t, "actions/upload-artifact@v4", asMap(steps[4])["uses"])
+
+	mainWorkflow := workflowByFile(t, workflows, "bitbucket-branches-main.yml")
+	jobs := asMap(mainWorkflow["jobs"])
+	assert.Len(t, jobs, 4)
+	unit := asMap(jobs["unit-tests"])
+	assert.Equal(t, []interface{}{"build"}, unit["needs"])
+	assert.Equal(t, "actions/download-artifact@v4", asMap(asList(unit["steps"])[1])["uses"])
+	assert.Equal(t, map[string]interface{}{
+		"image":       "postgres:16",
+		"credentials": map[string]interface{}{"username": "${{ secrets.REGISTRY_USER }}", "password": "${{ secrets.REGISTRY_PASSWORD }}"},
+		"env":         map[string]interface{}{"POSTGRES_PASSWORD": "secret"},
+	}, asMap(unit["services"])["postgres"])
+	assert.Equal(t, []interface{}{"build"}, asMap(jobs["lint"])["needs"])
+
+	deploy := asMap(jobs["deploy"])
+	assert.Equal(t, []interface{}{"unit-tests", "lint"}, deploy["needs"])
+	assert.Equal(t, "production", deploy["environment"])
+	assert.Equal(t, 15, deploy["timeout-minutes"])
+	assert.Equal(t, map[string]interface{}{"contents": "read", "id-token": "write"}, deploy["permissions"])
+	deploySteps := asList(deploy["steps"])
+	assert.Equal(t, "Bitbucket pipe atlassian/aws-s3-deploy:1.1.0", asMap(deploySteps[2])["name"])
+	assert.Equal(t, "always()", asMap(deploySteps[len(deploySteps)-1])["if"])
+
+	custom := workflowByFile(t, workflows, "bitbucket-custom-release.yml")
+	assert.Equal(t, map[string]interface{}{"workflow_dispatch": map[string]interface{}{"inputs": map[string]interface{}{
+		"VERSION": map[string]interface{}{"default": "1.0", "required": false, "type": "string"},
+	}}}, custom["on"])
+	release := asMap(asMap(custom["jobs"])["release"])
+	assert.Equal(t, []interface{}{"self-hosted", "linux"}, release["runs-on"])
+	assert.Equal(t, map[string]interface{}{"fetch-depth": 0}, asMap(asList(release["steps"])[0])["with"])
+
+	features := map[string]bool{}
+	for _, feature := range unsupported {
+		features[feature.Pipeline+"/"+feature.Step+"/"+feature.Feature] = true
+	}
+	assert.Equal(t, map[string]bool{
+		"branches: main/Deploy/manual trigger":                true,
+		"branches: main/Deploy/pipe":                          true,
+		"branches: main/Deploy/variable BITBUCKET_DEPLOY_KEY": true,
+	}, features)
+}
+
+func TestConvertPipelinesInvalidConfig(t *testing.T) {
+	_, _, err := convertPipelines([]byte("pipelines: [\n"))
+	assert.Error(t, err)
+
+	_, _, err = convertPipelines([]byte("image: node:20\n"))
+	assert.ErrorContains(t, err, "no pipelines section")
+
+	workflows, unsupported, err := convertPipelines([]byte("pipelines:\n  default:\n    import: shared:main:build\n"))
+	require.NoError(t, err)
+	assert.Empty(t, workflows)
+	require.Len(t, unsupported, 1)
+	assert.Equal(t, "import", unsupported[0].Feature)
+}
+
+func TestWritePipelineWorkflows(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(workDir, pipelinesConfigFile), []byte(samplePipelines), 0644))
+	runGit(t, workDir, "add", "-A")
+	runGit(t, workDir, "commit", "-m", "Add pipeline")
+	runGit(t, t.TempDir(), "clone", "--mirror", workDir, filepath.Join(exporter.outputDir, "repositories", "ws", "repo.git"))
+
+	exporter.collectPipelineWorkflows("ws", "repo")
+	assert.Empty(t, exporter.pipelineConversions, "disabled by default")
+
+	exporter.SetConvertPipelines(true)
+	exporter.collectPipelineWorkflows("ws", "repo")
+	exporter.collectPipelineWorkflows("ws", "missing")
+	require.NoError(t, exporter.writePipelineWorkflows())
+
+	var report data.PipelinesConversionReport
+	readReportFile(t, filepath.Join(exporter.outputDir, pipelinesConversionFile), &report)
+	require.Len(t, report.Repositories, 1)
+	assert.Equal(t, "ws/repo", report.Repositories[0].SourceRepository)
+	assert.Contains(t, report.Repositories[0].Workflows, "migration-notes/repo/.github/workflows/bitbucket-default.yml")
+	assert.Len(t, report.Repositories[0].Unsupported, 3)
+	assert.Equal(t, 3, exporter.report.Counts.ConvertedWorkflows)
+	assert.Equal(t, 3, exporter.report.Counts.UnsupportedPipelineFeatures)
+
+	content, err := os.ReadFile(filepath.Join(exporter.outputDir, migrationNotesDir, "repo", ".github", "workflows", "bitbucket-custom-release.yml"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "workflow_dispatch:")
+	assert.True(t, sidecarPaths[pipelinesConversionFile])
+}
+
+func TestConvertPipelinesConflictsWithSkipGit(t *testing.T) {
+	err := ValidateGitContentOptions(&data.CmdExportFlags{SkipGit: true, ConvertPipelines: true})
+	assert.ErrorContains(t, err, "--convert-pipelines")
+}
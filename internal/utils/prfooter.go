@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// prFooterFields are the values available to --pr-footer-template.
+type prFooterFields struct {
+	ID             int    // Bitbucket pull request number
+	Title          string // Pull request title
+	URL            string // Pull request page on Bitbucket
+	Workspace      string
+	Repository     string
+	State          string // OPEN, MERGED, DECLINED or SUPERSEDED
+	Author         string // Display name of the original author
+	AuthorNickname string
+	CreatedAt      string // RFC 3339 creation time on Bitbucket
+	Date           string // Export date as YYYY-MM-DD
+}
+
+// commentFooterFields are the values available to --comment-footer-template.
+type commentFooterFields struct {
+	ID             int    // Bitbucket comment ID
+	PullRequest    int    // Bitbucket pull request number
+	URL            string // Comment on the pull request page on Bitbucket
+	Workspace      string
+	Repository     string
+	Path           string // File of an inline comment; empty for general comments
+	Author         string // Display name of the original author
+	AuthorNickname string
+	CreatedAt      string // RFC 3339 creation time on Bitbucket
+	UpdatedAt      string // RFC 3339 time of the last edit on Bitbucket
+	Date           string // Export date as YYYY-MM-DD
+}
+
+var (
+	prFooterSample = prFooterFields{ID: 1, Title: "Title", URL: "https://bitbucket.org/workspace/repo/pull-requests/1",
+		Workspace: "workspace", Repository: "repo", State: "MERGED", Author: "Author",
+		AuthorNickname: "author", CreatedAt: "2024-01-01T00:00:00Z", Date: "2024-01-01"}
+	commentFooterSample = commentFooterFields{ID: 1, PullRequest: 1,
+		URL:       "https://bitbucket.org/workspace/repo/pull-requests/1#comment-1",
+		Workspace: "workspace", Repository: "repo", Path: "main.go", Author: "Author", AuthorNickname: "author",
+		CreatedAt: "2024-01-01T00:00:00Z", UpdatedAt: "2024-01-01T00:00:00Z", Date: "2024-01-01"}
+)
+
+// ParsePRFooterTemplate parses a --pr-footer-template value and executes it
+// once against sample values, so unknown fields fail before the export
+// starts rather than on the first pull request.
+func ParsePRFooterTemplate(text string) (*template.Template, error) {
+	return parseFooterTemplate("--pr-footer-template", text, prFooterSample)
+}
+
+// ParseCommentFooterTemplate parses a --comment-footer-template value the
+// same way.
+func ParseCommentFooterTemplate(text string) (*template.Template, error) {
+	return parseFooterTemplate("--comment-footer-template", text, commentFooterSample)
+}
+
+func parseFooterTemplate(flag, text string, sample interface{}) (*template.Template, error) {
+	tmpl, err := template.New(strings.TrimPrefix(flag, "--")).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", flag, err)
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, sample); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", flag, err)
+	}
+	return tmpl, nil
+}
+
+// SetPRFooterTemplate appends the rendered template to every exported pull
+// request description. An empty template leaves descriptions unchanged.
+func (c *Client) SetPRFooterTemplate(text string) error {
+	c.prFooter = nil
+	if text == "" {
+		return nil
+	}
+	tmpl, err := ParsePRFooterTemplate(text)
+	if err != nil {
+		return err
+	}
+	c.prFooter = tmpl
+	return nil
+}
+
+// SetCommentFooterTemplate appends the rendered template to every exported
+// pull request comment, for example to keep a link to the original comment.
+// An empty template leaves comments unchanged.
+func (c *Client) SetCommentFooterTemplate(text string) error {
+	c.commentFooter = nil
+	if text == "" {
+		return nil
+	}
+	tmpl, err := ParseCommentFooterTemplate(text)
+	if err != nil {
+		return err
+	}
+	c.commentFooter = tmpl
+	return nil
+}
+
+// prBody returns the pull request description followed by the provenance
+// footer.
+func (c *Client) prBody(description string, pr data.BitbucketPR, workspace, repoSlug string) string {
+	if c.prFooter == nil {
+		return description
+	}
+	fields := prFooterFields{
+		ID:             pr.ID,
+		Title:          pr.Title,
+		URL:            fmt.Sprintf("https://bitbucket.org/%s/%s/pull-requests/%d", workspace, repoSlug, pr.ID),
+		Workspace:      workspace,
+		Repository:     repoSlug,
+		State:          pr.State,
+		Author:         pr.Author.DisplayName,
+		AuthorNickname: pr.Author.Nickname,
+		CreatedAt:      formatDateToZ(pr.CreatedOn),
+		Date:           c.now().UTC().Format(time.DateOnly),
+	}
+	return c.appendFooter(description, c.prFooter, fields,
+		zap.String("repository", workspace+"/"+repoSlug), zap.Int("pr_id", pr.ID))
+}
+
+// commentBodyWithFooter returns a comment body followed by the provenance
+// footer.
+func (c *Client) commentBodyWithFooter(body string, comment data.BitbucketComment, workspace, repoSlug string, prID int) string {
+	if c.commentFooter == nil {
+		return body
+	}
+	fields := commentFooterFields{
+		ID:          comment.ID,
+		PullRequest: prID,
+		URL: fmt.Sprintf("https://bitbucket.org/%s/%s/pull-requests/%d#comment-%d",
+			workspace, repoSlug, prID, comment.ID),
+		Workspace:      workspace,
+		Repository:     repoSlug,
+		Author:         comment.User.DisplayName,
+		AuthorNickname: comment.User.Nickname,
+		CreatedAt:      formatDateToZ(comment.CreatedOn),
+		UpdatedAt:      formatDateToZ(comment.UpdatedOn),
+		Date:           c.now().UTC().Format(time.DateOnly),
+	}
+	if comment.Inline != nil {
+		fields.Path = comment.Inline.Path
+	}
+	return c.appendFooter(body, c.commentFooter, fields,
+		zap.String("repository", workspace+"/"+repoSlug), zap.Int("pr_id", prID), zap.Int("comment_id", comment.ID))
+}
+
+// appendFooter renders tmpl and appends it to body, separated by a blank
+// line. A footer that fails to render is left out and logged.
+func (c *Client) appendFooter(body string, tmpl *template.Template, fields interface{}, logFields ...zap.Field) string {
+	var footer bytes.Buffer
+	if err := tmpl.Execute(&footer, fields); err != nil {
+		c.logger.Warn("Failed to render "+tmpl.Name(), append(logFields, zap.Error(err))...)
+		return body
+	}
+	if strings.TrimSpace(body) == "" {
+		return footer.String()
+	}
+	return strings.TrimRight(body, "\n") + "\n\n" + footer.String()
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPRBodyFooter(t *testing.T) {
+	client := &Client{logger: zap.NewNop(), clock: FixedClock{Time: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}}
+	require.NoError(t, client.SetPRFooterTemplate(
+		"_Migrated from Bitbucket PR [#{{.ID}}]({{.URL}}) on {{.Date}}; original author {{.Author}}._"))
+	pr := data.BitbucketPR{ID: 42, Author: data.BitbucketPRUser{DisplayName: "Alice Smith"}}
+	footer := "_Migrated from Bitbucket PR [#42](https://bitbucket.org/workspace/repo/pull-requests/42) on 2024-06-01; original author Alice Smith._"
+
+	assert.Equal(t, "Fixes the build.\n\n"+footer, client.prBody("Fixes the build.\n", pr, "workspace", "repo"))
+	assert.Equal(t, footer, client.prBody("", pr, "workspace", "repo"))
+
+	require.NoError(t, client.SetPRFooterTemplate(""))
+	assert.Equal(t, "Fixes the build.\n", client.prBody("Fixes the build.\n", pr, "workspace", "repo"))
+}
+
+func TestParsePRFooterTemplate(t *testing.T) {
+	_, err := ParsePRFooterTemplate("Migrated by {{.Author}} in {{.Repository}}")
+	assert.NoError(t, err)
+
+	_, err = ParsePRFooterTemplate("{{.ID")
+	assert.ErrorContains(t, err, "invalid --pr-footer-template")
+
+	_, err = ParsePRFooterTemplate("{{.Reviewer}}")
+	assert.ErrorContains(t, err, "invalid --pr-footer-template", "unknown fields fail before the export")
+}
+
+func TestGetPullRequestsAppendsFooter(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(t, w, []byte(`{"values": [{"id": 7, "title": "Add feature", "description": "Details",
+			"state": "OPEN", "created_on": "2024-05-01T10:00:00+00:00",
+			"author": {"uuid": "{alice}", "display_name": "Alice"}}], "next": null}`))
+	}))
+	defer testServer.Close()
+
+	client := &Client{baseURL: testServer.URL, httpClient: testServer.Client(), logger: zap.NewNop(),
+		commitSHACache: make(map[string]string), skipCommitLookup: true}
+	require.NoError(t, client.SetPRFooterTemplate("Bitbucket PR {{.ID}} opened {{.CreatedAt}} by {{.Author}}"))
+
+	prs, err := client.GetPullRequests("workspace", "repo", false, "")
+
+	require.NoError(t, err)
+	require.Len(t, prs, 1)
+	assert.Equal(t, "Details\n\nBitbucket PR 7 opened 2024-05-01T10:00:00Z by Alice", prs[0].Body)
+}
+
+func TestValidateExportFlagsPRFooterTemplate(t *testing.T) {
+	flags := &data.CmdExportFlags{
+		Workspace:            "workspace",
+		Repository:           "repo",
+		BitbucketAccessToken: "token",
+		PRFooterTemplate:     "{{.Missing}}",
+	}
+	assert.ErrorContains(t, ValidateExportFlags(flags), "invalid --pr-footer-template")
+
+	flags.PRFooterTemplate = "Migrated from Bitbucket PR {{.ID}}"
+	assert.NoError(t, ValidateExportFlags(flags))
+}
+
+func TestCommentBodyWithFooter(t *testing.T) {
+	client := &Client{logger: zap.NewNop()}
+	require.NoError(t, client.SetCommentFooterTemplate(
+		"_Originally posted by {{.Author}} at {{.URL}} on {{.CreatedAt}}{{if .Path}} on `{{.Path}}`{{end}}._"))
+	comment := data.BitbucketComment{ID: 99, CreatedOn: "2024-03-01T08:30:00.123456+00:00",
+		User: data.BitbucketPRUser{DisplayName: "Bob"}}
+
+	assert.Equal(t, "LGTM\n\n_Originally posted by Bob at https://bitbucket.org/workspace/repo/pull-requests/5#comment-99 on 2024-03-01T08:30:00Z._",
+		client.commentBodyWithFooter("LGTM", comment, "workspace", "repo", 5))
+
+	comment.Inline = &data.Inline{Path: "main.go"}
+	assert.Contains(t, client.commentBodyWithFooter("Typo", comment, "workspace", "repo", 5), " on `main.go`._")
+
+	require.NoError(t, client.SetCommentFooterTemplate(""))
+	assert.Equal(t, "LGTM", client.commentBodyWithFooter("LGTM", comment, "workspace", "repo", 5))
+}
+
+func TestGetPullRequestCommentsAppendsFooter(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(t, w, []byte(`{"values": [
+			{"id": 1, "created_on": "2024-01-01T00:00:00Z", "content": {"raw": "general"}, "user": {"uuid": "{a}"}},
+			{"id": 2, "created_on": "2024-01-01T00:00:00Z", "content": {"raw": "inline"}, "user": {"uuid": "{a}"},
+			 "inline": {"path": "main.go", "to": 3}}], "next": null}`))
+	}))
+	defer testServer.Close()
+
+	client := &Client{baseURL: testServer.URL, httpClient: testServer.Client(), logger: zap.NewNop(),
+		commitSHACache: make(map[string]string), skipCommitLookup: true}
+	require.NoError(t, client.SetCommentFooterTemplate("Comment {{.ID}} on PR {{.PullRequest}}"))
+
+	regular, review, err := client.GetPullRequestComments("workspace", "repo",
+		[]data.PullRequest{{URL: "https://bitbucket.org/workspace/repo/pull/4", Head: data.PRBranch{SHA: "abc1234"}}})
+
+	require.NoError(t, err)
+	require.Len(t, regular, 1)
+	require.Len(t, review, 1)
+	assert.Equal(t, "general\n\nComment 1 on PR 4", regular[0].Body)
+	assert.Equal(t, "inline\n\nComment 2 on PR 4", review[0].Body)
+	assert.NotContains(t, review[0].DiffHunk, "Comment 2", "the footer is not part of the diff hunk")
+}
+
+func TestParseCommentFooterTemplate(t *testing.T) {
+	_, err := ParseCommentFooterTemplate("{{.URL}} {{.UpdatedAt}} {{.Path}}")
+	assert.NoError(t, err)
+
+	_, err = ParseCommentFooterTemplate("{{.Title}}")
+	assert.ErrorContains(t, err, "invalid --comment-footer-template")
+}
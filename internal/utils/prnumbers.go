@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
+	"go.uber.org/zap"
+)
+
+const (
+	prNumberMapFile = "pr-number-map.json"
+
+	prNumberMapNote = "GitHub numbers imported pull requests in creation order after the items the repository " +
+		"already has; the numbers are a prediction and differ if other issues or pull requests are created before the import"
+)
+
+// prReferencePattern matches #123 references that GitHub links to an issue
+// or pull request: not part of a word, an HTML entity such as &#123; or a
+// URL fragment.
+var prReferencePattern = regexp.MustCompile(`(^|[^\w&#/])#(\d+)\b`)
+
+// ValidatePRNumberPrediction checks --predict-pr-numbers and
+// --pr-number-offset.
+func ValidatePRNumberPrediction(cmdFlags *data.CmdExportFlags) error {
+	switch {
+	case cmdFlags.PRNumberOffset < 0:
+		return fmt.Errorf("invalid value for --pr-number-offset: %d (must be 0 or more)", cmdFlags.PRNumberOffset)
+	case cmdFlags.PRNumberOffset > 0 && !cmdFlags.PredictPRNumbers:
+		return errors.New("--pr-number-offset requires --predict-pr-numbers")
+	case cmdFlags.PredictPRNumbers && len(cmdFlags.SubdirSplits) > 0:
+		return errors.New("--predict-pr-numbers cannot be combined with --subdir-split")
+	}
+	return nil
+}
+
+// SetPRNumberPrediction predicts the GitHub number of every exported pull
+// request, writes the predictions to pr-number-map.json and rewrites #123
+// references in pull request descriptions and comments to the predicted
+// numbers. offset is the number of issues and pull requests the target
+// repository already has.
+func (e *Exporter) SetPRNumberPrediction(enabled bool, offset int) {
+	e.predictPRNumbers = enabled
+	e.prNumberOffset = offset
+}
+
+// predictGitHubNumbers returns the GitHub number each pull request of a
+// repository is expected to get. The importer creates them in creation
+// order, after the offset items the repository already has.
+func predictGitHubNumbers(urls format.URLs, prs []data.PullRequest, offset int) []data.PRNumber {
+	type created struct {
+		number int
+		at     string
+	}
+	var order []created
+	for _, pr := range prs {
+		if number, err := strconv.Atoi(extractPRNumber(urls, pr.URL)); err == nil {
+			order = append(order, created{number: number, at: pr.CreatedAt})
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].at != order[j].at {
+			return order[i].at < order[j].at
+		}
+		return order[i].number < order[j].number
+	})
+
+	numbers := make([]data.PRNumber, 0, len(order))
+	for i, pr := range order {
+		numbers = append(numbers, data.PRNumber{Bitbucket: pr.number, GitHub: offset + i + 1})
+	}
+	return numbers
+}
+
+// renumberPullRequestReferences predicts the GitHub numbers of the pull
+// requests of each repository and rewrites #123 references in their bodies
+// and comments to them. References to pull requests that are not exported
+// are left alone. In repositories with an issue tracker a bare #123 is an
+// issue, so only "pull request #123" and "PR #123" are rewritten there.
+func (e *Exporter) renumberPullRequestReferences(workspace string, repoSlugs []string,
+	repos map[string]*data.BitbucketRepository, prs []data.PullRequest,
+	issueComments []data.IssueComment, reviewComments []data.PullRequestReviewComment) {
+	e.prNumbers = nil
+	byRepository := make(map[string]map[int]int)
+	hasIssues := make(map[string]bool)
+	repositoryOfPR := make(map[string]string)
+	for _, repoSlug := range repoSlugs {
+		repoURL := e.client.formatURL("repository", workspace, repoSlug)
+		var repoPRs []data.PullRequest
+		for _, pr := range prs {
+			if pr.Repository == repoURL {
+				repoPRs = append(repoPRs, pr)
+				repositoryOfPR[pr.URL] = repoURL
+			}
+		}
+		numbers := predictGitHubNumbers(e.client.sourceURLs(), repoPRs, e.prNumberOffset)
+		mapping := make(map[int]int, len(numbers))
+		for _, number := range numbers {
+			mapping[number.Bitbucket] = number.GitHub
+		}
+		byRepository[repoURL] = mapping
+		hasIssues[repoURL] = repos[repoSlug] != nil && repos[repoSlug].HasIssues
+		e.prNumbers = append(e.prNumbers, data.RepositoryPRNumbers{
+			SourceRepository: workspace + "/" + repoSlug,
+			Offset:           e.prNumberOffset,
+			PullRequests:     numbers,
+		})
+	}
+
+	renumber := func(body, repoURL string) string {
+		mapping := byRepository[repoURL]
+		if len(mapping) == 0 {
+			return body
+		}
+		var out strings.Builder
+		last := 0
+		for _, match := range prReferencePattern.FindAllStringSubmatchIndex(body, -1) {
+			number, err := strconv.Atoi(body[match[4]:match[5]])
+			if err != nil {
+				continue
+			}
+			predicted, ok := mapping[number]
+			if !ok || predicted == number {
+				continue
+			}
+			if hasIssues[repoURL] && !pullRequestPrefix.MatchString(body[:match[4]-1]) {
+				continue
+			}
+			out.WriteString(body[last:match[4]])
+			out.WriteString(strconv.Itoa(predicted))
+			last = match[5]
+			e.report.Counts.RenumberedPRReferences++
+		}
+		out.WriteString(body[last:])
+		return out.String()
+	}
+	for i, pr := range prs {
+		prs[i].Body = renumber(pr.Body, pr.Repository)
+	}
+	for i, comment := range issueComments {
+		issueComments[i].Body = renumber(comment.Body, repositoryOfPR[comment.PullRequest])
+	}
+	for i, comment := range reviewComments {
+		reviewComments[i].Body = renumber(comment.Body, repositoryOfPR[comment.PullRequest])
+	}
+
+	e.logger.Info("Rewrote pull request references to the predicted GitHub numbers",
+		zap.Int("references", e.report.Counts.RenumberedPRReferences))
+}
+
+// writePRNumberMap writes the predicted pull request numbers.
+func (e *Exporter) writePRNumberMap() error {
+	report := data.PRNumberMap{Note: prNumberMapNote, Repositories: e.prNumbers}
+	if report.Repositories == nil {
+		report.Repositories = []data.RepositoryPRNumbers{}
+	}
+	if err := e.writeJSONFile(prNumberMapFile, report); err != nil {
+		return err
+	}
+	e.logger.Info("Wrote predicted GitHub pull request numbers (excluded from import archive)",
+		zap.String("file", prNumberMapFile))
+	return nil
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidatePRNumberPrediction(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   data.CmdExportFlags
+		wantErr string
+	}{
+		{name: "disabled", flags: data.CmdExportFlags{}},
+		{name: "enabled with offset", flags: data.CmdExportFlags{PredictPRNumbers: true, PRNumberOffset: 12}},
+		{name: "negative offset", flags: data.CmdExportFlags{PredictPRNumbers: true, PRNumberOffset: -1},
+			wantErr: "must be 0 or more"},
+		{name: "offset without prediction", flags: data.CmdExportFlags{PRNumberOffset: 3},
+			wantErr: "--pr-number-offset requires --predict-pr-numbers"},
+		{name: "subdirectory split", flags: data.CmdExportFlags{PredictPRNumbers: true, SubdirSplits: []string{"lib:lib"}},
+			wantErr: "cannot be combined with --subdir-split"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidatePRNumberPrediction(&tc.flags)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestPredictGitHubNumbers(t *testing.T) {
+	prs := []data.PullRequest{
+		{URL: format.URL("pr", "ws", "repo", 7), CreatedAt: "2024-03-01T00:00:00Z"},
+		{URL: format.URL("pr", "ws", "repo", 2), CreatedAt: "2024-01-01T00:00:00Z"},
+		{URL: format.URL("pr", "ws", "repo", 5), CreatedAt: "2024-01-01T00:00:00Z"},
+	}
+
+	assert.Equal(t, []data.PRNumber{
+		{Bitbucket: 2, GitHub: 11},
+		{Bitbucket: 5, GitHub: 12},
+		{Bitbucket: 7, GitHub: 13},
+	}, predictGitHubNumbers(format.URLs{}, prs, 10), "pull requests are numbered in creation order after the offset")
+}
+
+func TestRenumberPullRequestReferences(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.SetPRNumberPrediction(true, 4)
+
+	repoURL := format.URL("repository", "ws", "repo")
+	otherURL := format.URL("repository", "ws", "other")
+	prs := []data.PullRequest{
+		{URL: format.URL("pr", "ws", "repo", 1), Repository: repoURL, CreatedAt: "2024-01-01T00:00:00Z",
+			Body: "Follow-up in #2, unrelated to #99."},
+		{URL: format.URL("pr", "ws", "repo", 2), Repository: repoURL, CreatedAt: "2024-02-01T00:00:00Z",
+			Body: "Reverts #1. Escaped &#1; and [link](https://example.com/page#1) stay."},
+		{URL: format.URL("pr", "ws", "other", 1), Repository: otherURL, CreatedAt: "2024-01-01T00:00:00Z",
+			Body: "Same as #1 here."},
+	}
+	issueComments := []data.IssueComment{{PullRequest: prs[1].URL, Body: "(#1)"}}
+	reviewComments := []data.PullRequestReviewComment{{PullRequest: prs[2].URL, Body: "#1"}}
+
+	exporter.renumberPullRequestReferences("ws", []string{"repo", "other"}, nil, prs, issueComments, reviewComments)
+
+	assert.Equal(t, "Follow-up in #6, unrelated to #99.", prs[0].Body)
+	assert.Equal(t, "Reverts #5. Escaped &#1; and [link](https://example.com/page#1) stay.", prs[1].Body)
+	assert.Equal(t, "Same as #5 here.", prs[2].Body)
+	assert.Equal(t, "(#5)", issueComments[0].Body)
+	assert.Equal(t, "#5", reviewComments[0].Body)
+	assert.Equal(t, 5, exporter.report.Counts.RenumberedPRReferences)
+
+	require.Len(t, exporter.prNumbers, 2)
+	assert.Equal(t, data.RepositoryPRNumbers{
+		SourceRepository: "ws/repo",
+		Offset:           4,
+		PullRequests:     []data.PRNumber{{Bitbucket: 1, GitHub: 5}, {Bitbucket: 2, GitHub: 6}},
+	}, exporter.prNumbers[0])
+}
+
+func TestRenumberPullRequestReferencesWithIssueTracker(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.SetPRNumberPrediction(true, 4)
+
+	repoURL := format.URL("repository", "ws", "repo")
+	prs := []data.PullRequest{
+		{URL: format.URL("pr", "ws", "repo", 1), Repository: repoURL, CreatedAt: "2024-01-01T00:00:00Z",
+			Body: "Fixes #2, see pull request #2 and PR #2."},
+		{URL: format.URL("pr", "ws", "repo", 2), Repository: repoURL, CreatedAt: "2024-02-01T00:00:00Z"},
+	}
+	issueComments := []data.IssueComment{{PullRequest: prs[1].URL, Body: "Issue #1 is back, reverted in pr #1"}}
+	repos := map[string]*data.BitbucketRepository{"repo": {HasIssues: true}}
+
+	exporter.renumberPullRequestReferences("ws", []string{"repo"}, repos, prs, issueComments, nil)
+
+	assert.Equal(t, "Fixes #2, see pull request #6 and PR #6.", prs[0].Body,
+		"bare references are issues when the repository has an issue tracker")
+	assert.Equal(t, "Issue #1 is back, reverted in pr #5", issueComments[0].Body)
+	assert.Equal(t, 3, exporter.report.Counts.RenumberedPRReferences)
+}
+
+func TestWritePRNumberMap(t *testing.T) {
+	outputDir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.SetPRNumberPrediction(true, 0)
+	exporter.prNumbers = []data.RepositoryPRNumbers{{SourceRepository: "ws/repo",
+		PullRequests: []data.PRNumber{{Bitbucket: 3, GitHub: 1}}}}
+
+	require.NoError(t, exporter.writePRNumberMap())
+
+	content, err := os.ReadFile(filepath.Join(outputDir, prNumberMapFile))
+	require.NoError(t, err)
+	var report data.PRNumberMap
+	require.NoError(t, json.Unmarshal(content, &report))
+	assert.Equal(t, prNumberMapNote, report.Note)
+	assert.Equal(t, exporter.prNumbers, report.Repositories)
+	assert.True(t, sidecarPaths[prNumberMapFile], "the map stays out of the archive")
+}
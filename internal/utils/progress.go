@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+)
+
+const (
+	// ProgressFormatText reports progress only through the log.
+	ProgressFormatText = "text"
+	// ProgressFormatJSON additionally streams data.ProgressEvent lines to
+	// stdout for CI pipelines and wrapper tools.
+	ProgressFormatJSON = "json"
+
+	progressEventProgress  = "progress"
+	progressEventCompleted = "completed"
+	progressEventFailed    = "failed"
+)
+
+// ValidateProgressFormat checks the --progress-format value.
+func ValidateProgressFormat(format string) error {
+	switch format {
+	case "", ProgressFormatText, ProgressFormatJSON:
+		return nil
+	}
+	return fmt.Errorf("invalid value for --progress-format: %q (supported: %s, %s)",
+		format, ProgressFormatText, ProgressFormatJSON)
+}
+
+// progressReporter writes JSON progress events for the phases of an export.
+// Within a phase an event is written whenever the whole percentage changes,
+// so even phases with thousands of steps produce at most about 100 lines.
+// A nil reporter writes nothing.
+type progressReporter struct {
+	mu           sync.Mutex
+	out          io.Writer
+	now          func() time.Time
+	started      time.Time
+	phase        string
+	phaseStarted time.Time
+	completed    int
+	total        int
+	lastPercent  int
+}
+
+func newProgressReporter(out io.Writer) *progressReporter {
+	return &progressReporter{out: out, now: time.Now, started: time.Now()}
+}
+
+// SetProgressFormat streams JSON progress events to stdout with
+// ProgressFormatJSON and turns them off otherwise.
+func (e *Exporter) SetProgressFormat(format string) {
+	e.progressEvents = nil
+	if format == ProgressFormatJSON {
+		e.progressEvents = newProgressReporter(os.Stdout)
+	}
+	if e.client != nil {
+		e.client.progressEvents = e.progressEvents
+	}
+}
+
+// startPhase begins a phase of total steps and writes its first event.
+func (p *progressReporter) startPhase(phase string, total int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.phase = phase
+	p.phaseStarted = p.now()
+	p.completed = 0
+	p.total = total
+	p.lastPercent = -1
+	p.emit()
+}
+
+// advance records finished steps of the current phase.
+func (p *progressReporter) advance(steps int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	completed := min(p.completed+steps, p.total)
+	if completed == p.completed {
+		return
+	}
+	p.completed = completed
+	if p.completed == p.total || int(p.percent()) != p.lastPercent {
+		p.emit()
+	}
+}
+
+func (p *progressReporter) percent() float64 {
+	if p.total == 0 {
+		return 100
+	}
+	return math.Floor(float64(p.completed) * 100 / float64(p.total))
+}
+
+// emit writes an event for the current phase. The ETA extrapolates the
+// time the phase took so far to its remaining steps.
+func (p *progressReporter) emit() {
+	now := p.now()
+	event := data.ProgressEvent{
+		Event:          progressEventProgress,
+		Phase:          p.phase,
+		Completed:      p.completed,
+		Total:          p.total,
+		Percent:        p.percent(),
+		ElapsedSeconds: roundSeconds(now.Sub(p.started)),
+		Time:           now.UTC().Format(time.RFC3339),
+	}
+	if p.completed > 0 {
+		remaining := now.Sub(p.phaseStarted) * time.Duration(p.total-p.completed) / time.Duration(p.completed)
+		eta := roundSeconds(remaining)
+		event.ETASeconds = &eta
+	}
+	p.lastPercent = int(event.Percent)
+	writeProgressEvent(p.out, event)
+}
+
+// WriteProgressResult writes the final event of an export run started with
+// --progress-format json: the created archives or directories, or the
+// error that stopped the run.
+func WriteProgressResult(out io.Writer, started time.Time, outputs []string, err error) {
+	event := data.ProgressEvent{
+		Event:          progressEventCompleted,
+		Outputs:        outputs,
+		Percent:        100,
+		ElapsedSeconds: roundSeconds(time.Since(started)),
+		Time:           time.Now().UTC().Format(time.RFC3339),
+	}
+	if err != nil {
+		event.Event = progressEventFailed
+		event.Percent = 0
+		event.Error = SanitizeSupportText(err.Error())
+	}
+	writeProgressEvent(out, event)
+}
+
+func writeProgressEvent(out io.Writer, event data.ProgressEvent) {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	_, _ = out.Write(append(line, '\n'))
+}
+
+// countPullRequests returns the number of pull requests of all repositories.
+func countPullRequests(prsByRepo map[string][]data.PullRequest) int {
+	total := 0
+	for _, prs := range prsByRepo {
+		total += len(prs)
+	}
+	return total
+}
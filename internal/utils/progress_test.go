@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func progressEvents(t *testing.T, buf *bytes.Buffer) []data.ProgressEvent {
+	t.Helper()
+	var events []data.ProgressEvent
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var event data.ProgressEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &event), line)
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestValidateProgressFormat(t *testing.T) {
+	for _, format := range []string{"", ProgressFormatText, ProgressFormatJSON} {
+		assert.NoError(t, ValidateProgressFormat(format))
+	}
+	assert.ErrorContains(t, ValidateProgressFormat("yaml"), `invalid value for --progress-format: "yaml"`)
+}
+
+func TestProgressReporter(t *testing.T) {
+	var buf bytes.Buffer
+	clock := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	reporter := &progressReporter{out: &buf, now: func() time.Time { return clock }, started: clock}
+
+	reporter.startPhase(stageComments, 200)
+	clock = clock.Add(10 * time.Second)
+	reporter.advance(50)
+	for i := 0; i < 150; i++ {
+		reporter.advance(1)
+	}
+	reporter.advance(1)
+
+	events := progressEvents(t, &buf)
+	require.Len(t, events, 1+1+75, "one event per whole percent; steps past the total are ignored")
+
+	first := events[0]
+	assert.Equal(t, "progress", first.Event)
+	assert.Equal(t, stageComments, first.Phase)
+	assert.Equal(t, 0, first.Completed)
+	assert.Equal(t, 200, first.Total)
+	assert.Nil(t, first.ETASeconds)
+
+	quarter := events[1]
+	assert.Equal(t, 50, quarter.Completed)
+	assert.Equal(t, float64(25), quarter.Percent)
+	require.NotNil(t, quarter.ETASeconds)
+	assert.Equal(t, float64(30), *quarter.ETASeconds)
+	assert.Equal(t, float64(10), quarter.ElapsedSeconds)
+	assert.Equal(t, "2024-05-01T12:00:10Z", quarter.Time)
+
+	last := events[len(events)-1]
+	assert.Equal(t, 200, last.Completed)
+	assert.Equal(t, float64(100), last.Percent)
+	assert.Equal(t, float64(0), *last.ETASeconds)
+
+	var nilReporter *progressReporter
+	nilReporter.startPhase(stageArchive, 1)
+	nilReporter.advance(1)
+}
+
+func TestWriteProgressResult(t *testing.T) {
+	var buf bytes.Buffer
+	WriteProgressResult(&buf, time.Now(), []string{"export.tar.gz"}, nil)
+	WriteProgressResult(&buf, time.Now(), nil, errors.New("clone failed"))
+
+	events := progressEvents(t, &buf)
+	require.Len(t, events, 2)
+	assert.Equal(t, "completed", events[0].Event)
+	assert.Equal(t, []string{"export.tar.gz"}, events[0].Outputs)
+	assert.Equal(t, "failed", events[1].Event)
+	assert.Equal(t, "clone failed", events[1].Error)
+}
+
+func TestCommentsAdvanceProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(t, w, []byte(`{"values": []}`))
+	}))
+	defer server.Close()
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop(), concurrency: 2}
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	exporter.SetProgressFormat(ProgressFormatJSON)
+	require.Same(t, exporter.progressEvents, client.progressEvents)
+	var buf bytes.Buffer
+	exporter.progressEvents.out = &buf
+
+	prs := []data.PullRequest{{URL: "https://bitbucket.org/ws/repo/pull/1"}, {URL: "https://bitbucket.org/ws/repo/pull/2"}}
+	exporter.progressEvents.startPhase(stageComments, len(prs))
+	_, _, err := client.GetPullRequestComments("ws", "repo", prs)
+	require.NoError(t, err)
+
+	events := progressEvents(t, &buf)
+	assert.Len(t, events, 3)
+	assert.Equal(t, 2, events[len(events)-1].Completed)
+
+	exporter.SetProgressFormat(ProgressFormatText)
+	assert.Nil(t, client.progressEvents)
+}
@@ -0,0 +1,321 @@
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	protectionHealthFile = "protection-health.json"
+
+	DefaultProtectionHealthDays = 90
+
+	protectionExercised  = "exercised"
+	protectionIdle       = "idle"
+	protectionNoBranches = "no_matching_branches"
+
+	protectionHealthNote = "Commits are first-parent commits of each branch in the mirror. Pull request merges come from " +
+		"the exported pull requests; commits of fast-forward merges other than the tip count as direct commits."
+)
+
+// SetProtectionHealth writes protection-health.json, which shows for each
+// Bitbucket branch restriction how much the branches it protects received in
+// the last days days, and which active branches have no restriction.
+func (e *Exporter) SetProtectionHealth(enabled bool, days int) {
+	e.protectionHealth = enabled
+	e.protectionHealthDays = days
+}
+
+// ValidateProtectionHealth checks --protection-health-days.
+func ValidateProtectionHealth(cmdFlags *data.CmdExportFlags) error {
+	if cmdFlags.ProtectionHealth && cmdFlags.ProtectionHealthDays < 1 {
+		return fmt.Errorf("invalid value for --protection-health-days: %d (must be 1 or more)", cmdFlags.ProtectionHealthDays)
+	}
+	return nil
+}
+
+// branchPatternRegexp compiles a Bitbucket branch glob, where * also matches
+// slashes.
+func branchPatternRegexp(pattern string) *regexp.Regexp {
+	return regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$")
+}
+
+// GetBranchingModel returns the branching model of a repository.
+func (c *Client) GetBranchingModel(workspace, repoSlug string) (*data.BitbucketBranchingModel, error) {
+	var model data.BitbucketBranchingModel
+	endpoint := fmt.Sprintf("repositories/%s/%s/branching-model", workspace, repoSlug)
+	if err := c.makeRequest("GET", endpoint, &model); err != nil {
+		return nil, fmt.Errorf("failed to fetch the branching model of %s/%s: %w", workspace, repoSlug, err)
+	}
+	return &model, nil
+}
+
+// branchTypePattern returns the branch glob a restriction on a branch type
+// of the branching model covers: the development or production branch, or
+// the prefix of the other branch types followed by *.
+func branchTypePattern(model *data.BitbucketBranchingModel, branchType string) (string, bool) {
+	branchName := func(branch *data.BranchingModelBranch) (string, bool) {
+		switch {
+		case branch == nil:
+			return "", false
+		case branch.Name != "":
+			return branch.Name, true
+		case branch.Branch != nil && branch.Branch.Name != "":
+			return branch.Branch.Name, true
+		}
+		return "", false
+	}
+	switch branchType {
+	case "development":
+		return branchName(model.Development)
+	case "production":
+		return branchName(model.Production)
+	}
+	for _, kind := range model.BranchTypes {
+		if kind.Kind == branchType && kind.Prefix != "" {
+			return kind.Prefix + "*", true
+		}
+	}
+	return "", false
+}
+
+// branchActivity measures the activity of every branch of a mirror since
+// since. mergeCommits holds the merge commit SHAs of the pull requests merged
+// into each branch in the window, and merges their number.
+func branchActivity(repoDir string, since time.Time, mergeCommits map[string][]string, merges map[string]int) ([]data.BranchActivity, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname)%09%(committerdate:iso-strict)", "refs/heads")
+	cmd.Dir = repoDir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches in %s: %w", repoDir, err)
+	}
+
+	var branches []data.BranchActivity
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		ref, committed, ok := strings.Cut(line, "\t")
+		if !ok {
+			continue
+		}
+		activity := data.BranchActivity{
+			Branch:            strings.TrimPrefix(ref, "refs/heads/"),
+			LastCommitAt:      committed,
+			PullRequestMerges: merges[strings.TrimPrefix(ref, "refs/heads/")],
+		}
+		if last, err := time.Parse(time.RFC3339, committed); err == nil && last.Before(since) {
+			branches = append(branches, activity)
+			continue
+		}
+
+		cmd := exec.Command("git", "log", "--first-parent", "--since="+since.Format(time.RFC3339), "--format=%H %P", ref)
+		cmd.Dir = repoDir
+		commits, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the history of %s: %w", activity.Branch, err)
+		}
+		for _, commit := range strings.Split(strings.TrimSpace(string(commits)), "\n") {
+			fields := strings.Fields(commit)
+			if len(fields) == 0 {
+				continue
+			}
+			activity.Commits++
+			// Merge commits and the merge commits of pull requests arrived
+			// through a pull request; everything else was pushed directly.
+			if len(fields) < 3 && !matchesMergeCommit(fields[0], mergeCommits[activity.Branch]) {
+				activity.DirectCommits++
+			}
+		}
+		branches = append(branches, activity)
+	}
+	return branches, nil
+}
+
+// matchesMergeCommit reports whether sha is one of the possibly abbreviated
+// merge commits.
+func matchesMergeCommit(sha string, mergeCommits []string) bool {
+	for _, merge := range mergeCommits {
+		if merge != "" && strings.HasPrefix(sha, merge) {
+			return true
+		}
+	}
+	return false
+}
+
+// assessProtection sets the status and recommendation of a pattern from the
+// activity of its branches.
+func assessProtection(pattern *data.ProtectedPattern) {
+	restricts := func(kind string) bool {
+		for _, restriction := range pattern.Restrictions {
+			if restriction == kind {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case len(pattern.Branches) == 0:
+		pattern.Status = protectionNoBranches
+		pattern.Recommendation = "no branch matches this pattern; configure the ruleset only if such branches are planned"
+	case pattern.Commits == 0 && pattern.PullRequestMerges == 0:
+		pattern.Status = protectionIdle
+		pattern.Recommendation = "no recent activity on the protected branches; the ruleset is low priority"
+	case restricts(restrictionPush) && pattern.DirectCommits > 0:
+		pattern.Status = protectionExercised
+		pattern.Recommendation = "direct commits reached the branches despite the push restriction; add the users allowed to push as ruleset bypass actors"
+	case restricts(restrictionPush):
+		pattern.Status = protectionExercised
+		pattern.Recommendation = "all recent changes arrived through pull requests; a pull_request rule matches how the branches are used"
+	case pattern.DirectCommits > 0:
+		pattern.Status = protectionExercised
+		pattern.Recommendation = "the branches receive direct pushes; a pull_request rule would block them"
+	default:
+		pattern.Status = protectionExercised
+		pattern.Recommendation = "recent changes arrived through pull requests; consider requiring pull requests in the ruleset"
+	}
+}
+
+// analyzeProtectionHealth correlates the branch restrictions of a repository
+// with the activity of its branches.
+func (e *Exporter) analyzeProtectionHealth(workspace, repoSlug string, prs []data.PullRequest, since time.Time) data.RepositoryProtectionHealth {
+	health := data.RepositoryProtectionHealth{
+		SourceRepository:    fmt.Sprintf("%s/%s", workspace, repoSlug),
+		Patterns:            []data.ProtectedPattern{},
+		UnprotectedBranches: []data.BranchActivity{},
+	}
+
+	mergeCommits := make(map[string][]string)
+	merges := make(map[string]int)
+	for _, pr := range prs {
+		if pr.MergedAt == nil {
+			continue
+		}
+		if merged, err := time.Parse(time.RFC3339, *pr.MergedAt); err != nil || merged.Before(since) {
+			continue
+		}
+		merges[pr.Base.Ref]++
+		if pr.MergeCommitSHA != nil {
+			mergeCommits[pr.Base.Ref] = append(mergeCommits[pr.Base.Ref], *pr.MergeCommitSHA)
+		}
+	}
+
+	repoDir := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+	branches, err := branchActivity(repoDir, since, mergeCommits, merges)
+	if err != nil {
+		health.Notes = append(health.Notes, fmt.Sprintf("failed to read branch activity: %v", err))
+		return health
+	}
+
+	restrictions, err := e.client.GetBranchRestrictions(workspace, repoSlug)
+	if err != nil {
+		health.Notes = append(health.Notes, fmt.Sprintf("failed to fetch branch restrictions: %v", err))
+		return health
+	}
+
+	// Restrictions on a branch type protect the branches the branching model
+	// assigns to it, so they are analysed as the equivalent glob.
+	var model *data.BitbucketBranchingModel
+	var modelErr error
+	var order []string
+	patterns := make(map[string]*data.ProtectedPattern)
+	for _, restriction := range restrictions {
+		name := restriction.Pattern
+		if restriction.BranchMatchKind != "" && restriction.BranchMatchKind != branchMatchGlob {
+			if model == nil && modelErr == nil {
+				model, modelErr = e.client.GetBranchingModel(workspace, repoSlug)
+			}
+			resolved, ok := "", false
+			if model != nil {
+				resolved, ok = branchTypePattern(model, restriction.BranchType)
+			}
+			if !ok {
+				health.Notes = append(health.Notes, fmt.Sprintf("%s restriction on branch type %q is not analysed",
+					restriction.Kind, restriction.BranchType))
+				continue
+			}
+			name = resolved
+		}
+		pattern, ok := patterns[name]
+		if !ok {
+			pattern = &data.ProtectedPattern{Pattern: name, Branches: []data.BranchActivity{}}
+			patterns[name] = pattern
+			order = append(order, name)
+		}
+		pattern.Restrictions = append(pattern.Restrictions, restriction.Kind)
+	}
+	if modelErr != nil {
+		health.Notes = append(health.Notes, modelErr.Error())
+	}
+
+	protected := make(map[string]bool)
+	for _, name := range order {
+		pattern := patterns[name]
+		sort.Strings(pattern.Restrictions)
+		matcher := branchPatternRegexp(name)
+		for _, branch := range branches {
+			if !matcher.MatchString(branch.Branch) {
+				continue
+			}
+			protected[branch.Branch] = true
+			pattern.Branches = append(pattern.Branches, branch)
+			pattern.Commits += branch.Commits
+			pattern.PullRequestMerges += branch.PullRequestMerges
+			pattern.DirectCommits += branch.DirectCommits
+		}
+		assessProtection(pattern)
+		health.Patterns = append(health.Patterns, *pattern)
+	}
+
+	for _, branch := range branches {
+		if !protected[branch.Branch] && branch.PullRequestMerges > 0 {
+			health.UnprotectedBranches = append(health.UnprotectedBranches, branch)
+		}
+	}
+	return health
+}
+
+// writeProtectionHealth analyses every exported repository and writes the
+// report.
+func (e *Exporter) writeProtectionHealth(workspace string, repoSlugs []string, prsByRepo map[string][]data.PullRequest) error {
+	if !e.protectionHealth {
+		return nil
+	}
+
+	since := e.now().UTC().AddDate(0, 0, -e.protectionHealthDays)
+	report := data.ProtectionHealthReport{
+		Note:         protectionHealthNote,
+		Since:        since.Format(time.RFC3339),
+		WindowDays:   e.protectionHealthDays,
+		Repositories: []data.RepositoryProtectionHealth{},
+	}
+	idle := 0
+	for _, repoSlug := range repoSlugs {
+		health := e.analyzeProtectionHealth(workspace, repoSlug, prsByRepo[repoSlug], since)
+		for _, note := range health.Notes {
+			e.logger.Warn("Branch protection analysis incomplete",
+				zap.String("repository", health.SourceRepository),
+				zap.String("note", note))
+		}
+		for _, pattern := range health.Patterns {
+			if pattern.Status != protectionExercised {
+				idle++
+			}
+		}
+		report.Repositories = append(report.Repositories, health)
+	}
+
+	if err := e.writeJSONFile(protectionHealthFile, report); err != nil {
+		return err
+	}
+	e.logger.Info("Wrote branch protection health (excluded from import archive)",
+		zap.String("file", protectionHealthFile),
+		zap.Int("unexercised_patterns", idle))
+	return nil
+}
@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidateProtectionHealth(t *testing.T) {
+	assert.NoError(t, ValidateProtectionHealth(&data.CmdExportFlags{ProtectionHealth: true, ProtectionHealthDays: 30}))
+	assert.NoError(t, ValidateProtectionHealth(&data.CmdExportFlags{ProtectionHealthDays: 0}))
+	assert.Error(t, ValidateProtectionHealth(&data.CmdExportFlags{ProtectionHealth: true, ProtectionHealthDays: 0}))
+}
+
+func TestBranchPatternRegexp(t *testing.T) {
+	assert.True(t, branchPatternRegexp("main").MatchString("main"))
+	assert.False(t, branchPatternRegexp("main").MatchString("main2"))
+	assert.True(t, branchPatternRegexp("release/*").MatchString("release/1.0/hotfix"))
+	assert.False(t, branchPatternRegexp("release/*").MatchString("release"))
+	assert.True(t, branchPatternRegexp("feature.*").MatchString("feature.x"))
+	assert.False(t, branchPatternRegexp("feature.*").MatchString("featureAx"))
+}
+
+func TestWriteProtectionHealth(t *testing.T) {
+	outputDir := t.TempDir()
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+
+	commitAt := func(when time.Time, message string) {
+		t.Setenv("GIT_AUTHOR_DATE", when.Format(time.RFC3339))
+		t.Setenv("GIT_COMMITTER_DATE", when.Format(time.RFC3339))
+		runGit(t, workDir, "commit", "--allow-empty", "-m", message)
+	}
+	old := time.Now().AddDate(-1, 0, 0)
+	recent := time.Now().AddDate(0, 0, -2)
+
+	commitAt(old, "initial")
+	runGit(t, workDir, "branch", "release/1.0")
+	runGit(t, workDir, "checkout", "-q", "-b", "develop")
+	commitAt(recent, "develop work")
+	runGit(t, workDir, "checkout", "-q", "main")
+	runGit(t, workDir, "checkout", "-q", "-b", "feature")
+	commitAt(recent, "feature work")
+	runGit(t, workDir, "checkout", "-q", "main")
+	commitAt(recent, "direct push")
+	t.Setenv("GIT_COMMITTER_DATE", recent.Format(time.RFC3339))
+	runGit(t, workDir, "merge", "--no-ff", "-m", "Merged in feature (pull request #1)", "feature")
+	commitAt(recent, "squashed pull request #2")
+	squash := runGit(t, workDir, "rev-parse", "HEAD")
+
+	mirror := filepath.Join(outputDir, "repositories", "ws", "repo.git")
+	runGit(t, filepath.Dir(workDir), "clone", "-q", "--mirror", workDir, mirror)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repositories/ws/repo/branching-model" {
+			writeResponse(t, w, []byte(`{"development": {"name": "develop"}, "branch_types": []}`))
+			return
+		}
+		assert.Equal(t, "/repositories/ws/repo/branch-restrictions", r.URL.Path)
+		writeResponse(t, w, []byte(`{"values": [
+			{"id": 1, "kind": "push", "branch_match_kind": "glob", "pattern": "main"},
+			{"id": 2, "kind": "delete", "branch_match_kind": "glob", "pattern": "main"},
+			{"id": 3, "kind": "force", "branch_match_kind": "glob", "pattern": "release/*"},
+			{"id": 4, "kind": "push", "branch_match_kind": "glob", "pattern": "hotfix/*"},
+			{"id": 5, "kind": "delete", "branch_match_kind": "branching_model", "branch_type": "production"}
+		]}`))
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+	exporter.SetProtectionHealth(true, 30)
+
+	mergedAt := recent.UTC().Format(time.RFC3339)
+	oldMergedAt := old.UTC().Format(time.RFC3339)
+	squashSHA := squash[:12]
+	prs := []data.PullRequest{
+		{Base: data.PRBranch{Ref: "main"}, MergedAt: &mergedAt},
+		{Base: data.PRBranch{Ref: "main"}, MergedAt: &mergedAt, MergeCommitSHA: &squashSHA},
+		{Base: data.PRBranch{Ref: "main"}, MergedAt: &oldMergedAt},
+		{Base: data.PRBranch{Ref: "develop"}, MergedAt: &mergedAt},
+		{Base: data.PRBranch{Ref: "develop"}},
+	}
+	require.NoError(t, exporter.writeProtectionHealth("ws", []string{"repo"}, map[string][]data.PullRequest{"repo": prs}))
+
+	content, err := os.ReadFile(filepath.Join(outputDir, protectionHealthFile))
+	require.NoError(t, err)
+	var report data.ProtectionHealthReport
+	require.NoError(t, json.Unmarshal(content, &report))
+	assert.Equal(t, 30, report.WindowDays)
+	require.Len(t, report.Repositories, 1)
+	health := report.Repositories[0]
+	assert.Equal(t, "ws/repo", health.SourceRepository)
+	require.Len(t, health.Notes, 1)
+	assert.Contains(t, health.Notes[0], "production")
+
+	require.Len(t, health.Patterns, 3)
+	mainPattern := health.Patterns[0]
+	assert.Equal(t, "main", mainPattern.Pattern)
+	assert.Equal(t, []string{"delete", "push"}, mainPattern.Restrictions)
+	require.Len(t, mainPattern.Branches, 1)
+	assert.Equal(t, 3, mainPattern.Commits)
+	assert.Equal(t, 2, mainPattern.PullRequestMerges)
+	assert.Equal(t, 1, mainPattern.DirectCommits)
+	assert.Equal(t, protectionExercised, mainPattern.Status)
+	assert.Contains(t, mainPattern.Recommendation, "bypass actors")
+
+	releasePattern := health.Patterns[1]
+	assert.Equal(t, "release/*", releasePattern.Pattern)
+	require.Len(t, releasePattern.Branches, 1)
+	assert.Equal(t, "release/1.0", releasePattern.Branches[0].Branch)
+	assert.Equal(t, protectionIdle, releasePattern.Status)
+
+	assert.Equal(t, protectionNoBranches, health.Patterns[2].Status)
+
+	require.Len(t, health.UnprotectedBranches, 1)
+	assert.Equal(t, "develop", health.UnprotectedBranches[0].Branch)
+	assert.Equal(t, 1, health.UnprotectedBranches[0].PullRequestMerges)
+}
+
+func TestWriteProtectionHealthResolvesBranchTypes(t *testing.T) {
+	outputDir := t.TempDir()
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	now := time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	t.Setenv("GIT_AUTHOR_DATE", now.AddDate(0, 0, -2).Format(time.RFC3339))
+	t.Setenv("GIT_COMMITTER_DATE", now.AddDate(0, 0, -2).Format(time.RFC3339))
+	runGit(t, workDir, "commit", "--allow-empty", "-m", "initial")
+	runGit(t, workDir, "branch", "develop")
+	runGit(t, workDir, "branch", "feature/login")
+	mirror := filepath.Join(outputDir, "repositories", "ws", "repo.git")
+	runGit(t, filepath.Dir(workDir), "clone", "-q", "--mirror", workDir, mirror)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repositories/ws/repo/branching-model":
+			writeResponse(t, w, []byte(`{"development": {"name": "develop"},
+				"branch_types": [{"kind": "feature", "prefix": "feature/"}]}`))
+		default:
+			writeResponse(t, w, []byte(`{"values": [
+				{"id": 1, "kind": "push", "branch_match_kind": "branching_model", "branch_type": "development"},
+				{"id": 2, "kind": "delete", "branch_match_kind": "branching_model", "branch_type": "feature"},
+				{"id": 3, "kind": "delete", "branch_match_kind": "branching_model", "branch_type": "hotfix"}
+			]}`))
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop(), clock: FixedClock{Time: now}}
+	exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+	exporter.SetProtectionHealth(true, 30)
+
+	mergedAt := now.AddDate(0, 0, -1).Format(time.RFC3339)
+	prs := []data.PullRequest{
+		{Base: data.PRBranch{Ref: "develop"}, MergedAt: &mergedAt},
+		{Base: data.PRBranch{Ref: "feature/login"}, MergedAt: &mergedAt},
+	}
+	require.NoError(t, exporter.writeProtectionHealth("ws", []string{"repo"}, map[string][]data.PullRequest{"repo": prs}))
+
+	content, err := os.ReadFile(filepath.Join(outputDir, protectionHealthFile))
+	require.NoError(t, err)
+	var report data.ProtectionHealthReport
+	require.NoError(t, json.Unmarshal(content, &report))
+	assert.Equal(t, "2024-05-11T00:00:00Z", report.Since, "the window ends at the exporter's clock")
+	require.Len(t, report.Repositories, 1)
+	health := report.Repositories[0]
+	require.Len(t, health.Patterns, 2)
+	assert.Equal(t, "develop", health.Patterns[0].Pattern)
+	assert.Equal(t, "feature/*", health.Patterns[1].Pattern)
+	require.Len(t, health.Patterns[1].Branches, 1)
+	assert.Equal(t, "feature/login", health.Patterns[1].Branches[0].Branch)
+	assert.Empty(t, health.UnprotectedBranches, "branches covered by a branch type restriction are protected")
+	require.Len(t, health.Notes, 1)
+	assert.Contains(t, health.Notes[0], "hotfix")
+}
+
+func TestAssessProtection(t *testing.T) {
+	branches := []data.BranchActivity{{Branch: "main"}}
+
+	pattern := data.ProtectedPattern{Restrictions: []string{"push"}, Branches: branches, Commits: 4, PullRequestMerges: 4}
+	assessProtection(&pattern)
+	assert.Equal(t, protectionExercised, pattern.Status)
+	assert.Contains(t, pattern.Recommendation, "pull_request rule matches")
+
+	pattern = data.ProtectedPattern{Restrictions: []string{"delete"}, Branches: branches, Commits: 2, DirectCommits: 2}
+	assessProtection(&pattern)
+	assert.Contains(t, pattern.Recommendation, "would block them")
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"gopkg.in/yaml.v3"
+)
+
+// approvalShortcode is the shortcode Bitbucket approvals are translated
+// from.
+const approvalShortcode = "approved"
+
+// githubReactionTypes are the reaction contents accepted by GitHub.
+var githubReactionTypes = map[string]bool{
+	"+1": true, "-1": true, "laugh": true, "confused": true,
+	"heart": true, "hooray": true, "rocket": true, "eyes": true,
+}
+
+// defaultReactionMapping translates common Bitbucket emoji shortcodes and
+// approvals to GitHub reactions when no mapping file is provided.
+var defaultReactionMapping = map[string]string{
+	"approved":   "+1",
+	"thumbsup":   "+1",
+	"+1":         "+1",
+	"thumbsdown": "-1",
+	"-1":         "-1",
+	"smile":      "laugh",
+	"laughing":   "laugh",
+	"joy":        "laugh",
+	"confused":   "confused",
+	"heart":      "heart",
+	"tada":       "hooray",
+	"rocket":     "rocket",
+	"eyes":       "eyes",
+}
+
+func normalizeShortcode(shortcode string) string {
+	return strings.ToLower(strings.Trim(strings.TrimSpace(shortcode), ":"))
+}
+
+// LoadReactionMapping reads a YAML file mapping Bitbucket shortcodes to
+// GitHub reaction types. Entries override the built-in defaults.
+func LoadReactionMapping(path string) (map[string]string, error) {
+	mapping := make(map[string]string, len(defaultReactionMapping))
+	for shortcode, reaction := range defaultReactionMapping {
+		mapping[shortcode] = reaction
+	}
+	if path == "" {
+		return mapping, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reaction mapping file: %w", err)
+	}
+
+	var config data.ReactionMappingConfig
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse reaction mapping file %s: %w", path, err)
+	}
+
+	invalid := []string{}
+	for shortcode, reaction := range config.Reactions {
+		if !githubReactionTypes[reaction] {
+			invalid = append(invalid, fmt.Sprintf("%s: %s", shortcode, reaction))
+			continue
+		}
+		mapping[normalizeShortcode(shortcode)] = reaction
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return nil, fmt.Errorf("unsupported GitHub reaction types in %s (%s); allowed: +1, -1, laugh, confused, heart, hooray, rocket, eyes",
+			path, strings.Join(invalid, ", "))
+	}
+
+	return mapping, nil
+}
+
+func (e *Exporter) SetReactionMapping(mapping map[string]string) {
+	e.reactionMapping = mapping
+}
+
+// MapReaction returns the GitHub reaction type for a Bitbucket shortcode.
+func (e *Exporter) MapReaction(shortcode string) (string, bool) {
+	mapping := e.reactionMapping
+	if mapping == nil {
+		mapping = defaultReactionMapping
+	}
+	reaction, ok := mapping[normalizeShortcode(shortcode)]
+	return reaction, ok
+}
+
+// addApprovalReactions adds a reaction of the approving user to a pull
+// request for each approval in its activity log, translated with
+// --reaction-map.
+func (e *Exporter) addApprovalReactions(prs []data.PullRequest, reviews []map[string]interface{}) {
+	content, ok := e.MapReaction(approvalShortcode)
+	if !ok {
+		return
+	}
+	index := make(map[string]int, len(prs))
+	for i, pr := range prs {
+		index[pr.URL] = i
+	}
+	for _, review := range reviews {
+		if state, _ := review["state"].(int); state != reviewStateApproved {
+			continue
+		}
+		prURL, _ := review["pull_request"].(string)
+		i, found := index[prURL]
+		if !found {
+			continue
+		}
+		user, _ := review["user"].(string)
+		createdAt, _ := review["submitted_at"].(string)
+		prs[i].Reactions = append(prs[i].Reactions, data.Reaction{User: user, Content: content, CreatedAt: createdAt})
+	}
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLoadReactionMapping(t *testing.T) {
+	t.Run("Defaults without file", func(t *testing.T) {
+		mapping, err := LoadReactionMapping("")
+		require.NoError(t, err)
+		assert.Equal(t, "+1", mapping["thumbsup"])
+		assert.Equal(t, "hooray", mapping["tada"])
+	})
+
+	t.Run("File overrides defaults", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "reactions.yaml")
+		content := "reactions:\n  approved: heart\n  \":party_parrot:\": hooray\n"
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+		mapping, err := LoadReactionMapping(path)
+		require.NoError(t, err)
+		assert.Equal(t, "heart", mapping["approved"])
+		assert.Equal(t, "hooray", mapping["party_parrot"])
+		assert.Equal(t, "+1", mapping["thumbsup"])
+	})
+
+	t.Run("Unsupported reaction type", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "reactions.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("reactions:\n  thumbsup: like\n"), 0644))
+
+		_, err := LoadReactionMapping(path)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "thumbsup: like")
+	})
+
+	t.Run("Invalid YAML", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "reactions.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("reactions: [unclosed"), 0644))
+
+		_, err := LoadReactionMapping(path)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to parse reaction mapping file")
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := LoadReactionMapping(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.Error(t, err)
+	})
+}
+
+func TestMapReaction(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	exporter := NewExporter(&Client{logger: logger}, t.TempDir(), logger, false, "")
+
+	reaction, ok := exporter.MapReaction(":ThumbsUp:")
+	assert.True(t, ok)
+	assert.Equal(t, "+1", reaction)
+
+	exporter.SetReactionMapping(map[string]string{"approved": "rocket"})
+	reaction, ok = exporter.MapReaction("approved")
+	assert.True(t, ok)
+	assert.Equal(t, "rocket", reaction)
+
+	_, ok = exporter.MapReaction("thumbsup")
+	assert.False(t, ok)
+}
+
+func TestExportApprovalReactions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repositories/workspace/repo":
+			writeResponse(t, w, []byte(`{"slug": "repo", "name": "repo", "scm": "git", "mainbranch": {"name": "main"}}`))
+		case "/repositories/workspace/repo/pullrequests":
+			writeResponse(t, w, []byte(`{"values": [{"id": 1, "title": "Login", "state": "OPEN",
+				"created_on": "2024-03-01T08:00:00+00:00", "author": {"uuid": "{bob}", "display_name": "Bob"},
+				"source": {"branch": {"name": "feature"}, "commit": {"hash": "1234567890123456789012345678901234567890"}},
+				"destination": {"branch": {"name": "main"}, "commit": {"hash": "0987654321098765432109876543210987654321"}}}]}`))
+		case "/repositories/workspace/repo/pullrequests/1/activity":
+			writeResponse(t, w, []byte(`{"values": [
+				{"approval": {"date": "2024-03-02T10:00:00+00:00", "user": {"uuid": "{alice}", "display_name": "Alice"}}},
+				{"changes_requested": {"date": "2024-03-01T12:00:00+00:00", "user": {"uuid": "{carol}", "display_name": "Carol"}}}]}`))
+		default:
+			writeResponse(t, w, []byte(`{"values": []}`))
+		}
+	}))
+	defer server.Close()
+
+	mappingFile := filepath.Join(t.TempDir(), "reactions.yaml")
+	require.NoError(t, os.WriteFile(mappingFile, []byte("reactions:\n  approved: heart\n"), 0644))
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop(),
+		commitSHACache: make(map[string]string), skipCommitLookup: true}
+	outputDir := t.TempDir()
+	exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+	require.NoError(t, exporter.ApplyExportFlags(&data.CmdExportFlags{ReactionMapFile: mappingFile, SkipGit: true}))
+
+	require.NoError(t, exporter.ExportRepositories("workspace", []string{"repo"}))
+
+	var prs []data.PullRequest
+	readReportFile(t, filepath.Join(outputDir, pullRequestsFile), &prs)
+	require.Len(t, prs, 1)
+	assert.Equal(t, []data.Reaction{
+		{User: "https://bitbucket.org/alice", Content: "heart", CreatedAt: "2024-03-02T10:00:00Z"},
+	}, prs[0].Reactions)
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultRecordsPerFile is the --records-per-file default: the most records
+// of one type written to an archive JSON file before the next numbered file
+// is started.
+const DefaultRecordsPerFile = 1000
+
+// ValidateRecordsPerFile checks a --records-per-file value. Zero writes all
+// records of a type to one file.
+func ValidateRecordsPerFile(records int) error {
+	if records < 0 {
+		return fmt.Errorf("invalid value for --records-per-file: %d (must be 0 or more)", records)
+	}
+	return nil
+}
+
+// SetRecordsPerFile splits the users, repositories, pull requests, comments
+// and reviews of the archive into numbered files of at most records
+// records each, e.g. pull_requests_000002.json. Zero writes one file per
+// type.
+func (e *Exporter) SetRecordsPerFile(records int) {
+	e.recordsPerFile = records
+}
+
+// recordFileName returns the name of the index-th file of the record type
+// whose first file is firstFile, e.g. pull_requests_000001.json.
+func recordFileName(firstFile string, index int) string {
+	return fmt.Sprintf("%s_%06d.json", strings.TrimSuffix(firstFile, "_000001.json"), index)
+}
+
+// isRecordFile reports whether name is one of the numbered files of the
+// record type whose first file is firstFile.
+func isRecordFile(name, firstFile string) bool {
+	prefix := strings.TrimSuffix(firstFile, "000001.json")
+	number, ok := strings.CutPrefix(name, prefix)
+	if !ok || len(number) != len("000001.json") || !strings.HasSuffix(number, ".json") {
+		return false
+	}
+	for _, digit := range strings.TrimSuffix(number, ".json") {
+		if digit < '0' || digit > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// recordFileNames lists the files of a record type in an export directory:
+// firstFile and the numbered files that follow it without a gap.
+func recordFileNames(dir, firstFile string) ([]string, error) {
+	var names []string
+	for index := 1; ; index++ {
+		name := recordFileName(firstFile, index)
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			if os.IsNotExist(err) {
+				return names, nil
+			}
+			return nil, err
+		}
+		names = append(names, name)
+	}
+}
+
+// readRecordFiles reads the records of every file of a record type in an
+// export directory, in file order. A type without files has no records.
+func readRecordFiles[T any](dir, firstFile string) ([]T, error) {
+	names, err := recordFileNames(dir, firstFile)
+	if err != nil {
+		return nil, err
+	}
+	var records []T
+	for _, name := range names {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		var fileRecords []T
+		if err := json.Unmarshal(content, &fileRecords); err != nil {
+			return nil, fmt.Errorf("%w %s: %w", ErrCorruptExportFile, name, err)
+		}
+		records = append(records, fileRecords...)
+	}
+	return records, nil
+}
+
+// writeRecordFiles writes records to firstFile and, beyond the exporter's
+// records per file, to the numbered files after it. Files left from an
+// earlier write of more records are removed.
+func writeRecordFiles[T any](e *Exporter, firstFile string, records []T) error {
+	perFile := e.recordsPerFile
+	if perFile <= 0 || perFile > len(records) {
+		perFile = len(records)
+	}
+	index := 1
+	for start := 0; index == 1 || start < len(records); start += perFile {
+		end := min(start+perFile, len(records))
+		if err := e.writeJSONFile(recordFileName(firstFile, index), records[start:end]); err != nil {
+			return err
+		}
+		index++
+	}
+	return e.removeRecordFiles(firstFile, index)
+}
+
+// removeRecordFiles removes the files of a record type from the from-th
+// file on.
+func (e *Exporter) removeRecordFiles(firstFile string, from int) error {
+	for index := from; ; index++ {
+		name := recordFileName(firstFile, index)
+		if err := os.Remove(filepath.Join(e.outputDir, name)); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+}
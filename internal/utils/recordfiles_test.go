@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidateRecordsPerFile(t *testing.T) {
+	assert.NoError(t, ValidateRecordsPerFile(0))
+	assert.NoError(t, ValidateRecordsPerFile(DefaultRecordsPerFile))
+	assert.ErrorContains(t, ValidateRecordsPerFile(-1), "--records-per-file")
+}
+
+func TestRecordFileNames(t *testing.T) {
+	assert.Equal(t, "pull_requests_000002.json", recordFileName(pullRequestsFile, 2))
+	assert.Equal(t, "issue_comments_000123.json", recordFileName(issueCommentsFile, 123))
+
+	assert.True(t, isRecordFile("pull_requests_000001.json", pullRequestsFile))
+	assert.True(t, isRecordFile("pull_requests_000042.json", pullRequestsFile))
+	assert.False(t, isRecordFile("pull_requests_00004.json", pullRequestsFile))
+	assert.False(t, isRecordFile("pull_requests_00004x.json", pullRequestsFile))
+	assert.False(t, isRecordFile("pull_request_review_comments_000001.json", pullRequestsFile))
+}
+
+func TestWriteRecordFilesSplitsRecords(t *testing.T) {
+	outputDir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.SetRecordsPerFile(2)
+
+	users := []data.User{{Login: "a"}, {Login: "b"}, {Login: "c"}, {Login: "d"}, {Login: "e"}}
+	require.NoError(t, writeRecordFiles(exporter, usersFile, users))
+
+	names, err := recordFileNames(outputDir, usersFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"users_000001.json", "users_000002.json", "users_000003.json"}, names)
+
+	read, err := readRecordFiles[data.User](outputDir, usersFile)
+	require.NoError(t, err)
+	assert.Equal(t, users, read)
+
+	// A later write of fewer records removes the files it no longer needs.
+	require.NoError(t, writeRecordFiles(exporter, usersFile, users[:1]))
+	names, err = recordFileNames(outputDir, usersFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"users_000001.json"}, names)
+}
+
+func TestWriteRecordFilesSingleFile(t *testing.T) {
+	outputDir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+
+	users := []data.User{{Login: "a"}, {Login: "b"}, {Login: "c"}}
+	require.NoError(t, writeRecordFiles(exporter, usersFile, users))
+	names, err := recordFileNames(outputDir, usersFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"users_000001.json"}, names)
+
+	// A type without records still gets its first file.
+	require.NoError(t, writeRecordFiles(exporter, reviewsFile, []data.PullRequestReviewComment{}))
+	content, err := os.ReadFile(filepath.Join(outputDir, reviewsFile))
+	require.NoError(t, err)
+	assert.JSONEq(t, "[]", string(content))
+}
+
+func TestReadRecordFilesCorrupt(t *testing.T) {
+	outputDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, usersFile), []byte("[]"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "users_000002.json"), []byte("{"), 0644))
+
+	_, err := readRecordFiles[data.User](outputDir, usersFile)
+	assert.ErrorIs(t, err, ErrCorruptExportFile)
+	assert.ErrorContains(t, err, "users_000002.json")
+}
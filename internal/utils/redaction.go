@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultRedactionReplacement replaces matches of rules without a
+// replacement.
+const defaultRedactionReplacement = "[REDACTED]"
+
+type redactionRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// RedactionRules are regular expressions replaced in exported pull request
+// descriptions and comment bodies, applied in file order.
+type RedactionRules struct {
+	rules []redactionRule
+}
+
+// LoadRedactionRules reads a YAML redaction rules file. It returns nil when
+// path is empty.
+func LoadRedactionRules(path string) (*RedactionRules, error) {
+	if path == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redaction rules file: %w", err)
+	}
+
+	var config data.RedactionRulesConfig
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse redaction rules file %s: %w", path, err)
+	}
+	if len(config.Rules) == 0 {
+		return nil, fmt.Errorf("redaction rules file %s has no rules", path)
+	}
+
+	rules := &RedactionRules{}
+	var invalid []string
+	for i, rule := range config.Rules {
+		if rule.Pattern == "" {
+			invalid = append(invalid, fmt.Sprintf("rule %d: empty pattern", i+1))
+			continue
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			invalid = append(invalid, fmt.Sprintf("rule %d: %v", i+1, err))
+			continue
+		}
+		replacement := defaultRedactionReplacement
+		if rule.Replacement != nil {
+			replacement = *rule.Replacement
+		}
+		rules.rules = append(rules.rules, redactionRule{pattern: pattern, replacement: replacement})
+	}
+	if len(invalid) > 0 {
+		return nil, fmt.Errorf("invalid redaction rules in %s (%s)", path, strings.Join(invalid, "; "))
+	}
+	return rules, nil
+}
+
+// Len returns the number of rules.
+func (r *RedactionRules) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.rules)
+}
+
+// apply returns body with every rule applied.
+func (r *RedactionRules) apply(body string) string {
+	for _, rule := range r.rules {
+		body = rule.pattern.ReplaceAllString(body, rule.replacement)
+	}
+	return body
+}
+
+// SetRedactionRules applies rules to the pull request descriptions and
+// comment bodies the client exports; nil exports them unchanged.
+func (c *Client) SetRedactionRules(rules *RedactionRules) {
+	c.redactionRules = rules
+}
+
+// redact applies the redaction rules to an exported body and counts the
+// bodies they changed.
+func (c *Client) redact(body string) string {
+	if c.redactionRules == nil || body == "" {
+		return body
+	}
+	redacted := c.redactionRules.apply(body)
+	if redacted != body {
+		c.mu.Lock()
+		c.redactedBodies++
+		c.mu.Unlock()
+	}
+	return redacted
+}
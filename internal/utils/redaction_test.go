@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func writeRedactionRules(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "redaction.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoadRedactionRules(t *testing.T) {
+	rules, err := LoadRedactionRules("")
+	require.NoError(t, err)
+	assert.Nil(t, rules, "no file means no redaction")
+
+	rules, err = LoadRedactionRules(writeRedactionRules(t, `rules:
+  - pattern: '[a-z0-9-]+\.corp\.example\.com'
+  - pattern: 'ticket ([A-Z]+)-\d+'
+    replacement: 'ticket $1-XXX'
+  - pattern: 'secret'
+    replacement: ''
+`))
+	require.NoError(t, err)
+	assert.Equal(t, 3, rules.Len())
+	assert.Equal(t, "Deployed to [REDACTED] for ticket OPS-XXX, ",
+		rules.apply("Deployed to build-01.corp.example.com for ticket OPS-142, secret"))
+
+	_, err = LoadRedactionRules(writeRedactionRules(t, "rules:\n  - pattern: '(unclosed'\n  - pattern: ''\n"))
+	assert.ErrorContains(t, err, "rule 1:")
+	assert.ErrorContains(t, err, "rule 2: empty pattern")
+
+	_, err = LoadRedactionRules(writeRedactionRules(t, "rules: []\n"))
+	assert.ErrorContains(t, err, "has no rules")
+
+	_, err = LoadRedactionRules(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.ErrorContains(t, err, "failed to read redaction rules file")
+}
+
+func TestClientRedactCountsChangedBodies(t *testing.T) {
+	client := &Client{logger: zap.NewNop()}
+	assert.Equal(t, "internal.host", client.redact("internal.host"), "no rules leave bodies unchanged")
+
+	rules, err := LoadRedactionRules(writeRedactionRules(t, "rules:\n  - pattern: 'internal\\.host'\n"))
+	require.NoError(t, err)
+	client.SetRedactionRules(rules)
+
+	assert.Equal(t, "see [REDACTED]", client.redact("see internal.host"))
+	assert.Equal(t, "nothing to hide", client.redact("nothing to hide"))
+	assert.Equal(t, 1, client.redactedBodies)
+}
+
+func TestGetPullRequestCommentsRedactsBodies(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(t, w, []byte(`{"values": [
+			{"id": 1, "created_on": "2024-01-01T00:00:00Z", "content": {"raw": "ping build.corp"}, "user": {"uuid": "{a}"}},
+			{"id": 2, "created_on": "2024-01-01T00:00:00Z", "content": {"raw": "uses build.corp"}, "user": {"uuid": "{a}"},
+			 "inline": {"path": "main.go", "to": 3}}], "next": null}`))
+	}))
+	defer testServer.Close()
+
+	client := &Client{baseURL: testServer.URL, httpClient: testServer.Client(), logger: zap.NewNop(),
+		commitSHACache: make(map[string]string), skipCommitLookup: true}
+	rules, err := LoadRedactionRules(writeRedactionRules(t, "rules:\n  - pattern: 'build\\.corp'\n    replacement: 'build-host'\n"))
+	require.NoError(t, err)
+	client.SetRedactionRules(rules)
+
+	regular, review, err := client.GetPullRequestComments("workspace", "repo",
+		[]data.PullRequest{{URL: "https://bitbucket.org/workspace/repo/pull/4", Head: data.PRBranch{SHA: "abc1234"}}})
+
+	require.NoError(t, err)
+	require.Len(t, regular, 1)
+	require.Len(t, review, 1)
+	assert.Equal(t, "ping build-host", regular[0].Body)
+	assert.Equal(t, "uses build-host", review[0].Body)
+	assert.Equal(t, 2, client.redactedBodies)
+}
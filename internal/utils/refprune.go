@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"fmt"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const notesRefPrefix = "refs/notes"
+
+// defaultPrunedRefPrefixes are ref namespaces that show up in mirror clones
+// but are rejected by the GitHub importer.
+var defaultPrunedRefPrefixes = []string{"refs/pull", "refs/stash", notesRefPrefix}
+
+// SetRefPruning configures which refs are deleted from cloned repositories.
+// Extra prefixes are added to the defaults; keepNotes preserves refs/notes.
+func (e *Exporter) SetRefPruning(extraPrefixes []string, keepNotes bool) error {
+	prefixes := []string{}
+	for _, prefix := range defaultPrunedRefPrefixes {
+		if keepNotes && prefix == notesRefPrefix {
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	for _, prefix := range extraPrefixes {
+		prefix = strings.TrimSuffix(strings.TrimSpace(prefix), "/")
+		if !strings.HasPrefix(prefix, "refs/") {
+			return fmt.Errorf("invalid --prune-ref %q: must start with refs/", prefix)
+		}
+		if prefix == "refs/heads" || prefix == "refs/tags" {
+			return fmt.Errorf("invalid --prune-ref %q: pruning every branch or tag is not allowed", prefix)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	e.prunedRefPrefixes = prefixes
+	return nil
+}
+
+// matchesRefPrefix reports whether ref is the prefix itself or lies below it.
+func matchesRefPrefix(ref, prefix string) bool {
+	return ref == prefix || strings.HasPrefix(ref, prefix+"/")
+}
+
+// pruneRefs deletes refs matching the prune list from a cloned repository and
+// records them for the export report.
+func (e *Exporter) pruneRefs(workspace, repoSlug, repoPath string) error {
+	if len(e.prunedRefPrefixes) == 0 {
+		return nil
+	}
+
+	cmd := exec.Command("git", "for-each-ref", "--format=%(refname)")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to list repository refs: %w", err)
+	}
+
+	repository := fmt.Sprintf("%s/%s", workspace, repoSlug)
+	for _, ref := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			continue
+		}
+		for _, prefix := range e.prunedRefPrefixes {
+			if !matchesRefPrefix(ref, prefix) {
+				continue
+			}
+			deleteCmd := exec.Command("git", "update-ref", "-d", ref)
+			deleteCmd.Dir = repoPath
+			if out, err := deleteCmd.CombinedOutput(); err != nil {
+				return fmt.Errorf("failed to prune ref %s: %s: %w", ref, string(out), err)
+			}
+			e.logger.Info("Pruned ref rejected by GitHub import",
+				zap.String("repository", repository),
+				zap.String("ref", ref))
+			pruned := data.PrunedRef{Repository: repository, Ref: ref}
+			if !slices.Contains(e.report.PrunedRefs, pruned) {
+				e.report.PrunedRefs = append(e.report.PrunedRefs, pruned)
+			}
+			break
+		}
+	}
+	return nil
+}
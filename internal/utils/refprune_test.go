@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSetRefPruning(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	assert.Equal(t, []string{"refs/pull", "refs/stash", "refs/notes"}, exporter.prunedRefPrefixes)
+
+	require.NoError(t, exporter.SetRefPruning([]string{"refs/keep-around/"}, true))
+	assert.Equal(t, []string{"refs/pull", "refs/stash", "refs/keep-around"}, exporter.prunedRefPrefixes)
+
+	assert.ErrorContains(t, exporter.SetRefPruning([]string{"keep-around"}, false), "must start with refs/")
+	assert.ErrorContains(t, exporter.SetRefPruning([]string{"refs/heads/"}, false), "not allowed")
+}
+
+func TestMatchesRefPrefix(t *testing.T) {
+	assert.True(t, matchesRefPrefix("refs/stash", "refs/stash"))
+	assert.True(t, matchesRefPrefix("refs/pull/1/head", "refs/pull"))
+	assert.False(t, matchesRefPrefix("refs/pullrequests/1", "refs/pull"))
+	assert.False(t, matchesRefPrefix("refs/heads/main", "refs/notes"))
+}
+
+func TestPruneRefs(t *testing.T) {
+	repoDir := t.TempDir()
+	if err := exec.Command("git", "init", "-b", "main", repoDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	runGit(t, repoDir, "commit", "--allow-empty", "-m", "initial")
+	runGit(t, repoDir, "update-ref", "refs/pull/1/head", "HEAD")
+	runGit(t, repoDir, "update-ref", "refs/stash", "HEAD")
+	runGit(t, repoDir, "notes", "add", "-m", "note")
+	runGit(t, repoDir, "update-ref", "refs/keep-around/abc", "HEAD")
+	runGit(t, repoDir, "tag", "v1")
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	require.NoError(t, exporter.SetRefPruning(nil, true))
+	require.NoError(t, exporter.pruneRefs("ws", "repo", repoDir))
+
+	refs := strings.Fields(runGit(t, repoDir, "for-each-ref", "--format=%(refname)"))
+	assert.ElementsMatch(t, []string{"refs/heads/main", "refs/keep-around/abc", "refs/notes/commits", "refs/tags/v1"}, refs)
+	assert.Equal(t, []data.PrunedRef{
+		{Repository: "ws/repo", Ref: "refs/pull/1/head"},
+		{Repository: "ws/repo", Ref: "refs/stash"},
+	}, exporter.report.PrunedRefs)
+
+	require.NoError(t, exporter.SetRefPruning([]string{"refs/keep-around"}, false))
+	require.NoError(t, exporter.pruneRefs("ws", "repo", repoDir))
+	refs = strings.Fields(runGit(t, repoDir, "for-each-ref", "--format=%(refname)"))
+	assert.ElementsMatch(t, []string{"refs/heads/main", "refs/tags/v1"}, refs)
+	assert.Len(t, exporter.report.PrunedRefs, 4)
+}
@@ -0,0 +1,699 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
+	"go.uber.org/zap"
+)
+
+// Outputs of the repair command: a supplemental archive with only the
+// missing records, or a script that replays them through the GitHub API.
+const (
+	RepairFormatArchive = "archive"
+	RepairFormatScript  = "script"
+)
+
+const (
+	repairReportFile = "repair-report.json"
+	repairScriptFile = "repair.sh"
+	githubPageSize   = 100
+)
+
+// repairRecordFiles are the export files the repair command filters; every
+// other file of the export is copied into a supplemental archive unchanged.
+var repairRecordFiles = []string{
+	repositoriesFile,
+	pullRequestsFile,
+	issueCommentsFile,
+	reviewCommentsFile,
+	reviewThreadsFile,
+	reviewsFile,
+}
+
+// RepairOptions configures RunRepair.
+type RepairOptions struct {
+	ExportPath string // Export directory or its .tar.gz archive
+	Repository string // Repository of the export to repair; optional when it holds one
+	TargetOrg  string
+	TargetRepo string // Defaults to the repository name in the export
+	Format     string
+	OutputDir  string
+}
+
+// ValidateRepairFormat checks the --format value of the repair command.
+func ValidateRepairFormat(format string) error {
+	switch format {
+	case RepairFormatArchive, RepairFormatScript:
+		return nil
+	}
+	return fmt.Errorf("invalid value for --format: %q (supported: %s, %s)",
+		format, RepairFormatArchive, RepairFormatScript)
+}
+
+// githubRecord holds the fields the repair command reads from GitHub's pull
+// request and comment listings.
+type githubRecord struct {
+	Number         int    `json:"number"`
+	IssueURL       string `json:"issue_url"`
+	PullRequestURL string `json:"pull_request_url"`
+	CreatedAt      string `json:"created_at"`
+}
+
+// importedRecords is what a GitHub repository holds after the import: its
+// pull request numbers, and its comments counted by pull request number and
+// creation time, which the importer preserves.
+type importedRecords struct {
+	pullRequests   map[string]bool
+	issueComments  map[string]int
+	reviewComments map[string]int
+}
+
+// repairRecords are the records of one repository of an export.
+type repairRecords struct {
+	repository     map[string]interface{}
+	pullRequests   []map[string]interface{}
+	issueComments  []map[string]interface{}
+	reviewComments []map[string]interface{}
+	reviewThreads  []map[string]interface{}
+	reviews        []map[string]interface{}
+	logins         map[string]string // user URL to login
+}
+
+// repairSet holds the URLs of the records missing from GitHub.
+type repairSet struct {
+	pullRequests   map[string]bool
+	issueComments  map[string]bool
+	reviewComments map[string]bool
+}
+
+// RunRepair compares an export with the GitHub repository it was imported
+// into and writes the missing pull requests and comments to opts.OutputDir,
+// as a supplemental archive or a replay script. It returns the archive or
+// script path, empty when nothing is missing, and the repair report.
+func RunRepair(ctx context.Context, opts RepairOptions, g *APIGetter, logger *zap.Logger) (string, *data.RepairReport, error) {
+	exportDir, cleanup, err := openExport(opts.ExportPath)
+	if err != nil {
+		return "", nil, err
+	}
+	defer cleanup()
+
+	records, err := loadRepairRecords(NewExporter(&Client{logger: logger}, exportDir, logger, false, ""), opts.Repository)
+	if err != nil {
+		return "", nil, err
+	}
+	targetRepo := opts.TargetRepo
+	if targetRepo == "" {
+		targetRepo = recordField(records.repository, "name")
+	}
+	target := opts.TargetOrg + "/" + targetRepo
+
+	logger.Info("Comparing the export with the imported repository",
+		zap.String("repository", recordField(records.repository, "url")),
+		zap.String("target", target),
+		zap.Int("pullRequests", len(records.pullRequests)))
+	imported, err := g.getImportedRecords(ctx, target)
+	if err != nil {
+		return "", nil, err
+	}
+
+	missing := findMissingRecords(records, imported)
+	report := missing.report(records)
+	report.Repository = recordField(records.repository, "url")
+	report.Target = target
+	report.Format = opts.Format
+
+	if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+	client := &Client{logger: logger}
+	client.SetContext(ctx)
+	out := NewExporter(client, opts.OutputDir, logger, false, "")
+	if err := out.writeJSONFile(repairReportFile, report); err != nil {
+		return "", nil, err
+	}
+
+	logger.Info("Compared the export with the imported repository",
+		zap.Int("missingPullRequests", len(report.PullRequests)),
+		zap.Int("missingIssueComments", len(report.IssueComments)),
+		zap.Int("missingReviewComments", len(report.ReviewComments)))
+	if report.Missing() == 0 {
+		logger.Info("Nothing to repair: every pull request and comment of the export is on GitHub")
+		return "", report, nil
+	}
+
+	var output string
+	switch opts.Format {
+	case RepairFormatScript:
+		output, err = out.writeRepairScript(target, records, missing)
+	default:
+		output, err = out.writeRepairArchive(exportDir, records, missing)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return output, report, nil
+}
+
+// openExport returns the export directory to read, unpacking an
+// archive into a temporary directory removed by the returned function.
+func openExport(path string) (string, func(), error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read export: %w", err)
+	}
+	if info.IsDir() {
+		return path, func() {}, nil
+	}
+
+	dir, err := os.MkdirTemp("", "bbc-export-*")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	cleanup := func() {
+		_ = os.RemoveAll(dir)
+	}
+	if err := extractExportArchive(path, dir); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to unpack %s: %w", path, err)
+	}
+	return dir, cleanup, nil
+}
+
+// extractExportArchive unpacks the directories and regular files of a
+// tar.gz export archive into dir.
+func extractExportArchive(archivePath, dir string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("invalid gzip stream: %w", err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.FromSlash(strings.TrimPrefix(header.Name, "./"))
+		if name == "" || name == "." {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("entry %q points outside the archive", header.Name)
+		}
+		path := filepath.Join(dir, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := writeFileAtomic(path, 0644, func(w io.Writer) error {
+				_, err := io.Copy(w, tarReader)
+				return err
+			}); err != nil {
+				return fmt.Errorf("%s: %w", header.Name, err)
+			}
+		}
+	}
+}
+
+// loadRepairRecords reads the records of one repository of an export.
+// repoSlug selects the repository when the export holds several.
+func loadRepairRecords(source *Exporter, repoSlug string) (*repairRecords, error) {
+	files := make(map[string][]map[string]interface{})
+	for _, fileName := range append([]string{usersFile}, repairRecordFiles...) {
+		fileRecords, err := source.readExportRecords(fileName)
+		if err != nil {
+			return nil, err
+		}
+		files[fileName] = fileRecords
+	}
+
+	repository, err := selectRepairRepository(files[repositoriesFile], repoSlug)
+	if err != nil {
+		return nil, err
+	}
+	records := &repairRecords{repository: repository, logins: make(map[string]string)}
+	for _, user := range files[usersFile] {
+		records.logins[recordField(user, "url")] = recordField(user, "login")
+	}
+
+	repoURL := recordField(repository, "url")
+	prURLs := make(map[string]bool)
+	for _, pr := range files[pullRequestsFile] {
+		if recordField(pr, "repository") == repoURL {
+			records.pullRequests = append(records.pullRequests, pr)
+			prURLs[recordField(pr, "url")] = true
+		}
+	}
+	ofRepository := func(fileName string) []map[string]interface{} {
+		var selected []map[string]interface{}
+		for _, record := range files[fileName] {
+			if prURLs[recordField(record, "pull_request")] {
+				selected = append(selected, record)
+			}
+		}
+		return selected
+	}
+	records.issueComments = ofRepository(issueCommentsFile)
+	records.reviewComments = ofRepository(reviewCommentsFile)
+	records.reviewThreads = ofRepository(reviewThreadsFile)
+	records.reviews = ofRepository(reviewsFile)
+	return records, nil
+}
+
+// selectRepairRepository returns the repository record named repoSlug, or
+// the only repository of the export when repoSlug is empty.
+func selectRepairRepository(repositories []map[string]interface{}, repoSlug string) (map[string]interface{}, error) {
+	if repoSlug == "" {
+		if len(repositories) == 1 {
+			return repositories[0], nil
+		}
+		return nil, fmt.Errorf("the export holds %d repositories; choose one with --repo", len(repositories))
+	}
+	for _, repository := range repositories {
+		if strings.EqualFold(recordField(repository, "slug"), repoSlug) ||
+			strings.EqualFold(recordField(repository, "name"), repoSlug) {
+			return repository, nil
+		}
+	}
+	return nil, fmt.Errorf("repository %s is not in the export", repoSlug)
+}
+
+// getImportedRecords lists the pull requests and comments of a GitHub
+// repository, given as owner/name.
+func (g *APIGetter) getImportedRecords(ctx context.Context, repository string) (*importedRecords, error) {
+	imported := &importedRecords{
+		pullRequests:   make(map[string]bool),
+		issueComments:  make(map[string]int),
+		reviewComments: make(map[string]int),
+	}
+
+	prs, err := g.listGitHubRecords(ctx, fmt.Sprintf("repos/%s/pulls?state=all", repository))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pull requests of %s: %w", repository, err)
+	}
+	for _, pr := range prs {
+		imported.pullRequests[fmt.Sprintf("%d", pr.Number)] = true
+	}
+
+	comments, err := g.listGitHubRecords(ctx, fmt.Sprintf("repos/%s/issues/comments?", repository))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list issue comments of %s: %w", repository, err)
+	}
+	for _, comment := range comments {
+		imported.issueComments[repairKey(lastPathSegment(comment.IssueURL), comment.CreatedAt)]++
+	}
+
+	reviewComments, err := g.listGitHubRecords(ctx, fmt.Sprintf("repos/%s/pulls/comments?", repository))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list review comments of %s: %w", repository, err)
+	}
+	for _, comment := range reviewComments {
+		imported.reviewComments[repairKey(lastPathSegment(comment.PullRequestURL), comment.CreatedAt)]++
+	}
+	return imported, nil
+}
+
+// listGitHubRecords reads every page of a GitHub listing. path ends with
+// its query string, or "?" without one.
+func (g *APIGetter) listGitHubRecords(ctx context.Context, path string) ([]githubRecord, error) {
+	separator := "&"
+	if strings.HasSuffix(path, "?") {
+		separator = ""
+	}
+	var records []githubRecord
+	for page := 1; ; page++ {
+		var pageRecords []githubRecord
+		pagePath := fmt.Sprintf("%s%sper_page=%d&page=%d", path, separator, githubPageSize, page)
+		if err := g.restClient.DoWithContext(ctx, "GET", pagePath, nil, &pageRecords); err != nil {
+			return nil, err
+		}
+		records = append(records, pageRecords...)
+		if len(pageRecords) < githubPageSize {
+			return records, nil
+		}
+	}
+}
+
+func lastPathSegment(url string) string {
+	return url[strings.LastIndex(url, "/")+1:]
+}
+
+// repairKey identifies a comment by its pull request number and creation
+// time, normalized to UTC seconds.
+func repairKey(prNumber, createdAt string) string {
+	if parsed, err := time.Parse(time.RFC3339, createdAt); err == nil {
+		createdAt = parsed.UTC().Format(time.RFC3339)
+	}
+	return prNumber + "@" + createdAt
+}
+
+// findMissingRecords returns the pull requests and comments of the export
+// that are not on GitHub. Comments of a missing pull request are missing
+// too; other comments are matched by pull request and creation time.
+func findMissingRecords(records *repairRecords, imported *importedRecords) repairSet {
+	missing := repairSet{
+		pullRequests:   make(map[string]bool),
+		issueComments:  make(map[string]bool),
+		reviewComments: make(map[string]bool),
+	}
+	for _, pr := range records.pullRequests {
+		if !imported.pullRequests[extractPRNumber(format.URLs{}, recordField(pr, "url"))] {
+			missing.pullRequests[recordField(pr, "url")] = true
+		}
+	}
+
+	match := func(comments []map[string]interface{}, counts map[string]int, into map[string]bool) {
+		for _, comment := range comments {
+			prURL := recordField(comment, "pull_request")
+			key := repairKey(extractPRNumber(format.URLs{}, prURL), recordField(comment, "created_at"))
+			if !missing.pullRequests[prURL] && counts[key] > 0 {
+				counts[key]--
+				continue
+			}
+			into[recordField(comment, "url")] = true
+		}
+	}
+	match(records.issueComments, imported.issueComments, missing.issueComments)
+	match(records.reviewComments, imported.reviewComments, missing.reviewComments)
+	return missing
+}
+
+// report lists the missing records in export order.
+func (s repairSet) report(records *repairRecords) *data.RepairReport {
+	report := &data.RepairReport{PullRequests: []string{}, IssueComments: []string{}, ReviewComments: []string{}}
+	for _, pr := range records.pullRequests {
+		if s.pullRequests[recordField(pr, "url")] {
+			report.PullRequests = append(report.PullRequests, recordField(pr, "url"))
+		}
+	}
+	for _, comment := range records.issueComments {
+		if s.issueComments[recordField(comment, "url")] {
+			report.IssueComments = append(report.IssueComments, recordField(comment, "url"))
+		}
+	}
+	for _, comment := range records.reviewComments {
+		if s.reviewComments[recordField(comment, "url")] {
+			report.ReviewComments = append(report.ReviewComments, recordField(comment, "url"))
+		}
+	}
+	return report
+}
+
+// writeRepairArchive writes a supplemental export of the missing records to
+// the output directory and archives it. Pull requests that are on GitHub
+// but miss comments are included so the comments can be imported, as are
+// the reviews and threads of missing review comments.
+func (e *Exporter) writeRepairArchive(sourceDir string, records *repairRecords, missing repairSet) (string, error) {
+	if err := copyRepairExport(sourceDir, e.outputDir, records.repository); err != nil {
+		return "", fmt.Errorf("failed to copy the export: %w", err)
+	}
+
+	prs := make(map[string]bool)
+	reviews := make(map[string]bool)
+	threads := make(map[string]bool)
+	for url := range missing.pullRequests {
+		prs[url] = true
+	}
+	for _, comment := range records.issueComments {
+		if missing.issueComments[recordField(comment, "url")] {
+			prs[recordField(comment, "pull_request")] = true
+		}
+	}
+	for _, comment := range records.reviewComments {
+		if missing.reviewComments[recordField(comment, "url")] {
+			prs[recordField(comment, "pull_request")] = true
+			reviews[recordField(comment, "pull_request_review")] = true
+			threads[recordField(comment, "pull_request_review_thread")] = true
+		}
+	}
+
+	selectRecords := func(all []map[string]interface{}, keep func(record map[string]interface{}) bool) []map[string]interface{} {
+		selected := []map[string]interface{}{}
+		for _, record := range all {
+			if keep(record) {
+				selected = append(selected, record)
+			}
+		}
+		return selected
+	}
+	ofMissingPR := func(record map[string]interface{}) bool {
+		return missing.pullRequests[recordField(record, "pull_request")]
+	}
+	files := []struct {
+		name    string
+		records []map[string]interface{}
+	}{
+		{repositoriesFile, []map[string]interface{}{records.repository}},
+		{pullRequestsFile, selectRecords(records.pullRequests, func(record map[string]interface{}) bool {
+			return prs[recordField(record, "url")]
+		})},
+		{issueCommentsFile, selectRecords(records.issueComments, func(record map[string]interface{}) bool {
+			return missing.issueComments[recordField(record, "url")]
+		})},
+		{reviewCommentsFile, selectRecords(records.reviewComments, func(record map[string]interface{}) bool {
+			return missing.reviewComments[recordField(record, "url")]
+		})},
+		{reviewThreadsFile, selectRecords(records.reviewThreads, func(record map[string]interface{}) bool {
+			return ofMissingPR(record) || threads[recordField(record, "url")]
+		})},
+		{reviewsFile, selectRecords(records.reviews, func(record map[string]interface{}) bool {
+			return ofMissingPR(record) || reviews[recordField(record, "url")]
+		})},
+	}
+	for _, file := range files {
+		if len(file.records) == 0 {
+			continue
+		}
+		if err := writeRecordFiles(e, file.name, file.records); err != nil {
+			return "", err
+		}
+	}
+
+	archivePath, err := e.CreateArchive()
+	if err != nil {
+		return "", err
+	}
+	e.logger.Info("Wrote supplemental archive with the missing records",
+		zap.String("archive", archivePath),
+		zap.Int("pullRequests", len(files[1].records)))
+	return archivePath, nil
+}
+
+// copyRepairExport copies an export directory for a supplemental archive,
+// without sidecar files, the files of the filtered record types and the git
+// trees of other repositories. Files are hard-linked where possible.
+func copyRepairExport(sourceDir, targetDir string, repository map[string]interface{}) error {
+	keepGitDir := strings.TrimPrefix(recordField(repository, "git_url"), "tarball://root/")
+	keepWikiDir := strings.TrimSuffix(keepGitDir, ".git") + ".wiki.git"
+
+	return filepath.WalkDir(sourceDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil || relPath == "." {
+			return err
+		}
+		unixPath := ToUnixPath(relPath)
+		if sidecarPaths[unixPath] || isExportReportFile(unixPath) || isRepairRecordFile(unixPath) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() && strings.HasSuffix(unixPath, ".git") && strings.HasPrefix(unixPath, "repositories/") &&
+			unixPath != keepGitDir && unixPath != keepWikiDir {
+			return filepath.SkipDir
+		}
+
+		targetPath := filepath.Join(targetDir, relPath)
+		if entry.IsDir() {
+			return os.MkdirAll(targetPath, 0755)
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		return linkOrCopyFile(path, targetPath)
+	})
+}
+
+func isRepairRecordFile(name string) bool {
+	for _, firstFile := range repairRecordFiles {
+		if isRecordFile(name, firstFile) {
+			return true
+		}
+	}
+	return false
+}
+
+// linkOrCopyFile hard-links src to dst, and copies it when the link fails,
+// e.g. across file systems.
+func linkOrCopyFile(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	return writeFileAtomic(dst, info.Mode().Perm(), func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
+
+// writeRepairScript writes a script that replays the missing records to the
+// GitHub repository through gh api.
+func (e *Exporter) writeRepairScript(target string, records *repairRecords, missing repairSet) (string, error) {
+	script := repairScript(target, records, missing)
+	scriptPath := filepath.Join(e.outputDir, repairScriptFile)
+	err := writeFileAtomic(scriptPath, 0755, func(w io.Writer) error {
+		_, err := io.WriteString(w, script)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", repairScriptFile, err)
+	}
+	e.logger.Info("Wrote script that replays the missing records", zap.String("script", scriptPath))
+	return scriptPath, nil
+}
+
+func repairScript(target string, records *repairRecords, missing repairSet) string {
+	var script strings.Builder
+	fmt.Fprintf(&script, `#!/usr/bin/env bash
+# Generated by gh-bbc-exporter: replays the pull requests and comments that
+# are missing from %s after the import, as listed in repair-report.json.
+# The records are created by the authenticated gh user and name their
+# original author. Run with gh authenticated for the target host:
+#   ./repair.sh
+# Set GH_HOST for GitHub Enterprise (e.g. example.ghe.com).
+set -uo pipefail
+
+REPO=%s
+failed=0
+
+create_pull_request() {
+  gh api "repos/${REPO}/pulls" -f title="$1" -f head="$2" -f base="$3" -f body="$4" --jq .number
+}
+
+close_pull_request() {
+  if ! gh api -X PATCH "repos/${REPO}/pulls/$1" -f state=closed > /dev/null; then
+    echo "Failed to close pull request #$1" >&2
+    failed=$((failed + 1))
+  fi
+}
+
+add_comment() {
+  if ! gh api "repos/${REPO}/issues/$1/comments" -f body="$2" > /dev/null; then
+    echo "Failed to add comment $3" >&2
+    failed=$((failed + 1))
+  fi
+}
+
+add_review_comment() {
+  if ! gh api "repos/${REPO}/pulls/$1/comments" -f body="$2" -f commit_id="$3" -f path="$4" -F position="$5" > /dev/null; then
+    echo "Failed to add review comment $6" >&2
+    failed=$((failed + 1))
+  fi
+}
+`, target, shellQuote(target))
+
+	commentCalls := func(prURL, prNumber string) []string {
+		var calls []string
+		for _, comment := range records.issueComments {
+			if recordField(comment, "pull_request") == prURL && missing.issueComments[recordField(comment, "url")] {
+				calls = append(calls, fmt.Sprintf("add_comment %s %s %s", prNumber,
+					shellQuote(records.attributedBody(comment)), shellQuote(recordField(comment, "url"))))
+			}
+		}
+		for _, comment := range records.reviewComments {
+			if recordField(comment, "pull_request") == prURL && missing.reviewComments[recordField(comment, "url")] {
+				commitID := recordField(comment, "commit_id")
+				if commitID == "" {
+					commitID = recordField(comment, "original_commit_id")
+				}
+				position, _ := comment["position"].(float64)
+				calls = append(calls, fmt.Sprintf("add_review_comment %s %s %s %s %d %s", prNumber,
+					shellQuote(records.attributedBody(comment)), shellQuote(commitID),
+					shellQuote(recordField(comment, "path")), int(position), shellQuote(recordField(comment, "url"))))
+			}
+		}
+		return calls
+	}
+
+	for _, pr := range records.pullRequests {
+		prURL := recordField(pr, "url")
+		if missing.pullRequests[prURL] {
+			fmt.Fprintf(&script, "\n# %s\n", prURL)
+			fmt.Fprintf(&script, "if pr=$(create_pull_request %s %s %s %s); then\n",
+				shellQuote(recordField(pr, "title")), shellQuote(recordField(pr, "head.ref")),
+				shellQuote(recordField(pr, "base.ref")), shellQuote(records.attributedBody(pr)))
+			fmt.Fprintf(&script, "  echo \"Created pull request #${pr} for %s\"\n", prURL)
+			for _, call := range commentCalls(prURL, `"${pr}"`) {
+				fmt.Fprintf(&script, "  %s\n", call)
+			}
+			if pr["closed_at"] != nil || pr["merged_at"] != nil {
+				script.WriteString("  close_pull_request \"${pr}\"\n")
+			}
+			fmt.Fprintf(&script, "else\n  echo \"Failed to create pull request %s\" >&2\n  failed=$((failed + 1))\nfi\n", prURL)
+			continue
+		}
+		calls := commentCalls(prURL, extractPRNumber(format.URLs{}, prURL))
+		if len(calls) == 0 {
+			continue
+		}
+		fmt.Fprintf(&script, "\n# %s\n", prURL)
+		for _, call := range calls {
+			fmt.Fprintf(&script, "%s\n", call)
+		}
+	}
+
+	script.WriteString(`
+if [ "${failed}" -gt 0 ]; then
+  echo "${failed} records could not be replayed" >&2
+  exit 1
+fi
+`)
+	return script.String()
+}
+
+// attributedBody returns the body of a pull request or comment, headed by
+// its original author and date since the API creates it as the caller.
+func (r *repairRecords) attributedBody(record map[string]interface{}) string {
+	author := r.logins[recordField(record, "user")]
+	if author == "" {
+		author = "a Bitbucket user"
+	}
+	return fmt.Sprintf("_Originally posted by %s on %s: %s_\n\n%s",
+		author, recordField(record, "created_at"), recordField(record, "url"), recordField(record, "body"))
+}
@@ -0,0 +1,287 @@
+package utils
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const repairRepoURL = "https://bitbucket.org/workspace/repo"
+
+// repairFixture writes an export with two pull requests: #1 with an issue
+// comment and a review comment, and #2 with one issue comment.
+func repairFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, dir, zap.NewNop(), false, "")
+	files := map[string]interface{}{
+		usersFile: []map[string]interface{}{
+			{"url": "https://bitbucket.org/alice", "login": "alice"},
+		},
+		repositoriesFile: []map[string]interface{}{
+			{"url": repairRepoURL, "name": "repo", "slug": "repo",
+				"git_url": "tarball://root/repositories/workspace/repo.git"},
+			{"url": "https://bitbucket.org/workspace/other", "name": "other", "slug": "other",
+				"git_url": "tarball://root/repositories/workspace/other.git"},
+		},
+		pullRequestsFile: []map[string]interface{}{
+			{"url": repairRepoURL + "/pull/1", "repository": repairRepoURL, "title": "First",
+				"user": "https://bitbucket.org/alice", "body": "first body", "created_at": "2024-01-01T00:00:00Z",
+				"head": map[string]interface{}{"ref": "feature"}, "base": map[string]interface{}{"ref": "main"},
+				"closed_at": "2024-01-03T00:00:00Z"},
+			{"url": repairRepoURL + "/pull/2", "repository": repairRepoURL, "title": "Second",
+				"user": "https://bitbucket.org/alice", "created_at": "2024-02-01T00:00:00Z",
+				"head": map[string]interface{}{"ref": "fix"}, "base": map[string]interface{}{"ref": "main"}},
+			{"url": "https://bitbucket.org/workspace/other/pull/1", "repository": "https://bitbucket.org/workspace/other"},
+		},
+		issueCommentsFile: []map[string]interface{}{
+			{"url": repairRepoURL + "/pull/1#issuecomment-10", "pull_request": repairRepoURL + "/pull/1",
+				"user": "https://bitbucket.org/alice", "body": "on first", "created_at": "2024-01-02T00:00:00Z"},
+			{"url": repairRepoURL + "/pull/2#issuecomment-20", "pull_request": repairRepoURL + "/pull/2",
+				"user": "https://bitbucket.org/alice", "body": "it's on second", "created_at": "2024-02-02T00:00:00Z"},
+			{"url": repairRepoURL + "/pull/2#issuecomment-21", "pull_request": repairRepoURL + "/pull/2",
+				"user": "https://bitbucket.org/alice", "body": "imported", "created_at": "2024-02-03T00:00:00Z"},
+		},
+		reviewCommentsFile: []map[string]interface{}{
+			{"url": repairRepoURL + "/pull/1/files#r30", "pull_request": repairRepoURL + "/pull/1",
+				"pull_request_review":        repairRepoURL + "/pull/1/files#pullrequestreview-30",
+				"pull_request_review_thread": repairRepoURL + "/pull/1/files#pullrequestreviewthread-30",
+				"user":                       "https://bitbucket.org/alice", "body": "inline", "created_at": "2024-01-02T01:00:00Z",
+				"commit_id": "abc123", "path": "main.go", "position": 3},
+		},
+		reviewsFile: []map[string]interface{}{
+			{"url": repairRepoURL + "/pull/1/files#pullrequestreview-30", "pull_request": repairRepoURL + "/pull/1"},
+		},
+		reviewThreadsFile: []map[string]interface{}{
+			{"url": repairRepoURL + "/pull/1/files#pullrequestreviewthread-30", "pull_request": repairRepoURL + "/pull/1"},
+		},
+	}
+	for name, records := range files {
+		require.NoError(t, exporter.writeJSONFile(name, records))
+	}
+	for _, repo := range []string{"repo", "other"} {
+		gitDir := filepath.Join(dir, "repositories", "workspace", repo+".git")
+		require.NoError(t, os.MkdirAll(gitDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("ref: refs/heads/main\n"), 0644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, exportReportFile), []byte("{}"), 0644))
+	return dir
+}
+
+// repairGitHub serves a GitHub repository into which pull request #2 and
+// its second comment were imported.
+func repairGitHub(t *testing.T) *APIGetter {
+	t.Helper()
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+		switch r.URL.Path {
+		case "/repos/org/repo/pulls":
+			assert.Equal(t, "all", r.URL.Query().Get("state"))
+			writeResponse(t, w, []byte(`[{"number": 2}]`))
+		case "/repos/org/repo/issues/comments":
+			writeResponse(t, w, []byte(`[{"issue_url": "https://api.github.com/repos/org/repo/issues/2",
+				"created_at": "2024-02-03T00:00:00Z"}]`))
+		case "/repos/org/repo/pulls/comments":
+			writeResponse(t, w, []byte(`[]`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(testServer.Close)
+
+	return testServerAPIGetter(t, testServer)
+}
+
+// testServerTransport sends every request to a test server.
+type testServerTransport struct {
+	server *httptest.Server
+}
+
+func (s testServerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(s.server.URL)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// testServerAPIGetter returns an APIGetter whose REST client talks to
+// testServer as github.com.
+func testServerAPIGetter(t *testing.T, testServer *httptest.Server) *APIGetter {
+	t.Helper()
+	restClient, err := api.NewRESTClient(api.ClientOptions{
+		Host: "github.com", AuthToken: "test-token", Transport: testServerTransport{testServer},
+	})
+	require.NoError(t, err)
+	return NewAPIGetter(&api.GraphQLClient{}, restClient, "test-token")
+}
+
+func TestValidateRepairFormat(t *testing.T) {
+	assert.NoError(t, ValidateRepairFormat(RepairFormatArchive))
+	assert.NoError(t, ValidateRepairFormat(RepairFormatScript))
+	assert.ErrorContains(t, ValidateRepairFormat("patch"), "invalid value for --format")
+}
+
+func TestRepairKey(t *testing.T) {
+	assert.Equal(t, "3@2024-01-02T00:00:00Z", repairKey("3", "2024-01-02T01:00:00+01:00"))
+	assert.Equal(t, "3@not a time", repairKey("3", "not a time"))
+}
+
+func TestSelectRepairRepository(t *testing.T) {
+	repos := []map[string]interface{}{{"slug": "repo", "name": "repo"}, {"slug": "other", "name": "Other"}}
+
+	repo, err := selectRepairRepository(repos, "OTHER")
+	require.NoError(t, err)
+	assert.Equal(t, "other", repo["slug"])
+	_, err = selectRepairRepository(repos, "")
+	assert.ErrorContains(t, err, "the export holds 2 repositories; choose one with --repo")
+	_, err = selectRepairRepository(repos, "missing")
+	assert.ErrorContains(t, err, "repository missing is not in the export")
+}
+
+func TestFindMissingRecords(t *testing.T) {
+	records, err := loadRepairRecords(NewExporter(&Client{logger: zap.NewNop()}, repairFixture(t), zap.NewNop(), false, ""), "repo")
+	require.NoError(t, err)
+	require.Len(t, records.pullRequests, 2, "pull requests of other repositories are ignored")
+
+	missing := findMissingRecords(records, &importedRecords{
+		pullRequests:   map[string]bool{"2": true},
+		issueComments:  map[string]int{"2@2024-02-03T00:00:00Z": 1},
+		reviewComments: map[string]int{},
+	})
+	report := missing.report(records)
+	assert.Equal(t, []string{repairRepoURL + "/pull/1"}, report.PullRequests)
+	assert.Equal(t, []string{repairRepoURL + "/pull/1#issuecomment-10", repairRepoURL + "/pull/2#issuecomment-20"},
+		report.IssueComments)
+	assert.Equal(t, []string{repairRepoURL + "/pull/1/files#r30"}, report.ReviewComments)
+}
+
+func TestRunRepairScript(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "repair")
+	output, report, err := RunRepair(context.Background(), RepairOptions{
+		ExportPath: repairFixture(t), Repository: "repo", TargetOrg: "org",
+		Format: RepairFormatScript, OutputDir: outputDir,
+	}, repairGitHub(t), zap.NewNop())
+	require.NoError(t, err)
+
+	assert.Equal(t, filepath.Join(outputDir, repairScriptFile), output)
+	assert.Equal(t, "org/repo", report.Target)
+	assert.Equal(t, 4, report.Missing())
+	assert.FileExists(t, filepath.Join(outputDir, repairReportFile))
+
+	script, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(script), "REPO='org/repo'")
+	assert.Contains(t, string(script), "if pr=$(create_pull_request 'First' 'feature' 'main' '_Originally posted by alice")
+	assert.Contains(t, string(script), `  add_review_comment "${pr}" '_Originally posted by alice`)
+	assert.Contains(t, string(script), `'abc123' 'main.go' 3 '`+repairRepoURL+`/pull/1/files#r30'`)
+	assert.Contains(t, string(script), `  close_pull_request "${pr}"`)
+	assert.Contains(t, string(script), `add_comment 2 '_Originally posted by alice on 2024-02-02T00:00:00Z: `+
+		repairRepoURL+`/pull/2#issuecomment-20_
+
+it'\''s on second'`)
+	assert.NotContains(t, string(script), "issuecomment-21", "imported comments are not replayed")
+}
+
+func TestRunRepairArchive(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "repair")
+	output, _, err := RunRepair(context.Background(), RepairOptions{
+		ExportPath: repairFixture(t), Repository: "repo", TargetOrg: "org",
+		Format: RepairFormatArchive, OutputDir: outputDir,
+	}, repairGitHub(t), zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, outputDir+".tar.gz", output)
+
+	extracted := t.TempDir()
+	require.NoError(t, extractExportArchive(output, extracted))
+	assert.FileExists(t, filepath.Join(extracted, "repositories", "workspace", "repo.git", "HEAD"))
+	assert.NoDirExists(t, filepath.Join(extracted, "repositories", "workspace", "other.git"))
+	assert.NoFileExists(t, filepath.Join(extracted, exportReportFile))
+	assert.NoFileExists(t, filepath.Join(extracted, repairReportFile))
+
+	supplemental := NewExporter(&Client{logger: zap.NewNop()}, extracted, zap.NewNop(), false, "")
+	prs, err := supplemental.readExportRecords(pullRequestsFile)
+	require.NoError(t, err)
+	require.Len(t, prs, 2, "pull request #2 is included for its missing comment")
+	comments, err := supplemental.readExportRecords(issueCommentsFile)
+	require.NoError(t, err)
+	assert.Len(t, comments, 2)
+	reviews, err := supplemental.readExportRecords(reviewsFile)
+	require.NoError(t, err)
+	assert.Len(t, reviews, 1)
+	repos, err := supplemental.readExportRecords(repositoriesFile)
+	require.NoError(t, err)
+	assert.Len(t, repos, 1)
+}
+
+func TestRunRepairNothingMissing(t *testing.T) {
+	exportDir := repairFixture(t)
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, exportDir, zap.NewNop(), false, "")
+	require.NoError(t, exporter.writeJSONFile(pullRequestsFile, []map[string]interface{}{
+		{"url": repairRepoURL + "/pull/2", "repository": repairRepoURL},
+	}))
+	require.NoError(t, exporter.writeJSONFile(issueCommentsFile, []map[string]interface{}{
+		{"url": repairRepoURL + "/pull/2#issuecomment-21", "pull_request": repairRepoURL + "/pull/2",
+			"created_at": "2024-02-03T00:00:00Z"},
+	}))
+
+	output, report, err := RunRepair(context.Background(), RepairOptions{
+		ExportPath: exportDir, Repository: "repo", TargetOrg: "org",
+		Format: RepairFormatArchive, OutputDir: filepath.Join(t.TempDir(), "repair"),
+	}, repairGitHub(t), zap.NewNop())
+	require.NoError(t, err)
+	assert.Empty(t, output)
+	assert.Zero(t, report.Missing())
+}
+
+func TestListGitHubRecordsPaginates(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := 1
+		if r.URL.Query().Get("page") == "1" {
+			count = githubPageSize
+		}
+		items := make([]string, count)
+		for i := range items {
+			items[i] = fmt.Sprintf(`{"number": %d}`, i+1)
+		}
+		writeResponse(t, w, []byte("["+strings.Join(items, ",")+"]"))
+	}))
+	defer testServer.Close()
+
+	records, err := testServerAPIGetter(t, testServer).listGitHubRecords(context.Background(), "repos/org/repo/pulls?state=all")
+	require.NoError(t, err)
+	assert.Len(t, records, githubPageSize+1)
+}
+
+func TestExtractExportArchiveRejectsEscapingPaths(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "export.tar.gz")
+	file, err := os.Create(archivePath)
+	require.NoError(t, err)
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: "../escape.json", Mode: 0644, Size: 2, Typeflag: tar.TypeReg}))
+	_, err = tarWriter.Write([]byte("[]"))
+	require.NoError(t, err)
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, gzipWriter.Close())
+	require.NoError(t, file.Close())
+
+	err = extractExportArchive(archivePath, t.TempDir())
+	assert.ErrorContains(t, err, "points outside the archive")
+}
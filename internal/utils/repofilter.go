@@ -0,0 +1,166 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+// repositoryList is the value of a --repo flag that can be repeated or given
+// a comma-separated list. The slugs are kept comma-separated in the string
+// it points to; RepositorySlugs splits them.
+type repositoryList struct {
+	value   *string
+	changed bool
+}
+
+// NewRepositoryListValue returns a --repo flag value that collects every
+// repository it is given into *p.
+func NewRepositoryListValue(p *string) pflag.Value {
+	return &repositoryList{value: p}
+}
+
+func (r *repositoryList) Set(value string) error {
+	if !r.changed || *r.value == "" {
+		*r.value = value
+	} else {
+		*r.value += "," + value
+	}
+	r.changed = true
+	return nil
+}
+
+func (r *repositoryList) String() string {
+	return *r.value
+}
+
+func (r *repositoryList) Type() string {
+	return "strings"
+}
+
+// RepositorySlugs returns the repositories selected with --repo, in the
+// order given. Several repositories are exported into one archive.
+func RepositorySlugs(cmdFlags *data.CmdExportFlags) []string {
+	var slugs []string
+	for _, slug := range strings.Split(cmdFlags.Repository, ",") {
+		if slug = strings.TrimSpace(slug); slug != "" {
+			slugs = append(slugs, slug)
+		}
+	}
+	return slugs
+}
+
+// validateRepositorySlugs reports repositories listed more than once and
+// empty entries in a --repo list.
+func validateRepositorySlugs(repository string) error {
+	var problems []error
+	seen := make(map[string]bool)
+	for _, slug := range strings.Split(repository, ",") {
+		slug = strings.TrimSpace(slug)
+		if slug == "" {
+			return fmt.Errorf("invalid value for --repo: %q contains an empty repository name", repository)
+		}
+		if seen[strings.ToLower(slug)] {
+			problems = append(problems, fmt.Errorf("repository %s is given more than once in --repo", slug))
+		}
+		seen[strings.ToLower(slug)] = true
+	}
+	return JoinProblems(problems...)
+}
+
+// LoadRepositoryList reads a repository list file with one repository slug or
+// glob pattern per line, e.g. "payments-*". Blank lines and lines starting
+// with "#" are ignored, and a leading "workspace/" is accepted so lists can be
+// built from full repository names.
+func LoadRepositoryList(fileName string) ([]string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository list: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.LastIndex(line, "/"); i >= 0 {
+			line = line[i+1:]
+		}
+		line = strings.ToLower(line)
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q on line %d of %s: %w", line, lineNumber, fileName, err)
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read repository list: %w", err)
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("repository list %s contains no repositories", fileName)
+	}
+	return patterns, nil
+}
+
+func matchesRepositoryPattern(slug string, patterns []string) (string, bool) {
+	slug = strings.ToLower(slug)
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, slug); matched {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// FilterRepositoriesByList keeps the repositories matching an include pattern
+// (all repositories when include is empty) and drops those matching an
+// exclude pattern. Include patterns that match nothing are logged, since they
+// usually point to a typo or a renamed repository in a migration wave file.
+func FilterRepositoriesByList(repositories []data.BitbucketRepository, include, exclude []string, logger *zap.Logger) []data.BitbucketRepository {
+	if len(include) == 0 && len(exclude) == 0 {
+		return repositories
+	}
+
+	usedPatterns := make(map[string]bool)
+	filtered := make([]data.BitbucketRepository, 0, len(repositories))
+	for _, repo := range repositories {
+		if len(include) > 0 {
+			pattern, ok := matchesRepositoryPattern(repo.Slug, include)
+			if !ok {
+				continue
+			}
+			usedPatterns[pattern] = true
+		}
+		if pattern, ok := matchesRepositoryPattern(repo.Slug, exclude); ok {
+			logger.Debug("Repository excluded by repository list",
+				zap.String("repository", repo.Slug),
+				zap.String("pattern", pattern))
+			continue
+		}
+		filtered = append(filtered, repo)
+	}
+
+	for _, pattern := range include {
+		if !usedPatterns[pattern] {
+			logger.Warn("Include pattern did not match any repository in the workspace",
+				zap.String("pattern", pattern))
+		}
+	}
+	logger.Info("Applied repository lists",
+		zap.Int("repositories", len(repositories)),
+		zap.Int("selected", len(filtered)))
+
+	return filtered
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func writeRepositoryList(t *testing.T, content string) string {
+	t.Helper()
+	fileName := filepath.Join(t.TempDir(), "wave.txt")
+	require.NoError(t, os.WriteFile(fileName, []byte(content), 0644))
+	return fileName
+}
+
+func TestRepositoryListValue(t *testing.T) {
+	repository := "default"
+	value := NewRepositoryListValue(&repository)
+	require.NoError(t, value.Set("repo-a"))
+	require.NoError(t, value.Set("repo-b, repo-c"))
+	assert.Equal(t, "repo-a,repo-b, repo-c", value.String())
+	assert.Equal(t, []string{"repo-a", "repo-b", "repo-c"},
+		RepositorySlugs(&data.CmdExportFlags{Repository: repository}))
+	assert.Nil(t, RepositorySlugs(&data.CmdExportFlags{}))
+}
+
+func TestValidateRepositorySlugs(t *testing.T) {
+	assert.NoError(t, validateRepositorySlugs("repo-a,repo-b"))
+	assert.ErrorContains(t, validateRepositorySlugs("repo-a,,repo-b"), "contains an empty repository name")
+	assert.ErrorContains(t, validateRepositorySlugs("repo-a,repo-b,Repo-A"), "repository Repo-A is given more than once")
+}
+
+func TestLoadRepositoryList(t *testing.T) {
+	fileName := writeRepositoryList(t, "# wave 1\n\npayments-*\n  my-workspace/Billing-API  \n")
+
+	patterns, err := LoadRepositoryList(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"payments-*", "billing-api"}, patterns)
+}
+
+func TestLoadRepositoryListErrors(t *testing.T) {
+	_, err := LoadRepositoryList(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.ErrorContains(t, err, "failed to read repository list")
+
+	_, err = LoadRepositoryList(writeRepositoryList(t, "# nothing here\n"))
+	assert.ErrorContains(t, err, "contains no repositories")
+
+	_, err = LoadRepositoryList(writeRepositoryList(t, "repo-[a\n"))
+	assert.ErrorContains(t, err, "invalid pattern \"repo-[a\" on line 1")
+}
+
+func TestFilterRepositoriesByList(t *testing.T) {
+	repositories := []data.BitbucketRepository{
+		{Slug: "payments-api"}, {Slug: "payments-legacy"}, {Slug: "billing"}, {Slug: "docs"},
+	}
+	slugs := func(repos []data.BitbucketRepository) []string {
+		result := make([]string, 0, len(repos))
+		for _, repo := range repos {
+			result = append(result, repo.Slug)
+		}
+		return result
+	}
+	logger := zap.NewNop()
+
+	assert.Equal(t, slugs(repositories), slugs(FilterRepositoriesByList(repositories, nil, nil, logger)))
+	assert.Equal(t, []string{"payments-api", "payments-legacy", "billing"},
+		slugs(FilterRepositoriesByList(repositories, []string{"payments-*", "billing", "unknown"}, nil, logger)))
+	assert.Equal(t, []string{"billing", "docs"},
+		slugs(FilterRepositoriesByList(repositories, nil, []string{"payments-*"}, logger)))
+	assert.Equal(t, []string{"payments-api"},
+		slugs(FilterRepositoriesByList(repositories, []string{"payments-*"}, []string{"*-legacy"}, logger)))
+}
+
+func TestExportWorkspaceRepositoryListsMatchNothing(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		writeResponse(t, w, []byte(`{"values": [{"slug": "repo-a", "scm": "git"}, {"slug": "repo-b", "scm": "git"}], "next": ""}`))
+	}))
+	defer testServer.Close()
+
+	client := &Client{
+		baseURL:    testServer.URL,
+		httpClient: testServer.Client(),
+		logger:     zap.NewNop(),
+	}
+
+	outputs, err := ExportWorkspace(client, &data.CmdExportFlags{
+		Workspace:        "ws",
+		OutputDir:        t.TempDir(),
+		AllRepos:         true,
+		IncludeReposFile: writeRepositoryList(t, "repo-*\n"),
+		ExcludeReposFile: writeRepositoryList(t, "repo-a\nrepo-b\n"),
+	}, zap.NewNop())
+	assert.Empty(t, outputs)
+	assert.ErrorContains(t, err, "no repositories in workspace ws match the repository lists")
+
+	_, err = ExportWorkspace(client, &data.CmdExportFlags{
+		Workspace:        "ws",
+		OutputDir:        t.TempDir(),
+		AllRepos:         true,
+		IncludeReposFile: filepath.Join(t.TempDir(), "missing.txt"),
+	}, zap.NewNop())
+	assert.ErrorContains(t, err, "failed to read repository list")
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	exportReportFile     = "export-report.json"
+	exportCheckpointFile = "export-checkpoint.json"
+
+	reportStatusCompleted = "completed"
+	reportStatusFailed    = "failed"
+	reportStatusTimedOut  = "timed_out"
+	reportStatusCancelled = "cancelled"
+
+	stageRepositoryMetadata = "repository_metadata"
+	stageGitClone           = "git_clone"
+	stageUsers              = "users"
+	stagePullRequests       = "pull_requests"
+	stageComments           = "comments"
+	stageArchive            = "archive"
+)
+
+var ErrMaxDurationExceeded = errors.New("maximum export duration exceeded")
+
+// SetDeadline aborts the export once the given time has passed. A zero time
+// disables the watchdog.
+func (e *Exporter) SetDeadline(deadline time.Time) {
+	e.deadline = deadline
+	if e.client != nil {
+		e.client.deadline = deadline
+	}
+}
+
+// ExportDeadline returns the deadline implied by --max-duration, or the zero
+// time when no limit was requested.
+func ExportDeadline(flags *data.CmdExportFlags) time.Time {
+	if flags.MaxDuration <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(flags.MaxDuration)
+}
+
+// exportAborted reports whether err stopped the export on purpose: the
+// --max-duration watchdog fired or the export's context was cancelled.
+func exportAborted(err error) bool {
+	return errors.Is(err, ErrMaxDurationExceeded) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+func deadlineError(deadline time.Time, stage string) error {
+	return fmt.Errorf("%w (deadline %s, stage: %s)", ErrMaxDurationExceeded, deadline.Format(time.RFC3339), stage)
+}
+
+// SetContext stops the API requests and git commands of an export, and the
+// export itself at the next stage, once ctx is cancelled.
+func (c *Client) SetContext(ctx context.Context) {
+	c.ctx = ctx
+}
+
+func (c *Client) context() context.Context {
+	if c == nil || c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+func (e *Exporter) checkDeadline(stage string) error {
+	if err := e.client.context().Err(); err != nil {
+		return fmt.Errorf("export cancelled (stage: %s): %w", stage, err)
+	}
+	if e.deadline.IsZero() || time.Now().Before(e.deadline) {
+		return nil
+	}
+	return deadlineError(e.deadline, stage)
+}
+
+func (c *Client) checkDeadline(endpoint string) error {
+	if c.deadline.IsZero() || time.Now().Before(c.deadline) {
+		return nil
+	}
+	return deadlineError(c.deadline, endpoint)
+}
+
+// deadlineContext returns a context that expires at the export deadline, or
+// when the client's context is cancelled, used to stop long-running git
+// subprocesses.
+func (e *Exporter) deadlineContext() (context.Context, context.CancelFunc) {
+	parent := e.client.context()
+	if e.deadline.IsZero() {
+		return context.WithCancel(parent)
+	}
+	return context.WithDeadline(parent, e.deadline)
+}
+
+func (e *Exporter) beginReport(workspace string, repoSlugs []string) {
+	e.startedAt = time.Now()
+	e.completedStages = []string{}
+	e.reportWritten = false
+	e.report = data.ExportReport{
+		Workspace:    workspace,
+		Wave:         e.wave(),
+		OutputPrefix: e.outputPrefix(),
+		Repositories: repoSlugs,
+		StartedAt:    e.startedAt.UTC().Format(time.RFC3339),
+		Flags:        SanitizeFlags(e.flags),
+	}
+	e.checkpoint = data.ExportCheckpoint{
+		Workspace:    workspace,
+		Repositories: repoSlugs,
+	}
+	if e.client != nil {
+		e.client.failedResponses = nil
+		e.client.redactedBodies = 0
+		e.client.resetThrottling()
+	}
+}
+
+// completeStage records a finished export step and checks the watchdog
+// before the next one starts.
+func (e *Exporter) completeStage(stage string) error {
+	e.completedStages = append(e.completedStages, stage)
+	e.report.LastStage = stage
+	e.saveCheckpoint()
+	return e.checkDeadline(stage)
+}
+
+// finishReport writes the export report, and for runs that did not
+// complete a checkpoint of the stages that did.
+func (e *Exporter) finishReport(exportErr error) {
+	if e.reportWritten {
+		return
+	}
+	e.reportWritten = true
+
+	finishedAt := time.Now()
+	e.report.FinishedAt = finishedAt.UTC().Format(time.RFC3339)
+	e.report.DurationSeconds = finishedAt.Sub(e.startedAt).Seconds()
+	e.report.Counts.UnsafePaths = len(e.unsafePaths)
+	e.report.Counts.DocFindings = len(e.docFindings)
+	e.report.PermissionNotes = e.permissionNotes
+	if e.client != nil {
+		e.report.FailedAPIResponses = e.client.failedResponses
+		e.report.Counts.RedactedBodies = e.client.redactedBodies
+		e.report.Throttling = e.client.throttlingStats(finishedAt.Sub(e.startedAt))
+		e.logThrottlingStats(e.report.Throttling)
+	}
+
+	switch {
+	case exportErr == nil:
+		e.report.Status = reportStatusCompleted
+	case errors.Is(exportErr, ErrMaxDurationExceeded):
+		e.report.Status = reportStatusTimedOut
+		e.report.Error = exportErr.Error()
+	case exportAborted(exportErr):
+		e.report.Status = reportStatusCancelled
+		e.report.Error = exportErr.Error()
+	default:
+		e.report.Status = reportStatusFailed
+		e.report.Error = exportErr.Error()
+	}
+
+	if exportErr != nil {
+		e.saveCheckpoint()
+	} else {
+		e.removeCheckpoint()
+	}
+
+	if err := e.writeJSONFile(e.reportFile(), e.report); err != nil {
+		e.logger.Warn("Failed to write export report", zap.Error(err))
+		return
+	}
+
+	e.logger.Debug("Wrote export report",
+		zap.String("status", e.report.Status),
+		zap.String("file", e.reportFile()))
+}
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func readReportFile(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, v))
+}
+
+func TestExportDeadline(t *testing.T) {
+	assert.True(t, ExportDeadline(&data.CmdExportFlags{}).IsZero())
+
+	deadline := ExportDeadline(&data.CmdExportFlags{MaxDuration: time.Hour})
+	assert.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Minute)
+}
+
+func TestCheckDeadline(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	exporter := NewExporter(&Client{logger: logger}, t.TempDir(), logger, false, "")
+
+	assert.NoError(t, exporter.checkDeadline(stageUsers))
+
+	exporter.SetDeadline(time.Now().Add(time.Hour))
+	assert.NoError(t, exporter.checkDeadline(stageUsers))
+
+	exporter.SetDeadline(time.Now().Add(-time.Second))
+	err := exporter.checkDeadline(stageUsers)
+	assert.True(t, errors.Is(err, ErrMaxDurationExceeded))
+	assert.Contains(t, err.Error(), "stage: users")
+	assert.Error(t, exporter.client.checkDeadline("repositories/ws/repo"))
+}
+
+func TestFinishReport(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+
+	t.Run("Completed", func(t *testing.T) {
+		outputDir := t.TempDir()
+		exporter := NewExporter(&Client{logger: logger}, outputDir, logger, false, "")
+		exporter.beginReport("workspace", []string{"repo"})
+		require.NoError(t, exporter.completeStage(stageRepositoryMetadata))
+		exporter.finishReport(nil)
+
+		var report data.ExportReport
+		readReportFile(t, filepath.Join(outputDir, exportReportFile), &report)
+		assert.Equal(t, reportStatusCompleted, report.Status)
+		assert.Equal(t, stageRepositoryMetadata, report.LastStage)
+		assert.NoFileExists(t, filepath.Join(outputDir, exportCheckpointFile))
+	})
+
+	t.Run("Timed out writes checkpoint", func(t *testing.T) {
+		outputDir := t.TempDir()
+		exporter := NewExporter(&Client{logger: logger}, outputDir, logger, false, "")
+		exporter.beginReport("workspace", []string{"repo"})
+		require.NoError(t, exporter.completeStage(stageRepositoryMetadata))
+		exporter.SetDeadline(time.Now().Add(-time.Second))
+		err := exporter.completeStage(stageGitClone)
+		require.Error(t, err)
+		exporter.finishReport(err)
+		exporter.finishReport(nil)
+
+		var report data.ExportReport
+		readReportFile(t, filepath.Join(outputDir, exportReportFile), &report)
+		assert.Equal(t, reportStatusTimedOut, report.Status)
+		assert.Contains(t, report.Error, "maximum export duration exceeded")
+
+		var checkpoint data.ExportCheckpoint
+		readReportFile(t, filepath.Join(outputDir, exportCheckpointFile), &checkpoint)
+		assert.Equal(t, []string{stageRepositoryMetadata, stageGitClone}, checkpoint.CompletedStages)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		outputDir := t.TempDir()
+		exporter := NewExporter(&Client{logger: logger}, outputDir, logger, false, "")
+		exporter.beginReport("workspace", []string{"repo"})
+		exporter.finishReport(errors.New("boom"))
+
+		var report data.ExportReport
+		readReportFile(t, filepath.Join(outputDir, exportReportFile), &report)
+		assert.Equal(t, reportStatusFailed, report.Status)
+		assert.Equal(t, "boom", report.Error)
+	})
+}
+
+func TestExportRepositoriesAbortsAfterDeadline(t *testing.T) {
+	requests := 0
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer testServer.Close()
+
+	logger, _ := zap.NewDevelopment()
+	client := &Client{
+		baseURL:        testServer.URL,
+		httpClient:     testServer.Client(),
+		logger:         logger,
+		commitSHACache: make(map[string]string),
+	}
+	outputDir := t.TempDir()
+	exporter := NewExporter(client, outputDir, logger, false, "")
+	exporter.SetDeadline(time.Now().Add(-time.Second))
+
+	err := exporter.ExportRepositories("workspace", []string{"repo"})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrMaxDurationExceeded))
+	assert.Zero(t, requests, "no API calls should be made after the deadline")
+
+	var report data.ExportReport
+	readReportFile(t, filepath.Join(outputDir, exportReportFile), &report)
+	assert.Equal(t, reportStatusTimedOut, report.Status)
+	assert.FileExists(t, filepath.Join(outputDir, exportCheckpointFile))
+}
+
+func TestDeadlineContext(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available")
+	}
+
+	logger, _ := zap.NewDevelopment()
+	exporter := NewExporter(&Client{logger: logger}, t.TempDir(), logger, false, "")
+	exporter.SetDeadline(time.Now().Add(100 * time.Millisecond))
+
+	ctx, cancel := exporter.deadlineContext()
+	defer cancel()
+
+	start := time.Now()
+	err := exec.CommandContext(ctx, "sleep", "10").Run()
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestCheckDeadlineCancelledContext(t *testing.T) {
+	client := &Client{logger: zap.NewNop()}
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	client.SetContext(ctx)
+	assert.NoError(t, exporter.checkDeadline(stageUsers))
+
+	cancel()
+	err := exporter.checkDeadline(stageUsers)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "stage: users")
+
+	gitCtx, gitCancel := exporter.deadlineContext()
+	defer gitCancel()
+	assert.ErrorIs(t, gitCtx.Err(), context.Canceled)
+	assert.ErrorIs(t, client.makeRequest("GET", "repositories/ws/repo", &data.BitbucketRepository{}), context.Canceled)
+}
+
+func TestFinishReportCancelled(t *testing.T) {
+	outputDir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.beginReport("workspace", []string{"repo"})
+	exporter.finishReport(fmt.Errorf("export cancelled (stage: %s): %w", stageComments, context.Canceled))
+
+	var report data.ExportReport
+	readReportFile(t, filepath.Join(outputDir, exportReportFile), &report)
+	assert.Equal(t, reportStatusCancelled, report.Status)
+	assert.FileExists(t, filepath.Join(outputDir, exportCheckpointFile))
+}
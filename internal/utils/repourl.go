@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scpLikeURLPattern = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+	cloudHosts        = map[string]bool{"bitbucket.org": true, "www.bitbucket.org": true}
+)
+
+// ParseRepositoryURL extracts the workspace and repository from a URL copied
+// from the browser or a clone URL, e.g. https://bitbucket.org/ws/repo/src/main
+// or git@bitbucket.org:ws/repo.git. Bitbucket Server / Data Center URLs are
+// recognised so they can be rejected with a clear message.
+func ParseRepositoryURL(raw string) (*data.RepositoryURL, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return nil, fmt.Errorf("repository URL is empty")
+	}
+
+	var host, repoPath string
+	if match := scpLikeURLPattern.FindStringSubmatch(trimmed); match != nil && !strings.Contains(trimmed, "://") {
+		host, repoPath = match[1], match[2]
+	} else {
+		if !strings.Contains(trimmed, "://") {
+			trimmed = "https://" + trimmed
+		}
+		parsed, err := url.Parse(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repository URL %q: %w", raw, err)
+		}
+		host, repoPath = parsed.Hostname(), parsed.Path
+	}
+
+	host = strings.ToLower(host)
+	var segments []string
+	for _, segment := range strings.Split(repoPath, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+
+	if cloudHosts[host] {
+		if len(segments) < 2 {
+			return nil, fmt.Errorf("invalid repository URL %q: expected https://bitbucket.org/<workspace>/<repository>", raw)
+		}
+		return &data.RepositoryURL{
+			Host:       host,
+			Workspace:  segments[0],
+			Repository: strings.TrimSuffix(segments[1], ".git"),
+			Cloud:      true,
+		}, nil
+	}
+
+	// Bitbucket Server / Data Center layouts:
+	//   /projects/<KEY>/repos/<slug>/browse, /users/<user>/repos/<slug>, /scm/<key>/<slug>.git
+	for i := 0; i+2 < len(segments); i++ {
+		if (segments[i] == "projects" || segments[i] == "users") && segments[i+2] == "repos" && i+3 < len(segments) {
+			return &data.RepositoryURL{Host: host, Workspace: segments[i+1], Repository: segments[i+3]}, nil
+		}
+		if segments[i] == "scm" {
+			return &data.RepositoryURL{Host: host, Workspace: segments[i+1], Repository: strings.TrimSuffix(segments[i+2], ".git")}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unrecognized repository URL %q: expected a Bitbucket Cloud URL such as https://bitbucket.org/<workspace>/<repository>", raw)
+}
+
+// ApplyRepositoryURLArg fills --workspace and --repo from an optional
+// positional repository URL. Values given explicitly by flag must agree with
+// the URL.
+func ApplyRepositoryURLArg(cmd *cobra.Command, args []string, cmdFlags *data.CmdExportFlags) error {
+	if len(args) == 0 {
+		return nil
+	}
+	if cmdFlags.AllRepos {
+		return fmt.Errorf("a repository URL cannot be combined with --all-repos")
+	}
+
+	repoURL, err := ParseRepositoryURL(args[0])
+	if err != nil {
+		return err
+	}
+	if !repoURL.Cloud {
+		return fmt.Errorf("%s looks like a Bitbucket Server / Data Center repository (project %s, repository %s); only Bitbucket Cloud repositories can be exported",
+			args[0], repoURL.Workspace, repoURL.Repository)
+	}
+
+	if cmdFlags.Workspace != "" && cmdFlags.Workspace != repoURL.Workspace {
+		return fmt.Errorf("--workspace %s does not match workspace %s in the repository URL", cmdFlags.Workspace, repoURL.Workspace)
+	}
+	if cmdFlags.Repository != "" && cmdFlags.Repository != repoURL.Repository {
+		return fmt.Errorf("--repo %s does not match repository %s in the repository URL", cmdFlags.Repository, repoURL.Repository)
+	}
+
+	// Setting the flags (rather than the struct fields) also satisfies
+	// cobra's required-flag check.
+	if err := setCommandFlag(cmd, "workspace", repoURL.Workspace); err != nil {
+		return err
+	}
+	return setCommandFlag(cmd, "repo", repoURL.Repository)
+}
+
+// setCommandFlag sets a local or persistent flag; persistent flags are only
+// merged into cmd.Flags() once the command is executed.
+func setCommandFlag(cmd *cobra.Command, name, value string) error {
+	if cmd.Flags().Lookup(name) != nil {
+		return cmd.Flags().Set(name, value)
+	}
+	return cmd.PersistentFlags().Set(name, value)
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRepositoryURL(t *testing.T) {
+	tests := []struct {
+		raw       string
+		workspace string
+		repo      string
+		cloud     bool
+	}{
+		{"https://bitbucket.org/my-ws/my-repo", "my-ws", "my-repo", true},
+		{"https://bitbucket.org/my-ws/my-repo/", "my-ws", "my-repo", true},
+		{"https://bitbucket.org/my-ws/my-repo/src/main/README.md", "my-ws", "my-repo", true},
+		{"https://bitbucket.org/my-ws/my-repo/pull-requests/12", "my-ws", "my-repo", true},
+		{"https://user@bitbucket.org/my-ws/my-repo.git", "my-ws", "my-repo", true},
+		{"git@bitbucket.org:my-ws/my-repo.git", "my-ws", "my-repo", true},
+		{"ssh://git@bitbucket.org/my-ws/my-repo.git", "my-ws", "my-repo", true},
+		{"bitbucket.org/my-ws/my-repo", "my-ws", "my-repo", true},
+		{"https://www.bitbucket.org/my-ws/my-repo", "my-ws", "my-repo", true},
+		{"https://bitbucket.example.com/projects/PROJ/repos/service/browse", "PROJ", "service", false},
+		{"https://bitbucket.example.com/scm/proj/service.git", "proj", "service", false},
+		{"ssh://git@bitbucket.example.com:7999/proj/service.git", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			repoURL, err := ParseRepositoryURL(tt.raw)
+			if tt.workspace == "" {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.workspace, repoURL.Workspace)
+			assert.Equal(t, tt.repo, repoURL.Repository)
+			assert.Equal(t, tt.cloud, repoURL.Cloud)
+		})
+	}
+
+	for _, raw := range []string{"", "https://bitbucket.org/only-ws", "https://github.com/owner/repo"} {
+		_, err := ParseRepositoryURL(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func newRepositoryURLTestCommand(cmdFlags *data.CmdExportFlags) *cobra.Command {
+	cmd := &cobra.Command{Use: "test"}
+	cmd.Flags().StringVarP(&cmdFlags.Workspace, "workspace", "w", cmdFlags.Workspace, "")
+	cmd.Flags().StringVarP(&cmdFlags.Repository, "repo", "r", cmdFlags.Repository, "")
+	return cmd
+}
+
+func TestApplyRepositoryURLArg(t *testing.T) {
+	cmdFlags := &data.CmdExportFlags{}
+	cmd := newRepositoryURLTestCommand(cmdFlags)
+
+	require.NoError(t, ApplyRepositoryURLArg(cmd, []string{"https://bitbucket.org/ws/repo"}, cmdFlags))
+	assert.Equal(t, "ws", cmdFlags.Workspace)
+	assert.Equal(t, "repo", cmdFlags.Repository)
+	assert.True(t, cmd.Flags().Lookup("workspace").Changed)
+
+	// No argument leaves the flags untouched
+	cmdFlags = &data.CmdExportFlags{Workspace: "other"}
+	cmd = newRepositoryURLTestCommand(cmdFlags)
+	require.NoError(t, ApplyRepositoryURLArg(cmd, nil, cmdFlags))
+	assert.Equal(t, "other", cmdFlags.Workspace)
+}
+
+func TestApplyRepositoryURLArgErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   data.CmdExportFlags
+		arg     string
+		wantErr string
+	}{
+		{"conflicting workspace", data.CmdExportFlags{Workspace: "a"}, "https://bitbucket.org/b/repo", "--workspace a does not match"},
+		{"conflicting repo", data.CmdExportFlags{Repository: "x"}, "https://bitbucket.org/ws/y", "--repo x does not match"},
+		{"all repos", data.CmdExportFlags{AllRepos: true}, "https://bitbucket.org/ws/repo", "cannot be combined with --all-repos"},
+		{"server URL", data.CmdExportFlags{}, "https://bb.example.com/projects/P/repos/r/browse", "Bitbucket Server / Data Center"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmdFlags := tt.flags
+			cmd := newRepositoryURLTestCommand(&cmdFlags)
+			err := ApplyRepositoryURLArg(cmd, []string{tt.arg}, &cmdFlags)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
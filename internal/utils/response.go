@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	defaultMaxResponseSize = 256 << 20 // 256 MiB
+	maxErrorBodySize       = 64 << 10  // 64 KiB
+)
+
+var ErrResponseTooLarge = errors.New("API response exceeds maximum size")
+
+// SetMaxResponseSize limits how many bytes of a single API response are read.
+// A value of zero or less restores the default.
+func (c *Client) SetMaxResponseSize(size int64) {
+	c.maxResponseSize = size
+}
+
+func (c *Client) responseSizeLimit() int64 {
+	if c.maxResponseSize <= 0 {
+		return defaultMaxResponseSize
+	}
+	return c.maxResponseSize
+}
+
+// sizeLimitedReader fails with ErrResponseTooLarge once more than limit bytes
+// have been read, so a runaway response cannot exhaust memory while decoding.
+type sizeLimitedReader struct {
+	reader io.Reader
+	limit  int64
+	read   int64
+}
+
+func (r *sizeLimitedReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	if r.read > r.limit {
+		return n, fmt.Errorf("%w of %d bytes", ErrResponseTooLarge, r.limit)
+	}
+	return n, err
+}
+
+// limitResponseBody wraps a response body with the client's size guard,
+// rejecting responses whose declared length is already too large.
+func (c *Client) limitResponseBody(body io.Reader, contentLength int64) (io.Reader, error) {
+	limit := c.responseSizeLimit()
+	if contentLength > limit {
+		return nil, fmt.Errorf("%w of %d bytes (Content-Length: %d)", ErrResponseTooLarge, limit, contentLength)
+	}
+	return &sizeLimitedReader{reader: body, limit: limit}, nil
+}
+
+// readErrorBody returns at most maxErrorBodySize bytes of an error response.
+func readErrorBody(body io.Reader) string {
+	content, _ := io.ReadAll(io.LimitReader(body, maxErrorBodySize+1))
+	if len(content) > maxErrorBodySize {
+		return string(content[:maxErrorBodySize]) + "... (truncated)"
+	}
+	return string(content)
+}
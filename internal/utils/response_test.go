@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSizeLimitedReader(t *testing.T) {
+	client := &Client{}
+	client.SetMaxResponseSize(10)
+
+	reader, err := client.limitResponseBody(strings.NewReader("0123456789"), -1)
+	require.NoError(t, err)
+	buf := make([]byte, 32)
+	n, _ := reader.Read(buf)
+	assert.Equal(t, 10, n)
+
+	reader, err = client.limitResponseBody(strings.NewReader("0123456789A"), -1)
+	require.NoError(t, err)
+	_, err = reader.Read(buf)
+	assert.True(t, errors.Is(err, ErrResponseTooLarge))
+
+	_, err = client.limitResponseBody(strings.NewReader(""), 11)
+	assert.True(t, errors.Is(err, ErrResponseTooLarge))
+	assert.Contains(t, err.Error(), "Content-Length: 11")
+}
+
+func TestResponseSizeLimitDefault(t *testing.T) {
+	client := &Client{}
+	assert.EqualValues(t, defaultMaxResponseSize, client.responseSizeLimit())
+
+	client.SetMaxResponseSize(1024)
+	assert.EqualValues(t, 1024, client.responseSizeLimit())
+
+	client.SetMaxResponseSize(0)
+	assert.EqualValues(t, defaultMaxResponseSize, client.responseSizeLimit())
+}
+
+func TestReadErrorBody(t *testing.T) {
+	assert.Equal(t, "short error", readErrorBody(strings.NewReader("short error")))
+
+	body := readErrorBody(strings.NewReader(strings.Repeat("x", maxErrorBodySize+100)))
+	assert.True(t, strings.HasSuffix(body, "... (truncated)"))
+	assert.Len(t, body, maxErrorBodySize+len("... (truncated)"))
+}
+
+func TestMakeRequestRejectsOversizedResponse(t *testing.T) {
+	for _, chunked := range []bool{false, true} {
+		t.Run(fmt.Sprintf("chunked=%t", chunked), func(t *testing.T) {
+			testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body := []byte(`{"name": "` + strings.Repeat("a", 4096) + `"}`)
+				if !chunked {
+					w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+				}
+				w.WriteHeader(http.StatusOK)
+				if chunked {
+					w.(http.Flusher).Flush()
+				}
+				writeResponse(t, w, body)
+			}))
+			defer testServer.Close()
+
+			logger, _ := zap.NewDevelopment()
+			client := &Client{
+				baseURL:    testServer.URL,
+				httpClient: testServer.Client(),
+				logger:     logger,
+			}
+			client.SetMaxResponseSize(1024)
+
+			_, err := client.GetRepository("ws", "repo")
+			assert.Error(t, err)
+			assert.True(t, errors.Is(err, ErrResponseTooLarge))
+		})
+	}
+}
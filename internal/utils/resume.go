@@ -0,0 +1,359 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	// checkpointCacheDir holds the pull requests and comments fetched before
+	// an interruption, one JSON line per page or pull request.
+	checkpointCacheDir    = "export-checkpoint"
+	pullRequestPagesCache = "pull_requests.ndjson"
+	commentsCache         = "comments.ndjson"
+
+	// Comment progress is written to the checkpoint every this many pull
+	// requests; the comments themselves are appended to the cache right away.
+	checkpointCommentInterval = 25
+)
+
+// SetResume continues the interrupted export recorded in the output
+// directory's checkpoint instead of starting over: mirrors that were cloned
+// are reused and fetched pull request pages and comments are not requested
+// again.
+func (e *Exporter) SetResume(enabled bool) {
+	e.resume = enabled
+}
+
+// startCheckpoint loads the progress of an interrupted export for --resume,
+// or removes a stale checkpoint, and lets the client record this run's
+// progress.
+func (e *Exporter) startCheckpoint(workspace string, repoSlugs []string) error {
+	checkpointPath := filepath.Join(e.outputDir, exportCheckpointFile)
+	cacheDir := filepath.Join(e.outputDir, checkpointCacheDir)
+
+	if !e.resume {
+		for _, path := range []string{checkpointPath, cacheDir} {
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("failed to remove the checkpoint of an earlier export: %w", err)
+			}
+		}
+	} else {
+		previous, err := readCheckpoint(checkpointPath)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			e.logger.Info("No checkpoint to resume from; exporting from the beginning",
+				zap.String("output", e.outputDir))
+		case err != nil:
+			return err
+		case previous.Workspace != workspace || !slices.Equal(previous.Repositories, repoSlugs):
+			return fmt.Errorf("the checkpoint in %s is for %s/%s, not %s/%s; export without --resume to start over",
+				e.outputDir, previous.Workspace, strings.Join(previous.Repositories, ","),
+				workspace, strings.Join(repoSlugs, ","))
+		default:
+			e.checkpoint.ClonedRepositories = previous.ClonedRepositories
+			e.checkpoint.PullRequests = previous.PullRequests
+			e.checkpoint.CommentedPRs = previous.CommentedPRs
+			e.checkpoint.Contributors = previous.Contributors
+			if e.client != nil {
+				for login, name := range previous.Contributors {
+					e.client.recordContributor(data.BitbucketPRUser{UUID: login, DisplayName: name})
+				}
+			}
+			e.logger.Info("Resuming interrupted export",
+				zap.Strings("completed_stages", previous.CompletedStages),
+				zap.Int("cloned_repositories", len(previous.ClonedRepositories)),
+				zap.String("checkpoint_updated_at", previous.UpdatedAt))
+		}
+	}
+
+	if e.client != nil {
+		e.client.progress = &fetchProgress{
+			dir:        cacheDir,
+			checkpoint: &e.checkpoint,
+			save:       e.saveCheckpoint,
+		}
+	}
+	return nil
+}
+
+func readCheckpoint(path string) (data.ExportCheckpoint, error) {
+	var checkpoint data.ExportCheckpoint
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return checkpoint, err
+	}
+	if err := json.Unmarshal(content, &checkpoint); err != nil {
+		return checkpoint, fmt.Errorf("%w %s: %w", ErrCorruptExportFile, exportCheckpointFile, err)
+	}
+	return checkpoint, nil
+}
+
+// saveCheckpoint writes the progress of the export so far.
+func (e *Exporter) saveCheckpoint() {
+	e.checkpoint.CompletedStages = e.completedStages
+	e.checkpoint.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	if e.client != nil {
+		if contributors := e.client.contributorNames(); len(contributors) > 0 {
+			e.checkpoint.Contributors = contributors
+		}
+	}
+	if err := e.writeJSONFile(exportCheckpointFile, e.checkpoint); err != nil {
+		e.logger.Warn("Failed to write export checkpoint", zap.Error(err))
+	}
+}
+
+// removeCheckpoint deletes the checkpoint of an export that completed.
+func (e *Exporter) removeCheckpoint() {
+	for _, name := range []string{exportCheckpointFile, checkpointCacheDir} {
+		if err := os.RemoveAll(filepath.Join(e.outputDir, name)); err != nil {
+			e.logger.Warn("Failed to remove export checkpoint", zap.Error(err))
+		}
+	}
+}
+
+// recordClone marks a repository as cloned in the checkpoint.
+func (e *Exporter) recordClone(repoSlug string) {
+	if e.checkpoint.ClonedRepositories == nil {
+		e.checkpoint.ClonedRepositories = make(map[string]string)
+	}
+	e.checkpoint.ClonedRepositories[repoSlug] = e.cloneTimes[repoSlug].UTC().Format(time.RFC3339Nano)
+	e.saveCheckpoint()
+}
+
+// resumeClone reuses a mirror cloned before the export was interrupted and
+// restores what cloning it records for the later stages.
+func (e *Exporter) resumeClone(workspace, repoSlug string) (bool, error) {
+	started, ok := e.checkpoint.ClonedRepositories[repoSlug]
+	if !e.resume || !ok {
+		return false, nil
+	}
+	repoDir := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+	head, err := os.ReadFile(filepath.Join(repoDir, "HEAD"))
+	if err != nil {
+		e.logger.Warn("Repository cloned before the interruption is missing; cloning it again",
+			zap.String("repository", repoSlug),
+			zap.Error(err))
+		return false, nil
+	}
+
+	if cloneTime, err := time.Parse(time.RFC3339Nano, started); err == nil {
+		if e.cloneTimes == nil {
+			e.cloneTimes = make(map[string]time.Time)
+		}
+		e.cloneTimes[repoSlug] = cloneTime
+	}
+	defaultBranch := strings.TrimPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/")
+	e.updateRepositoryField(repoSlug, "default_branch", defaultBranch)
+	e.updateRepositoryField(repoSlug, "git_url", fmt.Sprintf("tarball://root/repositories/%s/%s.git", workspace, repoSlug))
+	e.resumeWiki(workspace, repoSlug)
+	e.recordLFSObjects(repoSlug, repoDir)
+
+	if err := e.checkImportSafety(workspace, repoSlug, repoDir); err != nil {
+		return false, err
+	}
+	if err := e.createRepositoryInfoFiles(workspace, repoSlug); err != nil {
+		e.logger.Warn("Failed to create repository info files",
+			zap.String("repository", repoSlug),
+			zap.Error(err))
+	}
+
+	e.logger.Info("Reusing repository cloned before the interruption",
+		zap.String("repository", repoSlug),
+		zap.String("default_branch", defaultBranch))
+	return true, nil
+}
+
+// fetchProgress lets the client record the pull request pages and comments
+// it fetched in the export checkpoint, and replay them on --resume. A nil
+// fetchProgress records nothing.
+type fetchProgress struct {
+	dir               string
+	checkpoint        *data.ExportCheckpoint
+	save              func()
+	unsavedCommentPRs int
+}
+
+type pullRequestPage struct {
+	Page         int                `json:"page"`
+	PullRequests []data.PullRequest `json:"pull_requests"`
+}
+
+type pullRequestComments struct {
+	PullRequest    int                             `json:"pull_request"`
+	IssueComments  []data.IssueComment             `json:"issue_comments"`
+	ReviewComments []data.PullRequestReviewComment `json:"review_comments"`
+}
+
+// pullRequests returns the pages of pull requests fetched before the
+// interruption and whether they are all of them.
+func (p *fetchProgress) pullRequests(repoSlug string) (int, []data.PullRequest, bool) {
+	if p == nil {
+		return 0, nil, false
+	}
+	progress := p.checkpoint.PullRequests[repoSlug]
+	if progress.PagesFetched == 0 {
+		return 0, nil, false
+	}
+
+	pages := make(map[int][]data.PullRequest)
+	err := readCacheLines(filepath.Join(p.dir, repoSlug, pullRequestPagesCache), func(line []byte) error {
+		var page pullRequestPage
+		if err := json.Unmarshal(line, &page); err != nil {
+			return err
+		}
+		pages[page.Page] = page.PullRequests
+		return nil
+	})
+	if err != nil {
+		return 0, nil, false
+	}
+	var prs []data.PullRequest
+	for page := 1; page <= progress.PagesFetched; page++ {
+		cached, ok := pages[page]
+		if !ok {
+			return 0, nil, false
+		}
+		prs = append(prs, cached...)
+	}
+	return progress.PagesFetched, prs, progress.Complete
+}
+
+// recordPullRequestPage caches a fetched page of pull requests.
+func (p *fetchProgress) recordPullRequestPage(repoSlug string, page int, prs []data.PullRequest, ids []int, complete bool) error {
+	if p == nil {
+		return nil
+	}
+	if prs == nil {
+		prs = []data.PullRequest{}
+	}
+	if err := appendCacheLine(filepath.Join(p.dir, repoSlug, pullRequestPagesCache), pullRequestPage{Page: page, PullRequests: prs}); err != nil {
+		return err
+	}
+
+	if p.checkpoint.PullRequests == nil {
+		p.checkpoint.PullRequests = make(map[string]data.CheckpointPullRequests)
+	}
+	progress := p.checkpoint.PullRequests[repoSlug]
+	progress.PagesFetched = page
+	progress.Complete = complete
+	progress.IDs = append(progress.IDs, ids...)
+	p.checkpoint.PullRequests[repoSlug] = progress
+	p.save()
+	return nil
+}
+
+// comments returns the comments of the pull requests whose comments were
+// fetched before the interruption, by pull request ID.
+func (p *fetchProgress) comments(repoSlug string) map[int]pullRequestComments {
+	cached := make(map[int]pullRequestComments)
+	if p == nil || len(p.checkpoint.CommentedPRs[repoSlug]) == 0 {
+		return cached
+	}
+
+	done := make(map[int]bool)
+	for _, id := range p.checkpoint.CommentedPRs[repoSlug] {
+		done[id] = true
+	}
+	err := readCacheLines(filepath.Join(p.dir, repoSlug, commentsCache), func(line []byte) error {
+		var comments pullRequestComments
+		if err := json.Unmarshal(line, &comments); err != nil {
+			return err
+		}
+		if done[comments.PullRequest] {
+			cached[comments.PullRequest] = comments
+		}
+		return nil
+	})
+	if err != nil {
+		return map[int]pullRequestComments{}
+	}
+	return cached
+}
+
+// recordComments caches the comments of one pull request.
+func (p *fetchProgress) recordComments(repoSlug string, prID int, regular []data.IssueComment,
+	review []data.PullRequestReviewComment) error {
+	if p == nil {
+		return nil
+	}
+	line := pullRequestComments{PullRequest: prID, IssueComments: regular, ReviewComments: review}
+	if err := appendCacheLine(filepath.Join(p.dir, repoSlug, commentsCache), line); err != nil {
+		return err
+	}
+
+	if p.checkpoint.CommentedPRs == nil {
+		p.checkpoint.CommentedPRs = make(map[string][]int)
+	}
+	p.checkpoint.CommentedPRs[repoSlug] = append(p.checkpoint.CommentedPRs[repoSlug], prID)
+	p.unsavedCommentPRs++
+	if p.unsavedCommentPRs >= checkpointCommentInterval {
+		p.flush()
+	}
+	return nil
+}
+
+// flush writes comment progress not yet in the checkpoint.
+func (p *fetchProgress) flush() {
+	if p == nil || p.unsavedCommentPRs == 0 {
+		return
+	}
+	p.unsavedCommentPRs = 0
+	p.save()
+}
+
+func appendCacheLine(path string, value interface{}) error {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint cache: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("failed to write checkpoint cache: %w", err)
+	}
+	return file.Close()
+}
+
+// readCacheLines calls read for every complete line of a cache file. A last
+// line cut short by the interruption is ignored.
+func readCacheLines(path string, read func(line []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := read(line); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func resumeClient(serverURL string, progress *fetchProgress) *Client {
+	return &Client{
+		baseURL:        serverURL,
+		httpClient:     http.DefaultClient,
+		logger:         zap.NewNop(),
+		commitSHACache: make(map[string]string),
+		progress:       progress,
+	}
+}
+
+func testProgress(t *testing.T) (*fetchProgress, *int32) {
+	t.Helper()
+	saves := new(int32)
+	return &fetchProgress{
+		dir:        t.TempDir(),
+		checkpoint: &data.ExportCheckpoint{},
+		save:       func() { atomic.AddInt32(saves, 1) },
+	}, saves
+}
+
+func TestGetPullRequestsResumesFromCheckpoint(t *testing.T) {
+	var pages []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/pullrequests") {
+			writeResponse(t, w, []byte(`{}`))
+			return
+		}
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		next := `"next"`
+		if page == "2" {
+			next = "null"
+		}
+		writeResponse(t, w, []byte(fmt.Sprintf(`{"values": [{"id": %s, "title": "PR %s"}], "next": %s}`, page, page, next)))
+	}))
+	defer testServer.Close()
+
+	progress, saves := testProgress(t)
+	client := resumeClient(testServer.URL, progress)
+
+	prs, err := client.GetPullRequests("workspace", "repo", false, "")
+	require.NoError(t, err)
+	require.Len(t, prs, 2)
+	assert.Equal(t, []string{"1", "2"}, pages)
+	assert.Equal(t, data.CheckpointPullRequests{PagesFetched: 2, Complete: true, IDs: []int{1, 2}},
+		progress.checkpoint.PullRequests["repo"])
+	assert.Equal(t, int32(2), *saves)
+
+	// An export interrupted after the first page fetches only the second.
+	progress.checkpoint.PullRequests["repo"] = data.CheckpointPullRequests{PagesFetched: 1, IDs: []int{1}}
+	pages = nil
+	prs, err = client.GetPullRequests("workspace", "repo", false, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2"}, pages)
+	require.Len(t, prs, 2)
+	assert.Equal(t, "PR 1", prs[0].Title)
+	assert.Equal(t, "PR 2", prs[1].Title)
+
+	// A completed fetch is not repeated.
+	pages = nil
+	prs, err = client.GetPullRequests("workspace", "repo", false, "")
+	require.NoError(t, err)
+	assert.Empty(t, pages)
+	assert.Len(t, prs, 2)
+}
+
+func TestGetPullRequestCommentsResumesFromCheckpoint(t *testing.T) {
+	var requested []string
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		writeResponse(t, w, []byte(`{"values": [{"id": 7, "created_on": "2024-01-01T00:00:00Z",
+			"content": {"raw": "fetched"}, "user": {"uuid": "{alice}"}}], "next": null}`))
+	}))
+	defer testServer.Close()
+
+	progress, _ := testProgress(t)
+	require.NoError(t, progress.recordComments("repo", 1,
+		[]data.IssueComment{{Body: "cached"}}, nil))
+	client := resumeClient(testServer.URL, progress)
+
+	regular, _, err := client.GetPullRequestComments("workspace", "repo", []data.PullRequest{
+		{URL: "https://bitbucket.org/workspace/repo/pull/1"},
+		{URL: "https://bitbucket.org/workspace/repo/pull/2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/repositories/workspace/repo/pullrequests/2/comments"}, requested)
+	bodies := []string{}
+	for _, comment := range regular {
+		bodies = append(bodies, comment.Body)
+	}
+	assert.ElementsMatch(t, []string{"cached", "fetched"}, bodies)
+	assert.ElementsMatch(t, []int{1, 2}, progress.checkpoint.CommentedPRs["repo"])
+}
+
+func TestFetchProgressIgnoresTruncatedCacheLine(t *testing.T) {
+	progress, _ := testProgress(t)
+	require.NoError(t, progress.recordComments("repo", 1, []data.IssueComment{{Body: "kept"}}, nil))
+	cachePath := filepath.Join(progress.dir, "repo", commentsCache)
+	file, err := os.OpenFile(cachePath, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"pull_request": 2, "issue_comm`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	progress.checkpoint.CommentedPRs["repo"] = append(progress.checkpoint.CommentedPRs["repo"], 2)
+
+	cached := progress.comments("repo")
+
+	require.Len(t, cached, 1)
+	assert.Equal(t, "kept", cached[1].IssueComments[0].Body)
+}
+
+func TestFetchProgressNil(t *testing.T) {
+	var progress *fetchProgress
+
+	pages, prs, complete := progress.pullRequests("repo")
+	assert.Zero(t, pages)
+	assert.Nil(t, prs)
+	assert.False(t, complete)
+	assert.Empty(t, progress.comments("repo"))
+	assert.NoError(t, progress.recordPullRequestPage("repo", 1, nil, nil, true))
+	assert.NoError(t, progress.recordComments("repo", 1, nil, nil))
+	progress.flush()
+}
+
+func TestStartCheckpoint(t *testing.T) {
+	outputDir := t.TempDir()
+	client := &Client{logger: zap.NewNop()}
+	exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+	exporter.beginReport("workspace", []string{"repo"})
+	require.NoError(t, exporter.startCheckpoint("workspace", []string{"repo"}))
+	client.recordContributor(data.BitbucketPRUser{UUID: "{alice}", DisplayName: "Alice"})
+	exporter.cloneTimes = map[string]time.Time{"repo": time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)}
+	exporter.recordClone("repo")
+	require.NoError(t, client.progress.recordComments("repo", 3, nil, nil))
+	exporter.finishReport(ErrMaxDurationExceeded)
+
+	t.Run("Resume restores progress", func(t *testing.T) {
+		client := &Client{logger: zap.NewNop()}
+		exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+		exporter.SetResume(true)
+		exporter.beginReport("workspace", []string{"repo"})
+		require.NoError(t, exporter.startCheckpoint("workspace", []string{"repo"}))
+
+		assert.Equal(t, map[string]string{"repo": "2024-06-01T00:00:00Z"}, exporter.checkpoint.ClonedRepositories)
+		assert.Equal(t, []int{3}, exporter.checkpoint.CommentedPRs["repo"])
+		assert.Equal(t, map[string]string{"alice": "Alice"}, client.contributors)
+		assert.Len(t, client.progress.comments("repo"), 1)
+	})
+
+	t.Run("Resume rejects another repository", func(t *testing.T) {
+		exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+		exporter.SetResume(true)
+		err := exporter.startCheckpoint("workspace", []string{"other"})
+		assert.ErrorContains(t, err, "export without --resume to start over")
+	})
+
+	t.Run("Export without resume removes the checkpoint", func(t *testing.T) {
+		exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+		require.NoError(t, exporter.startCheckpoint("workspace", []string{"repo"}))
+
+		assert.NoFileExists(t, filepath.Join(outputDir, exportCheckpointFile))
+		assert.NoDirExists(t, filepath.Join(outputDir, checkpointCacheDir))
+	})
+}
+
+func TestStartCheckpointResumeWithoutCheckpoint(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.SetResume(true)
+
+	require.NoError(t, exporter.startCheckpoint("workspace", []string{"repo"}))
+	assert.Empty(t, exporter.checkpoint.ClonedRepositories)
+}
+
+func TestResumeClone(t *testing.T) {
+	outputDir := t.TempDir()
+	mirrorPath := filepath.Join(outputDir, "repositories", "workspace", "repo.git")
+	if err := exec.Command("git", "init", "--bare", "-b", "develop", mirrorPath).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.repositories = []data.Repository{{Name: "repo"}}
+	exporter.checkpoint.ClonedRepositories = map[string]string{"repo": "2024-06-01T00:00:00Z"}
+
+	resumed, err := exporter.resumeClone("workspace", "repo")
+	require.NoError(t, err)
+	assert.False(t, resumed, "clones are only reused with --resume")
+
+	exporter.SetResume(true)
+	resumed, err = exporter.resumeClone("workspace", "repo")
+	require.NoError(t, err)
+	assert.True(t, resumed)
+	assert.Equal(t, "develop", exporter.repositories[0].DefaultBranch)
+	assert.True(t, strings.HasSuffix(exporter.repositories[0].GitURL, "workspace/repo.git"))
+	assert.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), exporter.cloneTimes["repo"])
+
+	exporter.checkpoint.ClonedRepositories["missing"] = "2024-06-01T00:00:00Z"
+	resumed, err = exporter.resumeClone("workspace", "missing")
+	require.NoError(t, err)
+	assert.False(t, resumed, "a missing mirror is cloned again")
+}
+
+func TestValidateExportFlagsResumeRequiresOutput(t *testing.T) {
+	flags := &data.CmdExportFlags{
+		Workspace:            "workspace",
+		Repository:           "repo",
+		BitbucketAccessToken: "token",
+		Resume:               true,
+	}
+	assert.ErrorContains(t, ValidateExportFlags(flags), "--resume requires --output")
+
+	flags.OutputDir = "./export"
+	assert.NoError(t, ValidateExportFlags(flags))
+}
@@ -0,0 +1,268 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	rulesetsFile       = "rulesets.json"
+	rulesetsScriptFile = "apply-rulesets.sh"
+
+	restrictionPush             = "push"
+	restrictionForce            = "force"
+	restrictionDelete           = "delete"
+	restrictionRequireApprovals = "require_approvals_to_merge"
+	branchMatchGlob             = "glob"
+)
+
+// SetExportRulesets writes GitHub ruleset payloads translated from Bitbucket
+// branch restrictions, plus a script that applies them after the import.
+func (e *Exporter) SetExportRulesets(enabled bool) {
+	e.exportRulesets = enabled
+}
+
+func (c *Client) GetBranchRestrictions(workspace, repoSlug string) ([]data.BitbucketBranchRestriction, error) {
+	c.logger.Debug("Fetching branch restrictions",
+		zap.String("workspace", workspace),
+		zap.String("repository", repoSlug))
+
+	var restrictions []data.BitbucketBranchRestriction
+	page := 1
+	pageLen := c.pageLen(100)
+	hasMore := true
+
+	for hasMore {
+		endpoint := fmt.Sprintf("repositories/%s/%s/branch-restrictions?page=%d&pagelen=%d",
+			workspace, repoSlug, page, pageLen)
+
+		var response data.BitbucketBranchRestrictionResponse
+		if err := c.makeRequest("GET", endpoint, &response); err != nil {
+			return nil, fmt.Errorf("failed to list branch restrictions for %s/%s: %w", workspace, repoSlug, err)
+		}
+		restrictions = append(restrictions, response.Values...)
+
+		hasMore = response.Next != ""
+		if hasMore {
+			page++
+		}
+	}
+	return restrictions, nil
+}
+
+// rulesetRefPattern converts a Bitbucket branch glob, where * also matches
+// slashes, into a GitHub ruleset ref pattern.
+func rulesetRefPattern(pattern string) string {
+	if pattern == "*" {
+		return "~ALL"
+	}
+	return "refs/heads/" + strings.ReplaceAll(pattern, "*", "**")
+}
+
+// translateBranchRestrictions builds one GitHub ruleset per Bitbucket branch
+// pattern. A "push" restriction without exceptions means changes must be
+// merged via a pull request; approval counts are only carried over for such
+// branches, since GitHub's pull_request rule also blocks direct pushes.
+func translateBranchRestrictions(restrictions []data.BitbucketBranchRestriction) ([]data.GitHubRuleset, []data.SkippedBranchRestriction, []string) {
+	var skipped []data.SkippedBranchRestriction
+	var notes []string
+	var patterns []string
+	rulesByPattern := make(map[string]*data.GitHubRuleset)
+
+	rulesetFor := func(pattern string) *data.GitHubRuleset {
+		if ruleset, ok := rulesByPattern[pattern]; ok {
+			return ruleset
+		}
+		ruleset := &data.GitHubRuleset{
+			Name:        "Bitbucket: " + pattern,
+			Target:      "branch",
+			Enforcement: "active",
+			Conditions: data.GitHubRulesetConditions{
+				RefName: data.GitHubRulesetRefName{Include: []string{rulesetRefPattern(pattern)}, Exclude: []string{}},
+			},
+			Rules: []data.GitHubRulesetRule{},
+		}
+		rulesByPattern[pattern] = ruleset
+		patterns = append(patterns, pattern)
+		return ruleset
+	}
+	skip := func(restriction data.BitbucketBranchRestriction, reason string) {
+		skipped = append(skipped, data.SkippedBranchRestriction{
+			ID:      restriction.ID,
+			Kind:    restriction.Kind,
+			Pattern: restriction.Pattern,
+			Reason:  reason,
+		})
+	}
+
+	var approvals []data.BitbucketBranchRestriction
+	for _, restriction := range restrictions {
+		if restriction.BranchMatchKind != "" && restriction.BranchMatchKind != branchMatchGlob {
+			skip(restriction, fmt.Sprintf("branch match kind %q (branch type %q) is not translated",
+				restriction.BranchMatchKind, restriction.BranchType))
+			continue
+		}
+
+		switch restriction.Kind {
+		case restrictionPush:
+			ruleset := rulesetFor(restriction.Pattern)
+			ruleset.Rules = append(ruleset.Rules, data.GitHubRulesetRule{
+				Type:       "pull_request",
+				Parameters: &data.GitHubPullRequestParameters{},
+			})
+			if len(restriction.Users) > 0 || len(restriction.Groups) > 0 {
+				notes = append(notes, fmt.Sprintf(
+					"branch %s allows direct pushes for %d users and %d groups in Bitbucket; add them as ruleset bypass actors in GitHub",
+					restriction.Pattern, len(restriction.Users), len(restriction.Groups)))
+			}
+		case restrictionForce:
+			ruleset := rulesetFor(restriction.Pattern)
+			ruleset.Rules = append(ruleset.Rules, data.GitHubRulesetRule{Type: "non_fast_forward"})
+		case restrictionDelete:
+			ruleset := rulesetFor(restriction.Pattern)
+			ruleset.Rules = append(ruleset.Rules, data.GitHubRulesetRule{Type: "deletion"})
+		case restrictionRequireApprovals:
+			approvals = append(approvals, restriction)
+		default:
+			skip(restriction, "no GitHub ruleset equivalent")
+		}
+	}
+
+	for _, restriction := range approvals {
+		ruleset, ok := rulesByPattern[restriction.Pattern]
+		var parameters *data.GitHubPullRequestParameters
+		if ok {
+			for _, rule := range ruleset.Rules {
+				if rule.Type == "pull_request" {
+					parameters = rule.Parameters
+				}
+			}
+		}
+		if parameters == nil {
+			skip(restriction, "direct pushes are allowed in Bitbucket; a GitHub pull_request rule would block them")
+			continue
+		}
+		if restriction.Value != nil && *restriction.Value > parameters.RequiredApprovingReviewCount {
+			parameters.RequiredApprovingReviewCount = *restriction.Value
+		}
+	}
+
+	rulesets := make([]data.GitHubRuleset, 0, len(patterns))
+	for _, pattern := range patterns {
+		rulesets = append(rulesets, *rulesByPattern[pattern])
+	}
+	return rulesets, skipped, notes
+}
+
+// collectRulesets fetches and translates the branch restrictions of a
+// repository. githubName is the repository name used in the archive.
+func (e *Exporter) collectRulesets(workspace, repoSlug, githubName string) {
+	if !e.exportRulesets {
+		return
+	}
+
+	entry := data.RepositoryRulesets{
+		SourceRepository: fmt.Sprintf("%s/%s", workspace, repoSlug),
+		Repository:       githubName,
+		Rulesets:         []data.GitHubRuleset{},
+	}
+	restrictions, err := e.client.GetBranchRestrictions(workspace, repoSlug)
+	if err != nil {
+		e.logger.Warn("Failed to fetch branch restrictions; no rulesets will be exported for this repository",
+			zap.String("repository", entry.SourceRepository),
+			zap.Error(err))
+		entry.Notes = append(entry.Notes, fmt.Sprintf("failed to fetch branch restrictions: %v", err))
+	} else {
+		entry.Rulesets, entry.Skipped, entry.Notes = translateBranchRestrictions(restrictions)
+	}
+
+	for _, skipped := range entry.Skipped {
+		e.logger.Warn("Branch restriction not exported as a GitHub ruleset",
+			zap.String("repository", entry.SourceRepository),
+			zap.String("kind", skipped.Kind),
+			zap.String("pattern", skipped.Pattern),
+			zap.String("reason", skipped.Reason))
+	}
+	e.rulesets = append(e.rulesets, entry)
+}
+
+// writeRulesets writes the rulesets sidecar and the script that applies it.
+func (e *Exporter) writeRulesets() error {
+	if !e.exportRulesets {
+		return nil
+	}
+
+	if err := e.writeJSONFile(rulesetsFile, data.RulesetsExport{Repositories: e.rulesets}); err != nil {
+		return err
+	}
+
+	script, err := rulesetsScript(e.rulesets)
+	if err != nil {
+		return err
+	}
+	scriptPath := filepath.Join(e.outputDir, rulesetsScriptFile)
+	err = writeFileAtomic(scriptPath, 0755, func(w io.Writer) error {
+		_, err := io.WriteString(w, script)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", rulesetsScriptFile, err)
+	}
+
+	count := 0
+	for _, entry := range e.rulesets {
+		count += len(entry.Rulesets)
+	}
+	e.logger.Info("Wrote GitHub rulesets (excluded from import archive)",
+		zap.String("file", rulesetsFile),
+		zap.String("script", rulesetsScriptFile),
+		zap.Int("rulesets", count))
+	return nil
+}
+
+// shellQuote wraps a value in single quotes for a POSIX shell.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func rulesetsScript(rulesets []data.RepositoryRulesets) (string, error) {
+	var script strings.Builder
+	script.WriteString(`#!/usr/bin/env bash
+# Generated by gh-bbc-exporter: applies the GitHub rulesets in rulesets.json,
+# translated from Bitbucket branch restrictions. Run after the import:
+#   GITHUB_TOKEN=<token> ./apply-rulesets.sh <github-owner>
+# Set GITHUB_API_URL for GitHub Enterprise (e.g. https://api.example.ghe.com).
+set -euo pipefail
+
+OWNER="${1:?usage: $0 <github-owner>}"
+: "${GITHUB_TOKEN:?GITHUB_TOKEN must be set}"
+API_URL="${GITHUB_API_URL:-https://api.github.com}"
+
+apply_ruleset() {
+  curl -fsS -X POST \
+    -H "Authorization: Bearer ${GITHUB_TOKEN}" \
+    -H "Accept: application/vnd.github+json" \
+    -H "X-GitHub-Api-Version: 2022-11-28" \
+    "${API_URL}/repos/${OWNER}/$1/rulesets" \
+    -d "$2" > /dev/null
+  echo "Applied ruleset to ${OWNER}/$1"
+}
+`)
+
+	for _, entry := range rulesets {
+		for _, ruleset := range entry.Rulesets {
+			payload, err := json.Marshal(ruleset)
+			if err != nil {
+				return "", fmt.Errorf("failed to encode ruleset for %s: %w", entry.Repository, err)
+			}
+			fmt.Fprintf(&script, "\napply_ruleset %s %s\n", shellQuote(entry.Repository), shellQuote(string(payload)))
+		}
+	}
+	return script.String(), nil
+}
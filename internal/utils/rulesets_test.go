@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestTranslateBranchRestrictions(t *testing.T) {
+	restrictions := []data.BitbucketBranchRestriction{
+		{ID: 1, Kind: "require_approvals_to_merge", BranchMatchKind: "glob", Pattern: "main", Value: intPtr(2)},
+		{ID: 2, Kind: "push", BranchMatchKind: "glob", Pattern: "main"},
+		{ID: 3, Kind: "force", BranchMatchKind: "glob", Pattern: "main"},
+		{ID: 4, Kind: "delete", BranchMatchKind: "glob", Pattern: "release/*"},
+		{ID: 5, Kind: "push", BranchMatchKind: "glob", Pattern: "release/*", Users: []data.Owner{{Username: "alice"}}},
+		{ID: 6, Kind: "require_approvals_to_merge", BranchMatchKind: "glob", Pattern: "develop", Value: intPtr(1)},
+		{ID: 7, Kind: "push", BranchMatchKind: "branching_model", BranchType: "production"},
+		{ID: 8, Kind: "require_passing_builds_to_merge", BranchMatchKind: "glob", Pattern: "main", Value: intPtr(1)},
+	}
+
+	rulesets, skipped, notes := translateBranchRestrictions(restrictions)
+
+	require.Len(t, rulesets, 2)
+	assert.Equal(t, "Bitbucket: main", rulesets[0].Name)
+	assert.Equal(t, []string{"refs/heads/main"}, rulesets[0].Conditions.RefName.Include)
+	require.Len(t, rulesets[0].Rules, 2)
+	assert.Equal(t, "pull_request", rulesets[0].Rules[0].Type)
+	assert.Equal(t, 2, rulesets[0].Rules[0].Parameters.RequiredApprovingReviewCount)
+	assert.Equal(t, "non_fast_forward", rulesets[0].Rules[1].Type)
+
+	assert.Equal(t, []string{"refs/heads/release/**"}, rulesets[1].Conditions.RefName.Include)
+	assert.Equal(t, "deletion", rulesets[1].Rules[0].Type)
+	assert.Equal(t, "pull_request", rulesets[1].Rules[1].Type)
+	require.Len(t, notes, 1)
+	assert.Contains(t, notes[0], "release/*")
+
+	skippedIDs := []int{}
+	for _, restriction := range skipped {
+		skippedIDs = append(skippedIDs, restriction.ID)
+	}
+	assert.ElementsMatch(t, []int{6, 7, 8}, skippedIDs)
+}
+
+func TestRulesetRefPattern(t *testing.T) {
+	assert.Equal(t, "~ALL", rulesetRefPattern("*"))
+	assert.Equal(t, "refs/heads/main", rulesetRefPattern("main"))
+	assert.Equal(t, "refs/heads/feature/**", rulesetRefPattern("feature/*"))
+}
+
+func TestGetBranchRestrictions(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repositories/ws/repo/branch-restrictions", r.URL.Path)
+		if r.URL.Query().Get("page") == "1" {
+			writeResponse(t, w, []byte(`{"values": [{"id": 1, "kind": "push", "pattern": "main"}], "next": "`+server.URL+`?page=2"}`))
+			return
+		}
+		writeResponse(t, w, []byte(`{"values": [{"id": 2, "kind": "delete", "pattern": "main"}]}`))
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	restrictions, err := client.GetBranchRestrictions("ws", "repo")
+	require.NoError(t, err)
+	require.Len(t, restrictions, 2)
+	assert.Equal(t, "delete", restrictions[1].Kind)
+}
+
+func TestWriteRulesets(t *testing.T) {
+	outputDir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.SetExportRulesets(true)
+	rulesets, _, _ := translateBranchRestrictions([]data.BitbucketBranchRestriction{
+		{ID: 1, Kind: "push", BranchMatchKind: "glob", Pattern: "it's-main"},
+	})
+	exporter.rulesets = []data.RepositoryRulesets{{SourceRepository: "ws/repo", Repository: "repo", Rulesets: rulesets}}
+
+	require.NoError(t, exporter.writeRulesets())
+
+	content, err := os.ReadFile(filepath.Join(outputDir, rulesetsFile))
+	require.NoError(t, err)
+	var export data.RulesetsExport
+	require.NoError(t, json.Unmarshal(content, &export))
+	require.Len(t, export.Repositories, 1)
+	assert.Equal(t, "Bitbucket: it's-main", export.Repositories[0].Rulesets[0].Name)
+
+	scriptPath := filepath.Join(outputDir, rulesetsScriptFile)
+	script, err := os.ReadFile(scriptPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(script), `apply_ruleset 'repo' '{"name":"Bitbucket: it'\''s-main"`)
+	if _, err := exec.LookPath("bash"); err == nil {
+		out, err := exec.Command("bash", "-n", scriptPath).CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+
+	assert.True(t, sidecarPaths[rulesetsFile])
+	assert.True(t, sidecarPaths[rulesetsScriptFile])
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// RunExport runs the export pipeline for validated flags: a single
+// repository, every repository of a workspace, or sub-directory splits. It
+// returns the archive, or output directory, of every export produced.
+func RunExport(cmdFlags *data.CmdExportFlags, logger *zap.Logger) ([]string, error) {
+	return RunExportContext(context.Background(), cmdFlags, logger)
+}
+
+// InterruptContext returns a context cancelled by the first Ctrl-C or
+// SIGTERM, so the export stops its requests and git commands, writes its
+// checkpoint and report, and removes an incomplete archive. A second signal
+// exits immediately.
+func InterruptContext(parent context.Context, logger *zap.Logger) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-signals:
+			logger.Warn("Interrupted; stopping the export and writing a checkpoint (interrupt again to exit immediately)")
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(signals)
+	}()
+	return ctx, cancel
+}
+
+// RunExportContext is RunExport that stops once ctx is cancelled.
+func RunExportContext(ctx context.Context, cmdFlags *data.CmdExportFlags, logger *zap.Logger) ([]string, error) {
+	client, err := newExportClient(ctx, cmdFlags, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if cmdFlags.OpenPRsOnly {
+		logger.Info("Filtering: Only open PRs will be exported")
+	}
+	if cmdFlags.PRsFromDate != "" {
+		logger.Info("Filtering: PRs from date", zap.String("from_date", cmdFlags.PRsFromDate))
+	}
+	if cmdFlags.Wave != "" {
+		logger.Info("Exporting as part of migration wave", zap.String("wave", cmdFlags.Wave))
+	}
+
+	// Apply commit SHA expansion behavior
+	if cmdFlags.SkipCommitLookup {
+		logger.Info("Skipping Bitbucket API commit SHA lookups (will look locally only)")
+	}
+
+	if len(cmdFlags.SubdirSplits) > 0 {
+		outputs, err := ExportSubdirSplits(client, cmdFlags, logger)
+		if err != nil {
+			logger.Error("Sub-directory split export failed")
+			return outputs, err
+		}
+		logger.Info("Sub-directory split export completed successfully", zap.Int("archives", len(outputs)))
+		return outputs, nil
+	}
+
+	if cmdFlags.AllRepos {
+		outputs, err := ExportWorkspace(client, cmdFlags, logger)
+		if err != nil {
+			logger.Error("Workspace export failed")
+			return outputs, err
+		}
+		logger.Info("Workspace export completed successfully", zap.Int("archives", len(outputs)))
+		return outputs, nil
+	}
+
+	exporter := NewExporter(client, cmdFlags.OutputDir, logger, cmdFlags.OpenPRsOnly, cmdFlags.PRsFromDate)
+
+	if cmdFlags.TempDir != "" {
+		exporter.SetTempDir(cmdFlags.TempDir)
+		logger.Debug("Using custom temporary directory", zap.String("temp_dir", cmdFlags.TempDir))
+	}
+	if err := exporter.ApplyExportFlags(cmdFlags); err != nil {
+		return nil, err
+	}
+
+	// Run export
+	if err := exporter.ExportRepositories(cmdFlags.Workspace, RepositorySlugs(cmdFlags)); err != nil {
+		logger.Error("Export failed")
+		return nil, err
+	}
+
+	logger.Info("Export completed successfully")
+	return []string{exporter.GetOutputPath()}, nil
+}
+
+// newExportClient returns a client configured by cmdFlags that stops once
+// ctx is cancelled.
+func newExportClient(ctx context.Context, cmdFlags *data.CmdExportFlags, logger *zap.Logger) (*Client, error) {
+	client := NewClient(
+		cmdFlags.BitbucketAPIURL,
+		cmdFlags.BitbucketAccessToken,
+		cmdFlags.BitbucketAPIToken,
+		cmdFlags.BitbucketEmail,
+		cmdFlags.BitbucketUser,
+		cmdFlags.BitbucketAppPass,
+		logger,
+		cmdFlags.OutputDir,
+		cmdFlags.SkipCommitLookup,
+	)
+	if err := ConfigureClient(client, cmdFlags); err != nil {
+		return nil, err
+	}
+	client.SetContext(ctx)
+	return client, nil
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRunExportReportsConfigErrors(t *testing.T) {
+	flags := &data.CmdExportFlags{
+		BitbucketAPIURL:      "http://127.0.0.1:1",
+		BitbucketAccessToken: "token",
+		Workspace:            "workspace",
+		Repository:           "repo",
+		OutputDir:            t.TempDir(),
+		ConfigFile:           filepath.Join(t.TempDir(), "missing.yaml"),
+	}
+
+	outputs, err := RunExport(flags, zap.NewNop())
+	assert.Error(t, err)
+	assert.Empty(t, outputs)
+}
+
+func TestInterruptContext(t *testing.T) {
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+
+	ctx, stop := InterruptContext(context.Background(), zap.NewNop())
+	defer stop()
+	if err := process.Signal(os.Interrupt); err != nil {
+		t.Skip("sending an interrupt is not supported on this platform")
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context not cancelled by the interrupt")
+	}
+
+	parent, cancelParent := context.WithCancel(context.Background())
+	ctx, stop = InterruptContext(parent, zap.NewNop())
+	defer stop()
+	cancelParent()
+	<-ctx.Done()
+}
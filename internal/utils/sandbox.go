@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// SandboxRepository describes the content seeded into a sandbox workspace
+// for end-to-end tests.
+type SandboxRepository struct {
+	Workspace     string
+	Slug          string
+	MainBranch    string
+	FeatureBranch string
+	PullRequestID int
+	CommentText   string
+}
+
+// sendRequest performs a single write request against the Bitbucket API. It
+// is only used to seed and clean up sandbox workspaces, so unlike
+// makeRequest it does not retry.
+func (c *Client) sendRequest(method, endpoint, contentType string, body io.Reader, v interface{}) error {
+	fullURL := fmt.Sprintf("%s/%s", c.baseURLFor(endpoint), strings.TrimPrefix(endpoint, "/"))
+	req, err := http.NewRequest(method, fullURL, body)
+	if err != nil {
+		return err
+	}
+	if err := c.ensureOAuthToken(c.context()); err != nil {
+		return err
+	}
+	c.setAuthHeader(req)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	c.logger.Debug("Making API request",
+		zap.String("method", method),
+		zap.String("url", fullURL))
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			c.logger.Warn("Error closing response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		errorBody := readErrorBody(resp.Body)
+		c.recordFailedResponse(method, fullURL, resp.StatusCode, errorBody)
+		return &sandboxRequestError{status: resp.StatusCode, message: fmt.Sprintf(
+			"API request failed with status %d: %s: %s", resp.StatusCode, resp.Status, errorBody)}
+	}
+	if v == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+type sandboxRequestError struct {
+	status  int
+	message string
+}
+
+func (e *sandboxRequestError) Error() string {
+	return e.message
+}
+
+func (c *Client) sendJSON(method, endpoint string, payload, v interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return c.sendRequest(method, endpoint, "application/json", bytes.NewReader(body), v)
+}
+
+// commitSandboxFiles creates a commit with the given files through the
+// Bitbucket src endpoint, creating branch when it does not exist yet.
+func (c *Client) commitSandboxFiles(workspace, repoSlug, branch, message string, files map[string]string) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	fields := map[string]string{"message": message, "branch": branch}
+	for path, content := range files {
+		fields[path] = content
+	}
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	endpoint := fmt.Sprintf("repositories/%s/%s/src", workspace, repoSlug)
+	return c.sendRequest("POST", endpoint, writer.FormDataContentType(), &body, nil)
+}
+
+// SeedSandboxRepository creates a private repository in a sandbox workspace
+// with a commit on main, a feature branch, an open pull request and a
+// comment on it. The repository must not exist yet.
+func (c *Client) SeedSandboxRepository(workspace, repoSlug string) (*SandboxRepository, error) {
+	repo := &SandboxRepository{
+		Workspace:     workspace,
+		Slug:          repoSlug,
+		MainBranch:    "main",
+		FeatureBranch: "feature/e2e",
+		CommentText:   "Seeded comment for the end-to-end export test",
+	}
+	c.logger.Info("Seeding sandbox repository",
+		zap.String("workspace", workspace),
+		zap.String("repository", repoSlug))
+
+	repoEndpoint := fmt.Sprintf("repositories/%s/%s", workspace, url.PathEscape(repoSlug))
+	if err := c.sendJSON("POST", repoEndpoint, map[string]interface{}{
+		"scm":         "git",
+		"is_private":  true,
+		"description": "Temporary repository created by the bbc-exporter end-to-end tests",
+	}, nil); err != nil {
+		return nil, fmt.Errorf("failed to create sandbox repository: %w", err)
+	}
+
+	if err := c.commitSandboxFiles(workspace, repoSlug, repo.MainBranch, "Initial commit",
+		map[string]string{"README.md": "# bbc-exporter end-to-end test\n"}); err != nil {
+		return repo, fmt.Errorf("failed to seed main branch: %w", err)
+	}
+	if err := c.commitSandboxFiles(workspace, repoSlug, repo.FeatureBranch, "Add feature",
+		map[string]string{"feature.txt": "feature\n"}); err != nil {
+		return repo, fmt.Errorf("failed to seed feature branch: %w", err)
+	}
+
+	var pullRequest struct {
+		ID int `json:"id"`
+	}
+	if err := c.sendJSON("POST", repoEndpoint+"/pullrequests", map[string]interface{}{
+		"title":       "End-to-end test pull request",
+		"source":      map[string]interface{}{"branch": map[string]string{"name": repo.FeatureBranch}},
+		"destination": map[string]interface{}{"branch": map[string]string{"name": repo.MainBranch}},
+	}, &pullRequest); err != nil {
+		return repo, fmt.Errorf("failed to create sandbox pull request: %w", err)
+	}
+	repo.PullRequestID = pullRequest.ID
+
+	commentEndpoint := fmt.Sprintf("%s/pullrequests/%d/comments", repoEndpoint, pullRequest.ID)
+	if err := c.sendJSON("POST", commentEndpoint, map[string]interface{}{
+		"content": map[string]string{"raw": repo.CommentText},
+	}, nil); err != nil {
+		return repo, fmt.Errorf("failed to comment on sandbox pull request: %w", err)
+	}
+
+	return repo, nil
+}
+
+// DeleteSandboxRepository removes a repository created by
+// SeedSandboxRepository. A repository that no longer exists is not an error.
+func (c *Client) DeleteSandboxRepository(workspace, repoSlug string) error {
+	c.logger.Info("Deleting sandbox repository",
+		zap.String("workspace", workspace),
+		zap.String("repository", repoSlug))
+
+	endpoint := fmt.Sprintf("repositories/%s/%s", workspace, url.PathEscape(repoSlug))
+	err := c.sendRequest("DELETE", endpoint, "", nil, nil)
+	var requestErr *sandboxRequestError
+	if errors.As(err, &requestErr) && requestErr.status == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete sandbox repository: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSeedSandboxRepository(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	srcBranches := map[string]string{}
+
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		assert.Equal(t, "Bearer sandbox-token", r.Header.Get("Authorization"))
+
+		switch {
+		case r.URL.Path == "/repositories/sandbox/e2e-repo":
+			var payload map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			assert.Equal(t, "git", payload["scm"])
+			assert.Equal(t, true, payload["is_private"])
+			w.WriteHeader(http.StatusOK)
+			writeResponse(t, w, []byte(`{"slug": "e2e-repo"}`))
+		case r.URL.Path == "/repositories/sandbox/e2e-repo/src":
+			require.NoError(t, r.ParseMultipartForm(1<<20))
+			mu.Lock()
+			srcBranches[r.FormValue("branch")] = r.FormValue("message")
+			mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+		case r.URL.Path == "/repositories/sandbox/e2e-repo/pullrequests":
+			var payload map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			assert.Equal(t, "feature/e2e", payload["source"].(map[string]interface{})["branch"].(map[string]interface{})["name"])
+			w.WriteHeader(http.StatusCreated)
+			writeResponse(t, w, []byte(`{"id": 7}`))
+		case r.URL.Path == "/repositories/sandbox/e2e-repo/pullrequests/7/comments":
+			var payload map[string]map[string]string
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+			assert.NotEmpty(t, payload["content"]["raw"])
+			w.WriteHeader(http.StatusCreated)
+			writeResponse(t, w, []byte(`{"id": 1}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	client := &Client{
+		baseURL:     testServer.URL,
+		httpClient:  testServer.Client(),
+		logger:      zap.NewNop(),
+		accessToken: "sandbox-token",
+	}
+
+	repo, err := client.SeedSandboxRepository("sandbox", "e2e-repo")
+	require.NoError(t, err)
+	assert.Equal(t, 7, repo.PullRequestID)
+	assert.Equal(t, "main", repo.MainBranch)
+	assert.Equal(t, map[string]string{"main": "Initial commit", "feature/e2e": "Add feature"}, srcBranches)
+	assert.Equal(t, []string{
+		"POST /repositories/sandbox/e2e-repo",
+		"POST /repositories/sandbox/e2e-repo/src",
+		"POST /repositories/sandbox/e2e-repo/src",
+		"POST /repositories/sandbox/e2e-repo/pullrequests",
+		"POST /repositories/sandbox/e2e-repo/pullrequests/7/comments",
+	}, requests)
+}
+
+func TestSeedSandboxRepositoryAlreadyExists(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		writeResponse(t, w, []byte(`{"error": {"message": "Repository with this Slug and Owner already exists."}}`))
+	}))
+	defer testServer.Close()
+
+	client := &Client{baseURL: testServer.URL, httpClient: testServer.Client(), logger: zap.NewNop()}
+	repo, err := client.SeedSandboxRepository("sandbox", "e2e-repo")
+	assert.Nil(t, repo)
+	assert.ErrorContains(t, err, "failed to create sandbox repository")
+	assert.ErrorContains(t, err, "already exists")
+}
+
+func TestDeleteSandboxRepository(t *testing.T) {
+	status := http.StatusNoContent
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		assert.True(t, strings.HasSuffix(r.URL.Path, "/repositories/sandbox/e2e-repo"))
+		w.WriteHeader(status)
+	}))
+	defer testServer.Close()
+
+	client := &Client{baseURL: testServer.URL, httpClient: testServer.Client(), logger: zap.NewNop()}
+	assert.NoError(t, client.DeleteSandboxRepository("sandbox", "e2e-repo"))
+
+	status = http.StatusNotFound
+	assert.NoError(t, client.DeleteSandboxRepository("sandbox", "e2e-repo"))
+
+	status = http.StatusForbidden
+	assert.ErrorContains(t, client.DeleteSandboxRepository("sandbox", "e2e-repo"), "failed to delete sandbox repository")
+}
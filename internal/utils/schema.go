@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// schemaDrift lists response fields that the exporter does not model
+// (unknown) and modelled fields that the response did not contain (missing).
+type schemaDrift struct {
+	Unknown []string
+	Missing []string
+}
+
+func (c *Client) schemaValidationEnabled() bool {
+	return c.logger != nil && c.logger.Core().Enabled(zapcore.DebugLevel)
+}
+
+// detectSchemaDrift compares a raw response body with the type it was decoded
+// into and logs each drifted field once per client, so API changes that would
+// otherwise silently drop data show up in debug logs.
+func (c *Client) detectSchemaDrift(endpoint string, body []byte, v interface{}) {
+	var raw interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return
+	}
+
+	drift := findSchemaDrift(raw, reflect.TypeOf(v), "")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.schemaDriftSeen == nil {
+		c.schemaDriftSeen = make(map[string]bool)
+	}
+
+	endpointPath := strings.SplitN(endpoint, "?", 2)[0]
+	for _, field := range drift.Unknown {
+		if c.schemaDriftSeen["unknown:"+field] {
+			continue
+		}
+		c.schemaDriftSeen["unknown:"+field] = true
+		c.logger.Debug("Schema drift: response contains unmodelled field",
+			zap.String("endpoint", endpointPath),
+			zap.String("field", field))
+	}
+	for _, field := range drift.Missing {
+		if c.schemaDriftSeen["missing:"+field] {
+			continue
+		}
+		c.schemaDriftSeen["missing:"+field] = true
+		c.logger.Debug("Schema drift: expected field missing from response",
+			zap.String("endpoint", endpointPath),
+			zap.String("field", field))
+	}
+}
+
+func findSchemaDrift(raw interface{}, t reflect.Type, path string) schemaDrift {
+	drift := schemaDrift{}
+	collectSchemaDrift(raw, t, path, &drift)
+	sort.Strings(drift.Unknown)
+	sort.Strings(drift.Missing)
+	return drift
+}
+
+func collectSchemaDrift(raw interface{}, t reflect.Type, path string, drift *schemaDrift) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || raw == nil {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		items, ok := raw.([]interface{})
+		if !ok || len(items) == 0 {
+			return
+		}
+		collectSchemaDrift(items[0], t.Elem(), path+"[]", drift)
+	case reflect.Struct:
+		object, ok := raw.(map[string]interface{})
+		if !ok {
+			return
+		}
+
+		known := make(map[string]bool)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, omitempty := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+			known[name] = true
+
+			value, present := object[name]
+			if !present {
+				if !omitempty && field.Type.Kind() != reflect.Ptr {
+					drift.Missing = append(drift.Missing, joinFieldPath(path, name))
+				}
+				continue
+			}
+			collectSchemaDrift(value, field.Type, joinFieldPath(path, name), drift)
+		}
+
+		for name := range object {
+			if !known[name] {
+				drift.Unknown = append(drift.Unknown, joinFieldPath(path, name))
+			}
+		}
+	}
+}
+
+func jsonFieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "" {
+		return field.Name, false
+	}
+	parts := strings.Split(tag, ",")
+	name := parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	omitempty := false
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitempty = true
+		}
+	}
+	return name, omitempty
+}
+
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
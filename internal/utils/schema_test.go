@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type schemaTestBranch struct {
+	Name string `json:"name"`
+}
+
+type schemaTestPR struct {
+	ID          int                `json:"id"`
+	Title       string             `json:"title"`
+	Description *string            `json:"description"`
+	Summary     string             `json:"summary,omitempty"`
+	Source      schemaTestBranch   `json:"source"`
+	Reviewers   []schemaTestBranch `json:"reviewers"`
+	internal    string
+}
+
+func TestFindSchemaDrift(t *testing.T) {
+	var raw interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"id": 1,
+		"rendered": {"title": {"html": "<p>x</p>"}},
+		"source": {"name": "main", "commit": {"hash": "abc"}},
+		"reviewers": [{"display_name": "user"}]
+	}`), &raw))
+
+	drift := findSchemaDrift(raw, reflect.TypeOf(&schemaTestPR{}), "")
+
+	assert.Equal(t, []string{"rendered", "reviewers[].display_name", "source.commit"}, drift.Unknown)
+	assert.Equal(t, []string{"reviewers[].name", "title"}, drift.Missing)
+}
+
+func TestFindSchemaDriftPaginated(t *testing.T) {
+	var raw interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"values": [{"id": 1, "title": "t", "source": {"name": "a"}, "reviewers": [], "extra": true}], "next": ""}`), &raw))
+
+	var response struct {
+		Values []schemaTestPR `json:"values"`
+		Next   string         `json:"next"`
+	}
+	drift := findSchemaDrift(raw, reflect.TypeOf(&response), "")
+
+	assert.Equal(t, []string{"values[].extra"}, drift.Unknown)
+	assert.Empty(t, drift.Missing)
+}
+
+func TestMakeRequestLogsSchemaDriftInDebugMode(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		writeResponse(t, w, []byte(`{"hash": "abc123", "new_field": 1}`))
+	}))
+	defer testServer.Close()
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	client := &Client{
+		baseURL:    testServer.URL,
+		httpClient: testServer.Client(),
+		logger:     zap.New(core),
+	}
+
+	var response struct {
+		Hash string `json:"hash"`
+	}
+	require.NoError(t, client.makeRequest("GET", "commit/abc?fields=x", &response))
+	require.NoError(t, client.makeRequest("GET", "commit/abc", &response))
+	assert.Equal(t, "abc123", response.Hash)
+
+	driftLogs := logs.FilterMessage("Schema drift: response contains unmodelled field").All()
+	require.Len(t, driftLogs, 1, "each drifted field should be logged once")
+	assert.Equal(t, "new_field", driftLogs[0].ContextMap()["field"])
+	assert.Equal(t, "commit/abc", driftLogs[0].ContextMap()["endpoint"])
+}
+
+func TestMakeRequestSkipsSchemaDriftOutsideDebugMode(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		writeResponse(t, w, []byte(`{"hash": "abc123", "new_field": 1}`))
+	}))
+	defer testServer.Close()
+
+	core, logs := observer.New(zapcore.InfoLevel)
+	client := &Client{
+		baseURL:    testServer.URL,
+		httpClient: testServer.Client(),
+		logger:     zap.New(core),
+	}
+
+	var response struct {
+		Hash string `json:"hash"`
+	}
+	require.NoError(t, client.makeRequest("GET", "commit/abc", &response))
+	assert.Nil(t, client.schemaDriftSeen)
+	assert.Zero(t, logs.Len())
+}
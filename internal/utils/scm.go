@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"fmt"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const scmMercurial = "hg"
+
+// isMercurialRepository reports whether Bitbucket still lists the repository
+// as a retired Mercurial repository, which cannot be cloned with git.
+func isMercurialRepository(repo *data.BitbucketRepository) bool {
+	return repo != nil && repo.SCM == scmMercurial
+}
+
+func checkRepositorySCM(workspace, repoSlug string, repo *data.BitbucketRepository) error {
+	if isMercurialRepository(repo) {
+		return fmt.Errorf("cannot export %s/%s: Mercurial repositories cannot be exported", workspace, repoSlug)
+	}
+	return nil
+}
+
+// filterGitRepositories drops Mercurial repositories from a workspace listing.
+func filterGitRepositories(repositories []data.BitbucketRepository, logger *zap.Logger) []data.BitbucketRepository {
+	gitRepos := make([]data.BitbucketRepository, 0, len(repositories))
+	for _, repo := range repositories {
+		if isMercurialRepository(&repo) {
+			logger.Warn("Skipping Mercurial repository: Mercurial repositories cannot be exported",
+				zap.String("repository", repo.Slug))
+			continue
+		}
+		gitRepos = append(gitRepos, repo)
+	}
+	return gitRepos
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestIsMercurialRepository(t *testing.T) {
+	assert.True(t, isMercurialRepository(&data.BitbucketRepository{SCM: "hg"}))
+	assert.False(t, isMercurialRepository(&data.BitbucketRepository{SCM: "git"}))
+	assert.False(t, isMercurialRepository(&data.BitbucketRepository{}))
+	assert.False(t, isMercurialRepository(nil))
+}
+
+func TestFilterGitRepositories(t *testing.T) {
+	repos := []data.BitbucketRepository{
+		{Slug: "legacy", SCM: "hg"},
+		{Slug: "service", SCM: "git"},
+	}
+
+	filtered := filterGitRepositories(repos, zap.NewNop())
+	require.Len(t, filtered, 1)
+	assert.Equal(t, "service", filtered[0].Slug)
+}
+
+func TestExportRepositoriesRejectsMercurial(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		writeResponse(t, w, []byte(`{"slug": "legacy", "name": "legacy", "scm": "hg"}`))
+	}))
+	defer testServer.Close()
+
+	logger := zap.NewNop()
+	client := &Client{
+		baseURL:    testServer.URL,
+		httpClient: testServer.Client(),
+		logger:     logger,
+	}
+	exporter := NewExporter(client, t.TempDir(), logger, false, "")
+
+	err := exporter.ExportRepositories("workspace", []string{"legacy"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Mercurial repositories cannot be exported")
+}
+
+func TestExportWorkspaceSkipsMercurial(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		writeResponse(t, w, []byte(`{"values": [{"slug": "legacy", "scm": "hg"}], "next": ""}`))
+	}))
+	defer testServer.Close()
+
+	logger := zap.NewNop()
+	client := &Client{
+		baseURL:    testServer.URL,
+		httpClient: testServer.Client(),
+		logger:     logger,
+	}
+
+	outputs, err := ExportWorkspace(client, &data.CmdExportFlags{
+		Workspace: "old-ws",
+		OutputDir: t.TempDir(),
+		AllRepos:  true,
+	}, logger)
+	require.Error(t, err)
+	assert.Empty(t, outputs)
+	assert.Contains(t, err.Error(), "no repositories found in workspace old-ws")
+}
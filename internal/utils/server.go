@@ -0,0 +1,482 @@
+package utils
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+var errJobQueueFull = errors.New("the job queue is full")
+
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	// JobStatusInterrupted marks jobs that were running when the server
+	// stopped.
+	JobStatusInterrupted = "interrupted"
+
+	maxQueuedJobs      = 256
+	maxJobRequestBytes = 1 << 20
+)
+
+// jobPollInterval is how often idle workers check the job store for jobs
+// queued by jobs retry.
+var jobPollInterval = 2 * time.Second
+
+// ExportServer runs export jobs submitted over HTTP with the same pipeline
+// as the export command. Each job writes to its own directory under the
+// server's output directory, and jobs are recorded in a job store there so
+// they survive restarts.
+type ExportServer struct {
+	defaults  data.CmdExportFlags
+	outputDir string
+	authToken string
+	logger    *zap.Logger
+
+	// run is replaced in tests to avoid calling Bitbucket.
+	run func(context.Context, *data.CmdExportFlags, *zap.Logger) ([]string, error)
+
+	store *jobStore
+
+	// ctx is passed to running jobs and cancelled by Close.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	closed  bool
+	claims  sync.WaitGroup // Job store claims in progress, waited for by Close
+	workers sync.WaitGroup
+	done    chan struct{}
+	wake    chan struct{}
+}
+
+// NewExportServer returns a server whose jobs start from the given flags.
+// Requests must carry authToken as a bearer token unless it is empty.
+func NewExportServer(defaults data.CmdExportFlags, outputDir, authToken string, logger *zap.Logger) *ExportServer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ExportServer{
+		defaults:  defaults,
+		outputDir: outputDir,
+		authToken: authToken,
+		logger:    logger,
+		run:       RunExportContext,
+		store:     newJobStore(outputDir),
+		ctx:       ctx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Restore marks jobs that were running when the server last stopped as
+// interrupted. Queued jobs are picked up once the workers start.
+func (s *ExportServer) Restore() error {
+	interrupted, err := s.store.interruptRunning()
+	if err != nil {
+		return err
+	}
+	if interrupted > 0 {
+		s.logger.Warn("Jobs were interrupted when the server last stopped; retry them with jobs retry",
+			zap.Int("jobs", interrupted))
+	}
+	return nil
+}
+
+// ValidateServeAuth refuses to serve without an auth token on an address
+// other hosts can reach, since jobs use the server's Bitbucket credentials.
+func ValidateServeAuth(listen, authToken string) error {
+	if authToken != "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return fmt.Errorf("invalid value for --listen: %w", err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("--auth-token is required when listening on %s", listen)
+}
+
+// StartWorkers runs queued jobs on the given number of workers until Close
+// is called.
+func (s *ExportServer) StartWorkers(workers int) {
+	s.workers.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+}
+
+func (s *ExportServer) work() {
+	defer s.workers.Done()
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+	for {
+		job, found, err := s.claim()
+		if err != nil {
+			s.logger.Error("Failed to read the job store", zap.Error(err))
+		}
+		if found {
+			s.runJob(job)
+			continue
+		}
+		select {
+		case <-s.done:
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops accepting jobs, cancels the running jobs and returns once the
+// workers have stopped, so no job writes to the output directory after it.
+// Cancelled jobs are marked interrupted; queued jobs stay in the job store
+// for the next start.
+func (s *ExportServer) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+		s.cancel()
+	}
+	s.mu.Unlock()
+	s.claims.Wait()
+	s.workers.Wait()
+}
+
+// claim takes the next queued job unless the server is closed. Close waits
+// for claims in progress, so idle workers leave the job store alone once it
+// returns.
+func (s *ExportServer) claim() (data.ExportJob, bool, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return data.ExportJob{}, false, nil
+	}
+	s.claims.Add(1)
+	s.mu.Unlock()
+	defer s.claims.Done()
+	return s.store.claimNext()
+}
+
+func (s *ExportServer) notifyWorkers() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Handler returns the REST API:
+//
+//	GET  /healthz
+//	POST /v1/jobs
+//	GET  /v1/jobs
+//	GET  /v1/jobs/{id}
+//	GET  /v1/jobs/{id}/report
+//	POST /v1/jobs/{id}/retry
+func (s *ExportServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeServerJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("POST /v1/jobs", s.authorize(s.handleSubmit))
+	mux.HandleFunc("GET /v1/jobs", s.authorize(s.handleList))
+	mux.HandleFunc("GET /v1/jobs/{id}", s.authorize(s.handleGet))
+	mux.HandleFunc("GET /v1/jobs/{id}/report", s.authorize(s.handleReport))
+	mux.HandleFunc("POST /v1/jobs/{id}/retry", s.authorize(s.handleRetry))
+	return mux
+}
+
+func (s *ExportServer) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.authToken != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.authToken)) != 1 {
+				writeServerError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func (s *ExportServer) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var request data.ExportJobRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxJobRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeServerError(w, http.StatusBadRequest, fmt.Errorf("invalid job request: %w", err))
+		return
+	}
+	if _, err := s.jobFlags(request, ""); err != nil {
+		writeServerError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		writeServerError(w, http.StatusServiceUnavailable, errors.New("the server is shutting down"))
+		return
+	}
+
+	var job data.ExportJob
+	err := s.store.update(func(jobs []data.ExportJob) ([]data.ExportJob, error) {
+		queued := 0
+		for _, existing := range jobs {
+			if existing.Status == JobStatusQueued {
+				queued++
+			}
+		}
+		if queued >= maxQueuedJobs {
+			return nil, errJobQueueFull
+		}
+		id := nextJobID(jobs)
+		job = data.ExportJob{
+			ID:          id,
+			Status:      JobStatusQueued,
+			Request:     request,
+			SubmittedAt: time.Now().UTC().Format(time.RFC3339),
+			OutputDir:   filepath.Join(s.outputDir, id),
+		}
+		return append(jobs, job), nil
+	})
+	if errors.Is(err, errJobQueueFull) {
+		writeServerError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+	if err != nil {
+		writeServerError(w, http.StatusInternalServerError, err)
+		return
+	}
+	s.notifyWorkers()
+
+	s.logger.Info("Queued export job",
+		zap.String("job", job.ID),
+		zap.String("workspace", request.Workspace),
+		zap.String("repository", request.Repository))
+	writeServerJSON(w, http.StatusAccepted, job)
+}
+
+func (s *ExportServer) handleList(w http.ResponseWriter, r *http.Request) {
+	jobs, err := s.store.jobs()
+	if err != nil {
+		writeServerError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeServerJSON(w, http.StatusOK, jobs)
+}
+
+func (s *ExportServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.requestedJob(w, r)
+	if !ok {
+		return
+	}
+	writeServerJSON(w, http.StatusOK, job)
+}
+
+func (s *ExportServer) handleRetry(w http.ResponseWriter, r *http.Request) {
+	job, err := s.store.retry(r.PathValue("id"))
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		writeServerError(w, http.StatusNotFound, err)
+		return
+	case err != nil:
+		writeServerError(w, http.StatusConflict, err)
+		return
+	}
+	s.notifyWorkers()
+	s.logger.Info("Queued export job again", zap.String("job", job.ID))
+	writeServerJSON(w, http.StatusAccepted, job)
+}
+
+// handleReport returns the export reports of a finished job, one per
+// archive, as a JSON array.
+func (s *ExportServer) handleReport(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.requestedJob(w, r)
+	if !ok {
+		return
+	}
+	if len(job.Reports) == 0 {
+		writeServerError(w, http.StatusNotFound, fmt.Errorf("job %s has no export report (status: %s)", job.ID, job.Status))
+		return
+	}
+	reports := make([]json.RawMessage, 0, len(job.Reports))
+	for _, path := range job.Reports {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			writeServerError(w, http.StatusInternalServerError, fmt.Errorf("failed to read export report: %w", err))
+			return
+		}
+		reports = append(reports, content)
+	}
+	writeServerJSON(w, http.StatusOK, reports)
+}
+
+// requestedJob looks up the job named in the request path, writing the
+// error response when it cannot be returned.
+func (s *ExportServer) requestedJob(w http.ResponseWriter, r *http.Request) (data.ExportJob, bool) {
+	job, err := s.store.job(r.PathValue("id"))
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		writeServerError(w, http.StatusNotFound, err)
+		return data.ExportJob{}, false
+	case err != nil:
+		writeServerError(w, http.StatusInternalServerError, err)
+		return data.ExportJob{}, false
+	}
+	return job, true
+}
+
+// jobFlags applies a job request to a copy of the server's flags and
+// validates the result the way the export command does.
+func (s *ExportServer) jobFlags(request data.ExportJobRequest, outputDir string) (*data.CmdExportFlags, error) {
+	flags := s.defaults
+	flags.OutputDir = outputDir
+	flags.Workspace = request.Workspace
+	flags.Repository = request.Repository
+	flags.AllRepos = request.AllRepos
+	flags.GroupByProject = request.GroupByProject
+	flags.OpenPRsOnly = request.OpenPRsOnly
+	flags.PRsFromDate = request.PRsFromDate
+	flags.SkipCommitLookup = request.SkipCommitLookup
+	flags.Wave = request.Wave
+	if request.OutputPrefix != "" {
+		flags.OutputPrefix = request.OutputPrefix
+	}
+	flags.ExportRulesets = request.ExportRulesets
+	flags.ExportWatchers = request.ExportWatchers
+	flags.GenerateCodeowners = request.GenerateCodeowners
+	flags.TopUpFetch = request.TopUpFetch
+	flags.SkipGit = request.SkipGit
+	flags.GitDepth = request.GitDepth
+	flags.AsOf = request.AsOf
+	if request.UsersScope != "" {
+		flags.UsersScope = request.UsersScope
+	}
+	if request.Consistency != "" {
+		flags.Consistency = request.Consistency
+	}
+	if request.MaxDuration != "" {
+		duration, err := time.ParseDuration(request.MaxDuration)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for max_duration: %w", err)
+		}
+		flags.MaxDuration = duration
+	}
+
+	switch {
+	case flags.Workspace == "":
+		return nil, errors.New("a bitbucket workspace must be specified")
+	case flags.AllRepos && flags.Repository != "":
+		return nil, errors.New("repository cannot be combined with all_repos")
+	case !flags.AllRepos && flags.Repository == "":
+		return nil, errors.New("a bitbucket repository must be specified")
+	case flags.GroupByProject && !flags.AllRepos:
+		return nil, errors.New("group_by_project requires all_repos")
+	}
+	if err := ValidateWaveName(flags.Wave); err != nil {
+		return nil, err
+	}
+	if err := ValidateOutputPrefix(flags.OutputPrefix); err != nil {
+		return nil, err
+	}
+	if err := ValidateExportFlags(&flags); err != nil {
+		return nil, err
+	}
+	return &flags, nil
+}
+
+func (s *ExportServer) runJob(job data.ExportJob) {
+	logger := s.logger.With(zap.String("job", job.ID))
+	logger.Info("Starting export job", zap.Int("attempt", job.Attempts))
+
+	var outputs []string
+	flags, err := s.jobFlags(job.Request, job.OutputDir)
+	if err == nil {
+		outputs, err = s.run(s.ctx, flags, logger)
+	}
+	reports := jobReports(job.OutputDir)
+	interrupted := err != nil && s.ctx.Err() != nil
+
+	storeErr := s.store.update(func(jobs []data.ExportJob) ([]data.ExportJob, error) {
+		for i := range jobs {
+			if jobs[i].ID != job.ID {
+				continue
+			}
+			jobs[i].FinishedAt = time.Now().UTC().Format(time.RFC3339)
+			jobs[i].Archives = outputs
+			jobs[i].Reports = reports
+			switch {
+			case interrupted:
+				jobs[i].Status = JobStatusInterrupted
+				jobs[i].Error = SanitizeSupportText(err.Error())
+			case err != nil:
+				jobs[i].Status = JobStatusFailed
+				jobs[i].Error = SanitizeSupportText(err.Error())
+			default:
+				jobs[i].Status = JobStatusCompleted
+			}
+		}
+		return jobs, nil
+	})
+	if storeErr != nil {
+		logger.Error("Failed to record the job result", zap.Error(storeErr))
+	}
+
+	if interrupted {
+		logger.Warn("Export job interrupted by the server stopping; retry it with jobs retry", zap.Error(err))
+		return
+	}
+	if err != nil {
+		logger.Error("Export job failed", zap.Error(err))
+		return
+	}
+	logger.Info("Export job completed", zap.Strings("archives", outputs))
+}
+
+// jobReports finds the export reports written under a job's output
+// directory: one for a single export, one per project or split target
+// otherwise.
+func jobReports(outputDir string) []string {
+	var reports []string
+	for _, pattern := range []string{
+		filepath.Join(outputDir, "*"+exportReportFile),
+		filepath.Join(outputDir, "*", "*"+exportReportFile),
+	} {
+		matches, _ := filepath.Glob(pattern)
+		reports = append(reports, matches...)
+	}
+	return reports
+}
+
+func writeServerJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(value)
+}
+
+func writeServerError(w http.ResponseWriter, status int, err error) {
+	writeServerJSON(w, status, map[string]string{"error": err.Error()})
+}
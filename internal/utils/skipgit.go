@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// ValidateGitContentOptions checks --skip-git and --git-depth and rejects the
+// options that need the history they leave out.
+func ValidateGitContentOptions(cmdFlags *data.CmdExportFlags) error {
+	var problems []error
+	if cmdFlags.GitDepth < 0 {
+		problems = append(problems, fmt.Errorf("invalid value for --git-depth: %d (must be 0 or more)", cmdFlags.GitDepth))
+	}
+	if cmdFlags.SkipGit && cmdFlags.GitDepth > 0 {
+		problems = append(problems, errors.New("--skip-git cannot be combined with --git-depth"))
+	}
+	if cmdFlags.LocalMirror != "" && (cmdFlags.SkipGit || cmdFlags.GitDepth > 0) {
+		problems = append(problems, errors.New("--local-mirror cannot be combined with --skip-git or --git-depth"))
+	}
+	if cmdFlags.GitDepth > 0 && len(cmdFlags.SubdirSplits) > 0 {
+		problems = append(problems, errors.New("--subdir-split needs the full history and cannot be combined with --git-depth"))
+	}
+
+	if cmdFlags.SkipGit {
+		conflicts := []struct {
+			set  bool
+			flag string
+		}{
+			{cmdFlags.TopUpFetch, "--top-up-fetch"},
+			{len(cmdFlags.SubdirSplits) > 0, "--subdir-split"},
+			{len(cmdFlags.PRsTouchingPaths) > 0, "--prs-touching-path"},
+			{cmdFlags.AnalyzeDocs, "--analyze-docs"},
+			{cmdFlags.FeatureChecklist, "--feature-checklist"},
+			{cmdFlags.ProtectionHealth, "--protection-health"},
+			{cmdFlags.ConvertPipelines, "--convert-pipelines"},
+			{cmdFlags.GitOutput != "" && cmdFlags.GitOutput != GitOutputMirror, "--git-output " + cmdFlags.GitOutput},
+		}
+		for _, conflict := range conflicts {
+			if conflict.set {
+				problems = append(problems, fmt.Errorf("%s reads the cloned repository and cannot be combined with --skip-git", conflict.flag))
+			}
+		}
+	}
+	return JoinProblems(problems...)
+}
+
+// SetSkipGit exports metadata only: instead of cloning, every repository is
+// stored as an empty bare repository at the path its git_url points to, so
+// the archive keeps its layout.
+func (e *Exporter) SetSkipGit(enabled bool) {
+	e.skipGit = enabled
+}
+
+// SetGitDepth clones repositories with at most depth commits of history per
+// branch. 0 clones the full history.
+func (e *Exporter) SetGitDepth(depth int) {
+	e.gitDepth = depth
+}
+
+// cloneDepthArgs returns the git clone arguments for --git-depth. --depth
+// implies --single-branch, which would leave every branch but the default
+// one out of the mirror.
+func (e *Exporter) cloneDepthArgs() []string {
+	if e.gitDepth <= 0 {
+		return nil
+	}
+	return []string{"--depth", strconv.Itoa(e.gitDepth), "--no-single-branch"}
+}
+
+// writeGitPlaceholder stores an empty bare repository in place of the mirror
+// of a repository. HEAD points at the default branch of the repository
+// record.
+func (e *Exporter) writeGitPlaceholder(workspace, repoSlug string) error {
+	repoDir := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+	if err := os.RemoveAll(repoDir); err != nil {
+		return fmt.Errorf("failed to remove existing repository directory: %w", err)
+	}
+
+	cmd := exec.Command("git", "init", "--bare", "--quiet", repoDir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to create placeholder repository for %s: %s: %w",
+			repoSlug, strings.TrimSpace(string(output)), err)
+	}
+
+	defaultBranch := "main"
+	for _, repo := range e.repositories {
+		if repo.Slug == repoSlug && repo.DefaultBranch != "" {
+			defaultBranch = repo.DefaultBranch
+		}
+	}
+	if err := validateGitReference(defaultBranch); err != nil {
+		return fmt.Errorf("invalid branch reference: %w", err)
+	}
+	headContent := fmt.Sprintf("ref: refs/heads/%s\n", defaultBranch)
+	if err := os.WriteFile(filepath.Join(repoDir, "HEAD"), []byte(headContent), 0644); err != nil {
+		return fmt.Errorf("failed to update HEAD file: %w", err)
+	}
+
+	if err := e.createRepositoryInfoFiles(workspace, repoSlug); err != nil {
+		e.logger.Warn("Failed to create repository info files",
+			zap.String("repository", repoSlug),
+			zap.Error(err))
+	}
+
+	e.report.Counts.PlaceholderRepositories++
+	e.logger.Warn("Skipped cloning; the archive holds an empty placeholder repository, so its pull requests cannot be imported",
+		zap.String("repository", repoSlug),
+		zap.String("default_branch", defaultBranch))
+	return nil
+}
@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidateGitContentOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   data.CmdExportFlags
+		wantErr string
+	}{
+		{name: "defaults", flags: data.CmdExportFlags{GitOutput: GitOutputMirror}},
+		{name: "skip git", flags: data.CmdExportFlags{SkipGit: true, GitOutput: GitOutputMirror}},
+		{name: "shallow", flags: data.CmdExportFlags{GitDepth: 10, TopUpFetch: true, GitOutput: GitOutputBundle}},
+		{name: "negative depth", flags: data.CmdExportFlags{GitDepth: -1}, wantErr: "--git-depth: -1"},
+		{name: "both", flags: data.CmdExportFlags{SkipGit: true, GitDepth: 1}, wantErr: "--skip-git cannot be combined with --git-depth"},
+		{name: "local mirror", flags: data.CmdExportFlags{GitDepth: 1, LocalMirror: "/tmp/repo.git"}, wantErr: "--local-mirror"},
+		{name: "shallow split", flags: data.CmdExportFlags{GitDepth: 1, SubdirSplits: []string{"app"}}, wantErr: "--subdir-split"},
+		{name: "skip with top-up", flags: data.CmdExportFlags{SkipGit: true, TopUpFetch: true}, wantErr: "--top-up-fetch reads"},
+		{name: "skip with bundle", flags: data.CmdExportFlags{SkipGit: true, GitOutput: GitOutputBoth}, wantErr: "--git-output both"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateGitContentOptions(&tc.flags)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+}
+
+func TestWriteGitPlaceholder(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available for testing")
+	}
+	outputDir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.repositories = []data.Repository{{Slug: "repo", DefaultBranch: "develop"}}
+
+	require.NoError(t, exporter.writeGitPlaceholder("ws", "repo"))
+
+	repoDir := filepath.Join(outputDir, "repositories", "ws", "repo.git")
+	head, err := os.ReadFile(filepath.Join(repoDir, "HEAD"))
+	require.NoError(t, err)
+	assert.Equal(t, "ref: refs/heads/develop\n", string(head))
+	nwo, err := os.ReadFile(filepath.Join(repoDir, "info", "nwo"))
+	require.NoError(t, err)
+	assert.Equal(t, "ws/repo\n", string(nwo))
+	assert.Equal(t, "true", runGit(t, repoDir, "rev-parse", "--is-bare-repository"))
+	assert.False(t, hasRefs(repoDir))
+	assert.Equal(t, 1, exporter.report.Counts.PlaceholderRepositories)
+}
+
+func TestCloneMirrorWithGitDepth(t *testing.T) {
+	workDir := filepath.Join(t.TempDir(), "work")
+	if err := exec.Command("git", "init", "-b", "main", workDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	for _, message := range []string{"one", "two", "three"} {
+		runGit(t, workDir, "commit", "--allow-empty", "-m", message)
+	}
+	runGit(t, workDir, "checkout", "-b", "feature")
+	runGit(t, workDir, "commit", "--allow-empty", "-m", "feature")
+	runGit(t, workDir, "checkout", "main")
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.SetGitDepth(1)
+	mirror := filepath.Join(t.TempDir(), "mirror")
+	require.NoError(t, exporter.cloneMirror("file://"+workDir, mirror))
+
+	assert.Equal(t, "refs/heads/feature\nrefs/heads/main", runGit(t, mirror, "for-each-ref", "--format=%(refname)", "refs/heads"))
+	assert.Equal(t, "1", runGit(t, mirror, "rev-list", "--count", "main"))
+	assert.Equal(t, "1", runGit(t, mirror, "rev-list", "--count", "feature"))
+	assert.FileExists(t, filepath.Join(mirror, "shallow"))
+}
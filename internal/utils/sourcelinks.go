@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
+)
+
+var (
+	// commitHashPattern matches abbreviated and full SHA-1 or SHA-256 hashes.
+	commitHashPattern = regexp.MustCompile(`^[0-9a-fA-F]{7,64}$`)
+	// sourceLineAnchorPattern matches Bitbucket's #lines-10 and #lines-10:20
+	// anchors on source views.
+	sourceLineAnchorPattern = regexp.MustCompile(`^lines-(\d+)(?::(\d+))?$`)
+)
+
+// ValidateLinkTarget checks a --link-target value: the URL of a GitHub
+// repository, in which {repository} stands for the exported repository.
+func ValidateLinkTarget(target string) error {
+	if target == "" {
+		return nil
+	}
+	parsed, err := url.Parse(format.ExpandTemplate(target, "", "", "repository"))
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" ||
+		len(strings.Split(strings.Trim(parsed.Path, "/"), "/")) != 2 {
+		return fmt.Errorf("invalid value for --link-target: %q (expected a repository URL such as https://github.com/<org>/<repository>)", target)
+	}
+	return nil
+}
+
+// SetLinkTarget rewrites links to commits, compare views and source files of
+// the exported repository in comment bodies to the same views of a GitHub
+// repository. {repository} in target is replaced with the repository slug;
+// an empty target keeps the links pointing at Bitbucket.
+func (c *Client) SetLinkTarget(target string) {
+	c.linkTarget = strings.TrimSuffix(target, "/")
+}
+
+// rewriteSourceLinks rewrites Bitbucket commit, compare and src links of a
+// repository in body to their GitHub equivalents under --link-target:
+//   - commits/<sha> becomes commit/<sha>
+//   - branches/compare/<source>%0D<destination> (or <source>..<destination>)
+//     becomes compare/<destination>...<source>
+//   - src/<ref>/<path> becomes blob/<ref>/<path>, or tree/<ref>/<path> for
+//     the repository root and directories, with #lines-N:M anchors as #LN-LM
+//
+// Links Bitbucket has no GitHub equivalent for are left unchanged.
+func (c *Client) rewriteSourceLinks(body, workspace, repoSlug string) string {
+	if c.linkTarget == "" || body == "" {
+		return body
+	}
+	target := format.ExpandTemplate(c.linkTarget, "", workspace, repoSlug)
+	pattern := regexp.MustCompile(fmt.Sprintf(`https://bitbucket\.org/%s/%s/(commits|branches/compare|src)/([^\s)\]<>"']+)`,
+		regexp.QuoteMeta(workspace), regexp.QuoteMeta(repoSlug)))
+
+	return pattern.ReplaceAllStringFunc(body, func(match string) string {
+		// Punctuation ending a sentence is not part of the link.
+		link := strings.TrimRight(match, ".,;:!?")
+		trailing := match[len(link):]
+		groups := pattern.FindStringSubmatch(link)
+		if groups == nil {
+			return match
+		}
+		rewritten, ok := githubSourceLink(target, groups[1], groups[2])
+		if !ok {
+			return match
+		}
+		return rewritten + trailing
+	})
+}
+
+// githubSourceLink converts the part of a Bitbucket link after
+// commits/, branches/compare/ or src/ into the GitHub URL of the same view.
+func githubSourceLink(target, kind, rest string) (string, bool) {
+	rest, fragment, _ := strings.Cut(rest, "#")
+	rest, _, _ = strings.Cut(rest, "?")
+	if rest == "" {
+		return "", false
+	}
+
+	switch kind {
+	case "commits":
+		if !commitHashPattern.MatchString(rest) {
+			return "", false
+		}
+		return fmt.Sprintf("%s/commit/%s", target, rest), true
+	case "branches/compare":
+		source, destination, found := cutCompareSpec(rest)
+		if !found || source == "" || destination == "" {
+			return "", false
+		}
+		return fmt.Sprintf("%s/compare/%s...%s", target, destination, source), true
+	default:
+		view := "blob"
+		if !strings.Contains(strings.TrimSuffix(rest, "/"), "/") || strings.HasSuffix(rest, "/") {
+			view = "tree"
+		}
+		link := fmt.Sprintf("%s/%s/%s", target, view, strings.TrimSuffix(rest, "/"))
+		if lines := sourceLineAnchorPattern.FindStringSubmatch(fragment); lines != nil && view == "blob" {
+			link += "#L" + lines[1]
+			if lines[2] != "" {
+				link += "-L" + lines[2]
+			}
+		}
+		return link, true
+	}
+}
+
+// cutCompareSpec splits a Bitbucket compare spec into its source and
+// destination, separated by an encoded carriage return or "..".
+func cutCompareSpec(spec string) (string, string, bool) {
+	for _, separator := range []string{"%0D", "%0d", ".."} {
+		if source, destination, found := strings.Cut(spec, separator); found {
+			return source, destination, true
+		}
+	}
+	return "", "", false
+}
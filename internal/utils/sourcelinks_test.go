@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestValidateLinkTarget(t *testing.T) {
+	for _, target := range []string{"", "https://github.com/org/repo", "https://github.com/org/{repository}/", "https://ghe.example.com/org/repo"} {
+		assert.NoError(t, ValidateLinkTarget(target), target)
+	}
+	for _, target := range []string{"github.com/org/repo", "https://github.com/org", "https://github.com/org/repo/tree/main", "ftp://github.com/org/repo"} {
+		assert.ErrorContains(t, ValidateLinkTarget(target), "invalid value for --link-target", target)
+	}
+}
+
+func TestRewriteSourceLinks(t *testing.T) {
+	client := &Client{logger: zap.NewNop()}
+	client.SetLinkTarget("https://github.com/org/{repository}")
+	const source = "https://bitbucket.org/ws/repo"
+	const target = "https://github.com/org/repo"
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"commit", "Fixed in " + source + "/commits/0123abcd.", "Fixed in " + target + "/commit/0123abcd."},
+		{"commit of a branch", source + "/commits/branch/main", source + "/commits/branch/main"},
+		{"compare", "[diff](" + source + "/branches/compare/feature/x%0Dmain#diff)", "[diff](" + target + "/compare/main...feature/x)"},
+		{"compare with dots", source + "/branches/compare/abc1234..def5678", target + "/compare/def5678...abc1234"},
+		{"file with lines", source + "/src/main/cmd/main.go#lines-10:20", target + "/blob/main/cmd/main.go#L10-L20"},
+		{"file with a line and query", source + "/src/0123abcd/README.md?at=main#lines-3", target + "/blob/0123abcd/README.md#L3"},
+		{"directory", source + "/src/main/docs/", target + "/tree/main/docs"},
+		{"repository root", source + "/src/main", target + "/tree/main"},
+		{"other repository", "https://bitbucket.org/ws/other/commits/0123abcd", "https://bitbucket.org/ws/other/commits/0123abcd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, client.rewriteSourceLinks(tt.body, "ws", "repo"))
+		})
+	}
+}
+
+func TestTransformCommentBodyLinkTarget(t *testing.T) {
+	client := &Client{logger: zap.NewNop()}
+	body := "See https://bitbucket.org/ws/repo/commits/0123abcd"
+
+	assert.Equal(t, body, client.transformCommentBody(body, "ws", "repo"), "links are kept without --link-target")
+
+	client.SetLinkTarget("https://github.com/org/repo/")
+	assert.Equal(t, "See https://github.com/org/repo/commit/0123abcd", client.transformCommentBody(body, "ws", "repo"))
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const filterRepoCommand = "git-filter-repo"
+
+// ParseSubdirSplits parses --subdir-split values of the form path=new-repo-name.
+func ParseSubdirSplits(values []string) ([]data.SubdirSplit, error) {
+	splits := make([]data.SubdirSplit, 0, len(values))
+	seen := make(map[string]bool)
+	for _, value := range values {
+		idx := strings.LastIndex(value, "=")
+		if idx <= 0 || idx == len(value)-1 {
+			return nil, fmt.Errorf("invalid --subdir-split value %q (expected path=new-repo-name)", value)
+		}
+
+		path := strings.Trim(ToUnixPath(strings.TrimSpace(value[:idx])), "/")
+		repoName := strings.TrimSpace(value[idx+1:])
+		if path == "" || repoName == "" {
+			return nil, fmt.Errorf("invalid --subdir-split value %q (expected path=new-repo-name)", value)
+		}
+		if repoNameInvalidCharsRegex.MatchString(repoName) {
+			return nil, fmt.Errorf("invalid repository name %q in --subdir-split", repoName)
+		}
+		if seen[repoName] {
+			return nil, fmt.Errorf("duplicate repository name %q in --subdir-split", repoName)
+		}
+		seen[repoName] = true
+
+		splits = append(splits, data.SubdirSplit{Path: path, RepoName: repoName})
+	}
+	return splits, nil
+}
+
+func (e *Exporter) SetSubdirSplit(path string) {
+	e.splitSubdir = path
+}
+
+// applySubdirSplit rewrites the exported mirror so it only contains the
+// history of the split sub-directory, then points pull request and review
+// comment SHAs at the rewritten commits. Empty commits are kept so every
+// original commit has a counterpart in the new history.
+func (e *Exporter) applySubdirSplit(workspace, repoSlug string, prs []data.PullRequest, reviewComments []data.PullRequestReviewComment) error {
+	if !isExecutableInPath(filterRepoCommand) {
+		return fmt.Errorf("%s is required for --subdir-split; install it from https://github.com/newren/git-filter-repo", filterRepoCommand)
+	}
+
+	repoPath := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+	e.logger.Info("Extracting sub-directory history",
+		zap.String("repository", repoSlug),
+		zap.String("subdir", e.splitSubdir))
+
+	ctx, cancel := e.deadlineContext()
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "filter-repo",
+		"--subdirectory-filter", e.splitSubdir,
+		"--prune-empty", "never",
+		"--prune-degenerate", "never",
+		"--force")
+	cmd.Dir = repoPath
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if deadlineErr := e.checkDeadline(stageGitClone); deadlineErr != nil {
+			return deadlineErr
+		}
+		return fmt.Errorf("failed to extract %s with git filter-repo: %s: %w", e.splitSubdir, string(output), err)
+	}
+
+	// git filter-repo leaves its commit map and other metadata in the mirror;
+	// drop them once read so they do not end up in the archive.
+	commitMap, err := readFilterRepoCommitMap(filepath.Join(repoPath, "filter-repo", "commit-map"))
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(filepath.Join(repoPath, "filter-repo")); err != nil {
+		return fmt.Errorf("failed to remove git filter-repo metadata: %w", err)
+	}
+
+	for i := range prs {
+		prs[i].Base.SHA = e.remapSplitSHA(commitMap, prs[i].Base.SHA)
+		prs[i].Head.SHA = e.remapSplitSHA(commitMap, prs[i].Head.SHA)
+		if prs[i].MergeCommitSHA != nil {
+			mergeSHA := e.remapSplitSHA(commitMap, *prs[i].MergeCommitSHA)
+			prs[i].MergeCommitSHA = &mergeSHA
+		}
+	}
+	for i := range reviewComments {
+		reviewComments[i].CommitID = e.remapSplitSHA(commitMap, reviewComments[i].CommitID)
+		reviewComments[i].OriginalCommitId = e.remapSplitSHA(commitMap, reviewComments[i].OriginalCommitId)
+	}
+
+	e.logger.Info("Sub-directory history extracted",
+		zap.String("subdir", e.splitSubdir),
+		zap.Int("rewritten_commits", len(commitMap)))
+	return nil
+}
+
+// readFilterRepoCommitMap parses the "old new" commit map git filter-repo
+// writes next to the rewritten repository.
+func readFilterRepoCommitMap(path string) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open filter-repo commit map: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	commitMap := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || fields[0] == "old" {
+			continue
+		}
+		if strings.Trim(fields[1], "0") == "" {
+			continue
+		}
+		commitMap[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read filter-repo commit map: %w", err)
+	}
+	return commitMap, nil
+}
+
+func (e *Exporter) remapSplitSHA(commitMap map[string]string, sha string) string {
+	if sha == "" {
+		return sha
+	}
+	if newSHA, ok := commitMap[sha]; ok {
+		return newSHA
+	}
+	for oldSHA, newSHA := range commitMap {
+		if strings.HasPrefix(oldSHA, sha) {
+			return newSHA
+		}
+	}
+	e.logger.Debug("Commit not present in rewritten history, keeping original SHA",
+		zap.String("sha", sha))
+	return sha
+}
+
+// filterSplitReviewComments keeps review comments on files inside the split
+// sub-directory and rewrites their paths relative to the new repository root.
+func (e *Exporter) filterSplitReviewComments(comments []data.PullRequestReviewComment) []data.PullRequestReviewComment {
+	prefix := e.splitSubdir + "/"
+	kept := []data.PullRequestReviewComment{}
+	dropped := 0
+	for _, comment := range comments {
+		if !strings.HasPrefix(comment.Path, prefix) {
+			dropped++
+			continue
+		}
+		comment.Path = strings.TrimPrefix(comment.Path, prefix)
+		kept = append(kept, comment)
+	}
+
+	if dropped > 0 {
+		e.logger.Info("Dropped review comments on files outside the split sub-directory",
+			zap.String("subdir", e.splitSubdir),
+			zap.Int("dropped", dropped))
+	}
+	return kept
+}
+
+// ExportSubdirSplits produces one independent archive per --subdir-split
+// target. Each archive contains the sub-directory's rewritten history and
+// only the pull requests that touched it. With several targets, archiving
+// waits until all targets are exported, so that links to pull requests that
+// moved to another target can be rewritten from the split manifest.
+func ExportSubdirSplits(client *Client, cmdFlags *data.CmdExportFlags, logger *zap.Logger) ([]string, error) {
+	splits, err := ParseSubdirSplits(cmdFlags.SubdirSplits)
+	if err != nil {
+		return nil, err
+	}
+
+	baseOutputDir := cmdFlags.OutputDir
+	if baseOutputDir == "" {
+		baseOutputDir = DefaultOutputDir(cmdFlags.Wave)
+	}
+
+	deadline := ExportDeadline(cmdFlags)
+	deferArchive := len(splits) > 1
+	var exporters []*Exporter
+	var outputs []string
+	for _, split := range splits {
+		logger.Info("Exporting sub-directory split",
+			zap.String("subdir", split.Path),
+			zap.String("target_repo", split.RepoName))
+
+		exporter := NewExporter(client, filepath.Join(baseOutputDir, split.RepoName), logger, cmdFlags.OpenPRsOnly, cmdFlags.PRsFromDate)
+		if cmdFlags.TempDir != "" {
+			exporter.SetTempDir(cmdFlags.TempDir)
+		}
+		if err := exporter.ApplyExportFlags(cmdFlags); err != nil {
+			return archiveSplits(exporters, outputs, logger), err
+		}
+		exporter.SetDeadline(deadline)
+		if err := exporter.SetPRPathFilters([]string{split.Path + "/**"}); err != nil {
+			return archiveSplits(exporters, outputs, logger), err
+		}
+		exporter.SetSubdirSplit(split.Path)
+		exporter.deferArchive = deferArchive
+
+		if err := exporter.ExportRepositories(cmdFlags.Workspace, []string{cmdFlags.Repository}); err != nil {
+			return archiveSplits(exporters, outputs, logger), fmt.Errorf("failed to export split %s: %w", split.RepoName, err)
+		}
+		if !deferArchive {
+			outputs = append(outputs, exporter.GetOutputPath())
+		}
+		exporters = append(exporters, exporter)
+	}
+
+	if !deferArchive {
+		return outputs, nil
+	}
+
+	manifest, err := fixSplitLinks(cmdFlags.Workspace, cmdFlags.Repository, cmdFlags.SplitLinkBase, splits, exporters, logger)
+	if err != nil {
+		return archiveSplits(exporters, outputs, logger), err
+	}
+	for i, exporter := range exporters {
+		if err := exporter.completeDeferredArchive(); err != nil {
+			return outputs, fmt.Errorf("failed to archive split %s: %w", splits[i].RepoName, err)
+		}
+		outputs = append(outputs, exporter.GetOutputPath())
+		manifest.Targets[i].Output = exporter.GetOutputPath()
+	}
+	if err := writeSplitManifest(baseOutputDir, manifest); err != nil {
+		logger.Warn("Failed to write split manifest", zap.Error(err))
+	}
+	return outputs, nil
+}
+
+// archiveSplits archives targets whose archiving was deferred when a later
+// target fails, so finished work is not lost; their links are left as
+// exported.
+func archiveSplits(exporters []*Exporter, outputs []string, logger *zap.Logger) []string {
+	for _, exporter := range exporters {
+		if !exporter.deferArchive {
+			continue
+		}
+		if err := exporter.completeDeferredArchive(); err != nil {
+			logger.Warn("Failed to archive split", zap.String("output", exporter.GetOutputPath()), zap.Error(err))
+			continue
+		}
+		outputs = append(outputs, exporter.GetOutputPath())
+	}
+	return outputs
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseSubdirSplits(t *testing.T) {
+	splits, err := ParseSubdirSplits([]string{"src/service-a=service-a", "/libs/shared/=shared-lib"})
+	require.NoError(t, err)
+	assert.Equal(t, []data.SubdirSplit{
+		{Path: "src/service-a", RepoName: "service-a"},
+		{Path: "libs/shared", RepoName: "shared-lib"},
+	}, splits)
+
+	invalid := map[string][]string{
+		"expected path=new-repo-name": {"src/service-a"},
+		"invalid --subdir-split":      {"=service-a"},
+		"invalid repository name":     {"src/a=bad name"},
+		"duplicate repository name":   {"src/a=repo", "src/b=repo"},
+	}
+	for wantErr, values := range invalid {
+		_, err := ParseSubdirSplits(values)
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), wantErr)
+	}
+}
+
+func TestReadFilterRepoCommitMap(t *testing.T) {
+	oldA := strings.Repeat("a", 40)
+	oldB := strings.Repeat("b", 40)
+	newA := strings.Repeat("c", 40)
+	mapPath := filepath.Join(t.TempDir(), "commit-map")
+	content := "old                                      new\n" +
+		oldA + " " + newA + "\n" +
+		oldB + " " + strings.Repeat("0", 40) + "\n"
+	require.NoError(t, os.WriteFile(mapPath, []byte(content), 0644))
+
+	commitMap, err := readFilterRepoCommitMap(mapPath)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{oldA: newA}, commitMap)
+
+	_, err = readFilterRepoCommitMap(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestRemapSplitSHA(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	exporter := NewExporter(&Client{logger: logger}, t.TempDir(), logger, false, "")
+
+	oldSHA := "1234567" + strings.Repeat("a", 33)
+	newSHA := strings.Repeat("f", 40)
+	commitMap := map[string]string{oldSHA: newSHA}
+
+	assert.Equal(t, newSHA, exporter.remapSplitSHA(commitMap, oldSHA))
+	assert.Equal(t, newSHA, exporter.remapSplitSHA(commitMap, "1234567"))
+	assert.Equal(t, "deadbeef", exporter.remapSplitSHA(commitMap, "deadbeef"))
+	assert.Equal(t, "", exporter.remapSplitSHA(commitMap, ""))
+}
+
+func TestFilterSplitReviewComments(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	exporter := NewExporter(&Client{logger: logger}, t.TempDir(), logger, false, "")
+	exporter.SetSubdirSplit("src/service-a")
+
+	comments := []data.PullRequestReviewComment{
+		{URL: "inside", Path: "src/service-a/pkg/main.go"},
+		{URL: "outside", Path: "src/service-b/main.go"},
+		{URL: "prefix", Path: "src/service-ab/main.go"},
+	}
+
+	kept := exporter.filterSplitReviewComments(comments)
+	require.Len(t, kept, 1)
+	assert.Equal(t, "inside", kept[0].URL)
+	assert.Equal(t, "pkg/main.go", kept[0].Path)
+}
+
+func TestApplySubdirSplitRequiresFilterRepo(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	logger, _ := zap.NewDevelopment()
+	exporter := NewExporter(&Client{logger: logger}, t.TempDir(), logger, false, "")
+	exporter.SetSubdirSplit("src/service-a")
+
+	err := exporter.applySubdirSplit("workspace", "repo", nil, nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "git-filter-repo is required")
+}
+
+func TestApplySubdirSplitRemovesFilterRepoMetadata(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script in place of git-filter-repo")
+	}
+	oldSHA := strings.Repeat("a", 40)
+	newSHA := strings.Repeat("b", 40)
+
+	// Stands in for git-filter-repo: writes the commit map it leaves behind.
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nmkdir -p filter-repo\nprintf 'old new\\n" + oldSHA + " " + newSHA + "\\n' > filter-repo/commit-map\n"
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, filterRepoCommand), []byte(script), 0755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	outputDir := t.TempDir()
+	repoPath := filepath.Join(outputDir, "repositories", "workspace", "repo.git")
+	require.NoError(t, os.MkdirAll(repoPath, 0755))
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.SetSubdirSplit("src/service-a")
+	prs := []data.PullRequest{{Head: data.PRBranch{SHA: oldSHA}}}
+
+	require.NoError(t, exporter.applySubdirSplit("workspace", "repo", prs, nil))
+	assert.Equal(t, newSHA, prs[0].Head.SHA)
+	assert.NoDirExists(t, filepath.Join(repoPath, "filter-repo"), "filter-repo metadata stays out of the archive")
+}
@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
+	"go.uber.org/zap"
+)
+
+const splitManifestFile = "split-manifest.json"
+
+// ValidateSplitLinkBase checks the --split-link-base URL.
+func ValidateSplitLinkBase(value string) error {
+	if value == "" {
+		return nil
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return fmt.Errorf("invalid value for --split-link-base: %q (expected a URL such as https://github.com/your-org)", value)
+	}
+	return nil
+}
+
+// splitPullRequestNumbers lists the pull request numbers written to an
+// export directory.
+func splitPullRequestNumbers(urls format.URLs, outputDir string) ([]int, error) {
+	prs, err := readRecordFiles[data.PullRequest](outputDir, pullRequestsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	numbers := make([]int, 0, len(prs))
+	for _, pr := range prs {
+		if number, err := strconv.Atoi(extractPRNumber(urls, pr.URL)); err == nil {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// splitLinkRewriter rewrites links to pull requests of the source repository
+// that are not part of one split target. Links to pull requests that moved
+// to another target point at that target under linkBase; without a link
+// base they point at the original Bitbucket pull request, so the importer
+// does not resolve them to an unrelated pull request of the same number.
+type splitLinkRewriter struct {
+	pattern  *regexp.Regexp
+	own      map[int]bool
+	owners   map[int]string // PR number -> first target that holds it
+	linkBase string
+	source   string // workspace/repo
+	base     string // Base of the original Bitbucket URLs
+	rewrites int
+}
+
+func newSplitLinkRewriter(urls format.URLs, workspace, repoSlug string, manifest *data.SplitManifest, target data.SplitTarget) *splitLinkRewriter {
+	rewriter := &splitLinkRewriter{
+		pattern:  regexp.MustCompile(`(\[#(\d+)\]\()?` + urlPattern(urls, "pr", workspace, repoSlug).String()),
+		own:      make(map[int]bool),
+		owners:   make(map[int]string),
+		linkBase: strings.TrimSuffix(manifest.LinkBase, "/"),
+		source:   workspace + "/" + repoSlug,
+		base:     urlBase(urls),
+	}
+	for _, number := range target.PullRequests {
+		rewriter.own[number] = true
+	}
+	for _, other := range manifest.Targets {
+		for _, number := range other.PullRequests {
+			if _, ok := rewriter.owners[number]; !ok {
+				rewriter.owners[number] = other.RepoName
+			}
+		}
+	}
+	return rewriter
+}
+
+func (r *splitLinkRewriter) rewrite(body string) string {
+	return r.pattern.ReplaceAllStringFunc(body, func(match string) string {
+		groups := r.pattern.FindStringSubmatch(match)
+		number, err := strconv.Atoi(groups[3])
+		if err != nil || r.own[number] {
+			return match
+		}
+
+		prefix := groups[1]
+		owner, moved := r.owners[number]
+		var link string
+		switch {
+		case moved && r.linkBase != "":
+			link = fmt.Sprintf("%s/%s/pull/%d", r.linkBase, owner, number)
+		default:
+			link = fmt.Sprintf("%s/%s/pull-requests/%d", r.base, r.source, number)
+		}
+		if prefix != "" && moved {
+			prefix = fmt.Sprintf("[%s#%s](", owner, groups[2])
+		}
+		r.rewrites++
+		return prefix + link
+	})
+}
+
+// rewriteSplitLinks applies the rewriter to the bodies of the pull requests
+// and comments of an export directory.
+func (e *Exporter) rewriteSplitLinks(rewriter *splitLinkRewriter) error {
+	prFiles, err := recordFileNames(e.outputDir, pullRequestsFile)
+	if err != nil {
+		return err
+	}
+	for _, name := range prFiles {
+		var prs []data.PullRequest
+		if err := e.rewriteExportFile(name, &prs, func() {
+			for i := range prs {
+				prs[i].Body = rewriter.rewrite(prs[i].Body)
+			}
+		}); err != nil {
+			return err
+		}
+	}
+
+	issueCommentFiles, err := recordFileNames(e.outputDir, issueCommentsFile)
+	if err != nil {
+		return err
+	}
+	for _, name := range issueCommentFiles {
+		var issueComments []data.IssueComment
+		if err := e.rewriteExportFile(name, &issueComments, func() {
+			for i := range issueComments {
+				issueComments[i].Body = rewriter.rewrite(issueComments[i].Body)
+			}
+		}); err != nil {
+			return err
+		}
+	}
+
+	reviewCommentFiles, err := recordFileNames(e.outputDir, reviewCommentsFile)
+	if err != nil {
+		return err
+	}
+	for _, name := range reviewCommentFiles {
+		var reviewComments []data.PullRequestReviewComment
+		if err := e.rewriteExportFile(name, &reviewComments, func() {
+			for i := range reviewComments {
+				reviewComments[i].Body = rewriter.rewrite(reviewComments[i].Body)
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewriteExportFile decodes an export file into v, applies update and writes
+// the file back. Missing files are skipped.
+func (e *Exporter) rewriteExportFile(filename string, v interface{}, update func()) error {
+	content, err := os.ReadFile(filepath.Join(e.outputDir, filename))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrCorruptExportFile, filename, err)
+	}
+	update()
+	return e.writeJSONFile(filename, v)
+}
+
+// fixSplitLinks builds the split manifest from the exported targets and
+// rewrites links to pull requests that ended up in another target.
+func fixSplitLinks(workspace, repoSlug, linkBase string, splits []data.SubdirSplit, exporters []*Exporter, logger *zap.Logger) (*data.SplitManifest, error) {
+	manifest := &data.SplitManifest{
+		SourceRepository: workspace + "/" + repoSlug,
+		LinkBase:         linkBase,
+	}
+	for i, exporter := range exporters {
+		numbers, err := splitPullRequestNumbers(exporter.client.sourceURLs(), exporter.outputDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read pull requests of split %s: %w", splits[i].RepoName, err)
+		}
+		manifest.Targets = append(manifest.Targets, data.SplitTarget{
+			RepoName:     splits[i].RepoName,
+			Path:         splits[i].Path,
+			PullRequests: numbers,
+		})
+	}
+
+	for i, exporter := range exporters {
+		rewriter := newSplitLinkRewriter(exporters[i].client.sourceURLs(), workspace, repoSlug, manifest, manifest.Targets[i])
+		if err := exporter.rewriteSplitLinks(rewriter); err != nil {
+			return nil, fmt.Errorf("failed to rewrite links of split %s: %w", splits[i].RepoName, err)
+		}
+		manifest.Targets[i].RewrittenLinks = rewriter.rewrites
+		if rewriter.rewrites > 0 {
+			logger.Info("Rewrote links to pull requests outside the split",
+				zap.String("target_repo", splits[i].RepoName),
+				zap.Int("links", rewriter.rewrites))
+		}
+	}
+	return manifest, nil
+}
+
+func writeSplitManifest(baseOutputDir string, manifest *data.SplitManifest) error {
+	if err := os.MkdirAll(baseOutputDir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(baseOutputDir, splitManifestFile), 0644, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(manifest)
+	})
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSplitLinkRewriter(t *testing.T) {
+	manifest := &data.SplitManifest{
+		LinkBase: "https://github.com/acme/",
+		Targets: []data.SplitTarget{
+			{RepoName: "service-a", PullRequests: []int{1, 3}},
+			{RepoName: "service-b", PullRequests: []int{2, 3}},
+		},
+	}
+
+	rewriter := newSplitLinkRewriter(format.URLs{}, "ws", "mono", manifest, manifest.Targets[0])
+	body := "See [#2](https://bitbucket.org/ws/mono/pull/2), " +
+		"https://bitbucket.org/ws/mono/pull/3 and https://bitbucket.org/ws/mono/pull/1/diff, " +
+		"https://bitbucket.org/ws/mono/pull/9 and https://bitbucket.org/ws/other/pull/2"
+
+	assert.Equal(t, "See [service-b#2](https://github.com/acme/service-b/pull/2), "+
+		"https://bitbucket.org/ws/mono/pull/3 and https://bitbucket.org/ws/mono/pull/1/diff, "+
+		"https://bitbucket.org/ws/mono/pull-requests/9 and https://bitbucket.org/ws/other/pull/2",
+		rewriter.rewrite(body))
+	assert.Equal(t, 2, rewriter.rewrites)
+}
+
+func TestSplitLinkRewriterWithoutLinkBase(t *testing.T) {
+	manifest := &data.SplitManifest{Targets: []data.SplitTarget{
+		{RepoName: "service-a", PullRequests: []int{1}},
+		{RepoName: "service-b", PullRequests: []int{2}},
+	}}
+
+	rewriter := newSplitLinkRewriter(format.URLs{}, "ws", "mono", manifest, manifest.Targets[0])
+	assert.Equal(t, "[service-b#2](https://bitbucket.org/ws/mono/pull-requests/2)",
+		rewriter.rewrite("[#2](https://bitbucket.org/ws/mono/pull/2)"))
+}
+
+func TestValidateSplitLinkBase(t *testing.T) {
+	assert.NoError(t, ValidateSplitLinkBase(""))
+	assert.NoError(t, ValidateSplitLinkBase("https://github.com/acme"))
+	assert.Error(t, ValidateSplitLinkBase("github.com/acme"))
+	assert.Error(t, ValidateSplitLinkBase("ftp://github.com/acme"))
+}
+
+func writeSplitExport(t *testing.T, dir string, prs []data.PullRequest, comments []data.IssueComment) *Exporter {
+	t.Helper()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, dir, zap.NewNop(), false, "")
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, exporter.writeJSONFile("pull_requests_000001.json", prs))
+	if comments != nil {
+		require.NoError(t, exporter.writeJSONFile("issue_comments_000001.json", comments))
+	}
+	return exporter
+}
+
+func TestFixSplitLinks(t *testing.T) {
+	baseDir := t.TempDir()
+	exporterA := writeSplitExport(t, filepath.Join(baseDir, "service-a"),
+		[]data.PullRequest{{URL: "https://bitbucket.org/ws/mono/pull/1",
+			Body: "Follow-up in https://bitbucket.org/ws/mono/pull/2"}},
+		[]data.IssueComment{{URL: "https://bitbucket.org/ws/mono/pull/1#issuecomment-5",
+			Body: "Duplicate of [#2](https://bitbucket.org/ws/mono/pull/2)"}})
+	exporterB := writeSplitExport(t, filepath.Join(baseDir, "service-b"),
+		[]data.PullRequest{{URL: "https://bitbucket.org/ws/mono/pull/2", Body: "Part of #1"}}, nil)
+
+	splits := []data.SubdirSplit{{Path: "a", RepoName: "service-a"}, {Path: "b", RepoName: "service-b"}}
+	manifest, err := fixSplitLinks("ws", "mono", "https://github.com/acme", splits,
+		[]*Exporter{exporterA, exporterB}, zap.NewNop())
+	require.NoError(t, err)
+
+	assert.Equal(t, "ws/mono", manifest.SourceRepository)
+	assert.Equal(t, []int{1}, manifest.Targets[0].PullRequests)
+	assert.Equal(t, []int{2}, manifest.Targets[1].PullRequests)
+	assert.Equal(t, 2, manifest.Targets[0].RewrittenLinks)
+	assert.Equal(t, 0, manifest.Targets[1].RewrittenLinks)
+
+	var prs []data.PullRequest
+	content, err := os.ReadFile(filepath.Join(baseDir, "service-a", "pull_requests_000001.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, &prs))
+	assert.Equal(t, "Follow-up in https://github.com/acme/service-b/pull/2", prs[0].Body)
+	assert.Equal(t, "https://bitbucket.org/ws/mono/pull/1", prs[0].URL, "record URLs are not rewritten")
+
+	var comments []data.IssueComment
+	content, err = os.ReadFile(filepath.Join(baseDir, "service-a", "issue_comments_000001.json"))
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(content, &comments))
+	assert.Equal(t, "Duplicate of [service-b#2](https://github.com/acme/service-b/pull/2)", comments[0].Body)
+
+	require.NoError(t, writeSplitManifest(baseDir, manifest))
+	assert.FileExists(t, filepath.Join(baseDir, splitManifestFile))
+}
+
+func TestCompleteDeferredArchive(t *testing.T) {
+	outputDir := filepath.Join(t.TempDir(), "service-a")
+	exporter := writeSplitExport(t, outputDir, []data.PullRequest{}, nil)
+	exporter.deferArchive = true
+	exporter.beginReport("ws", []string{"mono"})
+
+	require.NoError(t, exporter.completeDeferredArchive())
+
+	assert.Equal(t, outputDir+".tar.gz", exporter.GetOutputPath())
+	assert.FileExists(t, outputDir+".tar.gz")
+	var report data.ExportReport
+	readReportFile(t, filepath.Join(outputDir, exportReportFile), &report)
+	assert.Equal(t, reportStatusCompleted, report.Status)
+	assert.Equal(t, stageArchive, report.LastStage)
+}
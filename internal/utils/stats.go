@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// minArchiveSizeRatio is the smallest fraction of Bitbucket's reported
+// repository size the cloned repository may have before it is flagged. Git
+// repacks differently on every host, so sizes are never expected to match.
+const minArchiveSizeRatio = 0.5
+
+// SetCompareStats queries Bitbucket's repository statistics at the start of
+// the export and compares them with the archive contents at the end.
+func (e *Exporter) SetCompareStats(enabled bool) {
+	e.compareStats = enabled
+}
+
+// countCollection returns the total size of a paginated collection, or -1
+// when Bitbucket does not report one.
+func (c *Client) countCollection(endpoint string) (int, error) {
+	var response data.BitbucketCountResponse
+	if err := c.makeRequest("GET", endpoint, &response); err != nil {
+		return -1, err
+	}
+	if response.Size == nil {
+		return -1, nil
+	}
+	return *response.Size, nil
+}
+
+// GetRepositoryStatistics returns Bitbucket's own totals for a repository.
+// Totals that cannot be fetched are reported as -1.
+func (c *Client) GetRepositoryStatistics(workspace, repoSlug string, repo *data.BitbucketRepository) data.RepositoryStatistics {
+	stats := data.RepositoryStatistics{SizeBytes: -1, Commits: -1, Branches: -1, PullRequests: -1}
+	if repo != nil && repo.Size > 0 {
+		stats.SizeBytes = repo.Size
+	}
+
+	counts := []struct {
+		name     string
+		endpoint string
+		target   *int
+	}{
+		{"commits", fmt.Sprintf("repositories/%s/%s/commits?pagelen=1", workspace, repoSlug), &stats.Commits},
+		{"branches", fmt.Sprintf("repositories/%s/%s/refs/branches?pagelen=1", workspace, repoSlug), &stats.Branches},
+		{"pull_requests", fmt.Sprintf("repositories/%s/%s/pullrequests?state=ALL&pagelen=1", workspace, repoSlug), &stats.PullRequests},
+	}
+	for _, count := range counts {
+		total, err := c.countCollection(count.endpoint)
+		if err != nil {
+			c.logger.Warn("Failed to fetch repository statistic",
+				zap.String("repository", repoSlug),
+				zap.String("statistic", count.name),
+				zap.Error(err))
+		}
+		*count.target = total
+	}
+	return stats
+}
+
+// collectBitbucketStatistics records Bitbucket's totals before anything is
+// exported, so later changes to the repository do not skew the comparison.
+func (e *Exporter) collectBitbucketStatistics(workspace, repoSlug string, repo *data.BitbucketRepository) {
+	if !e.compareStats {
+		return
+	}
+	if e.bitbucketStats == nil {
+		e.bitbucketStats = make(map[string]data.RepositoryStatistics)
+	}
+	e.bitbucketStats[repoSlug] = e.client.GetRepositoryStatistics(workspace, repoSlug, repo)
+}
+
+// archiveStatistics counts what ended up in the export directory for a
+// repository, including pull requests moved to cold storage.
+func (e *Exporter) archiveStatistics(workspace, repoSlug string, pullRequests int) data.RepositoryStatistics {
+	repoDir := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+	stats := data.RepositoryStatistics{SizeBytes: -1, Commits: -1, Branches: -1, PullRequests: pullRequests}
+
+	cmd := exec.Command("git", "rev-list", "--all", "--count")
+	cmd.Dir = repoDir
+	if output, err := cmd.Output(); err == nil {
+		if commits, err := strconv.Atoi(strings.TrimSpace(string(output))); err == nil {
+			stats.Commits = commits
+		}
+	} else {
+		e.logger.Warn("Failed to count commits in exported repository",
+			zap.String("repository", repoSlug),
+			zap.Error(err))
+	}
+
+	cmd = exec.Command("git", "for-each-ref", "--format=%(refname)", "refs/heads/")
+	cmd.Dir = repoDir
+	if output, err := cmd.Output(); err == nil {
+		stats.Branches = len(strings.Fields(string(output)))
+	} else {
+		e.logger.Warn("Failed to count branches in exported repository",
+			zap.String("repository", repoSlug),
+			zap.Error(err))
+	}
+
+	var size int64
+	err := filepath.WalkDir(repoDir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	if err == nil {
+		stats.SizeBytes = size
+	}
+	return stats
+}
+
+// compareStatistics lists the differences between Bitbucket's totals and the
+// archive. Unknown values (-1) on either side are not compared.
+func compareStatistics(bitbucket, archive data.RepositoryStatistics, comparePRs, compareHistory bool) []string {
+	var discrepancies []string
+	if compareHistory && bitbucket.Commits >= 0 && archive.Commits >= 0 && bitbucket.Commits != archive.Commits {
+		discrepancies = append(discrepancies, fmt.Sprintf("commits: Bitbucket reports %d, archive has %d",
+			bitbucket.Commits, archive.Commits))
+	}
+	if bitbucket.Branches >= 0 && archive.Branches >= 0 && bitbucket.Branches != archive.Branches {
+		discrepancies = append(discrepancies, fmt.Sprintf("branches: Bitbucket reports %d, archive has %d",
+			bitbucket.Branches, archive.Branches))
+	}
+	if comparePRs && bitbucket.PullRequests >= 0 && bitbucket.PullRequests != archive.PullRequests {
+		discrepancies = append(discrepancies, fmt.Sprintf("pull requests: Bitbucket reports %d, archive has %d",
+			bitbucket.PullRequests, archive.PullRequests))
+	}
+	if compareHistory && bitbucket.SizeBytes > 0 && archive.SizeBytes >= 0 &&
+		float64(archive.SizeBytes) < float64(bitbucket.SizeBytes)*minArchiveSizeRatio {
+		discrepancies = append(discrepancies, fmt.Sprintf("size: Bitbucket reports %d bytes, archive has %d bytes",
+			bitbucket.SizeBytes, archive.SizeBytes))
+	}
+	return discrepancies
+}
+
+// compareRepositoryStatistics compares the archive with the statistics
+// collected at the start and records the result in the export report.
+func (e *Exporter) compareRepositoryStatistics(workspace string, repoSlugs []string, prsByRepo map[string][]data.PullRequest) {
+	if !e.compareStats {
+		return
+	}
+
+	skippedPRs := make(map[string]int)
+	for _, pr := range e.report.AmbiguousPullRequests {
+		if pr.Action == ambiguousActionSkipped {
+			skippedPRs[pr.Repository]++
+		}
+	}
+
+	comparePRs := !e.openPRsOnly && e.prsFromDate == "" && len(e.prPathPatterns) == 0
+	compareHistory := e.splitSubdir == "" && !e.skipGit && e.gitDepth == 0
+
+	for _, repoSlug := range repoSlugs {
+		bitbucket, ok := e.bitbucketStats[repoSlug]
+		if !ok {
+			continue
+		}
+		comparison := data.StatisticsComparison{
+			Repository: fmt.Sprintf("%s/%s", workspace, repoSlug),
+			Bitbucket:  bitbucket,
+			Archive:    e.archiveStatistics(workspace, repoSlug, len(prsByRepo[repoSlug])),
+		}
+
+		// Pull requests dropped on purpose are not discrepancies.
+		archive := comparison.Archive
+		if skipped := skippedPRs[repoSlug]; skipped > 0 {
+			archive.PullRequests += skipped
+			comparison.Notes = append(comparison.Notes,
+				fmt.Sprintf("%d pull requests with ambiguous branch names were skipped", skipped))
+		}
+		if !comparePRs {
+			comparison.Notes = append(comparison.Notes, "pull request filters are active; pull request counts not compared")
+		}
+		switch {
+		case e.skipGit:
+			comparison.Notes = append(comparison.Notes, "repository was not cloned (--skip-git); commits and size not compared")
+		case e.gitDepth > 0:
+			comparison.Notes = append(comparison.Notes, "history was limited by --git-depth; commits and size not compared")
+		case !compareHistory:
+			comparison.Notes = append(comparison.Notes, "history was rewritten by --subdir-split; commits and size not compared")
+		}
+		comparison.Discrepancies = compareStatistics(bitbucket, archive, comparePRs, compareHistory)
+
+		for _, discrepancy := range comparison.Discrepancies {
+			e.logger.Warn("Archive does not match Bitbucket repository statistics",
+				zap.String("repository", comparison.Repository),
+				zap.String("discrepancy", discrepancy))
+		}
+		if len(comparison.Discrepancies) == 0 {
+			e.logger.Info("Archive matches Bitbucket repository statistics",
+				zap.String("repository", comparison.Repository))
+		}
+		e.report.StatisticsComparisons = append(e.report.StatisticsComparisons, comparison)
+	}
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGetRepositoryStatistics(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/commits"):
+			// The commits endpoint does not always report a size
+			writeResponse(t, w, []byte(`{"values": [{}], "next": "more"}`))
+		case strings.HasSuffix(r.URL.Path, "/refs/branches"):
+			writeResponse(t, w, []byte(`{"size": 3, "values": [{}]}`))
+		case strings.HasSuffix(r.URL.Path, "/pullrequests"):
+			assert.Equal(t, "ALL", r.URL.Query().Get("state"))
+			writeResponse(t, w, []byte(`{"size": 12, "values": [{}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer testServer.Close()
+
+	client := &Client{
+		baseURL:    testServer.URL,
+		httpClient: testServer.Client(),
+		logger:     zap.NewNop(),
+	}
+
+	stats := client.GetRepositoryStatistics("ws", "repo", &data.BitbucketRepository{Size: 2048})
+	assert.Equal(t, data.RepositoryStatistics{SizeBytes: 2048, Commits: -1, Branches: 3, PullRequests: 12}, stats)
+}
+
+func TestCompareStatistics(t *testing.T) {
+	bitbucket := data.RepositoryStatistics{SizeBytes: 1000, Commits: 10, Branches: 2, PullRequests: 5}
+
+	assert.Empty(t, compareStatistics(bitbucket,
+		data.RepositoryStatistics{SizeBytes: 800, Commits: 10, Branches: 2, PullRequests: 5}, true, true))
+
+	discrepancies := compareStatistics(bitbucket,
+		data.RepositoryStatistics{SizeBytes: 100, Commits: 9, Branches: 1, PullRequests: 4}, true, true)
+	require.Len(t, discrepancies, 4)
+	assert.Contains(t, discrepancies[0], "commits: Bitbucket reports 10, archive has 9")
+	assert.Contains(t, discrepancies[1], "branches")
+	assert.Contains(t, discrepancies[2], "pull requests")
+	assert.Contains(t, discrepancies[3], "size")
+
+	// Filtered pull requests, rewritten history and unknown values are not compared
+	assert.Equal(t, []string{"branches: Bitbucket reports 2, archive has 1"},
+		compareStatistics(bitbucket, data.RepositoryStatistics{SizeBytes: 1, Commits: 1, Branches: 1, PullRequests: 0}, false, false))
+	assert.Empty(t, compareStatistics(data.RepositoryStatistics{SizeBytes: -1, Commits: -1, Branches: -1, PullRequests: -1},
+		data.RepositoryStatistics{SizeBytes: 1, Commits: 1, Branches: 1, PullRequests: 1}, true, true))
+}
+
+func TestCompareRepositoryStatistics(t *testing.T) {
+	outputDir := t.TempDir()
+	repoDir := filepath.Join(outputDir, "repositories", "ws", "repo.git")
+	if err := exec.Command("git", "init", "-b", "main", repoDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	runGit(t, repoDir, "commit", "--allow-empty", "-m", "first")
+	runGit(t, repoDir, "commit", "--allow-empty", "-m", "second")
+	runGit(t, repoDir, "branch", "feature")
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.SetCompareStats(true)
+	exporter.bitbucketStats = map[string]data.RepositoryStatistics{
+		"repo": {SizeBytes: -1, Commits: 2, Branches: 3, PullRequests: 3},
+	}
+	exporter.report.AmbiguousPullRequests = []data.AmbiguousPullRequest{
+		{Repository: "repo", ID: 3, Action: ambiguousActionSkipped},
+	}
+	prsByRepo := map[string][]data.PullRequest{"repo": {{URL: "1"}, {URL: "2"}}}
+
+	exporter.compareRepositoryStatistics("ws", []string{"repo"}, prsByRepo)
+
+	require.Len(t, exporter.report.StatisticsComparisons, 1)
+	comparison := exporter.report.StatisticsComparisons[0]
+	assert.Equal(t, "ws/repo", comparison.Repository)
+	assert.Equal(t, 2, comparison.Archive.Commits)
+	assert.Equal(t, 2, comparison.Archive.Branches)
+	assert.Equal(t, 2, comparison.Archive.PullRequests)
+	assert.Greater(t, comparison.Archive.SizeBytes, int64(0))
+	assert.Equal(t, []string{"branches: Bitbucket reports 3, archive has 2"}, comparison.Discrepancies)
+	assert.Len(t, comparison.Notes, 1)
+}
+
+func TestCompareRepositoryStatisticsDisabled(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.collectBitbucketStatistics("ws", "repo", nil)
+	exporter.compareRepositoryStatistics("ws", []string{"repo"}, nil)
+	assert.Nil(t, exporter.bitbucketStats)
+	assert.Empty(t, exporter.report.StatisticsComparisons)
+}
@@ -0,0 +1,469 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
+	"go.uber.org/zap"
+)
+
+const (
+	// syncStateFile and syncCacheDir hold what the sync command fetched in
+	// its last cycle; the cache has one JSON line per pull request.
+	syncStateFile = "sync-state.json"
+	syncCacheDir  = "sync-cache"
+
+	// DefaultSyncInterval is the wait between sync cycles.
+	DefaultSyncInterval = time.Hour
+)
+
+// syncedRecordFiles are the records a sync cycle writes only when there is
+// something to put in them, so those of the last cycle are removed first.
+var syncedRecordFiles = []string{
+	pullRequestsFile,
+	issueCommentsFile,
+	reviewCommentsFile,
+	reviewThreadsFile,
+	reviewsFile,
+}
+
+// SyncOptions configures RunSync.
+type SyncOptions struct {
+	Interval time.Duration // Wait between cycles; 0 uses DefaultSyncInterval
+	Once     bool          // Run one cycle and return
+	Archive  bool          // Run one cycle and archive the export directory
+}
+
+// SetSync updates the mirrors and records already in the output directory
+// instead of exporting from scratch: mirrors are fetched rather than cloned
+// again, and pull requests not updated in Bitbucket since the last sync are
+// taken from the sync cache with their comments and reviews.
+func (e *Exporter) SetSync(enabled bool) {
+	e.sync = enabled
+}
+
+// startSync loads the state of the last sync of the output directory and
+// lets the client reuse the pull requests it fetched.
+func (e *Exporter) startSync(workspace string, repoSlugs []string) error {
+	if !e.sync {
+		return nil
+	}
+
+	state, err := readSyncState(filepath.Join(e.outputDir, syncStateFile))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		e.logger.Info("No earlier sync in the output directory; fetching all pull requests",
+			zap.String("output", e.outputDir))
+	case err != nil:
+		return err
+	case state.Workspace != workspace || !slices.Equal(state.Repositories, repoSlugs):
+		return fmt.Errorf("%s is synced from %s/%s, not %s/%s; sync into another output directory",
+			e.outputDir, state.Workspace, strings.Join(state.Repositories, ","),
+			workspace, strings.Join(repoSlugs, ","))
+	default:
+		for login, name := range state.Contributors {
+			e.client.recordContributor(data.BitbucketPRUser{UUID: login, DisplayName: name})
+		}
+		e.logger.Info("Updating synced export",
+			zap.Int("cycles", state.Cycles),
+			zap.String("last_synced_at", state.LastSyncedAt))
+	}
+	state.Workspace = workspace
+	state.Repositories = repoSlugs
+	e.syncState = state
+	e.syncUpdatedRefs = 0
+
+	cache := &syncCache{dir: filepath.Join(e.outputDir, syncCacheDir), urls: e.client.sourceURLs()}
+	for _, repoSlug := range repoSlugs {
+		if err := cache.load(repoSlug); err != nil && !errors.Is(err, os.ErrNotExist) {
+			e.logger.Warn("Failed to read the sync cache; fetching all pull requests again",
+				zap.String("repository", repoSlug),
+				zap.Error(err))
+		}
+	}
+	e.client.syncCache = cache
+
+	for _, name := range syncedRecordFiles {
+		if err := e.removeRecordFiles(name, 1); err != nil {
+			return fmt.Errorf("failed to remove the records of the last sync: %w", err)
+		}
+	}
+	return nil
+}
+
+func readSyncState(path string) (data.SyncState, error) {
+	var state data.SyncState
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(content, &state); err != nil {
+		return state, fmt.Errorf("%w %s: %w", ErrCorruptExportFile, syncStateFile, err)
+	}
+	return state, nil
+}
+
+// saveSync writes the sync cache and state once a cycle has written its
+// records.
+func (e *Exporter) saveSync() error {
+	if !e.sync {
+		return nil
+	}
+	cache := e.client.syncCache
+	if err := cache.save(); err != nil {
+		return err
+	}
+
+	e.syncState.Cycles++
+	e.syncState.LastSyncedAt = e.client.now().UTC().Format(time.RFC3339)
+	e.syncState.UpdatedRefs = e.syncUpdatedRefs
+	e.syncState.ChangedPullRequests = cache.changed
+	e.syncState.Contributors = e.client.contributorNames()
+	if err := e.writeJSONFile(syncStateFile, e.syncState); err != nil {
+		return err
+	}
+	e.logger.Info("Sync cycle recorded",
+		zap.Int("cycle", e.syncState.Cycles),
+		zap.Int("updated_refs", e.syncUpdatedRefs),
+		zap.Int("changed_pull_requests", cache.changed),
+		zap.Int("unchanged_pull_requests", cache.reused))
+	return nil
+}
+
+// syncMirror fetches the branches and tags of a mirror cloned by an earlier
+// cycle and repeats the checks done after a clone. It returns false when
+// there is no mirror to update and the repository must be cloned.
+func (e *Exporter) syncMirror(workspace, repoSlug string) (bool, error) {
+	if !e.sync {
+		return false, nil
+	}
+	repoDir := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+	head, err := os.ReadFile(filepath.Join(repoDir, "HEAD"))
+	if err != nil {
+		return false, nil
+	}
+	if e.client.keepAmbiguousPRs {
+		// The renamed branches would be fetched again next to their copies.
+		e.logger.Info("Cloning the mirror again: --keep-ambiguous-prs renamed its branches",
+			zap.String("repository", repoSlug))
+		return false, nil
+	}
+
+	e.recordCloneTime(repoSlug)
+	before, err := mirrorRefs(repoDir)
+	if err != nil {
+		return false, err
+	}
+	args := append([]string{"--prune", "--no-tags", "origin"}, topUpRefspecs...)
+	if err := e.fetchOrigin(repoDir, args...); err != nil {
+		if deadlineErr := e.checkDeadline(stageGitClone); deadlineErr != nil {
+			return false, deadlineErr
+		}
+		return false, fmt.Errorf("failed to fetch %s: %w", repoSlug, err)
+	}
+	after, err := mirrorRefs(repoDir)
+	if err != nil {
+		return false, err
+	}
+
+	updated := changedRefs(before, after)
+	e.syncUpdatedRefs += updated
+	if updated > 0 {
+		if err := e.pruneRefs(workspace, repoSlug, repoDir); err != nil {
+			return false, err
+		}
+		if err := e.validateGitReferences(repoDir); err != nil {
+			return false, err
+		}
+		if err := e.splitPacks(workspace, repoSlug, repoDir); err != nil {
+			return false, err
+		}
+	}
+	if err := e.checkImportSafety(workspace, repoSlug, repoDir); err != nil {
+		return false, err
+	}
+
+	cloneURL := fmt.Sprintf("https://bitbucket.org/%s/%s.git", workspace, repoSlug)
+	if err := e.fetchLFSObjects(repoSlug, repoDir, cloneURL); err != nil {
+		return false, err
+	}
+	defaultBranch := strings.TrimPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/")
+	e.updateRepositoryField(repoSlug, "default_branch", defaultBranch)
+	e.updateRepositoryField(repoSlug, "git_url", e.client.formatURL("git", workspace, repoSlug))
+	if err := e.createRepositoryInfoFiles(workspace, repoSlug); err != nil {
+		e.logger.Warn("Failed to create repository info files",
+			zap.String("repository", repoSlug),
+			zap.Error(err))
+	}
+	if err := e.exportWiki(workspace, repoSlug, cloneURL); err != nil {
+		return false, err
+	}
+
+	e.logger.Info("Mirror updated",
+		zap.String("repository", repoSlug),
+		zap.Int("updated_refs", updated))
+	return true, nil
+}
+
+// syncCache holds the pull requests of the last sync cycle with their
+// comments and reviews, by repository and pull request ID. A pull request
+// whose updated_on is unchanged is reused as is; Bitbucket updates it on
+// every change, comment and review. A nil syncCache reuses nothing.
+type syncCache struct {
+	dir      string
+	urls     format.URLs // Source URL policy the pull request URLs were generated with
+	mu       sync.Mutex
+	previous map[string]map[int]syncedPullRequest
+	current  map[string]map[int]*syncedPullRequest
+	changed  int // Pull requests converted again this cycle
+	reused   int
+}
+
+type syncedPullRequest struct {
+	ID             int                      `json:"id"`
+	UpdatedOn      string                   `json:"updated_on"`
+	PullRequest    data.PullRequest         `json:"pull_request"`
+	Comments       *pullRequestComments     `json:"comments,omitempty"`
+	Reviews        []map[string]interface{} `json:"reviews,omitempty"`
+	ReviewsFetched bool                     `json:"reviews_fetched,omitempty"`
+}
+
+func (s *syncCache) path(repoSlug string) string {
+	return filepath.Join(s.dir, repoSlug+".ndjson")
+}
+
+// load reads the pull requests a repository had in the last cycle.
+func (s *syncCache) load(repoSlug string) error {
+	entries := make(map[int]syncedPullRequest)
+	err := readCacheLines(s.path(repoSlug), func(line []byte) error {
+		var entry syncedPullRequest
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return err
+		}
+		entries[entry.ID] = entry
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if s.previous == nil {
+		s.previous = make(map[string]map[int]syncedPullRequest)
+	}
+	s.previous[repoSlug] = entries
+	return nil
+}
+
+// pullRequest returns the converted pull request of the last cycle when it
+// has not been updated since.
+func (s *syncCache) pullRequest(repoSlug string, pr data.BitbucketPR) (data.PullRequest, bool) {
+	if s == nil {
+		return data.PullRequest{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.previous[repoSlug][pr.ID]
+	if !ok || pr.UpdatedOn == "" || entry.UpdatedOn != pr.UpdatedOn {
+		return data.PullRequest{}, false
+	}
+	s.entries(repoSlug)[pr.ID] = &entry
+	s.reused++
+	return entry.PullRequest, true
+}
+
+// recordPullRequest caches a pull request converted this cycle. Its
+// comments and reviews are fetched again.
+func (s *syncCache) recordPullRequest(repoSlug string, pr data.BitbucketPR, converted data.PullRequest) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries(repoSlug)[pr.ID] = &syncedPullRequest{ID: pr.ID, UpdatedOn: pr.UpdatedOn, PullRequest: converted}
+	s.changed++
+}
+
+// comments returns the cached comments of an unchanged pull request.
+func (s *syncCache) comments(repoSlug string, prID int) (pullRequestComments, bool) {
+	if s == nil {
+		return pullRequestComments{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.current[repoSlug][prID]
+	if !ok || entry.Comments == nil {
+		return pullRequestComments{}, false
+	}
+	return *entry.Comments, true
+}
+
+// recordComments caches the comments fetched for a pull request.
+func (s *syncCache) recordComments(repoSlug string, prID int, regular []data.IssueComment,
+	review []data.PullRequestReviewComment) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.current[repoSlug][prID]; ok {
+		entry.Comments = &pullRequestComments{PullRequest: prID, IssueComments: regular, ReviewComments: review}
+	}
+}
+
+// reviews returns the cached activity reviews of an unchanged pull request.
+func (s *syncCache) reviews(repoSlug string, pr data.PullRequest) ([]map[string]interface{}, bool) {
+	if s == nil {
+		return nil, false
+	}
+	prID, err := strconv.Atoi(extractPRNumber(s.urls, pr.URL))
+	if err != nil {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.current[repoSlug][prID]
+	if !ok || !entry.ReviewsFetched {
+		return nil, false
+	}
+	return entry.Reviews, true
+}
+
+// recordReviews caches the activity reviews fetched for a pull request.
+func (s *syncCache) recordReviews(repoSlug string, pr data.PullRequest, reviews []map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	prID, err := strconv.Atoi(extractPRNumber(s.urls, pr.URL))
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.current[repoSlug][prID]; ok {
+		entry.Reviews = reviews
+		entry.ReviewsFetched = true
+	}
+}
+
+// entries returns the pull requests of a repository cached this cycle.
+// Callers hold s.mu.
+func (s *syncCache) entries(repoSlug string) map[int]*syncedPullRequest {
+	if s.current == nil {
+		s.current = make(map[string]map[int]*syncedPullRequest)
+	}
+	if s.current[repoSlug] == nil {
+		s.current[repoSlug] = make(map[int]*syncedPullRequest)
+	}
+	return s.current[repoSlug]
+}
+
+// save replaces the cache of every repository whose pull requests were
+// fetched this cycle, dropping pull requests that are gone.
+func (s *syncCache) save() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create sync cache directory: %w", err)
+	}
+	for repoSlug, entries := range s.current {
+		ids := make([]int, 0, len(entries))
+		for id := range entries {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		err := writeFileAtomic(s.path(repoSlug), 0644, func(w io.Writer) error {
+			encoder := json.NewEncoder(w)
+			for _, id := range ids {
+				if err := encoder.Encode(entries[id]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write sync cache of %s: %w", repoSlug, err)
+		}
+	}
+	return nil
+}
+
+// RunSync keeps the export directory of cmdFlags up to date: every interval
+// it fetches the mirrors and the pull requests changed since the last cycle
+// and rewrites the records, without archiving. A failed cycle is retried at
+// the next interval. RunSync returns nil once ctx is cancelled.
+//
+// With opts.Once or opts.Archive it runs a single cycle and returns its
+// error; with opts.Archive it also archives the directory and returns the
+// archive path.
+func RunSync(ctx context.Context, cmdFlags *data.CmdExportFlags, opts SyncOptions, logger *zap.Logger) (string, error) {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultSyncInterval
+	}
+	for {
+		started := time.Now()
+		output, err := syncExport(ctx, cmdFlags, opts.Archive, logger)
+		if opts.Once || opts.Archive {
+			return output, err
+		}
+		if ctx.Err() != nil {
+			logger.Info("Sync stopped", zap.String("output", cmdFlags.OutputDir))
+			return "", nil
+		}
+		if err != nil {
+			logger.Error("Sync cycle failed; retrying at the next interval", zap.Error(err))
+		} else {
+			logger.Info("Sync cycle complete",
+				zap.Duration("duration", time.Since(started).Round(time.Second)),
+				zap.Time("next_sync_at", time.Now().Add(interval)))
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Sync stopped", zap.String("output", cmdFlags.OutputDir))
+			return "", nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// syncExport runs one sync cycle and, when archive is set, archives the
+// export directory.
+func syncExport(ctx context.Context, cmdFlags *data.CmdExportFlags, archive bool, logger *zap.Logger) (string, error) {
+	client, err := newExportClient(ctx, cmdFlags, logger)
+	if err != nil {
+		return "", err
+	}
+	exporter := NewExporter(client, cmdFlags.OutputDir, logger, cmdFlags.OpenPRsOnly, cmdFlags.PRsFromDate)
+	if cmdFlags.TempDir != "" {
+		exporter.SetTempDir(cmdFlags.TempDir)
+	}
+	if err := exporter.ApplyExportFlags(cmdFlags); err != nil {
+		return "", err
+	}
+	exporter.SetSync(true)
+	exporter.deferArchive = !archive
+
+	if err := exporter.ExportRepositories(cmdFlags.Workspace, RepositorySlugs(cmdFlags)); err != nil {
+		return "", err
+	}
+	if !archive {
+		exporter.finishReport(nil)
+	}
+	return exporter.GetOutputPath(), nil
+}
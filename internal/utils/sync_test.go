@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSyncMirror(t *testing.T) {
+	exporter, _, head := consistencyFixture(t, ConsistencyBestEffort)
+	exporter.SetSync(true)
+	mirrorPath := filepath.Join(exporter.outputDir, "repositories", "workspace", "repo.git")
+
+	synced, err := exporter.syncMirror("workspace", "repo")
+	require.NoError(t, err)
+	assert.True(t, synced)
+
+	refs, err := mirrorRefs(mirrorPath)
+	require.NoError(t, err)
+	assert.Equal(t, head, refs["refs/heads/feature"])
+	assert.Equal(t, 1, exporter.syncUpdatedRefs)
+}
+
+func TestSyncMirrorWithoutMirror(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.SetSync(true)
+
+	synced, err := exporter.syncMirror("workspace", "repo")
+	require.NoError(t, err)
+	assert.False(t, synced, "a repository without a mirror is cloned")
+}
+
+func TestSyncMirrorOnlyWhenSyncing(t *testing.T) {
+	exporter, _, _ := consistencyFixture(t, ConsistencyBestEffort)
+
+	synced, err := exporter.syncMirror("workspace", "repo")
+	require.NoError(t, err)
+	assert.False(t, synced)
+}
+
+func TestSyncCacheReusesUnchangedPullRequests(t *testing.T) {
+	cache := &syncCache{dir: t.TempDir()}
+	cache.previous = map[string]map[int]syncedPullRequest{"repo": {
+		1: {ID: 1, UpdatedOn: "2024-05-01T00:00:00+00:00",
+			PullRequest: data.PullRequest{URL: "https://bitbucket.org/workspace/repo/pull/1", Title: "cached"},
+			Comments:    &pullRequestComments{PullRequest: 1, IssueComments: []data.IssueComment{{Body: "cached"}}},
+			Reviews:     []map[string]interface{}{{"state": "approved"}}, ReviewsFetched: true},
+		2: {ID: 2, UpdatedOn: "2024-05-01T00:00:00+00:00"},
+		3: {ID: 3, UpdatedOn: "2024-05-01T00:00:00+00:00"},
+	}}
+
+	cached, ok := cache.pullRequest("repo", data.BitbucketPR{ID: 1, UpdatedOn: "2024-05-01T00:00:00+00:00"})
+	require.True(t, ok)
+	assert.Equal(t, "cached", cached.Title)
+	_, ok = cache.pullRequest("repo", data.BitbucketPR{ID: 2, UpdatedOn: "2024-06-01T00:00:00+00:00"})
+	assert.False(t, ok, "an updated pull request is converted again")
+
+	changed := data.PullRequest{URL: "https://bitbucket.org/workspace/repo/pull/2", Title: "changed"}
+	cache.recordPullRequest("repo", data.BitbucketPR{ID: 2, UpdatedOn: "2024-06-01T00:00:00+00:00"}, changed)
+
+	comments, ok := cache.comments("repo", 1)
+	require.True(t, ok)
+	assert.Equal(t, "cached", comments.IssueComments[0].Body)
+	reviews, ok := cache.reviews("repo", cached)
+	require.True(t, ok)
+	assert.Equal(t, "approved", reviews[0]["state"])
+	_, ok = cache.comments("repo", 2)
+	assert.False(t, ok, "the comments of an updated pull request are fetched again")
+	_, ok = cache.reviews("repo", changed)
+	assert.False(t, ok)
+
+	cache.recordComments("repo", 2, []data.IssueComment{{Body: "fetched"}}, nil)
+	cache.recordReviews("repo", changed, []map[string]interface{}{})
+	assert.Equal(t, 1, cache.changed)
+	assert.Equal(t, 1, cache.reused)
+	require.NoError(t, cache.save())
+
+	reloaded := &syncCache{dir: cache.dir}
+	require.NoError(t, reloaded.load("repo"))
+	require.Len(t, reloaded.previous["repo"], 2, "pull requests gone from Bitbucket are dropped")
+	assert.Equal(t, "changed", reloaded.previous["repo"][2].PullRequest.Title)
+	assert.Equal(t, "fetched", reloaded.previous["repo"][2].Comments.IssueComments[0].Body)
+	assert.True(t, reloaded.previous["repo"][2].ReviewsFetched)
+}
+
+func TestSyncCacheNil(t *testing.T) {
+	var cache *syncCache
+	_, ok := cache.pullRequest("repo", data.BitbucketPR{ID: 1})
+	assert.False(t, ok)
+	_, ok = cache.comments("repo", 1)
+	assert.False(t, ok)
+	cache.recordPullRequest("repo", data.BitbucketPR{ID: 1}, data.PullRequest{})
+	cache.recordComments("repo", 1, nil, nil)
+	assert.NoError(t, cache.save())
+}
+
+func TestGetPullRequestsReusesSyncedPullRequests(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(t, w, []byte(`{"values": [
+			{"id": 1, "title": "Bitbucket title", "state": "OPEN", "updated_on": "2024-05-01T00:00:00+00:00",
+				"created_on": "2024-04-01T00:00:00+00:00",
+				"source": {"branch": {"name": "feature"}}, "destination": {"branch": {"name": "main"}}},
+			{"id": 2, "title": "Updated title", "state": "OPEN", "updated_on": "2024-06-01T00:00:00+00:00",
+				"created_on": "2024-04-01T00:00:00+00:00",
+				"source": {"branch": {"name": "feature"}}, "destination": {"branch": {"name": "main"}}}
+		], "next": null}`))
+	}))
+	defer testServer.Close()
+
+	client := resumeClient(testServer.URL, nil)
+	client.skipCommitLookup = true
+	client.syncCache = &syncCache{dir: t.TempDir(), previous: map[string]map[int]syncedPullRequest{"repo": {
+		1: {ID: 1, UpdatedOn: "2024-05-01T00:00:00+00:00", PullRequest: data.PullRequest{Title: "Synced title"}},
+		2: {ID: 2, UpdatedOn: "2024-05-01T00:00:00+00:00", PullRequest: data.PullRequest{Title: "Stale title"}},
+	}}}
+
+	prs, err := client.GetPullRequests("workspace", "repo", false, "")
+	require.NoError(t, err)
+	require.Len(t, prs, 2)
+	assert.Equal(t, "Synced title", prs[0].Title, "unchanged pull requests come from the sync cache")
+	assert.Equal(t, "Updated title", prs[1].Title)
+	assert.Equal(t, 1, client.syncCache.changed)
+}
+
+func TestStartSync(t *testing.T) {
+	outputDir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.SetSync(true)
+	require.NoError(t, exporter.writeJSONFile(syncStateFile, data.SyncState{
+		Workspace: "workspace", Repositories: []string{"repo"}, Cycles: 2,
+		Contributors: map[string]string{"alice": "Alice"},
+	}))
+	require.NoError(t, os.WriteFile(filepath.Join(outputDir, "pull_requests_000001.json"), []byte("[]"), 0644))
+
+	require.NoError(t, exporter.startSync("workspace", []string{"repo"}))
+
+	assert.NoFileExists(t, filepath.Join(outputDir, "pull_requests_000001.json"),
+		"records of the last cycle are written again")
+	assert.Equal(t, 2, exporter.syncState.Cycles)
+	assert.Equal(t, map[string]string{"alice": "Alice"}, exporter.client.contributorNames())
+	assert.NotNil(t, exporter.client.syncCache)
+}
+
+func TestStartSyncRejectsOtherRepository(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.SetSync(true)
+	require.NoError(t, exporter.writeJSONFile(syncStateFile, data.SyncState{
+		Workspace: "workspace", Repositories: []string{"other"},
+	}))
+
+	err := exporter.startSync("workspace", []string{"repo"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is synced from workspace/other")
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"archive/tar"
+	"fmt"
+	"strings"
+)
+
+const (
+	TarFormatUSTAR = "ustar"
+	TarFormatGNU   = "gnu"
+	TarFormatPAX   = "pax"
+)
+
+var tarFormats = []string{TarFormatUSTAR, TarFormatGNU, TarFormatPAX}
+
+// ValidateTarFormat checks a --tar-format value. An empty value selects
+// ustar.
+func ValidateTarFormat(format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, known := range tarFormats {
+		if format == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value for --tar-format: %q (supported: %s)",
+		format, strings.Join(tarFormats, ", "))
+}
+
+// tarFormat returns the header format of archive entries:
+//   - ustar: plain ustar headers, with GNU long-name entries only for paths
+//     over 100 characters (see --long-paths)
+//   - gnu: GNU headers for every entry
+//   - pax: PAX headers for every entry, which also carry UTF-8 names and
+//     sizes over 8 GiB in a standard form
+func (e *Exporter) tarFormat() tar.Format {
+	if e.flags == nil {
+		return tar.FormatUSTAR
+	}
+	switch e.flags.TarFormat {
+	case TarFormatGNU:
+		return tar.FormatGNU
+	case TarFormatPAX:
+		return tar.FormatPAX
+	default:
+		return tar.FormatUSTAR
+	}
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidateTarFormat(t *testing.T) {
+	for _, format := range []string{"", TarFormatUSTAR, TarFormatGNU, TarFormatPAX} {
+		assert.NoError(t, ValidateTarFormat(format), format)
+	}
+	err := ValidateTarFormat("v7")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--tar-format")
+}
+
+func TestValidateExportFlagsLongPathsRequiresUSTAR(t *testing.T) {
+	flags := &data.CmdExportFlags{BitbucketAccessToken: "token", TarFormat: TarFormatPAX, LongPaths: LongPathsTruncate}
+	err := ValidateExportFlags(flags)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--tar-format ustar")
+
+	flags.LongPaths = LongPathsGNU
+	assert.NoError(t, ValidateExportFlags(flags))
+}
+
+func archiveHeaders(t *testing.T, sourceDir, format string) []*tar.Header {
+	t.Helper()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, sourceDir, zap.NewNop(), false, "")
+	exporter.flags = &data.CmdExportFlags{TarFormat: format}
+	exporter.archiveWorkers = 1
+
+	var buf bytes.Buffer
+	require.NoError(t, exporter.archiveDirectory(sourceDir, &buf))
+	require.NoError(t, tar.NewWriter(&buf).Close())
+
+	var headers []*tar.Header
+	reader := tar.NewReader(&buf)
+	for {
+		header, err := reader.Next()
+		if err != nil {
+			break
+		}
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+func TestArchiveTarFormatPAXKeepsUTF8Names(t *testing.T) {
+	sourceDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "schema.json"), []byte(`{}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "résumé-日本.json"), []byte(`{}`), 0644))
+
+	headers := archiveHeaders(t, sourceDir, TarFormatPAX)
+	require.Len(t, headers, 2)
+	names := []string{headers[0].Name, headers[1].Name}
+	assert.Contains(t, names, "résumé-日本.json")
+	for _, header := range headers {
+		if header.Name == "résumé-日本.json" {
+			assert.Equal(t, tar.FormatPAX, header.Format)
+		}
+	}
+}
+
+func TestArchiveTarFormatGNU(t *testing.T) {
+	sourceDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "schema.json"), []byte(`{}`), 0644))
+
+	headers := archiveHeaders(t, sourceDir, TarFormatGNU)
+	require.Len(t, headers, 1)
+	assert.Equal(t, tar.FormatGNU, headers[0].Format)
+
+	headers = archiveHeaders(t, sourceDir, "")
+	require.Len(t, headers, 1)
+	assert.Equal(t, tar.FormatUSTAR, headers[0].Format)
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	// throttlingWaitShareHigh is the share of a run spent waiting on rate
+	// limits above which a quota increase is worth requesting.
+	throttlingWaitShareHigh = 0.10
+	// niceDelayShareHigh is the share of a run spent in nice-mode delays
+	// above which dropping --nice is suggested when no limit was hit.
+	niceDelayShareHigh = 0.25
+)
+
+// throttlingCounters accumulates waits and retries of the API client.
+type throttlingCounters struct {
+	requests         int
+	retries          int
+	rateLimitHits    int
+	lowQuotaWarnings int
+	rateLimitWait    time.Duration
+	niceDelay        time.Duration
+}
+
+func (c *Client) resetThrottling() {
+	c.throttling = throttlingCounters{}
+	c.tokenRefreshes = 0
+}
+
+// throttlingStats summarises the client's waits over a run of the given
+// duration. It returns nil when no API request was made.
+func (c *Client) throttlingStats(runDuration time.Duration) *data.ThrottlingStats {
+	counters := c.throttling
+	if counters.requests == 0 {
+		return nil
+	}
+
+	totalWait := counters.rateLimitWait + counters.niceDelay
+	stats := &data.ThrottlingStats{
+		Requests:             counters.requests,
+		Retries:              counters.retries,
+		RateLimitHits:        counters.rateLimitHits,
+		LowQuotaWarnings:     counters.lowQuotaWarnings,
+		TokenRefreshes:       c.tokenRefreshes,
+		RateLimitWaitSeconds: roundSeconds(counters.rateLimitWait),
+		NiceDelaySeconds:     roundSeconds(counters.niceDelay),
+		TotalWaitSeconds:     roundSeconds(totalWait),
+	}
+	if runDuration > 0 {
+		stats.WaitShare = math.Round(float64(totalWait)/float64(runDuration)*1000) / 1000
+	}
+	stats.Advice = throttlingAdvice(stats, counters, runDuration, c.niceMode, c.concurrency)
+	return stats
+}
+
+// throttlingAdvice turns the counters into concrete suggestions for the
+// next run. Besides --concurrency, "concurrency" means other exports or
+// integrations sharing the workspace's quota.
+func throttlingAdvice(stats *data.ThrottlingStats, counters throttlingCounters, runDuration time.Duration, niceMode bool, workers int) []string {
+	var advice []string
+	rateLimitShare := 0.0
+	if runDuration > 0 {
+		rateLimitShare = float64(counters.rateLimitWait) / float64(runDuration)
+	}
+
+	if counters.rateLimitHits > 0 {
+		if rateLimitShare >= throttlingWaitShareHigh {
+			advice = append(advice, fmt.Sprintf(
+				"Rate limits paused the export for %s (%.0f%% of the run); request an API quota increase for the workspace or split the export into smaller waves.",
+				counters.rateLimitWait.Round(time.Second), rateLimitShare*100))
+		}
+		if workers > 1 {
+			advice = append(advice, fmt.Sprintf(
+				"Lower concurrency: reduce --concurrency from %d, and avoid running several exports or other integrations against the workspace at the same time.",
+				workers))
+		} else {
+			advice = append(advice,
+				"Lower concurrency: avoid running several exports or other integrations against the workspace at the same time.")
+		}
+		if !niceMode {
+			advice = append(advice,
+				"Use --nice to space out requests so the shared quota is not exhausted.")
+		}
+	} else if counters.lowQuotaWarnings > 0 {
+		advice = append(advice,
+			"The API quota ran low without being exhausted; use --nice or schedule the export outside busy hours to leave room for other integrations.")
+	}
+
+	if niceMode && counters.rateLimitHits == 0 && runDuration > 0 &&
+		float64(counters.niceDelay)/float64(runDuration) >= niceDelayShareHigh {
+		advice = append(advice, fmt.Sprintf(
+			"No rate limits were hit while --nice delays took %.0f%% of the run; drop --nice to finish faster if the quota allows.",
+			float64(counters.niceDelay)/float64(runDuration)*100))
+	}
+	if stats.TokenRefreshes > 1 {
+		advice = append(advice, fmt.Sprintf(
+			"The token was refreshed %d times; mint tokens with a longer lifetime to avoid the extra round trips.",
+			stats.TokenRefreshes))
+	}
+	return advice
+}
+
+func roundSeconds(d time.Duration) float64 {
+	return math.Round(d.Seconds()*100) / 100
+}
+
+func (e *Exporter) logThrottlingStats(stats *data.ThrottlingStats) {
+	if stats == nil || (stats.RateLimitHits == 0 && stats.TotalWaitSeconds == 0) {
+		return
+	}
+	e.logger.Info("API throttling",
+		zap.Int("requests", stats.Requests),
+		zap.Int("rate_limit_hits", stats.RateLimitHits),
+		zap.Float64("wait_seconds", stats.TotalWaitSeconds),
+		zap.Float64("wait_share", stats.WaitShare))
+	for _, advice := range stats.Advice {
+		e.logger.Info("Throttling advice", zap.String("advice", advice))
+	}
+}
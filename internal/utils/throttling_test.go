@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestThrottlingStatsRateLimited(t *testing.T) {
+	client := &Client{logger: zap.NewNop()}
+	client.throttling = throttlingCounters{
+		requests:      120,
+		retries:       4,
+		rateLimitHits: 4,
+		rateLimitWait: 30 * time.Second,
+	}
+
+	stats := client.throttlingStats(100 * time.Second)
+
+	require.NotNil(t, stats)
+	assert.Equal(t, 120, stats.Requests)
+	assert.Equal(t, 4, stats.RateLimitHits)
+	assert.Equal(t, 30.0, stats.RateLimitWaitSeconds)
+	assert.Equal(t, 0.3, stats.WaitShare)
+	require.Len(t, stats.Advice, 3)
+	assert.Contains(t, stats.Advice[0], "request an API quota increase")
+	assert.Contains(t, stats.Advice[1], "Lower concurrency")
+	assert.Contains(t, stats.Advice[2], "--nice")
+}
+
+func TestThrottlingStatsAdvice(t *testing.T) {
+	t.Run("No requests", func(t *testing.T) {
+		client := &Client{logger: zap.NewNop()}
+		assert.Nil(t, client.throttlingStats(time.Minute))
+	})
+
+	t.Run("Unthrottled run", func(t *testing.T) {
+		client := &Client{logger: zap.NewNop(), throttling: throttlingCounters{requests: 10}}
+		stats := client.throttlingStats(time.Minute)
+		require.NotNil(t, stats)
+		assert.Empty(t, stats.Advice)
+	})
+
+	t.Run("Nice mode without rate limits", func(t *testing.T) {
+		client := &Client{logger: zap.NewNop(), niceMode: true,
+			throttling: throttlingCounters{requests: 100, niceDelay: 50 * time.Second}}
+		stats := client.throttlingStats(100 * time.Second)
+		require.Len(t, stats.Advice, 1)
+		assert.Contains(t, stats.Advice[0], "drop --nice")
+	})
+
+	t.Run("Low quota", func(t *testing.T) {
+		client := &Client{logger: zap.NewNop(), throttling: throttlingCounters{requests: 10, lowQuotaWarnings: 2}}
+		stats := client.throttlingStats(time.Minute)
+		require.Len(t, stats.Advice, 1)
+		assert.Contains(t, stats.Advice[0], "ran low")
+	})
+}
+
+func TestThrottlingCountersFromRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "5")
+		w.Header().Set("X-RateLimit-Limit", "1000")
+		writeResponse(t, w, []byte(`{}`))
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop(),
+		requestDelay: 20 * time.Millisecond}
+	for i := 0; i < 3; i++ {
+		require.NoError(t, client.makeRequest("GET", "repositories/ws/repo", &struct{}{}))
+	}
+
+	assert.Equal(t, 3, client.throttling.requests)
+	assert.Equal(t, 3, client.throttling.lowQuotaWarnings)
+	assert.Positive(t, client.throttling.niceDelay)
+}
+
+func TestFinishReportIncludesThrottling(t *testing.T) {
+	outputDir := t.TempDir()
+	client := &Client{logger: zap.NewNop()}
+	exporter := NewExporter(client, outputDir, zap.NewNop(), false, "")
+	exporter.beginReport("workspace", []string{"repo"})
+	client.throttling.requests = 7
+	client.throttling.rateLimitHits = 1
+	client.throttling.rateLimitWait = time.Second
+	exporter.finishReport(nil)
+
+	var report data.ExportReport
+	readReportFile(t, filepath.Join(outputDir, exportReportFile), &report)
+	require.NotNil(t, report.Throttling)
+	assert.Equal(t, 7, report.Throttling.Requests)
+	assert.Equal(t, 1, report.Throttling.RateLimitHits)
+	assert.NotEmpty(t, report.Throttling.Advice)
+
+	exporter.beginReport("workspace", []string{"repo"})
+	assert.Zero(t, client.throttling.requests, "counters restart with each report")
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// tokenRefreshTimeout bounds a single run of the token refresh command.
+const tokenRefreshTimeout = 2 * time.Minute
+
+// SetTokenRefreshCommand sets a shell command that prints a new Bitbucket
+// token on standard output. When a request is rejected with 401, the command
+// is run once, the token of the active authentication method is replaced
+// with its output and the request is retried. An empty command disables
+// refreshing.
+func (c *Client) SetTokenRefreshCommand(command string) {
+	c.tokenRefreshCmd = strings.TrimSpace(command)
+}
+
+func tokenRefreshCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// refreshToken runs the token refresh command and swaps the new token into
+// the credential the client authenticates with. Replaced tokens are kept so
+// that cloned repositories are still checked for them. seen is the
+// tokenGeneration the rejected request was sent with: when concurrent
+// workers are rejected together, only the first runs the command and the
+// others retry with the token it obtained.
+func (c *Client) refreshToken(ctx context.Context, seen int) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	if c.tokenGeneration() != seen {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tokenRefreshTimeout)
+	defer cancel()
+
+	c.logger.Info("Received 401 from Bitbucket; refreshing token",
+		zap.String("authMethod", getAuthMethodDescription(c)))
+
+	var stderr bytes.Buffer
+	cmd := tokenRefreshCommand(ctx, c.tokenRefreshCmd)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("token refresh command failed: %s: %w", strings.TrimSpace(stderr.String()), err)
+	}
+	token := strings.TrimSpace(string(output))
+	if token == "" {
+		return fmt.Errorf("token refresh command printed no token")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch c.primaryAuthMethod() {
+	case AuthMethodAccessToken:
+		c.retiredSecrets = append(c.retiredSecrets, c.accessToken)
+		c.accessToken = token
+	case AuthMethodAPIToken:
+		c.retiredSecrets = append(c.retiredSecrets, c.apiToken)
+		c.apiToken = token
+	case AuthMethodAppPassword:
+		c.retiredSecrets = append(c.retiredSecrets, c.appPass)
+		c.appPass = token
+	default:
+		c.accessToken = token
+	}
+	c.tokenRefreshes++
+	c.logger.Debug("Token refreshed", zap.Int("refreshes", c.tokenRefreshes))
+	return nil
+}
+
+// tokenGeneration returns how often the token has been refreshed.
+func (c *Client) tokenGeneration() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokenRefreshes
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMakeRequestRefreshesTokenOn401(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available for testing")
+	}
+
+	var authHeaders []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer fresh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeResponse(t, w, []byte(`{"slug": "repo"}`))
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop(), accessToken: "expired-token"}
+	client.SetTokenRefreshCommand("echo fresh-token")
+
+	var repo struct {
+		Slug string `json:"slug"`
+	}
+	require.NoError(t, client.makeRequest("GET", "repositories/ws/repo", &repo))
+	assert.Equal(t, "repo", repo.Slug)
+	assert.Equal(t, []string{"Bearer expired-token", "Bearer fresh-token"}, authHeaders)
+	assert.Equal(t, 1, client.tokenRefreshes)
+	assert.Contains(t, client.gitSecrets(), "expired-token", "replaced tokens are still scrubbed from clones")
+}
+
+func TestMakeRequestRefreshesTokenOnlyOnce(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available for testing")
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop(), apiToken: "old-api-token"}
+	client.SetTokenRefreshCommand("echo still-rejected")
+
+	err := client.makeRequest("GET", "repositories/ws/repo", &struct{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, "still-rejected", client.apiToken)
+}
+
+func TestRefreshTokenFailures(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available for testing")
+	}
+
+	client := &Client{logger: zap.NewNop(), accessToken: "token"}
+	client.SetTokenRefreshCommand("echo boom >&2; exit 3")
+	err := client.refreshToken(t.Context(), 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+
+	client.SetTokenRefreshCommand("true")
+	err = client.refreshToken(t.Context(), 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "printed no token")
+	assert.Equal(t, "token", client.accessToken)
+}
+
+func TestMakeRequestWithoutRefreshCommand(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop(), accessToken: "token"}
+	require.Error(t, client.makeRequest("GET", "repositories/ws/repo", &struct{}{}))
+	assert.Zero(t, client.tokenRefreshes)
+}
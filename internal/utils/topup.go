@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// topUpRefspecs update branches and tags only, so refs removed from the
+// mirror after the clone (pull request refs, pruned prefixes) stay removed.
+var topUpRefspecs = []string{
+	"+refs/heads/*:refs/heads/*",
+	"+refs/tags/*:refs/tags/*",
+}
+
+// SetTopUpFetch fetches every mirror again once pull requests and comments
+// have been exported, so commits pushed during the metadata export are
+// included in the archive.
+func (e *Exporter) SetTopUpFetch(enabled bool) {
+	e.topUpFetch = enabled
+}
+
+// fetchOrigin runs git fetch with the given arguments against the origin the
+// mirror was cloned from, using the client's credentials.
+func (e *Exporter) fetchOrigin(repoPath string, args ...string) error {
+	cmd := exec.Command("git", "remote", "get-url", "origin")
+	cmd.Dir = repoPath
+	remoteURL, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("failed to read the mirror's origin: %w", err)
+	}
+	credentialEnv, removeCredentials, err := e.client.gitCredentialHelper(strings.TrimSpace(string(remoteURL)))
+	if err != nil {
+		return err
+	}
+	defer removeCredentials()
+
+	ctx, cancel := e.deadlineContext()
+	defer cancel()
+	cmd = exec.CommandContext(ctx, "git", append([]string{"fetch"}, args...)...)
+	cmd.Dir = repoPath
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	cmd.Env = append(cmd.Env, e.client.gitNetworkEnv()...)
+	cmd.Env = append(cmd.Env, credentialEnv...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w", SanitizeSupportText(string(output)), err)
+	}
+	return nil
+}
+
+// topUpMirrors runs a final git fetch --prune for every exported repository
+// and repeats the checks done after the clone on the updated mirror.
+func (e *Exporter) topUpMirrors(workspace string, repoSlugs []string) error {
+	if e.client.keepAmbiguousPRs {
+		e.logger.Warn("Skipping the top-up fetch: --keep-ambiguous-prs renamed branches in the mirrors")
+		return nil
+	}
+
+	for _, repoSlug := range repoSlugs {
+		repoPath := ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".git"))
+		if _, err := os.Stat(repoPath); err != nil {
+			continue
+		}
+
+		before, err := mirrorRefs(repoPath)
+		if err != nil {
+			return err
+		}
+		args := append([]string{"--prune", "--no-tags", "origin"}, topUpRefspecs...)
+		if err := e.fetchOrigin(repoPath, args...); err != nil {
+			if deadlineErr := e.checkDeadline(stageGitClone); deadlineErr != nil {
+				return deadlineErr
+			}
+			if e.failOnCloneError {
+				return fmt.Errorf("top-up fetch of %s failed: %w", repoSlug, err)
+			}
+			e.logger.Warn("Top-up fetch failed; archiving the mirror as cloned",
+				zap.String("repository", repoSlug),
+				zap.Error(err))
+			continue
+		}
+		after, err := mirrorRefs(repoPath)
+		if err != nil {
+			return err
+		}
+
+		updated := changedRefs(before, after)
+		e.report.Counts.TopUpUpdatedRefs += updated
+		e.logger.Info("Top-up fetch complete",
+			zap.String("repository", repoSlug),
+			zap.Int("updated_refs", updated))
+		if updated == 0 {
+			continue
+		}
+
+		if err := e.recheckMirror(workspace, repoSlug, repoPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recheckMirror repeats the checks done after the clone on a mirror that a
+// later fetch updated.
+func (e *Exporter) recheckMirror(workspace, repoSlug, repoPath string) error {
+	if err := e.pruneRefs(workspace, repoSlug, repoPath); err != nil {
+		return err
+	}
+	if err := e.validateGitReferences(repoPath); err != nil {
+		return err
+	}
+	if err := e.checkImportSafety(workspace, repoSlug, repoPath); err != nil {
+		return err
+	}
+	return e.splitPacks(workspace, repoSlug, repoPath)
+}
+
+// mirrorRefs returns the object each branch and tag of a mirror points to.
+func mirrorRefs(repoPath string) (map[string]string, error) {
+	cmd := exec.Command("git", "for-each-ref", "--format=%(objectname) %(refname)", "refs/heads", "refs/tags")
+	cmd.Dir = repoPath
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refs in %s: %w", repoPath, err)
+	}
+	refs := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if object, ref, ok := strings.Cut(line, " "); ok {
+			refs[ref] = object
+		}
+	}
+	return refs, nil
+}
+
+// changedRefs counts the refs that were added, moved or deleted.
+func changedRefs(before, after map[string]string) int {
+	changed := 0
+	for ref, object := range after {
+		if before[ref] != object {
+			changed++
+		}
+	}
+	for ref := range before {
+		if _, ok := after[ref]; !ok {
+			changed++
+		}
+	}
+	return changed
+}
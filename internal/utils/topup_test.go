@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopUpMirrors(t *testing.T) {
+	exporter, base, head := consistencyFixture(t, ConsistencyBestEffort)
+	mirrorPath := filepath.Join(exporter.outputDir, "repositories", "workspace", "repo.git")
+	workDir := runGit(t, mirrorPath, "remote", "get-url", "origin")
+	runGit(t, mirrorPath, "update-ref", "refs/heads/gone", base)
+	runGit(t, workDir, "update-ref", "refs/pull-requests/1/from", head)
+
+	require.NoError(t, exporter.topUpMirrors("workspace", []string{"repo"}))
+
+	refs, err := mirrorRefs(mirrorPath)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"refs/heads/main": base, "refs/heads/feature": head}, refs)
+	assert.Equal(t, 2, exporter.report.Counts.TopUpUpdatedRefs)
+
+	pullRefs := runGit(t, mirrorPath, "for-each-ref", "refs/pull-requests")
+	assert.Empty(t, pullRefs, "pull request refs must not be fetched again")
+}
+
+func TestTopUpMirrorsSkipsAmbiguousBranchRenames(t *testing.T) {
+	exporter, base, _ := consistencyFixture(t, ConsistencyBestEffort)
+	exporter.client.keepAmbiguousPRs = true
+	mirrorPath := filepath.Join(exporter.outputDir, "repositories", "workspace", "repo.git")
+
+	require.NoError(t, exporter.topUpMirrors("workspace", []string{"repo"}))
+
+	refs, err := mirrorRefs(mirrorPath)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"refs/heads/main": base}, refs)
+}
+
+func TestChangedRefs(t *testing.T) {
+	before := map[string]string{"refs/heads/main": "a", "refs/heads/old": "b", "refs/tags/v1": "c"}
+	after := map[string]string{"refs/heads/main": "d", "refs/heads/new": "e", "refs/tags/v1": "c"}
+	assert.Equal(t, 3, changedRefs(before, after))
+	assert.Equal(t, 0, changedRefs(before, before))
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+)
+
+// SetTransport replaces the RoundTripper used for Bitbucket API requests, for
+// example to route traffic through a corporate authentication sidecar.
+func (c *Client) SetTransport(transport http.RoundTripper) {
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{}
+	}
+	httpClient := *c.httpClient
+	httpClient.Transport = transport
+	c.httpClient = &httpClient
+}
+
+// NewTransport builds a RoundTripper that dials either a unix domain socket or
+// a proxy command speaking HTTP over its stdin/stdout (like ssh ProxyCommand).
+// It returns nil when no custom transport is configured.
+func NewTransport(config data.TransportConfig) (http.RoundTripper, error) {
+	if config.UnixSocket != "" && len(config.ProxyCommand) > 0 {
+		return nil, fmt.Errorf("api.transport.unix_socket and api.transport.proxy_command cannot both be set")
+	}
+
+	var dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	switch {
+	case config.UnixSocket != "":
+		socketPath := config.UnixSocket
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		}
+	case len(config.ProxyCommand) > 0:
+		command := config.ProxyCommand
+		dial = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialCommand(ctx, command)
+		}
+	default:
+		return nil, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dial
+	return transport, nil
+}
+
+// commandConn is a net.Conn backed by a subprocess's stdin and stdout.
+type commandConn struct {
+	cmd       *exec.Cmd
+	stdin     *os.File
+	stdout    *os.File
+	connected atomic.Bool
+}
+
+// dialCommand starts the proxy command. Like net.Dialer, ctx bounds the dial
+// only: once started, the command runs until the connection is closed.
+func dialCommand(ctx context.Context, command []string) (net.Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to start proxy command %s: %w", command[0], err)
+	}
+	stdinReader, stdinWriter, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open proxy command stdin: %w", err)
+	}
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		_ = stdinReader.Close()
+		_ = stdinWriter.Close()
+		return nil, fmt.Errorf("failed to open proxy command stdout: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = stdinReader
+	cmd.Stdout = stdoutWriter
+	conn := &commandConn{cmd: cmd, stdin: stdinWriter, stdout: stdoutReader}
+	cmd.Cancel = func() error {
+		if conn.connected.Load() {
+			return nil
+		}
+		return cmd.Process.Kill()
+	}
+
+	err = cmd.Start()
+	// The command holds its own copies of these ends.
+	_ = stdinReader.Close()
+	_ = stdoutWriter.Close()
+	if err != nil {
+		_ = stdinWriter.Close()
+		_ = stdoutReader.Close()
+		return nil, fmt.Errorf("failed to start proxy command %s: %w", command[0], err)
+	}
+	if err := ctx.Err(); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to start proxy command %s: %w", command[0], err)
+	}
+	conn.connected.Store(true)
+	return conn, nil
+}
+
+func (c *commandConn) Read(b []byte) (int, error) {
+	return c.stdout.Read(b)
+}
+
+func (c *commandConn) Write(b []byte) (int, error) {
+	return c.stdin.Write(b)
+}
+
+func (c *commandConn) Close() error {
+	_ = c.stdin.Close()
+	if c.cmd.Process != nil {
+		_ = c.cmd.Process.Kill()
+	}
+	_ = c.cmd.Wait()
+	_ = c.stdout.Close()
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr  { return commandAddr{} }
+func (c *commandConn) RemoteAddr() net.Addr { return commandAddr{} }
+
+func (c *commandConn) SetDeadline(t time.Time) error {
+	if err := c.stdout.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.stdin.SetWriteDeadline(t)
+}
+
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return c.stdout.SetReadDeadline(t) }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return c.stdin.SetWriteDeadline(t) }
+
+type commandAddr struct{}
+
+func (commandAddr) Network() string { return "command" }
+func (commandAddr) String() string  { return "proxy-command" }
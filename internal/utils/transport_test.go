@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewTransport(t *testing.T) {
+	transport, err := NewTransport(data.TransportConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, transport)
+
+	_, err = NewTransport(data.TransportConfig{UnixSocket: "/tmp/sock", ProxyCommand: []string{"nc"}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot both be set")
+}
+
+func TestUnixSocketTransport(t *testing.T) {
+	socketDir, err := os.MkdirTemp("", "bbc")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(socketDir)
+	}()
+	socketPath := filepath.Join(socketDir, "api.sock")
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("unix sockets not supported: %v", err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/2.0/repositories/ws/repo", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		writeResponse(t, w, []byte(`{"name": "repo", "slug": "repo"}`))
+	})}
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	defer func() {
+		_ = server.Close()
+	}()
+
+	logger, _ := zap.NewDevelopment()
+	client := &Client{
+		baseURL: "http://bitbucket.sidecar/2.0",
+		logger:  logger,
+	}
+	transport, err := NewTransport(data.TransportConfig{UnixSocket: socketPath})
+	require.NoError(t, err)
+	client.SetTransport(transport)
+
+	repo, err := client.GetRepository("ws", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, "repo", repo.Slug)
+}
+
+func TestCommandConn(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not available")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := dialCommand(ctx, []string{"cat"})
+	require.NoError(t, err)
+	cancel()
+
+	_, err = conn.Write([]byte("ping"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 4)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "ping", string(buf[:n]))
+	assert.Equal(t, "command", conn.RemoteAddr().Network())
+	assert.NoError(t, conn.Close())
+
+	_, err = dialCommand(context.Background(), []string{filepath.Join(t.TempDir(), "missing-command")})
+	assert.Error(t, err)
+}
+
+func TestCommandConnHonoursDeadlines(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("cat not available")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := dialCommand(ctx, []string{"cat"})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	conn, err := dialCommand(context.Background(), []string{"cat"})
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	start := time.Now()
+	_, err = conn.Read(make([]byte, 4))
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	require.NoError(t, conn.SetDeadline(time.Time{}))
+	_, err = conn.Write([]byte("pong"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	n, err := conn.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(buf[:n]))
+}
+
+func TestSetTransportKeepsExistingClientSettings(t *testing.T) {
+	client := &Client{httpClient: &http.Client{Timeout: 42}}
+	client.SetTransport(http.DefaultTransport)
+	assert.Equal(t, http.DefaultTransport, client.httpClient.Transport)
+	assert.EqualValues(t, 42, client.httpClient.Timeout)
+}
@@ -13,12 +13,18 @@ import (
 	"strings"
 	"time"
 
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
 	"go.uber.org/zap"
 )
 
 var (
 	DefaultPartSize           int64 = 100 * 1024 * 1024  // 100 MB
 	DefaultMultipartThreshold int64 = 5000 * 1024 * 1024 // 5 GB
+
+	// uploadRequestTimeout bounds each upload request rather than the whole
+	// upload, so a multipart upload of any size only fails when a single
+	// part stalls.
+	uploadRequestTimeout = 60 * time.Minute
 )
 
 const (
@@ -70,8 +76,7 @@ func (g *APIGetter) UploadArchiveToGitHub(orgID int, archivePath string, logger
 		zap.Int("orgID", orgID),
 		zap.String("archivePath", archivePath))
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Minute)
-	defer cancel()
+	ctx := context.Background()
 
 	logger.Debug("Opening archive file", zap.String("path", archivePath))
 	file, err := os.Open(archivePath)
@@ -127,9 +132,8 @@ func (g *APIGetter) uploadSingleFile(ctx context.Context, orgID int, file *os.Fi
 		zap.Int64("size", fileSize),
 		zap.Int("orgID", orgID))
 
-	httpClient := &http.Client{
-		Timeout: 60 * time.Minute,
-	}
+	ctx, cancel := context.WithTimeout(ctx, uploadRequestTimeout)
+	defer cancel()
 
 	logger.Debug("Creating HTTP POST request for single-file upload")
 	req, err := http.NewRequestWithContext(ctx, "POST", uploadURL, file)
@@ -150,7 +154,7 @@ func (g *APIGetter) uploadSingleFile(ctx context.Context, orgID int, file *os.Fi
 
 	logger.Debug("Executing HTTP request for upload")
 	startTime := time.Now()
-	resp, err := httpClient.Do(req)
+	resp, err := http.DefaultClient.Do(req)
 	uploadDuration := time.Since(startTime)
 	if err != nil {
 		logger.Debug("Failed to upload file",
@@ -221,48 +225,72 @@ func (g *APIGetter) uploadMultipartFile(ctx context.Context, orgID int, file *os
 		zap.Int64("fileSize", fileSize))
 
 	startTime := time.Now()
+	statePath := uploadStatePath(file.Name())
+
+	// Step 1: Resume an interrupted upload of this archive, or start a new one
+	state := loadUploadState(statePath, file, orgID, fileName, fileSize, logger)
+	resumed := state != nil
+	if resumed {
+		logger.Info("Resuming interrupted multipart upload",
+			zap.String("guid", state.GUID),
+			zap.String("uploadID", state.UploadID),
+			zap.Int("completedParts", len(state.Parts)),
+			zap.Int64("uploadedBytes", state.UploadedBytes),
+			zap.Bool("partsComplete", state.PartsComplete))
+	} else {
+		logger.Debug("Step 1: Initiating multipart upload session")
+		guid, uploadID, location, err := g.startMultipartUpload(ctx, orgID, fileName, fileSize, logger)
+		if err != nil {
+			logger.Debug("Failed to start multipart upload", zap.Error(err))
+			return "", fmt.Errorf("failed to start multipart upload: %w", err)
+		}
 
-	// Step 1: Start multipart upload
-	logger.Debug("Step 1: Initiating multipart upload session")
-	guid, uploadID, nextLocation, err := g.startMultipartUpload(ctx, orgID, fileName, fileSize, logger)
-	if err != nil {
-		logger.Debug("Failed to start multipart upload", zap.Error(err))
-		return "", fmt.Errorf("failed to start multipart upload: %w", err)
+		logger.Info("Multipart upload started",
+			zap.String("guid", guid),
+			zap.String("uploadID", uploadID))
+
+		logger.Debug("Multipart upload session created",
+			zap.String("guid", guid),
+			zap.String("uploadID", uploadID),
+			zap.String("initialLocation", location))
+
+		state = &data.UploadState{
+			ArchiveName:  fileName,
+			ArchiveSize:  fileSize,
+			OrgID:        orgID,
+			PartSize:     DefaultPartSize,
+			GUID:         guid,
+			UploadID:     uploadID,
+			NextLocation: location,
+			LastLocation: location,
+		}
+		if err := saveUploadState(statePath, state); err != nil {
+			logger.Warn("Upload cannot be resumed if interrupted", zap.Error(err))
+		}
 	}
 
-	logger.Info("Multipart upload started",
-		zap.String("guid", guid),
-		zap.String("uploadID", uploadID))
-
-	logger.Debug("Multipart upload session created",
-		zap.String("guid", guid),
-		zap.String("uploadID", uploadID),
-		zap.String("initialLocation", nextLocation))
-
 	// Step 2: Upload parts
 	logger.Debug("Step 2: Beginning part uploads")
-	partNumber := 1
-	var uploadedBytes int64 = 0
-	lastLocation := nextLocation
+	partNumber := len(state.Parts) + 1
 	totalParts := int((fileSize + DefaultPartSize - 1) / DefaultPartSize)
 
-	for uploadedBytes < fileSize {
+	for !state.PartsComplete && state.UploadedBytes < fileSize {
 		// Calculate the size of this part
 		currentPartSize := DefaultPartSize
-		if fileSize-uploadedBytes < DefaultPartSize {
-			currentPartSize = fileSize - uploadedBytes
+		if fileSize-state.UploadedBytes < DefaultPartSize {
+			currentPartSize = fileSize - state.UploadedBytes
 		}
 
 		logger.Debug("Preparing part for upload",
 			zap.Int("partNumber", partNumber),
 			zap.Int("totalParts", totalParts),
 			zap.Int64("partSize", currentPartSize),
-			zap.Int64("uploadedSoFar", uploadedBytes),
-			zap.Int64("remaining", fileSize-uploadedBytes))
+			zap.Int64("uploadedSoFar", state.UploadedBytes),
+			zap.Int64("remaining", fileSize-state.UploadedBytes))
 
 		// Read the part into memory
 		partBuf := make([]byte, currentPartSize)
-		n, err := file.Read(partBuf)
+		n, err := file.ReadAt(partBuf, state.UploadedBytes)
 		if err != nil && err != io.EOF {
 			logger.Debug("Failed to read file part",
 				zap.Int("partNumber", partNumber),
@@ -275,41 +303,64 @@ func (g *APIGetter) uploadMultipartFile(ctx context.Context, orgID int, file *os
 				zap.Int("actual", n))
 			partBuf = partBuf[:n]
 		}
+		checksum := partChecksum(partBuf)
 
 		logger.Info("Uploading part",
 			zap.Int("part", partNumber),
-			zap.Int("bytes", n))
+			zap.Int("bytes", n),
+			zap.String("sha256", checksum))
 
-		// Save the previous location for the finalization step
-		lastLocation = nextLocation
-
-		// Upload this part
+		// Upload this part, retrying transient failures such as network drops
 		partStartTime := time.Now()
-		nextLocation, err = g.uploadPart(ctx, nextLocation, partBuf, partNumber, logger)
+		location := state.NextLocation
+		nextLocation, err := g.uploadPartWithRetry(ctx, location, partBuf, partNumber, logger)
 		partDuration := time.Since(partStartTime)
 		if err != nil {
 			logger.Debug("Failed to upload part",
 				zap.Int("partNumber", partNumber),
 				zap.Duration("duration", partDuration),
 				zap.Error(err))
+			if resumed && !isRetryableUploadError(err) {
+				// The upload session was likely discarded by GitHub; start over next time.
+				removeUploadState(statePath, logger)
+			} else {
+				logger.Info("Upload interrupted; re-run the migration to resume from the last completed part",
+					zap.String("state", statePath))
+			}
 			return "", fmt.Errorf("failed to upload part %d: %w", partNumber, err)
 		}
 
-		uploadedBytes += int64(n)
+		// Save the previous location for the finalization step
+		state.LastLocation = location
+		state.NextLocation = nextLocation
+		state.Parts = append(state.Parts, data.UploadedPart{
+			Number: partNumber,
+			Offset: state.UploadedBytes,
+			Size:   int64(n),
+			SHA256: checksum,
+		})
+		state.UploadedBytes += int64(n)
+		// The last part has no next location; mark the parts complete so an
+		// interrupted finalize resumes straight into finalizing.
+		state.PartsComplete = state.UploadedBytes >= fileSize || nextLocation == ""
+		if err := saveUploadState(statePath, state); err != nil {
+			logger.Warn("Failed to record upload progress", zap.Error(err))
+		}
+
 		logger.Debug("Part uploaded successfully",
 			zap.Int("partNumber", partNumber),
 			zap.Int("bytesUploaded", n),
-			zap.Int64("totalUploaded", uploadedBytes),
-			zap.Float64("progressPercent", float64(uploadedBytes)/float64(fileSize)*100),
+			zap.Int64("totalUploaded", state.UploadedBytes),
+			zap.Float64("progressPercent", float64(state.UploadedBytes)/float64(fileSize)*100),
 			zap.Duration("partDuration", partDuration),
 			zap.String("nextLocation", nextLocation))
 
 		partNumber++
 
 		// If this is the last part, break the loop
-		if uploadedBytes >= fileSize || nextLocation == "" {
+		if state.PartsComplete {
 			logger.Debug("All parts uploaded",
-				zap.Int64("totalBytes", uploadedBytes),
+				zap.Int64("totalBytes", state.UploadedBytes),
 				zap.Int("totalParts", partNumber-1))
 			break
 		}
@@ -317,22 +368,25 @@ func (g *APIGetter) uploadMultipartFile(ctx context.Context, orgID int, file *os
 
 	// Step 3: Complete multipart upload
 	logger.Debug("Step 3: Finalizing multipart upload",
-		zap.String("lastLocation", lastLocation))
+		zap.String("lastLocation", state.LastLocation))
 	logger.Info("Finalizing upload...")
-	uri, err := g.completeMultipartUpload(ctx, lastLocation, logger)
+	uri, err := g.completeMultipartUpload(ctx, state.LastLocation, logger)
 	if err != nil {
 		logger.Debug("Failed to complete multipart upload", zap.Error(err))
+		logger.Info("Upload interrupted; re-run the migration to resume with finalizing the upload",
+			zap.String("state", statePath))
 		return "", fmt.Errorf("failed to complete multipart upload: %w", err)
 	}
+	removeUploadState(statePath, logger)
 
 	totalDuration := time.Since(startTime)
 	logger.Info("Multipart upload completed", zap.String("uri", uri))
 	logger.Debug("Multipart upload finished",
 		zap.String("uri", uri),
-		zap.Int64("totalBytes", uploadedBytes),
+		zap.Int64("totalBytes", state.UploadedBytes),
 		zap.Int("totalParts", partNumber-1),
 		zap.Duration("totalDuration", totalDuration),
-		zap.Float64("avgMBps", float64(uploadedBytes)/(1024*1024)/totalDuration.Seconds()))
+		zap.Float64("avgMBps", float64(state.UploadedBytes)/(1024*1024)/totalDuration.Seconds()))
 
 	return uri, nil
 }
@@ -359,6 +413,9 @@ func (g *APIGetter) startMultipartUpload(ctx context.Context, orgID int, fileNam
 
 	logger.Debug("Request body prepared", zap.String("body", string(bodyBytes)))
 
+	ctx, cancel := context.WithTimeout(ctx, uploadRequestTimeout)
+	defer cancel()
+
 	logger.Debug("Sending multipart upload initiation request via REST client",
 		zap.String("method", "POST"),
 		zap.String("url", uploadURL))
@@ -432,6 +489,9 @@ func (g *APIGetter) uploadPart(ctx context.Context, location string, data []byte
 		zap.String("method", "PATCH"),
 		zap.Int("contentLength", len(data)))
 
+	ctx, cancel := context.WithTimeout(ctx, uploadRequestTimeout)
+	defer cancel()
+
 	startTime := time.Now()
 	resp, err := g.restClient.RequestWithContext(ctx, "PATCH", uploadURL, bytes.NewReader(data))
 	duration := time.Since(startTime)
@@ -471,6 +531,35 @@ func (g *APIGetter) uploadPart(ctx context.Context, location string, data []byte
 	return nextLocation, nil
 }
 
+// uploadPartWithRetry uploads a part, retrying transient failures with
+// exponential backoff so a brief network drop does not abort a long upload.
+func (g *APIGetter) uploadPartWithRetry(ctx context.Context, location string, part []byte, partNumber int, logger *zap.Logger) (string, error) {
+	var err error
+	for attempt := 1; attempt <= maxPartUploadAttempts; attempt++ {
+		var nextLocation string
+		nextLocation, err = g.uploadPart(ctx, location, part, partNumber, logger)
+		if err == nil {
+			return nextLocation, nil
+		}
+		if !isRetryableUploadError(err) || attempt == maxPartUploadAttempts {
+			break
+		}
+
+		delay := partRetryDelay(attempt)
+		logger.Warn("Part upload failed, retrying",
+			zap.Int("part", partNumber),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", delay),
+			zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return "", err
+}
+
 func (g *APIGetter) completeMultipartUpload(ctx context.Context, lastLocation string, logger *zap.Logger) (string, error) {
 	finalizeURL := g.uploadsHost + lastLocation
 
@@ -481,6 +570,9 @@ func (g *APIGetter) completeMultipartUpload(ctx context.Context, lastLocation st
 		zap.String("method", "PUT"),
 		zap.String("url", finalizeURL))
 
+	ctx, cancel := context.WithTimeout(ctx, uploadRequestTimeout)
+	defer cancel()
+
 	startTime := time.Now()
 	resp, err := g.restClient.RequestWithContext(ctx, "PUT", finalizeURL, nil)
 	duration := time.Since(startTime)
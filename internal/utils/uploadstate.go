@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const uploadStateSuffix = ".upload-state.json"
+
+var (
+	maxPartUploadAttempts       = 5
+	partUploadRetryBackoff      = 2 * time.Second
+	partUploadRetryBackoffLimit = 1 * time.Minute
+)
+
+// uploadStatePath returns where the resume state of a multipart upload is
+// kept, next to the archive so a re-run of the same archive picks it up.
+func uploadStatePath(archivePath string) string {
+	return archivePath + uploadStateSuffix
+}
+
+func partChecksum(part []byte) string {
+	sum := sha256.Sum256(part)
+	return hex.EncodeToString(sum[:])
+}
+
+func saveUploadState(path string, state *data.UploadState) error {
+	stateData, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode upload state: %w", err)
+	}
+	err = writeFileAtomic(path, 0600, func(w io.Writer) error {
+		_, err := w.Write(stateData)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write upload state: %w", err)
+	}
+	return nil
+}
+
+func removeUploadState(path string, logger *zap.Logger) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logger.Warn("Failed to remove upload state", zap.String("path", path), zap.Error(err))
+	}
+}
+
+// loadUploadState returns the saved state of an interrupted upload of the same
+// archive, or nil when there is nothing to resume. Every recorded part is
+// re-hashed so a modified archive is never resumed. Once all parts are
+// uploaded there is no next location, only the last one to finalize.
+func loadUploadState(path string, file io.ReaderAt, orgID int, fileName string, fileSize int64, logger *zap.Logger) *data.UploadState {
+	stateData, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn("Failed to read upload state, starting a new upload", zap.Error(err))
+		}
+		return nil
+	}
+
+	var state data.UploadState
+	if err := json.Unmarshal(stateData, &state); err != nil {
+		logger.Warn("Ignoring unreadable upload state, starting a new upload", zap.Error(err))
+		return nil
+	}
+
+	if state.ArchiveName != fileName || state.ArchiveSize != fileSize || state.OrgID != orgID ||
+		state.PartSize != DefaultPartSize || (state.NextLocation == "" && !state.PartsComplete) {
+		logger.Info("Upload state does not match this archive, starting a new upload",
+			zap.String("state", path))
+		return nil
+	}
+
+	var offset int64
+	for _, part := range state.Parts {
+		if part.Offset != offset {
+			logger.Warn("Upload state has non-contiguous parts, starting a new upload")
+			return nil
+		}
+		buf := make([]byte, part.Size)
+		if _, err := file.ReadAt(buf, part.Offset); err != nil && !errors.Is(err, io.EOF) {
+			logger.Warn("Failed to verify uploaded part, starting a new upload", zap.Error(err))
+			return nil
+		}
+		if partChecksum(buf) != part.SHA256 {
+			logger.Warn("Archive changed since the interrupted upload, starting a new upload",
+				zap.Int("part", part.Number))
+			return nil
+		}
+		offset += part.Size
+	}
+	if offset != state.UploadedBytes {
+		logger.Warn("Upload state byte count does not match its parts, starting a new upload")
+		return nil
+	}
+
+	return &state
+}
+
+// isRetryableUploadError reports whether a failed part upload is worth
+// retrying. Network errors and 5xx/429 responses are; other HTTP errors are not.
+func isRetryableUploadError(err error) bool {
+	var httpErr *api.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500 || httpErr.StatusCode == 429
+	}
+	return true
+}
+
+func partRetryDelay(attempt int) time.Duration {
+	delay := partUploadRetryBackoff * time.Duration(1<<uint(attempt-1))
+	if delay > partUploadRetryBackoffLimit {
+		delay = partUploadRetryBackoffLimit
+	}
+	return delay
+}
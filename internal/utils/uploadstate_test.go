@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// chunkedUploadServer simulates the GitHub multipart archive upload API and
+// records what it receives. failPart makes PATCH requests for that part fail
+// with failStatus while failRemaining is positive, failFinalize fails that
+// many PUT requests and partDelay slows every PATCH request down. Like
+// GitHub, the response to the last part has no next location when lastPart
+// is set.
+type chunkedUploadServer struct {
+	mu            sync.Mutex
+	starts        int
+	patches       int
+	received      []byte
+	failPart      int
+	failStatus    int
+	failRemaining int
+	failFinalize  int
+	lastPart      int
+	partDelay     time.Duration
+}
+
+func (s *chunkedUploadServer) handler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case "POST":
+		s.starts++
+		w.Header().Set("Location", "/organizations/12345/gei/archive/blobs/uploads?part_number=1&guid=test-guid&upload_id=test-upload")
+		w.WriteHeader(http.StatusAccepted)
+	case "PATCH":
+		s.patches++
+		time.Sleep(s.partDelay)
+		partNumber := 0
+		_, _ = fmt.Sscanf(r.URL.Query().Get("part_number"), "%d", &partNumber)
+		body, _ := io.ReadAll(r.Body)
+		if partNumber == s.failPart && s.failRemaining > 0 {
+			s.failRemaining--
+			w.WriteHeader(s.failStatus)
+			return
+		}
+		s.received = append(s.received, body...)
+		if partNumber == s.lastPart {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/organizations/12345/gei/archive/blobs/uploads?part_number=%d&guid=test-guid&upload_id=test-upload", partNumber+1))
+		w.WriteHeader(http.StatusAccepted)
+	case "PUT":
+		if s.failFinalize > 0 {
+			s.failFinalize--
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"guid": "test-guid", "uri": "gei://archive/resumed"}`))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newChunkedUploadTest(t *testing.T, server *chunkedUploadServer) (*APIGetter, string, []byte) {
+	testServer := httptest.NewServer(http.HandlerFunc(server.handler))
+	t.Cleanup(testServer.Close)
+
+	restClient, err := api.NewRESTClient(api.ClientOptions{
+		Host:      testServer.URL,
+		AuthToken: "test-token",
+	})
+	require.NoError(t, err)
+	apiGetter := NewAPIGetter(&api.GraphQLClient{}, restClient, "test-token")
+	apiGetter.SetUploadsBaseURL(testServer.URL+"/organizations/%d/gei/archive", testServer.URL)
+
+	oldThreshold, oldPartSize := DefaultMultipartThreshold, DefaultPartSize
+	oldBackoff := partUploadRetryBackoff
+	DefaultMultipartThreshold = 1
+	DefaultPartSize = 10
+	partUploadRetryBackoff = time.Millisecond
+	t.Cleanup(func() {
+		DefaultMultipartThreshold, DefaultPartSize = oldThreshold, oldPartSize
+		partUploadRetryBackoff = oldBackoff
+	})
+
+	content := []byte("0123456789abcdefghijABCDEFGHIJklmnopqrstZ")
+	archivePath := filepath.Join(t.TempDir(), "archive.tar.gz")
+	require.NoError(t, os.WriteFile(archivePath, content, 0644))
+
+	return apiGetter, archivePath, content
+}
+
+func TestMultipartUploadRetriesTransientFailures(t *testing.T) {
+	server := &chunkedUploadServer{failPart: 2, failStatus: http.StatusServiceUnavailable, failRemaining: 2}
+	apiGetter, archivePath, content := newChunkedUploadTest(t, server)
+
+	uri, err := apiGetter.UploadArchiveToGitHub(12345, archivePath, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, "gei://archive/resumed", uri)
+	assert.Equal(t, content, server.received)
+	assert.NoFileExists(t, uploadStatePath(archivePath))
+}
+
+func TestMultipartUploadResumesAfterInterruption(t *testing.T) {
+	server := &chunkedUploadServer{failPart: 3, failStatus: http.StatusBadRequest, failRemaining: 1}
+	apiGetter, archivePath, content := newChunkedUploadTest(t, server)
+
+	_, err := apiGetter.UploadArchiveToGitHub(12345, archivePath, zap.NewNop())
+	require.Error(t, err)
+	assert.FileExists(t, uploadStatePath(archivePath))
+	assert.Equal(t, content[:20], server.received)
+
+	uri, err := apiGetter.UploadArchiveToGitHub(12345, archivePath, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, "gei://archive/resumed", uri)
+	assert.Equal(t, 1, server.starts, "resumed upload should not start a new session")
+	assert.Equal(t, content, server.received)
+	assert.NoFileExists(t, uploadStatePath(archivePath))
+}
+
+func TestMultipartUploadResumesIntoFinalize(t *testing.T) {
+	server := &chunkedUploadServer{failFinalize: 1, lastPart: 5}
+	apiGetter, archivePath, content := newChunkedUploadTest(t, server)
+
+	_, err := apiGetter.UploadArchiveToGitHub(12345, archivePath, zap.NewNop())
+	require.Error(t, err)
+	require.FileExists(t, uploadStatePath(archivePath))
+	assert.Equal(t, 5, server.patches)
+
+	uri, err := apiGetter.UploadArchiveToGitHub(12345, archivePath, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, "gei://archive/resumed", uri)
+	assert.Equal(t, 1, server.starts, "resumed upload should not start a new session")
+	assert.Equal(t, 5, server.patches, "every part was already uploaded")
+	assert.Equal(t, content, server.received)
+	assert.NoFileExists(t, uploadStatePath(archivePath))
+}
+
+func TestMultipartUploadDeadlineIsPerPart(t *testing.T) {
+	// Five parts of 40ms each take longer than one request may.
+	server := &chunkedUploadServer{partDelay: 40 * time.Millisecond}
+	apiGetter, archivePath, content := newChunkedUploadTest(t, server)
+	oldTimeout := uploadRequestTimeout
+	uploadRequestTimeout = 150 * time.Millisecond
+	t.Cleanup(func() { uploadRequestTimeout = oldTimeout })
+
+	uri, err := apiGetter.UploadArchiveToGitHub(12345, archivePath, zap.NewNop())
+	require.NoError(t, err)
+	assert.Equal(t, "gei://archive/resumed", uri)
+	assert.Equal(t, content, server.received)
+	assert.Equal(t, 5, server.patches, "no part timed out")
+}
+
+func TestLoadUploadStateRejectsModifiedArchive(t *testing.T) {
+	oldPartSize := DefaultPartSize
+	DefaultPartSize = 4
+	defer func() { DefaultPartSize = oldPartSize }()
+
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "archive.tar.gz")
+	require.NoError(t, os.WriteFile(archivePath, []byte("abcdefgh"), 0644))
+
+	state := &data.UploadState{
+		ArchiveName:   "archive.tar.gz",
+		ArchiveSize:   8,
+		OrgID:         1,
+		PartSize:      4,
+		NextLocation:  "/next",
+		UploadedBytes: 4,
+		Parts:         []data.UploadedPart{{Number: 1, Offset: 0, Size: 4, SHA256: partChecksum([]byte("abcd"))}},
+	}
+	statePath := uploadStatePath(archivePath)
+	require.NoError(t, saveUploadState(statePath, state))
+
+	file, err := os.Open(archivePath)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+
+	loaded := loadUploadState(statePath, file, 1, "archive.tar.gz", 8, zap.NewNop())
+	require.NotNil(t, loaded)
+	assert.Equal(t, "/next", loaded.NextLocation)
+
+	assert.Nil(t, loadUploadState(statePath, file, 2, "archive.tar.gz", 8, zap.NewNop()), "different org")
+
+	require.NoError(t, os.WriteFile(archivePath, []byte("XXXXefgh"), 0644))
+	assert.Nil(t, loadUploadState(statePath, file, 1, "archive.tar.gz", 8, zap.NewNop()), "modified archive")
+}
+
+func TestIsRetryableUploadError(t *testing.T) {
+	assert.True(t, isRetryableUploadError(fmt.Errorf("connection reset")))
+	assert.True(t, isRetryableUploadError(fmt.Errorf("wrapped: %w", &api.HTTPError{StatusCode: 502})))
+	assert.True(t, isRetryableUploadError(&api.HTTPError{StatusCode: 429}))
+	assert.False(t, isRetryableUploadError(&api.HTTPError{StatusCode: 404}))
+	assert.True(t, strings.HasSuffix(uploadStatePath("/tmp/a.tar.gz"), ".tar.gz.upload-state.json"))
+}
+
+func TestPartRetryDelay(t *testing.T) {
+	oldBackoff, oldLimit := partUploadRetryBackoff, partUploadRetryBackoffLimit
+	partUploadRetryBackoff, partUploadRetryBackoffLimit = time.Second, 5*time.Second
+	defer func() { partUploadRetryBackoff, partUploadRetryBackoffLimit = oldBackoff, oldLimit }()
+
+	assert.Equal(t, time.Second, partRetryDelay(1))
+	assert.Equal(t, 4*time.Second, partRetryDelay(3))
+	assert.Equal(t, 5*time.Second, partRetryDelay(10))
+}
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
+)
+
+const defaultURLBase = format.DefaultBase
+
+// ValidateURLConfig checks the urls section of the config file.
+func ValidateURLConfig(config data.URLConfig) error {
+	if config.Base != "" {
+		if err := validateSourceURL(config.Base); err != nil {
+			return fmt.Errorf("invalid urls.base %q: %w", config.Base, err)
+		}
+	}
+
+	kinds := make([]string, 0, len(config.Templates))
+	for kind := range config.Templates {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		template := config.Templates[kind]
+		ids, ok := format.TemplateIDs(kind)
+		if !ok {
+			return fmt.Errorf("unknown URL template %q (supported: %s)", kind, strings.Join(format.TemplateKinds(), ", "))
+		}
+		if ids >= 1 && !strings.Contains(template, "{id}") {
+			return fmt.Errorf("URL template %q must contain {id}", kind)
+		}
+		if ids >= 2 && !strings.Contains(template, "{sub_id}") {
+			return fmt.Errorf("URL template %q must contain {sub_id}", kind)
+		}
+		expanded := format.ExpandTemplate(template, defaultURLBase, "workspace", "repository", "1", "2")
+		if err := validateSourceURL(expanded); err != nil {
+			return fmt.Errorf("invalid URL template %q: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+func validateSourceURL(value string) error {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return err
+	}
+	if (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return fmt.Errorf("expected an absolute http(s) URL")
+	}
+	return nil
+}
+
+// SetURLTemplates applies the urls section of the config file to every URL
+// the client and its exporter generate afterwards. An empty config restores
+// Bitbucket Cloud URLs.
+func (c *Client) SetURLTemplates(config data.URLConfig) {
+	urls := format.URLs{Base: strings.TrimSuffix(config.Base, "/")}
+	if len(config.Templates) > 0 {
+		urls.Templates = make(map[string]string, len(config.Templates))
+		for kind, template := range config.Templates {
+			urls.Templates[kind] = template
+		}
+	}
+	c.urls = urls
+}
+
+// sourceURLs returns the source URL policy of the client. A nil client
+// generates Bitbucket Cloud URLs.
+func (c *Client) sourceURLs() format.URLs {
+	if c == nil {
+		return format.URLs{}
+	}
+	return c.urls
+}
+
+// formatURL returns the source URL of a record with the urls policy of the
+// config file.
+func (c *Client) formatURL(urlType string, workspace, repoSlug string, id ...interface{}) string {
+	return c.sourceURLs().URL(urlType, workspace, repoSlug, id...)
+}
+
+// urlBase returns the base of the source URLs of urls.
+func urlBase(urls format.URLs) string {
+	if urls.Base == "" {
+		return defaultURLBase
+	}
+	return urls.Base
+}
+
+// urlWildcard stands for any workspace or repository in urlPattern.
+const urlWildcard = "\x01"
+
+// urlPattern returns a regular expression matching the URLs urls generates
+// for one kind, with the IDs captured in order. Pass urlWildcard
+// as workspace or repository to match any.
+func urlPattern(urls format.URLs, urlType, workspace, repoSlug string) *regexp.Regexp {
+	const idMarker = "\x00"
+	idCount, _ := format.TemplateIDs(urlType)
+	ids := make([]interface{}, idCount)
+	for i := range ids {
+		ids[i] = idMarker
+	}
+	pattern := regexp.QuoteMeta(urls.URL(urlType, workspace, repoSlug, ids...))
+	pattern = strings.ReplaceAll(pattern, idMarker, `([^/?#\s)]+)`)
+	pattern = strings.ReplaceAll(pattern, urlWildcard, `[^/]+`)
+	return regexp.MustCompile(pattern)
+}
+
+// idsFromURL returns the IDs of a URL of the given kind, or nil when the URL
+// was not generated for that kind.
+func idsFromURL(urls format.URLs, urlType, value string) []string {
+	pattern := urlPattern(urls, urlType, urlWildcard, urlWildcard)
+	match := regexp.MustCompile("^" + pattern.String()).FindStringSubmatch(value)
+	if match == nil {
+		return nil
+	}
+	return match[1:]
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// templatedClient returns a client generating URLs with config.
+func templatedClient(config data.URLConfig) *Client {
+	client := &Client{logger: zap.NewNop()}
+	client.SetURLTemplates(config)
+	return client
+}
+
+func TestFormatURLWithBase(t *testing.T) {
+	client := templatedClient(data.URLConfig{Base: "https://bb-mirror.example.com/"})
+
+	assert.Equal(t, "https://bb-mirror.example.com/ws/repo", client.formatURL("repository", "ws", "repo"))
+	assert.Equal(t, "https://bb-mirror.example.com/ws/repo/pull/7", client.formatURL("pr", "ws", "repo", 7))
+	assert.Equal(t, "https://bb-mirror.example.com/abc", client.formatURL("user", "ws", "", "abc"))
+	assert.Equal(t, "tarball://root/repositories/ws/repo.git", client.formatURL("git", "ws", "repo"))
+	assert.Equal(t, "7", extractPRNumber(client.urls, "https://bb-mirror.example.com/ws/repo/pull/7"))
+}
+
+func TestFormatURLWithTemplates(t *testing.T) {
+	client := templatedClient(data.URLConfig{
+		Base: "https://bb-mirror.example.com",
+		Templates: map[string]string{
+			"pr":            "{base}/projects/{workspace}/repos/{repository}/pull-requests/{id}",
+			"issue_comment": "{base}/projects/{workspace}/repos/{repository}/pull-requests/{id}?commentId={sub_id}",
+			"user":          "https://people.example.com/users/{id}",
+		},
+	})
+
+	assert.Equal(t, "https://bb-mirror.example.com/projects/ws/repos/repo/pull-requests/7",
+		client.formatURL("pr", "ws", "repo", 7))
+	assert.Equal(t, "https://bb-mirror.example.com/projects/ws/repos/repo/pull-requests/7?commentId=9",
+		client.formatURL("issue_comment", "ws", "repo", 7, 9))
+	assert.Equal(t, "https://people.example.com/users/abc", client.formatURL("user", "ws", "", "abc"))
+	assert.Equal(t, "https://bb-mirror.example.com/ws/repo/pull/7/files#r9",
+		client.formatURL("pr_review_comment", "ws", "repo", 7, 9), "kinds without a template use the base")
+	assert.Equal(t, "https://bb-mirror.example.com/ws/repo/pulls", client.formatURL("pr", "ws", "repo"),
+		"templates need their IDs")
+
+	assert.Equal(t, "7", extractPRNumber(client.urls, "https://bb-mirror.example.com/projects/ws/repos/repo/pull-requests/7"))
+	assert.Equal(t, "", extractPRNumber(client.urls, "https://bitbucket.org/ws/repo/pull/7"))
+	assert.Equal(t, []string{"abc"}, idsFromURL(client.urls, "user", "https://people.example.com/users/abc"))
+
+	var nilClient *Client
+	assert.Equal(t, "https://bitbucket.org/ws/repo/pull/7", nilClient.formatURL("pr", "ws", "repo", 7))
+}
+
+func TestTransformCommentBodyUsesURLTemplates(t *testing.T) {
+	client := templatedClient(data.URLConfig{Templates: map[string]string{
+		"pr": "https://bb-mirror.example.com/{workspace}/{repository}/pull-requests/{id}",
+	}})
+
+	body := client.transformCommentBody("See https://bitbucket.org/ws/repo/pull-requests/3 and PR#4", "ws", "repo")
+	assert.Equal(t, "See https://bb-mirror.example.com/ws/repo/pull-requests/3 and "+
+		"PR[#4](https://bb-mirror.example.com/ws/repo/pull-requests/4)", body)
+}
+
+func TestContributorUsersWithUserTemplate(t *testing.T) {
+	client := templatedClient(data.URLConfig{Templates: map[string]string{
+		"user": "https://people.example.com/users/{id}/profile",
+	}})
+
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	users := exporter.contributorUsers(
+		[]data.PullRequest{{User: client.formatURL("user", "ws", "", "bob")}}, nil, nil)
+	require.Len(t, users, 1)
+	assert.Equal(t, "bob", users[0].Login)
+}
+
+// TestURLTemplatesPerJob configures two clients from different config files
+// at the same time, as concurrent serve jobs do; run it with -race.
+func TestURLTemplatesPerJob(t *testing.T) {
+	configs := []string{
+		writeConfigFile(t, "urls:\n  base: https://one.example.com\n"),
+		writeConfigFile(t, "urls:\n  templates:\n    pr: https://two.example.com/{workspace}/{repository}/pr/{id}\n"),
+	}
+	want := []string{"https://one.example.com/ws/repo/pull/7", "https://two.example.com/ws/repo/pr/7"}
+
+	var wg sync.WaitGroup
+	got := make([][]string, len(configs))
+	for i, config := range configs {
+		wg.Add(1)
+		go func(i int, config string) {
+			defer wg.Done()
+			client := &Client{logger: zap.NewNop()}
+			if err := ConfigureClient(client, &data.CmdExportFlags{ConfigFile: config}); err != nil {
+				t.Error(err)
+				return
+			}
+			for j := 0; j < 100; j++ {
+				got[i] = append(got[i], client.formatURL("pr", "ws", "repo", 7))
+			}
+		}(i, config)
+	}
+	wg.Wait()
+
+	for i := range configs {
+		require.Len(t, got[i], 100)
+		for _, url := range got[i] {
+			assert.Equal(t, want[i], url)
+		}
+	}
+}
+
+func TestValidateURLConfig(t *testing.T) {
+	assert.NoError(t, ValidateURLConfig(data.URLConfig{}))
+	assert.NoError(t, ValidateURLConfig(data.URLConfig{
+		Base:      "https://bb-mirror.example.com",
+		Templates: map[string]string{"pr_review": "{base}/{workspace}/{repository}/pr/{id}#review-{sub_id}"},
+	}))
+
+	cases := map[string]data.URLConfig{
+		"invalid urls.base":            {Base: "bb-mirror"},
+		"unknown URL template \"git\"": {Templates: map[string]string{"git": "https://example.com/{id}"}},
+		"must contain {id}":            {Templates: map[string]string{"pr": "{base}/{workspace}/{repository}"}},
+		"must contain {sub_id}":        {Templates: map[string]string{"issue_comment": "{base}/pr/{id}"}},
+		"invalid URL template \"pr\"":  {Templates: map[string]string{"pr": "/relative/{id}"}},
+	}
+	for message, config := range cases {
+		err := ValidateURLConfig(config)
+		require.Error(t, err, message)
+		assert.Contains(t, err.Error(), message)
+	}
+}
+
+func TestLoadConfigValidatesURLs(t *testing.T) {
+	_, err := LoadConfig(writeConfigFile(t, "urls:\n  templates:\n    pr: https://example.com/pr\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must contain {id}")
+}
@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+)
+
+var bitbucketUUIDPattern = regexp.MustCompile(`^\{?[0-9A-Fa-f]{8}(?:-[0-9A-Fa-f]{4}){3}-[0-9A-Fa-f]{12}\}?$`)
+
+// userMappingColumns are the header columns of a --user-mapping file; email
+// is optional.
+var userMappingColumns = []string{"bitbucket", "github_login", "email"}
+
+// mappedUser is the GitHub identity a Bitbucket user is exported as.
+type mappedUser struct {
+	login string
+	email string
+}
+
+// UserMapping maps Bitbucket users, by UUID or display name, to GitHub
+// logins and emails.
+type UserMapping struct {
+	byUUID  map[string]mappedUser // Lower-case UUID without braces
+	byName  map[string]mappedUser // Lower-case display name
+	byLogin map[string]mappedUser // Lower-case GitHub login
+}
+
+// LoadUserMapping reads a CSV file with the header bitbucket,github_login
+// and an optional email column. The bitbucket column holds a user UUID, with
+// or without braces, or a display name. A UUID match takes precedence over a
+// display name match.
+func LoadUserMapping(path string) (*UserMapping, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user mapping file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	mapping, err := parseUserMapping(file)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user mapping file %s: %w", path, err)
+	}
+	return mapping, nil
+}
+
+func parseUserMapping(r io.Reader) (*UserMapping, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("missing header %s", strings.Join(userMappingColumns, ","))
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range userMappingColumns[:2] {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing column %q (header: %s)", required, strings.Join(userMappingColumns, ","))
+		}
+	}
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	mapping := &UserMapping{
+		byUUID:  make(map[string]mappedUser),
+		byName:  make(map[string]mappedUser),
+		byLogin: make(map[string]mappedUser),
+	}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		bitbucket := field(record, "bitbucket")
+		user := mappedUser{login: field(record, "github_login"), email: field(record, "email")}
+		if bitbucket == "" || user.login == "" {
+			return nil, fmt.Errorf("line %d: bitbucket and github_login are required", line)
+		}
+		if !ghostUserPattern.MatchString(user.login) {
+			return nil, fmt.Errorf("line %d: invalid GitHub login %q", line, user.login)
+		}
+		if user.email != "" && !strings.Contains(user.email, "@") {
+			return nil, fmt.Errorf("line %d: invalid email %q", line, user.email)
+		}
+
+		keys, key := mapping.byName, strings.ToLower(bitbucket)
+		if bitbucketUUIDPattern.MatchString(bitbucket) {
+			keys, key = mapping.byUUID, strings.Trim(key, "{}")
+		}
+		if _, duplicate := keys[key]; duplicate {
+			return nil, fmt.Errorf("line %d: %q is mapped more than once", line, bitbucket)
+		}
+		keys[key] = user
+		if existing, ok := mapping.byLogin[strings.ToLower(user.login)]; !ok || existing.email == "" {
+			mapping.byLogin[strings.ToLower(user.login)] = user
+		}
+	}
+	return mapping, nil
+}
+
+// Len returns the number of mapped Bitbucket users.
+func (m *UserMapping) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.byUUID) + len(m.byName)
+}
+
+func (m *UserMapping) lookup(uuid, displayName string) (mappedUser, bool) {
+	if m == nil {
+		return mappedUser{}, false
+	}
+	if uuid != "" {
+		if user, ok := m.byUUID[strings.ToLower(strings.Trim(uuid, "{}"))]; ok {
+			return user, true
+		}
+	}
+	if displayName != "" {
+		if user, ok := m.byName[strings.ToLower(strings.TrimSpace(displayName))]; ok {
+			return user, true
+		}
+	}
+	return mappedUser{}, false
+}
+
+// SetUserMapping exports mapped Bitbucket users under their GitHub login and
+// email in user records and as authors of pull requests and comments.
+func (c *Client) SetUserMapping(mapping *UserMapping) {
+	c.userMapping = mapping
+}
+
+// userLogin returns the login a Bitbucket user is exported as: the mapped
+// GitHub login, or the UUID without braces.
+func (c *Client) userLogin(uuid, displayName string) string {
+	if user, ok := c.userMapping.lookup(uuid, displayName); ok {
+		return user.login
+	}
+	return strings.Trim(uuid, "{}")
+}
+
+// userEmails returns the email of a user record for a mapped login.
+func (c *Client) userEmails(login string) []data.Email {
+	if c.userMapping != nil {
+		if user, ok := c.userMapping.byLogin[strings.ToLower(login)]; ok && user.email != "" {
+			return []data.Email{{Address: user.email, Primary: true}}
+		}
+	}
+	return []data.Email{}
+}
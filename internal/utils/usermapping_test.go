@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	aliceUUID = "{0f3a2b1c-4d5e-4f60-8a7b-9c0d1e2f3a4b}"
+	bobUUID   = "{1a2b3c4d-5e6f-4a7b-8c9d-0e1f2a3b4c5d}"
+)
+
+func testUserMapping(t *testing.T) *UserMapping {
+	t.Helper()
+	mapping, err := parseUserMapping(strings.NewReader(
+		"bitbucket,github_login,email\n" +
+			"# Alice moved teams\n" +
+			"0F3A2B1C-4D5E-4F60-8A7B-9C0D1E2F3A4B, alice-gh, alice@example.com\n" +
+			"Bob Builder,bob-gh\n" +
+			"Alice Smith,someone-else,\n"))
+	require.NoError(t, err)
+	return mapping
+}
+
+func TestParseUserMapping(t *testing.T) {
+	mapping := testUserMapping(t)
+	assert.Equal(t, 3, mapping.Len())
+
+	user, ok := mapping.lookup(aliceUUID, "Alice Smith")
+	require.True(t, ok)
+	assert.Equal(t, mappedUser{login: "alice-gh", email: "alice@example.com"}, user, "a UUID match takes precedence")
+
+	user, ok = mapping.lookup(bobUUID, "bob builder")
+	require.True(t, ok)
+	assert.Equal(t, "bob-gh", user.login)
+
+	_, ok = mapping.lookup("{carol}", "Carol")
+	assert.False(t, ok)
+}
+
+func TestParseUserMappingErrors(t *testing.T) {
+	cases := map[string]string{
+		"":                                    "missing header",
+		"uuid,login\n":                        `missing column "bitbucket"`,
+		"bitbucket,github_login\nAlice,\n":    "line 2: bitbucket and github_login are required",
+		"bitbucket,github_login\nAlice,a b\n": `line 2: invalid GitHub login "a b"`,
+		"bitbucket,github_login,email\nAlice,alice,nope\n":    `line 2: invalid email "nope"`,
+		"bitbucket,github_login\nAlice,alice\nalice,alice2\n": `line 3: "alice" is mapped more than once`,
+	}
+	for content, want := range cases {
+		_, err := parseUserMapping(strings.NewReader(content))
+		assert.ErrorContains(t, err, want, content)
+	}
+}
+
+func TestLoadUserMapping(t *testing.T) {
+	mapping, err := LoadUserMapping("")
+	require.NoError(t, err)
+	assert.Nil(t, mapping)
+
+	_, err = LoadUserMapping(filepath.Join(t.TempDir(), "missing.csv"))
+	assert.ErrorContains(t, err, "failed to read user mapping file")
+
+	path := filepath.Join(t.TempDir(), "users.csv")
+	require.NoError(t, os.WriteFile(path, []byte("bitbucket,github_login\nAlice,alice\n"), 0644))
+	mapping, err = LoadUserMapping(path)
+	require.NoError(t, err)
+	assert.Equal(t, 1, mapping.Len())
+}
+
+func TestUserMappingAttribution(t *testing.T) {
+	client := &Client{logger: zap.NewNop()}
+	client.SetUserMapping(testUserMapping(t))
+
+	assert.Equal(t, "https://bitbucket.org/alice-gh",
+		client.authorURL("ws", data.BitbucketPRUser{UUID: aliceUUID, DisplayName: "Alice Smith"}))
+	assert.Equal(t, "https://bitbucket.org/bob-gh",
+		client.authorURL("ws", data.BitbucketPRUser{UUID: bobUUID, DisplayName: "Bob Builder"}))
+	assert.Equal(t, "https://bitbucket.org/carol",
+		client.authorURL("ws", data.BitbucketPRUser{UUID: "{carol}", DisplayName: "Carol"}))
+	assert.Equal(t, "https://bitbucket.org/ghost",
+		client.authorURL("ws", data.BitbucketPRUser{DisplayName: "Bob Builder"}), "deleted accounts stay with the ghost user")
+
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	users := exporter.contributorUsers([]data.PullRequest{
+		{User: "https://bitbucket.org/alice-gh"},
+		{User: "https://bitbucket.org/bob-gh"},
+	}, nil, nil)
+	require.Len(t, users, 2)
+	assert.Equal(t, "Alice Smith", users[0].Name)
+	assert.Equal(t, []data.Email{{Address: "alice@example.com", Primary: true}}, users[0].Emails)
+	assert.Equal(t, "Bob Builder", users[1].Name)
+	assert.Empty(t, users[1].Emails)
+}
+
+func TestGetUsersWithUserMapping(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(t, w, []byte(`{"values": [
+			{"user": {"display_name": "Alice Smith", "uuid": "`+aliceUUID+`"}},
+			{"user": {"display_name": "Alice (old account)", "uuid": "{2b3c4d5e-6f70-4b8c-9d0e-1f2a3b4c5d6e}"}},
+			{"user": {"display_name": "Dave", "uuid": "{dave}"}}], "next": null}`))
+	}))
+	defer server.Close()
+	mapping, err := parseUserMapping(strings.NewReader(
+		"bitbucket,github_login,email\n" + aliceUUID + ",alice-gh,alice@example.com\n{2b3c4d5e-6f70-4b8c-9d0e-1f2a3b4c5d6e},alice-gh,\n"))
+	require.NoError(t, err)
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	client.SetUserMapping(mapping)
+
+	users, err := client.GetUsers("workspace", "repo")
+
+	require.NoError(t, err)
+	require.Len(t, users, 2, "accounts mapped to one login become one user")
+	assert.Equal(t, "alice-gh", users[0].Login)
+	assert.Equal(t, "https://bitbucket.org/alice-gh", users[0].URL)
+	assert.Equal(t, []data.Email{{Address: "alice@example.com", Primary: true}}, users[0].Emails)
+	assert.Equal(t, "dave", users[1].Login)
+	assert.Empty(t, users[1].Emails)
+}
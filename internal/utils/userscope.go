@@ -0,0 +1,142 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	UsersScopeContributors = "contributors"
+	UsersScopeWorkspace    = "workspace"
+	UsersScopeNone         = "none"
+)
+
+var usersScopes = []string{UsersScopeContributors, UsersScopeWorkspace, UsersScopeNone}
+
+// ValidateUsersScope checks a --users-scope value. An empty value selects
+// the default workspace scope.
+func ValidateUsersScope(scope string) error {
+	if scope == "" {
+		return nil
+	}
+	for _, known := range usersScopes {
+		if scope == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value for --users-scope: %q (supported: %s)",
+		scope, strings.Join(usersScopes, ", "))
+}
+
+// usersScope returns which users are written to the archive:
+//   - workspace: every workspace member
+//   - contributors: only authors of exported pull requests and comments
+//   - none: a single placeholder user for the workspace
+func (e *Exporter) usersScope() string {
+	if e.flags == nil || e.flags.UsersScope == "" {
+		return UsersScopeWorkspace
+	}
+	return e.flags.UsersScope
+}
+
+// recordContributor remembers the display name of a pull request or comment
+// author under its exported login so contributor-scoped user records can be
+// named.
+func (c *Client) recordContributor(user data.BitbucketPRUser) {
+	if strings.Trim(user.UUID, "{}") == "" {
+		return
+	}
+	login := c.userLogin(user.UUID, user.DisplayName)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.contributors == nil {
+		c.contributors = make(map[string]string)
+	}
+	if user.DisplayName != "" || c.contributors[login] == "" {
+		c.contributors[login] = user.DisplayName
+	}
+}
+
+// exportUsers returns the user records for the workspace and none scopes.
+func (e *Exporter) exportUsers(workspace, repoSlug string) []data.User {
+	if e.usersScope() == UsersScopeNone {
+		e.logger.Info("Skipping workspace members", zap.String("users_scope", UsersScopeNone))
+		return e.createBasicUsers(workspace)
+	}
+
+	e.logger.Debug("Fetching users")
+	users, err := e.client.GetUsers(workspace, repoSlug)
+	if err != nil {
+		e.logger.Warn("Failed to fetch users", zap.Error(err))
+		return e.createBasicUsers(workspace)
+	}
+	return users
+}
+
+// contributorUsers builds user records for the authors referenced by the
+// exported pull requests, comments and reviews and for the repository
+// collaborators, sorted by login.
+func (e *Exporter) contributorUsers(prs []data.PullRequest, regularComments []data.IssueComment,
+	reviewComments []data.PullRequestReviewComment) []data.User {
+	userURLs := make(map[string]bool)
+	for _, pr := range prs {
+		userURLs[pr.User] = true
+	}
+	for _, comment := range regularComments {
+		userURLs[comment.User] = true
+	}
+	for _, comment := range reviewComments {
+		userURLs[comment.User] = true
+	}
+	for _, review := range e.activityReviews {
+		if user, ok := review["user"].(string); ok {
+			userURLs[user] = true
+		}
+	}
+	for _, repo := range e.repositories {
+		for _, collaborator := range repo.Collaborators {
+			userURLs[collaborator.User] = true
+		}
+	}
+
+	createdAt := formatDateToZ(e.client.now().Format(time.RFC3339))
+	users := []data.User{}
+	for userURL := range userURLs {
+		ids := idsFromURL(e.client.sourceURLs(), "user", userURL)
+		if len(ids) == 0 || ids[0] == "" {
+			continue
+		}
+		login := ids[0]
+		if login == e.client.ghostLogin() {
+			// Added by addGhostUser.
+			continue
+		}
+		name := e.client.contributors[login]
+		if name == "" {
+			name = login
+		}
+		users = append(users, data.User{
+			Type:      "user",
+			URL:       userURL,
+			Login:     login,
+			Name:      name,
+			Company:   nil,
+			Website:   nil,
+			Location:  nil,
+			Emails:    e.client.userEmails(login),
+			CreatedAt: createdAt,
+		})
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Login < users[j].Login
+	})
+
+	e.logger.Info("Exporting pull request and comment authors as users",
+		zap.Int("contributors", len(users)))
+	return users
+}
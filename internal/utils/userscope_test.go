@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestValidateUsersScope(t *testing.T) {
+	for _, scope := range []string{"", UsersScopeContributors, UsersScopeWorkspace, UsersScopeNone} {
+		assert.NoError(t, ValidateUsersScope(scope), scope)
+	}
+	err := ValidateUsersScope("everyone")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--users-scope")
+}
+
+func TestUsersScopeDefaultsToWorkspace(t *testing.T) {
+	exporter := NewExporter(&Client{}, t.TempDir(), zap.NewNop(), false, "")
+	assert.Equal(t, UsersScopeWorkspace, exporter.usersScope())
+
+	exporter.flags = &data.CmdExportFlags{UsersScope: UsersScopeContributors}
+	assert.Equal(t, UsersScopeContributors, exporter.usersScope())
+}
+
+func TestRecordContributor(t *testing.T) {
+	client := &Client{}
+	client.recordContributor(data.BitbucketPRUser{UUID: "{alice}", DisplayName: "Alice"})
+	client.recordContributor(data.BitbucketPRUser{UUID: "{alice}"})
+	client.recordContributor(data.BitbucketPRUser{DisplayName: "No UUID"})
+
+	assert.Equal(t, map[string]string{"alice": "Alice"}, client.contributors)
+}
+
+func TestContributorUsers(t *testing.T) {
+	client := &Client{logger: zap.NewNop()}
+	client.recordContributor(data.BitbucketPRUser{UUID: "{bob}", DisplayName: "Bob"})
+	client.recordContributor(data.BitbucketPRUser{UUID: "{alice}", DisplayName: "Alice"})
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+
+	users := exporter.contributorUsers(
+		[]data.PullRequest{{User: format.URL("user", "ws", "", "bob")}},
+		[]data.IssueComment{{User: format.URL("user", "ws", "", "alice")}, {User: format.URL("user", "ws", "", "bob")}},
+		[]data.PullRequestReviewComment{{User: format.URL("user", "ws", "", "carol")}},
+	)
+
+	require.Len(t, users, 3)
+	assert.Equal(t, []string{"alice", "bob", "carol"}, []string{users[0].Login, users[1].Login, users[2].Login})
+	assert.Equal(t, "Alice", users[0].Name)
+	assert.Equal(t, "https://bitbucket.org/bob", users[1].URL)
+	assert.Equal(t, "carol", users[2].Name, "unknown names fall back to the login")
+	assert.NotNil(t, users[2].Emails)
+}
+
+func TestExportUsersNoneSkipsMemberLookup(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "token", "", "", "", "", zap.NewNop(), "", true)
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	exporter.flags = &data.CmdExportFlags{UsersScope: UsersScopeNone}
+
+	users := exporter.exportUsers("ws", "repo")
+	require.Len(t, users, 1)
+	assert.Equal(t, "ws", users[0].Login)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+}
+
+func TestCheckReferentialIntegrityUsersScopeNone(t *testing.T) {
+	outputDir := t.TempDir()
+	writeExportFixture(t, outputDir, usersFile, []data.User{{Type: "user", URL: "https://bitbucket.org/ws"}})
+	writeExportFixture(t, outputDir, pullRequestsFile, []data.PullRequest{
+		{URL: "https://bitbucket.org/ws/repo/pull/1", User: "https://bitbucket.org/alice"},
+	})
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+	exporter.flags = &data.CmdExportFlags{UsersScope: UsersScopeNone}
+	violations, err := exporter.checkReferentialIntegrity()
+	require.NoError(t, err)
+	assert.Empty(t, violations)
+}
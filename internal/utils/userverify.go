@@ -0,0 +1,259 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cli/go-gh/v2/pkg/api"
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+// Statuses of a user in the user verification report.
+const (
+	UserStatusMatched      = "matched"       // Email of exactly one GitHub account
+	UserStatusAmbiguous    = "ambiguous"     // Emails of several GitHub accounts
+	UserStatusUnmatched    = "unmatched"     // No GitHub account has the emails
+	UserStatusWithoutEmail = "without_email" // No email in the export
+)
+
+const (
+	// DefaultUserVerificationReport is the --output default of the
+	// verify-users command.
+	DefaultUserVerificationReport = "user-verification-report.json"
+	// DefaultSearchInterval spaces user searches to GitHub's limit of 30
+	// authenticated search requests a minute.
+	DefaultSearchInterval = 2 * time.Second
+
+	searchMaxRetries = 5
+	// searchDefaultWait is how long a rate limited search waits when the
+	// response does not say when to retry.
+	searchDefaultWait = time.Minute
+)
+
+// UserVerificationOptions configures RunUserVerification.
+type UserVerificationOptions struct {
+	ExportPath     string        // Export directory or its .tar.gz archive
+	Output         string        // Path of the report
+	SearchInterval time.Duration // Least time between user searches; 0 does not wait
+}
+
+// githubUserSearch holds the fields read from GitHub's user search.
+type githubUserSearch struct {
+	Items []struct {
+		Login string `json:"login"`
+	} `json:"items"`
+}
+
+// userSearcher searches GitHub users by email no faster than the search
+// rate limit allows, waiting out the limit when it is hit anyway.
+type userSearcher struct {
+	g              *APIGetter
+	interval       time.Duration
+	logger         *zap.Logger
+	sleep          func(ctx context.Context, d time.Duration) error
+	next           time.Time // Earliest time of the next search
+	searches       int
+	rateLimitWaits int
+}
+
+func newUserSearcher(g *APIGetter, interval time.Duration, logger *zap.Logger) *userSearcher {
+	return &userSearcher{g: g, interval: interval, logger: logger, sleep: sleepContext}
+}
+
+// RunUserVerification looks up the emails of the users of an export on
+// GitHub and writes a report of the accounts they match to opts.Output.
+// Users without exactly one matching account are predicted to become
+// mannequins.
+func RunUserVerification(ctx context.Context, opts UserVerificationOptions, g *APIGetter, logger *zap.Logger) (*data.UserVerificationReport, error) {
+	exportDir, cleanup, err := openExport(opts.ExportPath)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	users, err := readRecordFiles[data.User](exportDir, usersFile)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Verifying user emails against GitHub accounts", zap.Int("users", len(users)))
+
+	searcher := newUserSearcher(g, opts.SearchInterval, logger)
+	report := &data.UserVerificationReport{
+		Export:   opts.ExportPath,
+		Users:    len(users),
+		Accounts: []data.UserVerification{},
+	}
+	for _, user := range users {
+		account, err := searcher.verifyUser(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		switch account.Status {
+		case UserStatusMatched:
+			report.Matched++
+		case UserStatusAmbiguous:
+			report.Ambiguous++
+		case UserStatusUnmatched:
+			report.Unmatched++
+		default:
+			report.WithoutEmail++
+		}
+		report.Accounts = append(report.Accounts, account)
+	}
+	report.PredictedMannequins = report.Users - report.Matched
+	report.Searches = searcher.searches
+	report.RateLimitWaits = searcher.rateLimitWaits
+
+	out := NewExporter(&Client{logger: logger}, filepath.Dir(opts.Output), logger, false, "")
+	if err := out.writeJSONFile(filepath.Base(opts.Output), report); err != nil {
+		return nil, err
+	}
+	logger.Info("Verified user emails against GitHub accounts",
+		zap.Int("matched", report.Matched),
+		zap.Int("ambiguous", report.Ambiguous),
+		zap.Int("unmatched", report.Unmatched),
+		zap.Int("withoutEmail", report.WithoutEmail),
+		zap.Int("predictedMannequins", report.PredictedMannequins))
+	return report, nil
+}
+
+// verifyUser searches the GitHub accounts of every email of user.
+func (s *userSearcher) verifyUser(ctx context.Context, user data.User) (data.UserVerification, error) {
+	account := data.UserVerification{
+		URL:          user.URL,
+		Login:        user.Login,
+		Name:         user.Name,
+		Emails:       []string{},
+		GitHubLogins: []string{},
+	}
+	seenEmails := make(map[string]bool)
+	logins := make(map[string]string) // Lower-case login to login
+	for _, email := range user.Emails {
+		address := strings.TrimSpace(email.Address)
+		if address == "" || seenEmails[strings.ToLower(address)] {
+			continue
+		}
+		seenEmails[strings.ToLower(address)] = true
+		account.Emails = append(account.Emails, address)
+
+		found, err := s.search(ctx, address)
+		if err != nil {
+			return account, err
+		}
+		for _, login := range found {
+			logins[strings.ToLower(login)] = login
+		}
+	}
+	for _, login := range logins {
+		account.GitHubLogins = append(account.GitHubLogins, login)
+	}
+	sort.Strings(account.GitHubLogins)
+
+	switch {
+	case len(account.Emails) == 0:
+		account.Status = UserStatusWithoutEmail
+	case len(account.GitHubLogins) == 1:
+		account.Status = UserStatusMatched
+	case len(account.GitHubLogins) > 1:
+		account.Status = UserStatusAmbiguous
+	default:
+		account.Status = UserStatusUnmatched
+	}
+	return account, nil
+}
+
+// search returns the logins of the GitHub accounts with email.
+func (s *userSearcher) search(ctx context.Context, email string) ([]string, error) {
+	path := "search/users?q=" + url.QueryEscape(email+" in:email")
+	for attempt := 0; ; attempt++ {
+		if wait := time.Until(s.next); wait > 0 {
+			if err := s.sleep(ctx, wait); err != nil {
+				return nil, err
+			}
+		}
+		s.searches++
+		s.next = time.Now().Add(s.interval)
+
+		resp, err := s.g.restClient.RequestWithContext(ctx, "GET", path, nil)
+		if err != nil {
+			var httpErr *api.HTTPError
+			if !errors.As(err, &httpErr) || !isSearchRateLimited(httpErr) || attempt == searchMaxRetries {
+				return nil, fmt.Errorf("failed to search GitHub users by email: %w", err)
+			}
+			wait := searchRateLimitWait(httpErr.Headers, time.Now())
+			s.rateLimitWaits++
+			s.logger.Warn("GitHub search rate limit hit - waiting before retrying",
+				zap.Duration("delay", wait),
+				zap.Int("attempt", attempt+1),
+				zap.Int("max_retries", searchMaxRetries))
+			s.next = time.Now().Add(wait)
+			continue
+		}
+
+		var result githubUserSearch
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode GitHub user search: %w", err)
+		}
+		// Wait for the reset instead of running into the limit.
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			if reset := time.Now().Add(searchRateLimitWait(resp.Header, time.Now())); reset.After(s.next) {
+				s.next = reset
+			}
+		}
+
+		logins := make([]string, 0, len(result.Items))
+		for _, item := range result.Items {
+			logins = append(logins, item.Login)
+		}
+		return logins, nil
+	}
+}
+
+// isSearchRateLimited reports whether a failed search hit the primary or a
+// secondary rate limit rather than failing for good.
+func isSearchRateLimited(err *api.HTTPError) bool {
+	switch err.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+		return err.Headers.Get("Retry-After") != "" || err.Headers.Get("X-RateLimit-Remaining") == "0"
+	}
+	return false
+}
+
+// searchRateLimitWait returns how long to wait before searching again, from
+// the Retry-After or X-RateLimit-Reset header of a response.
+func searchRateLimitWait(header http.Header, now time.Time) time.Duration {
+	if seconds, err := strconv.Atoi(header.Get("Retry-After")); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		// One second more covers clock skew with GitHub.
+		return max(time.Unix(reset, 0).Sub(now)+time.Second, time.Second)
+	}
+	return searchDefaultWait
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// userSearchServer answers GitHub user searches from accounts, keyed by
+// email.
+func userSearchServer(t *testing.T, accounts map[string][]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/search/users", r.URL.Path)
+		email := strings.TrimSuffix(r.URL.Query().Get("q"), " in:email")
+		items := []map[string]string{}
+		for _, login := range accounts[email] {
+			items = append(items, map[string]string{"login": login})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"total_count": len(items), "items": items})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunUserVerification(t *testing.T) {
+	exportDir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, exportDir, zap.NewNop(), false, "")
+	users := []data.User{
+		{URL: "https://bitbucket.org/alice", Login: "alice", Emails: []data.Email{{Address: "alice@example.com", Primary: true}}},
+		{URL: "https://bitbucket.org/bob", Login: "bob", Emails: []data.Email{{Address: "bob@example.com"}, {Address: "BOB@example.com"}}},
+		{URL: "https://bitbucket.org/carol", Login: "carol", Emails: []data.Email{{Address: "carol@example.com"}, {Address: "c@example.com"}}},
+		{URL: "https://bitbucket.org/dave", Login: "dave", Emails: []data.Email{}},
+	}
+	require.NoError(t, writeRecordFiles(exporter, usersFile, users))
+
+	server := userSearchServer(t, map[string][]string{
+		"alice@example.com": {"alice-gh"},
+		"carol@example.com": {"carol-gh"},
+		"c@example.com":     {"carol-work"},
+	})
+	output := filepath.Join(t.TempDir(), DefaultUserVerificationReport)
+	report, err := RunUserVerification(context.Background(),
+		UserVerificationOptions{ExportPath: exportDir, Output: output}, testServerAPIGetter(t, server), zap.NewNop())
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, report.Users)
+	assert.Equal(t, 1, report.Matched)
+	assert.Equal(t, 1, report.Ambiguous)
+	assert.Equal(t, 1, report.Unmatched)
+	assert.Equal(t, 1, report.WithoutEmail)
+	assert.Equal(t, 3, report.PredictedMannequins)
+	assert.Equal(t, 4, report.Searches)
+
+	require.Len(t, report.Accounts, 4)
+	assert.Equal(t, UserStatusMatched, report.Accounts[0].Status)
+	assert.Equal(t, []string{"alice-gh"}, report.Accounts[0].GitHubLogins)
+	assert.Equal(t, []string{"bob@example.com"}, report.Accounts[1].Emails)
+	assert.Equal(t, UserStatusUnmatched, report.Accounts[1].Status)
+	assert.Equal(t, []string{"carol-gh", "carol-work"}, report.Accounts[2].GitHubLogins)
+	assert.Equal(t, UserStatusAmbiguous, report.Accounts[2].Status)
+	assert.Equal(t, UserStatusWithoutEmail, report.Accounts[3].Status)
+
+	content, err := os.ReadFile(output)
+	require.NoError(t, err)
+	var written data.UserVerificationReport
+	require.NoError(t, json.Unmarshal(content, &written))
+	assert.Equal(t, *report, written)
+}
+
+func TestUserSearcherWaitsOutRateLimit(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "7")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = fmt.Fprint(w, `{"message":"You have exceeded a secondary rate limit"}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprint(w, `{"total_count":1,"items":[{"login":"alice-gh"}]}`)
+	}))
+	defer server.Close()
+
+	searcher := newUserSearcher(testServerAPIGetter(t, server), 0, zap.NewNop())
+	var waits []time.Duration
+	searcher.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	logins, err := searcher.search(context.Background(), "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice-gh"}, logins)
+	assert.Equal(t, 2, searcher.searches)
+	assert.Equal(t, 1, searcher.rateLimitWaits)
+	require.Len(t, waits, 1)
+	assert.InDelta(t, 7*time.Second, waits[0], float64(time.Second))
+}
+
+func TestUserSearcherFailsOnOtherErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = fmt.Fprint(w, `{"message":"Validation Failed"}`)
+	}))
+	defer server.Close()
+
+	searcher := newUserSearcher(testServerAPIGetter(t, server), 0, zap.NewNop())
+	_, err := searcher.search(context.Background(), "alice@example.com")
+	assert.ErrorContains(t, err, "failed to search GitHub users by email")
+	assert.Equal(t, 0, searcher.rateLimitWaits)
+}
+
+func TestSearchRateLimitWait(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	assert.Equal(t, 30*time.Second, searchRateLimitWait(http.Header{"Retry-After": {"30"}}, now))
+	assert.Equal(t, 11*time.Second, searchRateLimitWait(http.Header{"X-Ratelimit-Reset": {"1700000010"}}, now))
+	assert.Equal(t, time.Second, searchRateLimitWait(http.Header{"X-Ratelimit-Reset": {"1699999990"}}, now))
+	assert.Equal(t, searchDefaultWait, searchRateLimitWait(http.Header{}, now))
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/i18n"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// suggestionDistance is the largest edit distance between a mistyped name
+// and a suggestion, the same as cobra uses for subcommands.
+const suggestionDistance = 2
+
+var unknownFlagPattern = regexp.MustCompile(`^unknown flag: --([^\s=]+)`)
+
+// ValidationError reports every problem found in the flags of a command, so
+// they can all be fixed before running it again.
+type ValidationError struct {
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "found %d problems:", len(e.Problems))
+	for _, problem := range e.Problems {
+		b.WriteString("\n  - ")
+		b.WriteString(strings.ReplaceAll(problem.Error(), "\n", "\n    "))
+	}
+	return b.String()
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// JoinProblems returns nil when every problem is nil, and otherwise a
+// *ValidationError listing the problems in order. Nested ValidationErrors
+// are flattened.
+func JoinProblems(problems ...error) error {
+	var joined []error
+	for _, problem := range problems {
+		var validationErr *ValidationError
+		switch {
+		case problem == nil:
+		case errors.As(problem, &validationErr):
+			joined = append(joined, validationErr.Problems...)
+		default:
+			joined = append(joined, problem)
+		}
+	}
+	if len(joined) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: joined}
+}
+
+// ValidateExportTarget checks the workspace and repository selection of a
+// command that exports, or estimates, a repository or a whole workspace.
+func ValidateExportTarget(cmdFlags *data.CmdExportFlags) error {
+	var problems []error
+	if cmdFlags.Workspace == "" {
+		problems = append(problems, i18n.Errorf(i18n.WorkspaceRequired))
+	}
+	if cmdFlags.AllRepos && cmdFlags.Repository != "" {
+		problems = append(problems, i18n.Errorf(i18n.RepoWithAllRepos))
+	}
+	if cmdFlags.Repository == "" && !cmdFlags.AllRepos {
+		problems = append(problems, i18n.Errorf(i18n.RepositoryRequired))
+	}
+	if cmdFlags.Repository != "" {
+		problems = append(problems, validateRepositorySlugs(cmdFlags.Repository))
+	}
+	if cmdFlags.GroupByProject && !cmdFlags.AllRepos {
+		problems = append(problems, errors.New("--group-by-project requires --all-repos"))
+	}
+	if (cmdFlags.IncludeReposFile != "" || cmdFlags.ExcludeReposFile != "") && !cmdFlags.AllRepos {
+		problems = append(problems, errors.New("--include-repos and --exclude-repos require --all-repos"))
+	}
+	if len(cmdFlags.SubdirSplits) > 0 && cmdFlags.AllRepos {
+		problems = append(problems, errors.New("--subdir-split cannot be combined with --all-repos"))
+	}
+	if len(cmdFlags.SubdirSplits) > 0 && len(RepositorySlugs(cmdFlags)) > 1 {
+		problems = append(problems, errors.New("--subdir-split requires a single --repo"))
+	}
+	if cmdFlags.SplitLinkBase != "" && len(cmdFlags.SubdirSplits) == 0 {
+		problems = append(problems, errors.New("--split-link-base requires --subdir-split"))
+	}
+	problems = append(problems,
+		ValidateSplitLinkBase(cmdFlags.SplitLinkBase),
+		ValidateWaveName(cmdFlags.Wave),
+		ValidateOutputPrefix(cmdFlags.OutputPrefix),
+	)
+	return JoinProblems(problems...)
+}
+
+// ValidateExportCommand checks everything about an export that is known
+// before credentials are read from the environment: the repository
+// selection, the option flags and the --config file. It runs before any
+// API request and reports every problem at once.
+func ValidateExportCommand(cmdFlags *data.CmdExportFlags) error {
+	problems := []error{ValidateExportTarget(cmdFlags), ValidateExportOptions(cmdFlags)}
+	if cmdFlags.ConfigFile != "" {
+		if _, err := LoadConfig(cmdFlags.ConfigFile); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	return JoinProblems(problems...)
+}
+
+// FlagErrorWithSuggestions is a cobra flag error function that adds the
+// closest flag names of the command to an unknown flag error.
+func FlagErrorWithSuggestions(cmd *cobra.Command, err error) error {
+	match := unknownFlagPattern.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+	var names []string
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if !flag.Hidden && flag.Deprecated == "" {
+			names = append(names, flag.Name)
+		}
+	})
+	suggestions := suggestNames(match[1], names)
+	if len(suggestions) == 0 {
+		return err
+	}
+	for i, name := range suggestions {
+		suggestions[i] = "--" + name
+	}
+	return fmt.Errorf("%w\n\nDid you mean this?\n\t%s", err, strings.Join(suggestions, "\n\t"))
+}
+
+// suggestNames returns the candidates within suggestionDistance of name, or
+// starting with it, closest first.
+func suggestNames(name string, candidates []string) []string {
+	name = strings.ToLower(name)
+	distances := map[string]int{}
+	var suggestions []string
+	for _, candidate := range candidates {
+		distance := levenshtein(name, strings.ToLower(candidate))
+		if distance <= suggestionDistance || strings.HasPrefix(strings.ToLower(candidate), name) {
+			distances[candidate] = distance
+			suggestions = append(suggestions, candidate)
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return distances[suggestions[i]] < distances[suggestions[j]]
+	})
+	return suggestions
+}
+
+// levenshtein returns the number of single-character edits that turn a
+// into b.
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
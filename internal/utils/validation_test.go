@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoinProblems(t *testing.T) {
+	assert.NoError(t, JoinProblems())
+	assert.NoError(t, JoinProblems(nil, nil))
+
+	single := errors.New("first")
+	err := JoinProblems(nil, single)
+	assert.EqualError(t, err, "first")
+	assert.ErrorIs(t, err, single)
+
+	err = JoinProblems(err, errors.New("second\nwith details"))
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, 2, "nested problems are flattened")
+	assert.Equal(t, "found 2 problems:\n  - first\n  - second\n    with details", err.Error())
+}
+
+func TestValidateExportTargetReportsAllProblems(t *testing.T) {
+	err := ValidateExportTarget(&data.CmdExportFlags{
+		GroupByProject: true,
+		SplitLinkBase:  "https://github.com/org",
+		Wave:           "bad wave",
+	})
+
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, 5)
+	assert.ErrorContains(t, err, "bitbucket workspace must be specified")
+	assert.ErrorContains(t, err, "bitbucket repository must be specified")
+	assert.ErrorContains(t, err, "--group-by-project requires --all-repos")
+	assert.ErrorContains(t, err, "--split-link-base requires --subdir-split")
+	assert.ErrorContains(t, err, "invalid wave name")
+
+	assert.NoError(t, ValidateExportTarget(&data.CmdExportFlags{Workspace: "ws", AllRepos: true, GroupByProject: true}))
+}
+
+func TestValidateExportCommand(t *testing.T) {
+	flags := &data.CmdExportFlags{
+		Workspace:   "ws",
+		Repository:  "repo",
+		PRsFromDate: "yesterday",
+		AsOf:        "2024-01-01",
+		TopUpFetch:  true,
+		ConfigFile:  writeConfigFile(t, "api:\n  endpoint_overides: {}\n"),
+	}
+
+	err := ValidateExportCommand(flags)
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, 3, "credentials are checked when the command runs")
+	assert.ErrorContains(t, err, "--prs-from-date")
+	assert.ErrorContains(t, err, "--top-up-fetch cannot be combined with --as-of")
+	assert.ErrorContains(t, err, `did you mean "endpoint_overrides"?`)
+}
+
+func TestValidateExportFlagsReportsCredentialsAndOptions(t *testing.T) {
+	err := ValidateExportFlags(&data.CmdExportFlags{Concurrency: 99, GitOutput: "tarball"})
+
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, 3)
+	assert.ErrorContains(t, err, "authentication credentials required")
+	assert.ErrorContains(t, err, "--concurrency")
+	assert.ErrorContains(t, err, "--git-output")
+}
+
+func TestFlagErrorWithSuggestions(t *testing.T) {
+	cmd := &cobra.Command{Use: "export"}
+	cmd.Flags().String("workspace", "", "")
+	cmd.Flags().String("repo", "", "")
+	cmd.Flags().Bool("hidden-flag", false, "")
+	require.NoError(t, cmd.Flags().MarkHidden("hidden-flag"))
+
+	err := FlagErrorWithSuggestions(cmd, errors.New("unknown flag: --wokspace"))
+	assert.EqualError(t, err, "unknown flag: --wokspace\n\nDid you mean this?\n\t--workspace")
+
+	err = FlagErrorWithSuggestions(cmd, errors.New("unknown flag: --rep=x"))
+	assert.Contains(t, err.Error(), "\t--repo")
+
+	err = FlagErrorWithSuggestions(cmd, errors.New("unknown flag: --hiden-flag"))
+	assert.EqualError(t, err, "unknown flag: --hiden-flag", "hidden flags are not suggested")
+
+	err = FlagErrorWithSuggestions(cmd, errors.New("invalid argument \"x\" for \"--debug\""))
+	assert.EqualError(t, err, "invalid argument \"x\" for \"--debug\"")
+}
+
+func TestSuggestNames(t *testing.T) {
+	candidates := []string{"output", "open-prs-only", "max-duration", "max-pack-size"}
+	assert.Equal(t, []string{"output"}, suggestNames("outptu", candidates))
+	assert.Equal(t, []string{"max-duration", "max-pack-size"}, suggestNames("max-", candidates))
+	assert.Empty(t, suggestNames("verbose", candidates))
+}
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, levenshtein("repo", "repo"))
+	assert.Equal(t, 1, levenshtein("repo", "rep"))
+	assert.Equal(t, 2, levenshtein("wokspace", "workspce"))
+	assert.Equal(t, 4, levenshtein("", "repo"))
+}
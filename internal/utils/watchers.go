@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const (
+	watchersFile = "watchers.json"
+
+	watchersNote = "GitHub's importer has no watcher records; ask these users to watch the repository on GitHub after the import"
+)
+
+// SetExportWatchers lists the watchers of every exported repository in
+// watchers.json next to the archive.
+func (e *Exporter) SetExportWatchers(enabled bool) {
+	e.exportWatchers = enabled
+}
+
+func (c *Client) GetRepositoryWatchers(workspace, repoSlug string) ([]data.BitbucketPRUser, error) {
+	var watchers []data.BitbucketPRUser
+	endpoint := fmt.Sprintf("repositories/%s/%s/watchers?pagelen=%d", workspace, repoSlug, c.pageLen(100))
+	for endpoint != "" {
+		var response data.BitbucketWatcherResponse
+		if err := c.makeRequest("GET", endpoint, &response); err != nil {
+			return nil, fmt.Errorf("failed to list watchers for %s/%s: %w", workspace, repoSlug, err)
+		}
+		watchers = append(watchers, response.Values...)
+		endpoint = response.Next
+	}
+	return watchers, nil
+}
+
+// collectWatchers fetches the watchers of a repository. githubName is the
+// repository name used in the archive.
+func (e *Exporter) collectWatchers(workspace, repoSlug, githubName string) {
+	if !e.exportWatchers {
+		return
+	}
+
+	entry := data.RepositoryWatchers{
+		SourceRepository: fmt.Sprintf("%s/%s", workspace, repoSlug),
+		Repository:       githubName,
+		Watchers:         []data.Watcher{},
+	}
+	watchers, err := e.client.GetRepositoryWatchers(workspace, repoSlug)
+	if err != nil {
+		e.logger.Warn("Failed to fetch repository watchers",
+			zap.String("repository", entry.SourceRepository),
+			zap.Error(err))
+		entry.Notes = append(entry.Notes, fmt.Sprintf("failed to fetch watchers: %v", err))
+	}
+	for _, watcher := range watchers {
+		uuid := strings.Trim(watcher.UUID, "{}")
+		if uuid == "" {
+			continue
+		}
+		_, mapped := e.client.userMapping.lookup(watcher.UUID, watcher.DisplayName)
+		entry.Watchers = append(entry.Watchers, data.Watcher{
+			DisplayName: watcher.DisplayName,
+			Nickname:    watcher.Nickname,
+			UUID:        uuid,
+			Login:       e.client.userLogin(watcher.UUID, watcher.DisplayName),
+			Mapped:      mapped,
+		})
+	}
+	sort.Slice(entry.Watchers, func(i, j int) bool {
+		return entry.Watchers[i].Login < entry.Watchers[j].Login
+	})
+
+	e.report.Counts.Watchers += len(entry.Watchers)
+	e.watchers = append(e.watchers, entry)
+	e.logger.Debug("Collected repository watchers",
+		zap.String("repository", entry.SourceRepository),
+		zap.Int("watchers", len(entry.Watchers)))
+}
+
+// writeWatchers writes the watchers report.
+func (e *Exporter) writeWatchers() error {
+	if !e.exportWatchers {
+		return nil
+	}
+
+	report := data.WatchersReport{Note: watchersNote, Repositories: e.watchers}
+	if report.Repositories == nil {
+		report.Repositories = []data.RepositoryWatchers{}
+	}
+	if err := e.writeJSONFile(watchersFile, report); err != nil {
+		return err
+	}
+	e.logger.Info("Wrote repository watchers (excluded from import archive)",
+		zap.String("file", watchersFile),
+		zap.Int("watchers", e.report.Counts.Watchers))
+	return nil
+}
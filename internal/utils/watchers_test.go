@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func watchersServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/private/"):
+			w.WriteHeader(http.StatusForbidden)
+		case r.URL.Query().Get("page") == "2":
+			writeResponse(t, w, []byte(`{"values": [{"uuid": "{carol}", "display_name": "Carol", "nickname": "carol"}]}`))
+		default:
+			writeResponse(t, w, []byte(fmt.Sprintf(`{"values": [
+				{"uuid": %q, "display_name": "Alice Smith", "nickname": "alice"},
+				{"uuid": "", "display_name": "Deleted"}],
+				"next": "%s/repositories/ws/repo/watchers?pagelen=100&page=2"}`, aliceUUID, server.URL)))
+		}
+	}))
+	return server
+}
+
+func TestCollectWatchers(t *testing.T) {
+	server := watchersServer(t)
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	client.SetUserMapping(testUserMapping(t))
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	exporter.SetExportWatchers(true)
+
+	exporter.collectWatchers("ws", "repo", "repo-renamed")
+
+	require.Len(t, exporter.watchers, 1)
+	entry := exporter.watchers[0]
+	assert.Equal(t, "ws/repo", entry.SourceRepository)
+	assert.Equal(t, "repo-renamed", entry.Repository)
+	assert.Equal(t, []data.Watcher{
+		{DisplayName: "Alice Smith", Nickname: "alice", UUID: strings.Trim(aliceUUID, "{}"), Login: "alice-gh", Mapped: true},
+		{DisplayName: "Carol", Nickname: "carol", UUID: "carol", Login: "carol"},
+	}, entry.Watchers, "deleted accounts are left out")
+	assert.Equal(t, 2, exporter.report.Counts.Watchers)
+}
+
+func TestCollectWatchersWithoutAccess(t *testing.T) {
+	server := watchersServer(t)
+	defer server.Close()
+
+	client := &Client{baseURL: server.URL, httpClient: server.Client(), logger: zap.NewNop()}
+	exporter := NewExporter(client, t.TempDir(), zap.NewNop(), false, "")
+	exporter.SetExportWatchers(true)
+
+	exporter.collectWatchers("ws", "private", "private")
+
+	require.Len(t, exporter.watchers, 1)
+	assert.Empty(t, exporter.watchers[0].Watchers)
+	assert.NotNil(t, exporter.watchers[0].Watchers, "watchers are written as an empty list")
+	require.Len(t, exporter.watchers[0].Notes, 1)
+	assert.Contains(t, exporter.watchers[0].Notes[0], "failed to fetch watchers")
+}
+
+func TestWriteWatchers(t *testing.T) {
+	outputDir := t.TempDir()
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, outputDir, zap.NewNop(), false, "")
+
+	require.NoError(t, exporter.writeWatchers())
+	assert.NoFileExists(t, filepath.Join(outputDir, watchersFile), "nothing is written unless requested")
+
+	exporter.SetExportWatchers(true)
+	exporter.watchers = []data.RepositoryWatchers{{SourceRepository: "ws/repo", Repository: "repo",
+		Watchers: []data.Watcher{{DisplayName: "Carol", UUID: "carol", Login: "carol"}}}}
+	require.NoError(t, exporter.writeWatchers())
+
+	content, err := os.ReadFile(filepath.Join(outputDir, watchersFile))
+	require.NoError(t, err)
+	var report data.WatchersReport
+	require.NoError(t, json.Unmarshal(content, &report))
+	assert.Equal(t, watchersNote, report.Note)
+	assert.Equal(t, exporter.watchers, report.Repositories)
+	assert.True(t, sidecarPaths[watchersFile], "the report stays out of the archive")
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// artifactNamePattern matches names that can be used in output directory,
+// archive and report names.
+var artifactNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// validateArtifactName checks a name that becomes part of the output
+// directory, archive or report names; kind names it in the error.
+func validateArtifactName(kind, value string) error {
+	if value == "" || artifactNamePattern.MatchString(value) {
+		return nil
+	}
+	return fmt.Errorf("invalid %s %q: use up to 63 letters, digits, '.', '_' or '-', starting with a letter or digit", kind, value)
+}
+
+// ValidateWaveName checks that a migration wave name can be used in output
+// directory and archive names.
+func ValidateWaveName(wave string) error {
+	return validateArtifactName("wave name", wave)
+}
+
+// DefaultOutputDir returns the timestamped output directory used when none is
+// given, tagged with the migration wave so archives of a wave sort together.
+func DefaultOutputDir(wave string) string {
+	timestamp := time.Now().Format("20060102-150405")
+	if wave != "" {
+		return fmt.Sprintf("./bitbucket-export-%s-%s", wave, timestamp)
+	}
+	return fmt.Sprintf("./bitbucket-export-%s", timestamp)
+}
+
+// wave returns the migration wave the export belongs to, if any.
+func (e *Exporter) wave() string {
+	if e.flags == nil {
+		return ""
+	}
+	return e.flags.Wave
+}
@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestValidateWaveName(t *testing.T) {
+	for _, wave := range []string{"", "wave1", "2024-Q3.payments", "wave_2"} {
+		assert.NoError(t, ValidateWaveName(wave), wave)
+	}
+	for _, wave := range []string{"../wave", "wave 1", "-wave", "wave/1"} {
+		assert.ErrorContains(t, ValidateWaveName(wave), "invalid wave name", wave)
+	}
+}
+
+func TestDefaultOutputDir(t *testing.T) {
+	assert.Regexp(t, regexp.MustCompile(`^\./bitbucket-export-\d{8}-\d{6}$`), DefaultOutputDir(""))
+	assert.Regexp(t, regexp.MustCompile(`^\./bitbucket-export-wave1-\d{8}-\d{6}$`), DefaultOutputDir("wave1"))
+}
+
+func TestReportRecordsWave(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.beginReport("workspace", []string{"repo"})
+	assert.Empty(t, exporter.report.Wave)
+
+	exporter.flags = &data.CmdExportFlags{Wave: "wave2"}
+	exporter.beginReport("workspace", []string{"repo"})
+	assert.Equal(t, "wave2", exporter.report.Wave)
+}
+
+func TestWaveInArchiveAndReportNames(t *testing.T) {
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, filepath.Join("exports", "payments"), zap.NewNop(), false, "")
+	exporter.flags = &data.CmdExportFlags{Wave: "wave1"}
+	assert.Equal(t, filepath.Join("exports", "wave1-payments.tar.gz"), exporter.archivePath())
+	assert.Equal(t, "wave1-export-report.json", exporter.reportFile())
+
+	exporter.flags.OutputPrefix = "acme-"
+	assert.Equal(t, filepath.Join("exports", "acme-wave1-payments.tar.gz"), exporter.archivePath())
+	assert.Equal(t, "acme-wave1-export-report.json", exporter.reportFile())
+
+	exporter.outputDir = filepath.Join("exports", "acme-payments")
+	assert.Equal(t, filepath.Join("exports", "acme-wave1-payments.tar.gz"), exporter.archivePath())
+
+	exporter.flags.OutputPrefix = "acme-wave1-"
+	assert.Equal(t, filepath.Join("exports", "acme-wave1-acme-payments.tar.gz"), exporter.archivePath())
+	assert.Equal(t, "acme-wave1-export-report.json", exporter.reportFile(), "a prefix carrying the wave is not tagged twice")
+
+	exporter.flags.OutputPrefix = ""
+	exporter.outputDir = filepath.Join("exports", "bitbucket-export-wave1-20240101-120000")
+	assert.Equal(t, filepath.Join("exports", "bitbucket-export-wave1-20240101-120000.tar.gz"), exporter.archivePath(),
+		"the default output directory already carries the wave")
+	exporter.outputDir = filepath.Join("exports", "wave10")
+	assert.Equal(t, filepath.Join("exports", "wave1-wave10.tar.gz"), exporter.archivePath())
+}
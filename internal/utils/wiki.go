@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// wikiRepoPath returns where the wiki of a repository is stored: next to its
+// mirror, as repositories/<workspace>/<repo>.wiki.git.
+func (e *Exporter) wikiRepoPath(workspace, repoSlug string) string {
+	return ToNativePath(filepath.Join(e.outputDir, "repositories", workspace, repoSlug+".wiki.git"))
+}
+
+// repositoryHasWiki reports whether the record of a repository has its wiki
+// enabled in Bitbucket.
+func (e *Exporter) repositoryHasWiki(repoSlug string) bool {
+	for _, repo := range e.repositories {
+		if repo.Slug == repoSlug {
+			return repo.HasWiki
+		}
+	}
+	return false
+}
+
+// exportWiki clones the wiki of a repository when it is enabled. Bitbucket
+// serves wikis as separate git repositories at <clone URL>/wiki. A wiki that
+// cannot be cloned or has no pages is left out of the archive; only
+// ErrMaxDurationExceeded and credential cleanup failures stop the export.
+func (e *Exporter) exportWiki(workspace, repoSlug, cloneURL string) error {
+	if !e.repositoryHasWiki(repoSlug) {
+		return nil
+	}
+
+	exported, err := e.cloneWiki(workspace, repoSlug, cloneURL+"/wiki")
+	if err != nil || !exported {
+		return err
+	}
+	e.recordWiki(workspace, repoSlug)
+	e.report.Counts.Wikis++
+	e.logger.Info("Wiki clone successful", zap.String("repository", repoSlug))
+	return nil
+}
+
+// cloneWiki mirrors wikiURL into the repository's wiki path and reports
+// whether a wiki with at least one ref was stored.
+func (e *Exporter) cloneWiki(workspace, repoSlug, wikiURL string) (bool, error) {
+	wikiDir := e.wikiRepoPath(workspace, repoSlug)
+	if err := os.RemoveAll(wikiDir); err != nil {
+		return false, fmt.Errorf("failed to remove existing wiki directory: %w", err)
+	}
+
+	credentialEnv, removeCredentials, err := e.client.gitCredentialHelper(wikiURL)
+	if err != nil {
+		return false, err
+	}
+
+	e.logger.Debug("Cloning wiki",
+		zap.String("repository", repoSlug),
+		zap.String("destination", wikiDir))
+	ctx, cancel := e.deadlineContext()
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", "clone", "--mirror", wikiURL, wikiDir)
+	cmd.Env = append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_ASKPASS=",
+		"SSH_ASKPASS=")
+	cmd.Env = append(cmd.Env, e.client.gitNetworkEnv()...)
+	cmd.Env = append(cmd.Env, credentialEnv...)
+
+	output, err := cmd.CombinedOutput()
+	removeCredentials()
+	if err != nil {
+		_ = os.RemoveAll(wikiDir)
+		if deadlineErr := e.checkDeadline(stageGitClone); deadlineErr != nil {
+			return false, deadlineErr
+		}
+		if e.failOnCloneError {
+			return false, fmt.Errorf("failed to clone wiki of %s: %s: %w", repoSlug, SanitizeSupportText(string(output)), err)
+		}
+		e.logger.Warn("Failed to clone wiki; the repository is exported without it",
+			zap.String("repository", repoSlug),
+			zap.String("output", SanitizeSupportText(string(output))),
+			zap.Error(err))
+		return false, nil
+	}
+
+	if err := e.scrubRepositoryCredentials(wikiDir); err != nil {
+		return false, err
+	}
+	if !hasRefs(wikiDir) {
+		e.logger.Info("Wiki has no pages; leaving it out of the archive",
+			zap.String("repository", repoSlug))
+		return false, os.RemoveAll(wikiDir)
+	}
+
+	cmd = exec.Command("git", "remote", "set-url", "origin",
+		fmt.Sprintf("https://bitbucket.org/%s/%s.git/wiki", workspace, repoSlug))
+	cmd.Dir = wikiDir
+	if err := cmd.Run(); err != nil {
+		e.logger.Warn("Failed to update wiki remote URL", zap.Error(err))
+	}
+	return true, nil
+}
+
+// recordWiki points the repository record at a wiki stored in the export
+// directory.
+func (e *Exporter) recordWiki(workspace, repoSlug string) {
+	e.updateRepositoryField(repoSlug, "wiki_url", e.client.formatURL("wiki", workspace, repoSlug))
+}
+
+// resumeWiki records a wiki cloned before an interrupted export.
+func (e *Exporter) resumeWiki(workspace, repoSlug string) {
+	if _, err := os.Stat(filepath.Join(e.wikiRepoPath(workspace, repoSlug), "HEAD")); err == nil {
+		e.recordWiki(workspace, repoSlug)
+	}
+}
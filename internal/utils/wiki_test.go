@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/pkg/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// wikiFixture returns an exporter whose record of "repo" has its wiki
+// enabled, and a clone URL whose /wiki path is a git repository with one
+// page.
+func wikiFixture(t *testing.T) (*Exporter, string) {
+	t.Helper()
+	cloneURL := filepath.Join(t.TempDir(), "repo.git")
+	wikiDir := filepath.Join(cloneURL, "wiki")
+	if err := exec.Command("git", "init", "-b", "main", wikiDir).Run(); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(wikiDir, "Home.md"), []byte("# Home\n"), 0644))
+	runGit(t, wikiDir, "add", "-A")
+	runGit(t, wikiDir, "commit", "-m", "Add home page")
+
+	exporter := NewExporter(&Client{logger: zap.NewNop()}, t.TempDir(), zap.NewNop(), false, "")
+	exporter.repositories = exporter.createRepositoriesData(&data.BitbucketRepository{
+		Name: "repo", Slug: "repo", HasWiki: true,
+	}, "workspace")
+	return exporter, cloneURL
+}
+
+func TestExportWiki(t *testing.T) {
+	exporter, cloneURL := wikiFixture(t)
+
+	require.NoError(t, exporter.exportWiki("workspace", "repo", cloneURL))
+
+	wikiDir := filepath.Join(exporter.outputDir, "repositories", "workspace", "repo.wiki.git")
+	assert.FileExists(t, filepath.Join(wikiDir, "HEAD"))
+	assert.Contains(t, runGit(t, wikiDir, "ls-tree", "--name-only", "main"), "Home.md")
+	assert.Equal(t, "https://bitbucket.org/workspace/repo.git/wiki",
+		strings.TrimSpace(runGit(t, wikiDir, "remote", "get-url", "origin")))
+
+	repo := exporter.repositories[0]
+	assert.True(t, repo.HasWiki)
+	require.NotNil(t, repo.WikiURL)
+	assert.Equal(t, "tarball://root/repositories/workspace/repo.wiki.git", *repo.WikiURL)
+	assert.Equal(t, 1, exporter.report.Counts.Wikis)
+}
+
+func TestExportWikiSkipped(t *testing.T) {
+	t.Run("wiki disabled", func(t *testing.T) {
+		exporter, cloneURL := wikiFixture(t)
+		exporter.repositories[0].HasWiki = false
+
+		require.NoError(t, exporter.exportWiki("workspace", "repo", cloneURL))
+		assert.NoDirExists(t, exporter.wikiRepoPath("workspace", "repo"))
+		assert.Nil(t, exporter.repositories[0].WikiURL)
+	})
+
+	t.Run("wiki cannot be cloned", func(t *testing.T) {
+		exporter, _ := wikiFixture(t)
+
+		require.NoError(t, exporter.exportWiki("workspace", "repo", filepath.Join(t.TempDir(), "missing.git")))
+		assert.NoDirExists(t, exporter.wikiRepoPath("workspace", "repo"))
+		assert.Nil(t, exporter.repositories[0].WikiURL)
+		assert.True(t, exporter.repositories[0].HasWiki, "the Bitbucket setting is kept")
+	})
+
+	t.Run("wiki without pages", func(t *testing.T) {
+		exporter, _ := wikiFixture(t)
+		cloneURL := filepath.Join(t.TempDir(), "empty.git")
+		runGit(t, t.TempDir(), "init", "--bare", filepath.Join(cloneURL, "wiki"))
+
+		require.NoError(t, exporter.exportWiki("workspace", "repo", cloneURL))
+		assert.NoDirExists(t, exporter.wikiRepoPath("workspace", "repo"))
+		assert.Equal(t, 0, exporter.report.Counts.Wikis)
+	})
+}
+
+func TestResumeWiki(t *testing.T) {
+	exporter, cloneURL := wikiFixture(t)
+	require.NoError(t, exporter.exportWiki("workspace", "repo", cloneURL))
+	exporter.repositories[0].WikiURL = nil
+
+	exporter.resumeWiki("workspace", "repo")
+
+	require.NotNil(t, exporter.repositories[0].WikiURL)
+	assert.Equal(t, format.URL("wiki", "workspace", "repo"), *exporter.repositories[0].WikiURL)
+}
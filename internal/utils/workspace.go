@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"go.uber.org/zap"
+)
+
+const noProjectKey = "NO_PROJECT"
+
+func (c *Client) GetWorkspaceRepositories(workspace string) ([]data.BitbucketRepository, error) {
+	c.logger.Info("Fetching workspace repositories", zap.String("workspace", workspace))
+
+	var repositories []data.BitbucketRepository
+	page := 1
+	pageLen := c.pageLen(100)
+	hasMore := true
+
+	for hasMore {
+		endpoint := fmt.Sprintf("repositories/%s?page=%d&pagelen=%d", workspace, page, pageLen)
+
+		var response data.BitbucketRepositoryResponse
+		if err := c.makeRequest("GET", endpoint, &response); err != nil {
+			c.logger.Error("Failed to fetch workspace repositories",
+				zap.String("workspace", workspace),
+				zap.Error(err))
+			return nil, fmt.Errorf("failed to list repositories for workspace %s: %w", workspace, err)
+		}
+
+		repositories = append(repositories, response.Values...)
+
+		hasMore = response.Next != ""
+		if hasMore {
+			page++
+		}
+	}
+
+	c.logger.Debug("Fetched workspace repositories",
+		zap.Int("count", len(repositories)))
+
+	return repositories, nil
+}
+
+// GroupRepositoriesByProject maps each Bitbucket project key to the sorted slugs
+// of the repositories it contains.
+func GroupRepositoriesByProject(repositories []data.BitbucketRepository) map[string][]string {
+	groups := make(map[string][]string)
+	for _, repo := range repositories {
+		key := noProjectKey
+		if repo.Project != nil && repo.Project.Key != "" {
+			key = repo.Project.Key
+		}
+		groups[key] = append(groups[key], repo.Slug)
+	}
+
+	for key := range groups {
+		sort.Strings(groups[key])
+	}
+
+	return groups
+}
+
+// ExportWorkspace exports every repository in a workspace. By default all
+// repositories share a single archive; with GroupByProject one archive is
+// produced per Bitbucket project, each carrying its own copy of the users.
+func ExportWorkspace(client *Client, cmdFlags *data.CmdExportFlags, logger *zap.Logger) ([]string, error) {
+	repositories, err := client.GetWorkspaceRepositories(cmdFlags.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	repositories = filterGitRepositories(repositories, logger)
+	if len(repositories) == 0 {
+		return nil, fmt.Errorf("no repositories found in workspace %s", cmdFlags.Workspace)
+	}
+
+	var include, exclude []string
+	if cmdFlags.IncludeReposFile != "" {
+		if include, err = LoadRepositoryList(cmdFlags.IncludeReposFile); err != nil {
+			return nil, err
+		}
+	}
+	if cmdFlags.ExcludeReposFile != "" {
+		if exclude, err = LoadRepositoryList(cmdFlags.ExcludeReposFile); err != nil {
+			return nil, err
+		}
+	}
+	repositories = FilterRepositoriesByList(repositories, include, exclude, logger)
+	if len(repositories) == 0 {
+		return nil, fmt.Errorf("no repositories in workspace %s match the repository lists", cmdFlags.Workspace)
+	}
+
+	baseOutputDir := cmdFlags.OutputDir
+	if baseOutputDir == "" {
+		baseOutputDir = DefaultOutputDir(cmdFlags.Wave)
+	}
+
+	groups := map[string][]string{}
+	if cmdFlags.GroupByProject {
+		groups = GroupRepositoriesByProject(repositories)
+	} else {
+		for _, repo := range repositories {
+			groups[""] = append(groups[""], repo.Slug)
+		}
+		sort.Strings(groups[""])
+	}
+
+	groupKeys := make([]string, 0, len(groups))
+	for key := range groups {
+		groupKeys = append(groupKeys, key)
+	}
+	sort.Strings(groupKeys)
+
+	deadline := ExportDeadline(cmdFlags)
+	var outputs []string
+	for _, key := range groupKeys {
+		outputDir := baseOutputDir
+		if key != "" {
+			outputDir = filepath.Join(baseOutputDir, key)
+			logger.Info("Exporting project",
+				zap.String("project", key),
+				zap.Strings("repositories", groups[key]))
+		}
+
+		exporter := NewExporter(client, outputDir, logger, cmdFlags.OpenPRsOnly, cmdFlags.PRsFromDate)
+		if cmdFlags.TempDir != "" {
+			exporter.SetTempDir(cmdFlags.TempDir)
+		}
+		if err := exporter.ApplyExportFlags(cmdFlags); err != nil {
+			return outputs, err
+		}
+		exporter.SetDeadline(deadline)
+
+		if err := exporter.ExportRepositories(cmdFlags.Workspace, groups[key]); err != nil {
+			return outputs, fmt.Errorf("failed to export %s: %w", describeGroup(key, cmdFlags.Workspace), err)
+		}
+		outputs = append(outputs, exporter.GetOutputPath())
+	}
+
+	return outputs, nil
+}
+
+func describeGroup(projectKey, workspace string) string {
+	if projectKey == "" {
+		return fmt.Sprintf("workspace %s", workspace)
+	}
+	return fmt.Sprintf("project %s", projectKey)
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestGetWorkspaceRepositories(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repositories/workspace", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Query().Get("page") == "1" {
+			writeResponse(t, w, []byte(fmt.Sprintf(`{
+				"values": [{"slug": "repo-a", "project": {"key": "PROJ"}}],
+				"next": "%s/repositories/workspace?page=2"
+			}`, "http://"+r.Host)))
+			return
+		}
+		writeResponse(t, w, []byte(`{"values": [{"slug": "repo-b"}], "next": ""}`))
+	}))
+	defer testServer.Close()
+
+	logger, _ := zap.NewDevelopment()
+	client := &Client{
+		baseURL:    testServer.URL,
+		httpClient: testServer.Client(),
+		logger:     logger,
+	}
+
+	repos, err := client.GetWorkspaceRepositories("workspace")
+	require.NoError(t, err)
+	require.Len(t, repos, 2)
+	assert.Equal(t, "repo-a", repos[0].Slug)
+	assert.Equal(t, "PROJ", repos[0].Project.Key)
+	assert.Nil(t, repos[1].Project)
+}
+
+func TestGetWorkspaceRepositoriesError(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer testServer.Close()
+
+	logger, _ := zap.NewDevelopment()
+	client := &Client{
+		baseURL:    testServer.URL,
+		httpClient: testServer.Client(),
+		logger:     logger,
+	}
+
+	_, err := client.GetWorkspaceRepositories("workspace")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to list repositories for workspace workspace")
+}
+
+func TestGroupRepositoriesByProject(t *testing.T) {
+	repos := []data.BitbucketRepository{
+		{Slug: "zeta", Project: &data.BitbucketProject{Key: "CORE"}},
+		{Slug: "alpha", Project: &data.BitbucketProject{Key: "CORE"}},
+		{Slug: "web", Project: &data.BitbucketProject{Key: "FE"}},
+		{Slug: "orphan"},
+	}
+
+	groups := GroupRepositoriesByProject(repos)
+
+	assert.Equal(t, []string{"alpha", "zeta"}, groups["CORE"])
+	assert.Equal(t, []string{"web"}, groups["FE"])
+	assert.Equal(t, []string{"orphan"}, groups[noProjectKey])
+}
+
+func TestExportWorkspaceNoRepositories(t *testing.T) {
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		writeResponse(t, w, []byte(`{"values": [], "next": ""}`))
+	}))
+	defer testServer.Close()
+
+	logger, _ := zap.NewDevelopment()
+	client := &Client{
+		baseURL:    testServer.URL,
+		httpClient: testServer.Client(),
+		logger:     logger,
+	}
+
+	outputs, err := ExportWorkspace(client, &data.CmdExportFlags{
+		Workspace:      "empty-ws",
+		OutputDir:      t.TempDir(),
+		AllRepos:       true,
+		GroupByProject: true,
+	}, logger)
+	assert.Error(t, err)
+	assert.Empty(t, outputs)
+	assert.True(t, strings.Contains(err.Error(), "no repositories found in workspace empty-ws"))
+}
+
+func TestExportRepositoriesRequiresRepositories(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	exporter := NewExporter(&Client{logger: logger}, t.TempDir(), logger, false, "")
+
+	err := exporter.ExportRepositories("workspace", nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no repositories specified")
+}
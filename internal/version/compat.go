@@ -0,0 +1,56 @@
+package version
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// ArchiveSchemaVersion is the migration archive schema written by this build.
+	ArchiveSchemaVersion = "1.0.1"
+	// MinimumGitVersion is the oldest git supporting every feature the
+	// exporter relies on, including `git rev-parse --show-object-format` and
+	// GIT_CONFIG_COUNT for passing clone credentials.
+	MinimumGitVersion = "2.31.0"
+)
+
+// SupportedSchemaVersions lists the archive schema versions this build can
+// produce and read back, e.g. when resuming an upload.
+var SupportedSchemaVersions = []string{ArchiveSchemaVersion}
+
+// AtLeast reports whether a dotted version such as "2.39.3" or
+// "2.42.0.windows.2" is greater than or equal to minimum. Unparseable
+// versions are never considered sufficient.
+func AtLeast(actual, minimum string) bool {
+	actualParts, ok := parseVersion(actual)
+	if !ok {
+		return false
+	}
+	minimumParts, ok := parseVersion(minimum)
+	if !ok {
+		return false
+	}
+	for i := 0; i < len(minimumParts); i++ {
+		var part int
+		if i < len(actualParts) {
+			part = actualParts[i]
+		}
+		if part != minimumParts[i] {
+			return part > minimumParts[i]
+		}
+	}
+	return true
+}
+
+func parseVersion(value string) ([]int, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "v")
+	var parts []int
+	for _, field := range strings.Split(value, ".") {
+		number, err := strconv.Atoi(field)
+		if err != nil {
+			break
+		}
+		parts = append(parts, number)
+	}
+	return parts, len(parts) > 0
+}
@@ -0,0 +1,33 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAtLeast(t *testing.T) {
+	testCases := []struct {
+		actual  string
+		minimum string
+		want    bool
+	}{
+		{"2.31.0", "2.31.0", true},
+		{"2.43.0", "2.29.0", true},
+		{"2.42.0.windows.2", "2.29.0", true},
+		{"3.0", "2.29.0", true},
+		{"2.28.9", "2.29.0", false},
+		{"1.9.5", "2.29.0", false},
+		{"v2.30", "2.29.0", true},
+		{"", "2.29.0", false},
+		{"unknown", "2.29.0", false},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.want, AtLeast(tc.actual, tc.minimum), "%s >= %s", tc.actual, tc.minimum)
+	}
+}
+
+func TestSupportedSchemaVersions(t *testing.T) {
+	assert.Contains(t, SupportedSchemaVersions, ArchiveSchemaVersion)
+}
@@ -0,0 +1,61 @@
+package version
+
+import (
+	"runtime/debug"
+)
+
+// Version, Commit, BuildDate and Distribution can be set at build time with
+// -ldflags "-X github.com/katiem0/gh-bbc-exporter/internal/version.Version=v1.2.3".
+// The GoReleaser configuration and the Dockerfile set all four.
+var (
+	Version      = ""
+	Commit       = ""
+	BuildDate    = ""
+	Distribution = "" // How the binary was built, e.g. goreleaser or docker
+)
+
+type BuildInfo struct {
+	Version      string `json:"version"`
+	Commit       string `json:"commit,omitempty"`
+	BuildDate    string `json:"build_date,omitempty"`
+	Distribution string `json:"distribution"`
+	GoVersion    string `json:"go_version"`
+}
+
+// Get returns the build information, falling back to the module and VCS
+// data embedded by the Go toolchain when no ldflags were provided.
+func Get() BuildInfo {
+	info := BuildInfo{
+		Version:      Version,
+		Commit:       Commit,
+		BuildDate:    BuildDate,
+		Distribution: Distribution,
+	}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		info.GoVersion = buildInfo.GoVersion
+		if info.Version == "" && buildInfo.Main.Version != "" && buildInfo.Main.Version != "(devel)" {
+			info.Version = buildInfo.Main.Version
+		}
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = setting.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = setting.Value
+				}
+			}
+		}
+	}
+
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Distribution == "" {
+		info.Distribution = "source"
+	}
+	return info
+}
@@ -0,0 +1,32 @@
+package version
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDefaults(t *testing.T) {
+	info := Get()
+	assert.NotEmpty(t, info.Version)
+	assert.NotEmpty(t, info.GoVersion)
+	assert.Equal(t, "source", info.Distribution)
+}
+
+func TestGetUsesLinkerValues(t *testing.T) {
+	originalVersion, originalCommit, originalDate, originalDistribution := Version, Commit, BuildDate, Distribution
+	defer func() {
+		Version, Commit, BuildDate, Distribution = originalVersion, originalCommit, originalDate, originalDistribution
+	}()
+
+	Version = "v1.2.3"
+	Commit = "abc123"
+	BuildDate = "2025-01-01T00:00:00Z"
+	Distribution = "docker"
+
+	info := Get()
+	assert.Equal(t, "v1.2.3", info.Version)
+	assert.Equal(t, "abc123", info.Commit)
+	assert.Equal(t, "2025-01-01T00:00:00Z", info.BuildDate)
+	assert.Equal(t, "docker", info.Distribution)
+}
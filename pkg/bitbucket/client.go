@@ -0,0 +1,165 @@
+// Package bitbucket is a client for the Bitbucket Cloud REST API built on the
+// exporter's API layer: retries with backoff on rate limits, response size
+// limits and schema drift logging. Collections are returned as iterators that
+// fetch pages lazily, so callers such as audit and reporting tools can stop
+// early without downloading whole workspaces.
+//
+//	client := bitbucket.NewClient(bitbucket.Options{APIToken: token, Email: email})
+//	for pr, err := range client.PullRequests(ctx, "workspace", "repo", nil) {
+//		if err != nil {
+//			return err
+//		}
+//		fmt.Println(pr.ID, pr.Title)
+//	}
+//
+// A Client is not safe for concurrent use; create one per goroutine.
+package bitbucket
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"net/url"
+	"strconv"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+	"go.uber.org/zap"
+)
+
+// DefaultBaseURL is the Bitbucket Cloud API used when Options.BaseURL is empty.
+const DefaultBaseURL = "https://api.bitbucket.org/2.0"
+
+// Bitbucket API types returned by the client.
+type (
+	Repository  = data.BitbucketRepository
+	PullRequest = data.BitbucketPR
+	Comment     = data.BitbucketComment
+)
+
+// Pull request states accepted by PullRequestOptions.
+const (
+	StateOpen       = "OPEN"
+	StateMerged     = "MERGED"
+	StateDeclined   = "DECLINED"
+	StateSuperseded = "SUPERSEDED"
+	StateAll        = "ALL"
+)
+
+// Options configures a Client. Set one authentication method: AccessToken,
+// APIToken with Email, Username with AppPassword, or OAuthKey with
+// OAuthSecret; or set several of the first three and list them in
+// AuthFallback.
+type Options struct {
+	BaseURL     string
+	AccessToken string
+	APIToken    string
+	Email       string
+	Username    string
+	AppPassword string
+	OAuthKey    string // OAuth consumer key for the client-credentials grant
+	OAuthSecret string
+	// AuthFallback lists "access-token", "api-token" and "app-password" in
+	// the order to try them when Bitbucket answers 401 or 403 for an
+	// endpoint. Each listed method needs its credentials.
+	AuthFallback []string
+	// Logger receives request and retry logs; nil disables logging.
+	Logger *zap.Logger
+}
+
+// PullRequestOptions filters PullRequests. The zero value lists pull requests
+// in every state.
+type PullRequestOptions struct {
+	// State is one of the State constants; empty means StateAll.
+	State string
+	// PageLen is the number of pull requests per API page (max 50).
+	PageLen int
+}
+
+// CommentOptions configures PullRequestComments.
+type CommentOptions struct {
+	// IncludeDeleted also returns comments that were deleted.
+	IncludeDeleted bool
+	// PageLen is the number of comments per API page (max 100).
+	PageLen int
+}
+
+// Client is a Bitbucket Cloud API client.
+type Client struct {
+	api *utils.Client
+}
+
+// NewClient returns a client for the Bitbucket Cloud API.
+func NewClient(opts Options) *Client {
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	api := utils.NewClient(baseURL, opts.AccessToken, opts.APIToken, opts.Email,
+		opts.Username, opts.AppPassword, logger, "", true)
+	api.SetOAuthConsumer(opts.OAuthKey, opts.OAuthSecret)
+	api.SetAuthFallback(opts.AuthFallback)
+	return &Client{api: api}
+}
+
+// Repository returns a single repository.
+func (c *Client) Repository(ctx context.Context, workspace, repoSlug string) (*Repository, error) {
+	var repo Repository
+	endpoint := fmt.Sprintf("repositories/%s/%s", url.PathEscape(workspace), url.PathEscape(repoSlug))
+	if err := c.api.GetJSON(ctx, endpoint, &repo); err != nil {
+		return nil, err
+	}
+	return &repo, nil
+}
+
+// Repositories iterates over the repositories of a workspace.
+func (c *Client) Repositories(ctx context.Context, workspace string) iter.Seq2[Repository, error] {
+	endpoint := fmt.Sprintf("repositories/%s?pagelen=100", url.PathEscape(workspace))
+	return paginate[Repository](ctx, c.api, endpoint)
+}
+
+// PullRequests iterates over the pull requests of a repository, newest
+// first as returned by Bitbucket.
+func (c *Client) PullRequests(ctx context.Context, workspace, repoSlug string, opts *PullRequestOptions) iter.Seq2[PullRequest, error] {
+	if opts == nil {
+		opts = &PullRequestOptions{}
+	}
+	query := url.Values{}
+	query.Set("state", StateAll)
+	if opts.State != "" {
+		query.Set("state", opts.State)
+	}
+	query.Set("pagelen", strconv.Itoa(pageLen(opts.PageLen, 50)))
+
+	endpoint := fmt.Sprintf("repositories/%s/%s/pullrequests?%s",
+		url.PathEscape(workspace), url.PathEscape(repoSlug), query.Encode())
+	return paginate[PullRequest](ctx, c.api, endpoint)
+}
+
+// PullRequestComments iterates over the comments of a pull request,
+// including inline review comments and replies.
+func (c *Client) PullRequestComments(ctx context.Context, workspace, repoSlug string, pullRequestID int, opts *CommentOptions) iter.Seq2[Comment, error] {
+	if opts == nil {
+		opts = &CommentOptions{}
+	}
+	query := url.Values{}
+	if !opts.IncludeDeleted {
+		query.Set("q", "deleted=false")
+	}
+	query.Set("pagelen", strconv.Itoa(pageLen(opts.PageLen, 100)))
+
+	endpoint := fmt.Sprintf("repositories/%s/%s/pullrequests/%d/comments?%s",
+		url.PathEscape(workspace), url.PathEscape(repoSlug), pullRequestID, query.Encode())
+	return paginate[Comment](ctx, c.api, endpoint)
+}
+
+func pageLen(requested, max int) int {
+	if requested <= 0 || requested > max {
+		return max
+	}
+	return requested
+}
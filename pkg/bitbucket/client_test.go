@@ -0,0 +1,144 @@
+package bitbucket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPagedServer(t *testing.T, pages int, requests *int32, queries chan<- string) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if queries != nil {
+			select {
+			case queries <- r.URL.RawQuery:
+			default:
+			}
+		}
+		current := 1
+		if p := r.URL.Query().Get("page"); p != "" {
+			_, _ = fmt.Sscanf(p, "%d", &current)
+		}
+		body := map[string]interface{}{
+			"values": []map[string]interface{}{
+				{"id": current*10 + 1, "title": fmt.Sprintf("PR %d", current*10+1)},
+				{"id": current*10 + 2, "title": fmt.Sprintf("PR %d", current*10+2)},
+			},
+		}
+		if current < pages {
+			body["next"] = fmt.Sprintf("%s%s?page=%d", server.URL, r.URL.Path, current+1)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPullRequestsIteratesAllPages(t *testing.T) {
+	var requests int32
+	server := newPagedServer(t, 3, &requests, nil)
+	client := NewClient(Options{BaseURL: server.URL, AccessToken: "token"})
+
+	var ids []int
+	for pr, err := range client.PullRequests(context.Background(), "ws", "repo", nil) {
+		require.NoError(t, err)
+		ids = append(ids, pr.ID)
+	}
+
+	assert.Equal(t, []int{11, 12, 21, 22, 31, 32}, ids)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestPullRequestsStopsFetchingOnBreak(t *testing.T) {
+	var requests int32
+	server := newPagedServer(t, 5, &requests, nil)
+	client := NewClient(Options{BaseURL: server.URL, AccessToken: "token"})
+
+	count := 0
+	for _, err := range client.PullRequests(context.Background(), "ws", "repo", nil) {
+		require.NoError(t, err)
+		count++
+		if count == 3 {
+			break
+		}
+	}
+
+	assert.Equal(t, 3, count)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestPullRequestsQueryOptions(t *testing.T) {
+	var requests int32
+	queries := make(chan string, 1)
+	server := newPagedServer(t, 1, &requests, queries)
+	client := NewClient(Options{BaseURL: server.URL, AccessToken: "token"})
+
+	for _, err := range client.PullRequests(context.Background(), "ws", "repo",
+		&PullRequestOptions{State: StateMerged, PageLen: 500}) {
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, "pagelen=50&state=MERGED", <-queries)
+}
+
+func TestPullRequestCommentsExcludesDeletedByDefault(t *testing.T) {
+	var requests int32
+	queries := make(chan string, 1)
+	server := newPagedServer(t, 1, &requests, queries)
+	client := NewClient(Options{BaseURL: server.URL, AccessToken: "token"})
+
+	for _, err := range client.PullRequestComments(context.Background(), "ws", "repo", 7, &CommentOptions{PageLen: 25}) {
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, "pagelen=25&q=deleted%3Dfalse", <-queries)
+}
+
+func TestPullRequestsCancelledContext(t *testing.T) {
+	var requests int32
+	server := newPagedServer(t, 2, &requests, nil)
+	client := NewClient(Options{BaseURL: server.URL, AccessToken: "token"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var errs []error
+	for _, err := range client.PullRequests(ctx, "ws", "repo", nil) {
+		errs = append(errs, err)
+	}
+
+	require.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], context.Canceled)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+}
+
+func TestRepository(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/repositories/ws/repo", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"slug":"repo","name":"Repo","is_private":true}`))
+	}))
+	defer server.Close()
+	client := NewClient(Options{BaseURL: server.URL, AccessToken: "token"})
+
+	repo, err := client.Repository(context.Background(), "ws", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, "repo", repo.Slug)
+	assert.True(t, repo.IsPrivate)
+}
+
+func TestPageLen(t *testing.T) {
+	assert.Equal(t, 50, pageLen(0, 50))
+	assert.Equal(t, 50, pageLen(80, 50))
+	assert.Equal(t, 20, pageLen(20, 50))
+}
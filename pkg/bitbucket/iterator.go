@@ -0,0 +1,37 @@
+package bitbucket
+
+import (
+	"context"
+	"iter"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+)
+
+// page is one page of a paginated Bitbucket collection.
+type page[T any] struct {
+	Values []T    `json:"values"`
+	Next   string `json:"next"`
+}
+
+// paginate follows the "next" links of a collection, fetching a page only
+// when the caller has consumed the previous one. An error is yielded once,
+// after which iteration stops.
+func paginate[T any](ctx context.Context, api *utils.Client, endpoint string) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		next := endpoint
+		for next != "" {
+			var current page[T]
+			if err := api.GetJSON(ctx, next, &current); err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, value := range current.Values {
+				if !yield(value, nil) {
+					return
+				}
+			}
+			next = current.Next
+		}
+	}
+}
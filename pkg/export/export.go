@@ -0,0 +1,288 @@
+// Package export runs the Bitbucket Cloud to GitHub migration archive export
+// from Go, for tools that embed the exporter instead of running the
+// gh bbc-exporter CLI. An export does exactly what the export command does
+// with the equivalent flags, and stops when its context is cancelled.
+//
+//	exporter, err := export.New(export.Options{
+//		APIToken:   token,
+//		Email:      email,
+//		Workspace:  "workspace",
+//		Repository: "repo",
+//		OutputDir:  "./exports",
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	result, err := exporter.Run(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	fmt.Println(result.Outputs)
+//
+// Use the bitbucket package for direct access to the Bitbucket API.
+package export
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/katiem0/gh-bbc-exporter/internal/data"
+	"github.com/katiem0/gh-bbc-exporter/internal/utils"
+	"go.uber.org/zap"
+)
+
+// DefaultBaseURL is the Bitbucket Cloud API used when Options.BaseURL is empty.
+const DefaultBaseURL = "https://api.bitbucket.org/2.0"
+
+// Values accepted by Options.UsersScope and Options.GitOutput.
+const (
+	UsersScopeContributors = utils.UsersScopeContributors
+	UsersScopeWorkspace    = utils.UsersScopeWorkspace
+	UsersScopeNone         = utils.UsersScopeNone
+
+	GitOutputMirror = utils.GitOutputMirror
+	GitOutputBundle = utils.GitOutputBundle
+	GitOutputBoth   = utils.GitOutputBoth
+)
+
+// Options configures an export. Each field matches the export command flag
+// named in its comment; empty fields use the flag's default. Set one
+// authentication method: AccessToken, APIToken with Email, Username with
+// AppPassword, or OAuthKey with OAuthSecret; or set several and list them in
+// AuthFallback in the order to try them. Export either Repository, several Repositories into one
+// archive, or, with AllRepositories, the whole workspace.
+type Options struct {
+	BaseURL      string   // --bbc-api-url
+	AccessToken  string   // --access-token
+	APIToken     string   // --api-token
+	Email        string   // --email
+	Username     string   // --user
+	AppPassword  string   // --app-password
+	OAuthKey     string   // --oauth-key
+	OAuthSecret  string   // --oauth-secret
+	AuthFallback []string // --auth-fallback
+	ConfigFile   string   // --config
+
+	Proxy           string // --proxy
+	CABundle        string // --ca-bundle
+	InsecureSkipTLS bool   // --insecure-skip-tls
+
+	Workspace        string   // --workspace
+	Repository       string   // --repo
+	Repositories     []string // --repo repeated; exported with Repository into one archive
+	AllRepositories  bool     // --all-repos
+	GroupByProject   bool     // --group-by-project
+	IncludeReposFile string   // --include-repos
+	ExcludeReposFile string   // --exclude-repos
+
+	OpenPRsOnly      bool   // --open-prs-only
+	PRsFromDate      string // --prs-from-date, YYYY-MM-DD
+	AsOf             string // --as-of, YYYY-MM-DD or RFC 3339
+	SkipCommitLookup bool   // --skip-commit-lookup
+	LocalMirror      string // --local-mirror; only with a single Repository
+	CloneRetries     int    // --clone-retries; 0 uses the default, negative disables retries
+	FailOnCloneError bool   // --fail-on-clone-error
+	SkipGit          bool   // --skip-git
+	GitDepth         int    // --git-depth; 0 clones the full history
+
+	OutputDir    string        // --output; empty uses ./bitbucket-export-TIMESTAMP
+	TempDir      string        // --temp-dir
+	Wave         string        // --wave
+	OutputPrefix string        // --output-prefix
+	GitOutput    string        // --git-output, one of the GitOutput constants
+	Encrypt      string        // --encrypt, age:<recipient> or gpg:<recipient>
+	CompactJSON  bool          // --compact-json
+	MaxDuration  time.Duration // --max-duration; 0 means no limit
+
+	// RecordsPerFile is --records-per-file; 0 uses the flag default of
+	// DefaultRecordsPerFile, a negative value writes one file per type.
+	RecordsPerFile int
+
+	UsersScope          string // --users-scope, one of the UsersScope constants
+	UserMappingFile     string // --user-mapping
+	RedactionRulesFile  string // --redaction-rules
+	DownloadAttachments bool   // --download-attachments
+	PredictPRNumbers    bool   // --predict-pr-numbers
+	PRNumberOffset      int    // --pr-number-offset
+	ExportRulesets      bool   // --export-rulesets
+	ExportWatchers      bool   // --export-watchers
+	IssueLinks          bool   // --issue-links
+	ProtectionHealth    bool   // --protection-health
+	GenerateCodeowners  bool   // --generate-codeowners
+	AnalyzeDocs         bool   // --analyze-docs
+	FeatureChecklist    bool   // --feature-checklist
+	ConvertPipelines    bool   // --convert-pipelines
+	Concurrency         int    // --concurrency
+
+	// ProtectionHealthDays is --protection-health-days; 0 uses the flag
+	// default of DefaultProtectionHealthDays.
+	ProtectionHealthDays int
+
+	// Logger receives the export log; nil disables logging.
+	Logger *zap.Logger
+}
+
+// Result describes a finished export.
+type Result struct {
+	// Outputs lists the archive, or output directory, of every export: one
+	// for a single repository, one per project with GroupByProject.
+	Outputs []string
+}
+
+// Exporter runs exports with fixed options. It is safe to reuse, but not to
+// run concurrently with the same output directory.
+type Exporter struct {
+	flags  data.CmdExportFlags
+	logger *zap.Logger
+}
+
+// New validates opts and returns an Exporter for them.
+func New(opts Options) (*Exporter, error) {
+	flags := opts.flags()
+	switch {
+	case flags.Workspace == "":
+		return nil, errors.New("a bitbucket workspace must be specified")
+	case flags.AllRepos && flags.Repository != "":
+		return nil, errors.New("set either Repository or AllRepositories, not both")
+	case strings.Contains(opts.Repository, ","):
+		return nil, errors.New("set several repositories in Repositories, not as a comma-separated Repository")
+	case !flags.AllRepos && flags.Repository == "":
+		return nil, errors.New("a bitbucket repository must be specified")
+	case flags.GroupByProject && !flags.AllRepos:
+		return nil, errors.New("option GroupByProject requires AllRepositories")
+	case (flags.IncludeReposFile != "" || flags.ExcludeReposFile != "") && !flags.AllRepos:
+		return nil, errors.New("options IncludeReposFile and ExcludeReposFile require AllRepositories")
+	}
+	if err := utils.ValidateExportTarget(&flags); err != nil {
+		return nil, err
+	}
+	if err := utils.ValidateExportFlags(&flags); err != nil {
+		return nil, err
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Exporter{flags: flags, logger: logger}, nil
+}
+
+// Run exports the configured repositories. When ctx is cancelled, API
+// requests and git commands in flight are stopped and Run returns an error
+// wrapping ctx.Err(); the export report in the output directory records how
+// far the export got.
+func (e *Exporter) Run(ctx context.Context) (*Result, error) {
+	flags := e.flags
+	if err := utils.CheckRuntimePrerequisites(utils.DetectRuntime(), flags.OutputDir, flags.TempDir, e.logger); err != nil {
+		return nil, err
+	}
+	outputs, err := utils.RunExportContext(ctx, &flags, e.logger)
+	return &Result{Outputs: outputs}, err
+}
+
+// flags returns the export command flags equivalent to opts.
+func (opts Options) flags() data.CmdExportFlags {
+	flags := data.CmdExportFlags{
+		BitbucketAPIURL:      DefaultBaseURL,
+		BitbucketAccessToken: opts.AccessToken,
+		BitbucketAPIToken:    opts.APIToken,
+		BitbucketEmail:       opts.Email,
+		BitbucketUser:        opts.Username,
+		BitbucketAppPass:     opts.AppPassword,
+		BitbucketOAuthKey:    opts.OAuthKey,
+		BitbucketOAuthSecret: opts.OAuthSecret,
+		AuthFallback:         opts.AuthFallback,
+		Proxy:                opts.Proxy,
+		CABundle:             opts.CABundle,
+		InsecureSkipTLS:      opts.InsecureSkipTLS,
+		ConfigFile:           opts.ConfigFile,
+		Workspace:            opts.Workspace,
+		Repository:           opts.repository(),
+		AllRepos:             opts.AllRepositories,
+		GroupByProject:       opts.GroupByProject,
+		IncludeReposFile:     opts.IncludeReposFile,
+		ExcludeReposFile:     opts.ExcludeReposFile,
+		OpenPRsOnly:          opts.OpenPRsOnly,
+		PRsFromDate:          opts.PRsFromDate,
+		AsOf:                 opts.AsOf,
+		SkipCommitLookup:     opts.SkipCommitLookup,
+		LocalMirror:          opts.LocalMirror,
+		CloneRetries:         utils.DefaultCloneRetries,
+		CloneRetryDelay:      utils.DefaultCloneRetryDelay,
+		CloneProtocol:        utils.CloneProtocolHTTPS,
+		FailOnCloneError:     opts.FailOnCloneError,
+		SkipGit:              opts.SkipGit,
+		GitDepth:             opts.GitDepth,
+		OutputDir:            opts.OutputDir,
+		TempDir:              opts.TempDir,
+		Wave:                 opts.Wave,
+		OutputPrefix:         opts.OutputPrefix,
+		GitOutput:            utils.GitOutputMirror,
+		Encrypt:              opts.Encrypt,
+		CompactJSON:          opts.CompactJSON,
+		MaxDuration:          opts.MaxDuration,
+		UsersScope:           utils.UsersScopeWorkspace,
+		RedactionRulesFile:   opts.RedactionRulesFile,
+		DownloadAttachments:  opts.DownloadAttachments,
+		PredictPRNumbers:     opts.PredictPRNumbers,
+		PRNumberOffset:       opts.PRNumberOffset,
+		UserMappingFile:      opts.UserMappingFile,
+		ExportRulesets:       opts.ExportRulesets,
+		ExportWatchers:       opts.ExportWatchers,
+		IssueLinks:           opts.IssueLinks,
+		ProtectionHealth:     opts.ProtectionHealth,
+		ProtectionHealthDays: utils.DefaultProtectionHealthDays,
+		GenerateCodeowners:   opts.GenerateCodeowners,
+		AnalyzeDocs:          opts.AnalyzeDocs,
+		FeatureChecklist:     opts.FeatureChecklist,
+		ConvertPipelines:     opts.ConvertPipelines,
+		Concurrency:          1,
+		RecordsPerFile:       utils.DefaultRecordsPerFile,
+		CommentFormatter:     "markdown",
+		MaxPackSize:          "1g",
+		LongPaths:            utils.LongPathsGNU,
+		TarFormat:            utils.TarFormatUSTAR,
+		Consistency:          utils.ConsistencyBestEffort,
+		GhostUser:            utils.DefaultGhostUser,
+		MergeCommitCheck:     utils.MergeCommitCheckReport,
+		ProgressFormat:       utils.ProgressFormatText,
+	}
+	if opts.BaseURL != "" {
+		flags.BitbucketAPIURL = opts.BaseURL
+	}
+	if opts.GitOutput != "" {
+		flags.GitOutput = opts.GitOutput
+	}
+	if opts.UsersScope != "" {
+		flags.UsersScope = opts.UsersScope
+	}
+	if opts.Concurrency != 0 {
+		flags.Concurrency = opts.Concurrency
+	}
+	if opts.CloneRetries > 0 {
+		flags.CloneRetries = opts.CloneRetries
+	} else if opts.CloneRetries < 0 {
+		flags.CloneRetries = 0
+	}
+	if opts.ProtectionHealthDays != 0 {
+		flags.ProtectionHealthDays = opts.ProtectionHealthDays
+	}
+	if opts.RecordsPerFile > 0 {
+		flags.RecordsPerFile = opts.RecordsPerFile
+	} else if opts.RecordsPerFile < 0 {
+		flags.RecordsPerFile = 0
+	}
+	return flags
+}
+
+// repository returns Repository and Repositories in the comma-separated form
+// of a repeated --repo flag.
+func (opts Options) repository() string {
+	repos := opts.Repositories
+	if opts.Repository != "" {
+		repos = append([]string{opts.Repository}, repos...)
+	}
+	return strings.Join(repos, ",")
+}
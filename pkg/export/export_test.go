@@ -0,0 +1,86 @@
+package export
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewValidatesOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr string
+	}{
+		{"no workspace", Options{AccessToken: "token", Repository: "repo"}, "workspace must be specified"},
+		{"no repository", Options{AccessToken: "token", Workspace: "ws"}, "repository must be specified"},
+		{"repository and all", Options{AccessToken: "token", Workspace: "ws", Repository: "repo", AllRepositories: true}, "not both"},
+		{"group without all", Options{AccessToken: "token", Workspace: "ws", Repository: "repo", GroupByProject: true}, "requires AllRepositories"},
+		{"no credentials", Options{Workspace: "ws", Repository: "repo"}, "authentication credentials required"},
+		{"comma-separated repository", Options{AccessToken: "token", Workspace: "ws", Repository: "a,b"}, "set several repositories in Repositories"},
+		{"duplicate repositories", Options{AccessToken: "token", Workspace: "ws", Repository: "a", Repositories: []string{"b", "A"}}, "more than once"},
+		{"invalid users scope", Options{AccessToken: "token", Workspace: "ws", Repository: "repo", UsersScope: "everyone"}, "--users-scope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.opts)
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestOptionsRepositories(t *testing.T) {
+	flags := Options{Repository: "a", Repositories: []string{"b", "c"}}.flags()
+	assert.Equal(t, "a,b,c", flags.Repository)
+	flags = Options{Repositories: []string{"b", "c"}}.flags()
+	assert.Equal(t, "b,c", flags.Repository)
+}
+
+func TestOptionsDefaults(t *testing.T) {
+	flags := Options{}.flags()
+	assert.Equal(t, DefaultBaseURL, flags.BitbucketAPIURL)
+	assert.Equal(t, GitOutputMirror, flags.GitOutput)
+	assert.Equal(t, UsersScopeWorkspace, flags.UsersScope)
+	assert.Equal(t, 1, flags.Concurrency)
+	assert.Equal(t, 1000, flags.RecordsPerFile)
+	assert.Equal(t, 0, Options{RecordsPerFile: -1}.flags().RecordsPerFile)
+
+	flags = Options{BaseURL: "https://gateway.example.com/2.0", GitOutput: GitOutputBoth, UsersScope: UsersScopeNone, Concurrency: 4}.flags()
+	assert.Equal(t, "https://gateway.example.com/2.0", flags.BitbucketAPIURL)
+	assert.Equal(t, GitOutputBoth, flags.GitOutput)
+	assert.Equal(t, UsersScopeNone, flags.UsersScope)
+	assert.Equal(t, 4, flags.Concurrency)
+}
+
+func TestRunCancelledContext(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Git not available for testing")
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	exporter, err := New(Options{
+		BaseURL:     server.URL,
+		AccessToken: "token",
+		Workspace:   "ws",
+		Repository:  "repo",
+		OutputDir:   filepath.Join(t.TempDir(), "export"),
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := exporter.Run(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, result.Outputs)
+	assert.Zero(t, requests, "no API requests after cancellation")
+}
@@ -0,0 +1,71 @@
+// Package format holds the formatting rules the exporter applies to the
+// records of a migration archive: timestamps, source URLs, descriptions and
+// archive paths. Companion tools, such as scripts verifying an archive or
+// custom importers, can use it to produce exactly the values the exporter
+// writes instead of re-implementing them.
+//
+//	created := format.Date(pr.CreatedOn)          // 2024-05-01T09:30:00Z
+//	link := format.URL(format.KindPR, "ws", "repo", 42)
+package format
+
+import (
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DateLayout is the timestamp layout of every date in the archive.
+const DateLayout = "2006-01-02T15:04:05Z"
+
+// dateLayouts are the layouts Date accepts, as returned by the Bitbucket API
+// and written by git.
+var dateLayouts = []string{
+	"2006-01-02T15:04:05.999999+00:00",
+	"2006-01-02T15:04:05.999999-07:00",
+	"2006-01-02T15:04:05Z",
+	"2006-01-02T15:04:05.999Z",
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02T15:04:05.999999Z",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+}
+
+var whitespacePattern = regexp.MustCompile(`\s+`)
+
+// Date converts a Bitbucket or git timestamp to UTC in DateLayout, dropping
+// fractional seconds. Timestamps without a zone are taken as UTC. It returns
+// an empty string for an empty or unrecognized timestamp.
+func Date(input string) string {
+	if input == "" {
+		return ""
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, input); err == nil {
+			return t.UTC().Format(DateLayout)
+		}
+	}
+	return ""
+}
+
+// Description collapses every run of whitespace, including line breaks, in
+// a repository description to a single space and trims both ends.
+func Description(description string) string {
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(description, " "))
+}
+
+// UnixPath returns path with forward slashes, as paths are stored in the
+// archive and its records.
+func UnixPath(path string) string {
+	return strings.ReplaceAll(path, "\\", "/")
+}
+
+// NativePath returns an archive path with the separators of the operating
+// system, for file operations.
+func NativePath(path string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ReplaceAll(path, "/", "\\")
+	}
+	return path
+}
@@ -0,0 +1,46 @@
+package format
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2024-05-01T09:30:00.123456+00:00", "2024-05-01T09:30:00Z"},
+		{"2024-05-01T11:30:00.123456+02:00", "2024-05-01T09:30:00Z"},
+		{"2024-05-01T09:30:00Z", "2024-05-01T09:30:00Z"},
+		{"2024-05-01T09:30:00.999Z", "2024-05-01T09:30:00Z"},
+		{"2024-05-01 04:30:00 -0500", "2024-05-01T09:30:00Z"},
+		{"2024-05-01T09:30:00", "2024-05-01T09:30:00Z"},
+		{"2024-05-01 09:30:00", "2024-05-01T09:30:00Z"},
+		{"2024/05/01 09:30:00", "2024-05-01T09:30:00Z"},
+		{"", ""},
+		{"yesterday", ""},
+		{"2024-05-01", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Date(tt.input), tt.input)
+	}
+}
+
+func TestDescription(t *testing.T) {
+	assert.Equal(t, "Service A: handles billing", Description("  Service A:\n\thandles   billing\r\n"))
+	assert.Equal(t, "", Description(" \n "))
+}
+
+func TestPaths(t *testing.T) {
+	assert.Equal(t, "repositories/ws/repo.git", UnixPath(`repositories\ws\repo.git`))
+	assert.Equal(t, "repositories/ws/repo.git", UnixPath("repositories/ws/repo.git"))
+
+	if runtime.GOOS == "windows" {
+		assert.Equal(t, `repositories\ws\repo.git`, NativePath("repositories/ws/repo.git"))
+	} else {
+		assert.Equal(t, "repositories/ws/repo.git", NativePath("repositories/ws/repo.git"))
+	}
+}
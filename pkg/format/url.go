@@ -0,0 +1,148 @@
+package format
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultBase is the base of source URLs when URLs.Base is empty.
+const DefaultBase = "https://bitbucket.org"
+
+// URL kinds accepted by URL. Git and wiki URLs point into the archive.
+const (
+	KindRepository      = "repository"
+	KindUser            = "user"
+	KindOrganization    = "organization"
+	KindPR              = "pr"
+	KindIssueComment    = "issue_comment"
+	KindPRReview        = "pr_review"
+	KindPRReviewComment = "pr_review_comment"
+	KindPRReviewThread  = "pr_review_thread"
+	KindGit             = "git"
+	KindWiki            = "wiki"
+)
+
+// templateIDs lists the URL kinds a template can override, with the number
+// of IDs each takes.
+var templateIDs = map[string]int{
+	KindRepository:      0,
+	KindUser:            1,
+	KindOrganization:    0,
+	KindPR:              1,
+	KindIssueComment:    2,
+	KindPRReview:        2,
+	KindPRReviewComment: 2,
+	KindPRReviewThread:  2,
+}
+
+// TemplateIDs returns the number of IDs a URL of kind takes, and false for
+// kinds a template cannot override.
+func TemplateIDs(kind string) (int, bool) {
+	ids, ok := templateIDs[kind]
+	return ids, ok
+}
+
+// TemplateKinds returns the URL kinds a template can override, sorted.
+func TemplateKinds() []string {
+	kinds := make([]string, 0, len(templateIDs))
+	for kind := range templateIDs {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// ExpandTemplate replaces the {base}, {workspace}, {repository}, {id} and
+// {sub_id} placeholders of a URL template.
+func ExpandTemplate(template, base, workspace, repoSlug string, ids ...string) string {
+	replacements := []string{
+		"{base}", base,
+		"{workspace}", workspace,
+		"{repository}", repoSlug,
+	}
+	if len(ids) > 0 {
+		replacements = append(replacements, "{id}", ids[0])
+	}
+	if len(ids) > 1 {
+		replacements = append(replacements, "{sub_id}", ids[1])
+	}
+	return strings.NewReplacer(replacements...).Replace(template)
+}
+
+// URLs generates the source URLs of archive records, like the urls section
+// of the exporter's --config file. The zero value generates Bitbucket Cloud
+// URLs.
+type URLs struct {
+	// Base replaces DefaultBase in every URL without a template.
+	Base string
+	// Templates override single URL kinds; see ExpandTemplate.
+	Templates map[string]string
+}
+
+// URL returns the source URL of kind for a record of the repository. ids
+// are the record's ID and, for comments and reviews, the pull request
+// number first. Without IDs the URL points at the collection.
+func URL(kind, workspace, repoSlug string, ids ...interface{}) string {
+	return URLs{}.URL(kind, workspace, repoSlug, ids...)
+}
+
+// URL returns the source URL of kind with u's base and templates; see the
+// package-level URL.
+func (u URLs) URL(kind, workspace, repoSlug string, ids ...interface{}) string {
+	base := DefaultBase
+	if u.Base != "" {
+		base = strings.TrimSuffix(u.Base, "/")
+	}
+	if template, ok := u.Templates[kind]; ok && len(ids) >= templateIDs[kind] {
+		values := make([]string, 0, len(ids))
+		for _, id := range ids {
+			values = append(values, fmt.Sprintf("%v", id))
+		}
+		return ExpandTemplate(template, base, workspace, repoSlug, values...)
+	}
+
+	switch kind {
+	case KindRepository:
+		return fmt.Sprintf("%s/%s/%s", base, workspace, repoSlug)
+	case KindUser:
+		userID := workspace
+		if len(ids) > 0 && ids[0] != nil {
+			userID = fmt.Sprintf("%v", ids[0])
+		}
+		return fmt.Sprintf("%s/%s", base, userID)
+	case KindOrganization:
+		return fmt.Sprintf("%s/%s", base, workspace)
+	case KindPR:
+		if len(ids) > 0 {
+			return fmt.Sprintf("%s/%s/%s/pull/%v", base, workspace, repoSlug, ids[0])
+		}
+		return fmt.Sprintf("%s/%s/%s/pulls", base, workspace, repoSlug)
+	case KindIssueComment:
+		if len(ids) > 1 {
+			return fmt.Sprintf("%s/%s/%s/pull/%v#issuecomment-%v", base, workspace, repoSlug, ids[0], ids[1])
+		}
+		return fmt.Sprintf("%s/%s/%s/pull/comments", base, workspace, repoSlug)
+	case KindPRReview:
+		if len(ids) > 1 {
+			return fmt.Sprintf("%s/%s/%s/pull/%v/files#pullrequestreview-%v", base, workspace, repoSlug, ids[0], ids[1])
+		}
+		return fmt.Sprintf("%s/%s/%s/pull/reviews", base, workspace, repoSlug)
+	case KindPRReviewComment:
+		if len(ids) > 1 {
+			return fmt.Sprintf("%s/%s/%s/pull/%v/files#r%v", base, workspace, repoSlug, ids[0], ids[1])
+		}
+		return fmt.Sprintf("%s/%s/%s/pull/comments", base, workspace, repoSlug)
+	case KindPRReviewThread:
+		if len(ids) > 1 {
+			return fmt.Sprintf("%s/%s/%s/pull/%v/files#pullrequestreviewthread-%v", base, workspace, repoSlug, ids[0], ids[1])
+		}
+		return fmt.Sprintf("%s/%s/%s/pull/threads", base, workspace, repoSlug)
+	case KindGit:
+		return fmt.Sprintf("tarball://root/repositories/%s/%s.git", workspace, repoSlug)
+	case KindWiki:
+		return fmt.Sprintf("tarball://root/repositories/%s/%s.wiki.git", workspace, repoSlug)
+	default:
+		return fmt.Sprintf("%s/%s/%s", base, workspace, repoSlug)
+	}
+}
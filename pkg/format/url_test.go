@@ -0,0 +1,69 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		kind     string
+		ids      []interface{}
+		expected string
+	}{
+		{KindRepository, nil, "https://bitbucket.org/ws/repo"},
+		{KindUser, []interface{}{"{user-uuid}"}, "https://bitbucket.org/{user-uuid}"},
+		{KindUser, nil, "https://bitbucket.org/ws"},
+		{KindOrganization, nil, "https://bitbucket.org/ws"},
+		{KindPR, []interface{}{42}, "https://bitbucket.org/ws/repo/pull/42"},
+		{KindPR, nil, "https://bitbucket.org/ws/repo/pulls"},
+		{KindIssueComment, []interface{}{42, 7}, "https://bitbucket.org/ws/repo/pull/42#issuecomment-7"},
+		{KindPRReview, []interface{}{42, 7}, "https://bitbucket.org/ws/repo/pull/42/files#pullrequestreview-7"},
+		{KindPRReview, []interface{}{42}, "https://bitbucket.org/ws/repo/pull/reviews"},
+		{KindPRReviewComment, []interface{}{42, 7}, "https://bitbucket.org/ws/repo/pull/42/files#r7"},
+		{KindPRReviewThread, []interface{}{42, 7}, "https://bitbucket.org/ws/repo/pull/42/files#pullrequestreviewthread-7"},
+		{KindGit, nil, "tarball://root/repositories/ws/repo.git"},
+		{KindWiki, nil, "tarball://root/repositories/ws/repo.wiki.git"},
+		{"unknown", nil, "https://bitbucket.org/ws/repo"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, URL(tt.kind, "ws", "repo", tt.ids...), tt.kind)
+	}
+}
+
+func TestURLsWithBaseAndTemplates(t *testing.T) {
+	urls := URLs{
+		Base: "https://bitbucket-mirror.example.com/",
+		Templates: map[string]string{
+			KindPR: "{base}/projects/{workspace}/repos/{repository}/pull-requests/{id}",
+		},
+	}
+
+	assert.Equal(t, "https://bitbucket-mirror.example.com/projects/ws/repos/repo/pull-requests/42",
+		urls.URL(KindPR, "ws", "repo", 42))
+	assert.Equal(t, "https://bitbucket-mirror.example.com/ws/repo/pulls", urls.URL(KindPR, "ws", "repo"),
+		"templates need all their IDs")
+	assert.Equal(t, "https://bitbucket-mirror.example.com/ws/repo", urls.URL(KindRepository, "ws", "repo"))
+	assert.Equal(t, "tarball://root/repositories/ws/repo.git", urls.URL(KindGit, "ws", "repo"))
+}
+
+func TestTemplateIDs(t *testing.T) {
+	ids, ok := TemplateIDs(KindIssueComment)
+	assert.True(t, ok)
+	assert.Equal(t, 2, ids)
+
+	_, ok = TemplateIDs(KindGit)
+	assert.False(t, ok, "archive URLs cannot be templated")
+
+	assert.Equal(t, []string{
+		KindIssueComment, KindOrganization, KindPR, KindPRReview,
+		KindPRReviewComment, KindPRReviewThread, KindRepository, KindUser,
+	}, TemplateKinds())
+}
+
+func TestExpandTemplate(t *testing.T) {
+	assert.Equal(t, "https://b.example.com/ws/repo/pr/1/comment/2",
+		ExpandTemplate("{base}/{workspace}/{repository}/pr/{id}/comment/{sub_id}", "https://b.example.com", "ws", "repo", "1", "2"))
+	assert.Equal(t, "https://b.example.com/{id}", ExpandTemplate("{base}/{id}", "https://b.example.com", "ws", "repo"))
+}